@@ -0,0 +1,111 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// runAnimation applies the transforms and resize to every frame of anim
+// and saves the result with the original timing and loop count
+func runAnimation(cfg *Config, r *resizer.Resizer, anim *imageio.Animation) error {
+	// Assertion 1: Options that write one still image do not apply
+	if len(cfg.Sizes) > 0 {
+		return fmt.Errorf("sizes cannot be combined with an animated input")
+	}
+
+	if cfg.DPI != 0 {
+		return fmt.Errorf("dpi cannot be recorded in an animation")
+	}
+
+	cfg.log.Info("Animated input", "frames", len(anim.Frames))
+	cfg.source(anim.Frames[0].Image.Bounds().Size())
+
+	pipeline, err := buildPipeline(cfg)
+	if err != nil {
+		return err
+	}
+
+	finishing, err := buildFinishing(cfg)
+	if err != nil {
+		return err
+	}
+
+	frames := make([]image.Image, len(anim.Frames))
+	for i := 0; i < len(frames); i++ {
+		frames[i], err = pipeline.Apply(anim.Frames[i].Image)
+		if err != nil {
+			return fmt.Errorf("transform failed on frame %d: %w", i, err)
+		}
+	}
+
+	// Assertion 2: Resize all frames with a single plan
+	if r != nil {
+		frames, err = resizeFrames(cfg, r, frames)
+		if err != nil {
+			return err
+		}
+	}
+
+	out := &imageio.Animation{
+		Frames:     make([]imageio.Frame, len(frames)),
+		LoopCount:  anim.LoopCount,
+		Background: anim.Background,
+	}
+
+	for i := 0; i < len(frames); i++ {
+		img, err := finishing.Apply(frames[i])
+		if err != nil {
+			return fmt.Errorf("transform failed on frame %d: %w", i, err)
+		}
+
+		out.Frames[i] = imageio.Frame{Image: img, Duration: anim.Frames[i].Duration, Disposal: anim.Frames[i].Disposal}
+	}
+
+	if cfg.DataURI {
+		data, err := imageio.EncodeAnimation(out, saveOptions(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to encode animation: %w", err)
+		}
+
+		return printDataURI(cfg, data)
+	}
+
+	cfg.log.Info("Saving animation", "path", pathName(cfg.OutputPath, "standard output"))
+	if cfg.OutputPath == stdio {
+		if err := imageio.WriteAnimationContext(cfg.ctx, cfg.output(), out, saveOptions(cfg)); err != nil {
+			return fmt.Errorf("failed to write animation: %w", err)
+		}
+	} else if err := imageio.SaveAnimationContext(cfg.ctx, cfg.OutputPath, out, saveOptions(cfg)); err != nil {
+		return fmt.Errorf("failed to save animation: %w", err)
+	}
+	cfg.wrote(cfg.OutputPath, out.Frames[0].Image.Bounds().Size())
+
+	success(cfg.log, "Animation completed successfully!")
+	return nil
+}
+
+// resizeFrames resizes every frame as planned for the first one
+func resizeFrames(cfg *Config, r *resizer.Resizer, frames []image.Image) ([]image.Image, error) {
+	r, size, err := planResize(cfg, r, frames[0])
+	if err != nil {
+		return nil, err
+	}
+
+	resized, err := r.ResizeFramesContext(cfg.ctx, frames)
+	cfg.bar.clear()
+	if err != nil {
+		return nil, fmt.Errorf("resize failed: %w", err)
+	}
+
+	for i := 0; i < len(resized); i++ {
+		if err := checkOutputSize(resized[i], size); err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+
+	return resized, nil
+}
@@ -0,0 +1,249 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+)
+
+const (
+	barDelay    = 500 * time.Millisecond // Quick jobs finish without a bar
+	barInterval = 100 * time.Millisecond // Time between redraws
+	barCells    = 24                     // Width of the bar itself
+	barName     = 20                     // Longest file name shown
+)
+
+// progressBar keeps one status line on the terminal, redrawn in place:
+// files done, throughput and time left for a batch, and the output rows
+// of the image being resized
+// Its methods do nothing on a nil bar, which -progress-bar off gives
+type progressBar struct {
+	out   io.Writer
+	mu    sync.Mutex
+	start time.Time
+	drawn time.Time // Last redraw, zero before the first
+	shown int       // Length of the line on screen, zero when cleared
+
+	files, filesDone int   // Zero files for a single image
+	bytes, bytesDone int64 // Input bytes, which weigh the files for the time left
+
+	name                string    // File whose rows were reported last
+	rowsStart           time.Time // First report of those rows
+	rowsDone, rowsTotal int
+}
+
+// parseProgressBar checks a -progress-bar mode
+func parseProgressBar(mode string) error {
+	switch mode {
+	case "auto", "on", "off":
+		return nil
+	default:
+		return fmt.Errorf("progress-bar must be auto, on or off, not %q", mode)
+	}
+}
+
+// newProgressBar returns the bar of a run over files inputs of bytes
+// bytes in all, nil when -progress-bar turns it off or, by default, when
+// stderr is not a terminal
+func newProgressBar(cfg *Config, files int, bytes int64) *progressBar {
+	if cfg.ProgressBar == "off" || (cfg.ProgressBar != "on" && !isTerminal(os.Stderr)) {
+		return nil
+	}
+
+	return &progressBar{out: os.Stderr, start: time.Now(), files: files, bytes: bytes}
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// rows returns the resizer progress function for the input at path
+func (b *progressBar) rows(path string) resizer.ProgressFunc {
+	if b == nil {
+		return nil
+	}
+
+	name := filepath.Base(path)
+	return func(done, total int) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		// Workers may report out of order
+		if name == b.name && total == b.rowsTotal && done < b.rowsDone {
+			return
+		}
+
+		if name != b.name || total != b.rowsTotal {
+			b.rowsStart = time.Now()
+		}
+
+		b.name, b.rowsDone, b.rowsTotal = name, done, total
+		b.draw(false)
+	}
+}
+
+// fileDone counts a finished input of size bytes
+func (b *progressBar) fileDone(size int64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.filesDone++
+	b.bytesDone += size
+	b.name, b.rowsDone, b.rowsTotal = "", 0, 0
+	b.draw(false)
+}
+
+// writer returns w printing above the bar, which is drawn again below
+// each write
+func (b *progressBar) writer(w io.Writer) io.Writer {
+	if b == nil {
+		return w
+	}
+
+	return barWriter{bar: b, out: w}
+}
+
+// barWriter is a writer sharing the terminal with a bar
+type barWriter struct {
+	bar *progressBar
+	out io.Writer
+}
+
+// Write erases the bar, writes p and draws the bar again
+func (w barWriter) Write(p []byte) (int, error) {
+	w.bar.mu.Lock()
+	defer w.bar.mu.Unlock()
+
+	w.bar.erase()
+	n, err := w.out.Write(p)
+	w.bar.draw(true)
+	return n, err
+}
+
+// clear removes the bar of a single image once it is resized, before the
+// messages that follow; a batch keeps its bar until finish
+func (b *progressBar) clear() {
+	if b == nil || b.files > 0 {
+		return
+	}
+
+	b.finish()
+}
+
+// finish removes the bar at the end of the run
+func (b *progressBar) finish() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.erase()
+	b.name, b.rowsDone, b.rowsTotal = "", 0, 0
+}
+
+// erase blanks the line on screen; the caller holds mu
+func (b *progressBar) erase() {
+	if b.shown > 0 {
+		fmt.Fprintf(b.out, "\r%s\r", strings.Repeat(" ", b.shown))
+		b.shown = 0
+	}
+}
+
+// draw redraws the line once the bar is due; the caller holds mu
+func (b *progressBar) draw(force bool) {
+	// Assertion 1: A single image has a bar only while it is resized
+	if b.files == 0 && b.rowsTotal == 0 {
+		return
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(b.start)
+	if elapsed < barDelay || (!force && now.Sub(b.drawn) < barInterval) {
+		return
+	}
+	b.drawn = now
+
+	line := b.line(elapsed)
+	pad := max(b.shown-len(line), 0)
+	fmt.Fprintf(b.out, "\r%s%s", line, strings.Repeat(" ", pad))
+	b.shown = len(line)
+}
+
+// line formats the status after elapsed of the run
+func (b *progressBar) line(elapsed time.Duration) string {
+	seconds := elapsed.Seconds()
+	rows := 0.0
+	if b.rowsTotal > 0 {
+		rows = float64(b.rowsDone) / float64(b.rowsTotal)
+	}
+
+	var sb strings.Builder
+
+	// Assertion 1: A single image shows its rows, timed from the first so
+	// decoding does not count
+	if b.files == 0 {
+		resizing := time.Since(b.rowsStart)
+		sb.WriteString(barCellsOf(rows))
+		fmt.Fprintf(&sb, " %3.0f%%  %d/%d rows  %.0f rows/s", 100*rows, b.rowsDone, b.rowsTotal, float64(b.rowsDone)/resizing.Seconds())
+		sb.WriteString(etaOf(rows, resizing))
+		return sb.String()
+	}
+
+	// A batch weighs its files by input size when they have one
+	fraction := float64(b.filesDone) / float64(b.files)
+	if b.bytes > 0 {
+		fraction = float64(b.bytesDone) / float64(b.bytes)
+	}
+
+	sb.WriteString(barCellsOf(fraction))
+	fmt.Fprintf(&sb, " %d/%d files %3.0f%%  %.1f files/s", b.filesDone, b.files, 100*fraction, float64(b.filesDone)/seconds)
+	if b.bytes > 0 {
+		fmt.Fprintf(&sb, "  %.1f MB/s", float64(b.bytesDone)/1e6/seconds)
+	}
+	sb.WriteString(etaOf(fraction, elapsed))
+
+	if b.name != "" && b.rowsTotal > 0 {
+		name := b.name
+		if len(name) > barName {
+			name = name[:barName-3] + "..."
+		}
+		fmt.Fprintf(&sb, "  %s %.0f%%", name, 100*rows)
+	}
+
+	return sb.String()
+}
+
+// barCellsOf draws the bar itself for fraction done
+func barCellsOf(fraction float64) string {
+	full := min(max(int(fraction*barCells), 0), barCells)
+	return "[" + strings.Repeat("#", full) + strings.Repeat("-", barCells-full) + "]"
+}
+
+// etaOf estimates the time left from the fraction done after elapsed
+func etaOf(fraction float64, elapsed time.Duration) string {
+	if fraction <= 0 || fraction >= 1 {
+		return ""
+	}
+
+	left := time.Duration(float64(elapsed) * (1 - fraction) / fraction).Round(time.Second)
+	return "  ETA " + left.String()
+}
@@ -0,0 +1,475 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// maxConcurrency bounds the files a batch processes at once
+const maxConcurrency = 256
+
+// batchJob is one image of a directory input and the output it is
+// written to, which may still hold {w} and {h} for -sizes
+type batchJob struct {
+	input  string
+	output string
+	size   int64   // Input bytes, weighing the job in the progress bar
+	cfg    *Config // Options of a job file row, nil for the shared ones
+}
+
+// batchResult reports how one job ended
+type batchResult struct {
+	job     batchJob
+	err     error
+	hash    string      // Input hash for the manifest, empty without one
+	options string      // Options hash for the manifest, empty without one
+	skipped bool        // Completed by an earlier run, found in the manifest
+	result  *fileResult // What the run read and wrote, nil when it never ran
+}
+
+// runBatch resizes every image of the input directory with the options of
+// cfg, -concurrency files at a time; a file that fails is reported and
+// the rest go on, the batch failing as a whole at the end
+// An interrupt lets the files being resized stop and skips the others;
+// with -manifest each completed file is recorded, and -resume skips the
+// files an earlier run recorded
+func runBatch(cfg *Config) error {
+	jobs, err := batchJobs(cfg)
+	if err != nil {
+		return err
+	}
+
+	done, err := openManifest(cfg)
+	if err != nil {
+		return err
+	}
+	defer done.close()
+
+	// Assertion 1: Create the output directory unless names are templated
+	// or it is a remote prefix
+	if batchDirectory(cfg) && !imageio.IsRemote(cfg.OutputPath) {
+		if err := os.MkdirAll(cfg.OutputPath, 0o755); err != nil {
+			return fmt.Errorf("cannot create output directory: %w", err)
+		}
+	}
+
+	workers := cfg.Concurrency
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(jobs))
+
+	cfg.log.Info("Processing images", "count", len(jobs), "from", batchSource(cfg), "workers", workers)
+
+	var bytes int64
+	for _, job := range jobs {
+		bytes += job.size
+	}
+
+	// The files share the bar, each reporting its rows, and the messages
+	// are written above it
+	bar := newProgressBar(cfg, len(jobs), bytes)
+	cfg.bar = bar
+	cfg.log = newLogger(cfg, bar)
+
+	queue := make(chan batchJob)
+	results := make(chan batchResult)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for job := range queue {
+				if interrupted(cfg) {
+					results <- batchResult{job: job, err: errInterrupted}
+					continue
+				}
+
+				results <- runBatchJob(cfg, job, done)
+			}
+		}()
+	}
+
+	go func() {
+		for _, job := range jobs {
+			queue <- job
+		}
+		close(queue)
+	}()
+
+	failed, resized, skipped := 0, 0, 0
+	for range jobs {
+		result := <-results
+		bar.fileDone(result.job.size)
+		if errors.Is(result.err, errInterrupted) {
+			continue
+		}
+
+		if result.skipped {
+			cfg.log.Debug("Skipped, done before", "input", result.job.input)
+			skipped++
+			printBatchRecord(cfg, result)
+			continue
+		}
+
+		if result.err == nil {
+			result.err = done.record(result.job, result.hash, result.options)
+		}
+		printBatchRecord(cfg, result)
+
+		if result.err != nil {
+			cfg.log.Error("Failed", "input", result.job.input, "error", result.err)
+			failed++
+			continue
+		}
+
+		success(cfg.log, "Resized", "input", result.job.input)
+		resized++
+	}
+	bar.finish()
+
+	if skipped > 0 {
+		cfg.log.Info("Skipped images done before", "count", skipped, "manifest", cfg.Manifest)
+	}
+
+	// Assertion 2: Report an interrupt and failures as a whole
+	if interrupted(cfg) {
+		return fmt.Errorf("%w after resizing %d of %d images", errInterrupted, resized+skipped, len(jobs))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed", failed, len(jobs))
+	}
+
+	success(cfg.log, "Processed images successfully!", "count", len(jobs))
+	return nil
+}
+
+// printBatchRecord prints the -json record of a job that ended; a failed
+// print is logged since the files go on
+func printBatchRecord(cfg *Config, result batchResult) {
+	if !cfg.JSON {
+		return
+	}
+
+	rec := result.result.record(result.job.input, result.job.output, result.err)
+	if result.skipped {
+		rec.Skipped, rec.BytesIn = true, result.job.size
+	}
+	if err := printRecord(rec); err != nil {
+		cfg.log.Error(err.Error())
+	}
+}
+
+// runBatchJob runs one job unless the manifest shows an earlier run
+// completed it, hashing the input first when there is a manifest
+func runBatchJob(cfg *Config, job batchJob, done *manifest) batchResult {
+	if done == nil {
+		result, err := runBatchFile(cfg, job)
+		return batchResult{job: job, err: err, result: result}
+	}
+
+	hash, err := inputHash(cfg.ctx, job.input)
+	if err != nil {
+		return batchResult{job: job, err: fmt.Errorf("cannot hash input: %w", err)}
+	}
+
+	options := jobOptions(cfg, job)
+	if done.completed(cfg.ctx, job, hash, options) {
+		return batchResult{job: job, hash: hash, options: options, skipped: true}
+	}
+
+	result, err := runBatchFile(cfg, job)
+	return batchResult{job: job, hash: hash, options: options, err: err, result: result}
+}
+
+// runBatchFile runs one job on its own copy of cfg, turning a decoder
+// panic on a corrupt file into its error
+func runBatchFile(cfg *Config, job batchJob) (result *fileResult, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("internal error: %v", p)
+		}
+	}()
+
+	file := *cfg
+	if job.cfg != nil {
+		file = *job.cfg
+		file.ctx, file.bar, file.hooks, file.Verbose = cfg.ctx, cfg.bar, cfg.hooks, cfg.Verbose
+	}
+	file.InputPath = job.input
+	file.OutputPath = job.output
+	file.batch = false
+
+	// The steps of files running side by side would interleave, so each
+	// file reports one line when it ends unless -verbose asks for them
+	file.log = cfg.log.With("input", job.input)
+	if !cfg.Verbose {
+		file.log = slog.New(atLeast{file.log.Handler(), slog.LevelWarn})
+	}
+
+	// Assertion 1: The encoding options must suit this output
+	if err := validateOutput(&file); err != nil {
+		return nil, err
+	}
+
+	err = run(&file)
+	return file.result, err
+}
+
+// batchJobs lists the images of the input directory, in name order, with
+// their output paths, or those of the -files list or the job file, refusing outputs that
+// would overwrite an input or each other
+func batchJobs(cfg *Config) ([]batchJob, error) {
+	var jobs []batchJob
+	switch {
+	case cfg.JobFile != "":
+		listed, err := jobFileJobs(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = listed
+	case cfg.Files != "":
+		listed, err := fileListJobs(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = listed
+	default:
+		inputs, err := batchInputs(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rel := range inputs {
+			jobs = append(jobs, batchJob{input: joinPath(cfg.InputPath, rel), output: batchOutput(cfg, rel)})
+		}
+	}
+
+	taken := make(map[string]string)
+	for i := range jobs {
+		job := &jobs[i]
+		if info, err := os.Stat(job.input); err == nil {
+			job.size = info.Size()
+		}
+
+		// Assertion 1: Every output must be a new file of its own
+		if filepath.Clean(job.output) == filepath.Clean(job.input) {
+			return nil, fmt.Errorf("output %s would overwrite its input", job.output)
+		}
+
+		if other, ok := taken[job.output]; ok {
+			return nil, fmt.Errorf("%s and %s would both be written to %s", other, job.input, job.output)
+		}
+
+		taken[job.output] = job.input
+	}
+
+	// Assertion 2: An empty batch is most likely a wrong path or filter
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no images found in %s", batchSource(cfg))
+	}
+
+	return jobs, nil
+}
+
+// batchSource names where the images of a batch are listed
+func batchSource(cfg *Config) string {
+	switch {
+	case cfg.JobFile != "":
+		return cfg.JobFile
+	case cfg.Files != "":
+		return pathName(cfg.Files, "standard input")
+	default:
+		return cfg.InputPath
+	}
+}
+
+// batchInputs returns the images of the input directory that pass the
+// filters, relative to it: its own files, or with -recursive those of
+// every directory below it too, apart from an output directory inside it
+// Links to directories are not followed, so the walk cannot loop
+func batchInputs(cfg *Config) ([]string, error) {
+	filter, err := parseBatchFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if imageio.IsRemote(cfg.InputPath) {
+		return remoteInputs(cfg, filter)
+	}
+
+	if !cfg.Recursive {
+		entries, err := os.ReadDir(cfg.InputPath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read input directory: %w", err)
+		}
+
+		var inputs []string
+		for _, entry := range entries {
+			if filter.file(entry.Name()) && isBatchImage(filepath.Join(cfg.InputPath, entry.Name())) {
+				inputs = append(inputs, entry.Name())
+			}
+		}
+
+		return inputs, nil
+	}
+
+	var output string
+	if batchDirectory(cfg) {
+		output = filepath.Clean(cfg.OutputPath)
+	}
+
+	// WalkDir visits the entries of each directory in name order
+	var inputs []string
+	err = filepath.WalkDir(cfg.InputPath, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(cfg.InputPath, file)
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			if rel != "." && (filter.excluded(rel) || filepath.Clean(file) == output) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if filter.file(rel) && isBatchImage(file) {
+			inputs = append(inputs, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read input directory: %w", err)
+	}
+
+	return inputs, nil
+}
+
+// batchFilter selects the files of a directory input by -ext, -include
+// and -exclude
+type batchFilter struct {
+	extensions []string // Lower case with the dot, empty takes every readable format
+	include    []string // Patterns one of which a file must match, empty takes all
+	exclude    []string // Patterns skipping the files and directories they match
+}
+
+// parseBatchFilter reads the filters of cfg, checking every extension is
+// readable and every pattern well formed
+func parseBatchFilter(cfg *Config) (batchFilter, error) {
+	filter := batchFilter{include: splitList(cfg.Include), exclude: splitList(cfg.Exclude)}
+
+	for _, ext := range splitList(cfg.Extensions) {
+		ext = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+		if !slices.Contains(imageio.SupportedFormats, ext) {
+			return batchFilter{}, fmt.Errorf("invalid ext: %s is not a readable format", ext)
+		}
+
+		filter.extensions = append(filter.extensions, ext)
+	}
+
+	for _, pattern := range slices.Concat(filter.include, filter.exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return batchFilter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return filter, nil
+}
+
+// file reports whether the file at rel, relative to the input directory,
+// passes the filters
+func (f batchFilter) file(rel string) bool {
+	ext := strings.ToLower(filepath.Ext(rel))
+	if len(f.extensions) > 0 && !slices.Contains(f.extensions, ext) {
+		return false
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include, rel) {
+		return false
+	}
+
+	return !f.excluded(rel)
+}
+
+// excluded reports whether an -exclude pattern matches rel
+func (f batchFilter) excluded(rel string) bool {
+	return matchAny(f.exclude, rel)
+}
+
+// matchAny reports whether one of patterns matches rel: a pattern with a
+// slash against the whole relative path, where * stops at slashes, and
+// one without against the last element, as .gitignore does
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBatchImage reports whether path is a regular file, or a link to one,
+// with an extension of a readable format
+func isBatchImage(path string) bool {
+	if !slices.Contains(imageio.SupportedFormats, strings.ToLower(filepath.Ext(path))) {
+		return false
+	}
+
+	stat, err := os.Stat(path)
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// batchDirectory reports whether a batch writes into the output directory
+// under the input names, rather than to a {name} path template
+func batchDirectory(cfg *Config) bool {
+	return cfg.batch && cfg.JobFile == "" && !strings.Contains(cfg.OutputPath, "{name}")
+}
+
+// batchOutput returns the output path of the input at rel, relative to
+// the input directory: the -output template with {name} expanded, or the
+// input name at the same relative place in the output directory, given
+// the -format extension and a size suffix when there are several sizes
+func batchOutput(cfg *Config, rel string) string {
+	name := strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+
+	if !batchDirectory(cfg) {
+		return strings.ReplaceAll(cfg.OutputPath, "{name}", name)
+	}
+
+	ext := filepath.Ext(rel)
+	if cfg.Format != "" {
+		ext = cfg.Format
+	}
+
+	if len(cfg.Sizes) > 1 && ext != ".ico" {
+		name += "-{w}x{h}"
+	}
+
+	return joinPath(cfg.OutputPath, filepath.Join(filepath.Dir(rel), name+ext))
+}
@@ -0,0 +1,65 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// errInterrupted reports a run stopped by an interrupt signal
+var errInterrupted = errors.New("interrupted")
+
+// errTimedOut reports an image given up on after -timeout
+var errTimedOut = errors.New("timed out")
+
+// interruptContext returns a context ended by the first interrupt or
+// termination signal, after which a second one stops the process at once
+// as it would without the handler
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return ctx, stop
+}
+
+// imageContext returns the context of cfg bounded by -timeout, for one
+// image
+func imageContext(cfg *Config) (context.Context, context.CancelFunc) {
+	ctx := cfg.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if cfg.Timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, cfg.Timeout)
+}
+
+// stopped replaces the error of a run whose context ended, which names
+// whatever step noticed, by the interrupt or timeout that ended it
+func stopped(cfg *Config, err error) error {
+	if err == nil || cfg.ctx.Err() == nil {
+		return err
+	}
+
+	if errors.Is(cfg.ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %v", errTimedOut, cfg.Timeout)
+	}
+
+	return errInterrupted
+}
+
+// interrupted reports whether an interrupt has ended the run of cfg
+func interrupted(cfg *Config) bool {
+	return cfg.ctx != nil && cfg.ctx.Err() != nil
+}
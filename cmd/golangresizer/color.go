@@ -0,0 +1,83 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Color modes of -color
+const (
+	colorAuto   = "auto"   // Color on a terminal unless NO_COLOR is set
+	colorAlways = "always" // Color even when redirected
+	colorNever  = "never"  // Plain text
+)
+
+// ANSI colors of the status messages
+const (
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiGreen  = "\x1b[32m"
+	ansiReset  = "\x1b[0m"
+)
+
+// levelSuccess marks the messages that report work done, shown in green;
+// it sits just above info so -quiet drops them with the other steps
+const levelSuccess = slog.LevelInfo + 1
+
+// parseColor checks a -color mode
+func parseColor(mode string) error {
+	switch mode {
+	case colorAuto, colorAlways, colorNever:
+		return nil
+	default:
+		return fmt.Errorf("color must be auto, always or never, not %q", mode)
+	}
+}
+
+// useColor reports whether text written to f is colored in mode: auto
+// colors a terminal unless the NO_COLOR variable is set to anything, and
+// the explicit modes override it
+func useColor(mode string, f *os.File) bool {
+	switch mode {
+	case colorNever:
+		return false
+	case colorAlways:
+		enableColor(f)
+		return true
+	default:
+		return os.Getenv("NO_COLOR") == "" && isTerminal(f) && enableColor(f)
+	}
+}
+
+// paint wraps s in color when on is set
+func paint(on bool, color, s string) string {
+	if !on {
+		return s
+	}
+
+	return color + s + ansiReset
+}
+
+// success logs msg at levelSuccess
+func success(log *slog.Logger, msg string, args ...any) {
+	log.Log(context.Background(), levelSuccess, msg, args...)
+}
+
+// levelName names levelSuccess as info in JSON records, where it is an
+// ordinary message
+func levelName(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && a.Value.Any() == levelSuccess {
+		a.Value = slog.StringValue(slog.LevelInfo.String())
+	}
+
+	return a
+}
+
+// printError prints err on stderr the way the logger prints errors, for
+// failures before the options are parsed
+func printError(err error) {
+	fmt.Fprintf(os.Stderr, "%s %v\n", paint(useColor(colorAuto, os.Stderr), ansiRed, "Error:"), err)
+}
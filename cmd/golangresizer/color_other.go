@@ -0,0 +1,13 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableColor reports whether f understands escape sequences, which
+// terminals here always do
+func enableColor(*os.File) bool {
+	return true
+}
@@ -0,0 +1,34 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableVirtualTerminal is the console mode flag that makes the Windows
+// console interpret ANSI escape sequences
+const enableVirtualTerminal = 0x0004
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// enableColor turns on escape sequences for the console f, reporting
+// whether it understands them; redirected output is left alone
+func enableColor(f *os.File) bool {
+	handle := syscall.Handle(f.Fd())
+
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return false
+	}
+
+	if mode&enableVirtualTerminal != 0 {
+		return true
+	}
+
+	ok, _, _ := setConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminal))
+	return ok != 0
+}
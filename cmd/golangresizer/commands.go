@@ -0,0 +1,207 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The subcommands taking the image options
+const (
+	resizeCommand  = "resize"
+	convertCommand = "convert"
+	batchCommand   = "batch"
+	helpCommand    = "help"
+)
+
+// errReported ends a command whose error was already printed or logged
+var errReported = errors.New("error reported")
+
+// command is one subcommand of the tool
+type command struct {
+	name    string
+	usage   string // Arguments shown after the name
+	summary string
+	run     func(args []string) error
+}
+
+// commandList returns the subcommands in the order help lists them
+func commandList() []command {
+	return []command{
+		{resizeCommand, "-i <file> -o <file> -w <pixels> [options]", "Resize, crop or transform one image; the default without a command", imageCommand(resizeCommand)},
+		{convertCommand, "-i <file> -o <file> -f <format> [options]", "Write one image in another format or encoding, without resizing it", imageCommand(convertCommand)},
+		{batchCommand, "-i <dir> -o <dir> -w <pixels> [-recursive] [options]", "Resize every image of a directory", imageCommand(batchCommand)},
+		{infoCommand, "[-json] <file>...", "Describe image files", runInfo},
+		{compareCommand, "[-diff <file>] [-json] <reference> <image>", "Measure how far an image differs from a reference", runCompare},
+		{tilesCommand, "-i <file> -o <path> [-layout dzi|iiif] [-tile-size 256]", "Cut a zoomable tile pyramid", runTiles},
+		{serveCommand, "[-addr :8080] [-allow-hosts cdn.example.com] [options]", "Resize images posted or fetched over HTTP", runServe},
+	}
+}
+
+// findCommand returns the subcommand called name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commandList() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+
+	return command{}, false
+}
+
+// runCommand runs the subcommand name with args, or the image options
+// without a command when name is empty
+func runCommand(name string, args []string) error {
+	// Assertion 1: Without a command every image flag applies
+	if name == "" {
+		return runImageCommand("", args)
+	}
+
+	if name == helpCommand {
+		return runHelp(args)
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		return fmt.Errorf("unknown command %q, run golangresizer help for the list", name)
+	}
+
+	return cmd.run(args)
+}
+
+// runHelp prints the manual, or the options of the command named by the
+// only argument
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		printHelp()
+		return nil
+	}
+
+	// Assertion 1: One known command
+	cmd, ok := findCommand(args[0])
+	if len(args) > 1 || !ok {
+		return fmt.Errorf("help takes one of the commands it lists")
+	}
+
+	switch cmd.name {
+	case resizeCommand, convertCommand, batchCommand:
+		fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		imageFlags(fs, &Config{}, cmd.name)
+		printCommandHelp(os.Stdout, cmd, fs)
+		return nil
+	default:
+		return cmd.run([]string{"-help"})
+	}
+}
+
+// printCommands lists the subcommands with their summaries
+func printCommands(w io.Writer) {
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// printCommandHelp prints the usage and the options of one image command
+func printCommandHelp(w io.Writer, cmd command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: golangresizer %s %s\n\n%s\n\nOptions:\n", cmd.name, cmd.usage, cmd.summary)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// imageCommand returns the run function of an image command
+func imageCommand(name string) func(args []string) error {
+	return func(args []string) error {
+		return runImageCommand(name, args)
+	}
+}
+
+// runImageCommand parses the image options of command from args and runs
+// them, once per image for a directory input
+func runImageCommand(name string, args []string) error {
+	fs := flag.NewFlagSet("golangresizer", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, name, args)
+
+	// Assertion 1: The options must parse; the manual or the command's own
+	// options follow the error
+	if err != nil {
+		help := errors.Is(err, flag.ErrHelp)
+		if !help {
+			printError(err)
+			fmt.Fprintln(os.Stderr)
+		}
+
+		printImageHelp(name, fs)
+		if help {
+			return nil
+		}
+
+		return errReported
+	}
+
+	// Handle help flag
+	if cfg.ShowHelp {
+		printImageHelp(name, fs)
+		return nil
+	}
+
+	// Handle version flag
+	if cfg.ShowVer {
+		return printVersion(cfg.JSON)
+	}
+
+	// Status messages keep stdout for the data URI or the image alone
+	if !cfg.batch {
+		cfg.bar = newProgressBar(cfg, 0, 0)
+	}
+	cfg.log = newLogger(cfg, cfg.bar)
+
+	// Profile the run when asked, so slow jobs can be measured as they are
+	stopProfiles, err := cfg.Profile.start()
+	if err != nil {
+		cfg.log.Error(err.Error())
+		return errReported
+	}
+
+	// Ctrl-C lets the image at hand stop cleanly instead of killing it
+	// while its output is half written
+	ctx, stop := interruptContext()
+	cfg.ctx = ctx
+
+	// Execute main logic, once per image for a directory input
+	if cfg.batch {
+		err = runBatch(cfg)
+	} else {
+		err = run(cfg)
+		if cfg.JSON {
+			if printErr := printRecord(cfg.result.record(cfg.InputPath, cfg.OutputPath, err)); err == nil {
+				err = printErr
+			}
+		}
+	}
+	stop()
+	if stopErr := stopProfiles(); err == nil {
+		err = stopErr
+	}
+
+	if err != nil {
+		cfg.log.Error(err.Error())
+		return errReported
+	}
+
+	return nil
+}
+
+// printImageHelp prints the manual without a command, or the options of
+// the image command name
+func printImageHelp(name string, fs *flag.FlagSet) {
+	cmd, ok := findCommand(name)
+	if !ok {
+		printHelp()
+		return
+	}
+
+	printCommandHelp(os.Stdout, cmd, fs)
+}
@@ -0,0 +1,249 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// compareCommand is the subcommand name that measures image differences
+const compareCommand = "compare"
+
+// ssimWindow is the side of the square windows SSIM is averaged over, and
+// ssimStep the distance between them
+const (
+	ssimWindow = 8
+	ssimStep   = 4
+)
+
+// comparison is what the compare subcommand reports; differences are in
+// 8-bit levels over the red, green, blue and alpha samples
+type comparison struct {
+	Reference string   `json:"reference"`
+	Image     string   `json:"image"`
+	Width     int      `json:"width"`
+	Height    int      `json:"height"`
+	PSNR      *float64 `json:"psnr"` // Decibels, null for identical images
+	RMSE      float64  `json:"rmse"`
+	MAE       float64  `json:"mae"`
+	MaxDiff   float64  `json:"maxDiff"`
+	Differing float64  `json:"differing"` // Fraction of pixels off by half a level or more
+	SSIM      float64  `json:"ssim"`      // Structural similarity of the luma, 1 for identical images
+}
+
+// runCompare parses the compare subcommand arguments and measures how far
+// the image differs from the reference, failing under -min-psnr
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet(compareCommand, flag.ContinueOnError)
+
+	var diffPath string
+	var asJSON, noAutoOrient bool
+	var minPSNR float64
+	fs.StringVar(&diffPath, "diff", "", "Write an image of the differences to this file, the largest one white")
+	fs.BoolVar(&asJSON, "json", false, "Print a JSON object instead of text")
+	fs.Float64Var(&minPSNR, "min-psnr", 0, "Fail when the PSNR is below this many decibels, for checks in scripts")
+	fs.BoolVar(&noAutoOrient, "no-auto-orient", false, "Compare the stored pixel orientation instead of applying the EXIF orientation")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Assertion 1: Exactly a reference and an image
+	if fs.NArg() != 2 {
+		return fmt.Errorf("compare needs a reference and an image")
+	}
+
+	if minPSNR < 0 {
+		return fmt.Errorf("min-psnr must not be negative")
+	}
+
+	opts := imageio.DecodeOptions{AutoOrient: !noAutoOrient}
+	ref, err := imageio.LoadImageWithOptions(fs.Arg(0), opts)
+	if err != nil {
+		return fmt.Errorf("failed to load reference: %w", err)
+	}
+
+	img, err := imageio.LoadImageWithOptions(fs.Arg(1), opts)
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+
+	// Assertion 2: The pixels must line up
+	rb, ib := ref.Bounds(), img.Bounds()
+	if rb.Dx() != ib.Dx() || rb.Dy() != ib.Dy() {
+		return fmt.Errorf("sizes differ: %dx%d and %dx%d", rb.Dx(), rb.Dy(), ib.Dx(), ib.Dy())
+	}
+
+	if diffPath != "" {
+		if err := validator.ValidatePath(diffPath); err != nil {
+			return fmt.Errorf("invalid diff path: %w", err)
+		}
+	}
+
+	a, b := toRGBA64(ref), toRGBA64(img)
+	result, diff := compareImages(a, b, diffPath != "")
+	result.Reference, result.Image = fs.Arg(0), fs.Arg(1)
+
+	if diff != nil {
+		if err := imageio.SaveImage(diffPath, diff); err != nil {
+			return fmt.Errorf("failed to save diff: %w", err)
+		}
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		printComparison(result)
+	}
+
+	if minPSNR > 0 && result.PSNR != nil && *result.PSNR < minPSNR {
+		return fmt.Errorf("PSNR %.2f dB is below -min-psnr %g", *result.PSNR, minPSNR)
+	}
+
+	return nil
+}
+
+// toRGBA64 returns img as an RGBA64 image at the origin
+func toRGBA64(img image.Image) *image.RGBA64 {
+	b := img.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// compareImages measures the differences of two images of one size, and
+// draws them as gray levels when withDiff is set
+func compareImages(a, b *image.RGBA64, withDiff bool) (comparison, *image.Gray) {
+	width, height := a.Rect.Dx(), a.Rect.Dy()
+	result := comparison{Width: width, Height: height}
+
+	var diffs []float64
+	if withDiff {
+		diffs = make([]float64, width*height)
+	}
+
+	var sum, sumSquares float64
+	differing := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := a.PixOffset(x, y)
+			largest := 0.0
+			for c := 0; c < 4; c++ {
+				va := float64(uint16(a.Pix[i+2*c])<<8|uint16(a.Pix[i+2*c+1])) / 257
+				vb := float64(uint16(b.Pix[i+2*c])<<8|uint16(b.Pix[i+2*c+1])) / 257
+				d := math.Abs(va - vb)
+				sum += d
+				sumSquares += d * d
+				largest = math.Max(largest, d)
+			}
+
+			if largest >= 0.5 {
+				differing++
+			}
+			result.MaxDiff = math.Max(result.MaxDiff, largest)
+			if withDiff {
+				diffs[y*width+x] = largest
+			}
+		}
+	}
+
+	samples := float64(4 * width * height)
+	result.MAE = sum / samples
+	result.RMSE = math.Sqrt(sumSquares / samples)
+	result.Differing = float64(differing) / float64(width*height)
+	if result.RMSE > 0 {
+		psnr := 20 * math.Log10(255/result.RMSE)
+		result.PSNR = &psnr
+	}
+	result.SSIM = ssim(luma(a), luma(b), width, height)
+
+	if !withDiff {
+		return result, nil
+	}
+
+	// The largest difference is white, so small ones stay visible
+	diff := image.NewGray(image.Rect(0, 0, width, height))
+	if result.MaxDiff > 0 {
+		for i, d := range diffs {
+			diff.Pix[i] = uint8(math.Round(255 * d / result.MaxDiff))
+		}
+	}
+
+	return result, diff
+}
+
+// luma returns the Rec. 601 luma of img in 8-bit levels, row by row
+func luma(img *image.RGBA64) []float64 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	out := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.RGBA64At(x, y)
+			out[y*width+x] = (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 257
+		}
+	}
+
+	return out
+}
+
+// ssim averages the structural similarity of the windows of two luma
+// planes; images smaller than a window are taken as one window
+func ssim(a, b []float64, width, height int) float64 {
+	const c1 = (0.01 * 255) * (0.01 * 255)
+	const c2 = (0.03 * 255) * (0.03 * 255)
+
+	side := min(ssimWindow, width, height)
+	total, windows := 0.0, 0
+	for top := 0; top+side <= height; top += ssimStep {
+		for left := 0; left+side <= width; left += ssimStep {
+			var sa, sb, saa, sbb, sab float64
+			for y := top; y < top+side; y++ {
+				for x := left; x < left+side; x++ {
+					va, vb := a[y*width+x], b[y*width+x]
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+
+			n := float64(side * side)
+			ma, mb := sa/n, sb/n
+			varA, varB := saa/n-ma*ma, sbb/n-mb*mb
+			cov := sab/n - ma*mb
+			total += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (varA + varB + c2))
+			windows++
+		}
+	}
+
+	return total / float64(windows)
+}
+
+// printComparison prints a comparison as aligned text
+func printComparison(r comparison) {
+	fmt.Printf("Reference: %s\n", r.Reference)
+	fmt.Printf("Image:     %s\n", r.Image)
+	fmt.Printf("Size:      %dx%d\n", r.Width, r.Height)
+	if r.PSNR == nil {
+		fmt.Println("PSNR:      identical")
+	} else {
+		fmt.Printf("PSNR:      %.2f dB\n", *r.PSNR)
+	}
+	fmt.Printf("RMSE:      %.3f levels\n", r.RMSE)
+	fmt.Printf("MAE:       %.3f levels\n", r.MAE)
+	fmt.Printf("Max diff:  %.1f levels\n", r.MaxDiff)
+	fmt.Printf("Differing: %.2f%% of pixels\n", 100*r.Differing)
+	fmt.Printf("SSIM:      %.4f\n", r.SSIM)
+}
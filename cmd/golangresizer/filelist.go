@@ -0,0 +1,134 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// maxListedFiles bounds the inputs of one -files list
+const maxListedFiles = 1000000
+
+// maxListedPath bounds one line of a -files list
+const maxListedPath = 64 * 1024
+
+// validateFileList checks the -files option of cfg, whose paths replace
+// the -input of a batch
+func validateFileList(cfg *Config) error {
+	if cfg.Files == "" {
+		return nil
+	}
+
+	// Assertion 1: The list names the inputs
+	if cfg.InputPath != "" || cfg.JobFile != "" || cfg.DataURI {
+		return fmt.Errorf("files lists the inputs and cannot be combined with -input, -job-file or -data-uri")
+	}
+
+	if cfg.OutputPath == "" || cfg.OutputPath == stdio {
+		return fmt.Errorf("files needs an output directory or a path template with {name}")
+	}
+
+	if cfg.Recursive {
+		return fmt.Errorf("recursive walks a directory input, not a list of files")
+	}
+
+	if cfg.Files != stdio {
+		if err := validator.ValidatePath(cfg.Files); err != nil {
+			return fmt.Errorf("invalid files path: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fileListJobs reads the inputs of the -files list of cfg, one path per
+// line, keeping those that pass the filters as in a directory walk; a
+// relative path below the working directory keeps its directories under
+// the output directory, any other or a remote object is written under its
+// name alone
+func fileListJobs(cfg *Config) ([]batchJob, error) {
+	inputs, err := readFileList(cfg.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := parseBatchFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]batchJob, 0, len(inputs))
+	for _, input := range inputs {
+		rel := filepath.Clean(input)
+		if imageio.IsRemote(input) {
+			rel = path.Base(input)
+		} else if !filepath.IsLocal(rel) {
+			rel = filepath.Base(rel)
+		}
+
+		if filter.file(rel) && !excludedDirectory(filter, rel) {
+			jobs = append(jobs, batchJob{input: input, output: batchOutput(cfg, rel)})
+		}
+	}
+
+	return jobs, nil
+}
+
+// excludedDirectory reports whether an -exclude pattern matches one of
+// the directories of rel, as it would skip it in a directory walk
+func excludedDirectory(filter batchFilter, rel string) bool {
+	for dir := filepath.Dir(rel); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if filter.excluded(dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readFileList returns the paths listed in the file at path, or on
+// standard input for -, skipping blank lines
+func readFileList(path string) ([]string, error) {
+	var reader io.Reader = os.Stdin
+	if path != stdio {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read file list: %w", err)
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 4096), maxListedPath)
+
+	var inputs []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Assertion 1: Bound the inputs
+		if len(inputs) == maxListedFiles {
+			return nil, fmt.Errorf("file list has more than %d paths", maxListedFiles)
+		}
+
+		inputs = append(inputs, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read file list: %w", err)
+	}
+
+	return inputs, nil
+}
@@ -0,0 +1,157 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// imageFlags registers the flags command takes on fs; the flags of the
+// other groups are registered on a set that is never parsed, which only
+// gives cfg their defaults
+// Without a command every group is taken, as before the subcommands
+func imageFlags(fs *flag.FlagSet, cfg *Config, command string) {
+	unused := flag.NewFlagSet(command, flag.ContinueOnError)
+	pick := func(takes bool) *flag.FlagSet {
+		if takes {
+			return fs
+		}
+
+		return unused
+	}
+
+	ioFlags(fs, cfg)
+	resizeFlags(pick(command != convertCommand), cfg)
+	encodeFlags(fs, cfg)
+	metadataFlags(fs, cfg)
+	batchFlags(pick(command == batchCommand || command == ""), cfg)
+	runFlags(fs, cfg)
+}
+
+// ioFlags registers the input, output and format flags
+func ioFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.InputPath, "input", "", "Input image file path, an s3://, gs:// or azblob:// object, or - for standard input (required)")
+	fs.StringVar(&cfg.InputPath, "i", "", "Input image file path, - for standard input (shorthand)")
+	fs.StringVar(&cfg.OutputPath, "output", "", "Output image file path, an s3://, gs:// or azblob:// object, or - for standard output (required)")
+	fs.StringVar(&cfg.OutputPath, "o", "", "Output image file path, - for standard output (shorthand)")
+	fs.StringVar(&cfg.Format, "format", "", "Output format such as png, overriding the output extension")
+	fs.StringVar(&cfg.Format, "f", "", "Output format (shorthand)")
+	fs.BoolVar(&cfg.DataURI, "data-uri", false, "Print the output as a base64 data URI on stdout instead of writing a file")
+}
+
+// resizeFlags registers the size, filter and transform flags
+func resizeFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.IntVar(&cfg.Width, "width", 0, "Target width in pixels (omit to keep aspect)")
+	fs.IntVar(&cfg.Width, "w", 0, "Target width in pixels (shorthand)")
+	fs.IntVar(&cfg.Height, "height", 0, "Target height in pixels (omit to keep aspect)")
+	fs.IntVar(&cfg.Height, "h", 0, "Target height in pixels (shorthand)")
+	fs.StringVar(&cfg.Filter, "filter", string(resizer.FilterBicubic), "Interpolation filter: "+strings.Join(resizer.FilterNames(), ", "))
+	fs.Float64Var(&cfg.Sigma, "sigma", 0.5, "Gaussian filter sigma (used with -filter gaussian)")
+	fs.BoolVar(&cfg.AntiRing, "anti-ringing", false, "Clamp output to the local source range to suppress halos")
+	fs.BoolVar(&cfg.EWA, "ewa", false, "Use elliptical weighted average resampling")
+	fs.BoolVar(&cfg.Supersample, "supersample", false, "Pre-shrink in 2x area passes, allows downscales beyond -min-scale")
+	fs.Float64Var(&cfg.MaxScale, "max-scale", validator.DefaultMaxScaleFactor, "Largest allowed scale factor per axis")
+	fs.Float64Var(&cfg.MinScale, "min-scale", validator.DefaultMinScaleFactor, "Smallest allowed scale factor per axis")
+	fs.BoolVar(&cfg.FixedPoint, "fixed-point", false, "Use integer arithmetic for 8-bit images")
+	fs.StringVar(&cfg.Palette, "palette", string(resizer.PaletteTrueColor), "Output of paletted inputs: truecolor, source, adaptive")
+	fs.BoolVar(&cfg.Dither, "dither", false, "Dither when re-quantizing to a palette")
+	fs.IntVar(&cfg.Colors, "colors", 0, "Quantize the output to a palette of at most this many colors (PNG8)")
+	fs.IntVar(&cfg.Grayscale, "grayscale", 0, "Convert the output to 8 or 16-bit grayscale, resized in linear light")
+	fs.IntVar(&cfg.Jobs, "jobs", 0, "Worker goroutines resizing rows in parallel, 0 uses all CPUs")
+	fs.StringVar(&cfg.Accel, "accel", string(resizer.AccelCPU), "Convolution hardware: cpu, gpu (experimental, opencl builds)")
+	fs.StringVar(&cfg.Precision, "precision", string(resizer.PrecisionFloat64), "Intermediate samples of JPEG and 16-bit resizes: float64, float32")
+	fs.BoolVar(&cfg.FullDecode, "full-decode", false, "Decode JPEG input at full size even when the resize shrinks it 4x or more")
+	fs.StringVar(&cfg.Edge, "edge", "clamp", "Edge policy: clamp, mirror, wrap, constant")
+	fs.StringVar(&cfg.EdgeColor, "edge-color", "#00000000", "Fill color for -edge constant (#RRGGBB or #RRGGBBAA)")
+	fs.StringVar(&cfg.Mode, "mode", string(resizer.ModeStretch), "Resize mode: stretch, fit, fill")
+	fs.StringVar(&cfg.Aspect, "aspect", "", "Output aspect ratio such as 16:9, used with one dimension or -mode fill")
+	fs.Float64Var(&cfg.Megapixels, "megapixels", 0, "Scale to about this many million pixels, keeping the aspect")
+	fs.Var(&cfg.Sizes, "sizes", "Comma separated output sizes (W, WxH or xH) written from one decode")
+	fs.Var(&cfg.Sizes, "size", "One output size WxH, may be repeated")
+	fs.StringVar(&cfg.PrintSize, "print-size", "", "Physical output size such as 4x6in or 10x15cm, needs -dpi")
+	fs.IntVar(&cfg.MaxEdge, "max-edge", 0, "Scale so the longest side is this many pixels")
+	fs.StringVar(&cfg.NoUpscale, "no-upscale", string(resizer.UpscaleAllow), "Guard against enlarging: allow, copy, clamp, error")
+	fs.StringVar(&cfg.Crop, "crop", "", "Crop WxH+X+Y from the source before resizing")
+	fs.StringVar(&cfg.Gravity, "gravity", "", "Part kept by -crop and fill mode: a gravity such as south-east, or x,y focal point")
+	fs.Float64Var(&cfg.Rotate, "rotate", 0, "Rotate clockwise by this many degrees before cropping")
+	fs.StringVar(&cfg.Flip, "flip", "", "Mirror after rotating: h (left-right) or v (top-bottom)")
+	fs.StringVar(&cfg.Extend, "extend", "", "Add borders after resizing: N, V,H or T,R,B,L pixels")
+	fs.StringVar(&cfg.Background, "background", "#00000000", "Fill color for -rotate corners and -extend borders (#RRGGBB or #RRGGBBAA)")
+}
+
+// encodeFlags registers the encoder options
+func encodeFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.Progressive, "progressive", false, "Write progressive JPEG output")
+	fs.IntVar(&cfg.Quality, "quality", 0, "JPEG quality 1-100 (default 95)")
+	fs.IntVar(&cfg.Quality, "q", 0, "JPEG quality 1-100 (shorthand)")
+	fs.StringVar(&cfg.PNGCompression, "png-compression", "", "PNG compression: none, fast, default, best")
+	fs.StringVar(&cfg.TIFFCompression, "tiff-compression", "", "TIFF compression: none, lzw, deflate, jpeg")
+	fs.BoolVar(&cfg.TIFFPredictor, "tiff-predictor", false, "Apply the horizontal predictor to LZW or Deflate TIFF output")
+	fs.IntVar(&cfg.BMPBits, "bmp-bits", 0, "BMP bits per pixel: 24 or 32")
+	fs.BoolVar(&cfg.BMPRLE, "bmp-rle", false, "Write 8-bit run-length encoded BMP output")
+	fs.Float64Var(&cfg.DPI, "dpi", 0, "Dots per inch recorded in JPEG, PNG, BMP or TIFF output, and the density for -print-size")
+}
+
+// metadataFlags registers the flags copying or dropping metadata
+func metadataFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.KeepEXIF, "keep-exif", false, "Copy the input's EXIF metadata to JPEG, PNG or WebP output")
+	fs.BoolVar(&cfg.KeepMetadata, "keep-metadata", false, "Copy the input's EXIF, XMP and IPTC metadata to JPEG, PNG, WebP or TIFF output")
+	fs.BoolVar(&cfg.StripThumbnail, "strip-thumbnail", false, "Drop the EXIF thumbnail instead of regenerating it from the output")
+	fs.BoolVar(&cfg.KeepPNGChunks, "keep-png-chunks", false, "Copy tEXt, zTXt, iTXt, gAMA, cHRM and sRGB chunks from a PNG input to PNG output")
+	fs.StringVar(&cfg.XMPPath, "xmp", "", "Embed the XMP packet of this sidecar file in the output")
+	fs.StringVar(&cfg.Artist, "artist", "", "Creator recorded as EXIF Artist and XMP dc:creator in every output")
+	fs.StringVar(&cfg.Copyright, "copyright", "", "Rights notice recorded as EXIF Copyright and XMP dc:rights in every output")
+	fs.BoolVar(&cfg.StripMetadata, "strip-metadata", false, "Write no EXIF, XMP, IPTC or ICC data, for privacy")
+	fs.BoolVar(&cfg.StripGPS, "strip-gps", false, "Keep metadata like -keep-metadata but remove the location")
+	fs.BoolVar(&cfg.NoAutoOrient, "no-auto-orient", false, "Keep the stored pixel orientation instead of applying the EXIF orientation")
+}
+
+// batchFlags registers the flags selecting the files of a directory input
+func batchFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.IntVar(&cfg.Concurrency, "concurrency", 0, "Files of a directory input processed at once, 0 uses all CPUs")
+	fs.BoolVar(&cfg.Recursive, "recursive", false, "Resize the images of every directory below a directory input, mirroring the tree in the output")
+	fs.StringVar(&cfg.Include, "include", "", "Comma separated patterns such as IMG_*, only files of a directory input matching one are resized")
+	fs.StringVar(&cfg.Exclude, "exclude", "", "Comma separated patterns such as *-small.jpg or drafts, skipping matching files and directories")
+	fs.StringVar(&cfg.Extensions, "ext", "", "Comma separated extensions such as jpg,png, only files of a directory input with one are resized")
+	fs.StringVar(&cfg.Manifest, "manifest", "", "Record each completed file of a directory input with its input and options hashes in this JSON lines file")
+	fs.StringVar(&cfg.Files, "files", "", "Resize the images listed one per line in this file, or - for standard input")
+	fs.StringVar(&cfg.JobFile, "job-file", "", "Run the rows of this CSV or JSON file, each naming its input, output, size and options")
+	fs.BoolVar(&cfg.Resume, "resume", false, "Skip the files -manifest records as done with an unchanged input and options, appending the rest")
+}
+
+// runFlags registers the limits, logging, profiling, help and version flags
+func runFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.JSON, "json", false, "Print a JSON line per file on stdout with its sizes, bytes, time and error")
+	fs.StringVar(&cfg.Exec, "exec", "", "Run this command for every output written, such as 'optipng {output}'; {input} is replaced too")
+	fs.IntVar(&cfg.ExecJobs, "exec-jobs", 0, "Commands of -exec run at once, 0 runs one per file being resized")
+	fs.StringVar(&cfg.ExecFail, "exec-fail", hookFail, "When -exec fails: fail counts the file as failed, warn only logs it")
+	fs.DurationVar(&cfg.Timeout, "timeout", 0, "Give up on an image after this long, such as 30s or 2m; 0 waits")
+	fs.StringVar(&cfg.ProgressBar, "progress-bar", "auto", "Status line with progress and time left on stderr: auto (on a terminal), on or off")
+	fs.BoolVar(&cfg.Quiet, "quiet", false, "Log only warnings and errors")
+	fs.BoolVar(&cfg.Verbose, "verbose", false, "Also log the resizer settings, timings and every file of a directory input")
+	fs.StringVar(&cfg.Color, "color", colorAuto, "Color errors, warnings and successes: auto (on a terminal without NO_COLOR), always or never")
+	fs.StringVar(&cfg.LogFormat, "log-format", logText, "Status messages as text lines, or json objects on stderr")
+	fs.Var(&cfg.MaxMemory, "max-memory", "Peak memory such as 512M or 2G, checked before decoding; larger TIFF resizes stream strip by strip")
+	fs.Var(&cfg.MaxMemory, "memory-limit", "Peak memory (same as -max-memory)")
+	fs.BoolVar(&cfg.MemoryMap, "mmap", false, "Map the input file into memory instead of reading it, for large TIFF and BMP files on local disks")
+	fs.StringVar(&cfg.Profile.CPU, "cpuprofile", "", "Write a CPU profile of the run to this file for go tool pprof")
+	fs.StringVar(&cfg.Profile.Mem, "memprofile", "", "Write a heap profile to this file when the run ends")
+	fs.StringVar(&cfg.Profile.Trace, "trace", "", "Write an execution trace of the run to this file for go tool trace")
+	fs.BoolVar(&cfg.ShowHelp, "help", false, "Show help message")
+	fs.BoolVar(&cfg.ShowVer, "version", false, "Show version information")
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+
+	return items
+}
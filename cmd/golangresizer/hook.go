@@ -0,0 +1,204 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// maxHookOutput bounds the output of a hook kept for its error message
+const maxHookOutput = 4096
+
+// maxHookArgs bounds the words of an -exec command
+const maxHookArgs = 256
+
+// Hook failure policies of -exec-fail
+const (
+	hookFail = "fail" // The file counts as failed, its output is kept
+	hookWarn = "warn" // The failure is logged and the file counts as done
+)
+
+// hooks runs the -exec command for every output written, at most
+// -exec-jobs at a time across the files of a batch
+type hooks struct {
+	args  []string      // Command and arguments holding the placeholders
+	slots chan struct{} // One token per hook allowed to run, nil for no limit
+	warn  bool          // Log failures instead of failing the file
+}
+
+// newHooks parses the -exec options of cfg, nil without a command
+func newHooks(cfg *Config) (*hooks, error) {
+	// Assertion 1: The options belong to a command
+	if cfg.Exec == "" {
+		if cfg.ExecJobs != 0 || cfg.ExecFail != hookFail {
+			return nil, fmt.Errorf("exec-jobs and exec-fail need -exec")
+		}
+
+		return nil, nil
+	}
+
+	if cfg.ExecJobs < 0 || cfg.ExecJobs > maxConcurrency {
+		return nil, fmt.Errorf("exec-jobs must be between 0 and %d", maxConcurrency)
+	}
+
+	if cfg.ExecFail != hookFail && cfg.ExecFail != hookWarn {
+		return nil, fmt.Errorf("exec-fail must be %s or %s", hookFail, hookWarn)
+	}
+
+	if cfg.DataURI || cfg.OutputPath == stdio {
+		return nil, fmt.Errorf("exec runs on output files, not standard output or a data URI")
+	}
+
+	args, err := splitCommand(cfg.Exec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exec command: %w", err)
+	}
+
+	h := &hooks{args: args, warn: cfg.ExecFail == hookWarn}
+	if cfg.ExecJobs > 0 {
+		h.slots = make(chan struct{}, cfg.ExecJobs)
+	}
+
+	return h, nil
+}
+
+// run runs the command once for each output written from input, stopping
+// at the first failure unless failures only warn
+func (h *hooks) run(cfg *Config, input string, outputs []string) error {
+	if h == nil {
+		return nil
+	}
+
+	for _, output := range outputs {
+		cfg.log.Debug("Running exec", "output", output)
+		err := h.runOne(cfg.ctx, input, output)
+		if err == nil {
+			continue
+		}
+
+		// Assertion 1: An interrupt is never only a warning
+		if !h.warn || interrupted(cfg) {
+			return err
+		}
+
+		cfg.log.Warn("Exec failed", "output", output, "error", err)
+	}
+
+	return nil
+}
+
+// runOne runs the command for one output, without a shell: {output} and
+// {input} are replaced in every argument, and the paths are also set as
+// GOLANGRESIZER_OUTPUT and GOLANGRESIZER_INPUT for commands run through
+// sh -c, which can quote them safely that way
+func (h *hooks) runOne(ctx context.Context, input, output string) error {
+	// Assertion 1: Wait for a slot unless the run ends first
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			defer func() { <-h.slots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	replacer := strings.NewReplacer("{output}", output, "{input}", input)
+	args := make([]string, len(h.args))
+	for i, arg := range h.args {
+		args[i] = replacer.Replace(arg)
+	}
+
+	var out hookOutput
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "GOLANGRESIZER_OUTPUT="+output, "GOLANGRESIZER_INPUT="+input)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if text := strings.TrimSpace(string(out)); text != "" {
+			return fmt.Errorf("exec %s: %w: %s", args[0], err, text)
+		}
+
+		return fmt.Errorf("exec %s: %w", args[0], err)
+	}
+
+	return nil
+}
+
+// hookOutput keeps the first maxHookOutput bytes written to it
+type hookOutput []byte
+
+// Write implements io.Writer, dropping what does not fit
+func (o *hookOutput) Write(p []byte) (int, error) {
+	if room := maxHookOutput - len(*o); room > 0 {
+		*o = append(*o, p[:min(room, len(p))]...)
+	}
+
+	return len(p), nil
+}
+
+// splitCommand splits s into words at unquoted spaces; single quotes keep
+// everything up to the next one, and within double quotes a backslash
+// escapes the next character
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'' && r == '\'', quote == '"' && r == '"':
+			quote = 0
+		case quote == '\'':
+			word.WriteRune(r)
+		case r == '\\' && quote == '"':
+			escaped = true
+		case quote == '"':
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+
+		// Assertion 1: Bound the words
+		if len(args) > maxHookArgs {
+			return nil, fmt.Errorf("more than %d arguments", maxHookArgs)
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote")
+	}
+
+	if inWord {
+		args = append(args, word.String())
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	return args, nil
+}
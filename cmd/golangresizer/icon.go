@@ -0,0 +1,63 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// isIcon reports whether the output is an ICO file, which holds every
+// requested size as one entry
+func isIcon(cfg *Config) bool {
+	return outputFormat(cfg) == ".ico"
+}
+
+// iconSizes returns the standard favicon sizes as a size list
+func iconSizes() sizeList {
+	sizes := make(sizeList, 0, len(imageio.ICOSizes))
+	for _, side := range imageio.ICOSizes {
+		sizes = append(sizes, sizeSpec{Width: side, Height: side})
+	}
+
+	return sizes
+}
+
+// saveIcon writes the resized entries to one ICO file, or prints it as a
+// data URI
+func saveIcon(cfg *Config, entries []image.Image) error {
+	if cfg.DataURI {
+		data, err := imageio.EncodeIcon(entries)
+		if err != nil {
+			return fmt.Errorf("failed to encode icon: %w", err)
+		}
+
+		return printDataURI(cfg, data)
+	}
+
+	// Assertion 1: A stopped run writes nothing
+	if err := cfg.ctx.Err(); err != nil {
+		return err
+	}
+
+	cfg.log.Info("Saving icon", "path", pathName(cfg.OutputPath, "standard output"), "sizes", len(entries))
+	if cfg.OutputPath == stdio {
+		if err := imageio.WriteIcon(cfg.output(), entries); err != nil {
+			return fmt.Errorf("failed to write icon: %w", err)
+		}
+	} else if err := imageio.SaveIcon(cfg.OutputPath, entries); err != nil {
+		return fmt.Errorf("failed to save icon: %w", err)
+	}
+
+	// The largest entry stands for the icon in the results
+	var largest image.Point
+	for _, entry := range entries {
+		if size := entry.Bounds().Size(); size.X*size.Y > largest.X*largest.Y {
+			largest = size
+		}
+	}
+	cfg.wrote(cfg.OutputPath, largest)
+
+	return nil
+}
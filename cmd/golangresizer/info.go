@@ -0,0 +1,233 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// infoCommand is the subcommand name that describes image files
+const infoCommand = "info"
+
+// imageInfo is what the info subcommand reports for one file
+type imageInfo struct {
+	Path        string    `json:"path"`
+	Error       string    `json:"error,omitempty"`
+	Format      string    `json:"format,omitempty"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	ColorModel  string    `json:"colorModel,omitempty"`
+	BitDepth    int       `json:"bitDepth,omitempty"`
+	Alpha       bool      `json:"alpha"`
+	Frames      int       `json:"frames,omitempty"`
+	FileSize    int64     `json:"fileSize,omitempty"`
+	Orientation int       `json:"orientation,omitempty"`
+	EXIF        *exifInfo `json:"exif,omitempty"`
+	XMPBytes    int       `json:"xmpBytes,omitempty"`
+	IPTCBytes   int       `json:"iptcBytes,omitempty"`
+}
+
+// exifInfo is the EXIF part of imageInfo
+type exifInfo struct {
+	Bytes     int    `json:"bytes"`
+	Make      string `json:"make,omitempty"`
+	Model     string `json:"model,omitempty"`
+	DateTime  string `json:"dateTime,omitempty"`
+	Artist    string `json:"artist,omitempty"`
+	Copyright string `json:"copyright,omitempty"`
+	GPS       bool   `json:"gps"`
+}
+
+// runInfo parses the info subcommand arguments and describes each file,
+// failing when any of them cannot be read
+func runInfo(args []string) error {
+	fs := flag.NewFlagSet(infoCommand, flag.ContinueOnError)
+
+	var asJSON bool
+	fs.BoolVar(&asJSON, "json", false, "Print a JSON array instead of text")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Assertion 1: At least one file to describe
+	paths := fs.Args()
+	if len(paths) == 0 {
+		return fmt.Errorf("info needs one or more image files")
+	}
+
+	infos := make([]imageInfo, 0, len(paths))
+	failed := 0
+	for _, path := range paths {
+		info := describe(path)
+		if info.Error != "" {
+			failed++
+		}
+		infos = append(infos, info)
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, info := range infos {
+			printInfo(info)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be read", failed, len(paths))
+	}
+
+	return nil
+}
+
+// describe gathers the imageInfo of one file, recording the first error
+func describe(path string) imageInfo {
+	info := imageInfo{Path: path}
+
+	config, err := imageio.LoadImageConfig(path)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.Format = strings.TrimPrefix(config.Format, ".")
+	info.Width, info.Height = config.Width, config.Height
+
+	anim, err := imageio.LoadAnimation(path)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	// Animation frames are composited canvases, the first still frame
+	// keeps the stored color model
+	img := anim.Frames[0].Image
+	info.Frames = len(anim.Frames)
+	if anim.Animated() {
+		if img, err = imageio.LoadImage(path); err != nil {
+			info.Error = err.Error()
+			return info
+		}
+	}
+
+	info.ColorModel, info.BitDepth = colorModelOf(img)
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		info.Alpha = !o.Opaque()
+	}
+
+	if stat, err := os.Stat(path); err == nil {
+		info.FileSize = stat.Size()
+	}
+
+	if info.Orientation, err = imageio.ReadOrientation(path); err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	meta, err := imageio.ReadMetadata(path)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	if meta.EXIF != nil {
+		summary := imageio.SummarizeEXIF(meta.EXIF)
+		info.EXIF = &exifInfo{
+			Bytes:     len(meta.EXIF),
+			Make:      summary.Make,
+			Model:     summary.Model,
+			DateTime:  summary.DateTime,
+			Artist:    summary.Artist,
+			Copyright: summary.Copyright,
+			GPS:       summary.GPS,
+		}
+	}
+	info.XMPBytes, info.IPTCBytes = len(meta.XMP), len(meta.IPTC)
+
+	return info
+}
+
+// colorModelOf names the storage of img and its bits per sample
+func colorModelOf(img image.Image) (string, int) {
+	switch img.(type) {
+	case *image.Gray:
+		return "gray", 8
+	case *image.Gray16:
+		return "gray", 16
+	case *image.Paletted:
+		return "paletted", 8
+	case *image.YCbCr:
+		return "ycbcr", 8
+	case *image.NYCbCrA:
+		return "ycbcra", 8
+	case *image.CMYK:
+		return "cmyk", 8
+	case *image.RGBA, *image.NRGBA:
+		return "rgb", 8
+	case *image.RGBA64, *image.NRGBA64:
+		return "rgb", 16
+	case *hdr.RGBA:
+		return "rgb float", 32
+	default:
+		return fmt.Sprintf("%T", img), 16
+	}
+}
+
+// printInfo writes one imageInfo as indented text
+func printInfo(info imageInfo) {
+	fmt.Println(info.Path)
+	if info.Error != "" {
+		fmt.Printf("  error:       %s\n", info.Error)
+		return
+	}
+
+	fmt.Printf("  format:      %s\n", info.Format)
+	fmt.Printf("  size:        %dx%d\n", info.Width, info.Height)
+	fmt.Printf("  color:       %s, %d bits, alpha %s\n", info.ColorModel, info.BitDepth, yesNo(info.Alpha))
+	fmt.Printf("  frames:      %d\n", info.Frames)
+	fmt.Printf("  file size:   %d bytes\n", info.FileSize)
+	fmt.Printf("  orientation: %d\n", info.Orientation)
+
+	if e := info.EXIF; e != nil {
+		fmt.Printf("  exif:        %d bytes, GPS %s\n", e.Bytes, yesNo(e.GPS))
+		fields := []struct{ name, value string }{
+			{"camera", strings.TrimSpace(e.Make + " " + e.Model)},
+			{"taken", e.DateTime},
+			{"artist", e.Artist},
+			{"copyright", e.Copyright},
+		}
+		for _, field := range fields {
+			if field.value != "" {
+				fmt.Printf("  %-12s %s\n", field.name+":", field.value)
+			}
+		}
+	}
+
+	if info.XMPBytes > 0 {
+		fmt.Printf("  xmp:         %d bytes\n", info.XMPBytes)
+	}
+	if info.IPTCBytes > 0 {
+		fmt.Printf("  iptc:        %d bytes\n", info.IPTCBytes)
+	}
+}
+
+// yesNo spells a flag for the text report
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
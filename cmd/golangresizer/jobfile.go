@@ -0,0 +1,287 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// maxJobRows bounds the jobs of one job file
+const maxJobRows = 100000
+
+// maxJobFileSize bounds the bytes of a job file read whole
+const maxJobFileSize = 64 << 20
+
+// runOnlyFlags are the flags that steer the run as a whole, which the jobs
+// of a job file do not inherit
+var runOnlyFlags = []string{"help", "version", "cpuprofile", "memprofile", "trace", "progress-bar", "quiet", "verbose", "log-format", "color", "json", "exec", "exec-jobs", "exec-fail"}
+
+// jobRow is one job of a job file: the options it sets by flag name, and
+// where it is in the file for error messages
+type jobRow struct {
+	place  string
+	values map[string]string
+}
+
+// validateJobFile checks the -job-file option of cfg, whose rows replace
+// the -input and -output of a batch
+func validateJobFile(cfg *Config) error {
+	if cfg.JobFile == "" {
+		return nil
+	}
+
+	// Assertion 1: The rows name the inputs and outputs
+	if cfg.InputPath != "" || cfg.OutputPath != "" || cfg.DataURI {
+		return fmt.Errorf("job-file takes the input and output of every row, not -input, -output or -data-uri")
+	}
+
+	if cfg.Recursive || cfg.Include != "" || cfg.Exclude != "" || cfg.Extensions != "" {
+		return fmt.Errorf("recursive, include, exclude and ext select files of a directory input, not job file rows")
+	}
+
+	ext := strings.ToLower(filepath.Ext(cfg.JobFile))
+	if ext != ".csv" && ext != ".json" {
+		return fmt.Errorf("job-file must be a .csv or .json file")
+	}
+
+	if err := validator.ValidatePath(cfg.JobFile); err != nil {
+		return fmt.Errorf("invalid job file path: %w", err)
+	}
+
+	return nil
+}
+
+// sharedFlags returns the flags set on fs that every job of a job file
+// inherits, as arguments that parse back to the same values
+func sharedFlags(fs *flag.FlagSet) []string {
+	batch := flag.NewFlagSet("", flag.ContinueOnError)
+	batchFlags(batch, &Config{})
+
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if batch.Lookup(f.Name) == nil && !slices.Contains(runOnlyFlags, f.Name) {
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	return args
+}
+
+// jobFileJobs reads the jobs of the -job-file of cfg, parsing each row as
+// the shared options followed by its own, so a row overrides them
+func jobFileJobs(cfg *Config) ([]batchJob, error) {
+	rows, err := readJobRows(cfg.JobFile)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]batchJob, 0, len(rows))
+	for _, row := range rows {
+		job, err := parseJob(cfg, row)
+		if err != nil {
+			return nil, fmt.Errorf("job file %s: %w", row.place, err)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// parseJob parses the options of one row into the job it describes
+func parseJob(cfg *Config, row jobRow) (batchJob, error) {
+	names := make([]string, 0, len(row.values))
+	for name := range row.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Assertion 1: A row sets the options of one image only
+	allowed := flag.NewFlagSet("", flag.ContinueOnError)
+	ioFlags(allowed, &Config{})
+	resizeFlags(allowed, &Config{})
+	encodeFlags(allowed, &Config{})
+	metadataFlags(allowed, &Config{})
+
+	var args []string
+	for _, name := range names {
+		if allowed.Lookup(name) == nil || name == "data-uri" {
+			return batchJob{}, fmt.Errorf("%q is not an option of one image", name)
+		}
+
+		if row.values[name] != "" {
+			args = append(args, "-"+name+"="+row.values[name])
+		}
+	}
+
+	for _, input := range []string{row.values["input"], row.values["i"]} {
+		if stat, err := os.Stat(input); (err == nil && stat.IsDir()) || imageio.IsRemoteDir(input) {
+			return batchJob{}, fmt.Errorf("input %s is a directory, a row takes one image", input)
+		}
+	}
+
+	// Sizes add up when repeated, so a row's own replace the shared ones
+	shared := cfg.shared
+	if row.values["sizes"] != "" || row.values["size"] != "" {
+		shared = slices.DeleteFunc(slices.Clone(shared), func(arg string) bool {
+			return strings.HasPrefix(arg, "-sizes=") || strings.HasPrefix(arg, "-size=")
+		})
+	}
+
+	job, err := parseFlags(flag.NewFlagSet(resizeCommand, flag.ContinueOnError), resizeCommand, slices.Concat(shared, args))
+	if err != nil {
+		return batchJob{}, err
+	}
+
+	// Assertion 2: The outputs of a batch are files of their own
+	if job.InputPath == stdio || job.OutputPath == stdio {
+		return batchJob{}, fmt.Errorf("standard input and output take one image, not a job file row")
+	}
+
+	return batchJob{input: job.InputPath, output: job.OutputPath, cfg: job}, nil
+}
+
+// readJobRows reads the rows of a CSV job file, whose header names the
+// options, or the objects of a JSON array
+func readJobRows(path string) ([]jobRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read job file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxJobFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read job file: %w", err)
+	}
+
+	// Assertion 1: Bound the file read whole
+	if len(data) > maxJobFileSize {
+		return nil, fmt.Errorf("job file is larger than %d MiB", maxJobFileSize>>20)
+	}
+
+	var rows []jobRow
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		rows, err = jsonJobRows(data)
+	} else {
+		rows, err = csvJobRows(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: Bound the jobs, and expect some
+	if len(rows) > maxJobRows {
+		return nil, fmt.Errorf("job file has more than %d jobs", maxJobRows)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no jobs in %s", path)
+	}
+
+	return rows, nil
+}
+
+// csvJobRows reads CSV data whose first record names an option per
+// column, such as input,output,width,quality; an empty cell keeps the
+// shared value, and lines starting with # are skipped
+func csvJobRows(data []byte) ([]jobRow, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid job file: %w", err)
+	}
+
+	for i, name := range header {
+		header[i] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "-")
+	}
+
+	var rows []jobRow
+	for len(rows) <= maxJobRows {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid job file: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := jobRow{place: fmt.Sprintf("line %d", line), values: make(map[string]string, len(header))}
+		for i, value := range record {
+			row.values[header[i]] = strings.TrimSpace(value)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// jsonJobRows reads a JSON array of objects keyed by option name, such as
+// {"input": "a.jpg", "output": "a.webp", "width": 800}
+func jsonJobRows(data []byte) ([]jobRow, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("invalid job file: want an array of objects: %w", err)
+	}
+
+	rows := make([]jobRow, 0, len(objects))
+	for i, object := range objects {
+		row := jobRow{place: fmt.Sprintf("job %d", i+1), values: make(map[string]string, len(object))}
+		for name, raw := range object {
+			value, err := jsonJobValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("job file %s: %s: %w", row.place, name, err)
+			}
+
+			row.values[strings.TrimPrefix(strings.ToLower(name), "-")] = value
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// jsonJobValue returns a JSON string, number or boolean as the text a flag
+// parses; null leaves the shared value
+func jsonJobValue(raw json.RawMessage) (string, error) {
+	var value any
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("want a string, number or boolean")
+	}
+}
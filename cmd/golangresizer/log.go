@@ -0,0 +1,171 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log formats of -log-format
+const (
+	logText = "text" // One plain line per message, for people
+	logJSON = "json" // One JSON object per message on stderr, for log collectors
+)
+
+// parseLogFormat checks a -log-format and the verbosity flags
+func parseLogFormat(cfg *Config) error {
+	if cfg.LogFormat != logText && cfg.LogFormat != logJSON {
+		return fmt.Errorf("log-format must be text or json, not %q", cfg.LogFormat)
+	}
+
+	if cfg.Quiet && cfg.Verbose {
+		return fmt.Errorf("quiet and verbose cannot be combined")
+	}
+
+	return nil
+}
+
+// logLevel returns the least severe level -quiet and -verbose let through
+func logLevel(cfg *Config) slog.Level {
+	switch {
+	case cfg.Quiet:
+		return slog.LevelWarn
+	case cfg.Verbose:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// newLogger returns the logger of a run: text messages go to stdout, or
+// stderr when stdout carries the image or -json results, and warnings and
+// errors always to stderr, while JSON records all go to stderr
+// Lines are written above bar, which is drawn again below them
+func newLogger(cfg *Config, bar *progressBar) *slog.Logger {
+	level := logLevel(cfg)
+
+	if cfg.LogFormat == logJSON {
+		return slog.New(slog.NewJSONHandler(bar.writer(os.Stderr), &slog.HandlerOptions{Level: level, ReplaceAttr: levelName}))
+	}
+
+	out := os.Stdout
+	if cfg.DataURI || cfg.OutputPath == stdio || cfg.JSON {
+		out = os.Stderr
+	}
+
+	return slog.New(&textHandler{
+		level:    level,
+		out:      bar.writer(out),
+		errOut:   bar.writer(os.Stderr),
+		color:    useColor(cfg.Color, out),
+		errColor: useColor(cfg.Color, os.Stderr),
+		mu:       new(sync.Mutex),
+	})
+}
+
+// textHandler writes each record as its message followed by its
+// attributes as key=value, warnings and errors prefixed by their level
+// With color the message of an error is red, a warning yellow and a
+// success green
+type textHandler struct {
+	level    slog.Level
+	out      io.Writer // Debug and info messages
+	errOut   io.Writer // Warnings and errors
+	color    bool      // Whether out takes colors
+	errColor bool      // Whether errOut takes colors
+	attrs    []slog.Attr
+	mu       *sync.Mutex // Shared by the handlers derived from one
+}
+
+// Enabled reports whether level is logged
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+// Handle writes r as one line
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var sb strings.Builder
+	out := h.out
+
+	switch {
+	case r.Level >= slog.LevelError:
+		sb.WriteString(paint(h.errColor, ansiRed, "Error: "+r.Message))
+		out = h.errOut
+	case r.Level >= slog.LevelWarn:
+		sb.WriteString(paint(h.errColor, ansiYellow, "Warning: "+r.Message))
+		out = h.errOut
+	case r.Level == levelSuccess:
+		sb.WriteString(paint(h.color, ansiGreen, r.Message))
+	default:
+		sb.WriteString(r.Message)
+	}
+
+	for _, a := range h.attrs {
+		writeAttr(&sb, a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&sb, a)
+		return true
+	})
+	sb.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := io.WriteString(out, sb.String())
+	return err
+}
+
+// writeAttr appends a as key=value, quoting values with spaces
+func writeAttr(sb *strings.Builder, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = fmt.Sprintf("%q", value)
+	}
+
+	fmt.Fprintf(sb, " %s=%s", a.Key, value)
+}
+
+// WithAttrs returns a handler adding attrs to every record
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	derived := *h
+	derived.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &derived
+}
+
+// WithGroup returns h; the tool logs no groups
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// atLeast passes only the records of level or above to its handler, so a
+// batch file logs its warnings and errors but not its steps
+type atLeast struct {
+	slog.Handler
+	level slog.Level
+}
+
+// Enabled reports whether level is logged
+func (h atLeast) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level && h.Handler.Enabled(ctx, level)
+}
+
+// WithAttrs keeps the level on the derived handler
+func (h atLeast) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return atLeast{h.Handler.WithAttrs(attrs), h.level}
+}
+
+// WithGroup keeps the level on the derived handler
+func (h atLeast) WithGroup(name string) slog.Handler {
+	return atLeast{h.Handler.WithGroup(name), h.level}
+}
@@ -2,13 +2,29 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/kasurarykerion/golangresizer/internal/interpolation"
 	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/transform"
 	"github.com/kasurarykerion/golangresizer/internal/validator"
 	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+	"github.com/kasurarykerion/golangresizer/pkg/version"
 )
 
 const (
@@ -16,37 +32,131 @@ const (
 	ExitSuccess = 0
 	// ExitError indicates an error occurred
 	ExitError = 1
-	// Version of the application
-	Version = "1.0.0"
 )
 
 // Config holds application configuration
 type Config struct {
-	InputPath  string
-	OutputPath string
-	Width      int
-	Height     int
-	ShowHelp   bool
-	ShowVer    bool
-}
-
-// parseFlags parses command line flags
-func parseFlags() (*Config, error) {
-	cfg := &Config{}
-
-	// Define flags
-	flag.StringVar(&cfg.InputPath, "input", "", "Input image file path (required)")
-	flag.StringVar(&cfg.InputPath, "i", "", "Input image file path (shorthand)")
-	flag.StringVar(&cfg.OutputPath, "output", "", "Output image file path (required)")
-	flag.StringVar(&cfg.OutputPath, "o", "", "Output image file path (shorthand)")
-	flag.IntVar(&cfg.Width, "width", 0, "Target width in pixels (required)")
-	flag.IntVar(&cfg.Width, "w", 0, "Target width in pixels (shorthand)")
-	flag.IntVar(&cfg.Height, "height", 0, "Target height in pixels (required)")
-	flag.IntVar(&cfg.Height, "h", 0, "Target height in pixels (shorthand)")
-	flag.BoolVar(&cfg.ShowHelp, "help", false, "Show help message")
-	flag.BoolVar(&cfg.ShowVer, "version", false, "Show version information")
-
-	flag.Parse()
+	InputPath       string
+	OutputPath      string
+	Format          string
+	DataURI         bool
+	Progressive     bool
+	Quality         int
+	PNGCompression  string
+	TIFFCompression string
+	TIFFPredictor   bool
+	BMPBits         int
+	BMPRLE          bool
+	Width           int
+	Height          int
+	Filter          string
+	Sigma           float64
+	AntiRing        bool
+	EWA             bool
+	Supersample     bool
+	FixedPoint      bool
+	Edge            string
+	EdgeColor       string
+	Mode            string
+	MaxEdge         int
+	NoUpscale       string
+	Crop            string
+	Gravity         string
+	Rotate          float64
+	Background      string
+	Flip            string
+	Extend          string
+	Sizes           sizeList
+	Aspect          string
+	Megapixels      float64
+	PrintSize       string
+	DPI             float64
+	MaxScale        float64
+	MinScale        float64
+	Palette         string
+	Dither          bool
+	Colors          int
+	Grayscale       int
+	Jobs            int
+	Concurrency     int
+	Recursive       bool
+	Include         string
+	Exclude         string
+	Extensions      string
+	Manifest        string
+	JobFile         string
+	Files           string
+	Resume          bool
+	Exec            string
+	ExecJobs        int
+	ExecFail        string
+	JSON            bool
+	Color           string
+	Timeout         time.Duration
+	ProgressBar     string
+	Quiet           bool
+	Verbose         bool
+	LogFormat       string
+	Accel           string
+	Precision       string
+	MaxMemory       memorySize
+	FullDecode      bool
+	MemoryMap       bool
+	Profile         profileFlags
+	KeepEXIF        bool
+	KeepMetadata    bool
+	StripThumbnail  bool
+	KeepPNGChunks   bool
+	XMPPath         string
+	Artist          string
+	Copyright       string
+	NoAutoOrient    bool
+	StripMetadata   bool
+	StripGPS        bool
+	ShowHelp        bool
+	ShowVer         bool
+
+	metadata  imageio.Metadata   // Blocks read from the input for -keep-exif and -keep-metadata
+	pngChunks []imageio.PNGChunk // Chunks read from a PNG input for -keep-png-chunks
+	reduction int                // Scale a JPEG input was decoded at, 1/reduction of fullSize
+	fullSize  image.Point        // Upright size of an input decoded reduced
+	command   string             // Subcommand parsed, empty for the flags without one
+	shared    []string           // Flags the rows of -job-file inherit
+	batch     bool               // Input is a directory whose images are resized in turn
+	stdin     []byte             // Standard input or a remote input, read whole once
+	bar       *progressBar       // Status line on the terminal, nil when off
+	hooks     *hooks             // Command run for every output, nil without -exec
+	result    *fileResult        // What the image run read and wrote, for -json and -exec
+	stdout    io.Writer          // Where -o - writes, nil for standard output
+	log       *slog.Logger       // Status messages at the -quiet or -verbose level
+	ctx       context.Context    // Ended by an interrupt, and for one image by -timeout
+}
+
+// hasSize reports whether an explicit output dimension was given
+func (c *Config) hasSize() bool {
+	return c.Width > 0 || c.Height > 0 || c.MaxEdge > 0 || c.Megapixels > 0
+}
+
+// resizes reports whether any option asks for a resize step
+func (c *Config) resizes() bool {
+	return c.hasSize() || c.Aspect != ""
+}
+
+// parseFlags parses the flags of an image command from args into a new
+// configuration, registering them on fs
+func parseFlags(fs *flag.FlagSet, command string, args []string) (*Config, error) {
+	cfg := &Config{command: command}
+
+	fs.SetOutput(io.Discard)
+	imageFlags(fs, cfg, command)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
 
 	// Assertion 1: Check if help or version requested
 	if cfg.ShowHelp {
@@ -57,176 +167,1473 @@ func parseFlags() (*Config, error) {
 		return cfg, nil
 	}
 
-	// Assertion 2: Validate required parameters
-	if cfg.InputPath == "" {
+	// Convert a physical size into the pixel target
+	if cfg.PrintSize != "" {
+		if err := applyPrintSize(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Assertion 2: Validate required parameters; a job file gives them
+	// per row
+	if err := validateJobFile(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateFileList(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.InputPath == "" && cfg.JobFile == "" && cfg.Files == "" {
 		return nil, fmt.Errorf("input path is required")
 	}
 
-	if cfg.OutputPath == "" {
+	if cfg.OutputPath == "" && !cfg.DataURI && cfg.JobFile == "" {
 		return nil, fmt.Errorf("output path is required")
 	}
 
-	if cfg.Width <= 0 {
-		return nil, fmt.Errorf("width must be greater than 0")
+	// A directory input resizes every image in it, and a job file runs
+	// its rows the same way
+	if stat, err := os.Stat(cfg.InputPath); (err == nil && stat.IsDir()) || imageio.IsRemoteDir(cfg.InputPath) {
+		cfg.batch = true
+	}
+
+	if cfg.JobFile != "" {
+		cfg.batch = true
+		cfg.shared = sharedFlags(fs)
+	}
+
+	if cfg.Files != "" {
+		cfg.batch = true
+	}
+
+	switch {
+	case cfg.command == batchCommand && !cfg.batch:
+		return nil, fmt.Errorf("batch needs a directory input")
+	case cfg.command != "" && cfg.command != batchCommand && cfg.batch:
+		return nil, fmt.Errorf("%s takes one image, use batch for a directory", cfg.command)
+	}
+
+	// A data URI replaces the output file and defaults to PNG
+	if cfg.DataURI {
+		if cfg.OutputPath != "" {
+			return nil, fmt.Errorf("data-uri prints to stdout and cannot be combined with an output path")
+		}
+
+		if cfg.batch {
+			return nil, fmt.Errorf("data-uri prints one image and cannot take a directory input")
+		}
+
+		if cfg.Format == "" {
+			cfg.Format = "png"
+		}
+	}
+
+	if cfg.Format != "" {
+		format, err := imageio.ParseFormat(cfg.Format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid format: %w", err)
+		}
+
+		cfg.Format = format
+	}
+
+	// Icons without a size hold the standard favicon sizes, and shrink
+	// large artwork in area passes since they go beyond -min-scale
+	if isIcon(cfg) {
+		if !cfg.resizes() && len(cfg.Sizes) == 0 {
+			cfg.Sizes = iconSizes()
+		}
+
+		cfg.Supersample = true
+	}
+
+	if cfg.Width < 0 || cfg.Height < 0 || cfg.MaxEdge < 0 || cfg.Megapixels < 0 {
+		return nil, fmt.Errorf("width, height, max edge and megapixels must not be negative")
+	}
+
+	if cfg.Megapixels > 0 && (cfg.Width > 0 || cfg.Height > 0 || cfg.MaxEdge > 0) {
+		return nil, fmt.Errorf("megapixels cannot be combined with width, height or max edge")
 	}
 
-	if cfg.Height <= 0 {
-		return nil, fmt.Errorf("height must be greater than 0")
+	if cfg.MaxEdge > 0 && (cfg.Width > 0 || cfg.Height > 0) {
+		return nil, fmt.Errorf("max edge cannot be combined with width or height")
+	}
+
+	if len(cfg.Sizes) > 0 {
+		if cfg.hasSize() {
+			return nil, fmt.Errorf("sizes cannot be combined with width, height, max edge or megapixels")
+		}
+
+		if len(cfg.Sizes) > 1 && cfg.DataURI && !isIcon(cfg) {
+			return nil, fmt.Errorf("data-uri prints one image, give a single size")
+		}
+
+		if len(cfg.Sizes) > 1 && !cfg.DataURI && cfg.OutputPath != stdio && cfg.JobFile == "" && !hasSizePlaceholder(cfg.OutputPath) && !isIcon(cfg) && !batchDirectory(cfg) {
+			return nil, fmt.Errorf("output path needs {w} or {h} to write several sizes")
+		}
+	}
+
+	// A density alone retags the pixels for print, and convert needs no
+	// change to the pixels at all
+	if cfg.command != convertCommand && cfg.JobFile == "" && !cfg.resizes() && len(cfg.Sizes) == 0 && cfg.Crop == "" && cfg.Rotate == 0 && cfg.Flip == "" && cfg.Extend == "" && cfg.Colors == 0 && cfg.DPI == 0 {
+		return nil, fmt.Errorf("width, height, sizes, max edge, megapixels, aspect, crop, rotate, flip, extend, colors or dpi is required")
+	}
+
+	if cfg.Aspect != "" && cfg.Width > 0 && cfg.Height > 0 {
+		return nil, fmt.Errorf("aspect cannot be combined with both width and height")
 	}
 
 	// Assertion 3: Validate paths
-	if err := validator.ValidatePath(cfg.InputPath); err != nil {
-		return nil, fmt.Errorf("invalid input path: %w", err)
+	if cfg.JobFile == "" && cfg.Files == "" {
+		if err := validator.ValidatePath(cfg.InputPath); err != nil {
+			return nil, fmt.Errorf("invalid input path: %w", err)
+		}
 	}
 
-	if err := validator.ValidatePath(cfg.OutputPath); err != nil {
-		return nil, fmt.Errorf("invalid output path: %w", err)
+	if cfg.OutputPath != "" {
+		if err := validator.ValidatePath(cfg.OutputPath); err != nil {
+			return nil, fmt.Errorf("invalid output path: %w", err)
+		}
 	}
 
-	// Assertion 4: Validate dimensions
-	if err := validator.ValidateDimensions(cfg.Width, cfg.Height); err != nil {
+	// Assertion 4: Validate dimensions, one may be derived from the aspect
+	if !cfg.hasSize() {
+		// Transforms, sizes or aspect crop only, checked when parsed
+	} else if cfg.Megapixels > 0 {
+		// Checked against the pixel limit by the resizer
+	} else if cfg.MaxEdge > 0 {
+		if err := validator.ValidateDimensions(cfg.MaxEdge, cfg.MaxEdge); err != nil {
+			return nil, fmt.Errorf("invalid max edge: %w", err)
+		}
+	} else if err := validator.ValidateTargetDimensions(cfg.Width, cfg.Height); err != nil {
 		return nil, fmt.Errorf("invalid dimensions: %w", err)
 	}
 
+	// Assertion 5: Validate filter name
+	if _, err := resizer.ParseFilter(cfg.Filter); err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	// Assertion 6: Validate resize mode
+	if _, err := resizer.ParseMode(cfg.Mode); err != nil {
+		return nil, fmt.Errorf("invalid mode: %w", err)
+	}
+
+	// Assertion 7: Validate upscale guard
+	if _, err := resizer.ParseUpscalePolicy(cfg.NoUpscale); err != nil {
+		return nil, fmt.Errorf("invalid no-upscale policy: %w", err)
+	}
+
+	// Assertion 8: Validate edge policy
+	if _, err := resizer.ParseEdgeMode(cfg.Edge); err != nil {
+		return nil, fmt.Errorf("invalid edge: %w", err)
+	}
+
+	if _, err := parseHexColor(cfg.EdgeColor); err != nil {
+		return nil, fmt.Errorf("invalid edge color: %w", err)
+	}
+
+	if cfg.Aspect != "" {
+		if _, err := resizer.ParseAspect(cfg.Aspect); err != nil {
+			return nil, err
+		}
+
+		if !cfg.hasSize() && len(cfg.Sizes) == 0 && cfg.Mode != string(resizer.ModeFill) {
+			return nil, fmt.Errorf("aspect without width, height or max edge needs -mode fill")
+		}
+	}
+
+	// Assertion 9: Validate crop geometry and anchor
+	if cfg.Crop != "" {
+		if _, err := transform.ParseCrop(cfg.Crop); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Gravity != "" {
+		if _, err := transform.ParseAnchor(cfg.Gravity); err != nil {
+			return nil, fmt.Errorf("invalid gravity: %w", err)
+		}
+	}
+
+	// Assertion 10: Validate rotation
+	if math.IsNaN(cfg.Rotate) || math.IsInf(cfg.Rotate, 0) {
+		return nil, fmt.Errorf("invalid rotation angle")
+	}
+
+	if _, err := parseHexColor(cfg.Background); err != nil {
+		return nil, fmt.Errorf("invalid background: %w", err)
+	}
+
+	// Assertion 11: Validate output density
+	if cfg.DPI != 0 {
+		if err := imageio.ValidateDPI(cfg.DPI); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Flip != "" {
+		if _, err := transform.ParseFlipAxis(cfg.Flip); err != nil {
+			return nil, fmt.Errorf("invalid flip: %w", err)
+		}
+	}
+
+	if cfg.Extend != "" {
+		if _, err := transform.ParseBorders(cfg.Extend); err != nil {
+			return nil, fmt.Errorf("invalid extend: %w", err)
+		}
+	}
+
+	// Output carries no metadata unless an option copies it, which
+	// strip-metadata rules out
+	credits := cfg.Artist != "" || cfg.Copyright != ""
+	if cfg.StripMetadata && (cfg.KeepEXIF || cfg.KeepMetadata || cfg.StripGPS || cfg.XMPPath != "" || credits || cfg.KeepPNGChunks) {
+		return nil, fmt.Errorf("strip-metadata cannot be combined with keep-exif, keep-metadata, strip-gps, xmp, artist, copyright or keep-png-chunks")
+	}
+
+	// Removing only the location keeps everything else
+	if cfg.StripGPS {
+		cfg.KeepMetadata = true
+	}
+
+	if cfg.StripThumbnail && !cfg.KeepEXIF && !cfg.KeepMetadata {
+		return nil, fmt.Errorf("strip-thumbnail needs keep-exif, keep-metadata or strip-gps")
+	}
+
+	if cfg.TIFFCompression != "" {
+		compression, err := imageio.ParseTIFFCompression(cfg.TIFFCompression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tiff-compression: %w", err)
+		}
+
+		if cfg.TIFFPredictor && !compression.Predictable() {
+			return nil, fmt.Errorf("tiff-predictor needs lzw or deflate compression")
+		}
+	}
+
+	if cfg.BMPBits != 0 && cfg.BMPBits != 24 && cfg.BMPBits != 32 {
+		return nil, fmt.Errorf("bmp-bits must be 24 or 32")
+	}
+
+	if cfg.BMPBits != 0 && cfg.BMPRLE {
+		return nil, fmt.Errorf("bmp-rle writes 8-bit data and cannot be combined with bmp-bits")
+	}
+
+	if cfg.Quality != 0 {
+		if err := imageio.ValidateQuality(cfg.Quality); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.PNGCompression != "" {
+		if _, err := imageio.ParsePNGCompression(cfg.PNGCompression); err != nil {
+			return nil, fmt.Errorf("invalid png-compression: %w", err)
+		}
+	}
+
+	if cfg.Grayscale != 0 {
+		if cfg.Grayscale != 8 && cfg.Grayscale != 16 {
+			return nil, fmt.Errorf("grayscale must be 8 or 16 bits")
+		}
+
+		if !cfg.resizes() && len(cfg.Sizes) == 0 {
+			return nil, fmt.Errorf("grayscale is applied while resizing, give a size")
+		}
+	}
+
+	if cfg.Jobs < 0 || cfg.Jobs > resizer.MaxJobs {
+		return nil, fmt.Errorf("jobs must be between 0 and %d", resizer.MaxJobs)
+	}
+
+	if cfg.Concurrency < 0 || cfg.Concurrency > maxConcurrency {
+		return nil, fmt.Errorf("concurrency must be between 0 and %d", maxConcurrency)
+	}
+
+	if !cfg.batch && (cfg.Recursive || cfg.Include != "" || cfg.Exclude != "" || cfg.Extensions != "") {
+		return nil, fmt.Errorf("recursive, include, exclude and ext need a directory input")
+	}
+
+	if _, err := parseBatchFilter(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateManifest(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateJSON(cfg); err != nil {
+		return nil, err
+	}
+
+	hooks, err := newHooks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.hooks = hooks
+
+	if err := validateStdio(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
+	}
+
+	if err := parseProgressBar(cfg.ProgressBar); err != nil {
+		return nil, err
+	}
+
+	if err := parseLogFormat(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := parseColor(cfg.Color); err != nil {
+		return nil, err
+	}
+
+	if _, err := resizer.ParseAccel(cfg.Accel); err != nil {
+		return nil, fmt.Errorf("invalid accel: %w", err)
+	}
+
+	if _, err := resizer.ParsePrecision(cfg.Precision); err != nil {
+		return nil, fmt.Errorf("invalid precision: %w", err)
+	}
+
+	// Assertion 12: Validate scale factor limits
+	if err := validator.ValidateScaleLimits(cfg.MinScale, cfg.MaxScale); err != nil {
+		return nil, err
+	}
+
+	// Assertion 13: Validate palette policy and output palette size
+	if _, err := resizer.ParsePalettePolicy(cfg.Palette); err != nil {
+		return nil, fmt.Errorf("invalid palette: %w", err)
+	}
+
+	if cfg.Colors != 0 && (cfg.Colors < 1 || cfg.Colors > transform.MaxPaletteSize) {
+		return nil, fmt.Errorf("colors must be 1-%d", transform.MaxPaletteSize)
+	}
+
+	// Assertion 14: Validate the options tied to the output format, which
+	// a batch knows only per file
+	if !cfg.batch {
+		if err := validateOutput(cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	return cfg, nil
 }
 
+// validateOutput checks that the encoding options apply to the format the
+// output is written in
+func validateOutput(cfg *Config) error {
+	format := outputFormat(cfg)
+
+	// Assertion 1: Validate density and JPEG options
+	if cfg.DPI != 0 {
+		switch format {
+		case ".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".tif":
+		default:
+			return fmt.Errorf("dpi can only be recorded in JPEG, PNG, BMP and TIFF output")
+		}
+	}
+
+	if cfg.Progressive && format != ".jpg" && format != ".jpeg" {
+		return fmt.Errorf("progressive applies to JPEG output only")
+	}
+
+	// Assertion 2: Validate TIFF options
+	if (cfg.TIFFCompression != "" || cfg.TIFFPredictor) && format != ".tiff" && format != ".tif" {
+		return fmt.Errorf("tiff-compression and tiff-predictor apply to TIFF output only")
+	}
+
+	// Assertion 3: Validate metadata options
+	if cfg.KeepPNGChunks && format != ".png" {
+		return fmt.Errorf("keep-png-chunks applies to PNG output only")
+	}
+
+	if cfg.KeepEXIF {
+		switch format {
+		case ".jpg", ".jpeg", ".png", ".webp":
+		default:
+			return fmt.Errorf("keep-exif applies to JPEG, PNG and WebP output only")
+		}
+	}
+
+	// Every format that carries any block, the rest are dropped when saving
+	if cfg.KeepMetadata || cfg.XMPPath != "" || cfg.Artist != "" || cfg.Copyright != "" {
+		switch format {
+		case ".jpg", ".jpeg", ".png", ".webp", ".tiff", ".tif":
+		default:
+			return fmt.Errorf("keep-metadata, strip-gps, xmp, artist and copyright apply to JPEG, PNG, WebP and TIFF output only")
+		}
+	}
+
+	// Assertion 4: Validate BMP options
+	if (cfg.BMPBits != 0 || cfg.BMPRLE) && format != ".bmp" {
+		return fmt.Errorf("bmp-bits and bmp-rle apply to BMP output only")
+	}
+
+	// Assertion 5: Only the JPEG encoders are lossy; WebP is always
+	// written lossless
+	if cfg.Quality != 0 {
+		tiffJPEG := (format == ".tiff" || format == ".tif") && strings.EqualFold(strings.TrimSpace(cfg.TIFFCompression), "jpeg")
+		if format != ".jpg" && format != ".jpeg" && !tiffJPEG {
+			return fmt.Errorf("quality applies to JPEG output and -tiff-compression jpeg only")
+		}
+	}
+
+	if cfg.PNGCompression != "" && format != ".png" {
+		return fmt.Errorf("png-compression applies to PNG output only")
+	}
+
+	// Assertion 6: Validate the palette output
+	if cfg.Colors != 0 {
+		switch format {
+		case ".png", ".gif", ".bmp", ".tiff", ".tif":
+		default:
+			return fmt.Errorf("colors needs a paletted output: PNG, GIF, BMP or TIFF")
+		}
+	}
+
+	return nil
+}
+
+// applyPrintSize replaces the target size with the pixel size of the
+// -print-size at -dpi
+func applyPrintSize(cfg *Config) error {
+	// Assertion 1: The physical size is the only size option
+	if cfg.Width > 0 || cfg.Height > 0 || len(cfg.Sizes) > 0 || cfg.MaxEdge > 0 || cfg.Megapixels > 0 || cfg.Aspect != "" {
+		return fmt.Errorf("print size cannot be combined with width, height, sizes, max edge, megapixels or aspect")
+	}
+
+	// Assertion 2: A density is required to convert to pixels
+	if cfg.DPI == 0 {
+		return fmt.Errorf("print size needs -dpi")
+	}
+
+	if err := imageio.ValidateDPI(cfg.DPI); err != nil {
+		return err
+	}
+
+	widthInches, heightInches, err := resizer.ParsePrintSize(cfg.PrintSize)
+	if err != nil {
+		return err
+	}
+
+	cfg.Width, cfg.Height, err = resizer.PrintDimensions(widthInches, heightInches, cfg.DPI)
+	return err
+}
+
+// parseHexColor parses #RRGGBB or #RRGGBBAA into a color
+func parseHexColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+
+	// Assertion 1: Validate length
+	if len(s) != 6 && len(s) != 8 {
+		return color.NRGBA{}, fmt.Errorf("expected #RRGGBB or #RRGGBBAA, got %q", s)
+	}
+
+	if len(s) == 6 {
+		s += "ff"
+	}
+
+	// Assertion 2: Validate hex digits
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid hex color %q", s)
+	}
+
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
 // printHelp displays usage information
 func printHelp() {
 	fmt.Println("GolangResizer - High-Quality Image Resizer")
 	fmt.Println("Open source image resizer coded by kasuraSH")
 	fmt.Println()
 	fmt.Println("Usage:")
+	fmt.Println("  golangresizer <command> [options]")
 	fmt.Println("  golangresizer -input <file> -output <file> -width <pixels> -height <pixels>")
 	fmt.Println()
+	fmt.Println("Commands:")
+	printCommands(os.Stdout)
+	fmt.Println()
+	fmt.Println("Run golangresizer help <command> for the options of one command. Without a")
+	fmt.Println("command every option below applies, as in the examples.")
+	fmt.Println()
 	fmt.Println("Options:")
-	fmt.Println("  -input, -i     Input image file path (required)")
-	fmt.Println("  -output, -o    Output image file path (required)")
-	fmt.Println("  -width, -w     Target width in pixels (required)")
-	fmt.Println("  -height, -h    Target height in pixels (required)")
+	fmt.Println("  -input, -i     Input image file path, a directory whose images are all")
+	fmt.Println("                 resized, or - for standard input (required)")
+	fmt.Println("  -output, -o    Output image file path, for a directory input the output")
+	fmt.Println("                 directory or a path template with {name}, or - for")
+	fmt.Println("                 standard output with -format (required)")
+	fmt.Println("  -format, -f    Output format such as png or webp, overriding the output")
+	fmt.Println("                 extension; the path is written as given")
+	fmt.Println("  -progressive   Write JPEG output as progressive scans that sharpen while loading")
+	fmt.Println("  -quality, -q   JPEG quality 1-100 (default 95), also for -tiff-compression jpeg;")
+	fmt.Println("                 WebP output is always lossless")
+	fmt.Println("  -png-compression")
+	fmt.Println("                 PNG compression: none, fast, default or best")
+	fmt.Println("  -tiff-compression")
+	fmt.Println("                 TIFF compression: none, lzw, deflate (default) or jpeg;")
+	fmt.Println("                 jpeg is lossy and drops alpha")
+	fmt.Println("  -tiff-predictor")
+	fmt.Println("                 Difference samples horizontally before lzw or deflate,")
+	fmt.Println("                 which shrinks photographs")
+	fmt.Println("  -bmp-bits      BMP bits per pixel: 24, or 32 keeping alpha")
+	fmt.Println("  -bmp-rle       Run-length encode BMP output at 8 bits; images that are")
+	fmt.Println("                 not gray or paletted are quantized to 256 colors first")
+	fmt.Println("  -data-uri      Print a base64 data URI on stdout instead of writing -o,")
+	fmt.Println("                 PNG unless -format says otherwise; messages go to stderr")
+	fmt.Println("  -width, -w     Target width in pixels")
+	fmt.Println("  -height, -h    Target height in pixels")
+	fmt.Println("                 Give one of them to keep the source aspect ratio")
+	fmt.Println("  -aspect        Output ratio such as 16:9; with one dimension it sets the")
+	fmt.Println("                 other, with -mode fill alone it crops the source to it")
+	fmt.Println("  -megapixels    Scale to about this many million pixels, e.g. 2.0")
+	fmt.Println("  -sizes         Several outputs from one decode, e.g. 320,640,1280x720;")
+	fmt.Println("                 -size WxH may be repeated instead. The output path")
+	fmt.Println("                 expands {w}, {h} and {name} (input name). An .ico output")
+	fmt.Println("                 holds every size, up to 256x256, as one entry; without")
+	fmt.Println("                 a size it holds 16, 32, 48, 64, 128 and 256")
+	fmt.Println("  -print-size    Physical size such as 4x6in, 10x15cm or 90x50mm, needs -dpi")
+	fmt.Println("  -dpi           Print density recorded in JPEG (JFIF), PNG (pHYs), BMP and")
+	fmt.Println("                 TIFF output; alone it retags the file without resizing")
+	fmt.Println("  -max-edge      Scale so the longest side is this many pixels")
+	fmt.Println("  -no-upscale    When the output would be larger than the source:")
+	fmt.Println("                 allow it, copy the source, clamp to its size, or error")
+	fmt.Println("  -rotate        Rotate clockwise by any angle, resampled with -filter;")
+	fmt.Println("                 90, 180 and 270 move pixels without resampling")
+	fmt.Println("  -flip          Mirror h (left-right) or v (top-bottom)")
+	fmt.Println("  -extend        Add borders after resizing: N, V,H or T,R,B,L pixels")
+	fmt.Println("  -background    Fill color for -rotate corners and -extend borders")
+	fmt.Println("  -crop          Crop WxH+X+Y from the source first; resize is optional")
+	fmt.Println("  -gravity       Part kept by -crop and -mode fill: center, north, south-east,")
+	fmt.Println("                 ..., smart for the most detailed area, or an x,y")
+	fmt.Println("                 focal point as fractions, e.g. 0.3,0.4")
+	fmt.Println("  -mode          stretch, fit inside the box, or fill it and crop (default stretch)")
+	fmt.Println("  -filter        Interpolation filter (default bicubic):")
+	fmt.Printf("                 %s\n", strings.Join(resizer.FilterNames(), ", "))
+	fmt.Println("  -sigma         Gaussian filter sigma, larger is softer (default 0.5)")
+	fmt.Println("  -anti-ringing  Suppress halos around high-contrast edges")
+	fmt.Println("  -ewa           Elliptical weighted average, best for uneven x/y scales")
+	fmt.Println("  -max-scale     Largest allowed scale factor per axis (default 16)")
+	fmt.Println("  -min-scale     Smallest allowed scale factor per axis (default 0.0625)")
+	fmt.Println("  -supersample   Multi-pass area reduction for downscales beyond -min-scale")
+	fmt.Println("  -fixed-point   16.16 integer arithmetic for 8-bit images (embedded/ARM)")
+	fmt.Println("  -palette       Output of GIF and PNG8 inputs: truecolor, the source")
+	fmt.Println("                 palette, or an adaptive palette of the same size")
+	fmt.Println("  -colors        Quantize the output to at most this many colors (1-256)")
+	fmt.Println("                 with median cut; PNG output becomes PNG8")
+	fmt.Println("  -dither        Floyd-Steinberg dithering for -colors and palette re-quantizing")
+	fmt.Println("  -grayscale     Output 8 or 16-bit grayscale, luminance is resized in linear light")
+	fmt.Println("  -jobs          Worker goroutines sharing the output rows (default all CPUs)")
+	fmt.Println("  -concurrency   Files of a directory input processed at once (default all")
+	fmt.Println("                 CPUs); a file that fails is reported and the rest go on")
+	fmt.Println("  -recursive     Also resize the images below a directory input, each written")
+	fmt.Println("                 to the same relative directory under the output directory")
+	fmt.Println("  -ext           Only resize files of a directory input with these comma")
+	fmt.Println("                 separated extensions, such as jpg,png")
+	fmt.Println("  -include       Only resize files matching one of these comma separated")
+	fmt.Println("                 patterns; a pattern with a slash matches the path below")
+	fmt.Println("                 the input directory, one without the file name")
+	fmt.Println("  -exclude       Skip files and directories matching one of these patterns")
+	fmt.Println("  -files         Resize the images listed in this file, one path per line,")
+	fmt.Println("                 or - to read them from standard input; -o names the output")
+	fmt.Println("                 directory or a {name} template, and the filters apply")
+	fmt.Println("  -job-file      Run the rows of this CSV or JSON file in one batch: each")
+	fmt.Println("                 names its input and output and may set any option of one")
+	fmt.Println("                 image, such as width or quality; the options given on the")
+	fmt.Println("                 command line apply to every row unless it sets its own")
+	fmt.Println("  -manifest      Record each completed file of a directory input, with the")
+	fmt.Println("                 SHA-256 of its input and options, as a line of this JSON")
+	fmt.Println("                 lines file")
+	fmt.Println("  -resume        Skip the files -manifest records as done when the input is")
+	fmt.Println("                 unchanged and the output still there, redoing any file")
+	fmt.Println("                 whose options such as -w, -filter or -quality have changed")
+	fmt.Println("  -exec          Run this command for every output written, such as")
+	fmt.Println("                 'optipng -o2 {output}'; {output} and {input} are replaced")
+	fmt.Println("                 in each argument and also set as GOLANGRESIZER_OUTPUT and")
+	fmt.Println("                 GOLANGRESIZER_INPUT, and no shell is involved")
+	fmt.Println("  -exec-jobs     Commands of -exec run at once (default one per file)")
+	fmt.Println("  -exec-fail     fail (default) counts a file whose command fails as failed,")
+	fmt.Println("                 keeping its output; warn only logs the failure")
+	fmt.Println("  -json          Print one JSON line per file on stdout with the input,")
+	fmt.Println("                 output, source and target sizes, bytes read and written,")
+	fmt.Println("                 the time taken and any error; messages move to stderr")
+	fmt.Println("  -timeout       Give up on an image after this long, such as 30s or 2m")
+	fmt.Println("                 (default no limit); Ctrl-C stops the same way, and neither")
+	fmt.Println("                 leaves a partial output file behind")
+	fmt.Println("  -progress-bar  Status line on stderr with the files done, throughput and")
+	fmt.Println("                 time left of a directory input, or the rows of a large")
+	fmt.Println("                 image: auto draws it on a terminal (default), on or off")
+	fmt.Println("  -quiet         Log only warnings and errors")
+	fmt.Println("  -verbose       Also log the resizer settings, the time of each step and")
+	fmt.Println("                 the steps of every file of a directory input")
+	fmt.Println("  -color         auto (default) colors errors red, warnings yellow and")
+	fmt.Println("                 successes green on a terminal unless NO_COLOR is set;")
+	fmt.Println("                 always or never override both")
+	fmt.Println("  -log-format    text (default) for lines on stdout, warnings and errors on")
+	fmt.Println("                 stderr, or json for one object per message on stderr")
+	fmt.Println("  -accel         Convolution hardware: cpu or gpu (experimental, needs an")
+	fmt.Println("                 opencl build; EWA, anti-ringing, fixed point and constant")
+	fmt.Println("                 edges stay on the CPU)")
+	fmt.Println("  -precision     Intermediate samples of JPEG and 16-bit resizes: float64")
+	fmt.Println("                 (default) or float32, which needs half the memory and runs")
+	fmt.Println("                 faster but can differ by one level")
+	fmt.Println("  -max-memory, -memory-limit")
+	fmt.Println("                 Peak memory such as 512M or 2G (a bare number is MiB),")
+	fmt.Println("                 estimated from the header before decoding; a TIFF to TIFF")
+	fmt.Println("                 resize that would need more streams strip by strip, other")
+	fmt.Println("                 jobs are refused")
+	fmt.Println("  -full-decode   Decode JPEG input whole; by default a resize shrinking it")
+	fmt.Println("                 4x or more decodes at 1/2, 1/4 or 1/8 size from the DCT")
+	fmt.Println("                 blocks, several times faster, before the final pass")
+	fmt.Println("  -mmap          Map the input into memory instead of reading it, saving a")
+	fmt.Println("                 copy of large TIFF and BMP files on local disks; the file")
+	fmt.Println("                 must not change while it is being read")
+	fmt.Println("  -cpuprofile    Write a CPU profile of the run to this file for go tool pprof")
+	fmt.Println("  -memprofile    Write a heap profile to this file when the run ends")
+	fmt.Println("  -trace         Write an execution trace of the run for go tool trace")
+	fmt.Println("  -edge          Edge policy: clamp, mirror, wrap, constant (default clamp)")
+	fmt.Println("  -edge-color    Fill color for -edge constant, #RRGGBB or #RRGGBBAA")
+	fmt.Println("  -keep-exif     Copy camera EXIF data (time, exposure, lens) to JPEG, PNG")
+	fmt.Println("                 or WebP output, with the pixel size updated")
+	fmt.Println("  -strip-metadata")
+	fmt.Println("                 Write no EXIF, XMP, IPTC or ICC data at all, for privacy")
+	fmt.Println("  -keep-metadata Copy EXIF, XMP and IPTC data (captions, keywords, credit)")
+	fmt.Println("                 to JPEG, PNG, WebP or TIFF output; PNG and WebP hold no")
+	fmt.Println("                 IPTC and TIFF no EXIF, those blocks are dropped")
+	fmt.Println("  -strip-thumbnail")
+	fmt.Println("                 Drop the EXIF thumbnail of kept metadata; by default it")
+	fmt.Println("                 is regenerated from the output, never the original kept")
+	fmt.Println("  -keep-png-chunks")
+	fmt.Println("                 Copy the text annotations and gAMA, cHRM and sRGB chunks")
+	fmt.Println("                 of a PNG input to PNG output")
+	fmt.Println("  -xmp           Embed the XMP packet of a sidecar file, replacing the input's")
+	fmt.Println("  -artist        Creator written to EXIF Artist and XMP dc:creator")
+	fmt.Println("  -copyright     Notice written to EXIF Copyright and XMP dc:rights; both")
+	fmt.Println("                 apply to JPEG, PNG, WebP and TIFF (XMP only) output")
+	fmt.Println("  -strip-gps     Keep metadata like -keep-metadata but remove the GPS location")
+	fmt.Println("  -no-auto-orient")
+	fmt.Println("                 Keep pixels as stored; by default the EXIF orientation of")
+	fmt.Println("                 phone photos is applied and reset to upright")
 	fmt.Println("  -help          Show this help message")
-	fmt.Println("  -version       Show version information")
+	fmt.Println("  -version       Show the version, commit, build date and Go release; with")
+	fmt.Println("                 -json as one JSON object")
 	fmt.Println()
 	fmt.Println("Supported formats:")
-	fmt.Println("  Input:  JPEG, PNG, BMP, TIFF, WebP")
-	fmt.Println("  Output: JPEG, PNG, BMP, TIFF")
+	fmt.Println("  Input:  JPEG, PNG, BMP, TIFF, WebP, GIF, ICO (largest entry),")
+	fmt.Println("          PPM, PGM, PBM, PNM, EXR, HDR, PSD/PSB (flattened composite),")
+	fmt.Println("          DNG, CR2, NEF (largest embedded JPEG preview),")
+	fmt.Println("          DDS (top mip level, BC1-BC5, BC7 or uncompressed)")
+	fmt.Println("  Output: JPEG, PNG, BMP, TIFF, GIF, WebP (lossless), ICO,")
+	fmt.Println("          PPM, PGM, PBM, PNM (binary), EXR (half float), HDR,")
+	fmt.Println("          DDS (uncompressed BGRA)")
+	fmt.Println("  Input format is detected from the file contents, the extension only")
+	fmt.Println("  decides when they are ambiguous")
+	fmt.Println("  EXR and HDR input is resized in float32 without clipping highlights")
+	fmt.Println("  Animated WebP and GIF input resizes every frame, keeping delays, disposal")
+	fmt.Println("  and loop count, and must be saved as WebP or GIF")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  golangresizer -i input.jpg -o output.png -w 1920 -h 1080")
 	fmt.Println("  golangresizer -input photo.png -output resized.jpg -width 800 -height 600")
+	fmt.Println("  golangresizer -i large.jpg -o preview.jpg -w 320 -h 240 -filter bilinear")
+	fmt.Println("  golangresizer -i photo.jpg -o boxed.jpg -w 800 -h 800 -mode fit")
+	fmt.Println("  golangresizer -i photo.jpg -o avatar.jpg -w 256 -h 256 -mode fill")
+	fmt.Println("  golangresizer -i photo.jpg -o banner.jpg -w 1200 -h 300 -mode fill -gravity north")
+	fmt.Println("  golangresizer -i photo.jpg -o thumb.jpg -w 200 -h 200 -mode fill -gravity smart")
+	fmt.Println("  golangresizer -i photo.jpg -o web.jpg -w 1200")
+	fmt.Println("  golangresizer -i photo.jpg -o hero.jpg -w 1600 -progressive")
+	fmt.Println("  golangresizer -i photo.jpg -o small.jpg -w 800 -quality 80")
+	fmt.Println("  golangresizer -i IMG_1234.jpg -o share.jpg -w 1600 -keep-exif")
+	fmt.Println("  golangresizer -i wire.jpg -o web/wire.jpg -w 1200 -keep-metadata")
+	fmt.Println("  golangresizer -i shoot.jpg -o web/shoot.jpg -w 1600 -artist \"Ana Studio\" -copyright \"(c) 2026 Ana Studio\"")
+	fmt.Println("  golangresizer -i photo.jpg -o upload.jpg -max-edge 2048")
+	fmt.Println("  golangresizer -i scan.png -o archive.tif -w 2400 -tiff-compression lzw -tiff-predictor")
+	fmt.Println("  golangresizer -i logo.png -o splash.bmp -w 640 -bmp-rle")
+	fmt.Println("  golangresizer -i photo.jpg -o \"{name}-{w}.jpg\" -sizes 320,640,1280,1920")
+	fmt.Println("  golangresizer tiles -i scan.tif -o web/scan -layout dzi -tile-size 254 -overlap 1")
+	fmt.Println("  golangresizer info -json photos/*.jpg")
+	fmt.Println("  golangresizer convert -i scan.png -o scan.webp")
+	fmt.Println("  golangresizer batch -i photos -o web -w 1200 -recursive")
+	fmt.Println("  golangresizer compare -diff diff.png reference.png output.png")
+	fmt.Println("  golangresizer batch -i s3://photos/raw/ -o s3://photos/web/ -w 1200 -recursive")
+	fmt.Println("  golangresizer -i gs://photos/raw/hero.jpg -o azblob://web/hero.webp -w 1600")
+	fmt.Println("  golangresizer serve -addr :8080 -allow-hosts cdn.example.com -max-memory 1G")
+	fmt.Println("  golangresizer serve -max-inflight 4 -max-queue 32 -rate 5 -burst 20")
+	fmt.Println("  curl localhost:8080/resize/800x600/fit/aHR0cHM6Ly9jZG4uZXhhbXBsZS5jb20vaGVyby5qcGc.webp")
+	fmt.Println("  golangresizer -i huge.tif -o small.tif -w 2000 -cpuprofile cpu.out")
+	fmt.Println("  golangresizer -i photo.jpg -o dataset.jpg -megapixels 0.25")
+	fmt.Println("  golangresizer -i photo.jpg -o print.tif -print-size 4x6in -dpi 300 -mode fill")
+	fmt.Println("  golangresizer -i poster.png -o poster-300.png -dpi 300")
+	fmt.Println("  golangresizer -i photo.jpg -o wide.jpg -w 1920 -aspect 16:9 -mode fill")
+	fmt.Println("  golangresizer -i photo.jpg -o upright.jpg -rotate 90 -w 600")
+	fmt.Println("  golangresizer -i scan.png -o straight.png -rotate -1.5 -background #ffffff")
+	fmt.Println("  golangresizer -i photo.jpg -o framed.jpg -w 800 -extend 20 -background #ffffff")
+	fmt.Println("  golangresizer -i photo.jpg -o face.jpg -crop 600x600+320+80 -w 200")
+	fmt.Println("  golangresizer -i scan.tif -o thumb.jpg -w 128 -min-scale 0.01")
+	fmt.Println("  golangresizer -i logo.gif -o small.gif -w 64 -palette adaptive -dither")
+	fmt.Println("  golangresizer -i diagram.png -o diagram-8.png -w 800 -colors 64 -dither")
+	fmt.Println("  golangresizer -i scan.jpg -o page.png -w 1700 -grayscale 8")
+	fmt.Println("  golangresizer -i panorama.tif -o pano-8k.tif -w 8192 -jobs 4")
+	fmt.Println("  golangresizer -i panorama.tif -o pano-8k.tif -w 8192 -accel gpu")
+	fmt.Println("  golangresizer -i scan16.tif -o scan16-small.tif -w 6000 -precision float32")
+	fmt.Println("  golangresizer -i survey.tif -o survey-small.tif -w 12000 -max-memory 512M")
+	fmt.Println("  golangresizer -i mosaic.bmp -o mosaic-small.png -w 4000 -mmap")
+	fmt.Println("  golangresizer -i studio.exr -o studio-1k.exr -w 1024")
+	fmt.Println("  golangresizer -i poster.psd -o poster-thumb.jpg -w 400")
+	fmt.Println("  golangresizer -i shoot -o web -w 1600 -f jpg -concurrency 4")
+	fmt.Println("  golangresizer -i archive -o web -w 1600 -recursive -ext jpg,png -exclude drafts")
+	fmt.Println("  golangresizer batch -i archive -o web -w 1600 -manifest done.jsonl -resume")
+	fmt.Println("  golangresizer batch -job-file jobs.csv -filter lanczos3")
+	fmt.Println("  find photos -name '*.jpg' -mtime -1 | golangresizer batch -files - -o web -w 1600")
+	fmt.Println("  golangresizer batch -i shoot -o web -w 1600 -exec 'optipng -o2 {output}' -exec-jobs 2")
+	fmt.Println("  golangresizer batch -i shoot -o web -w 1600 -json > results.jsonl")
+	fmt.Println("  golangresizer -i shoot -o web -w 1600 -f jpg -timeout 30s")
+	fmt.Println("  golangresizer -i DSC_0042.NEF -o DSC_0042.jpg -max-edge 2048")
+	fmt.Println("  golangresizer -i sticker.webp -o small.webp -w 128")
+	fmt.Println("  golangresizer -i banner.gif -o banner-small.gif -w 240")
+	fmt.Println("  golangresizer -i logo.png -o favicon.ico -mode fill")
+	fmt.Println("  golangresizer -i logo.png -o favicon.ico -sizes 16,32,48")
+	fmt.Println("  golangresizer -i upload -o cache/thumb-42 -f webp -w 320")
+	fmt.Println("  golangresizer -i logo.png -w 64 -f webp -data-uri > logo.txt")
+	fmt.Println("  curl -s https://example.com/a.jpg | golangresizer -i - -o - -w 800 -f jpeg > a.jpg")
 }
 
-// printVersion displays version information
-func printVersion() {
-	fmt.Printf("GolangResizer version %s\n", Version)
+// printVersion displays version information, as one JSON object with
+// asJSON
+func printVersion(asJSON bool) error {
+	info := version.Get()
+	if asJSON {
+		out, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("GolangResizer version %s\n", info)
+	fmt.Printf("Go: %s %s\n", info.GoVersion, info.Platform)
+	fmt.Printf("Resampling backend: %s\n", resizer.Backend())
+	if device := resizer.AccelDevice(); device != "" {
+		fmt.Printf("GPU: %s\n", device)
+	}
 	fmt.Println("Open source image resizer coded by kasuraSH")
 	fmt.Println("Built with NASA Power of 10 safety-critical coding rules")
+	return nil
 }
 
-// run executes the main application logic
+// newResizer builds the resizer described by the command line configuration
+func newResizer(cfg *Config) (*resizer.Resizer, error) {
+	filter, err := resizer.ParseFilter(cfg.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	edge, err := resizer.ParseEdgeMode(cfg.Edge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edge: %w", err)
+	}
+
+	edgeColor, err := parseHexColor(cfg.EdgeColor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid edge color: %w", err)
+	}
+
+	mode, err := resizer.ParseMode(cfg.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mode: %w", err)
+	}
+
+	noUpscale, err := resizer.ParseUpscalePolicy(cfg.NoUpscale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid no-upscale policy: %w", err)
+	}
+
+	palette, err := resizer.ParsePalettePolicy(cfg.Palette)
+	if err != nil {
+		return nil, fmt.Errorf("invalid palette: %w", err)
+	}
+
+	anchor, err := parseAnchor(cfg.Gravity)
+	if err != nil {
+		return nil, err
+	}
+
+	accel, err := resizer.ParseAccel(cfg.Accel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid accel: %w", err)
+	}
+
+	precision, err := resizer.ParsePrecision(cfg.Precision)
+	if err != nil {
+		return nil, fmt.Errorf("invalid precision: %w", err)
+	}
+
+	var aspect float64
+	if cfg.Aspect != "" {
+		aspect, err = resizer.ParseAspect(cfg.Aspect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resizerCfg := resizer.Config{
+		TargetWidth:  cfg.Width,
+		TargetHeight: cfg.Height,
+		Filter:       filter,
+		Sigma:        cfg.Sigma,
+		AntiRinging:  cfg.AntiRing,
+		EWA:          cfg.EWA,
+		Supersample:  cfg.Supersample,
+		FixedPoint:   cfg.FixedPoint,
+		Edge:         edge,
+		EdgeColor:    edgeColor,
+		Mode:         mode,
+		MaxEdge:      cfg.MaxEdge,
+		Megapixels:   cfg.Megapixels,
+		NoUpscale:    noUpscale,
+		Anchor:       anchor,
+		Aspect:       aspect,
+		MaxScale:     cfg.MaxScale,
+		MinScale:     cfg.MinScale,
+		Palette:      palette,
+		Dither:       cfg.Dither,
+		Grayscale:    cfg.Grayscale,
+		Jobs:         cfg.Jobs,
+		Accel:        accel,
+		Precision:    precision,
+	}
+
+	r, err := resizer.NewResizer(resizerCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resizer: %w", err)
+	}
+
+	// Assertion 1: Validate resizer was created
+	if r == nil {
+		return nil, fmt.Errorf("resizer is nil")
+	}
+
+	cfg.log.Debug("Resizer settings", "filter", filter, "mode", mode, "edge", cfg.Edge, "jobs", cfg.Jobs, "accel", accel, "precision", precision)
+
+	if cfg.bar != nil {
+		r = r.WithProgress(cfg.bar.rows(cfg.InputPath))
+	}
+
+	return r, nil
+}
+
+// run executes the main application logic for one image, giving up at
+// an interrupt or after -timeout without leaving a partial output
 func run(cfg *Config) error {
 	// Assertion 1: Validate configuration
 	if cfg == nil {
 		return fmt.Errorf("configuration is nil")
 	}
 
-	// Load input image
-	fmt.Printf("Loading image: %s\n", cfg.InputPath)
-	img, err := imageio.LoadImage(cfg.InputPath)
+	ctx, cancel := imageContext(cfg)
+	defer cancel()
+
+	cfg.result = &fileResult{}
+	start := time.Now()
+
+	job := *cfg
+	job.ctx = ctx
+	err := stopped(&job, runImage(&job))
+	cfg.result.finish(&job, time.Since(start))
+	if err != nil {
+		return err
+	}
+
+	// The hook is not bound by -timeout, which is meant for the image
+	return stopped(cfg, cfg.hooks.run(cfg, cfg.InputPath, cfg.result.paths()))
+}
+
+// runImage loads, resizes and saves the input of cfg
+func runImage(cfg *Config) error {
+	// Create resizer unless the transforms or size list define the output
+	var r *resizer.Resizer
+	resizes := cfg.resizes() && len(cfg.Sizes) == 0
+	if resizes {
+		created, err := newResizer(cfg)
+		if err != nil {
+			return err
+		}
+
+		r = created
+	}
+
+	// Standard input can be read only once, so every step below works on
+	// the one copy, as it does on a remote input fetched once
+	if cfg.InputPath == stdio && cfg.stdin == nil {
+		if err := readStdin(cfg); err != nil {
+			return err
+		}
+	}
+
+	if imageio.IsRemote(cfg.InputPath) && cfg.stdin == nil {
+		if err := readRemote(cfg); err != nil {
+			return err
+		}
+	}
+
+	// Refuse jobs over -max-memory before decoding, TIFF resizes stream
+	if done, err := checkMemory(cfg, r); done || err != nil {
+		return err
+	}
+
+	// Load input image, keeping every frame of an animation
+	cfg.log.Info("Loading image", "path", pathName(cfg.InputPath, "standard input"))
+	start := time.Now()
+	anim, err := loadInput(cfg, r)
 	if err != nil {
 		return fmt.Errorf("failed to load image: %w", err)
 	}
+	cfg.log.Debug("Decoded", "elapsed", time.Since(start))
+
+	if err := readMetadata(cfg); err != nil {
+		return err
+	}
+
+	if anim.Animated() {
+		return runAnimation(cfg, r, anim)
+	}
+
+	img := anim.Frames[0].Image
 
 	// Assertion 2: Validate loaded image
 	if img == nil {
 		return fmt.Errorf("loaded image is nil")
 	}
 
-	bounds := img.Bounds()
-	srcWidth := bounds.Dx()
-	srcHeight := bounds.Dy()
+	// Turn phone photos upright before anything measures or crops them
+	if !cfg.NoAutoOrient {
+		img, err = autoOrient(cfg, img)
+		if err != nil {
+			return err
+		}
+	}
+	cfg.source(img.Bounds().Size())
 
-	fmt.Printf("Source dimensions: %dx%d\n", srcWidth, srcHeight)
-	fmt.Printf("Target dimensions: %dx%d\n", cfg.Width, cfg.Height)
+	// Apply the transforms before resizing so the resize sees their result
+	pipeline, err := buildPipeline(cfg)
+	if err != nil {
+		return err
+	}
 
-	// Assertion 3: Validate resize ratio
-	if err := validator.ValidateResizeRatio(srcWidth, srcHeight, cfg.Width, cfg.Height); err != nil {
-		return fmt.Errorf("invalid resize parameters: %w", err)
+	img, err = pipeline.Apply(img)
+	if err != nil {
+		return fmt.Errorf("transform failed: %w", err)
 	}
 
-	// Create resizer
-	resizerCfg := resizer.Config{
-		TargetWidth:  cfg.Width,
-		TargetHeight: cfg.Height,
-		Quality:      100,
+	// Several sizes share the decoded and transformed image
+	if len(cfg.Sizes) > 0 {
+		return runSizes(cfg, img)
 	}
 
-	r, err := resizer.NewResizer(resizerCfg)
+	// Resize when a target size was requested
+	if resizes {
+		img, err = resizeImage(cfg, r, img)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Apply the finishing transforms to the final size
+	finishing, err := buildFinishing(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to create resizer: %w", err)
+		return err
 	}
 
-	// Assertion 4: Validate resizer was created
-	if r == nil {
-		return fmt.Errorf("resizer is nil")
+	img, err = finishing.Apply(img)
+	if err != nil {
+		return fmt.Errorf("transform failed: %w", err)
+	}
+
+	if !resizes {
+		cfg.log.Info("Transformed dimensions", "width", img.Bounds().Dx(), "height", img.Bounds().Dy())
+	}
+
+	if err := saveImage(cfg, cfg.OutputPath, img); err != nil {
+		return err
+	}
+
+	if cfg.command == convertCommand {
+		success(cfg.log, "Conversion completed successfully!")
+		return nil
 	}
 
-	// Perform resize operation
-	fmt.Println("Resizing image using bicubic interpolation...")
-	resizedImg, err := r.Resize(img)
+	if !resizes {
+		success(cfg.log, "Transform completed successfully!")
+		return nil
+	}
+
+	success(cfg.log, "Resize completed successfully!")
+	return nil
+}
+
+// readMetadata loads the blocks and PNG chunks the metadata options copy,
+// as is apart from the pixel size, and drops blocks the output format
+// cannot carry
+func readMetadata(cfg *Config) error {
+	if cfg.KeepEXIF || cfg.KeepMetadata {
+		meta, err := inputMetadata(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to read metadata: %w", err)
+		}
+
+		if !cfg.KeepMetadata {
+			meta = imageio.Metadata{EXIF: meta.EXIF}
+		}
+
+		if meta.Empty() {
+			cfg.log.Info("Input has no metadata to keep")
+		}
+
+		if cfg.StripGPS {
+			meta = meta.WithoutGPS()
+		}
+
+		// Otherwise the thumbnail is regenerated from each output
+		if cfg.StripThumbnail {
+			meta = meta.WithoutThumbnail()
+		}
+
+		cfg.metadata = meta
+	}
+
+	// Assertion 1: A sidecar must hold an XMP packet
+	if cfg.XMPPath != "" {
+		xmp, err := os.ReadFile(cfg.XMPPath)
+		if err != nil {
+			return fmt.Errorf("failed to read XMP sidecar: %w", err)
+		}
+
+		if !bytes.Contains(xmp, []byte("<x:xmpmeta")) && !bytes.Contains(xmp, []byte("<rdf:RDF")) {
+			return fmt.Errorf("xmp file %s holds no XMP packet", cfg.XMPPath)
+		}
+
+		cfg.metadata.XMP = xmp
+	}
+
+	if cfg.KeepPNGChunks {
+		chunks, err := inputPNGChunks(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to read PNG chunks: %w", err)
+		}
+
+		if len(chunks) == 0 {
+			cfg.log.Info("Input has no PNG text or color space chunks to keep")
+		}
+
+		cfg.pngChunks = chunks
+	}
+
+	kept, dropped := cfg.metadata.Supported(outputFormat(cfg))
+	for _, name := range dropped {
+		cfg.log.Warn("Output format cannot carry this metadata, dropping it", "block", name)
+	}
+
+	// Studio credits go on top of whatever was kept, in the blocks the
+	// format can hold
+	cfg.metadata, _ = kept.WithCredits(cfg.Artist, cfg.Copyright).Supported(outputFormat(cfg))
+	return nil
+}
+
+// autoOrient applies the EXIF orientation of the input to img and marks
+// kept metadata as upright to match
+func autoOrient(cfg *Config, img image.Image) (image.Image, error) {
+	orientation, err := inputOrientation(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orientation: %w", err)
+	}
+
+	if orientation == 1 {
+		return img, nil
+	}
+
+	cfg.log.Info("Applying EXIF orientation", "orientation", orientation)
+	img, err = imageio.Orient(img, orientation)
+	if err != nil {
+		return nil, fmt.Errorf("orientation failed: %w", err)
+	}
+
+	cfg.metadata = cfg.metadata.Upright()
+
+	return img, nil
+}
+
+// resizeImage runs r on img and checks the result has the planned size
+func resizeImage(cfg *Config, r *resizer.Resizer, img image.Image) (image.Image, error) {
+	r, size, err := planResize(cfg, r, img)
 	if err != nil {
-		return fmt.Errorf("resize failed: %w", err)
+		return nil, err
+	}
+
+	start := time.Now()
+	resizedImg, err := r.ResizeContext(cfg.ctx, img)
+	cfg.bar.clear()
+	if err != nil {
+		return nil, fmt.Errorf("resize failed: %w", err)
+	}
+	cfg.log.Debug("Resized", "elapsed", time.Since(start))
+
+	if err := checkOutputSize(resizedImg, size); err != nil {
+		return nil, err
+	}
+
+	return resizedImg, nil
+}
+
+// planResize reports and validates the resize of img, returning the
+// resizer to use and the output size it must produce
+// A -crop is read by the resizer in place, without an intermediate copy,
+// and an input decoded reduced is planned at its full size
+func planResize(cfg *Config, r *resizer.Resizer, img image.Image) (*resizer.Resizer, image.Point, error) {
+	scale := max(cfg.reduction, 1)
+	source := img
+	if scale > 1 {
+		source = image.Rectangle{Max: cfg.fullSize}
+	}
+
+	if cfg.Crop != "" {
+		rect, err := cropRect(cfg, source)
+		if err != nil {
+			return nil, image.Point{}, err
+		}
+
+		r, err = r.WithRegion(rect)
+		if err != nil {
+			return nil, image.Point{}, fmt.Errorf("invalid crop: %w", err)
+		}
+	}
+
+	bounds := source.Bounds()
+	srcWidth := bounds.Dx()
+	srcHeight := bounds.Dy()
+	dstWidth, dstHeight := r.OutputSize(srcWidth, srcHeight)
+
+	cfg.log.Info("Source dimensions", "width", srcWidth, "height", srcHeight)
+	cfg.log.Info("Target dimensions", "width", dstWidth, "height", dstHeight)
+
+	// Assertion 1: Validate resize ratio
+	if err := r.CheckSource(srcWidth, srcHeight); err != nil {
+		return nil, image.Point{}, fmt.Errorf("invalid resize parameters: %w", err)
 	}
 
-	// Assertion 5: Validate resized image
-	if resizedImg == nil {
+	// Assertion 2: Carry the plan over to the reduced decode
+	r, err := r.ForReducedSource(srcWidth, srcHeight, scale)
+	if err != nil {
+		return nil, image.Point{}, fmt.Errorf("invalid resize parameters: %w", err)
+	}
+
+	// The auto filter sees the decoded size
+	filter := resizer.Filter(cfg.Filter)
+	if filter == resizer.FilterAuto {
+		filter = resizer.AutoFilter(srcWidth/scale, srcHeight/scale, dstWidth, dstHeight)
+	}
+
+	cfg.log.Info("Resizing image", "filter", filter)
+	return r, image.Pt(dstWidth, dstHeight), nil
+}
+
+// checkOutputSize verifies a resized image has the planned size
+func checkOutputSize(img image.Image, size image.Point) error {
+	// Assertion 1: Validate resized image
+	if img == nil {
 		return fmt.Errorf("resized image is nil")
 	}
 
-	// Verify output dimensions
-	outBounds := resizedImg.Bounds()
-	if outBounds.Dx() != cfg.Width || outBounds.Dy() != cfg.Height {
+	// Assertion 2: Verify output dimensions
+	outBounds := img.Bounds()
+	if outBounds.Dx() != size.X || outBounds.Dy() != size.Y {
 		return fmt.Errorf("output dimensions mismatch: got %dx%d, expected %dx%d",
-			outBounds.Dx(), outBounds.Dy(), cfg.Width, cfg.Height)
+			outBounds.Dx(), outBounds.Dy(), size.X, size.Y)
+	}
+
+	return nil
+}
+
+// buildPipeline returns the transforms requested on the command line in
+// their fixed order: rotate, flip, then crop
+func buildPipeline(cfg *Config) (transform.Pipeline, error) {
+	var pipeline transform.Pipeline
+
+	// Assertion 1: Rotation resamples with the selected filter
+	if cfg.Rotate != 0 {
+		k, err := rotationKernel(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		bg, err := parseHexColor(cfg.Background)
+		if err != nil {
+			return nil, fmt.Errorf("invalid background: %w", err)
+		}
+
+		pipeline = append(pipeline, func(img image.Image) (image.Image, error) {
+			cfg.log.Info("Rotating", "degrees", cfg.Rotate)
+			return transform.Rotate(img, cfg.Rotate, k, bg)
+		})
+	}
+
+	if cfg.Flip != "" {
+		axis, err := transform.ParseFlipAxis(cfg.Flip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flip: %w", err)
+		}
+
+		pipeline = append(pipeline, func(img image.Image) (image.Image, error) {
+			return transform.Flip(img, axis)
+		})
+	}
+
+	// Assertion 2: Crop the possibly rotated and flipped image, unless a
+	// resize follows and reads the crop region directly
+	if cfg.Crop != "" && !cfg.resizes() && len(cfg.Sizes) == 0 {
+		pipeline = append(pipeline, func(img image.Image) (image.Image, error) {
+			return cropImage(cfg, img)
+		})
+	}
+
+	return pipeline, nil
+}
+
+// buildFinishing returns the transforms applied after resizing
+func buildFinishing(cfg *Config) (transform.Pipeline, error) {
+	var pipeline transform.Pipeline
+
+	// Assertion 1: Borders are added at the final size
+	if cfg.Extend != "" {
+		borders, err := transform.ParseBorders(cfg.Extend)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extend: %w", err)
+		}
+
+		fill, err := parseHexColor(cfg.Background)
+		if err != nil {
+			return nil, fmt.Errorf("invalid background: %w", err)
+		}
+
+		pipeline = append(pipeline, func(img image.Image) (image.Image, error) {
+			return transform.Extend(img, borders, fill)
+		})
+	}
+
+	// Assertion 2: Quantize last so the palette fits the final pixels
+	if cfg.Colors > 0 {
+		pipeline = append(pipeline, func(img image.Image) (image.Image, error) {
+			palette, err := transform.Quantize(img, cfg.Colors)
+			if err != nil {
+				return nil, err
+			}
+
+			return transform.Remap(img, palette, cfg.Dither)
+		})
+	}
+
+	// Assertion 3: RLE BMP output stores palette indices
+	if cfg.BMPRLE && cfg.Colors == 0 {
+		pipeline = append(pipeline, func(img image.Image) (image.Image, error) {
+			switch img.(type) {
+			case *image.Paletted, *image.Gray:
+				return img, nil
+			}
+
+			palette, err := transform.Quantize(img, transform.MaxPaletteSize)
+			if err != nil {
+				return nil, err
+			}
+
+			return transform.Remap(img, palette, cfg.Dither)
+		})
 	}
 
-	// Save output image
-	fmt.Printf("Saving image: %s\n", cfg.OutputPath)
-	if err := imageio.SaveImage(cfg.OutputPath, resizedImg); err != nil {
+	return pipeline, nil
+}
+
+// rotationKernel returns the kernel of -filter, bicubic for auto
+func rotationKernel(cfg *Config) (interpolation.Kernel, error) {
+	filter, err := resizer.ParseFilter(cfg.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	// Assertion 1: Rotation keeps the scale, so auto needs no shrink filter
+	if filter == resizer.FilterAuto {
+		filter = resizer.FilterBicubic
+	}
+
+	if filter == resizer.FilterGaussian {
+		return interpolation.GaussianKernel{Sigma: cfg.Sigma}, nil
+	}
+
+	return interpolation.Lookup(string(filter))
+}
+
+// parseAnchor parses the -gravity value, empty selecting each default
+func parseAnchor(gravity string) (transform.Anchor, error) {
+	// Assertion 1: Empty leaves the choice to each operation
+	if gravity == "" {
+		return transform.Anchor{}, nil
+	}
+
+	anchor, err := transform.ParseAnchor(gravity)
+	if err != nil {
+		return transform.Anchor{}, fmt.Errorf("invalid gravity: %w", err)
+	}
+
+	return anchor, nil
+}
+
+// cropRect resolves the -crop geometry against img, placed by the
+// -gravity anchor, and reports it
+func cropRect(cfg *Config, img image.Image) (image.Rectangle, error) {
+	rect, err := resolveCrop(img, cfg.Crop, cfg.Gravity)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	cfg.log.Info("Cropping", "width", rect.Dx(), "height", rect.Dy(), "x", rect.Min.X, "y", rect.Min.Y)
+	return rect, nil
+}
+
+// resolveCrop resolves a WxH+X+Y crop against img like cropRect, silently
+func resolveCrop(img image.Image, geometry, gravity string) (image.Rectangle, error) {
+	spec, err := transform.ParseCrop(geometry)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	spec.Anchor, err = parseAnchor(gravity)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	spec.Anchor = spec.Anchor.Resolve(img, float64(spec.Width), float64(spec.Height))
+
+	bounds := img.Bounds()
+	return spec.Rect(bounds.Dx(), bounds.Dy())
+}
+
+// cropImage copies the -crop area of img into a new image
+func cropImage(cfg *Config, img image.Image) (image.Image, error) {
+	rect, err := cropRect(cfg, img)
+	if err != nil {
+		return nil, err
+	}
+
+	cropped, err := transform.Crop(img, rect)
+	if err != nil {
+		return nil, fmt.Errorf("crop failed: %w", err)
+	}
+
+	return cropped, nil
+}
+
+// outputFormat returns the extension selecting the output encoder
+func outputFormat(cfg *Config) string {
+	if cfg.Format != "" {
+		return cfg.Format
+	}
+
+	return strings.ToLower(filepath.Ext(cfg.OutputPath))
+}
+
+// saveOptions returns the density, format and encoding every output is
+// saved with
+func saveOptions(cfg *Config) imageio.EncodeOptions {
+	opts := imageio.EncodeOptions{
+		DPI:      cfg.DPI,
+		Format:   cfg.Format,
+		JPEG:     imageio.JPEGOptions{Quality: cfg.Quality, Progressive: cfg.Progressive},
+		PNG:      imageio.PNGOptions{Chunks: cfg.pngChunks},
+		TIFF:     imageio.TIFFOptions{Predictor: cfg.TIFFPredictor},
+		BMP:      imageio.BMPOptions{BitDepth: cfg.BMPBits, RLE: cfg.BMPRLE},
+		Metadata: cfg.metadata,
+	}
+
+	// The names were validated by parseFlags
+	if cfg.TIFFCompression != "" {
+		opts.TIFF.Compression, _ = imageio.ParseTIFFCompression(cfg.TIFFCompression)
+	}
+	if cfg.PNGCompression != "" {
+		opts.PNG.Compression, _ = imageio.ParsePNGCompression(cfg.PNGCompression)
+	}
+
+	return opts
+}
+
+// saveImage writes the final image to path, or prints it as a data URI
+func saveImage(cfg *Config, path string, img image.Image) error {
+	if cfg.DataURI {
+		data, err := imageio.EncodeImage(img, saveOptions(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to encode image: %w", err)
+		}
+
+		return printDataURI(cfg, data)
+	}
+
+	cfg.log.Info("Saving image", "path", pathName(path, "standard output"))
+	if path == stdio {
+		if err := imageio.WriteImageContext(cfg.ctx, cfg.output(), img, saveOptions(cfg)); err != nil {
+			return fmt.Errorf("failed to write image: %w", err)
+		}
+
+		return nil
+	}
+
+	start := time.Now()
+	if err := imageio.SaveImageContext(cfg.ctx, path, img, saveOptions(cfg)); err != nil {
 		return fmt.Errorf("failed to save image: %w", err)
 	}
+	cfg.wrote(path, img.Bounds().Size())
+	cfg.log.Debug("Encoded and written", "elapsed", time.Since(start))
 
-	fmt.Println("Resize completed successfully!")
 	return nil
 }
 
-// main is the entry point
-func main() {
-	// Parse command line flags
-	cfg, err := parseFlags()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
-		printHelp()
-		os.Exit(ExitError)
+// printDataURI writes encoded output to stdout as one data URI line
+func printDataURI(cfg *Config, data []byte) error {
+	// Assertion 1: A stopped run prints nothing
+	if err := cfg.ctx.Err(); err != nil {
+		return err
 	}
 
-	// Handle help flag
-	if cfg.ShowHelp {
-		printHelp()
-		os.Exit(ExitSuccess)
+	cfg.log.Info("Printing data URI", "type", imageio.MIMEType(cfg.Format), "bytes", len(data))
+	if _, err := fmt.Fprintln(cfg.output(), imageio.DataURI(data, cfg.Format)); err != nil {
+		return fmt.Errorf("failed to print data URI: %w", err)
 	}
 
-	// Handle version flag
-	if cfg.ShowVer {
-		printVersion()
-		os.Exit(ExitSuccess)
+	return nil
+}
+
+// main is the entry point: the command named by the first argument runs
+// with the rest, or the image options are parsed as they were before the
+// subcommands when the first argument is a flag
+func main() {
+	args := os.Args[1:]
+	name := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Execute main logic
-	if err := run(cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	err := runCommand(name, args)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		os.Exit(ExitSuccess)
+	case errors.Is(err, errReported):
+		os.Exit(ExitError)
+	default:
+		printError(err)
 		os.Exit(ExitError)
 	}
-
-	os.Exit(ExitSuccess)
 }
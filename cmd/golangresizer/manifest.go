@@ -0,0 +1,252 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// maxManifestLine bounds one line of a manifest read back for -resume
+const maxManifestLine = 64 * 1024
+
+// manifestEntry is one line of a manifest, written when a job of a batch
+// completes
+type manifestEntry struct {
+	Input   string `json:"input"`
+	Output  string `json:"output"`
+	SHA256  string `json:"sha256"`  // Hash of the input when it was resized, or etag: and the ETag of a remote one
+	Options string `json:"options"` // Hash of the image options it was resized with
+}
+
+// manifest records the completed jobs of a batch in a JSON lines file, one
+// line appended as each job ends, so an interrupted batch can be resumed
+type manifest struct {
+	file *os.File
+	done map[string]manifestEntry // Entries read back for -resume, by output
+}
+
+// validateManifest checks the -manifest and -resume options of cfg
+func validateManifest(cfg *Config) error {
+	// Assertion 1: Resuming needs the record of an earlier run
+	if cfg.Resume && cfg.Manifest == "" {
+		return fmt.Errorf("resume needs -manifest")
+	}
+
+	if cfg.Manifest == "" {
+		return nil
+	}
+
+	if !cfg.batch {
+		return fmt.Errorf("manifest needs a directory input")
+	}
+
+	if err := validator.ValidatePath(cfg.Manifest); err != nil {
+		return fmt.Errorf("invalid manifest path: %w", err)
+	}
+
+	return nil
+}
+
+// openManifest opens the -manifest file of cfg, nil without one: with
+// -resume the entries already there are read and new ones appended,
+// otherwise the file starts empty
+func openManifest(cfg *Config) (*manifest, error) {
+	if cfg.Manifest == "" {
+		return nil, nil
+	}
+
+	m := &manifest{done: make(map[string]manifestEntry)}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	if cfg.Resume {
+		if err := m.read(cfg.Manifest); err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(cfg.Manifest, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open manifest: %w", err)
+	}
+	m.file = file
+
+	// Assertion 1: A line cut short must not swallow the next one
+	if cfg.Resume {
+		if err := m.endLine(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// read loads the entries of the manifest at path; a missing file has
+// none, and a line cut short by an interrupt is ignored
+func (m *manifest) read(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read manifest: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 4096), maxManifestLine)
+	for scanner.Scan() {
+		var entry manifestEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Output == "" {
+			continue
+		}
+
+		m.done[entry.Output] = entry
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("cannot read manifest: %w", err)
+	}
+
+	return nil
+}
+
+// endLine ends a last line left without a newline by a killed run
+func (m *manifest) endLine() error {
+	info, err := m.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	reader, err := os.Open(m.file.Name())
+	if err != nil {
+		return fmt.Errorf("cannot read manifest: %w", err)
+	}
+	defer reader.Close()
+
+	if _, err := reader.ReadAt(last, info.Size()-1); err != nil {
+		return fmt.Errorf("cannot read manifest: %w", err)
+	}
+
+	if last[0] == '\n' {
+		return nil
+	}
+
+	if _, err := m.file.Write([]byte{'\n'}); err != nil {
+		return fmt.Errorf("cannot write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// completed reports whether job was done by an earlier run: recorded with
+// the same input, input hash and options, and its output still there
+func (m *manifest) completed(ctx context.Context, job batchJob, hash, options string) bool {
+	if m == nil {
+		return false
+	}
+
+	// Entries of runs that did not record their options are redone
+	entry, ok := m.done[job.output]
+	if !ok || entry.Input != job.input || entry.SHA256 != hash || entry.Options != options {
+		return false
+	}
+
+	// Outputs of several sizes are named when written, so the record
+	// stands for them
+	if strings.Contains(job.output, "{") {
+		return true
+	}
+
+	return outputExists(ctx, job.output)
+}
+
+// record appends the completed job to the manifest, so it survives the
+// process being killed right after
+func (m *manifest) record(job batchJob, hash, options string) error {
+	if m == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(manifestEntry{Input: job.input, Output: job.output, SHA256: hash, Options: options})
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("cannot write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// close closes the manifest file
+func (m *manifest) close() error {
+	if m == nil {
+		return nil
+	}
+
+	if err := m.file.Close(); err != nil {
+		return fmt.Errorf("cannot write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// jobOptions returns the hex SHA-256 of the image options job runs with,
+// those of its job file row or else of cfg, leaving out the input and
+// output the entry records itself
+func jobOptions(cfg *Config, job batchJob) string {
+	if job.cfg != nil {
+		cfg = job.cfg
+	}
+
+	// Registering sets the defaults, so the values are copied in after
+	options := &Config{}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	ioFlags(fs, options)
+	resizeFlags(fs, options)
+	encodeFlags(fs, options)
+	metadataFlags(fs, options)
+	*options = *cfg
+
+	hash := sha256.New()
+	fs.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		case "input", "i", "output", "o":
+		default:
+			fmt.Fprintf(hash, "-%s=%s\n", f.Name, f.Value.String())
+		}
+	})
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// hashFile returns the hex SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,182 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/transform"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// maxMemorySize bounds -max-memory far above any real machine so byte
+// counts cannot overflow
+const maxMemorySize = 1 << 50
+
+// memorySize is the byte count of -max-memory, given as 512M, 2G or
+// 1.5GiB in binary units; a bare number counts MiB
+type memorySize int64
+
+// String implements flag.Value
+func (m *memorySize) String() string {
+	if *m == 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%dMiB", (*m+1<<20-1)>>20)
+}
+
+// Set implements flag.Value
+func (m *memorySize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+
+	unit := int64(1 << 20)
+	for _, suffix := range []string{"IB", "B"} {
+		if strings.HasSuffix(s, suffix) {
+			s, unit = strings.TrimSuffix(s, suffix), 1
+			break
+		}
+	}
+
+	if n := len(s); n > 0 {
+		if shift := strings.IndexByte("KMGT", s[n-1]); shift >= 0 {
+			s, unit = s[:n-1], 1<<(10*(shift+1))
+		}
+	}
+
+	// Assertion 1: A finite, non-negative size within the bound
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || v < 0 || v*float64(unit) > maxMemorySize {
+		return fmt.Errorf("invalid memory size %q, want a size such as 512M or 2G", value)
+	}
+
+	*m = memorySize(v * float64(unit))
+	return nil
+}
+
+// checkMemory estimates the job from the input header before anything is
+// decoded, reporting true when the limit refused it or a TIFF resize
+// streamed in its place, and false when run should load the input whole
+func checkMemory(cfg *Config, r *resizer.Resizer) (bool, error) {
+	limit := int64(cfg.MaxMemory)
+	if limit == 0 {
+		return false, nil
+	}
+
+	// The stream reader has no file size limit, so it sizes TIFF input;
+	// standard input and remote objects are already read whole, and remote
+	// outputs are stored whole
+	var info imageio.ImageConfig
+	var reader *imageio.TIFFReader
+	streamErr := fmt.Errorf("the input is read whole")
+	switch {
+	case imageio.IsRemote(cfg.OutputPath):
+		streamErr = fmt.Errorf("remote outputs are stored whole")
+	case !cfg.buffered():
+		reader, streamErr = imageio.OpenTIFF(cfg.InputPath)
+	}
+	if streamErr == nil {
+		defer reader.Close()
+
+		bounds := reader.Bounds()
+		info = imageio.ImageConfig{Format: ".tif", Width: bounds.Dx(), Height: bounds.Dy(), ColorModel: reader.ColorModel()}
+	} else {
+		var err error
+		if info, err = inputConfig(cfg); err != nil {
+			// Loading reports the problem
+			return false, nil
+		}
+	}
+
+	// Assertion 1: Jobs that fit keep the full feature set
+	need := estimateMemory(cfg, r, info)
+	if need <= limit {
+		return false, nil
+	}
+
+	if reader == nil {
+		return true, overMemory(cfg, need, fmt.Sprintf("cannot be streamed: %v", streamErr))
+	}
+
+	return true, streamResize(cfg, r, reader, need)
+}
+
+// estimateMemory estimates the peak memory of decoding the input whole and
+// producing the output from it, the largest one of -sizes
+func estimateMemory(cfg *Config, r *resizer.Resizer, info imageio.ImageConfig) int64 {
+	need := info.DecodedBytes()
+	pixels := int64(info.Pixels())
+
+	// Transforms run on the source, a rotation may double its area
+	if cfg.Rotate != 0 || cfg.Flip != "" || cfg.Extend != "" || cfg.Colors != 0 {
+		need += 2 * 8 * pixels
+	}
+
+	// Assertion 1: The sizes are made one at a time from the same source
+	if len(cfg.Sizes) == 0 {
+		return need + outputBytes(cfg, r, info)
+	}
+
+	var largest int64
+	for _, spec := range cfg.Sizes {
+		sized := *cfg
+		sized.Width, sized.Height = spec.Width, spec.Height
+		sized.log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+		// The run reports a size it cannot make, and logs its settings
+		sizedResizer, err := newResizer(&sized)
+		if err != nil {
+			continue
+		}
+
+		largest = max(largest, outputBytes(cfg, sizedResizer, info))
+	}
+
+	return need + largest
+}
+
+// outputBytes estimates the memory of making one output from the decoded
+// source with r, nil for no resize: the resize, then the canvas -extend
+// grows around the result
+func outputBytes(cfg *Config, r *resizer.Resizer, info imageio.ImageConfig) int64 {
+	// Without a resize the output is at most a converted source
+	width, height := info.Width, info.Height
+	need := 4 * int64(info.Pixels())
+	if r != nil {
+		width, height = r.OutputSize(info.Width, info.Height)
+		need = r.WorkingBytes(info.Width, info.Height, info.ColorModel)
+	}
+
+	if cfg.Extend == "" {
+		return need
+	}
+
+	// Assertion 1: The borders were validated with the options
+	borders, err := transform.ParseBorders(cfg.Extend)
+	if err != nil {
+		return need
+	}
+
+	// Deep sources extend to RGBA64, the others to RGBA
+	pixel := int64(4)
+	if info.DecodedBytes() > 4*int64(info.Pixels()) {
+		pixel = 8
+	}
+
+	canvas := int64(width+borders.Left+borders.Right) * int64(height+borders.Top+borders.Bottom)
+	return need + pixel*canvas
+}
+
+// errOverMemory marks a job refused by -max-memory
+var errOverMemory = errors.New("over -max-memory")
+
+// overMemory reports a job refused by -max-memory
+func overMemory(cfg *Config, need int64, why string) error {
+	return fmt.Errorf("%s needs about %d MiB, %w %s, and %s", pathName(cfg.InputPath, "standard input"), need>>20+1, errOverMemory, cfg.MaxMemory.String(), why)
+}
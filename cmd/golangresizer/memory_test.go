@@ -0,0 +1,76 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"flag"
+	"image/color"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// estimateFor parses args as the resize command would and estimates the
+// job on a source described by info
+func estimateFor(t *testing.T, info imageio.ImageConfig, args ...string) int64 {
+	t.Helper()
+
+	args = append([]string{"-i", "in.png", "-o", "out-{w}.png"}, args...)
+	cfg, err := parseFlags(flag.NewFlagSet("test", flag.ContinueOnError), resizeCommand, args)
+	if err != nil {
+		t.Fatalf("parseFlags %v: %v", args, err)
+	}
+	cfg.log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var r *resizer.Resizer
+	if cfg.resizes() && len(cfg.Sizes) == 0 {
+		if r, err = newResizer(cfg); err != nil {
+			t.Fatalf("newResizer %v: %v", args, err)
+		}
+	}
+
+	return estimateMemory(cfg, r, info)
+}
+
+func TestEstimateMemoryCountsExtendedCanvas(t *testing.T) {
+	tiny := imageio.ImageConfig{Format: ".png", Width: 2, Height: 2, ColorModel: color.NRGBAModel}
+
+	// A 2x2 source grown by 30000 on every side is 60002 pixels square
+	canvas := int64(60002) * 60002 * 4
+	if need := estimateFor(t, tiny, "-extend", "30000"); need < canvas {
+		t.Errorf("-extend 30000 estimated at %d bytes, want at least %d", need, canvas)
+	}
+
+	// Borders are added after the resize, around its output
+	canvas = int64(100+20) * int64(100+40) * 4
+	if need := estimateFor(t, tiny, "-w", "100", "-h", "100", "-extend", "20,10"); need < canvas {
+		t.Errorf("-w 100 -extend 20,10 estimated at %d bytes, want at least %d", need, canvas)
+	}
+
+	deep := imageio.ImageConfig{Format: ".png", Width: 2, Height: 2, ColorModel: color.RGBA64Model}
+	canvas = int64(2002) * 2002 * 8
+	if need := estimateFor(t, deep, "-extend", "1000"); need < canvas {
+		t.Errorf("16-bit -extend 1000 estimated at %d bytes, want at least %d", need, canvas)
+	}
+}
+
+func TestEstimateMemoryCountsLargestSize(t *testing.T) {
+	big := imageio.ImageConfig{Format: ".png", Width: 4000, Height: 3000, ColorModel: color.NRGBAModel}
+
+	single := estimateFor(t, big, "-w", "60000")
+	sizes := estimateFor(t, big, "-sizes", "320,60000,640")
+	if sizes != single {
+		t.Errorf("-sizes 320,60000,640 estimated at %d bytes, want %d as for -w 60000", sizes, single)
+	}
+
+	if small := estimateFor(t, big, "-sizes", "320,640"); small >= single {
+		t.Errorf("-sizes 320,640 estimated at %d bytes, not below the %d of -w 60000", small, single)
+	}
+
+	// Assertion 1: The limit from the review must refuse it
+	if limit := int64(256 << 20); sizes <= limit {
+		t.Errorf("-sizes 60000 estimated at %d bytes, within a 256M budget", sizes)
+	}
+}
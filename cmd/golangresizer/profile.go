@@ -0,0 +1,95 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// profileFlags names the files the profiling flags write, empty for none
+type profileFlags struct {
+	CPU   string // pprof CPU profile of the whole run
+	Mem   string // pprof heap profile taken when the run ends
+	Trace string // Execution trace for go tool trace
+}
+
+// start begins the CPU profile and execution trace and returns a function
+// that stops them and writes the heap profile
+// Files already started are closed again when a later one fails
+func (p profileFlags) start() (func() error, error) {
+	var stops []func() error
+	stopAll := func() error {
+		var first error
+		for i := len(stops) - 1; i >= 0; i-- {
+			if err := stops[i](); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+
+	// Assertion 1: Start the CPU profile
+	if p.CPU != "" {
+		file, err := os.Create(p.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create CPU profile: %w", err)
+		}
+
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("cannot start CPU profile: %w", err)
+		}
+
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return file.Close()
+		})
+	}
+
+	// Assertion 2: Start the execution trace
+	if p.Trace != "" {
+		file, err := os.Create(p.Trace)
+		if err != nil {
+			stopAll()
+			return nil, fmt.Errorf("cannot create trace: %w", err)
+		}
+
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			stopAll()
+			return nil, fmt.Errorf("cannot start trace: %w", err)
+		}
+
+		stops = append(stops, func() error {
+			trace.Stop()
+			return file.Close()
+		})
+	}
+
+	// The heap profile is a snapshot, so it is taken last
+	if p.Mem != "" {
+		stops = append([]func() error{p.writeHeap}, stops...)
+	}
+
+	return stopAll, nil
+}
+
+// writeHeap writes the heap profile after a collection, so it shows the
+// memory still in use and the totals allocated by the run
+func (p profileFlags) writeHeap() error {
+	file, err := os.Create(p.Mem)
+	if err != nil {
+		return fmt.Errorf("cannot create memory profile: %w", err)
+	}
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write memory profile: %w", err)
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,99 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// loadInput loads every frame of the input, decoding a JPEG at the
+// reduced size the resize r allows
+func loadInput(cfg *Config, r *resizer.Resizer) (*imageio.Animation, error) {
+	cfg.reduction = 1
+
+	scale, size := planReduction(cfg, r)
+	if scale == 1 {
+		if cfg.buffered() {
+			return imageio.DecodeAnimationContext(cfg.ctx, bytes.NewReader(cfg.stdin), imageio.DecodeOptions{})
+		}
+
+		return imageio.LoadAnimationContext(cfg.ctx, cfg.InputPath, imageio.DecodeOptions{MemoryMap: cfg.MemoryMap})
+	}
+
+	var img image.Image
+	var applied int
+	var err error
+	if cfg.buffered() {
+		img, applied, err = imageio.DecodeReduced(bytes.NewReader(cfg.stdin), scale)
+	} else {
+		img, applied, err = imageio.LoadReduced(cfg.InputPath, scale)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Layouts such as CMYK are decoded whole
+	if applied > 1 {
+		cfg.log.Info("Decoding reduced from the JPEG DCT blocks", "scale", fmt.Sprintf("1/%d", applied))
+		cfg.reduction = applied
+		cfg.fullSize = size
+	}
+
+	return &imageio.Animation{Frames: []imageio.Frame{{Image: img}}}, nil
+}
+
+// planReduction returns the scale a JPEG input may be decoded at for the
+// resize r, 1 for none, and its upright full size
+// Problems with the input are left for loading to report
+func planReduction(cfg *Config, r *resizer.Resizer) (int, image.Point) {
+	// Assertion 1: Rotating, smart cropping and opting out need the full image
+	if r == nil || cfg.FullDecode || cfg.Rotate != 0 || (cfg.Crop != "" && smartGravity(cfg)) {
+		return 1, image.Point{}
+	}
+
+	config, err := inputConfig(cfg)
+	if err != nil || (config.Format != ".jpg" && config.Format != ".jpeg") {
+		return 1, image.Point{}
+	}
+
+	orientation := 1
+	if !cfg.NoAutoOrient {
+		if orientation, err = inputOrientation(cfg); err != nil {
+			return 1, image.Point{}
+		}
+	}
+
+	size := image.Pt(config.Width, config.Height)
+	if orientation >= 5 && orientation <= 8 {
+		size = image.Pt(config.Height, config.Width)
+	}
+
+	// Assertion 2: Plan on the crop the resize will read
+	if cfg.Crop != "" {
+		rect, err := resolveCrop(image.Rectangle{Max: size}, cfg.Crop, cfg.Gravity)
+		if err != nil {
+			return 1, image.Point{}
+		}
+
+		if r, err = r.WithRegion(rect); err != nil {
+			return 1, image.Point{}
+		}
+	}
+
+	scale := r.ReducedScale(size.X, size.Y)
+
+	// The partly covered last row and column of a reduced decode move to
+	// the top or left when the image is turned or mirrored, so then only
+	// scales dividing both sides keep the geometry exact
+	if orientation != 1 || cfg.Flip != "" {
+		for scale > 1 && (size.X%scale != 0 || size.Y%scale != 0) {
+			scale /= 2
+		}
+	}
+
+	return scale, size
+}
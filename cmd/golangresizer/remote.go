@@ -0,0 +1,116 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// maxRemoteInputs bounds the objects of one remote batch input
+const maxRemoteInputs = 1000000
+
+// buffered reports whether the input is held whole in memory: standard
+// input, or an object of remote storage
+func (c *Config) buffered() bool {
+	return c.InputPath == stdio || imageio.IsRemote(c.InputPath)
+}
+
+// readRemote reads the remote input of cfg whole, as standard input is
+func readRemote(cfg *Config) error {
+	data, err := imageio.ReadRemote(cfg.ctx, cfg.InputPath, validator.MaxFileSize)
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	// Assertion 1: An empty object is no image
+	if len(data) == 0 {
+		return fmt.Errorf("input %s is empty", cfg.InputPath)
+	}
+
+	cfg.stdin = data
+	return nil
+}
+
+// joinPath joins rel, a path below dir, to the local directory or remote
+// prefix dir
+func joinPath(dir, rel string) string {
+	if imageio.IsRemote(dir) {
+		return imageio.JoinRemote(dir, filepath.ToSlash(rel))
+	}
+
+	return filepath.Join(dir, rel)
+}
+
+// remoteInputs returns the images below the remote prefix input of cfg
+// that pass filter, relative to it: those directly below it, or with
+// -recursive every one, apart from an output prefix inside it
+func remoteInputs(cfg *Config, filter batchFilter) ([]string, error) {
+	objects, err := imageio.ListRemote(cfg.ctx, cfg.InputPath, maxRemoteInputs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list input: %w", err)
+	}
+
+	output := ""
+	if batchDirectory(cfg) && imageio.IsRemote(cfg.OutputPath) {
+		output = strings.TrimSuffix(cfg.OutputPath, "/") + "/"
+	}
+
+	var inputs []string
+	for _, object := range objects {
+		rel := filepath.FromSlash(object.Name)
+		if !cfg.Recursive && strings.Contains(object.Name, "/") {
+			continue
+		}
+
+		// Assertion 1: Only readable formats, and never the outputs
+		if !slices.Contains(imageio.SupportedFormats, strings.ToLower(path.Ext(object.Name))) || (output != "" && strings.HasPrefix(imageio.JoinRemote(cfg.InputPath, object.Name), output)) {
+			continue
+		}
+
+		if filter.file(rel) && !excludedDirectory(filter, rel) {
+			inputs = append(inputs, rel)
+		}
+	}
+
+	return inputs, nil
+}
+
+// outputExists reports whether the file or remote object at path exists
+func outputExists(ctx context.Context, path string) bool {
+	if imageio.IsRemote(path) {
+		_, err := imageio.StatRemote(ctx, path)
+		return err == nil
+	}
+
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// inputHash returns what -manifest records of the input at path: the hex
+// SHA-256 of a local file, or the ETag of a remote object, which changes
+// with its content and needs no download
+func inputHash(ctx context.Context, path string) (string, error) {
+	if !imageio.IsRemote(path) {
+		return hashFile(path)
+	}
+
+	object, err := imageio.StatRemote(ctx, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return "etag:" + object.ETag, nil
+}
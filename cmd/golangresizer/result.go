@@ -0,0 +1,169 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// fileResult collects what one image run read and wrote, for -json and
+// -exec
+type fileResult struct {
+	source  image.Point    // Upright size of the input, zero until decoded
+	outputs []outputResult // Files written, in order
+	bytesIn int64          // Size of the input file or standard input
+	elapsed time.Duration  // Time from the start of the run to its end
+}
+
+// outputResult is one output file written
+type outputResult struct {
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// dimensions is a size in a result record
+type dimensions struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// resultRecord is the JSON line -json prints for every file processed;
+// output and target describe the first file written, and outputs lists
+// them all when there are several sizes
+type resultRecord struct {
+	Input      string         `json:"input"`
+	Output     string         `json:"output,omitempty"`
+	Source     *dimensions    `json:"source,omitempty"`
+	Target     *dimensions    `json:"target,omitempty"`
+	BytesIn    int64          `json:"bytesIn"`
+	BytesOut   int64          `json:"bytesOut"`
+	DurationMS float64        `json:"durationMs"`
+	Outputs    []outputResult `json:"outputs,omitempty"`
+	Skipped    bool           `json:"skipped,omitempty"` // Done before, found in -manifest
+	Error      string         `json:"error,omitempty"`
+}
+
+// validateJSON checks that -json has standard output to itself
+func validateJSON(cfg *Config) error {
+	if cfg.JSON && (cfg.DataURI || cfg.OutputPath == stdio) {
+		return fmt.Errorf("json prints results on standard output, which -output - and -data-uri already use")
+	}
+
+	return nil
+}
+
+// source records the upright size of the input of cfg; a JPEG decoded
+// reduced reports its full size
+func (c *Config) source(size image.Point) {
+	if c.result == nil {
+		return
+	}
+
+	if c.reduction > 1 {
+		size = c.fullSize
+	}
+	c.result.source = size
+}
+
+// wrote records an output file written and its size, for -json and -exec
+func (c *Config) wrote(path string, size image.Point) {
+	if c.result != nil && path != stdio {
+		c.result.outputs = append(c.result.outputs, outputResult{Path: path, Width: size.X, Height: size.Y})
+	}
+}
+
+// finish completes the result of the run of cfg, which took elapsed
+func (r *fileResult) finish(cfg *Config, elapsed time.Duration) {
+	r.elapsed = elapsed
+
+	if cfg.buffered() {
+		r.bytesIn = int64(len(cfg.stdin))
+	} else if info, err := os.Stat(cfg.InputPath); err == nil {
+		r.bytesIn = info.Size()
+	}
+
+	for i := range r.outputs {
+		// Remote outputs are sized only for -json, which reports them
+		if imageio.IsRemote(r.outputs[i].Path) {
+			if !cfg.JSON {
+				continue
+			}
+
+			if object, err := imageio.StatRemote(cfg.ctx, r.outputs[i].Path); err == nil {
+				r.outputs[i].Bytes = object.Size
+			}
+		} else if info, err := os.Stat(r.outputs[i].Path); err == nil {
+			r.outputs[i].Bytes = info.Size()
+		}
+	}
+}
+
+// paths returns the paths of the outputs written
+func (r *fileResult) paths() []string {
+	if r == nil {
+		return nil
+	}
+
+	paths := make([]string, len(r.outputs))
+	for i, output := range r.outputs {
+		paths[i] = output.Path
+	}
+
+	return paths
+}
+
+// record returns the JSON record of the run of input, which was to write
+// output and ended with err; r may be nil for a file never run
+func (r *fileResult) record(input, output string, err error) resultRecord {
+	rec := resultRecord{Input: input, Output: output}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	if r == nil {
+		return rec
+	}
+
+	rec.BytesIn = r.bytesIn
+	rec.DurationMS = float64(r.elapsed.Microseconds()) / 1000
+	if r.source != (image.Point{}) {
+		rec.Source = &dimensions{Width: r.source.X, Height: r.source.Y}
+	}
+
+	for _, written := range r.outputs {
+		rec.BytesOut += written.Bytes
+	}
+
+	if len(r.outputs) > 0 {
+		first := r.outputs[0]
+		rec.Output = first.Path
+		rec.Target = &dimensions{Width: first.Width, Height: first.Height}
+	}
+
+	if len(r.outputs) > 1 {
+		rec.Outputs = r.outputs
+	}
+
+	return rec
+}
+
+// printRecord writes rec as one line of JSON on standard output
+func printRecord(rec resultRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(os.Stdout, "%s\n", line); err != nil {
+		return fmt.Errorf("failed to print result: %w", err)
+	}
+
+	return nil
+}
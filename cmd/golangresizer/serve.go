@@ -0,0 +1,565 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// serveCommand is the subcommand name of the HTTP server
+const serveCommand = "serve"
+
+const (
+	// maxFormFields bounds the parts of a multipart upload
+	maxFormFields = 64
+	// maxFormValue bounds one text field of a multipart upload
+	maxFormValue = 4096
+	// maxRedirects bounds the redirects followed fetching a source URL
+	maxRedirects = 5
+	// shutdownGrace is how long requests may finish after an interrupt
+	shutdownGrace = 10 * time.Second
+	// headerTimeout bounds reading the request headers
+	headerTimeout = 10 * time.Second
+	// defaultServeMemory is the -max-memory of a request unless the
+	// operator sets one, so -max-inflight of them have a known peak
+	defaultServeMemory = 512 << 20
+)
+
+// operatorFlags are the image options that bound what a request costs or
+// read local files, which only the operator sets and requests cannot;
+// extend and sizes stay open, their canvas counting toward -max-memory
+// before the source is decoded
+var operatorFlags = []string{"max-scale", "min-scale", "jobs", "accel", "xmp"}
+
+// errBadRequest marks a request whose parameters or source are wrong
+var errBadRequest = errors.New("bad request")
+
+// errMethod marks a request made with a method the path does not take
+var errMethod = errors.New("method not allowed")
+
+// server answers resize requests with the image pipeline of the command
+// line, one image per request
+type server struct {
+	log        *slog.Logger
+	maxUpload  int64         // Largest source accepted, uploaded or fetched
+	maxMemory  memorySize    // -max-memory of every request
+	timeout    time.Duration // -timeout of every request, also bounding a fetch
+	allowHosts []string      // Hosts source URLs may name, none refuses URLs
+	maxAge     time.Duration // Cache-Control max-age of resize path responses
+	client     *http.Client
+	admit      *admission // Rate, in-flight and queue limits of image requests
+}
+
+// runServe parses the serve subcommand arguments and serves requests
+// until an interrupt, letting those under way finish
+func runServe(args []string) error {
+	fs := flag.NewFlagSet(serveCommand, flag.ContinueOnError)
+
+	s := &server{admit: &admission{}, maxMemory: defaultServeMemory}
+	var addr, allowHosts, logFormat, color string
+	var quiet, verbose bool
+	var maxInflight, burst int
+	var rate float64
+	maxUpload := memorySize(64 << 20)
+	fs.StringVar(&addr, "addr", ":8080", "Address to listen on, host:port")
+	fs.Var(&maxUpload, "max-upload", "Largest source image accepted, uploaded or fetched, such as 64M")
+	fs.Var(&s.maxMemory, "max-memory", "Peak memory of one request, checked before decoding; with -max-inflight it bounds a burst, 0 does not limit")
+	fs.DurationVar(&s.timeout, "timeout", 30*time.Second, "Give up on a request after this long, fetching included; 0 waits")
+	fs.StringVar(&allowHosts, "allow-hosts", "", "Comma separated hosts the url parameter may fetch from, such as cdn.example.com or *.example.com; empty refuses URLs")
+	fs.DurationVar(&s.maxAge, "max-age", 24*time.Hour, "How long caches may keep the images of /resize/WxH/mode/source paths; 0 sends no Cache-Control")
+	fs.IntVar(&maxInflight, "max-inflight", 0, "Images processed at once, 0 uses all CPUs; with -max-memory this bounds the memory of a burst")
+	fs.Int64Var(&s.admit.maxQueue, "max-queue", 64, "Requests waiting for a free slot before more are refused with 503")
+	fs.DurationVar(&s.admit.queueTimeout, "queue-timeout", 10*time.Second, "Refuse a queued request with 503 after waiting this long")
+	fs.Float64Var(&rate, "rate", 0, "Image requests a second each client may start, refused with 429 beyond; 0 does not limit")
+	fs.IntVar(&burst, "burst", 10, "Image requests a client may start at once before -rate applies")
+	fs.BoolVar(&s.admit.trustForwarded, "trust-forwarded", false, "Tell clients apart by the X-Forwarded-For a proxy in front adds, not the peer address")
+	fs.BoolVar(&quiet, "quiet", false, "Log only warnings and errors")
+	fs.BoolVar(&verbose, "verbose", false, "Also log every request")
+	fs.StringVar(&logFormat, "log-format", logText, "Status messages as text lines, or json objects on stderr")
+	fs.StringVar(&color, "color", colorAuto, "Color errors and warnings: auto, always or never")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Assertion 1: No positional arguments and sane limits
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	if maxUpload <= 0 || int64(maxUpload) > validator.MaxFileSize {
+		return fmt.Errorf("max-upload must be between 1 byte and %d MiB", validator.MaxFileSize>>20)
+	}
+
+	if s.timeout < 0 || s.maxAge < 0 {
+		return fmt.Errorf("timeout and max-age must not be negative")
+	}
+
+	// Assertion 2: Limits that admit some requests
+	if maxInflight < 0 || maxInflight > maxConcurrency {
+		return fmt.Errorf("max-inflight must be between 0 and %d", maxConcurrency)
+	}
+
+	if s.admit.maxQueue < 0 || s.admit.queueTimeout <= 0 {
+		return fmt.Errorf("max-queue must not be negative and queue-timeout must be positive")
+	}
+
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) || (rate > 0 && burst < 1) {
+		return fmt.Errorf("rate must not be negative and burst must be at least 1")
+	}
+
+	logCfg := &Config{LogFormat: logFormat, Color: color, Quiet: quiet, Verbose: verbose}
+	if err := parseLogFormat(logCfg); err != nil {
+		return err
+	}
+
+	if err := parseColor(color); err != nil {
+		return err
+	}
+
+	s.log = newLogger(logCfg, nil)
+	s.maxUpload = int64(maxUpload)
+	s.allowHosts = splitList(allowHosts)
+	s.client = &http.Client{Timeout: s.timeout, CheckRedirect: s.checkRedirect}
+	if maxInflight == 0 {
+		maxInflight = runtime.GOMAXPROCS(0)
+	}
+	s.admit.slots = make(chan struct{}, maxInflight)
+	s.admit.rates = newRateLimiter(rate, burst)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: s.routes(), ReadHeaderTimeout: headerTimeout}
+	ctx, stop := interruptContext()
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(listener)
+	}()
+
+	s.log.Info("Serving", "addr", listener.Addr().String(), "inflight", maxInflight, "queue", s.admit.maxQueue)
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.log.Info("Shutting down")
+	shutdown, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+
+	return srv.Shutdown(shutdown)
+}
+
+// routes returns the handler of every path the server answers; resize
+// paths skip the mux, whose path cleaning would fold the slashes of a
+// plain source URL, and only image requests wait for admission
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/resize", s.handle(s.admit.limit(s.upload(resizeCommand))))
+	mux.HandleFunc("/convert", s.handle(s.admit.limit(s.upload(convertCommand))))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	transform := s.handle(s.admit.limit(s.transform))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, resizePath) {
+			transform(w, r)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// serveFunc answers one request with an image
+type serveFunc func(w http.ResponseWriter, r *http.Request) error
+
+// handle returns the handler answering each request with serve, logging
+// how it ended
+func (s *server) handle(serve serveFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		out := &countingWriter{ResponseWriter: w}
+
+		err := recovered(serve, out, r)
+		if err != nil && out.bytes == 0 {
+			status := statusOf(err)
+			out.Header().Del("Content-Type")
+			out.Header().Del("Cache-Control")
+			http.Error(out, err.Error(), status)
+		}
+
+		// A client over its rate is its own problem, not the server's
+		if errors.Is(err, errRateLimited) {
+			s.log.Debug("Request refused", "method", r.Method, "path", r.URL.Path, "status", out.status, "error", err)
+			return
+		}
+
+		if err != nil {
+			s.log.Warn("Request failed", "method", r.Method, "path", r.URL.Path, "status", out.status, "error", err)
+			return
+		}
+
+		s.log.Debug("Served", "method", r.Method, "path", r.URL.Path, "status", out.status, "bytes", out.bytes, "elapsed", time.Since(start))
+	}
+}
+
+// recovered runs serve, turning a panic into an error
+func recovered(serve serveFunc, w http.ResponseWriter, r *http.Request) (err error) {
+	// A decoder panic on a corrupt upload fails this request only
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("internal error: %v", p)
+		}
+	}()
+
+	return serve(w, r)
+}
+
+// upload returns the serveFunc running command on the image posted or
+// named by the url parameter, with the options of the query
+func (s *server) upload(command string) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		// Assertion 1: Images come in a body or from a URL
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			return fmt.Errorf("%w: %s", errMethod, r.Method)
+		}
+
+		params := r.URL.Query()
+		data, err := s.readSource(w, r, params)
+		if err != nil {
+			return err
+		}
+
+		return s.serveImage(w, r, command, params, data)
+	}
+}
+
+// serveImage runs command on data with the options of params and writes
+// the result as the response
+func (s *server) serveImage(w http.ResponseWriter, r *http.Request, command string, params url.Values, data []byte) error {
+	cfg, err := s.requestConfig(command, params, data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	cfg.stdin = data
+	cfg.stdout = w
+	cfg.ctx = r.Context()
+	cfg.log = slog.New(atLeast{s.log.Handler(), slog.LevelWarn}).With("path", r.URL.Path)
+
+	w.Header().Set("Content-Type", imageio.MIMEType(cfg.Format))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return run(cfg)
+}
+
+// readSource returns the image of r: fetched from the url parameter, the
+// image part of a multipart form, whose other fields join params, or the
+// whole body
+func (s *server) readSource(w http.ResponseWriter, r *http.Request, params url.Values) ([]byte, error) {
+	if source := params.Get("url"); source != "" {
+		params.Del("url")
+		return s.fetch(r.Context(), source)
+	}
+
+	// Assertion 1: A GET names its source
+	if r.Method == http.MethodGet {
+		return nil, fmt.Errorf("%w: send the image as the body of a POST or name it with the url parameter", errBadRequest)
+	}
+
+	// A form is bounded as a whole, its fields included
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return readMultipart(r, params, s.maxUpload)
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: the request body is empty", errBadRequest)
+	}
+
+	return data, nil
+}
+
+// readMultipart returns the file part, or the part named image, of the
+// multipart body of r, adding its text fields to params; an image over
+// limit bytes is refused
+func readMultipart(r *http.Request, params url.Values, limit int64) ([]byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errBadRequest, err)
+	}
+
+	var data []byte
+	for i := 0; i < maxFormFields; i++ {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FileName() != "" || part.FormName() == "image" {
+			if data != nil {
+				return nil, fmt.Errorf("%w: send one image per request", errBadRequest)
+			}
+
+			if data, err = io.ReadAll(io.LimitReader(part, limit+1)); err != nil {
+				return nil, err
+			}
+
+			// Assertion 1: Bound the image like a raw body
+			if int64(len(data)) > limit {
+				return nil, fmt.Errorf("%w: the image is over -max-upload", imageio.ErrTooLarge)
+			}
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxFormValue+1))
+		if err != nil {
+			return nil, err
+		}
+
+		// Assertion 2: Fields are short option values
+		if len(value) > maxFormValue {
+			return nil, fmt.Errorf("%w: field %s is over %d bytes", errBadRequest, part.FormName(), maxFormValue)
+		}
+
+		params.Add(part.FormName(), string(value))
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: the form has no image file", errBadRequest)
+	}
+
+	return data, nil
+}
+
+// fetch downloads the source at rawURL, which must be http or https on an
+// allowed host, refusing anything over -max-upload
+func (s *server) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	source, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid url: %v", errBadRequest, err)
+	}
+
+	// Assertion 1: Only the hosts the operator allowed
+	if err := s.checkSource(source); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid url: %v", errBadRequest, err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, &fetchError{fmt.Errorf("fetching the source failed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &fetchError{fmt.Errorf("the source answered %s", resp.Status)}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, s.maxUpload+1))
+	if err != nil {
+		return nil, &fetchError{fmt.Errorf("fetching the source failed: %w", err)}
+	}
+
+	// Assertion 2: Bound the source like an upload
+	if int64(len(data)) > s.maxUpload {
+		return nil, fmt.Errorf("%w: the source is over -max-upload", imageio.ErrTooLarge)
+	}
+
+	return data, nil
+}
+
+// checkSource refuses a source URL that is not http or https on one of
+// the -allow-hosts
+func (s *server) checkSource(source *url.URL) error {
+	if source.Scheme != "http" && source.Scheme != "https" {
+		return fmt.Errorf("%w: url must be http or https", errBadRequest)
+	}
+
+	host := strings.ToLower(source.Hostname())
+	for _, allowed := range s.allowHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == host {
+			return nil
+		}
+
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+
+	if len(s.allowHosts) == 0 {
+		return fmt.Errorf("%w: fetching URLs is off, start serve with -allow-hosts", errBadRequest)
+	}
+
+	return fmt.Errorf("%w: host %s is not allowed", errBadRequest, host)
+}
+
+// checkRedirect lets a fetch follow a few redirects within the allowed
+// hosts
+func (s *server) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("more than %d redirects", maxRedirects)
+	}
+
+	return s.checkSource(req.URL)
+}
+
+// requestConfig parses the query of a request into the image options of
+// command, reading from the source and writing to the response; without
+// a format the output keeps the source format when it can be written
+func (s *server) requestConfig(command string, params url.Values, data []byte) (*Config, error) {
+	allowed := flag.NewFlagSet("", flag.ContinueOnError)
+	resizeFlags(allowed, &Config{})
+	encodeFlags(allowed, &Config{})
+	metadataFlags(allowed, &Config{})
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	args := []string{"-i=-", "-o=-", "-max-memory=" + s.maxMemory.String(), "-timeout=" + s.timeout.String()}
+	for _, name := range names {
+		// Assertion 1: Only the options of one image, within the limits
+		// of the operator and reading no local files
+		format := name == "format" || name == "f"
+		if allowed.Lookup(name) == nil && !format {
+			return nil, fmt.Errorf("%q is not an image option", name)
+		}
+
+		if slices.Contains(operatorFlags, name) {
+			return nil, fmt.Errorf("%q is set by the server, not a request", name)
+		}
+
+		for _, value := range params[name] {
+			args = append(args, "-"+name+"="+value)
+		}
+	}
+
+	if !params.Has("format") && !params.Has("f") {
+		args = append(args, "-f="+sourceFormat(data))
+	}
+
+	return parseFlags(flag.NewFlagSet(command, flag.ContinueOnError), command, args)
+}
+
+// sourceFormat returns the format a response keeps from the source data,
+// PNG when the source format cannot be written or is not recognized
+func sourceFormat(data []byte) string {
+	info, err := imageio.DecodeImageConfig(bytes.NewReader(data))
+	if err != nil {
+		return "png"
+	}
+
+	if format, err := imageio.ParseFormat(info.Format); err == nil {
+		return strings.TrimPrefix(format, ".")
+	}
+
+	return "png"
+}
+
+// fetchError is a source URL that could not be fetched
+type fetchError struct {
+	err error
+}
+
+func (e *fetchError) Error() string { return e.err.Error() }
+func (e *fetchError) Unwrap() error { return e.err }
+
+// statusOf returns the HTTP status of a request that failed with err
+func statusOf(err error) int {
+	var tooBig *http.MaxBytesError
+	var fetch *fetchError
+
+	switch {
+	case errors.Is(err, errMethod):
+		return http.StatusMethodNotAllowed
+	case errors.Is(err, errRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, errBusy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.As(err, &tooBig), errors.Is(err, imageio.ErrTooLarge), errors.Is(err, errOverMemory), errors.Is(err, resizer.ErrMemoryBudget):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, imageio.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.As(err, &fetch):
+		return http.StatusBadGateway
+	case errors.Is(err, errTimedOut):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, errInterrupted):
+		return http.StatusServiceUnavailable
+	case strings.HasPrefix(err.Error(), "internal error"):
+		return http.StatusInternalServerError
+	default:
+		// The pipeline fails on what the source holds, such as a corrupt
+		// file or options that do not suit it
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// countingWriter records the status and bytes of a response
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status
+func (w *countingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes, the status being 200 unless set before
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
@@ -0,0 +1,70 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testServer returns a server with the default limits of serve, one image
+// at a time
+func testServer() *server {
+	return &server{
+		log:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		maxUpload: 64 << 20,
+		maxMemory: defaultServeMemory,
+		timeout:   30 * time.Second,
+		client:    http.DefaultClient,
+		admit:     &admission{slots: make(chan struct{}, 1), maxQueue: 1, queueTimeout: time.Second},
+	}
+}
+
+// tinyPNG returns a 2x2 PNG
+func tinyPNG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestServeRefusesCanvasOverMemory(t *testing.T) {
+	handler := testServer().routes()
+	source := tinyPNG(t)
+
+	cases := []struct {
+		query  string
+		status int
+	}{
+		{"extend=30000", http.StatusRequestEntityTooLarge},
+		{"sizes=60000", http.StatusRequestEntityTooLarge},
+		{"sizes=16,60000&format=ico", http.StatusRequestEntityTooLarge},
+		{"w=60000", http.StatusRequestEntityTooLarge},
+		{"extend=10", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/resize?"+c.query, bytes.NewReader(source)))
+		if rec.Code != c.status {
+			t.Errorf("%s answered %d (%s), want %d", c.query, rec.Code, bytes.TrimSpace(rec.Body.Bytes()), c.status)
+		}
+	}
+
+	// The server still answers after the refusals
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/healthz answered %d after the refusals", rec.Code)
+	}
+}
@@ -0,0 +1,197 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// maxRateClients bounds the clients whose request rate is remembered
+const maxRateClients = 100000
+
+// errRateLimited marks a request over the -rate of its client
+var errRateLimited = errors.New("too many requests")
+
+// errBusy marks a request that found the queue full or waited too long
+var errBusy = errors.New("server busy")
+
+// admission holds back requests before they read their source: each
+// client may start -rate a second with bursts of -burst, at most
+// -max-inflight images are processed at once, and up to -max-queue more
+// wait -queue-timeout for a slot, so a burst of large images cannot take
+// more than -max-inflight times -max-memory
+type admission struct {
+	slots          chan struct{} // One token per request being processed
+	queued         atomic.Int64  // Requests waiting for a slot
+	maxQueue       int64
+	queueTimeout   time.Duration
+	rates          *rateLimiter // Nil for no per-client limit
+	trustForwarded bool         // Name clients by X-Forwarded-For, set by a proxy in front
+}
+
+// limit returns serve run once r is admitted, refusing it with a
+// Retry-After header when its client is over the rate or the server
+// stays busy
+func (a *admission) limit(serve serveFunc) serveFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		// Assertion 1: The client is within its rate
+		if a.rates != nil {
+			if wait, ok := a.rates.allow(a.client(r), time.Now()); !ok {
+				w.Header().Set("Retry-After", retryAfter(wait))
+				return fmt.Errorf("%w: retry in %s", errRateLimited, wait.Round(time.Millisecond))
+			}
+		}
+
+		// Assertion 2: A slot, or a place in the queue for one
+		select {
+		case a.slots <- struct{}{}:
+		default:
+			if err := a.wait(w, r); err != nil {
+				return err
+			}
+		}
+
+		// A decoder a timeout left running keeps the slot until it ends,
+		// so -max-inflight bounds the work actually under way
+		running := &imageio.Running{}
+		defer func() {
+			go func() {
+				running.Wait()
+				<-a.slots
+			}()
+		}()
+
+		return serve(w, r.WithContext(imageio.WithRunning(r.Context(), running)))
+	}
+}
+
+// wait queues r until a slot frees, the queue timeout passes or the
+// client goes away; on success r holds a slot
+func (a *admission) wait(w http.ResponseWriter, r *http.Request) error {
+	if a.queued.Add(1) > a.maxQueue {
+		a.queued.Add(-1)
+		w.Header().Set("Retry-After", retryAfter(a.queueTimeout))
+		return fmt.Errorf("%w: the queue of %d requests is full", errBusy, a.maxQueue)
+	}
+	defer a.queued.Add(-1)
+
+	timer := time.NewTimer(a.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case a.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		w.Header().Set("Retry-After", retryAfter(a.queueTimeout))
+		return fmt.Errorf("%w: no slot freed within %s", errBusy, a.queueTimeout)
+	case <-r.Context().Done():
+		return fmt.Errorf("%w: the client went away while queued", errInterrupted)
+	}
+}
+
+// client returns who r comes from for the rate limit: the address the
+// last proxy saw with -trust-forwarded, the peer address otherwise
+func (a *admission) client(r *http.Request) string {
+	if a.trustForwarded {
+		// Each proxy appends the address it saw, so only the last one
+		// is not the client's own claim
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if hop := strings.TrimSpace(hops[len(hops)-1]); hop != "" {
+				return hop
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// retryAfter returns wait as the whole seconds of a Retry-After header,
+// at least 1
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(max(1, int(math.Ceil(wait.Seconds()))))
+}
+
+// rateLimiter keeps a token bucket per client: it holds up to burst
+// requests and refills at rate a second
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	clients map[string]*tokenBucket
+}
+
+// tokenBucket is the allowance of one client at the time it was last
+// updated
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns the limiter of rate requests a second with
+// bursts of burst, nil when rate is 0
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if rate == 0 {
+		return nil
+	}
+
+	return &rateLimiter{rate: rate, burst: float64(burst), clients: make(map[string]*tokenBucket)}
+}
+
+// allow takes a token of client at now, or returns how long until one
+// is there
+func (l *rateLimiter) allow(client string, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		// Assertion 1: Bound the clients remembered
+		if len(l.clients) >= maxRateClients {
+			l.forgetIdle(now)
+		}
+
+		// Past the bound a new client goes unremembered; the in-flight
+		// cap still holds
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		if len(l.clients) < maxRateClients {
+			l.clients[client] = bucket
+		}
+	}
+
+	bucket.tokens = min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second)), false
+	}
+
+	bucket.tokens--
+	return 0, true
+}
+
+// forgetIdle drops the clients whose buckets have refilled, which are
+// the same as new ones
+func (l *rateLimiter) forgetIdle(now time.Time) {
+	for client, bucket := range l.clients {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
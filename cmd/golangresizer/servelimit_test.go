@@ -0,0 +1,58 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// blockingReader stalls a decoder until release is closed
+type blockingReader struct {
+	release chan struct{}
+}
+
+func (b blockingReader) Read(p []byte) (int, error) {
+	<-b.release
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestAdmissionHoldsSlotUntilDecodeEnds(t *testing.T) {
+	a := &admission{slots: make(chan struct{}, 1), queueTimeout: time.Second}
+	release := make(chan struct{})
+
+	// The request times out while its decoder is stuck reading
+	serve := a.limit(func(w http.ResponseWriter, r *http.Request) error {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := imageio.DecodeImageContext(ctx, blockingReader{release}, imageio.DecodeOptions{})
+		return err
+	})
+
+	err := serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/resize", nil))
+	if !errors.Is(err, imageio.ErrCanceled) {
+		t.Fatalf("timed out request returned %v, want ErrCanceled", err)
+	}
+
+	// Assertion 1: The abandoned decoder still holds the only slot
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case a.slots <- struct{}{}:
+		t.Fatal("the slot was freed while the decoder was still running")
+	default:
+	}
+
+	close(release)
+	select {
+	case a.slots <- struct{}{}:
+	case <-time.After(time.Second):
+		t.Fatal("the slot was not freed once the decoder ended")
+	}
+}
@@ -0,0 +1,153 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resizePath starts the paths naming a resize and its source
+const resizePath = "/resize/"
+
+// plainSource starts a source path holding the URL itself, escaped,
+// instead of base64
+const plainSource = "plain/"
+
+// transform answers GET /resize/WxH/mode/source, the URL form of a
+// resize a CDN can sit in front of: size is WxH with either side empty or
+// 0 to keep the aspect, mode one of -mode, and source the URL of the
+// image, in unpadded base64url with an optional .ext choosing the output
+// format, or plain/ and the escaped URL with an optional @ext; the other
+// image options may follow as query parameters
+func (s *server) transform(w http.ResponseWriter, r *http.Request) error {
+	// Assertion 1: Resize paths are only read
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		return fmt.Errorf("%w: %s", errMethod, r.Method)
+	}
+
+	// size, mode and the source, still escaped so the slashes of a plain
+	// URL survive
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.EscapedPath(), resizePath), "/", 3)
+	if len(parts) < 3 {
+		return fmt.Errorf("%w: resize paths are %sWxH/mode/source", errBadRequest, resizePath)
+	}
+
+	width, height, err := parsePathSize(parts[0])
+	if err != nil {
+		return err
+	}
+
+	mode, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: invalid mode: %v", errBadRequest, err)
+	}
+
+	source, format, err := parsePathSource(parts[2])
+	if err != nil {
+		return err
+	}
+
+	params := r.URL.Query()
+	for _, name := range []string{"width", "w", "height", "h", "mode", "url"} {
+		params.Del(name)
+	}
+
+	if width > 0 {
+		params.Set("w", strconv.Itoa(width))
+	}
+	if height > 0 {
+		params.Set("h", strconv.Itoa(height))
+	}
+	params.Set("mode", mode)
+
+	if format != "" {
+		params.Del("format")
+		params.Set("f", format)
+	}
+
+	data, err := s.fetch(r.Context(), source)
+	if err != nil {
+		return err
+	}
+
+	if s.maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(s.maxAge/time.Second)))
+	}
+
+	return s.serveImage(w, r, resizeCommand, params, data)
+}
+
+// parsePathSize parses the WxH of a resize path, where an empty or 0 side
+// keeps the aspect and a bare number is the width
+func parsePathSize(size string) (int, int, error) {
+	w, h, _ := strings.Cut(size, "x")
+
+	width, err := parsePathSide(w)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid size %q", errBadRequest, size)
+	}
+
+	height, err := parsePathSide(h)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: invalid size %q", errBadRequest, size)
+	}
+
+	// Assertion 1: At least one side
+	if width == 0 && height == 0 {
+		return 0, 0, fmt.Errorf("%w: size %q gives neither a width nor a height", errBadRequest, size)
+	}
+
+	return width, height, nil
+}
+
+// parsePathSide parses one side of a resize path size, empty being 0
+func parsePathSide(side string) (int, error) {
+	if side == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(side)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid side %q", side)
+	}
+
+	return n, nil
+}
+
+// parsePathSource decodes the source of a resize path into the URL to
+// fetch and the output format its extension names, if any
+func parsePathSource(source string) (string, string, error) {
+	// Assertion 1: A source to fetch
+	if source == "" {
+		return "", "", fmt.Errorf("%w: the path names no source", errBadRequest)
+	}
+
+	if plain, ok := strings.CutPrefix(source, plainSource); ok {
+		escaped, format, _ := strings.Cut(plain, "@")
+		rawURL, err := url.PathUnescape(escaped)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: invalid plain source: %v", errBadRequest, err)
+		}
+
+		return rawURL, format, nil
+	}
+
+	// Long base64 may be split by slashes, and padding is optional
+	encoded := strings.ReplaceAll(source, "/", "")
+	format := strings.TrimPrefix(path.Ext(encoded), ".")
+	encoded = strings.TrimRight(strings.TrimSuffix(encoded, path.Ext(encoded)), "=")
+
+	rawURL, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: the source is neither base64url nor plain/: %v", errBadRequest, err)
+	}
+
+	return string(rawURL), format, nil
+}
@@ -0,0 +1,149 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// maxOutputSizes bounds how many outputs one invocation may produce
+const maxOutputSizes = 64
+
+// sizeSpec is one requested output size, a zero dimension follows the aspect
+type sizeSpec struct {
+	Width  int
+	Height int
+}
+
+// sizeList collects the values of -sizes and repeated -size flags
+type sizeList []sizeSpec
+
+// String implements flag.Value
+func (l *sizeList) String() string {
+	parts := make([]string, 0, len(*l))
+	for _, s := range *l {
+		parts = append(parts, fmt.Sprintf("%dx%d", s.Width, s.Height))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value, accepting comma separated W, WxH or xH entries
+func (l *sizeList) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		spec, err := parseSize(strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+
+		// Assertion 1: Bound the number of outputs
+		if len(*l) >= maxOutputSizes {
+			return fmt.Errorf("at most %d sizes are allowed", maxOutputSizes)
+		}
+
+		*l = append(*l, spec)
+	}
+
+	return nil
+}
+
+// parseSize parses W, WxH or xH into a sizeSpec
+func parseSize(s string) (sizeSpec, error) {
+	w, h, hasHeight := strings.Cut(s, "x")
+
+	var spec sizeSpec
+	var err error
+
+	if w != "" {
+		if spec.Width, err = strconv.Atoi(w); err != nil {
+			return sizeSpec{}, fmt.Errorf("invalid size %q", s)
+		}
+	}
+
+	if hasHeight && h != "" {
+		if spec.Height, err = strconv.Atoi(h); err != nil {
+			return sizeSpec{}, fmt.Errorf("invalid size %q", s)
+		}
+	}
+
+	// Assertion 1: Validate dimensions, one may follow the aspect
+	if err := validator.ValidateTargetDimensions(spec.Width, spec.Height); err != nil {
+		return sizeSpec{}, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	return spec, nil
+}
+
+// hasSizePlaceholder reports whether template names each output apart
+func hasSizePlaceholder(template string) bool {
+	return strings.Contains(template, "{w}") || strings.Contains(template, "{h}")
+}
+
+// outputPath expands {w}, {h} and {name}, the input file name without
+// extension, in an output path template
+func outputPath(template, input string, width, height int) string {
+	name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+
+	return strings.NewReplacer(
+		"{w}", strconv.Itoa(width),
+		"{h}", strconv.Itoa(height),
+		"{name}", name,
+	).Replace(template)
+}
+
+// runSizes resizes img once per requested size, reusing the decoded image
+// An icon output collects the sizes as entries of one file
+func runSizes(cfg *Config, img image.Image) error {
+	finishing, err := buildFinishing(cfg)
+	if err != nil {
+		return err
+	}
+
+	icon := isIcon(cfg)
+	var entries []image.Image
+
+	for i, spec := range cfg.Sizes {
+		sized := *cfg
+		sized.Width, sized.Height = spec.Width, spec.Height
+
+		r, err := newResizer(&sized)
+		if err != nil {
+			return err
+		}
+
+		out, err := resizeImage(&sized, r, img)
+		if err != nil {
+			return fmt.Errorf("size %d: %w", i+1, err)
+		}
+
+		out, err = finishing.Apply(out)
+		if err != nil {
+			return fmt.Errorf("transform failed: %w", err)
+		}
+
+		if icon {
+			entries = append(entries, out)
+			continue
+		}
+
+		// Name the file after the final size, borders included
+		bounds := out.Bounds()
+		if err := saveImage(cfg, outputPath(cfg.OutputPath, cfg.InputPath, bounds.Dx(), bounds.Dy()), out); err != nil {
+			return err
+		}
+	}
+
+	if icon {
+		if err := saveIcon(cfg, entries); err != nil {
+			return err
+		}
+	}
+
+	success(cfg.log, "Resized sizes successfully!", "count", len(cfg.Sizes))
+	return nil
+}
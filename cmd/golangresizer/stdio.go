@@ -0,0 +1,136 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// stdio is the path naming standard input for -i and standard output for
+// -o, so the tool can sit in a shell pipeline
+const stdio = "-"
+
+// validateStdio checks the options that cannot work on a stream
+func validateStdio(cfg *Config) error {
+	if cfg.buffered() && cfg.MemoryMap {
+		return fmt.Errorf("mmap needs a local input file, not standard input or remote storage")
+	}
+
+	if cfg.OutputPath != stdio {
+		return nil
+	}
+
+	// Assertion 1: Standard output takes one image in a named format
+	if cfg.batch {
+		return fmt.Errorf("a directory input cannot write to standard output")
+	}
+
+	if cfg.Format == "" {
+		return fmt.Errorf("writing to standard output needs -format")
+	}
+
+	if len(cfg.Sizes) > 1 && !isIcon(cfg) {
+		return fmt.Errorf("standard output takes one image, give a single size or -format ico")
+	}
+
+	return nil
+}
+
+// output returns where -o - writes: standard output, or the response
+// of a request in serve
+func (c *Config) output() io.Writer {
+	if c.stdout != nil {
+		return c.stdout
+	}
+
+	return os.Stdout
+}
+
+// pathName names path in progress messages
+func pathName(path, stream string) string {
+	if path == stdio {
+		return stream
+	}
+
+	return path
+}
+
+// readStdin reads standard input whole into cfg, giving up when the
+// context of cfg ends while the pipe is still open
+func readStdin(cfg *Config) error {
+	type result struct {
+		data []byte
+		err  error
+	}
+
+	// Buffered so an abandoned read can still end once the pipe does
+	results := make(chan result, 1)
+	go func() {
+		data, err := io.ReadAll(io.LimitReader(os.Stdin, validator.MaxFileSize+1))
+		results <- result{data, err}
+	}()
+
+	var read result
+	select {
+	case read = <-results:
+	case <-cfg.ctx.Done():
+		return cfg.ctx.Err()
+	}
+
+	// Assertion 1: Check the read and its size
+	if read.err != nil {
+		return fmt.Errorf("failed to read standard input: %w", read.err)
+	}
+
+	if int64(len(read.data)) > validator.MaxFileSize {
+		return fmt.Errorf("standard input is over the %d MiB input limit", validator.MaxFileSize>>20)
+	}
+
+	if len(read.data) == 0 {
+		return fmt.Errorf("standard input is empty")
+	}
+
+	cfg.stdin = read.data
+	return nil
+}
+
+// inputConfig returns the format and dimensions of the input
+func inputConfig(cfg *Config) (imageio.ImageConfig, error) {
+	if cfg.buffered() {
+		return imageio.DecodeImageConfig(bytes.NewReader(cfg.stdin))
+	}
+
+	return imageio.LoadImageConfig(cfg.InputPath)
+}
+
+// inputOrientation returns the EXIF orientation of the input
+func inputOrientation(cfg *Config) (int, error) {
+	if cfg.buffered() {
+		return imageio.DecodeOrientation(bytes.NewReader(cfg.stdin))
+	}
+
+	return imageio.ReadOrientation(cfg.InputPath)
+}
+
+// inputMetadata returns the EXIF, XMP and IPTC blocks of the input
+func inputMetadata(cfg *Config) (imageio.Metadata, error) {
+	if cfg.buffered() {
+		return imageio.DecodeMetadata(bytes.NewReader(cfg.stdin))
+	}
+
+	return imageio.ReadMetadata(cfg.InputPath)
+}
+
+// inputPNGChunks returns the PNG chunks -keep-png-chunks copies
+func inputPNGChunks(cfg *Config) ([]imageio.PNGChunk, error) {
+	if cfg.buffered() {
+		return imageio.DecodePNGChunks(bytes.NewReader(cfg.stdin))
+	}
+
+	return imageio.ReadPNGChunks(cfg.InputPath)
+}
@@ -0,0 +1,98 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/transform"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// streamResize resizes a TIFF over -max-memory one strip at a time, need
+// being the estimate of loading it whole
+func streamResize(cfg *Config, r *resizer.Resizer, reader *imageio.TIFFReader, need int64) error {
+	if r == nil {
+		return overMemory(cfg, need, "only a single resize can be streamed")
+	}
+
+	if reason := streamBlocker(cfg, reader); reason != "" {
+		return overMemory(cfg, need, reason+" cannot be streamed")
+	}
+
+	bounds := reader.Bounds()
+	if cfg.Crop != "" {
+		rect, err := cropRect(cfg, bounds)
+		if err != nil {
+			return err
+		}
+
+		r, err = r.WithRegion(rect)
+		if err != nil {
+			return fmt.Errorf("invalid crop: %w", err)
+		}
+	}
+
+	stream, err := r.NewStream(reader, int64(cfg.MaxMemory))
+	if err != nil {
+		return fmt.Errorf("cannot resize within -max-memory: %w", err)
+	}
+
+	width, height := stream.Size()
+	cfg.log.Info("Source dimensions", "width", bounds.Dx(), "height", bounds.Dy())
+	cfg.source(bounds.Size())
+	cfg.log.Info("Target dimensions", "width", width, "height", height)
+	cfg.log.Info("Streaming in bands", "rows", stream.BandHeight(), "peak_mib", stream.Peak()>>20+1)
+
+	writer, err := imageio.CreateTIFF(cfg.OutputPath, width, height, stream.ColorModel(), reader.Opaque(), saveOptions(cfg).TIFF)
+	if err != nil {
+		return fmt.Errorf("failed to save image: %w", err)
+	}
+
+	// Close drops a partial file rather than leave it looking complete
+	err = stream.RunContext(cfg.ctx, writer)
+	cfg.bar.clear()
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("resize failed: %w", err)
+	}
+	cfg.wrote(cfg.OutputPath, image.Pt(width, height))
+
+	success(cfg.log, "Resize completed successfully!")
+	return nil
+}
+
+// streamBlocker names the first option that needs the whole image, empty
+// when the resize can stream
+func streamBlocker(cfg *Config, reader *imageio.TIFFReader) string {
+	switch format := outputFormat(cfg); {
+	case format != ".tif" && format != ".tiff":
+		return format + " output"
+	case cfg.DataURI:
+		return "-data-uri"
+	case cfg.OutputPath == stdio:
+		return "standard output"
+	case cfg.Rotate != 0 || cfg.Flip != "" || cfg.Extend != "" || cfg.Colors != 0:
+		return "a transform"
+	case cfg.KeepEXIF || cfg.KeepMetadata || cfg.StripGPS || cfg.XMPPath != "" || cfg.Artist != "" || cfg.Copyright != "":
+		return "metadata"
+	case cfg.DPI != 0:
+		return "-dpi"
+	case cfg.Crop != "" && smartGravity(cfg):
+		return "-gravity smart"
+	case !cfg.NoAutoOrient && reader.Orientation() != 1:
+		return "turning the image upright (keep it as stored with -no-auto-orient)"
+	default:
+		return ""
+	}
+}
+
+// smartGravity reports whether -gravity places by image content
+func smartGravity(cfg *Config) bool {
+	anchor, err := parseAnchor(cfg.Gravity)
+	return err == nil && anchor.Gravity == transform.GravitySmart && anchor.Focal == nil
+}
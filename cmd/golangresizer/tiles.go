@@ -0,0 +1,98 @@
+// Open source image resizer coded by kasuraSH
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/pyramid"
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// tilesCommand is the subcommand name that builds a tile pyramid
+const tilesCommand = "tiles"
+
+// runTiles parses the tiles subcommand arguments and writes the pyramid
+func runTiles(args []string) error {
+	fs := flag.NewFlagSet(tilesCommand, flag.ContinueOnError)
+
+	var input, output, layout, format, filter, baseURL string
+	var tileSize, overlap int
+	var noAutoOrient bool
+	logCfg := &Config{}
+
+	fs.StringVar(&input, "i", "", "Input image file path (required)")
+	fs.StringVar(&output, "o", "", "Output base path (DZI) or directory (IIIF) (required)")
+	fs.StringVar(&layout, "layout", string(pyramid.LayoutDZI), "Pyramid layout: dzi, iiif")
+	fs.IntVar(&tileSize, "tile-size", pyramid.DefaultTileSize, "Tile edge in pixels")
+	fs.IntVar(&overlap, "overlap", 0, "Pixels shared with neighbouring tiles (DZI only)")
+	fs.StringVar(&format, "format", "jpg", "Tile format: jpg, png, bmp, tiff")
+	fs.StringVar(&filter, "filter", string(resizer.FilterBicubic), "Filter used to build each level: "+strings.Join(resizer.FilterNames(), ", "))
+	fs.StringVar(&baseURL, "base-url", ".", "IIIF service id written to info.json")
+	fs.BoolVar(&noAutoOrient, "no-auto-orient", false, "Keep the stored pixel orientation instead of applying the EXIF orientation")
+	fs.BoolVar(&logCfg.Quiet, "quiet", false, "Log only warnings and errors")
+	fs.BoolVar(&logCfg.Verbose, "verbose", false, "Also log the image size and pyramid settings")
+	fs.StringVar(&logCfg.LogFormat, "log-format", logText, "Status messages as text lines, or json objects on stderr")
+	fs.StringVar(&logCfg.Color, "color", colorAuto, "Color errors, warnings and successes: auto (on a terminal without NO_COLOR), always or never")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Assertion 1: Validate required parameters
+	if err := validator.ValidatePath(input); err != nil {
+		return fmt.Errorf("invalid input path: %w", err)
+	}
+
+	if err := validator.ValidatePath(output); err != nil {
+		return fmt.Errorf("invalid output path: %w", err)
+	}
+
+	// Assertion 2: Validate choices
+	parsedLayout, err := pyramid.ParseLayout(layout)
+	if err != nil {
+		return err
+	}
+
+	parsedFilter, err := resizer.ParseFilter(filter)
+	if err != nil {
+		return fmt.Errorf("invalid filter: %w", err)
+	}
+
+	if err := parseLogFormat(logCfg); err != nil {
+		return err
+	}
+
+	if err := parseColor(logCfg.Color); err != nil {
+		return err
+	}
+
+	log := newLogger(logCfg, nil)
+	log.Info("Loading image", "path", input)
+	img, err := imageio.LoadImageWithOptions(input, imageio.DecodeOptions{AutoOrient: !noAutoOrient})
+	if err != nil {
+		return fmt.Errorf("failed to load image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	log.Debug("Source dimensions", "width", bounds.Dx(), "height", bounds.Dy())
+	log.Info("Writing pyramid", "layout", parsedLayout, "path", output)
+	log.Debug("Pyramid settings", "tile-size", tileSize, "overlap", overlap, "format", format, "filter", parsedFilter)
+	err = pyramid.Generate(img, output, pyramid.Config{
+		Layout:   parsedLayout,
+		TileSize: tileSize,
+		Overlap:  overlap,
+		Format:   strings.TrimPrefix(format, "."),
+		Filter:   parsedFilter,
+		BaseURL:  baseURL,
+	})
+	if err != nil {
+		return fmt.Errorf("pyramid failed: %w", err)
+	}
+
+	success(log, "Pyramid completed successfully!")
+	return nil
+}
@@ -0,0 +1,217 @@
+// Open source image resizer coded by kasuraSH
+package hdr
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Color is a premultiplied RGBA color with float32 channels where 1.0 is
+// the nominal white; channels may exceed 1.0 or drop below zero
+type Color struct {
+	R, G, B, A float32
+}
+
+// RGBA implements color.Color, clipping each channel to [0, 1]
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return clip(c.R), clip(c.G), clip(c.B), clip(c.A)
+}
+
+// clip converts a float channel to 16 bits, clipping it to [0, 1]
+func clip(v float32) uint32 {
+	if !(v > 0) {
+		return 0
+	}
+
+	if v >= 1 {
+		return 0xFFFF
+	}
+
+	return uint32(v*0xFFFF + 0.5)
+}
+
+// ColorModel converts any color to a Color
+var ColorModel color.Model = color.ModelFunc(floatModel)
+
+// floatModel implements ColorModel
+func floatModel(c color.Color) color.Color {
+	if f, ok := c.(Color); ok {
+		return f
+	}
+
+	return FromColor(c)
+}
+
+// FromColor converts a 16-bit color to a Color
+func FromColor(c color.Color) Color {
+	r, g, b, a := c.RGBA()
+	return Color{R: float32(r) / 0xFFFF, G: float32(g) / 0xFFFF, B: float32(b) / 0xFFFF, A: float32(a) / 0xFFFF}
+}
+
+// RGBA is an in-memory image of Color values, laid out like image.RGBA
+// with four float32 values per pixel
+type RGBA struct {
+	Pix    []float32
+	Stride int
+	Rect   image.Rectangle
+}
+
+// NewRGBA returns a new RGBA image with the given bounds
+func NewRGBA(r image.Rectangle) *RGBA {
+	return &RGBA{
+		Pix:    make([]float32, 4*r.Dx()*r.Dy()),
+		Stride: 4 * r.Dx(),
+		Rect:   r,
+	}
+}
+
+// ColorModel implements image.Image
+func (p *RGBA) ColorModel() color.Model {
+	return ColorModel
+}
+
+// Bounds implements image.Image
+func (p *RGBA) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At implements image.Image
+func (p *RGBA) At(x, y int) color.Color {
+	return p.FloatAt(x, y)
+}
+
+// FloatAt returns the color of the pixel at (x, y)
+func (p *RGBA) FloatAt(x, y int) Color {
+	// Assertion 1: Pixels outside the bounds are transparent
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return Color{}
+	}
+
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	return Color{R: s[0], G: s[1], B: s[2], A: s[3]}
+}
+
+// PixOffset returns the index of the first element of Pix for (x, y)
+func (p *RGBA) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x-p.Rect.Min.X)*4
+}
+
+// Set implements draw.Image
+func (p *RGBA) Set(x, y int, c color.Color) {
+	p.SetFloat(x, y, floatModel(c).(Color))
+}
+
+// SetFloat sets the color of the pixel at (x, y)
+func (p *RGBA) SetFloat(x, y int, c Color) {
+	// Assertion 1: Writes outside the bounds are ignored
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return
+	}
+
+	i := p.PixOffset(x, y)
+	s := p.Pix[i : i+4 : i+4]
+	s[0], s[1], s[2], s[3] = c.R, c.G, c.B, c.A
+}
+
+// SubImage returns the part of p visible through r, sharing its pixels
+func (p *RGBA) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+
+	// Assertion 1: An empty intersection yields an empty image
+	if r.Empty() {
+		return &RGBA{}
+	}
+
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &RGBA{Pix: p.Pix[i:], Stride: p.Stride, Rect: r}
+}
+
+// Opaque scans the image and reports whether it is fully opaque
+func (p *RGBA) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		i := p.PixOffset(p.Rect.Min.X, y)
+		for x := 0; x < p.Rect.Dx(); x++ {
+			if p.Pix[i+4*x+3] < 1 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// FloatAt returns the float color of any image at (x, y), reading Pix
+// directly for RGBA so values beyond white survive
+func FloatAt(img image.Image, x, y int) Color {
+	if p, ok := img.(*RGBA); ok {
+		return p.FloatAt(x, y)
+	}
+
+	return FromColor(img.At(x, y))
+}
+
+// HalfToFloat expands an IEEE 754 half precision value
+func HalfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h) & 0x3FF
+
+	switch {
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Subnormal halves become normal floats
+		v := float32(mant) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case exp == 0x1F:
+		return math.Float32frombits(sign | 0x7F800000 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | mant<<13)
+	}
+}
+
+// FloatToHalf rounds f to the nearest IEEE 754 half precision value,
+// overflowing to infinity
+func FloatToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xFF
+	mant := bits & 0x7FFFFF
+
+	switch {
+	case exp == 0xFF:
+		// Infinity stays infinity, NaN keeps a set mantissa bit
+		if mant != 0 {
+			return sign | 0x7E00
+		}
+		return sign | 0x7C00
+	case exp > 142:
+		return sign | 0x7C00
+	case exp < 103:
+		return sign
+	case exp < 113:
+		// Subnormal half, round the shifted mantissa to nearest even
+		mant |= 0x800000
+		shift := uint(126 - exp)
+		half := mant >> shift
+		rest := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rest > mid || (rest == mid && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+
+	half := uint32(exp-112)<<10 | mant>>13
+	rest := mant & 0x1FFF
+	if rest > 0x1000 || (rest == 0x1000 && half&1 == 1) {
+		half++
+	}
+
+	return sign | uint16(half)
+}
@@ -0,0 +1,57 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+// GridRange returns the minimum and maximum of the grid pixels that receive
+// a non-zero kernel weight at the given fractional offset
+func GridRange(pixels [][]float64, dx, dy float64, k Kernel) (float64, float64) {
+	lo := math.Inf(1)
+	hi := math.Inf(-1)
+
+	taps, err := validateGrid(pixels)
+	if err != nil {
+		return lo, hi
+	}
+
+	offset := float64(taps/2 - 1)
+
+	for j := 0; j < taps; j++ {
+		if k.Weight(float64(j)-offset-dy) == 0.0 {
+			continue
+		}
+
+		for i := 0; i < taps; i++ {
+			if k.Weight(float64(i)-offset-dx) == 0.0 {
+				continue
+			}
+
+			lo = math.Min(lo, pixels[j][i])
+			hi = math.Max(hi, pixels[j][i])
+		}
+	}
+
+	return lo, hi
+}
+
+// ClampRange limits value to [lo, hi], suppressing overshoot halos
+// An empty range (lo > hi) leaves the value unchanged
+func ClampRange(value, lo, hi float64) float64 {
+	// Assertion 1: Ignore empty ranges
+	if lo > hi {
+		return value
+	}
+
+	// Assertion 2: Clamp to the contributing source range
+	if value < lo {
+		return lo
+	}
+
+	if value > hi {
+		return hi
+	}
+
+	return value
+}
@@ -9,8 +9,21 @@ import (
 const (
 	// KernelSize defines the bicubic kernel support (4x4 pixels)
 	KernelSize = 4
-	MaxUint16  = 65535
-	MaxUint8   = 255
+	// MaxKernelTaps bounds the grid size accepted by the grid interpolators
+	MaxKernelTaps = 64
+	// CubicSupport is the radius of the cubic kernels in source pixels
+	CubicSupport = 2.0
+	MaxUint16    = 65535
+	MaxUint8     = 255
+)
+
+const (
+	// MitchellB and MitchellC are the Mitchell-Netravali cubic parameters
+	MitchellB = 1.0 / 3.0
+	MitchellC = 1.0 / 3.0
+	// CatmullRomB and CatmullRomC are the Catmull-Rom cubic parameters
+	CatmullRomB = 0.0
+	CatmullRomC = 0.5
 )
 
 var (
@@ -21,9 +34,17 @@ var (
 // CubicWeight calculates the bicubic interpolation weight
 // Uses Mitchell-Netravali filter (B=1/3, C=1/3) for optimal quality
 func CubicWeight(x float64) float64 {
-	const b = 1.0 / 3.0
-	const c = 1.0 / 3.0
+	return BCCubicWeight(x, MitchellB, MitchellC)
+}
 
+// CatmullRomWeight calculates the Catmull-Rom cubic weight (B=0, C=0.5)
+// Sharper than Mitchell and matches ImageMagick's Catmull-Rom filter
+func CatmullRomWeight(x float64) float64 {
+	return BCCubicWeight(x, CatmullRomB, CatmullRomC)
+}
+
+// BCCubicWeight calculates the weight of the generic BC-spline cubic family
+func BCCubicWeight(x, b, c float64) float64 {
 	// Assertion 1: Ensure x is positive for calculation
 	x = math.Abs(x)
 
@@ -84,49 +105,117 @@ func GetSafeIndex(index, maxIndex int) int {
 	return index
 }
 
-// CalculateKernelBounds determines the pixel sampling region for bicubic interpolation
-func CalculateKernelBounds(center float64, maxBound int) (int, int, error) {
-	const halfKernel = KernelSize / 2
+// KernelTaps returns the number of grid pixels per axis needed by a
+// kernel of the given support radius, 4 for the cubic kernels
+func KernelTaps(support float64) int {
+	taps := 2 * int(math.Ceil(support))
+
+	// Assertion 1: Always sample at least the two neighbouring pixels
+	if taps < 2 {
+		return 2
+	}
+
+	return taps
+}
 
+// CalculateKernelBounds determines the pixel sampling region of taps pixels
+// around center, KernelSize taps for bicubic interpolation
+func CalculateKernelBounds(center float64, taps, maxBound int) (int, int, error) {
 	// Assertion 1: Validate center coordinate
 	if center < 0.0 || center >= float64(maxBound) {
 		return 0, 0, ErrInvalidCoordinate
 	}
 
-	// Calculate bounds with fixed kernel size
-	start := int(math.Floor(center)) - halfKernel + 1
-	end := start + KernelSize
-
-	// Assertion 2: Ensure bounds are calculable
-	if end-start != KernelSize {
-		return 0, 0, ErrInvalidCoordinate
+	// Assertion 2: Validate tap count fits the fixed weight buffers
+	if taps < 2 || taps > MaxKernelTaps || taps%2 != 0 {
+		return 0, 0, ErrInvalidKernel
 	}
 
+	start := int(math.Floor(center)) - taps/2 + 1
+	end := start + taps
+
 	return start, end, nil
 }
 
 // InterpolateBicubic performs bicubic interpolation on a 4x4 pixel grid
-func InterpolateBicubic(pixels [KernelSize][KernelSize]float64, dx, dy float64) (float64, error) {
+func InterpolateBicubic(pixels [][]float64, dx, dy float64) (float64, error) {
+	return interpolateGrid(pixels, dx, dy, CubicWeight)
+}
+
+// InterpolateCatmullRom performs Catmull-Rom interpolation on a 4x4 pixel grid
+func InterpolateCatmullRom(pixels [][]float64, dx, dy float64) (float64, error) {
+	return interpolateGrid(pixels, dx, dy, CatmullRomWeight)
+}
+
+// validateGrid checks that pixels is a square grid of an even tap count
+func validateGrid(pixels [][]float64) (int, error) {
+	taps := len(pixels)
+
+	// Assertion 1: Validate tap count
+	if taps < 2 || taps > MaxKernelTaps || taps%2 != 0 {
+		return 0, ErrInvalidKernel
+	}
+
+	// Assertion 2: Validate every row matches
+	for j := 0; j < taps; j++ {
+		if len(pixels[j]) != taps {
+			return 0, ErrInvalidKernel
+		}
+	}
+
+	return taps, nil
+}
+
+// interpolateGrid applies a separable weight function to a square pixel
+// grid whose pixel taps/2-1 sits at the integer part of the sample position
+// Weights are normalized per axis so kernels that do not sum to one stay exact
+func interpolateGrid(pixels [][]float64, dx, dy float64, weight func(float64) float64) (float64, error) {
 	// Assertion 1: Validate fractional coordinates
 	if dx < 0.0 || dx > 1.0 || dy < 0.0 || dy > 1.0 {
 		return 0.0, ErrInvalidCoordinate
 	}
 
+	taps, err := validateGrid(pixels)
+	if err != nil {
+		return 0.0, err
+	}
+
+	var wxBuf, wyBuf [MaxKernelTaps]float64
+	wx := wxBuf[:taps]
+	wy := wyBuf[:taps]
+	offset := float64(taps/2 - 1)
+	var sumX, sumY float64
+
+	for i := 0; i < taps; i++ {
+		wx[i] = weight(float64(i) - offset - dx)
+		wy[i] = weight(float64(i) - offset - dy)
+		sumX += wx[i]
+		sumY += wy[i]
+	}
+
+	// Assertion 2: Ensure weights can be normalized
+	if sumX == 0.0 || sumY == 0.0 {
+		return 0.0, ErrInvalidChannel
+	}
+
 	var result float64
 
-	for j := 0; j < KernelSize; j++ {
-		var rowSum float64
-		wy := CubicWeight(float64(j-1) - dy)
+	for j := 0; j < taps; j++ {
+		if wy[j] == 0.0 {
+			continue
+		}
 
-		for i := 0; i < KernelSize; i++ {
-			wx := CubicWeight(float64(i-1) - dx)
-			rowSum += pixels[j][i] * wx
+		var rowSum float64
+		for i := 0; i < taps; i++ {
+			rowSum += pixels[j][i] * wx[i]
 		}
 
-		result += rowSum * wy
+		result += rowSum * wy[j]
 	}
 
-	// Assertion 2: Validate result is not NaN or Inf
+	result /= sumX * sumY
+
+	// Assertion 3: Validate result is not NaN or Inf
 	if math.IsNaN(result) || math.IsInf(result, 0) {
 		return 0.0, ErrInvalidChannel
 	}
@@ -0,0 +1,28 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+// LinearSupport is the radius of the triangle kernel in source pixels
+const LinearSupport = 1.0
+
+// LinearWeight calculates the bilinear (triangle) interpolation weight
+func LinearWeight(x float64) float64 {
+	// Assertion 1: Ensure x is positive for calculation
+	x = math.Abs(x)
+
+	// Assertion 2: Check bounds for the triangle function
+	if x < 1.0 {
+		return 1.0 - x
+	}
+
+	return 0.0
+}
+
+// InterpolateBilinear performs bilinear interpolation on a 2x2 or 4x4 pixel grid
+// On a 4x4 grid only the inner 2x2 pixels contribute
+func InterpolateBilinear(pixels [][]float64, dx, dy float64) (float64, error) {
+	return interpolateGrid(pixels, dx, dy, LinearWeight)
+}
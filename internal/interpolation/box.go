@@ -0,0 +1,31 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+const (
+	// NameBox is the registry name of the box (area average) kernel
+	NameBox = "box"
+	// BoxSupport is the radius of the box kernel in source pixels
+	BoxSupport = 0.5
+)
+
+// BoxWeight calculates the box kernel weight
+// When widened by the shrink factor this averages the covered source area
+func BoxWeight(x float64) float64 {
+	// Assertion 1: Ensure x is positive for calculation
+	x = math.Abs(x)
+
+	// Assertion 2: Split the weight of samples sitting exactly on the edge
+	if x < BoxSupport {
+		return 1.0
+	}
+
+	if x == BoxSupport {
+		return 0.5
+	}
+
+	return 0.0
+}
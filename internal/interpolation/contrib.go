@@ -0,0 +1,81 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"fmt"
+)
+
+const (
+	// regionEpsilon absorbs rounding when a region ends on the source border
+	regionEpsilon = 1e-9
+	// pixelCenter is the offset from the edge of a pixel to its sample
+	// point, region coordinates measuring edges and weights sample points
+	pixelCenter = 0.5
+)
+
+// Contribution lists the source pixels feeding one destination index
+// Weights[i] applies to source index Start+i and the weights sum to one
+type Contribution struct {
+	Start   int
+	Weights []float64
+}
+
+// ComputeContributions precomputes the weight table for resampling one
+// axis of srcSize pixels to dstSize pixels with kernel k
+func ComputeContributions(srcSize, dstSize int, k Kernel) ([]Contribution, error) {
+	return ComputeRegionContributions(srcSize, 0.0, float64(srcSize), dstSize, k)
+}
+
+// ComputeRegionContributions precomputes the weight table for resampling
+// the span [regionStart, regionStart+regionSize) of a srcSize pixel axis
+// to dstSize pixels, so cropping and scaling happen in a single pass
+// Pixel i spans [i, i+1) of an axis, so an identity resize samples each
+// source pixel exactly and a whole-factor box reduction averages blocks
+// When downscaling the kernel is widened by the shrink factor so every
+// source pixel contributes; all weights share a single backing array
+func ComputeRegionContributions(srcSize int, regionStart, regionSize float64, dstSize int, k Kernel) ([]Contribution, error) {
+	// Assertion 1: Validate sizes
+	if srcSize <= 0 || dstSize <= 0 {
+		return nil, fmt.Errorf("%w: sizes must be positive", ErrInvalidScale)
+	}
+
+	// Assertion 2: Validate the region lies inside the source
+	if regionSize <= 0.0 || regionStart < 0.0 || regionStart+regionSize > float64(srcSize)+regionEpsilon {
+		return nil, fmt.Errorf("%w: region outside source", ErrInvalidCoordinate)
+	}
+
+	// Assertion 3: Validate kernel
+	if k == nil {
+		return nil, ErrInvalidKernel
+	}
+
+	ratio := regionSize / float64(dstSize)
+	scale := 1.0
+	if ratio > 1.0 {
+		scale = ratio
+	}
+
+	radius := k.Support() * scale
+	taps := ScaledTaps(radius)
+
+	contribs := make([]Contribution, dstSize)
+	backing := make([]float64, dstSize*taps)
+
+	for i := 0; i < dstSize; i++ {
+		center := regionStart + (float64(i)+0.5)*ratio - pixelCenter
+
+		start, end, err := CalculateScaledBounds(center, radius, srcSize)
+		if err != nil {
+			return nil, err
+		}
+
+		weights := backing[i*taps : i*taps+(end-start)]
+		if err := FillWeights(weights, center, scale, start, k.Weight); err != nil {
+			return nil, err
+		}
+
+		contribs[i] = Contribution{Start: start, Weights: weights}
+	}
+
+	return contribs, nil
+}
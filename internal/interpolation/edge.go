@@ -0,0 +1,74 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EdgeMode selects how samples outside the source image are resolved
+type EdgeMode string
+
+const (
+	// EdgeClamp repeats the border pixel (default)
+	EdgeClamp EdgeMode = "clamp"
+	// EdgeMirror reflects the image about its border
+	EdgeMirror EdgeMode = "mirror"
+	// EdgeWrap tiles the image, suited to seamless textures
+	EdgeWrap EdgeMode = "wrap"
+	// EdgeConstant treats everything outside as a fixed color
+	EdgeConstant EdgeMode = "constant"
+)
+
+var ErrUnknownEdgeMode = errors.New("unknown edge mode")
+
+// ParseEdgeMode converts a name into an EdgeMode
+func ParseEdgeMode(name string) (EdgeMode, error) {
+	switch EdgeMode(name) {
+	case EdgeClamp, EdgeMirror, EdgeWrap, EdgeConstant:
+		return EdgeMode(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownEdgeMode, name)
+	}
+}
+
+// ResolveIndex maps index onto [0, maxIndex) according to mode
+// It reports false when the sample lies outside in EdgeConstant mode
+func ResolveIndex(index, maxIndex int, mode EdgeMode) (int, bool) {
+	// Assertion 1: Indices inside the image never need resolving
+	if index >= 0 && index < maxIndex {
+		return index, true
+	}
+
+	// Assertion 2: Guard against empty axes
+	if maxIndex <= 0 {
+		return 0, false
+	}
+
+	switch mode {
+	case EdgeMirror:
+		// Symmetric reflection with period 2*maxIndex: -1 -> 0, max -> max-1
+		period := 2 * maxIndex
+		m := index % period
+		if m < 0 {
+			m += period
+		}
+
+		if m >= maxIndex {
+			m = period - 1 - m
+		}
+
+		return m, true
+	case EdgeWrap:
+		m := index % maxIndex
+		if m < 0 {
+			m += maxIndex
+		}
+
+		return m, true
+	case EdgeConstant:
+		return 0, false
+	default:
+		return GetSafeIndex(index, maxIndex), true
+	}
+}
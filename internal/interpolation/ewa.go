@@ -0,0 +1,92 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrDegenerateEllipse = errors.New("degenerate sampling ellipse")
+
+// Ellipse is the footprint of one destination pixel in source space for
+// elliptical weighted average (EWA) resampling
+// It is stored as the inverse quadratic form so Distance is cheap
+type Ellipse struct {
+	invA    float64
+	invB    float64
+	invC    float64
+	extentX float64
+	extentY float64
+}
+
+// NewEllipse builds the footprint from the Jacobian of the destination to
+// source mapping, [[dsx/ddx dsx/ddy] [dsy/ddx dsy/ddy]]
+// Axes shorter than one source pixel are stretched to one pixel so that
+// upscaling still reconstructs from a full kernel footprint
+func NewEllipse(j00, j01, j10, j11 float64) (Ellipse, error) {
+	// M = J * J^T describes the ellipse covered by a unit destination circle
+	a := j00*j00 + j01*j01
+	b := j00*j10 + j01*j11
+	c := j10*j10 + j11*j11
+
+	// Assertion 1: Validate the form is finite
+	if math.IsNaN(a+b+c) || math.IsInf(a+b+c, 0) {
+		return Ellipse{}, ErrDegenerateEllipse
+	}
+
+	// Eigen-decompose the symmetric 2x2 matrix
+	half := (a + c) / 2.0
+	disc := math.Sqrt((a-c)*(a-c)/4.0 + b*b)
+	l1 := half + disc
+	l2 := half - disc
+
+	vx, vy := 1.0, 0.0
+	if b != 0.0 {
+		vx, vy = l1-c, b
+		n := math.Hypot(vx, vy)
+		vx, vy = vx/n, vy/n
+	} else if c > a {
+		vx, vy = 0.0, 1.0
+	}
+
+	// Assertion 2: Never let an axis fall below one source pixel
+	l1 = math.Max(l1, 1.0)
+	l2 = math.Max(l2, 1.0)
+
+	// Rebuild M from the clamped eigenvalues, w = (-vy, vx)
+	ma := l1*vx*vx + l2*vy*vy
+	mb := (l1 - l2) * vx * vy
+	mc := l1*vy*vy + l2*vx*vx
+
+	det := ma*mc - mb*mb
+
+	// Assertion 3: Validate the clamped form is invertible
+	if det <= 0.0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Ellipse{}, ErrDegenerateEllipse
+	}
+
+	return Ellipse{
+		invA:    mc / det,
+		invB:    -mb / det,
+		invC:    ma / det,
+		extentX: math.Sqrt(ma),
+		extentY: math.Sqrt(mc),
+	}, nil
+}
+
+// Distance returns the normalized radial distance of the offset (dx, dy)
+// A value of 1 lies on the edge of the unit footprint
+func (e Ellipse) Distance(dx, dy float64) float64 {
+	q := e.invA*dx*dx + 2.0*e.invB*dx*dy + e.invC*dy*dy
+	if q <= 0.0 {
+		return 0.0
+	}
+
+	return math.Sqrt(q)
+}
+
+// Extent returns the half-size of the axis-aligned box enclosing the
+// footprint scaled to the given kernel radius
+func (e Ellipse) Extent(radius float64) (float64, float64) {
+	return e.extentX * radius, e.extentY * radius
+}
@@ -0,0 +1,79 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+const (
+	// FixedShift is the number of fractional bits of the 16.16 weights
+	FixedShift = 16
+	// FixedOne is 1.0 in 16.16 fixed point
+	FixedOne = 1 << FixedShift
+)
+
+// FixedContribution is a Contribution with 16.16 fixed-point weights
+// The weights of every entry sum to exactly FixedOne
+type FixedContribution struct {
+	Start   int
+	Weights []int32
+}
+
+// ToFixedContributions converts a float weight table to 16.16 fixed point
+// Rounding error is folded into the largest weight so brightness is exact
+func ToFixedContributions(contribs []Contribution) []FixedContribution {
+	total := 0
+	for i := 0; i < len(contribs); i++ {
+		total += len(contribs[i].Weights)
+	}
+
+	fixed := make([]FixedContribution, len(contribs))
+	backing := make([]int32, total)
+	offset := 0
+
+	for i := 0; i < len(contribs); i++ {
+		src := contribs[i].Weights
+		weights := backing[offset : offset+len(src)]
+		offset += len(src)
+
+		var sum int32
+		largest := 0
+		for j := 0; j < len(src); j++ {
+			weights[j] = int32(math.Round(src[j] * FixedOne))
+			sum += weights[j]
+
+			if weights[j] > weights[largest] {
+				largest = j
+			}
+		}
+
+		// Assertion 1: Keep the weights summing to exactly one
+		if len(weights) > 0 {
+			weights[largest] += FixedOne - sum
+		}
+
+		fixed[i] = FixedContribution{Start: contribs[i].Start, Weights: weights}
+	}
+
+	return fixed
+}
+
+// FixedToUint8 rounds a 16.16 squared accumulator (32 fractional bits)
+// to an 8-bit channel value, clamping overshoot from negative lobes
+func FixedToUint8(acc int64) uint8 {
+	const half = int64(1) << (2*FixedShift - 1)
+
+	value := (acc + half) >> (2 * FixedShift)
+
+	// Assertion 1: Check lower bound
+	if value < 0 {
+		return 0
+	}
+
+	// Assertion 2: Check upper bound
+	if value > MaxUint8 {
+		return MaxUint8
+	}
+
+	return uint8(value)
+}
@@ -0,0 +1,54 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	// DefaultGaussianSigma matches the common soft Gaussian resampling filter
+	DefaultGaussianSigma = 0.5
+	// MaxGaussianSigma bounds the kernel radius to keep sampling cost fixed
+	MaxGaussianSigma = 8.0
+	// gaussianSupportSigmas truncates the kernel at three standard deviations
+	gaussianSupportSigmas = 3.0
+)
+
+var ErrInvalidSigma = errors.New("gaussian sigma out of range")
+
+// ValidateGaussianSigma checks that sigma is usable as a kernel width
+func ValidateGaussianSigma(sigma float64) error {
+	// Assertion 1: Reject NaN and non-positive widths
+	if math.IsNaN(sigma) || sigma <= 0.0 {
+		return ErrInvalidSigma
+	}
+
+	// Assertion 2: Reject widths that would explode the kernel footprint
+	if sigma > MaxGaussianSigma {
+		return ErrInvalidSigma
+	}
+
+	return nil
+}
+
+// GaussianSupport returns the truncated kernel radius for sigma
+func GaussianSupport(sigma float64) float64 {
+	return gaussianSupportSigmas * sigma
+}
+
+// GaussianWeight calculates the Gaussian weight at distance x
+// The result is not normalized, callers must divide by the weight sum
+func GaussianWeight(x, sigma float64) float64 {
+	// Assertion 1: Guard against invalid widths
+	if sigma <= 0.0 {
+		return 0.0
+	}
+
+	// Assertion 2: Truncate outside the support
+	if math.Abs(x) >= GaussianSupport(sigma) {
+		return 0.0
+	}
+
+	return math.Exp(-(x * x) / (2.0 * sigma * sigma))
+}
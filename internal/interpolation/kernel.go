@@ -0,0 +1,177 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// NameBicubic is the registry name of the Mitchell-Netravali cubic
+	NameBicubic = "bicubic"
+	// NameBilinear is the registry name of the triangle kernel
+	NameBilinear = "bilinear"
+	// NameCatmullRom is the registry name of the Catmull-Rom cubic
+	NameCatmullRom = "catmullrom"
+	// NameGaussian is the registry name of the Gaussian kernel
+	NameGaussian = "gaussian"
+	// NameMagicKernelSharp2013 is the registry name of Magic Kernel Sharp 2013
+	NameMagicKernelSharp2013 = "mks2013"
+	// NameMagicKernelSharp2021 is the registry name of Magic Kernel Sharp 2021
+	NameMagicKernelSharp2021 = "mks2021"
+	// NameHermite is the registry name of the Hermite cubic
+	NameHermite = "hermite"
+	// NameSpline36 is the registry name of the Spline36 kernel
+	NameSpline36 = "spline36"
+
+	// maxKernelNameLength keeps registry keys short and printable
+	maxKernelNameLength = 32
+)
+
+var (
+	ErrUnknownKernel = errors.New("unknown kernel")
+	ErrKernelExists  = errors.New("kernel already registered")
+	ErrInvalidKernel = errors.New("invalid kernel")
+)
+
+// Kernel is a separable resampling filter evaluated in source pixel units
+type Kernel interface {
+	// Weight returns the filter response at distance x from the sample
+	Weight(x float64) float64
+	// Support returns the radius beyond which Weight is always zero
+	Support() float64
+}
+
+// funcKernel adapts a plain weight function to the Kernel interface
+type funcKernel struct {
+	weight  func(float64) float64
+	support float64
+}
+
+// Weight implements Kernel
+func (k funcKernel) Weight(x float64) float64 {
+	return k.weight(x)
+}
+
+// Support implements Kernel
+func (k funcKernel) Support() float64 {
+	return k.support
+}
+
+// NewKernel wraps a weight function and its support radius as a Kernel
+func NewKernel(weight func(float64) float64, support float64) Kernel {
+	return funcKernel{weight: weight, support: support}
+}
+
+// GaussianKernel is a Gaussian filter with an adjustable width
+type GaussianKernel struct {
+	Sigma float64
+}
+
+// Weight implements Kernel
+func (k GaussianKernel) Weight(x float64) float64 {
+	return GaussianWeight(x, k.Sigma)
+}
+
+// Support implements Kernel
+func (k GaussianKernel) Support() float64 {
+	return GaussianSupport(k.Sigma)
+}
+
+// kernelRegistry maps filter names to kernels, safe for concurrent use
+var kernelRegistry = struct {
+	sync.RWMutex
+	kernels map[string]Kernel
+}{kernels: make(map[string]Kernel)}
+
+func init() {
+	builtins := map[string]Kernel{
+		NameBicubic:              NewKernel(CubicWeight, CubicSupport),
+		NameBilinear:             NewKernel(LinearWeight, LinearSupport),
+		NameCatmullRom:           NewKernel(CatmullRomWeight, CubicSupport),
+		NameGaussian:             GaussianKernel{Sigma: DefaultGaussianSigma},
+		NameMagicKernelSharp2013: NewKernel(MagicKernelSharp2013Weight, MagicKernelSharp2013Support),
+		NameMagicKernelSharp2021: NewKernel(MagicKernelSharp2021Weight, MagicKernelSharp2021Support),
+		NameHermite:              NewKernel(HermiteWeight, HermiteSupport),
+		NameSpline36:             NewKernel(Spline36Weight, Spline36Support),
+		NameBox:                  NewKernel(BoxWeight, BoxSupport),
+		NameLanczos3:             NewKernel(Lanczos3Weight, Lanczos3Support),
+	}
+
+	for name, k := range builtins {
+		if err := Register(name, k); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds a kernel under name so the resizer can select it
+func Register(name string, k Kernel) error {
+	// Assertion 1: Validate name
+	if name == "" || len(name) > maxKernelNameLength {
+		return fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidKernel, maxKernelNameLength)
+	}
+
+	// Assertion 2: Validate kernel
+	if k == nil {
+		return fmt.Errorf("%w: %s is nil", ErrInvalidKernel, name)
+	}
+
+	support := k.Support()
+	if support <= 0.0 || math.IsNaN(support) || math.IsInf(support, 0) {
+		return fmt.Errorf("%w: %s has invalid support", ErrInvalidKernel, name)
+	}
+
+	kernelRegistry.Lock()
+	defer kernelRegistry.Unlock()
+
+	// Assertion 3: Refuse to silently replace an existing kernel
+	if _, exists := kernelRegistry.kernels[name]; exists {
+		return fmt.Errorf("%w: %s", ErrKernelExists, name)
+	}
+
+	kernelRegistry.kernels[name] = k
+	return nil
+}
+
+// Lookup returns the kernel registered under name
+func Lookup(name string) (Kernel, error) {
+	kernelRegistry.RLock()
+	defer kernelRegistry.RUnlock()
+
+	k, ok := kernelRegistry.kernels[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKernel, name)
+	}
+
+	return k, nil
+}
+
+// Names returns the sorted names of all registered kernels
+func Names() []string {
+	kernelRegistry.RLock()
+	defer kernelRegistry.RUnlock()
+
+	names := make([]string, 0, len(kernelRegistry.kernels))
+	for name := range kernelRegistry.kernels {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// InterpolateKernel applies any registered kernel to a square pixel grid
+// of KernelTaps(k.Support()) pixels per axis
+// Weights are normalized so non-interpolating kernels keep their brightness
+func InterpolateKernel(pixels [][]float64, dx, dy float64, k Kernel) (float64, error) {
+	// Assertion 1: Validate the grid matches the kernel footprint
+	if k == nil || len(pixels) != KernelTaps(k.Support()) {
+		return 0.0, ErrInvalidKernel
+	}
+
+	return interpolateGrid(pixels, dx, dy, k.Weight)
+}
@@ -0,0 +1,37 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+const (
+	// NameLanczos3 is the registry name of the 3-lobe Lanczos kernel
+	NameLanczos3 = "lanczos3"
+	// Lanczos3Support is the radius of the Lanczos3 kernel (6 taps)
+	Lanczos3Support = 3.0
+)
+
+// sinc calculates the normalized sinc function sin(pi x) / (pi x)
+func sinc(x float64) float64 {
+	// Assertion 1: Avoid division by zero at the origin
+	if x == 0.0 {
+		return 1.0
+	}
+
+	px := math.Pi * x
+	return math.Sin(px) / px
+}
+
+// Lanczos3Weight calculates the Lanczos weight with three lobes
+func Lanczos3Weight(x float64) float64 {
+	// Assertion 1: Ensure x is positive for calculation
+	x = math.Abs(x)
+
+	// Assertion 2: Truncate outside the support
+	if x >= Lanczos3Support {
+		return 0.0
+	}
+
+	return sinc(x) * sinc(x/Lanczos3Support)
+}
@@ -0,0 +1,65 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+const (
+	// MagicKernelSharp2013Support is the radius of the 2013 kernel
+	MagicKernelSharp2013Support = 2.5
+	// MagicKernelSharp2021Support is the radius of the 2021 kernel
+	MagicKernelSharp2021Support = 4.5
+)
+
+// MagicKernelSharp2013Weight calculates the Magic Kernel Sharp 2013 weight
+// This is the Magic Kernel convolved with the [-1/4, 3/2, -1/4] sharpener
+func MagicKernelSharp2013Weight(x float64) float64 {
+	// Assertion 1: Ensure x is positive for calculation
+	x = math.Abs(x)
+
+	// Assertion 2: Check bounds for piecewise function
+	if x <= 0.5 {
+		return 17.0/16.0 - 7.0/4.0*x*x
+	}
+
+	if x <= 1.5 {
+		return (1.0 - x) * (7.0/4.0 - x)
+	}
+
+	if x <= 2.5 {
+		return -1.0 / 8.0 * (x - 2.5) * (x - 2.5)
+	}
+
+	return 0.0
+}
+
+// MagicKernelSharp2021Weight calculates the Magic Kernel Sharp 2021 weight
+// The wider sharpening step removes the residual blur of the 2013 variant
+func MagicKernelSharp2021Weight(x float64) float64 {
+	// Assertion 1: Ensure x is positive for calculation
+	x = math.Abs(x)
+
+	// Assertion 2: Check bounds for piecewise function
+	if x <= 0.5 {
+		return 577.0/576.0 - 239.0/144.0*x*x
+	}
+
+	if x <= 1.5 {
+		return (140.0*x*x - 379.0*x + 239.0) / 144.0
+	}
+
+	if x <= 2.5 {
+		return -(24.0*x*x - 113.0*x + 130.0) / 144.0
+	}
+
+	if x <= 3.5 {
+		return (4.0*x*x - 27.0*x + 45.0) / 144.0
+	}
+
+	if x <= 4.5 {
+		return -(4.0*x*x - 36.0*x + 81.0) / 1152.0
+	}
+
+	return 0.0
+}
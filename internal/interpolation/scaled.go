@@ -0,0 +1,83 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrInvalidScale  = errors.New("invalid kernel scale")
+	ErrWeightBuffer  = errors.New("weight buffer too small")
+	ErrZeroWeightSum = errors.New("kernel weights sum to zero")
+)
+
+// ScaledTaps returns the number of source pixels a kernel of the given
+// radius can touch, used to size weight buffers ahead of sampling
+func ScaledTaps(radius float64) int {
+	return int(math.Ceil(2.0*radius)) + 1
+}
+
+// CalculateScaledBounds determines the source range [start, end) covered by
+// a kernel of the given radius centred on center, in pixel index units
+// where an edge pixel's outer border lies half a pixel outside; indices
+// exactly radius away are included, as the box kernel weighs them half
+func CalculateScaledBounds(center, radius float64, maxBound int) (int, int, error) {
+	// Assertion 1: Validate center coordinate
+	if center < -pixelCenter || center >= float64(maxBound)-pixelCenter {
+		return 0, 0, ErrInvalidCoordinate
+	}
+
+	// Assertion 2: Validate radius
+	if radius <= 0.0 || math.IsNaN(radius) || math.IsInf(radius, 0) {
+		return 0, 0, ErrInvalidScale
+	}
+
+	start := int(math.Ceil(center - radius))
+	end := int(math.Floor(center+radius)) + 1
+
+	// A kernel narrower than a pixel still takes the nearest one
+	if end <= start {
+		start = int(math.Round(center))
+		end = start + 1
+	}
+
+	// Assertion 3: Ensure the range fits a weight buffer
+	if end-start > ScaledTaps(radius) {
+		return 0, 0, ErrInvalidCoordinate
+	}
+
+	return start, end, nil
+}
+
+// FillWeights writes normalized kernel weights for source indices
+// start..start+len(dst)-1 into dst, stretching the kernel by scale
+func FillWeights(dst []float64, center, scale float64, start int, weight func(float64) float64) error {
+	// Assertion 1: Validate scale so the kernel is never narrowed
+	if scale < 1.0 || math.IsNaN(scale) || math.IsInf(scale, 0) {
+		return ErrInvalidScale
+	}
+
+	// Assertion 2: Validate buffer
+	if len(dst) == 0 {
+		return ErrWeightBuffer
+	}
+
+	var sum float64
+	for i := 0; i < len(dst); i++ {
+		w := weight((float64(start+i) - center) / scale)
+		dst[i] = w
+		sum += w
+	}
+
+	// Assertion 3: Ensure weights can be normalized
+	if sum == 0.0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return ErrZeroWeightSum
+	}
+
+	for i := 0; i < len(dst); i++ {
+		dst[i] /= sum
+	}
+
+	return nil
+}
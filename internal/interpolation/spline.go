@@ -0,0 +1,50 @@
+// Open source image resizer coded by kasuraSH
+package interpolation
+
+import (
+	"math"
+)
+
+const (
+	// HermiteB and HermiteC are the Hermite cubic parameters
+	HermiteB = 0.0
+	HermiteC = 0.0
+	// HermiteSupport is the radius of the Hermite kernel
+	HermiteSupport = 1.0
+	// Spline36Support is the radius of the Spline36 kernel (6 taps)
+	Spline36Support = 3.0
+)
+
+// HermiteWeight calculates the Hermite cubic weight (B=0, C=0)
+// Its support is a single pixel so it fits the 4x4 grid
+func HermiteWeight(x float64) float64 {
+	return BCCubicWeight(x, HermiteB, HermiteC)
+}
+
+// InterpolateHermite performs Hermite interpolation on a 4x4 pixel grid
+func InterpolateHermite(pixels [][]float64, dx, dy float64) (float64, error) {
+	return interpolateGrid(pixels, dx, dy, HermiteWeight)
+}
+
+// Spline36Weight calculates the Spline36 weight as used by AviSynth and ffmpeg
+func Spline36Weight(x float64) float64 {
+	// Assertion 1: Ensure x is positive for calculation
+	x = math.Abs(x)
+
+	// Assertion 2: Check bounds for piecewise function
+	if x < 1.0 {
+		return ((13.0/11.0*x-453.0/209.0)*x-3.0/209.0)*x + 1.0
+	}
+
+	if x < 2.0 {
+		x -= 1.0
+		return ((-6.0/11.0*x+270.0/209.0)*x - 156.0/209.0) * x
+	}
+
+	if x < 3.0 {
+		x -= 2.0
+		return ((1.0/11.0*x-45.0/209.0)*x + 26.0/209.0) * x
+	}
+
+	return 0.0
+}
@@ -0,0 +1,71 @@
+// Open source image resizer coded by kasuraSH
+package opencl
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnavailable = errors.New("GPU acceleration unavailable")
+	ErrOpenCL      = errors.New("OpenCL failed")
+)
+
+// Axis lists the taps of every output index along one axis: output i
+// reads Count[i] source indices from Index starting at First[i], weighted
+// by the matching entries of Weight; indices are already resolved against
+// the edge policy
+type Axis struct {
+	First  []int32
+	Count  []int32
+	Index  []int32
+	Weight []float32
+}
+
+// Convolution is a separable resampling job; Src holds four samples per
+// source pixel, rows first
+type Convolution struct {
+	Src       []float32
+	SrcWidth  int
+	SrcHeight int
+	X         Axis
+	Y         Axis
+}
+
+// validate checks that every tap stays inside the source and the tables
+func (c Convolution) validate() error {
+	// Assertion 1: The source holds four samples per pixel
+	if c.SrcWidth <= 0 || c.SrcHeight <= 0 || len(c.Src) != 4*c.SrcWidth*c.SrcHeight {
+		return fmt.Errorf("%w: source of %d samples for %dx%d", ErrOpenCL, len(c.Src), c.SrcWidth, c.SrcHeight)
+	}
+
+	if err := c.X.validate(c.SrcWidth); err != nil {
+		return err
+	}
+
+	return c.Y.validate(c.SrcHeight)
+}
+
+// validate checks the tables of one axis against a source of size samples
+func (a Axis) validate(size int) error {
+	// Assertion 1: The tables line up and hold taps
+	if len(a.First) == 0 || len(a.First) != len(a.Count) || len(a.Index) == 0 || len(a.Index) != len(a.Weight) {
+		return fmt.Errorf("%w: mismatched tap tables", ErrOpenCL)
+	}
+
+	for i := range a.First {
+		first, count := int(a.First[i]), int(a.Count[i])
+		if first < 0 || count < 0 || first+count > len(a.Index) {
+			return fmt.Errorf("%w: taps of output %d outside the table", ErrOpenCL, i)
+		}
+	}
+
+	// Assertion 2: Every tap reads a source index
+	for _, index := range a.Index {
+		if index < 0 || int(index) >= size {
+			return fmt.Errorf("%w: tap index %d outside 0-%d", ErrOpenCL, index, size-1)
+		}
+	}
+
+	return nil
+}
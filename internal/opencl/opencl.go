@@ -0,0 +1,247 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build opencl && cgo
+
+package opencl
+
+/*
+#cgo darwin LDFLAGS: -framework OpenCL
+#cgo !darwin LDFLAGS: -lOpenCL
+#define CL_TARGET_OPENCL_VERSION 120
+#define CL_USE_DEPRECATED_OPENCL_1_2_APIS
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+
+// One work item per output sample: horizontal filters every source row
+// into target columns, vertical filters those rows into target rows
+static const char *gr_source =
+	"__kernel void horizontal(__global const float4 *src, int srcWidth,\n"
+	"		__global const int *first, __global const int *count,\n"
+	"		__global const int *index, __global const float *weight,\n"
+	"		__global float4 *rows, int dstWidth) {\n"
+	"	int dx = get_global_id(0);\n"
+	"	int sy = get_global_id(1);\n"
+	"	__global const float4 *row = src + (size_t)sy * srcWidth;\n"
+	"	float4 acc = (float4)(0.0f);\n"
+	"	for (int i = first[dx], end = first[dx] + count[dx]; i < end; i++) {\n"
+	"		acc += weight[i] * row[index[i]];\n"
+	"	}\n"
+	"	rows[(size_t)sy * dstWidth + dx] = acc;\n"
+	"}\n"
+	"__kernel void vertical(__global const float4 *rows, int dstWidth,\n"
+	"		__global const int *first, __global const int *count,\n"
+	"		__global const int *index, __global const float *weight,\n"
+	"		__global float4 *dst) {\n"
+	"	int dx = get_global_id(0);\n"
+	"	int dy = get_global_id(1);\n"
+	"	float4 acc = (float4)(0.0f);\n"
+	"	for (int j = first[dy], end = first[dy] + count[dy]; j < end; j++) {\n"
+	"		acc += weight[j] * rows[(size_t)index[j] * dstWidth + dx];\n"
+	"	}\n"
+	"	dst[(size_t)dy * dstWidth + dx] = acc;\n"
+	"}\n";
+
+static cl_context gr_context;
+static cl_command_queue gr_queue;
+static cl_kernel gr_horizontal;
+static cl_kernel gr_vertical;
+static char gr_device[256];
+
+// gr_device_name exposes the device name, cgo cannot link static data
+static const char *gr_device_name(void) {
+	return gr_device;
+}
+
+// gr_init builds the kernels on the first GPU of any platform
+static cl_int gr_init(void) {
+	cl_platform_id platforms[16];
+	cl_uint count = 0;
+	cl_int err = clGetPlatformIDs(16, platforms, &count);
+	if (err != CL_SUCCESS) {
+		return err;
+	}
+
+	cl_device_id device = NULL;
+	for (cl_uint i = 0; i < count && i < 16 && device == NULL; i++) {
+		if (clGetDeviceIDs(platforms[i], CL_DEVICE_TYPE_GPU, 1, &device, NULL) != CL_SUCCESS) {
+			device = NULL;
+		}
+	}
+	if (device == NULL) {
+		return CL_DEVICE_NOT_FOUND;
+	}
+	clGetDeviceInfo(device, CL_DEVICE_NAME, sizeof(gr_device) - 1, gr_device, NULL);
+
+	gr_context = clCreateContext(NULL, 1, &device, NULL, NULL, &err);
+	if (err != CL_SUCCESS) {
+		return err;
+	}
+
+	gr_queue = clCreateCommandQueue(gr_context, device, 0, &err);
+	if (err != CL_SUCCESS) {
+		return err;
+	}
+
+	cl_program program = clCreateProgramWithSource(gr_context, 1, &gr_source, NULL, &err);
+	if (err != CL_SUCCESS) {
+		return err;
+	}
+
+	err = clBuildProgram(program, 1, &device, NULL, NULL, NULL);
+	if (err != CL_SUCCESS) {
+		return err;
+	}
+
+	gr_horizontal = clCreateKernel(program, "horizontal", &err);
+	if (err != CL_SUCCESS) {
+		return err;
+	}
+
+	gr_vertical = clCreateKernel(program, "vertical", &err);
+	return err;
+}
+
+// gr_input copies size bytes of host memory into a new device buffer
+static cl_mem gr_input(const void *data, size_t size, cl_int *err) {
+	if (*err != CL_SUCCESS) {
+		return NULL;
+	}
+
+	return clCreateBuffer(gr_context, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR, size, (void *)data, err);
+}
+
+// gr_run filters src through both passes into out within one call, so
+// OpenCL keeps no pointer to Go memory once it returns
+static cl_int gr_run(const float *src, int srcWidth, int srcHeight,
+		const int *xFirst, const int *xCount, const int *xIndex, const float *xWeight, int dstWidth, int xTaps,
+		const int *yFirst, const int *yCount, const int *yIndex, const float *yWeight, int dstHeight, int yTaps,
+		float *out) {
+	cl_int err = CL_SUCCESS;
+	size_t rowsSize = sizeof(cl_float4) * (size_t)dstWidth * srcHeight;
+	size_t outSize = sizeof(cl_float4) * (size_t)dstWidth * dstHeight;
+
+	cl_mem mem[11];
+	mem[0] = gr_input(src, sizeof(cl_float4) * (size_t)srcWidth * srcHeight, &err);
+	mem[1] = gr_input(xFirst, sizeof(int) * dstWidth, &err);
+	mem[2] = gr_input(xCount, sizeof(int) * dstWidth, &err);
+	mem[3] = gr_input(xIndex, sizeof(int) * xTaps, &err);
+	mem[4] = gr_input(xWeight, sizeof(float) * xTaps, &err);
+	mem[5] = gr_input(yFirst, sizeof(int) * dstHeight, &err);
+	mem[6] = gr_input(yCount, sizeof(int) * dstHeight, &err);
+	mem[7] = gr_input(yIndex, sizeof(int) * yTaps, &err);
+	mem[8] = gr_input(yWeight, sizeof(float) * yTaps, &err);
+	mem[9] = err == CL_SUCCESS ? clCreateBuffer(gr_context, CL_MEM_READ_WRITE, rowsSize, NULL, &err) : NULL;
+	mem[10] = err == CL_SUCCESS ? clCreateBuffer(gr_context, CL_MEM_WRITE_ONLY, outSize, NULL, &err) : NULL;
+
+	if (err == CL_SUCCESS) {
+		err |= clSetKernelArg(gr_horizontal, 0, sizeof(cl_mem), &mem[0]);
+		err |= clSetKernelArg(gr_horizontal, 1, sizeof(int), &srcWidth);
+		err |= clSetKernelArg(gr_horizontal, 2, sizeof(cl_mem), &mem[1]);
+		err |= clSetKernelArg(gr_horizontal, 3, sizeof(cl_mem), &mem[2]);
+		err |= clSetKernelArg(gr_horizontal, 4, sizeof(cl_mem), &mem[3]);
+		err |= clSetKernelArg(gr_horizontal, 5, sizeof(cl_mem), &mem[4]);
+		err |= clSetKernelArg(gr_horizontal, 6, sizeof(cl_mem), &mem[9]);
+		err |= clSetKernelArg(gr_horizontal, 7, sizeof(int), &dstWidth);
+
+		err |= clSetKernelArg(gr_vertical, 0, sizeof(cl_mem), &mem[9]);
+		err |= clSetKernelArg(gr_vertical, 1, sizeof(int), &dstWidth);
+		err |= clSetKernelArg(gr_vertical, 2, sizeof(cl_mem), &mem[5]);
+		err |= clSetKernelArg(gr_vertical, 3, sizeof(cl_mem), &mem[6]);
+		err |= clSetKernelArg(gr_vertical, 4, sizeof(cl_mem), &mem[7]);
+		err |= clSetKernelArg(gr_vertical, 5, sizeof(cl_mem), &mem[8]);
+		err |= clSetKernelArg(gr_vertical, 6, sizeof(cl_mem), &mem[10]);
+	}
+
+	// The queue is in order, so the vertical pass sees the finished rows
+	if (err == CL_SUCCESS) {
+		size_t global[2] = {(size_t)dstWidth, (size_t)srcHeight};
+		err = clEnqueueNDRangeKernel(gr_queue, gr_horizontal, 2, NULL, global, NULL, 0, NULL, NULL);
+	}
+	if (err == CL_SUCCESS) {
+		size_t global[2] = {(size_t)dstWidth, (size_t)dstHeight};
+		err = clEnqueueNDRangeKernel(gr_queue, gr_vertical, 2, NULL, global, NULL, 0, NULL, NULL);
+	}
+	if (err == CL_SUCCESS) {
+		err = clEnqueueReadBuffer(gr_queue, mem[10], CL_TRUE, 0, outSize, out, 0, NULL, NULL);
+	}
+
+	for (int i = 0; i < 11; i++) {
+		if (mem[i] != NULL) {
+			clReleaseMemObject(mem[i]);
+		}
+	}
+
+	return err;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// start picks the device and builds the kernels once per process
+var start = sync.OnceValue(func() error {
+	if code := C.gr_init(); code != C.CL_SUCCESS {
+		return fmt.Errorf("%w: no usable OpenCL GPU (error %d)", ErrUnavailable, int(code))
+	}
+
+	return nil
+})
+
+// mu serializes jobs, which share the kernel arguments
+var mu sync.Mutex
+
+// Available reports whether a GPU can run convolutions, starting OpenCL
+// on the first call
+func Available() error {
+	return start()
+}
+
+// Device names the GPU in use, empty without one
+func Device() string {
+	if start() != nil {
+		return ""
+	}
+
+	return C.GoString(C.gr_device_name())
+}
+
+// Run filters job on the GPU and returns four samples per target pixel,
+// len(job.X.First) pixels wide and len(job.Y.First) high
+// Sums are in float32, so values match the CPU to within rounding
+func Run(job Convolution) ([]float32, error) {
+	if err := Available(); err != nil {
+		return nil, err
+	}
+
+	if err := job.validate(); err != nil {
+		return nil, err
+	}
+
+	dstWidth, dstHeight := len(job.X.First), len(job.Y.First)
+	out := make([]float32, 4*dstWidth*dstHeight)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	code := C.gr_run((*C.float)(&job.Src[0]), C.int(job.SrcWidth), C.int(job.SrcHeight),
+		ints(job.X.First), ints(job.X.Count), ints(job.X.Index), (*C.float)(&job.X.Weight[0]), C.int(dstWidth), C.int(len(job.X.Index)),
+		ints(job.Y.First), ints(job.Y.Count), ints(job.Y.Index), (*C.float)(&job.Y.Weight[0]), C.int(dstHeight), C.int(len(job.Y.Index)),
+		(*C.float)(&out[0]))
+	if code != C.CL_SUCCESS {
+		return nil, fmt.Errorf("%w: error %d", ErrOpenCL, int(code))
+	}
+
+	return out, nil
+}
+
+// ints passes an int32 table as the 32-bit ints of the kernels
+func ints(s []int32) *C.int {
+	return (*C.int)(unsafe.Pointer(&s[0]))
+}
@@ -0,0 +1,23 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !opencl || !cgo
+
+package opencl
+
+import "fmt"
+
+// Available reports why there is no GPU: the binary was built without
+// the opencl tag
+func Available() error {
+	return fmt.Errorf("%w: built without the opencl tag", ErrUnavailable)
+}
+
+// Device names the GPU in use, empty without one
+func Device() string {
+	return ""
+}
+
+// Run fails without GPU support
+func Run(Convolution) ([]float32, error) {
+	return nil, Available()
+}
@@ -0,0 +1,64 @@
+// Open source image resizer coded by kasuraSH
+package pyramid
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// dziTemplate is the Deep Zoom descriptor written next to the tiles
+const dziTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="%s" Overlap="%d" TileSize="%d">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`
+
+// writeDZI writes out.dzi and out_files/<level>/<col>_<row>.<format>, where
+// level 0 is the single pixel image and the last level is full size
+func writeDZI(levels []image.Image, out string, cfg Config) error {
+	full := levels[0].Bounds()
+	dir := out + "_files"
+	top := len(levels) - 1
+
+	for i, level := range levels {
+		levelDir := filepath.Join(dir, fmt.Sprint(top-i))
+		if err := writeDZILevel(level, levelDir, cfg); err != nil {
+			return err
+		}
+	}
+
+	descriptor := fmt.Sprintf(dziTemplate, cfg.Format, cfg.Overlap, cfg.TileSize, full.Dx(), full.Dy())
+
+	// Assertion 1: Check descriptor write
+	if err := os.WriteFile(out+".dzi", []byte(descriptor), 0644); err != nil {
+		return fmt.Errorf("failed to write descriptor: %w", err)
+	}
+
+	return nil
+}
+
+// writeDZILevel cuts one level into tiles that extend by the overlap
+// into each neighbour
+func writeDZILevel(level image.Image, dir string, cfg Config) error {
+	bounds := level.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	ts, overlap := cfg.TileSize, cfg.Overlap
+
+	for row := 0; row*ts < height; row++ {
+		for col := 0; col*ts < width; col++ {
+			rect := image.Rect(
+				max(0, col*ts-overlap), max(0, row*ts-overlap),
+				min(width, (col+1)*ts+overlap), min(height, (row+1)*ts+overlap),
+			)
+
+			path := filepath.Join(dir, fmt.Sprintf("%d_%d.%s", col, row, cfg.Format))
+			if err := saveTile(level, rect, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
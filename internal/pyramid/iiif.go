@@ -0,0 +1,119 @@
+// Open source image resizer coded by kasuraSH
+package pyramid
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+)
+
+// iiifInfo is the subset of a IIIF Image API 3.0 info.json needed by
+// viewers to address a static level 0 tile tree
+type iiifInfo struct {
+	Context  string     `json:"@context"`
+	ID       string     `json:"id"`
+	Type     string     `json:"type"`
+	Protocol string     `json:"protocol"`
+	Profile  string     `json:"profile"`
+	Width    int        `json:"width"`
+	Height   int        `json:"height"`
+	Tiles    []iiifTile `json:"tiles"`
+	Sizes    []iiifSize `json:"sizes"`
+}
+
+type iiifTile struct {
+	Width        int   `json:"width"`
+	ScaleFactors []int `json:"scaleFactors"`
+}
+
+type iiifSize struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// writeIIIF writes info.json and <region>/<w>,<h>/0/default.<format> tiles
+// for every scale factor whose tiles do not exceed the whole image
+func writeIIIF(levels []image.Image, out string, cfg Config) error {
+	full := levels[0].Bounds()
+	width, height := full.Dx(), full.Dy()
+
+	info := iiifInfo{
+		Context:  "http://iiif.io/api/image/3/context.json",
+		ID:       cfg.BaseURL,
+		Type:     "ImageService3",
+		Protocol: "http://iiif.io/api/image",
+		Profile:  "level0",
+		Width:    width,
+		Height:   height,
+	}
+
+	tile := iiifTile{Width: cfg.TileSize}
+
+	for i, level := range levels {
+		scale := 1 << i
+		tile.ScaleFactors = append(tile.ScaleFactors, scale)
+		info.Sizes = append(info.Sizes, iiifSize{Width: level.Bounds().Dx(), Height: level.Bounds().Dy()})
+
+		if err := writeIIIFLevel(level, scale, width, height, out, cfg); err != nil {
+			return err
+		}
+
+		// Assertion 1: Stop once a single tile covers the whole image
+		if cfg.TileSize*scale >= max(width, height) {
+			break
+		}
+	}
+
+	info.Tiles = []iiifTile{tile}
+
+	// Sizes are listed smallest first
+	for i, j := 0, len(info.Sizes)-1; i < j; i, j = i+1, j-1 {
+		info.Sizes[i], info.Sizes[j] = info.Sizes[j], info.Sizes[i]
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode info.json: %w", err)
+	}
+
+	// Assertion 2: Check descriptor write
+	if err := os.WriteFile(filepath.Join(out, "info.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write info.json: %w", err)
+	}
+
+	return nil
+}
+
+// writeIIIFLevel writes the tiles of one scale factor, addressed by their
+// region in full resolution coordinates
+func writeIIIFLevel(level image.Image, scale, width, height int, out string, cfg Config) error {
+	span := cfg.TileSize * scale
+	bounds := level.Bounds()
+
+	for y := 0; y < height; y += span {
+		for x := 0; x < width; x += span {
+			regionWidth := min(span, width-x)
+			regionHeight := min(span, height-y)
+
+			// Tile position and size at this scale, kept inside the level
+			rect := image.Rect(x/scale, y/scale,
+				min(bounds.Dx(), (x+regionWidth+scale-1)/scale),
+				min(bounds.Dy(), (y+regionHeight+scale-1)/scale))
+
+			path := filepath.Join(out,
+				fmt.Sprintf("%d,%d,%d,%d", x, y, regionWidth, regionHeight),
+				fmt.Sprintf("%d,%d", rect.Dx(), rect.Dy()),
+				"0", "default."+cfg.Format)
+
+			if err := saveTile(level, rect, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The full image at this scale, requested by viewers as thumbnails
+	path := filepath.Join(out, "full", fmt.Sprintf("%d,%d", bounds.Dx(), bounds.Dy()), "0", "default."+cfg.Format)
+	return saveTile(level, image.Rect(0, 0, bounds.Dx(), bounds.Dy()), path)
+}
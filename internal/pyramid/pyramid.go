@@ -0,0 +1,166 @@
+// Open source image resizer coded by kasuraSH
+package pyramid
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/kasurarykerion/golangresizer/internal/resizer"
+	"github.com/kasurarykerion/golangresizer/internal/transform"
+	"github.com/kasurarykerion/golangresizer/pkg/imageio"
+)
+
+// Layout names a tile pyramid directory structure
+type Layout string
+
+const (
+	// LayoutDZI writes a Deep Zoom .dzi descriptor and _files directory
+	LayoutDZI Layout = "dzi"
+	// LayoutIIIF writes a static IIIF Image API 3.0 level 0 tree
+	LayoutIIIF Layout = "iiif"
+
+	// DefaultTileSize is the edge length of a tile without overlap
+	DefaultTileSize = 256
+	// MaxTileSize bounds tiles to sizes viewers handle well
+	MaxTileSize = 4096
+	// maxLevels bounds the pyramid depth, 2^17 exceeds any valid dimension
+	maxLevels = 17
+)
+
+var (
+	ErrUnknownLayout = errors.New("unknown pyramid layout")
+	ErrInvalidTiling = errors.New("invalid tiling parameters")
+	ErrNilImage      = errors.New("nil image provided")
+)
+
+// ParseLayout converts a layout name into a Layout value
+func ParseLayout(name string) (Layout, error) {
+	switch Layout(name) {
+	case LayoutDZI, LayoutIIIF:
+		return Layout(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownLayout, name)
+	}
+}
+
+// Config holds pyramid generation parameters
+type Config struct {
+	Layout   Layout         // Directory structure, defaults to DZI
+	TileSize int            // Tile edge in pixels, defaults to 256
+	Overlap  int            // Pixels shared with neighbouring tiles, DZI only
+	Format   string         // Tile file extension such as jpg or png, defaults to jpg
+	Filter   resizer.Filter // Kernel used to build each level, defaults to bicubic
+	BaseURL  string         // IIIF service id written to info.json
+}
+
+// validate fills defaults and checks the tiling parameters
+func (c *Config) validate() error {
+	// Assertion 1: Apply defaults
+	if c.Layout == "" {
+		c.Layout = LayoutDZI
+	}
+
+	if c.TileSize == 0 {
+		c.TileSize = DefaultTileSize
+	}
+
+	if c.Format == "" {
+		c.Format = "jpg"
+	}
+
+	if c.Filter == "" {
+		c.Filter = resizer.FilterBicubic
+	}
+
+	// Assertion 2: Validate values
+	if _, err := ParseLayout(string(c.Layout)); err != nil {
+		return err
+	}
+
+	if c.TileSize < 1 || c.TileSize > MaxTileSize {
+		return fmt.Errorf("%w: tile size must be 1-%d", ErrInvalidTiling, MaxTileSize)
+	}
+
+	if c.Overlap < 0 || c.Overlap >= c.TileSize {
+		return fmt.Errorf("%w: overlap must be 0-%d", ErrInvalidTiling, c.TileSize-1)
+	}
+
+	if c.Layout == LayoutIIIF && c.Overlap != 0 {
+		return fmt.Errorf("%w: IIIF tiles cannot overlap", ErrInvalidTiling)
+	}
+
+	if _, err := imageio.GetImageFormat("tile." + c.Format); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTiling, err)
+	}
+
+	return nil
+}
+
+// Generate writes the tile pyramid of src to out, a base path for DZI
+// (out.dzi and out_files) or a directory for IIIF
+func Generate(src image.Image, out string, cfg Config) error {
+	// Assertion 1: Validate inputs
+	if src == nil {
+		return ErrNilImage
+	}
+
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	levels, err := buildLevels(src, cfg.Filter)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Layout == LayoutIIIF {
+		return writeIIIF(levels, out, cfg)
+	}
+
+	return writeDZI(levels, out, cfg)
+}
+
+// buildLevels returns src followed by successive halvings, rounding odd
+// sizes up, down to a single pixel
+func buildLevels(src image.Image, filter resizer.Filter) ([]image.Image, error) {
+	levels := []image.Image{src}
+	current := src
+
+	for i := 0; i < maxLevels; i++ {
+		bounds := current.Bounds()
+
+		// Assertion 1: Stop at the single pixel level
+		if bounds.Dx() == 1 && bounds.Dy() == 1 {
+			return levels, nil
+		}
+
+		r, err := resizer.NewResizer(resizer.Config{
+			TargetWidth:  (bounds.Dx() + 1) / 2,
+			TargetHeight: (bounds.Dy() + 1) / 2,
+			Filter:       filter,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		current, err = r.Resize(current)
+		if err != nil {
+			return nil, fmt.Errorf("level %d: %w", i+1, err)
+		}
+
+		levels = append(levels, current)
+	}
+
+	return nil, fmt.Errorf("%w: image too large for %d levels", ErrInvalidTiling, maxLevels)
+}
+
+// saveTile crops rect from level and writes it to path
+func saveTile(level image.Image, rect image.Rectangle, path string) error {
+	tile, err := transform.Crop(level, rect)
+	if err != nil {
+		return err
+	}
+
+	return imageio.SaveImage(path, tile)
+}
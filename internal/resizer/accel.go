@@ -0,0 +1,235 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+	"github.com/kasuraSH/kasurarykerion/internal/opencl"
+)
+
+// Accel selects the hardware running the convolution step
+type Accel string
+
+const (
+	// AccelCPU resamples on the CPU (default)
+	AccelCPU Accel = "cpu"
+	// AccelGPU runs the separable convolution on an OpenCL GPU; it is
+	// experimental and needs a binary built with the opencl tag
+	AccelGPU Accel = "gpu"
+)
+
+var ErrUnknownAccel = errors.New("unknown accelerator")
+
+// ParseAccel converts an accelerator name into an Accel value
+func ParseAccel(name string) (Accel, error) {
+	switch Accel(name) {
+	case AccelCPU, AccelGPU:
+		return Accel(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownAccel, name)
+	}
+}
+
+// AccelDevice names the GPU -accel gpu runs on, empty when there is none
+func AccelDevice() string {
+	return opencl.Device()
+}
+
+// validateAccel checks the accelerator and, for the GPU, that one is
+// usable, so a missing device fails before any image is read
+func validateAccel(accel Accel) error {
+	if _, err := ParseAccel(string(accel)); err != nil {
+		return err
+	}
+
+	if accel == AccelGPU {
+		return opencl.Available()
+	}
+
+	return nil
+}
+
+// resizeAccelerated resizes src on the GPU when it is selected and the
+// configuration is a plain separable convolution, reporting false so the
+// CPU resampler runs otherwise
+// The GPU sums in float32, so results can differ from the CPU by one
+// level
+func (r *Resizer) resizeAccelerated(src image.Image, srcWidth, srcHeight int) (image.Image, bool, error) {
+	cfg := r.config
+
+	// Assertion 1: Only the table sampler has a GPU kernel
+	if cfg.Accel != AccelGPU || cfg.EWA || cfg.AntiRinging || cfg.FixedPoint || cfg.Edge == interpolation.EdgeConstant {
+		return nil, false, nil
+	}
+
+	table, err := r.newTableSampler(srcWidth, srcHeight)
+	if err != nil {
+		return nil, true, err
+	}
+
+	// Float and 16-bit outputs keep the full range, 8-bit ones drop to it
+	var shift uint
+	switch src.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, hdr.ColorModel:
+	default:
+		shift = 8
+	}
+
+	job := opencl.Convolution{
+		Src:       make([]float32, 4*srcWidth*srcHeight),
+		SrcWidth:  srcWidth,
+		SrcHeight: srcHeight,
+		X:         accelAxis(table.xContribs, srcWidth, cfg.Edge),
+		Y:         accelAxis(table.yContribs, srcHeight, cfg.Edge),
+	}
+
+	// JPEG pixels are filtered as Y, Cb and Cr like the planar CPU path
+	ycc, planar := r.planarYCbCr(src)
+	r.quiet().forEachRow(srcHeight, func(y int) {
+		row := job.Src[4*y*srcWidth : 4*(y+1)*srcWidth]
+		for x := 0; x < srcWidth; x++ {
+			if planar {
+				yi := ycc.YOffset(r.origin.X+x, r.origin.Y+y)
+				ci := ycc.COffset(r.origin.X+x, r.origin.Y+y)
+				row[4*x], row[4*x+1], row[4*x+2] = float32(ycc.Y[yi]), float32(ycc.Cb[ci]), float32(ycc.Cr[ci])
+				continue
+			}
+
+			px := table.edge.pixel(src, x, y, shift)
+			for c := 0; c < len(px); c++ {
+				row[4*x+c] = float32(px[c])
+			}
+		}
+	})
+
+	out, err := opencl.Run(job)
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrResizeFailed, err)
+	}
+
+	if planar {
+		return r.storeYCbCr(out), true, nil
+	}
+
+	return r.storeAccelerated(src.ColorModel(), out), true, nil
+}
+
+// accelAxis flattens contribution tables into the tap tables of the GPU,
+// resolving every tap against the edge policy up front
+func accelAxis(contribs []interpolation.Contribution, size int, mode interpolation.EdgeMode) opencl.Axis {
+	axis := opencl.Axis{
+		First: make([]int32, len(contribs)),
+		Count: make([]int32, len(contribs)),
+	}
+
+	for i, c := range contribs {
+		axis.First[i] = int32(len(axis.Index))
+		axis.Count[i] = int32(len(c.Weights))
+
+		for j, w := range c.Weights {
+			index, _ := interpolation.ResolveIndex(c.Start+j, size, mode)
+			axis.Index = append(axis.Index, int32(index))
+			axis.Weight = append(axis.Weight, float32(w))
+		}
+	}
+
+	return axis
+}
+
+// storeYCbCr converts filtered Y, Cb and Cr samples to RGBA
+func (r *Resizer) storeYCbCr(out []float32) *image.RGBA {
+	width := r.config.TargetWidth
+	dst := newRGBA(image.Rect(0, 0, width, r.config.TargetHeight))
+
+	r.forEachRow(r.config.TargetHeight, func(y int) {
+		for x := 0; x < width; x++ {
+			i := 4 * (y*width + x)
+			dst.SetRGBA(x, y, ycbcrToRGBA(float64(out[i]), float64(out[i+1])-128, float64(out[i+2])-128))
+		}
+	})
+
+	return dst
+}
+
+// storeAccelerated writes four samples per target pixel into the image
+// the CPU resampler returns for a source of the given color model
+func (r *Resizer) storeAccelerated(model color.Model, out []float32) image.Image {
+	rect := image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight)
+	width := r.config.TargetWidth
+
+	sample := func(x, y int) [4]float64 {
+		i := 4 * (y*width + x)
+		return [4]float64{float64(out[i]), float64(out[i+1]), float64(out[i+2]), float64(out[i+3])}
+	}
+
+	switch model {
+	case color.RGBA64Model, color.NRGBA64Model:
+		dst := newRGBA64(rect)
+		r.forEachRow(rect.Dy(), func(y int) {
+			for x := 0; x < width; x++ {
+				v := sample(x, y)
+				dst.SetRGBA64(x, y, color.RGBA64{
+					R: interpolation.ClampUint16(v[0]),
+					G: interpolation.ClampUint16(v[1]),
+					B: interpolation.ClampUint16(v[2]),
+					A: interpolation.ClampUint16(v[3]),
+				})
+			}
+		})
+		return dst
+
+	case color.GrayModel:
+		dst := newGray(rect)
+		r.forEachRow(rect.Dy(), func(y int) {
+			for x := 0; x < width; x++ {
+				dst.SetGray(x, y, color.Gray{Y: interpolation.ClampUint8(sample(x, y)[0])})
+			}
+		})
+		return dst
+
+	case color.Gray16Model:
+		dst := newGray16(rect)
+		r.forEachRow(rect.Dy(), func(y int) {
+			for x := 0; x < width; x++ {
+				dst.SetGray16(x, y, color.Gray16{Y: interpolation.ClampUint16(sample(x, y)[0])})
+			}
+		})
+		return dst
+
+	case hdr.ColorModel:
+		dst := newFloat(rect)
+		r.forEachRow(rect.Dy(), func(y int) {
+			for x := 0; x < width; x++ {
+				v := sample(x, y)
+				dst.SetFloat(x, y, hdr.Color{
+					R: float32(v[0] / 0xFFFF),
+					G: float32(v[1] / 0xFFFF),
+					B: float32(v[2] / 0xFFFF),
+					A: float32(math.Min(math.Max(v[3]/0xFFFF, 0), 1)),
+				})
+			}
+		})
+		return dst
+
+	default:
+		dst := newRGBA(rect)
+		r.forEachRow(rect.Dy(), func(y int) {
+			for x := 0; x < width; x++ {
+				v := sample(x, y)
+				dst.SetRGBA(x, y, color.RGBA{
+					R: interpolation.ClampUint8(v[0]),
+					G: interpolation.ClampUint8(v[1]),
+					B: interpolation.ClampUint8(v[2]),
+					A: interpolation.ClampUint8(v[3]),
+				})
+			}
+		})
+		return dst
+	}
+}
@@ -0,0 +1,77 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+// The convolution inner loops, dot and addScaled, and their float32
+// counterparts have AVX2 and NEON versions; every version sums in the
+// same order as the Go one below so the output does not depend on the
+// machine
+// Products are rounded before they are added, which keeps the compiler
+// from fusing them into FMA instructions on some architectures
+
+// dotGeneric returns the dot product of w and v[:len(w)], accumulating
+// blocks of four in four lanes combined as (s0+s1)+(s2+s3), then adding
+// the remaining elements in order
+func dotGeneric(w, v []float64) float64 {
+	v = v[:len(w)]
+	n := len(w) &^ 3
+
+	var s [4]float64
+	for i := 0; i < n; i += 4 {
+		s[0] += float64(w[i] * v[i])
+		s[1] += float64(w[i+1] * v[i+1])
+		s[2] += float64(w[i+2] * v[i+2])
+		s[3] += float64(w[i+3] * v[i+3])
+	}
+
+	sum := (s[0] + s[1]) + (s[2] + s[3])
+	for i := n; i < len(w); i++ {
+		sum += float64(w[i] * v[i])
+	}
+
+	return sum
+}
+
+// addScaledGeneric adds w times src[:len(dst)] to dst
+func addScaledGeneric(dst, src []float64, w float64) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] += float64(w * src[i])
+	}
+}
+
+// dot32Generic is dotGeneric for float32 samples, accumulating blocks of
+// eight in eight lanes and a remaining block of four in the first four,
+// combined as ((s0+s1)+(s2+s3))+((s4+s5)+(s6+s7))
+func dot32Generic(w, v []float32) float32 {
+	v = v[:len(w)]
+	n := len(w) &^ 7
+
+	var s [8]float32
+	for i := 0; i < n; i += 8 {
+		for k := 0; k < len(s); k++ {
+			s[k] += float32(w[i+k] * v[i+k])
+		}
+	}
+
+	if len(w)-n >= 4 {
+		for k := 0; k < 4; k++ {
+			s[k] += float32(w[n+k] * v[n+k])
+		}
+		n += 4
+	}
+
+	sum := ((s[0] + s[1]) + (s[2] + s[3])) + ((s[4] + s[5]) + (s[6] + s[7]))
+	for i := n; i < len(w); i++ {
+		sum += float32(w[i] * v[i])
+	}
+
+	return sum
+}
+
+// addScaled32Generic is addScaledGeneric for float32 samples
+func addScaled32Generic(dst, src []float32, w float32) {
+	src = src[:len(dst)]
+	for i := range dst {
+		dst[i] += float32(w * src[i])
+	}
+}
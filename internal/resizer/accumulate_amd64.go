@@ -0,0 +1,68 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !purego
+
+package resizer
+
+// hasAVX2 reports whether the CPU and OS support the AVX2 kernels
+var hasAVX2 = cpuHasAVX2()
+
+//go:noescape
+func dotAVX2(w, v []float64) float64
+
+//go:noescape
+func addScaledAVX2(dst, src []float64, w float64)
+
+//go:noescape
+func dot32AVX2(w, v []float32) float32
+
+//go:noescape
+func addScaled32AVX2(dst, src []float32, w float32)
+
+func cpuHasAVX2() bool
+
+// dot returns the dot product of w and v[:len(w)]
+func dot(w, v []float64) float64 {
+	// Assertion 1: Both kernels read len(w) elements of v
+	v = v[:len(w)]
+	if hasAVX2 {
+		return dotAVX2(w, v)
+	}
+
+	return dotGeneric(w, v)
+}
+
+// addScaled adds w times src[:len(dst)] to dst
+func addScaled(dst, src []float64, w float64) {
+	// Assertion 1: Both kernels read len(dst) elements of src
+	src = src[:len(dst)]
+	if hasAVX2 {
+		addScaledAVX2(dst, src, w)
+		return
+	}
+
+	addScaledGeneric(dst, src, w)
+}
+
+// dot32 returns the dot product of w and v[:len(w)]
+func dot32(w, v []float32) float32 {
+	// Assertion 1: Both kernels read len(w) elements of v
+	v = v[:len(w)]
+	if hasAVX2 {
+		return dot32AVX2(w, v)
+	}
+
+	return dot32Generic(w, v)
+}
+
+// addScaled32 adds w times src[:len(dst)] to dst
+func addScaled32(dst, src []float32, w float32) {
+	// Assertion 1: Both kernels read len(dst) elements of src
+	src = src[:len(dst)]
+	if hasAVX2 {
+		addScaled32AVX2(dst, src, w)
+		return
+	}
+
+	addScaled32Generic(dst, src, w)
+}
@@ -0,0 +1,225 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !purego
+
+#include "textflag.h"
+
+// func cpuHasAVX2() bool
+TEXT ·cpuHasAVX2(SB), NOSPLIT, $0-1
+	// Leaf 7 must exist
+	XORL AX, AX
+	XORL CX, CX
+	CPUID
+	CMPL AX, $7
+	JLT  no
+
+	// OSXSAVE and AVX, bits 27 and 28 of ECX in leaf 1
+	MOVL $1, AX
+	XORL CX, CX
+	CPUID
+	ANDL $0x18000000, CX
+	CMPL CX, $0x18000000
+	JNE  no
+
+	// The OS saves the XMM and YMM registers
+	XORL CX, CX
+	XGETBV
+	ANDL $6, AX
+	CMPL AX, $6
+	JNE  no
+
+	// AVX2, bit 5 of EBX in leaf 7
+	MOVL $7, AX
+	XORL CX, CX
+	CPUID
+	BTL  $5, BX
+	JCC  no
+
+	MOVB $1, ret+0(FP)
+	RET
+
+no:
+	MOVB $0, ret+0(FP)
+	RET
+
+// func dotAVX2(w, v []float64) float64
+TEXT ·dotAVX2(SB), NOSPLIT, $0-56
+	MOVQ w_base+0(FP), SI
+	MOVQ w_len+8(FP), CX
+	MOVQ v_base+24(FP), DI
+
+	VXORPD Y0, Y0, Y0
+	MOVQ   CX, DX
+	SHRQ   $2, DX
+	JZ     reduce
+
+block:
+	// Lane k of Y0 sums the products of the elements i with i%4 == k
+	VMOVUPD (SI), Y1
+	VMULPD  (DI), Y1, Y1
+	VADDPD  Y1, Y0, Y0
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    DX
+	JNZ     block
+
+reduce:
+	// (s0+s1)+(s2+s3)
+	VEXTRACTF128 $1, Y0, X1
+	VHADDPD      X1, X0, X0
+	VHADDPD      X0, X0, X0
+	VZEROUPPER
+
+	ANDQ $3, CX
+	JZ   done
+
+tail:
+	MOVSD (SI), X1
+	MULSD (DI), X1
+	ADDSD X1, X0
+	ADDQ  $8, SI
+	ADDQ  $8, DI
+	DECQ  CX
+	JNZ   tail
+
+done:
+	MOVSD X0, ret+48(FP)
+	RET
+
+// func addScaledAVX2(dst, src []float64, w float64)
+TEXT ·addScaledAVX2(SB), NOSPLIT, $0-56
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+
+	VBROADCASTSD w+48(FP), Y2
+	MOVQ         CX, DX
+	SHRQ         $2, DX
+	JZ           rest
+
+block:
+	VMULPD  (SI), Y2, Y1
+	VADDPD  (DI), Y1, Y1
+	VMOVUPD Y1, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    DX
+	JNZ     block
+
+rest:
+	VZEROUPPER
+	ANDQ $3, CX
+	JZ   done
+
+tail:
+	MOVSD (SI), X1
+	MULSD X2, X1
+	ADDSD (DI), X1
+	MOVSD X1, (DI)
+	ADDQ  $8, SI
+	ADDQ  $8, DI
+	DECQ  CX
+	JNZ   tail
+
+done:
+	RET
+
+// func dot32AVX2(w, v []float32) float32
+TEXT ·dot32AVX2(SB), NOSPLIT, $0-52
+	MOVQ w_base+0(FP), SI
+	MOVQ w_len+8(FP), CX
+	MOVQ v_base+24(FP), DI
+
+	VXORPS Y0, Y0, Y0
+	MOVQ   CX, DX
+	SHRQ   $3, DX
+	JZ     reduce
+
+block:
+	// Lane k of Y0 sums the products of the elements i with i%8 == k
+	VMOVUPS (SI), Y1
+	VMULPS  (DI), Y1, Y1
+	VADDPS  Y1, Y0, Y0
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    DX
+	JNZ     block
+
+reduce:
+	// X0 holds lanes s0 to s3, X1 lanes s4 to s7
+	VEXTRACTF128 $1, Y0, X1
+
+	// A remaining block of four goes to the first four lanes
+	BTQ     $2, CX
+	JCC     pairs
+	VMOVUPS (SI), X2
+	VMULPS  (DI), X2, X2
+	VADDPS  X2, X0, X0
+	ADDQ    $16, SI
+	ADDQ    $16, DI
+
+pairs:
+	// ((s0+s1)+(s2+s3))+((s4+s5)+(s6+s7)), shuffling the even and odd
+	// lanes apart as VHADDPS would, at a fraction of its latency
+	VSHUFPS   $0x88, X1, X0, X2
+	VSHUFPS   $0xDD, X1, X0, X3
+	VADDPS    X3, X2, X0
+	VMOVSHDUP X0, X1
+	VADDPS    X1, X0, X0
+	VMOVHLPS  X0, X0, X1
+	VADDSS    X1, X0, X0
+	VZEROUPPER
+
+	ANDQ $3, CX
+	JZ   done
+
+tail:
+	MOVSS (SI), X1
+	MULSS (DI), X1
+	ADDSS X1, X0
+	ADDQ  $4, SI
+	ADDQ  $4, DI
+	DECQ  CX
+	JNZ   tail
+
+done:
+	MOVSS X0, ret+48(FP)
+	RET
+
+// func addScaled32AVX2(dst, src []float32, w float32)
+TEXT ·addScaled32AVX2(SB), NOSPLIT, $0-52
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ src_base+24(FP), SI
+
+	VBROADCASTSS w+48(FP), Y2
+	MOVQ         CX, DX
+	SHRQ         $3, DX
+	JZ           rest
+
+block:
+	VMULPS  (SI), Y2, Y1
+	VADDPS  (DI), Y1, Y1
+	VMOVUPS Y1, (DI)
+	ADDQ    $32, SI
+	ADDQ    $32, DI
+	DECQ    DX
+	JNZ     block
+
+rest:
+	VZEROUPPER
+	ANDQ $7, CX
+	JZ   done
+
+tail:
+	MOVSS (SI), X1
+	MULSS X2, X1
+	ADDSS (DI), X1
+	MOVSS X1, (DI)
+	ADDQ  $4, SI
+	ADDQ  $4, DI
+	DECQ  CX
+	JNZ   tail
+
+done:
+	RET
@@ -0,0 +1,43 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !purego
+
+package resizer
+
+// Advanced SIMD is part of every arm64 CPU, so there is no feature check
+
+//go:noescape
+func dotNEON(w, v []float64) float64
+
+//go:noescape
+func addScaledNEON(dst, src []float64, w float64)
+
+//go:noescape
+func dot32NEON(w, v []float32) float32
+
+//go:noescape
+func addScaled32NEON(dst, src []float32, w float32)
+
+// dot returns the dot product of w and v[:len(w)]
+func dot(w, v []float64) float64 {
+	// Assertion 1: The kernel reads len(w) elements of v
+	return dotNEON(w, v[:len(w)])
+}
+
+// addScaled adds w times src[:len(dst)] to dst
+func addScaled(dst, src []float64, w float64) {
+	// Assertion 1: The kernel reads len(dst) elements of src
+	addScaledNEON(dst, src[:len(dst)], w)
+}
+
+// dot32 returns the dot product of w and v[:len(w)]
+func dot32(w, v []float32) float32 {
+	// Assertion 1: The kernel reads len(w) elements of v
+	return dot32NEON(w, v[:len(w)])
+}
+
+// addScaled32 adds w times src[:len(dst)] to dst
+func addScaled32(dst, src []float32, w float32) {
+	// Assertion 1: The kernel reads len(dst) elements of src
+	addScaled32NEON(dst, src[:len(dst)], w)
+}
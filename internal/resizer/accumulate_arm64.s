@@ -0,0 +1,178 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !purego
+
+#include "textflag.h"
+
+// The unfused vector FMUL, FADD and FADDP are written as WORDs, which
+// older assemblers do not know by name
+
+// func dotNEON(w, v []float64) float64
+TEXT ·dotNEON(SB), NOSPLIT, $0-56
+	MOVD w_base+0(FP), R0
+	MOVD w_len+8(FP), R2
+	MOVD v_base+24(FP), R1
+
+	// V0 holds lanes s0 and s1, V1 lanes s2 and s3
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	LSR  $2, R2, R3
+	CBZ  R3, reduce
+
+block:
+	VLD1.P 32(R0), [V2.D2, V3.D2]
+	VLD1.P 32(R1), [V4.D2, V5.D2]
+	WORD   $0x6E64DC42 // FMUL V2.2D, V2.2D, V4.2D
+	WORD   $0x6E65DC63 // FMUL V3.2D, V3.2D, V5.2D
+	WORD   $0x4E62D400 // FADD V0.2D, V0.2D, V2.2D
+	WORD   $0x4E63D421 // FADD V1.2D, V1.2D, V3.2D
+	SUBS   $1, R3, R3
+	BNE    block
+
+reduce:
+	// (s0+s1)+(s2+s3)
+	WORD  $0x7E70D806 // FADDP D6, V0.2D
+	WORD  $0x7E70D827 // FADDP D7, V1.2D
+	FADDD F7, F6, F6
+
+	AND $3, R2, R2
+	CBZ R2, done
+
+tail:
+	FMOVD.P 8(R0), F8
+	FMOVD.P 8(R1), F9
+	FMULD   F9, F8, F8
+	FADDD   F8, F6, F6
+	SUBS    $1, R2, R2
+	BNE     tail
+
+done:
+	FMOVD F6, ret+48(FP)
+	RET
+
+// func addScaledNEON(dst, src []float64, w float64)
+TEXT ·addScaledNEON(SB), NOSPLIT, $0-56
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD src_base+24(FP), R1
+
+	FMOVD w+48(FP), F10
+	VDUP  V10.D[0], V10.D2
+	LSR   $2, R2, R3
+	CBZ   R3, rest
+
+block:
+	VLD1.P 32(R1), [V2.D2, V3.D2]
+	VLD1   (R0), [V4.D2, V5.D2]
+	WORD   $0x6E6ADC42 // FMUL V2.2D, V2.2D, V10.2D
+	WORD   $0x6E6ADC63 // FMUL V3.2D, V3.2D, V10.2D
+	WORD   $0x4E62D484 // FADD V4.2D, V4.2D, V2.2D
+	WORD   $0x4E63D4A5 // FADD V5.2D, V5.2D, V3.2D
+	VST1.P [V4.D2, V5.D2], 32(R0)
+	SUBS   $1, R3, R3
+	BNE    block
+
+rest:
+	AND $3, R2, R2
+	CBZ R2, done
+
+tail:
+	FMOVD.P 8(R1), F8
+	FMOVD   (R0), F9
+	FMULD   F10, F8, F8
+	FADDD   F8, F9, F9
+	FMOVD.P F9, 8(R0)
+	SUBS    $1, R2, R2
+	BNE     tail
+
+done:
+	RET
+
+// func dot32NEON(w, v []float32) float32
+TEXT ·dot32NEON(SB), NOSPLIT, $0-52
+	MOVD w_base+0(FP), R0
+	MOVD w_len+8(FP), R2
+	MOVD v_base+24(FP), R1
+
+	// V0 holds lanes s0 to s3, V1 lanes s4 to s7
+	VEOR V0.B16, V0.B16, V0.B16
+	VEOR V1.B16, V1.B16, V1.B16
+	LSR  $3, R2, R3
+	CBZ  R3, reduce
+
+block:
+	VLD1.P 32(R0), [V2.S4, V3.S4]
+	VLD1.P 32(R1), [V4.S4, V5.S4]
+	WORD   $0x6E24DC42 // FMUL V2.4S, V2.4S, V4.4S
+	WORD   $0x6E25DC63 // FMUL V3.4S, V3.4S, V5.4S
+	WORD   $0x4E22D400 // FADD V0.4S, V0.4S, V2.4S
+	WORD   $0x4E23D421 // FADD V1.4S, V1.4S, V3.4S
+	SUBS   $1, R3, R3
+	BNE    block
+
+reduce:
+	// A remaining block of four goes to the first four lanes
+	TBZ    $2, R2, pairs
+	VLD1.P 16(R0), [V2.S4]
+	VLD1.P 16(R1), [V4.S4]
+	WORD   $0x6E24DC42 // FMUL V2.4S, V2.4S, V4.4S
+	WORD   $0x4E22D400 // FADD V0.4S, V0.4S, V2.4S
+
+pairs:
+	// ((s0+s1)+(s2+s3))+((s4+s5)+(s6+s7))
+	WORD $0x6E21D400 // FADDP V0.4S, V0.4S, V1.4S
+	WORD $0x6E20D400 // FADDP V0.4S, V0.4S, V0.4S
+	WORD $0x7E30D806 // FADDP S6, V0.2S
+
+	AND $3, R2, R2
+	CBZ R2, done
+
+tail:
+	FMOVS.P 4(R0), F8
+	FMOVS.P 4(R1), F9
+	FMULS   F9, F8, F8
+	FADDS   F8, F6, F6
+	SUBS    $1, R2, R2
+	BNE     tail
+
+done:
+	FMOVS F6, ret+48(FP)
+	RET
+
+// func addScaled32NEON(dst, src []float32, w float32)
+TEXT ·addScaled32NEON(SB), NOSPLIT, $0-52
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD src_base+24(FP), R1
+
+	FMOVS w+48(FP), F10
+	VDUP  V10.S[0], V10.S4
+	LSR   $3, R2, R3
+	CBZ   R3, rest
+
+block:
+	VLD1.P 32(R1), [V2.S4, V3.S4]
+	VLD1   (R0), [V4.S4, V5.S4]
+	WORD   $0x6E2ADC42 // FMUL V2.4S, V2.4S, V10.4S
+	WORD   $0x6E2ADC63 // FMUL V3.4S, V3.4S, V10.4S
+	WORD   $0x4E22D484 // FADD V4.4S, V4.4S, V2.4S
+	WORD   $0x4E23D4A5 // FADD V5.4S, V5.4S, V3.4S
+	VST1.P [V4.S4, V5.S4], 32(R0)
+	SUBS   $1, R3, R3
+	BNE    block
+
+rest:
+	AND $7, R2, R2
+	CBZ R2, done
+
+tail:
+	FMOVS.P 4(R1), F8
+	FMOVS   (R0), F9
+	FMULS   F10, F8, F8
+	FADDS   F8, F9, F9
+	FMOVS.P F9, 4(R0)
+	SUBS    $1, R2, R2
+	BNE     tail
+
+done:
+	RET
@@ -0,0 +1,25 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build (!amd64 && !arm64) || purego
+
+package resizer
+
+// dot returns the dot product of w and v[:len(w)]
+func dot(w, v []float64) float64 {
+	return dotGeneric(w, v)
+}
+
+// addScaled adds w times src[:len(dst)] to dst
+func addScaled(dst, src []float64, w float64) {
+	addScaledGeneric(dst, src, w)
+}
+
+// dot32 returns the dot product of w and v[:len(w)]
+func dot32(w, v []float32) float32 {
+	return dot32Generic(w, v)
+}
+
+// addScaled32 adds w times src[:len(dst)] to dst
+func addScaled32(dst, src []float32, w float32) {
+	addScaled32Generic(dst, src, w)
+}
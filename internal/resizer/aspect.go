@@ -0,0 +1,94 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+var ErrInvalidAspect = errors.New("invalid aspect ratio")
+
+// ParseAspect converts "16:9" or a decimal such as "1.5" into a
+// width/height ratio
+func ParseAspect(s string) (float64, error) {
+	var ratio float64
+
+	if w, h, ok := strings.Cut(s, ":"); ok {
+		fw, errW := strconv.ParseFloat(w, 64)
+		fh, errH := strconv.ParseFloat(h, 64)
+
+		// Assertion 1: Validate both terms
+		if errW != nil || errH != nil || fh <= 0.0 {
+			return 0.0, fmt.Errorf("%w: %q", ErrInvalidAspect, s)
+		}
+
+		ratio = fw / fh
+	} else {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0.0, fmt.Errorf("%w: %q", ErrInvalidAspect, s)
+		}
+
+		ratio = v
+	}
+
+	// Assertion 2: Validate the ratio itself
+	if err := validateAspect(ratio); err != nil {
+		return 0.0, err
+	}
+
+	return ratio, nil
+}
+
+// validateAspect checks that a ratio can describe an image within the
+// dimension limits
+func validateAspect(ratio float64) error {
+	limit := float64(validator.MaxImageDimension)
+
+	if math.IsNaN(ratio) || ratio < 1.0/limit || ratio > limit {
+		return fmt.Errorf("%w: %g", ErrInvalidAspect, ratio)
+	}
+
+	return nil
+}
+
+// RatioDimensions fills in a zero width or height from ratio instead of
+// the source aspect; with both zero it returns the largest box of that
+// ratio inside the source, so fill mode crops without scaling
+func RatioDimensions(srcWidth, srcHeight, width, height int, ratio float64) (int, int) {
+	limit := validator.MaxImageDimension
+
+	// Assertion 1: Derive the missing dimension from the ratio
+	if width > 0 && height == 0 {
+		return width, scaledDimension(width, 1.0/ratio, limit)
+	}
+
+	if height > 0 && width == 0 {
+		return scaledDimension(height, ratio, limit), height
+	}
+
+	// Assertion 2: Without a dimension use the largest box in the source
+	if width == 0 && height == 0 {
+		if float64(srcWidth)/float64(srcHeight) > ratio {
+			return scaledDimension(srcHeight, ratio, srcWidth), srcHeight
+		}
+
+		return srcWidth, scaledDimension(srcWidth, 1.0/ratio, srcHeight)
+	}
+
+	return width, height
+}
+
+// ratioEdgeDimensions sizes a box of ratio whose longest side is edge
+func ratioEdgeDimensions(edge int, ratio float64) (int, int) {
+	if ratio >= 1.0 {
+		return RatioDimensions(0, 0, edge, 0, ratio)
+	}
+
+	return RatioDimensions(0, 0, 0, edge, ratio)
+}
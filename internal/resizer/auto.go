@@ -0,0 +1,64 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+const (
+	// FilterAuto picks a kernel from the scale ratio at resize time
+	FilterAuto Filter = "auto"
+	// FilterBox averages the covered source area when downscaling
+	FilterBox Filter = interpolation.NameBox
+
+	// autoStrongShrink is the shrink factor from which area averaging wins
+	autoStrongShrink = 3.0
+)
+
+// AutoFilter returns the filter the auto mode uses for the given resize
+// Strong downscales use box, mild downscales Mitchell, upscales Catmull-Rom
+func AutoFilter(srcWidth, srcHeight, dstWidth, dstHeight int) Filter {
+	// Assertion 1: Guard against invalid dimensions
+	if srcWidth <= 0 || srcHeight <= 0 || dstWidth <= 0 || dstHeight <= 0 {
+		return FilterBicubic
+	}
+
+	xRatio := float64(srcWidth) / float64(dstWidth)
+	yRatio := float64(srcHeight) / float64(dstHeight)
+
+	shrink := xRatio
+	if yRatio > shrink {
+		shrink = yRatio
+	}
+
+	// Assertion 2: Pick the kernel by the strongest shrink factor
+	if shrink >= autoStrongShrink {
+		return FilterBox
+	}
+
+	if shrink > 1.0 {
+		return FilterBicubic
+	}
+
+	return FilterCatmullRom
+}
+
+// forSource returns a resizer whose kernel suits src, leaving r untouched
+// so one Resizer can safely serve images of different sizes
+func (r *Resizer) forSource(srcWidth, srcHeight int) (*Resizer, error) {
+	// Assertion 1: Only the auto mode needs a per-image kernel
+	if r.config.Filter != FilterAuto {
+		return r, nil
+	}
+
+	filter := AutoFilter(srcWidth, srcHeight, r.config.TargetWidth, r.config.TargetHeight)
+	kernel, err := interpolation.Lookup(string(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	active := *r
+	active.kernel = kernel
+	active.filter = filter
+	return &active, nil
+}
@@ -0,0 +1,136 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+)
+
+// benchModels are the source layouts measured, each taking its own path
+// through the resizer
+var benchModels = []string{"rgba", "nrgba", "rgba64", "gray", "gray16", "ycbcr", "cmyk", "paletted", "float"}
+
+// benchScales cover strong and mild reductions and an enlargement
+var benchScales = []float64{0.25, 0.5, 0.75, 2}
+
+// BenchmarkResize times every color model, filter and scale on a
+// 1024x768 source, named model/filter/xscale so -bench can pick a subset
+// and benchstat can compare two runs
+func BenchmarkResize(b *testing.B) {
+	for _, model := range benchModels {
+		src := benchSource(b, model, 1024, 768)
+
+		for _, filter := range FilterNames() {
+			for _, scale := range benchScales {
+				b.Run(fmt.Sprintf("%s/%s/x%g", model, filter, scale), func(b *testing.B) {
+					benchResize(b, src, Filter(filter), scale)
+				})
+			}
+		}
+	}
+}
+
+// benchResize measures resizing src by scale with filter, reporting
+// source megapixels per second alongside the time and allocations
+func benchResize(b *testing.B, src image.Image, filter Filter, scale float64) {
+	bounds := src.Bounds()
+	r, err := NewResizer(Config{
+		TargetWidth:  max(1, int(math.Round(float64(bounds.Dx())*scale))),
+		TargetHeight: max(1, int(math.Round(float64(bounds.Dy())*scale))),
+		Filter:       filter,
+	})
+	if err != nil {
+		b.Fatalf("NewResizer: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dst, err := r.Resize(src)
+		if err != nil {
+			b.Fatalf("Resize: %v", err)
+		}
+		Release(dst)
+	}
+
+	megapixels := float64(bounds.Dx()*bounds.Dy()) * float64(b.N) / 1e6
+	b.ReportMetric(megapixels/b.Elapsed().Seconds(), "MP/s")
+}
+
+// benchSource returns a width x height image in the named color model
+// holding gradients, noise and partial transparency, so no path can take
+// a shortcut for flat content
+func benchSource(b *testing.B, model string, width, height int) image.Image {
+	b.Helper()
+
+	rect := image.Rect(0, 0, width, height)
+
+	var img draw.Image
+	switch model {
+	case "rgba":
+		img = image.NewRGBA(rect)
+	case "nrgba":
+		img = image.NewNRGBA(rect)
+	case "rgba64":
+		img = image.NewRGBA64(rect)
+	case "gray":
+		img = image.NewGray(rect)
+	case "gray16":
+		img = image.NewGray16(rect)
+	case "cmyk":
+		img = image.NewCMYK(rect)
+	case "paletted":
+		img = image.NewPaletted(rect, palette.Plan9)
+	case "float":
+		img = hdr.NewRGBA(rect)
+	case "ycbcr":
+		return benchYCbCr(rect)
+	default:
+		b.Fatalf("unknown model %q", model)
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, benchColor(x, y))
+		}
+	}
+
+	return img
+}
+
+// benchYCbCr returns a 4:2:0 image like a decoded JPEG
+func benchYCbCr(rect image.Rectangle) *image.YCbCr {
+	img := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := benchColor(x, y)
+			luma, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			img.Y[img.YOffset(x, y)] = luma
+			img.Cb[img.COffset(x, y)] = cb
+			img.Cr[img.COffset(x, y)] = cr
+		}
+	}
+
+	return img
+}
+
+// benchColor is the test pattern at x, y
+func benchColor(x, y int) color.NRGBA {
+	noise := uint8((x*73856093 ^ y*19349663) >> 4 & 0x1f)
+
+	return color.NRGBA{
+		R: uint8(x) + noise,
+		G: uint8(y) + noise,
+		B: uint8(x+y) ^ noise,
+		A: 0xff - uint8((x+y)>>3)&0x3f,
+	}
+}
@@ -0,0 +1,19 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"image/draw"
+)
+
+// toRGBA converts a YCbCr or CMYK source to RGBA once, keeping its bounds,
+// so the filter taps read stored pixels instead of converting each sample
+// again; CMYK uses the uncalibrated conversion of image/color, sources
+// with an ICC profile are converted when decoded
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := newRGBA(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+
+	return dst
+}
@@ -0,0 +1,62 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// ResizeContext resizes src like Resize, giving up when ctx is done: the
+// rows not yet computed are skipped and the partial result is dropped,
+// so a canceled resize returns ctx.Err() wrapped in ErrResizeFailed
+func (r *Resizer) ResizeContext(ctx context.Context, src image.Image) (image.Image, error) {
+	// Assertion 1: A done context does no work
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrResizeFailed, err)
+	}
+
+	dst, err := r.withContext(ctx).Resize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: Rows skipped after the cancel leave dst incomplete
+	if err := ctx.Err(); err != nil {
+		if dst != src {
+			Release(dst)
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrResizeFailed, err)
+	}
+
+	return dst, nil
+}
+
+// withContext returns a resizer whose row loops stop once ctx is done,
+// leaving r untouched
+func (r *Resizer) withContext(ctx context.Context) *Resizer {
+	active := *r
+	active.ctx = ctx
+	return &active
+}
+
+// done returns the channel closed when the context of a ResizeContext
+// call ends; it is nil, and never closes, for Resize
+func (r *Resizer) done() <-chan struct{} {
+	if r.ctx == nil {
+		return nil
+	}
+
+	return r.ctx.Done()
+}
+
+// closed reports whether done has been closed, without waiting
+func closed(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
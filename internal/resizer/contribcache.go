@@ -0,0 +1,134 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"sync"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+const (
+	// maxCachedTables bounds the number of axis tables kept
+	maxCachedTables = 64
+	// maxCachedWeights bounds the weights kept across all tables, 32 MiB
+	maxCachedWeights = 4 << 20
+)
+
+// contribKey identifies the weight table of one axis; registered kernels
+// cannot be replaced, so the filter name and sigma identify the kernel
+type contribKey struct {
+	filter      Filter
+	sigma       float64
+	srcSize     int
+	regionStart float64
+	regionSize  float64
+	dstSize     int
+}
+
+// contribCache keeps recently used weight tables, so a batch of images
+// sharing source and target sizes, such as a camera dump, computes them
+// once instead of once per image; the tables are never written after
+// they are built, so every resize may share them
+type contribCache struct {
+	mu      sync.Mutex
+	tables  map[contribKey][]interpolation.Contribution
+	order   []contribKey // Least recently used first
+	weights int          // Weights held by all tables
+}
+
+var contribTables = contribCache{tables: make(map[contribKey][]interpolation.Contribution)}
+
+// axisContributions returns the weight table resampling the given span of
+// a srcSize pixel axis to dstSize pixels with the resizer's kernel
+func (r *Resizer) axisContributions(srcSize int, regionStart, regionSize float64, dstSize int) ([]interpolation.Contribution, error) {
+	// Assertion 1: Kernels not known by name are computed every time
+	if r.filter == "" {
+		return interpolation.ComputeRegionContributions(srcSize, regionStart, regionSize, dstSize, r.kernel)
+	}
+
+	key := contribKey{
+		filter:      r.filter,
+		sigma:       r.config.Sigma,
+		srcSize:     srcSize,
+		regionStart: regionStart,
+		regionSize:  regionSize,
+		dstSize:     dstSize,
+	}
+
+	if contribs, ok := contribTables.get(key); ok {
+		return contribs, nil
+	}
+
+	contribs, err := interpolation.ComputeRegionContributions(srcSize, regionStart, regionSize, dstSize, r.kernel)
+	if err != nil {
+		return nil, err
+	}
+
+	contribTables.put(key, contribs)
+	return contribs, nil
+}
+
+// get returns the table for key, marking it as the most recently used
+func (c *contribCache) get(key contribKey) ([]interpolation.Contribution, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	contribs, ok := c.tables[key]
+	if ok {
+		c.touch(key)
+	}
+
+	return contribs, ok
+}
+
+// put stores a table, dropping the least recently used ones until the
+// cache is back within its bounds
+func (c *contribCache) put(key contribKey, contribs []interpolation.Contribution) {
+	size := tableWeights(contribs)
+
+	// Assertion 1: A table larger than the whole cache is not kept
+	if size > maxCachedWeights {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another resize may have built the same table meanwhile
+	if _, ok := c.tables[key]; ok {
+		c.touch(key)
+		return
+	}
+
+	for len(c.order) > 0 && (len(c.order) >= maxCachedTables || c.weights+size > maxCachedWeights) {
+		oldest := c.order[0]
+		c.weights -= tableWeights(c.tables[oldest])
+		delete(c.tables, oldest)
+		c.order = c.order[1:]
+	}
+
+	c.tables[key] = contribs
+	c.order = append(c.order, key)
+	c.weights += size
+}
+
+// touch moves key to the most recently used end of the order
+func (c *contribCache) touch(key contribKey) {
+	for i, k := range c.order {
+		if k == key {
+			copy(c.order[i:], c.order[i+1:])
+			c.order[len(c.order)-1] = key
+			return
+		}
+	}
+}
+
+// tableWeights returns the number of weights a table holds; all of them
+// share one backing array, which the first entry starts
+func tableWeights(contribs []interpolation.Contribution) int {
+	if len(contribs) == 0 {
+		return 0
+	}
+
+	return cap(contribs[0].Weights)
+}
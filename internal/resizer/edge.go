@@ -0,0 +1,94 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// ParseEdgeMode converts an edge policy name into an EdgeMode value
+func ParseEdgeMode(name string) (interpolation.EdgeMode, error) {
+	return interpolation.ParseEdgeMode(name)
+}
+
+// edgeReader fetches source pixels, resolving out-of-range coordinates
+// with the configured edge policy
+type edgeReader struct {
+	mode   interpolation.EdgeMode
+	origin image.Point
+	width  int
+	height int
+	fill   [4]uint32
+}
+
+// newEdgeReader builds a reader for a source of the given size
+func (r *Resizer) newEdgeReader(width, height int) edgeReader {
+	e := edgeReader{mode: r.config.Edge, origin: r.origin, width: width, height: height}
+
+	if r.config.EdgeColor != nil {
+		e.fill[0], e.fill[1], e.fill[2], e.fill[3] = r.config.EdgeColor.RGBA()
+	}
+
+	return e
+}
+
+// at returns the 16-bit premultiplied channels of the pixel at (x, y),
+// given relative to the top-left corner of the source bounds
+func (e edgeReader) at(src image.Image, x, y int) (uint32, uint32, uint32, uint32) {
+	safeX, okX := interpolation.ResolveIndex(x, e.width, e.mode)
+	safeY, okY := interpolation.ResolveIndex(y, e.height, e.mode)
+
+	// Assertion 1: Samples outside in constant mode use the fill color
+	if !okX || !okY {
+		return e.fill[0], e.fill[1], e.fill[2], e.fill[3]
+	}
+
+	return rgbaAt(src, e.origin.X+safeX, e.origin.Y+safeY)
+}
+
+// pixel returns the channels of the pixel at (x, y) as at does, each
+// right-shifted by shift, except that float sources keep values beyond
+// white on the same scale
+func (e edgeReader) pixel(src image.Image, x, y int, shift uint) [4]float64 {
+	f, ok := src.(*hdr.RGBA)
+
+	// Assertion 1: Integer sources and constant fills go through at
+	if !ok || !e.inside(x, y) {
+		r32, g32, b32, a32 := e.at(src, x, y)
+		return [4]float64{float64(r32 >> shift), float64(g32 >> shift), float64(b32 >> shift), float64(a32 >> shift)}
+	}
+
+	safeX, _ := interpolation.ResolveIndex(x, e.width, e.mode)
+	safeY, _ := interpolation.ResolveIndex(y, e.height, e.mode)
+	c := f.FloatAt(e.origin.X+safeX, e.origin.Y+safeY)
+	scale := float64(uint32(0xFFFF) >> shift)
+
+	return [4]float64{float64(c.R) * scale, float64(c.G) * scale, float64(c.B) * scale, float64(c.A) * scale}
+}
+
+// inside reports whether (x, y) resolves to a source pixel rather than
+// the constant fill
+func (e edgeReader) inside(x, y int) bool {
+	_, okX := interpolation.ResolveIndex(x, e.width, e.mode)
+	_, okY := interpolation.ResolveIndex(y, e.height, e.mode)
+	return okX && okY
+}
+
+// withOrigin returns a resizer reading sources whose bounds start at
+// origin, leaving r untouched
+func (r *Resizer) withOrigin(origin image.Point) *Resizer {
+	// Assertion 1: Avoid a copy when the origin is unchanged
+	if origin == r.origin {
+		return r
+	}
+
+	active := *r
+	active.origin = origin
+	return &active
+}
+
+// defaultEdgeColor is used by the constant edge mode when none is set
+var defaultEdgeColor color.Color = color.Transparent
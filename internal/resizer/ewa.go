@@ -0,0 +1,124 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// ewaSampler filters with a radial kernel over an elliptical footprint,
+// avoiding the axis-aligned artifacts of separable filtering when the x and
+// y scale factors differ strongly
+type ewaSampler struct {
+	kernel      interpolation.Kernel
+	ellipse     interpolation.Ellipse
+	extentX     float64
+	extentY     float64
+	region      sourceRegion
+	xRatio      float64
+	yRatio      float64
+	width       int
+	height      int
+	edge        edgeReader
+	antiRinging bool
+}
+
+// newEWASampler builds the footprint for an axis-aligned scale; the general
+// Jacobian form of the ellipse leaves room for rotated mappings
+func (r *Resizer) newEWASampler(srcWidth, srcHeight int) (*ewaSampler, error) {
+	region := r.regionFor(srcWidth, srcHeight)
+	xRatio := region.width / float64(r.config.TargetWidth)
+	yRatio := region.height / float64(r.config.TargetHeight)
+
+	ellipse, err := interpolation.NewEllipse(xRatio, 0.0, 0.0, yRatio)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrResizeFailed, err)
+	}
+
+	extentX, extentY := ellipse.Extent(r.kernel.Support())
+
+	// Assertion 1: The footprint must be finite so sampling stays bounded
+	if !(extentX > 0 && extentY > 0) || math.IsInf(extentX, 0) || math.IsInf(extentY, 0) {
+		return nil, fmt.Errorf("%w: invalid EWA footprint %gx%g", ErrResizeFailed, extentX, extentY)
+	}
+
+	return &ewaSampler{
+		kernel:      r.kernel,
+		ellipse:     ellipse,
+		extentX:     extentX,
+		extentY:     extentY,
+		region:      region,
+		xRatio:      xRatio,
+		yRatio:      yRatio,
+		width:       srcWidth,
+		height:      srcHeight,
+		edge:        r.newEdgeReader(srcWidth, srcHeight),
+		antiRinging: r.config.AntiRinging,
+	}, nil
+}
+
+// sample implements pixelSampler; a footprint that catches no weight,
+// which narrow kernels can leave between source pixel centers, takes the
+// nearest source pixel
+func (s *ewaSampler) sample(src image.Image, dstX, dstY int, shift uint) [4]float64 {
+	// Pixel centers sit half a pixel inside their edges
+	x := s.region.x + (float64(dstX)+0.5)*s.xRatio - 0.5
+	y := s.region.y + (float64(dstY)+0.5)*s.yRatio - 0.5
+
+	var result, lo, hi [4]float64
+	for c := 0; c < len(result); c++ {
+		lo[c] = math.Inf(1)
+		hi[c] = math.Inf(-1)
+	}
+
+	startX, endX := int(math.Floor(x-s.extentX))+1, int(math.Ceil(x+s.extentX))
+	startY, endY := int(math.Floor(y-s.extentY))+1, int(math.Ceil(y+s.extentY))
+
+	support := s.kernel.Support()
+	var sum float64
+
+	for srcY := startY; srcY < endY; srcY++ {
+		offY := float64(srcY) - y
+
+		for srcX := startX; srcX < endX; srcX++ {
+			dist := s.ellipse.Distance(float64(srcX)-x, offY)
+			if dist >= support {
+				continue
+			}
+
+			w := s.kernel.Weight(dist)
+			if w == 0.0 {
+				continue
+			}
+
+			px := s.edge.pixel(src, srcX, srcY, shift)
+
+			for c := 0; c < len(result); c++ {
+				result[c] += px[c] * w
+				lo[c] = math.Min(lo[c], px[c])
+				hi[c] = math.Max(hi[c], px[c])
+			}
+
+			sum += w
+		}
+	}
+
+	// Assertion 1: Fall back when the weights cannot be normalized
+	if sum == 0.0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return s.edge.pixel(src, int(math.Round(x)), int(math.Round(y)), shift)
+	}
+
+	for c := 0; c < len(result); c++ {
+		result[c] /= sum
+
+		// Suppress halos from negative kernel lobes when requested
+		if s.antiRinging {
+			result[c] = interpolation.ClampRange(result[c], lo[c], hi[c])
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,182 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+)
+
+// resizeFast handles the resizes that need no kernel: a pixel aligned
+// region of the target size is copied and an exact 1/2 or 1/4 box
+// reduction averages blocks of samples; ok is false when the general
+// samplers must run
+// The result has the type the general path returns for src
+func (r *Resizer) resizeFast(src image.Image, srcWidth, srcHeight int) (image.Image, bool) {
+	origin, factorX, factorY, ok := r.fastRegion(srcWidth, srcHeight)
+	if !ok {
+		return nil, false
+	}
+
+	origin = origin.Add(src.Bounds().Min)
+
+	// Assertion 1: An identity resize is a copy whatever the kernel
+	if factorX == 1 && factorY == 1 {
+		return r.copyRegion(src, origin), true
+	}
+
+	// Assertion 2: Reductions take the box kernel, which EWA turns round
+	if r.config.EWA || !r.averagesArea(factorX*r.config.TargetWidth, factorY*r.config.TargetHeight) {
+		return nil, false
+	}
+
+	width, height := r.config.TargetWidth, r.config.TargetHeight
+	rect := image.Rect(0, 0, width, height)
+
+	switch s := src.(type) {
+	case *image.RGBA:
+		dst := newRGBA(rect)
+		r.boxAverage(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 4, 1, factorX, factorY)
+		return dst, true
+	case *image.RGBA64:
+		dst := newRGBA64(rect)
+		r.boxAverage(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 4, 2, factorX, factorY)
+		return dst, true
+	case *image.Gray:
+		dst := newGray(rect)
+		r.boxAverage(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 1, 1, factorX, factorY)
+		return dst, true
+	case *image.Gray16:
+		dst := newGray16(rect)
+		r.boxAverage(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 1, 2, factorX, factorY)
+		return dst, true
+	default:
+		return nil, false
+	}
+}
+
+// fastRegion returns the top-left pixel of the source region and the
+// whole shrink factors mapping it onto the target, ok being false unless
+// the region is pixel aligned and both factors are 1, 2 or 4
+func (r *Resizer) fastRegion(srcWidth, srcHeight int) (image.Point, int, int, bool) {
+	region := r.regionFor(srcWidth, srcHeight)
+
+	// Assertion 1: The region must start on a pixel boundary
+	if region.x != math.Trunc(region.x) || region.y != math.Trunc(region.y) {
+		return image.Point{}, 0, 0, false
+	}
+
+	factorX := region.width / float64(r.config.TargetWidth)
+	factorY := region.height / float64(r.config.TargetHeight)
+
+	// Assertion 2: Both axes shrink by a factor with a dedicated loop
+	if !fastFactor(factorX) || !fastFactor(factorY) {
+		return image.Point{}, 0, 0, false
+	}
+
+	return image.Pt(int(region.x), int(region.y)), int(factorX), int(factorY), true
+}
+
+// fastFactor reports whether a shrink factor is 1, 2 or 4
+func fastFactor(factor float64) bool {
+	return factor == 1.0 || factor == 2.0 || factor == 4.0
+}
+
+// averagesArea reports whether the kernel resolved for a region of the
+// given size is the box, which averages the covered area
+func (r *Resizer) averagesArea(regionWidth, regionHeight int) bool {
+	filter := r.config.Filter
+	if filter == FilterAuto {
+		filter = AutoFilter(regionWidth, regionHeight, r.config.TargetWidth, r.config.TargetHeight)
+	}
+
+	return filter == FilterBox
+}
+
+// copyRegion copies the target-sized area of src at origin, rows at a
+// time when the general path would keep the pixel layout
+func (r *Resizer) copyRegion(src image.Image, origin image.Point) image.Image {
+	width, height := r.config.TargetWidth, r.config.TargetHeight
+	rect := image.Rect(0, 0, width, height)
+
+	switch s := src.(type) {
+	case *image.RGBA:
+		dst := newRGBA(rect)
+		copyRows(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 4*width, height)
+		return dst
+	case *image.RGBA64:
+		dst := newRGBA64(rect)
+		copyRows(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 8*width, height)
+		return dst
+	case *image.Gray:
+		dst := newGray(rect)
+		copyRows(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, width, height)
+		return dst
+	case *image.Gray16:
+		dst := newGray16(rect)
+		copyRows(dst.Pix, dst.Stride, s.Pix[s.PixOffset(origin.X, origin.Y):], s.Stride, 2*width, height)
+		return dst
+	case *hdr.RGBA:
+		// Float pixels are copied as is so bright values are not clipped
+		dst := newFloat(rect)
+		offset := s.PixOffset(origin.X, origin.Y)
+		for y := 0; y < height; y++ {
+			copy(dst.Pix[y*dst.Stride:y*dst.Stride+4*width], s.Pix[offset+y*s.Stride:])
+		}
+		return dst
+	default:
+		// Other models convert to the layout their general path writes
+		dst := newImageLike(src, width, height)
+		draw.Draw(dst, rect, src, origin, draw.Src)
+		return dst
+	}
+}
+
+// copyRows copies rows of rowBytes bytes between strided buffers
+func copyRows(dst []uint8, dstStride int, src []uint8, srcStride, rowBytes, rows int) {
+	for y := 0; y < rows; y++ {
+		copy(dst[y*dstStride:y*dstStride+rowBytes], src[y*srcStride:y*srcStride+rowBytes])
+	}
+}
+
+// boxAverage writes the rounded mean of each factorX by factorY block of
+// src samples to dst, for pixels of the given channel count and sample
+// size in bytes, 2 being big-endian as in the standard 16-bit images
+// src starts at the first sample of the region
+func (r *Resizer) boxAverage(dst []uint8, dstStride int, src []uint8, srcStride, channels, size, factorX, factorY int) {
+	count := uint32(factorX * factorY)
+	pixel := channels * size
+
+	r.forEachRow(r.config.TargetHeight, func(y int) {
+		out := dst[y*dstStride:]
+		block := src[y*factorY*srcStride:]
+
+		for x := 0; x < r.config.TargetWidth; x++ {
+			for c := 0; c < channels; c++ {
+				var sum uint32
+
+				for j := 0; j < factorY; j++ {
+					row := block[j*srcStride+x*factorX*pixel+c*size:]
+
+					for i := 0; i < factorX; i++ {
+						if size == 2 {
+							sum += uint32(row[i*pixel])<<8 | uint32(row[i*pixel+1])
+						} else {
+							sum += uint32(row[i*pixel])
+						}
+					}
+				}
+
+				mean := (sum + count/2) / count
+				if size == 2 {
+					out[x*pixel+2*c] = uint8(mean >> 8)
+					out[x*pixel+2*c+1] = uint8(mean)
+				} else {
+					out[x*pixel+c] = uint8(mean)
+				}
+			}
+		}
+	})
+}
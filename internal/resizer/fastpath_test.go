@@ -0,0 +1,85 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// patternRGBA returns an opaque image whose samples vary in both axes
+func patternRGBA(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8((x + y) * 3), A: 255})
+		}
+	}
+
+	return img
+}
+
+// resizeTo resizes src to width by height with filter
+func resizeTo(t *testing.T, src image.Image, width, height int, filter Filter) image.Image {
+	t.Helper()
+
+	r, err := NewResizer(Config{TargetWidth: width, TargetHeight: height, Filter: filter})
+	if err != nil {
+		t.Fatalf("NewResizer: %v", err)
+	}
+
+	out, err := r.Resize(src)
+	if err != nil {
+		t.Fatalf("Resize to %dx%d with %s: %v", width, height, filter, err)
+	}
+
+	if got := out.Bounds().Size(); got != image.Pt(width, height) {
+		t.Fatalf("Resize to %dx%d with %s gave %v", width, height, filter, got)
+	}
+
+	return out
+}
+
+func TestResizeIdentityCopies(t *testing.T) {
+	src := patternRGBA(40, 30)
+	for _, filter := range []Filter{FilterBox, FilterBicubic, FilterLanczos3} {
+		out, ok := resizeTo(t, src, 40, 30, filter).(*image.RGBA)
+		if !ok || !bytes.Equal(out.Pix, src.Pix) {
+			t.Errorf("identity resize with %s changed the pixels", filter)
+		}
+	}
+}
+
+func TestResizeBoxAveragesBlocks(t *testing.T) {
+	src := patternRGBA(40, 32)
+	for _, factor := range []int{2, 4} {
+		out := resizeTo(t, src, 40/factor, 32/factor, FilterBox).(*image.RGBA)
+
+		for y := 0; y < 32/factor; y++ {
+			for x := 0; x < 40/factor; x++ {
+				var sum [4]int
+				for dy := 0; dy < factor; dy++ {
+					for dx := 0; dx < factor; dx++ {
+						c := src.RGBAAt(x*factor+dx, y*factor+dy)
+						sum[0], sum[1], sum[2], sum[3] = sum[0]+int(c.R), sum[1]+int(c.G), sum[2]+int(c.B), sum[3]+int(c.A)
+					}
+				}
+
+				n := factor * factor
+				want := color.RGBA{uint8((sum[0] + n/2) / n), uint8((sum[1] + n/2) / n), uint8((sum[2] + n/2) / n), uint8((sum[3] + n/2) / n)}
+				if got := out.RGBAAt(x, y); got != want {
+					t.Fatalf("1/%d box at %d,%d = %v, want %v", factor, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestResizeBoxUpscales(t *testing.T) {
+	// Sample centres falling on half pixels take both neighbours
+	src := patternRGBA(1002, 750)
+	for _, size := range []image.Point{{1003, 751}, {2004, 1500}, {1500, 1001}} {
+		resizeTo(t, src, size.X, size.Y, FilterBox)
+	}
+}
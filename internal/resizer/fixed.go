@@ -0,0 +1,93 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// fixedSampler is the 16.16 fixed-point variant of tableSampler for 8-bit
+// images, accumulating in integers so no float conversion happens per tap
+type fixedSampler struct {
+	xContribs   []interpolation.FixedContribution
+	yContribs   []interpolation.FixedContribution
+	edge        edgeReader
+	antiRinging bool
+}
+
+// new8BitSampler returns the fixed-point sampler when configured, otherwise
+// the regular sampler for the configured mode
+func (r *Resizer) new8BitSampler(srcWidth, srcHeight int) (pixelSampler, error) {
+	// Assertion 1: EWA has no fixed-point implementation
+	if !r.config.FixedPoint || r.config.EWA {
+		return r.newSampler(srcWidth, srcHeight)
+	}
+
+	table, err := r.newTableSampler(srcWidth, srcHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fixedSampler{
+		xContribs:   interpolation.ToFixedContributions(table.xContribs),
+		yContribs:   interpolation.ToFixedContributions(table.yContribs),
+		edge:        table.edge,
+		antiRinging: r.config.AntiRinging,
+	}, nil
+}
+
+// sample implements pixelSampler; only 8-bit channels fit the 16.16
+// accumulator, so the shift is always 8 and not read
+func (s *fixedSampler) sample(src image.Image, dstX, dstY int, _ uint) [4]float64 {
+	var result [4]float64
+
+	xc := s.xContribs[dstX]
+	yc := s.yContribs[dstY]
+
+	var acc [4]int64
+	lo := [4]int64{interpolation.MaxUint8, interpolation.MaxUint8, interpolation.MaxUint8, interpolation.MaxUint8}
+	var hi [4]int64
+
+	for j := 0; j < len(yc.Weights); j++ {
+		wy := int64(yc.Weights[j])
+		if wy == 0 {
+			continue
+		}
+
+		var row [4]int64
+		for i := 0; i < len(xc.Weights); i++ {
+			wx := int64(xc.Weights[i])
+			if wx == 0 {
+				continue
+			}
+
+			r32, g32, b32, a32 := s.edge.at(src, xc.Start+i, yc.Start+j)
+
+			px := [4]int64{int64(r32 >> 8), int64(g32 >> 8), int64(b32 >> 8), int64(a32 >> 8)}
+
+			for c := 0; c < len(row); c++ {
+				row[c] += px[c] * wx
+				lo[c] = min(lo[c], px[c])
+				hi[c] = max(hi[c], px[c])
+			}
+		}
+
+		for c := 0; c < len(acc); c++ {
+			acc[c] += row[c] * wy
+		}
+	}
+
+	for c := 0; c < len(result); c++ {
+		v := int64(interpolation.FixedToUint8(acc[c]))
+
+		// Suppress halos from negative kernel lobes when requested
+		if s.antiRinging {
+			v = max(lo[c], min(hi[c], v))
+		}
+
+		result[c] = float64(v)
+	}
+
+	return result
+}
@@ -0,0 +1,50 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// ResizeFrames resizes the equally sized frames of an animation with one
+// plan, so content-dependent anchors such as smart gravity stay put
+func (r *Resizer) ResizeFrames(frames []image.Image) ([]image.Image, error) {
+	return r.ResizeFramesContext(context.Background(), frames)
+}
+
+// ResizeFramesContext resizes frames like ResizeFrames, giving up when ctx
+// is done as ResizeContext does
+func (r *Resizer) ResizeFramesContext(ctx context.Context, frames []image.Image) ([]image.Image, error) {
+	// Assertion 1: Validate the frames
+	if len(frames) == 0 || frames[0] == nil {
+		return nil, ErrNilImage
+	}
+
+	size := frames[0].Bounds().Size()
+	for i := 1; i < len(frames); i++ {
+		if frames[i] == nil || frames[i].Bounds().Size() != size {
+			return nil, fmt.Errorf("%w: frame %d does not match the %dx%d first frame", ErrInvalidBounds, i, size.X, size.Y)
+		}
+	}
+
+	// Assertion 2: Resolve the anchor against the first frame only
+	if err := r.checkRegion(size.X, size.Y); err != nil {
+		return nil, err
+	}
+
+	planned, err := r.withContent(frames[0])
+	if err != nil {
+		return nil, err
+	}
+
+	resized := make([]image.Image, len(frames))
+	for i := 0; i < len(frames); i++ {
+		resized[i], err = planned.ResizeContext(ctx, frames[i])
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+
+	return resized, nil
+}
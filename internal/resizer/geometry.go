@@ -0,0 +1,308 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/transform"
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+// Mode selects how the target box maps onto the source image
+type Mode string
+
+const (
+	// ModeStretch resizes to exactly the target box, ignoring aspect (default)
+	ModeStretch Mode = "stretch"
+	// ModeFit scales to fit inside the target box, preserving aspect
+	ModeFit Mode = "fit"
+	// ModeFill scales to cover the target box and crops the overflow
+	ModeFill Mode = "fill"
+)
+
+var ErrUnknownMode = errors.New("unknown resize mode")
+
+// ParseMode converts a mode name into a Mode value
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case ModeStretch, ModeFit, ModeFill:
+		return Mode(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownMode, name)
+	}
+}
+
+// FitDimensions returns the largest size with the source aspect ratio that
+// fits inside the box, never smaller than one pixel per axis
+func FitDimensions(srcWidth, srcHeight, boxWidth, boxHeight int) (int, int) {
+	// Assertion 1: Guard against invalid dimensions
+	if srcWidth <= 0 || srcHeight <= 0 || boxWidth <= 0 || boxHeight <= 0 {
+		return boxWidth, boxHeight
+	}
+
+	scale := math.Min(float64(boxWidth)/float64(srcWidth), float64(boxHeight)/float64(srcHeight))
+
+	return scaledDimension(srcWidth, scale, boxWidth), scaledDimension(srcHeight, scale, boxHeight)
+}
+
+// sourceRegion is the part of the source, in source pixels, that maps onto
+// the whole output; the zero value stands for the full image
+type sourceRegion struct {
+	x      float64
+	y      float64
+	width  float64
+	height float64
+}
+
+// FillRegion returns the part of the source with the aspect ratio of the
+// box, which scaled to the box covers it exactly, placed by the anchor
+// The region is centred unless the anchor names a gravity or focal point
+func FillRegion(srcWidth, srcHeight, boxWidth, boxHeight int, anchor transform.Anchor) (float64, float64, float64, float64) {
+	sw, sh := float64(srcWidth), float64(srcHeight)
+
+	// Assertion 1: Guard against invalid dimensions
+	if srcWidth <= 0 || srcHeight <= 0 || boxWidth <= 0 || boxHeight <= 0 {
+		return 0.0, 0.0, sw, sh
+	}
+
+	scale := math.Max(float64(boxWidth)/sw, float64(boxHeight)/sh)
+	width := math.Min(sw, float64(boxWidth)/scale)
+	height := math.Min(sh, float64(boxHeight)/scale)
+
+	x, y := anchor.Position(sw, sh, width, height, 0.0, 0.0, transform.GravityCenter)
+	return x, y, width, height
+}
+
+// regionFor returns the configured source region, or the full image
+func (r *Resizer) regionFor(srcWidth, srcHeight int) sourceRegion {
+	if r.region.width > 0.0 && r.region.height > 0.0 {
+		return r.region
+	}
+
+	return sourceRegion{width: float64(srcWidth), height: float64(srcHeight)}
+}
+
+// regionSize returns the region dimensions rounded to whole pixels
+func (g sourceRegion) regionSize() (int, int) {
+	return max(validator.MinImageDimension, int(math.Round(g.width))),
+		max(validator.MinImageDimension, int(math.Round(g.height)))
+}
+
+// scaledDimension rounds size*scale into [MinImageDimension, limit]
+func scaledDimension(size int, scale float64, limit int) int {
+	d := int(math.Round(float64(size) * scale))
+
+	// Assertion 1: Check lower bound
+	if d < validator.MinImageDimension {
+		return validator.MinImageDimension
+	}
+
+	// Assertion 2: Check upper bound
+	if d > limit {
+		return limit
+	}
+
+	return d
+}
+
+// OutputSize returns the dimensions Resize produces for a source image of
+// the given size under the configured mode
+func (r *Resizer) OutputSize(srcWidth, srcHeight int) (int, int) {
+	width, height := r.requestedSize(srcWidth, srcHeight)
+
+	// Assertion 1: Apply the upscale guard to the requested size
+	if r.config.NoUpscale == UpscaleCopy || r.config.NoUpscale == UpscaleClamp {
+		region := r.requestedRegion(srcWidth, srcHeight, width, height)
+
+		if upscales(region, width, height) {
+			if r.config.NoUpscale == UpscaleCopy {
+				return r.roiFor(srcWidth, srcHeight).regionSize()
+			}
+
+			return clampToRegion(region, width, height)
+		}
+	}
+
+	return width, height
+}
+
+// requestedSize returns the output size asked for by the mode and target,
+// before any upscale guard is applied
+// A source rectangle takes the place of the whole source
+func (r *Resizer) requestedSize(srcWidth, srcHeight int) (int, int) {
+	srcWidth, srcHeight = r.roiFor(srcWidth, srcHeight).regionSize()
+
+	// Assertion 1: The longest-edge constraint alone fixes both dimensions
+	if r.config.MaxEdge > 0 && r.config.Aspect == 0.0 {
+		return LongestEdgeDimensions(srcWidth, srcHeight, r.config.MaxEdge)
+	}
+
+	var width, height int
+	switch {
+	case r.config.Megapixels > 0.0:
+		width, height = MegapixelDimensions(srcWidth, srcHeight, r.config.Megapixels, r.config.Aspect)
+	case r.config.MaxEdge > 0:
+		width, height = ratioEdgeDimensions(r.config.MaxEdge, r.config.Aspect)
+	case r.config.Aspect > 0.0:
+		width, height = RatioDimensions(srcWidth, srcHeight, r.config.TargetWidth, r.config.TargetHeight, r.config.Aspect)
+	default:
+		width, height = AspectDimensions(srcWidth, srcHeight, r.config.TargetWidth, r.config.TargetHeight)
+	}
+
+	switch r.config.Mode {
+	case ModeFit:
+		return FitDimensions(srcWidth, srcHeight, width, height)
+	default:
+		return width, height
+	}
+}
+
+// requestedRegion returns the source region the mode maps onto the output
+func (r *Resizer) requestedRegion(srcWidth, srcHeight, width, height int) sourceRegion {
+	roi := r.roiFor(srcWidth, srcHeight)
+
+	// Assertion 1: Only fill mode crops the source further
+	if r.config.Mode != ModeFill {
+		return roi
+	}
+
+	roiWidth, roiHeight := roi.regionSize()
+	x, y, w, h := FillRegion(roiWidth, roiHeight, width, height, r.config.Anchor)
+	return sourceRegion{x: roi.x + x, y: roi.y + y, width: w, height: h}
+}
+
+// skipsUpscale reports whether the copy policy returns src unchanged
+func (r *Resizer) skipsUpscale(srcWidth, srcHeight int) bool {
+	// Assertion 1: Only the copy policy skips work
+	if r.config.NoUpscale != UpscaleCopy {
+		return false
+	}
+
+	roiWidth, roiHeight := r.roiFor(srcWidth, srcHeight).regionSize()
+	return r.config.TargetWidth == roiWidth && r.config.TargetHeight == roiHeight
+}
+
+// refusesUpscale reports whether the error policy rejects the resize
+func (r *Resizer) refusesUpscale(srcWidth, srcHeight int) bool {
+	// Assertion 1: Only the error policy refuses work
+	if r.config.NoUpscale != UpscaleError {
+		return false
+	}
+
+	return upscales(r.regionFor(srcWidth, srcHeight), r.config.TargetWidth, r.config.TargetHeight)
+}
+
+// LongestEdgeDimensions scales the source so its longest side is edge
+// pixels, preserving the aspect ratio
+func LongestEdgeDimensions(srcWidth, srcHeight, edge int) (int, int) {
+	// Assertion 1: Scale along the longer axis
+	if srcWidth >= srcHeight {
+		return AspectDimensions(srcWidth, srcHeight, edge, 0)
+	}
+
+	return AspectDimensions(srcWidth, srcHeight, 0, edge)
+}
+
+// AspectDimensions fills in a zero width or height from the source aspect
+// ratio, returning the requested dimensions unchanged otherwise
+func AspectDimensions(srcWidth, srcHeight, width, height int) (int, int) {
+	// Assertion 1: Guard against invalid source dimensions
+	if srcWidth <= 0 || srcHeight <= 0 {
+		return width, height
+	}
+
+	if width == 0 && height > 0 {
+		width = scaledDimension(srcWidth, float64(height)/float64(srcHeight), validator.MaxImageDimension)
+	}
+
+	if height == 0 && width > 0 {
+		height = scaledDimension(srcHeight, float64(width)/float64(srcWidth), validator.MaxImageDimension)
+	}
+
+	return width, height
+}
+
+// CheckSource validates that a source image of the given size can be
+// resized under the configured mode and scale limits
+func (r *Resizer) CheckSource(srcWidth, srcHeight int) error {
+	// Assertion 1: Validate source dimensions and region
+	if err := validator.ValidateDimensions(srcWidth, srcHeight); err != nil {
+		return err
+	}
+
+	if err := r.checkRegion(srcWidth, srcHeight); err != nil {
+		return err
+	}
+
+	planned := r.withGeometry(srcWidth, srcHeight)
+	regionWidth, regionHeight := planned.regionFor(srcWidth, srcHeight).regionSize()
+
+	// Assertion 2: Validate the scale factors of the mapped region
+	return planned.validateRatio(regionWidth, regionHeight)
+}
+
+// withGeometry returns a resizer targeting the output size and source
+// region for src, leaving r untouched so it can serve images of different sizes
+func (r *Resizer) withGeometry(srcWidth, srcHeight int) *Resizer {
+	width, height := r.OutputSize(srcWidth, srcHeight)
+
+	// Assertion 1: Avoid a copy when the target box is used as is
+	if width == r.config.TargetWidth && height == r.config.TargetHeight && r.config.Mode != ModeFill && !r.hasRegion() {
+		return r
+	}
+
+	active := *r
+	active.config.TargetWidth = width
+	active.config.TargetHeight = height
+
+	if r.config.Mode == ModeFill || r.hasRegion() {
+		active.region = r.requestedRegion(srcWidth, srcHeight, width, height)
+	}
+
+	return &active
+}
+
+// withContent returns a resizer whose fill anchor is resolved against the
+// content of src, as needed by smart gravity, leaving r untouched
+// Only the source rectangle is analysed when one is set
+func (r *Resizer) withContent(src image.Image) (*Resizer, error) {
+	// Assertion 1: Only fill mode with a content-dependent anchor changes
+	if r.config.Mode != ModeFill || r.config.Anchor.Gravity != transform.GravitySmart || r.config.Anchor.Focal != nil {
+		return r, nil
+	}
+
+	roi, err := r.regionView(src)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	roiWidth, roiHeight := roi.Bounds().Dx(), roi.Bounds().Dy()
+	width, height := r.OutputSize(bounds.Dx(), bounds.Dy())
+	_, _, regionWidth, regionHeight := FillRegion(roiWidth, roiHeight, width, height, r.config.Anchor)
+
+	active := *r
+	active.config.Anchor = r.config.Anchor.Resolve(roi, regionWidth, regionHeight)
+	return &active, nil
+}
+
+// withScaledRegion returns a resizer whose source region follows the source
+// being shrunk by the given factors, as done by supersampling
+func (r *Resizer) withScaledRegion(scaleX, scaleY float64) *Resizer {
+	// Assertion 1: Full-image regions need no adjustment
+	if r.region.width <= 0.0 || r.region.height <= 0.0 {
+		return r
+	}
+
+	active := *r
+	active.region = sourceRegion{
+		x:      r.region.x * scaleX,
+		y:      r.region.y * scaleY,
+		width:  r.region.width * scaleX,
+		height: r.region.height * scaleY,
+	}
+	return &active
+}
@@ -0,0 +1,122 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
+
+var ErrInvalidGrayscale = errors.New("invalid grayscale depth")
+
+// validateGrayscale accepts the grayscale output depths, zero keeps color
+func validateGrayscale(depth int) error {
+	if depth != 0 && depth != 8 && depth != 16 {
+		return fmt.Errorf("%w: %d, want 8 or 16", ErrInvalidGrayscale, depth)
+	}
+
+	return nil
+}
+
+// srgbCurves maps 16-bit sRGB values to linear light and back
+type srgbCurves struct {
+	decode [0x10000]uint16
+	encode [0x10000]uint16
+}
+
+// curves is built on first use, a grayscale resize is not the common case
+var curves = sync.OnceValue(func() *srgbCurves {
+	c := &srgbCurves{}
+	for i := range c.decode {
+		v := float64(i) / 0xFFFF
+
+		linear := v / 12.92
+		if v > 0.04045 {
+			linear = math.Pow((v+0.055)/1.055, 2.4)
+		}
+
+		encoded := v * 12.92
+		if v > 0.0031308 {
+			encoded = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+
+		c.decode[i] = uint16(math.Round(linear * 0xFFFF))
+		c.encode[i] = uint16(math.Round(encoded * 0xFFFF))
+	}
+
+	return c
+})
+
+// linearLuminance returns the Rec. 709 luminance of src in linear light,
+// keeping its bounds; transparency darkens toward black as the alpha is
+// dropped
+func linearLuminance(src image.Image) *image.Gray16 {
+	c := curves()
+	bounds := src.Bounds()
+	dst := newGray16(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := rgbaAt(src, x, y)
+			if a == 0 {
+				continue
+			}
+
+			// Decode the straight color, then weight it by coverage
+			if a != 0xFFFF {
+				r, g, b = r*0xFFFF/a, g*0xFFFF/a, b*0xFFFF/a
+			}
+
+			lum := 0.2126*float64(c.decode[r]) + 0.7152*float64(c.decode[g]) + 0.0722*float64(c.decode[b])
+			lum = lum * float64(a) / 0xFFFF
+
+			i := dst.PixOffset(x, y)
+			v := uint16(math.Round(lum))
+			dst.Pix[i], dst.Pix[i+1] = uint8(v>>8), uint8(v)
+		}
+	}
+
+	return dst
+}
+
+// encodeGray converts linear luminance back to sRGB at the given depth
+func encodeGray(lin *image.Gray16, depth int) image.Image {
+	c := curves()
+	bounds := lin.Bounds()
+
+	// Assertion 1: Sixteen bits keep the full curve
+	if depth == 16 {
+		dst := newGray16(bounds)
+		for i := 0; i+1 < len(lin.Pix); i += 2 {
+			v := c.encode[uint16(lin.Pix[i])<<8|uint16(lin.Pix[i+1])]
+			dst.Pix[i], dst.Pix[i+1] = uint8(v>>8), uint8(v)
+		}
+
+		return dst
+	}
+
+	dst := newGray(bounds)
+	for i := 0; i < len(dst.Pix); i++ {
+		v := c.encode[uint16(lin.Pix[2*i])<<8|uint16(lin.Pix[2*i+1])]
+		dst.Pix[i] = uint8((uint32(v)*0xFF + 0x7FFF) / 0xFFFF)
+	}
+
+	return dst
+}
+
+// resizeGrayscale resizes the linear luminance of src and encodes the
+// result at the configured depth, so averaging happens in linear light
+func (r *Resizer) resizeGrayscale(src image.Image, srcWidth, srcHeight int) (image.Image, error) {
+	lum := linearLuminance(src)
+	lin, err := r.resizeGray16(lum, srcWidth, srcHeight)
+	Release(lum)
+	if err != nil {
+		return nil, err
+	}
+
+	gray := encodeGray(lin, r.config.Grayscale)
+	Release(lin)
+	return gray, nil
+}
@@ -0,0 +1,44 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+// pixelsPerMegapixel converts megapixels into a pixel count
+const pixelsPerMegapixel = 1e6
+
+var ErrInvalidMegapixels = errors.New("invalid megapixel target")
+
+// validateMegapixels checks that a pixel budget fits the dimension limits
+func validateMegapixels(megapixels float64) error {
+	limit := float64(validator.MaxImageDimension) * float64(validator.MaxImageDimension) / pixelsPerMegapixel
+
+	if math.IsNaN(megapixels) || megapixels <= 0.0 || megapixels > limit {
+		return fmt.Errorf("%w: %g must be in (0, %g]", ErrInvalidMegapixels, megapixels, limit)
+	}
+
+	return nil
+}
+
+// MegapixelDimensions returns the size with about megapixels million pixels
+// and the given width/height ratio, or the source ratio when ratio is zero
+func MegapixelDimensions(srcWidth, srcHeight int, megapixels, ratio float64) (int, int) {
+	// Assertion 1: Default to the source aspect
+	if ratio <= 0.0 {
+		if srcWidth <= 0 || srcHeight <= 0 {
+			return srcWidth, srcHeight
+		}
+
+		ratio = float64(srcWidth) / float64(srcHeight)
+	}
+
+	pixels := megapixels * pixelsPerMegapixel
+	limit := validator.MaxImageDimension
+
+	return scaledDimension(1, math.Sqrt(pixels*ratio), limit), scaledDimension(1, math.Sqrt(pixels/ratio), limit)
+}
@@ -0,0 +1,86 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image/color"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// pixelBytes returns the bytes per pixel of an image in model
+func pixelBytes(model color.Model) int {
+	switch model {
+	case color.GrayModel:
+		return 1
+	case color.Gray16Model:
+		return 2
+	case color.RGBA64Model, color.NRGBA64Model:
+		return 8
+	case hdr.ColorModel:
+		return 16
+	default:
+		return 4
+	}
+}
+
+// WorkingBytes estimates the memory Resize allocates for a source of this
+// size in model, beyond the source itself: the output plus the converted
+// copies and intermediate rows of the path the source takes
+// A nil model is estimated as 8-bit RGBA
+func (r *Resizer) WorkingBytes(srcWidth, srcHeight int, model color.Model) int64 {
+	width, height := r.OutputSize(srcWidth, srcHeight)
+	src := int64(srcWidth) * int64(srcHeight)
+	dst := int64(width) * int64(height)
+	cfg := r.config
+
+	// Assertion 1: Gray output resizes a linear luminance copy at 16 bits
+	if cfg.Grayscale != 0 {
+		return 2*src + 2*dst + int64(cfg.Grayscale/8)*dst
+	}
+
+	var total int64
+	switch model {
+	case color.YCbCrModel:
+		if cfg.EWA || cfg.FixedPoint || cfg.AntiRinging || cfg.Edge == interpolation.EdgeConstant {
+			total = 4*src + 4*dst
+			break
+		}
+
+		// Planar JPEG resizes keep three samples per output column of every
+		// source row
+		total = 3*sampleBytes(cfg.Precision)*int64(width)*int64(srcHeight) + 4*dst
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model:
+		channels := int64(pixelBytes(model) / 2)
+		total = 2 * channels * dst
+		if cfg.EWA || cfg.AntiRinging || cfg.Edge == interpolation.EdgeConstant {
+			break
+		}
+
+		// Planar 16-bit resizes keep a sample per channel and output
+		// column of every source row
+		total += sampleBytes(cfg.Precision) * channels * int64(width) * int64(srcHeight)
+	case color.CMYKModel:
+		total = 4*src + 4*dst
+	case nil:
+		total = 4 * dst
+	default:
+		total = int64(pixelBytes(model)) * dst
+		if _, ok := model.(color.Palette); ok {
+			// Paletted sources resize in full color, then map back
+			total = 5 * dst
+		}
+	}
+
+	// Area passes keep a halved copy of the source, each next one smaller
+	if cfg.Supersample {
+		total += int64(pixelBytes(model)) * src / 2
+	}
+
+	// The GPU path uploads float32 samples and reads back its result
+	if cfg.Accel == AccelGPU {
+		total += 16*src + 16*dst
+	}
+
+	return total
+}
@@ -0,0 +1,18 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !vips || !cgo
+
+package resizer
+
+import "image"
+
+// Backend names the resampling implementation built into the binary
+func Backend() string {
+	return "go"
+}
+
+// resizeNative is the hook of the optional libvips backend; without the
+// vips build tag every resize stays with the Go resampler
+func (r *Resizer) resizeNative(image.Image, int, int) (image.Image, bool, error) {
+	return nil, false, nil
+}
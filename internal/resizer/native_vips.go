@@ -0,0 +1,179 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build vips && cgo
+
+package resizer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+	"github.com/kasuraSH/kasurarykerion/internal/vips"
+)
+
+// vipsKernels maps the filters libvips implements to its kernels
+var vipsKernels = map[Filter]vips.Kernel{
+	FilterBilinear:             vips.KernelLinear,
+	FilterCatmullRom:           vips.KernelCubic,
+	FilterBicubic:              vips.KernelMitchell,
+	FilterLanczos3:             vips.KernelLanczos3,
+	FilterMagicKernelSharp2013: vips.KernelMKS2013,
+	FilterMagicKernelSharp2021: vips.KernelMKS2021,
+}
+
+// Backend names the resampling implementation built into the binary
+func Backend() string {
+	return "libvips " + vips.Version()
+}
+
+// resizeNative resizes src with libvips when it implements the
+// configuration, reporting false so the Go resampler runs otherwise
+// libvips applies its own kernels, so results are close to but not
+// identical with the Go resampler
+func (r *Resizer) resizeNative(src image.Image, srcWidth, srcHeight int) (image.Image, bool, error) {
+	kernel, ok := r.vipsKernel(srcWidth, srcHeight)
+	if !ok {
+		return nil, false, nil
+	}
+
+	// Assertion 1: libvips crops whole pixels only
+	region := r.regionFor(srcWidth, srcHeight)
+	for _, v := range []float64{region.x, region.y, region.width, region.height} {
+		if v != math.Trunc(v) {
+			return nil, false, nil
+		}
+	}
+
+	in, ok := packForVips(src)
+	if !ok {
+		return nil, false, nil
+	}
+
+	crop := image.Rect(int(region.x), int(region.y), int(region.x+region.width), int(region.y+region.height))
+	dst, out := newVipsTarget(in, r.config.TargetWidth, r.config.TargetHeight)
+	if err := vips.Resize(in, crop, out, kernel); err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrResizeFailed, err)
+	}
+
+	if out.Wide {
+		swapSamples(out.Pix)
+	}
+
+	return dst, true, nil
+}
+
+// vipsKernel returns the libvips kernel for the configured filter, false
+// when the configuration needs the Go resampler
+func (r *Resizer) vipsKernel(srcWidth, srcHeight int) (vips.Kernel, bool) {
+	cfg := r.config
+	if cfg.EWA || cfg.AntiRinging || cfg.FixedPoint || cfg.Edge != interpolation.EdgeClamp {
+		return 0, false
+	}
+
+	filter := cfg.Filter
+	if filter == FilterAuto {
+		regionWidth, regionHeight := r.regionFor(srcWidth, srcHeight).regionSize()
+		filter = AutoFilter(regionWidth, regionHeight, cfg.TargetWidth, cfg.TargetHeight)
+	}
+
+	kernel, ok := vipsKernels[filter]
+	return kernel, ok
+}
+
+// packForVips lays src out the way libvips reads memory, converting other
+// layouts to premultiplied RGBA at their bit depth; float images are left
+// to the Go resampler
+func packForVips(src image.Image) (vips.Image, bool) {
+	bounds := src.Bounds()
+	in := vips.Image{Width: bounds.Dx(), Height: bounds.Dy()}
+
+	switch s := src.(type) {
+	case *image.Gray:
+		in.Bands = 1
+		in.Pix = packRows(s.Pix, s.Stride, in.Width, in.Height)
+	case *image.Gray16:
+		in.Bands, in.Wide = 1, true
+		in.Pix = packRows(s.Pix, s.Stride, 2*in.Width, in.Height)
+	case *image.RGBA:
+		in.Bands = 4
+		in.Pix = packRows(s.Pix, s.Stride, 4*in.Width, in.Height)
+	case *image.RGBA64:
+		in.Bands, in.Wide = 4, true
+		in.Pix = packRows(s.Pix, s.Stride, 8*in.Width, in.Height)
+	case *image.NRGBA64:
+		wide := image.NewRGBA64(bounds)
+		draw.Draw(wide, bounds, src, bounds.Min, draw.Src)
+		in.Bands, in.Wide = 4, true
+		in.Pix = wide.Pix
+	case *hdr.RGBA:
+		return in, false
+	default:
+		in.Bands = 4
+		in.Pix = toRGBA(src).Pix
+	}
+
+	// Go stores 16-bit samples big-endian, libvips in machine order
+	if in.Wide && binary.NativeEndian.Uint16([]byte{1, 0}) == 1 {
+		in.Pix = append([]byte(nil), in.Pix...)
+		swapSamples(in.Pix)
+	}
+
+	return in, true
+}
+
+// packRows returns rows of rowBytes bytes from a buffer with the given
+// stride, copying only when the rows are padded
+func packRows(pix []byte, stride, rowBytes, height int) []byte {
+	if stride == rowBytes {
+		return pix[:rowBytes*height]
+	}
+
+	packed := make([]byte, rowBytes*height)
+	for y := 0; y < height; y++ {
+		copy(packed[y*rowBytes:], pix[y*stride:y*stride+rowBytes])
+	}
+
+	return packed
+}
+
+// newVipsTarget allocates the image the Go resampler returns for a source
+// laid out like in, and the libvips view of its pixels
+func newVipsTarget(in vips.Image, width, height int) (image.Image, vips.Image) {
+	rect := image.Rect(0, 0, width, height)
+	out := vips.Image{Width: width, Height: height, Bands: in.Bands, Wide: in.Wide}
+
+	var dst image.Image
+	switch {
+	case in.Bands == 1 && !in.Wide:
+		gray := newGray(rect)
+		dst, out.Pix = gray, gray.Pix
+	case in.Bands == 1:
+		gray := newGray16(rect)
+		dst, out.Pix = gray, gray.Pix
+	case !in.Wide:
+		rgba := newRGBA(rect)
+		dst, out.Pix = rgba, rgba.Pix
+	default:
+		rgba := newRGBA64(rect)
+		dst, out.Pix = rgba, rgba.Pix
+	}
+
+	return dst, out
+}
+
+// swapSamples converts 16-bit samples between machine order and the
+// big-endian order of Go images in place; big-endian machines need nothing
+func swapSamples(pix []byte) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		return
+	}
+
+	for i := 0; i+1 < len(pix); i += 2 {
+		pix[i], pix[i+1] = pix[i+1], pix[i]
+	}
+}
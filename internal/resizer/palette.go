@@ -0,0 +1,66 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/kasuraSH/kasurarykerion/internal/transform"
+)
+
+// PalettePolicy decides what a paletted source, such as a GIF or PNG8,
+// is resized into
+type PalettePolicy string
+
+const (
+	// PaletteTrueColor returns a full color image (default)
+	PaletteTrueColor PalettePolicy = "truecolor"
+	// PaletteSource maps the result back onto the palette of the source
+	PaletteSource PalettePolicy = "source"
+	// PaletteAdaptive builds a new palette of the same size from the result
+	PaletteAdaptive PalettePolicy = "adaptive"
+)
+
+var ErrUnknownPalettePolicy = errors.New("unknown palette policy")
+
+// ParsePalettePolicy converts a policy name into a PalettePolicy value
+func ParsePalettePolicy(name string) (PalettePolicy, error) {
+	switch PalettePolicy(name) {
+	case PaletteTrueColor, PaletteSource, PaletteAdaptive:
+		return PalettePolicy(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownPalettePolicy, name)
+	}
+}
+
+// sourcePalette returns the palette src should be re-quantized to, or nil
+// when the result stays true color
+func (r *Resizer) sourcePalette(src image.Image) color.Palette {
+	// Assertion 1: Only paletted sources under a palette policy qualify
+	palette, ok := src.ColorModel().(color.Palette)
+	if !ok || len(palette) == 0 || r.config.Palette == PaletteTrueColor {
+		return nil
+	}
+
+	return palette
+}
+
+// requantize converts a resized image back to a palette as configured,
+// reusing the source palette or deriving one with as many colors
+func (r *Resizer) requantize(img image.Image, source color.Palette) (*image.Paletted, error) {
+	palette := source
+
+	// Assertion 1: Adaptive palettes follow the resized colors
+	if r.config.Palette == PaletteAdaptive {
+		adaptive, err := transform.Quantize(img, len(source))
+		if err != nil {
+			return nil, err
+		}
+
+		palette = adaptive
+	}
+
+	return transform.Remap(img, palette, r.config.Dither)
+}
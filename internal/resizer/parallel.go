@@ -0,0 +1,84 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxJobs bounds the worker goroutines of one resize
+const MaxJobs = 1024
+
+var ErrInvalidJobs = errors.New("invalid job count")
+
+// validateJobs accepts worker counts up to MaxJobs, zero picks GOMAXPROCS
+func validateJobs(jobs int) error {
+	if jobs < 0 || jobs > MaxJobs {
+		return fmt.Errorf("%w: %d, want 0-%d", ErrInvalidJobs, jobs, MaxJobs)
+	}
+
+	return nil
+}
+
+// workers returns how many goroutines share rows rows
+func (r *Resizer) workers(rows int) int {
+	jobs := r.config.Jobs
+	if jobs == 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+
+	return max(min(jobs, rows), 1)
+}
+
+// forEachRow calls fn for every row in [0, rows), handing rows out to a
+// pool of workers; rows must not share output memory
+// fn cannot fail, everything it relies on is validated before the rows
+// are handed out; once the context of a ResizeContext call is done the
+// rows left are skipped, and the caller drops the partial result
+func (r *Resizer) forEachRow(rows int, fn func(y int)) {
+	r.forEachWorkerRow(rows, func(_, y int) { fn(y) })
+}
+
+// forEachWorkerRow is forEachRow also passing fn the index of the worker
+// running it, below r.workers(rows), so each worker can own scratch memory
+func (r *Resizer) forEachWorkerRow(rows int, fn func(worker, y int)) {
+	workers := r.workers(rows)
+	done := r.done()
+	counter := r.newRowCounter(rows)
+
+	// Assertion 1: A single worker runs inline, without goroutines
+	if workers == 1 {
+		for y := 0; y < rows && !closed(done); y++ {
+			fn(0, y)
+			counter.add()
+		}
+
+		return
+	}
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			// Each row is taken once, so a worker runs at most rows times
+			for i := 0; i < rows; i++ {
+				y := int(next.Add(1) - 1)
+				if y >= rows || closed(done) {
+					return
+				}
+
+				fn(worker, y)
+				counter.add()
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
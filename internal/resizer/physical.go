@@ -0,0 +1,61 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+var ErrInvalidPrintSize = errors.New("invalid print size")
+
+// printSizePattern matches WxH with an optional in, cm or mm unit
+var printSizePattern = regexp.MustCompile(`^([0-9]*\.?[0-9]+)x([0-9]*\.?[0-9]+)(in|cm|mm)?$`)
+
+// inchesPerUnit converts print size units into inches
+var inchesPerUnit = map[string]float64{
+	"":   1.0,
+	"in": 1.0,
+	"cm": 1.0 / 2.54,
+	"mm": 1.0 / 25.4,
+}
+
+// ParsePrintSize parses a physical size such as "4x6in" or "10x15cm" and
+// returns its width and height in inches
+func ParsePrintSize(s string) (float64, float64, error) {
+	m := printSizePattern.FindStringSubmatch(s)
+
+	// Assertion 1: Validate syntax
+	if m == nil {
+		return 0.0, 0.0, fmt.Errorf("%w: expected WxH[in|cm|mm], got %q", ErrInvalidPrintSize, s)
+	}
+
+	w, errW := strconv.ParseFloat(m[1], 64)
+	h, errH := strconv.ParseFloat(m[2], 64)
+
+	// Assertion 2: Validate both sides are positive
+	if errW != nil || errH != nil || w <= 0.0 || h <= 0.0 {
+		return 0.0, 0.0, fmt.Errorf("%w: %q", ErrInvalidPrintSize, s)
+	}
+
+	unit := inchesPerUnit[m[3]]
+	return w * unit, h * unit, nil
+}
+
+// PrintDimensions returns the pixel size of a print of the given inches
+// at dpi dots per inch
+func PrintDimensions(widthInches, heightInches, dpi float64) (int, int, error) {
+	width := int(math.Round(widthInches * dpi))
+	height := int(math.Round(heightInches * dpi))
+
+	// Assertion 1: Validate the resulting pixel size
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return 0, 0, fmt.Errorf("%w: %gx%g in at %g DPI: %v", ErrInvalidPrintSize, widthInches, heightInches, dpi, err)
+	}
+
+	return width, height, nil
+}
@@ -0,0 +1,58 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"image/color"
+)
+
+// rgbaAt returns the 16-bit premultiplied channels of the pixel at (x, y)
+// exactly as src.At(x, y).RGBA() would, reading the common image types
+// straight from their Pix slices instead of boxing a color.Color per call
+// (x, y) must lie inside the bounds of src
+func rgbaAt(src image.Image, x, y int) (uint32, uint32, uint32, uint32) {
+	switch p := src.(type) {
+	case *image.RGBA:
+		i := p.PixOffset(x, y)
+		s := p.Pix[i : i+4 : i+4]
+		return uint32(s[0]) * 0x101, uint32(s[1]) * 0x101, uint32(s[2]) * 0x101, uint32(s[3]) * 0x101
+
+	case *image.NRGBA:
+		i := p.PixOffset(x, y)
+		s := p.Pix[i : i+4 : i+4]
+		a := uint32(s[3])
+		r, g, b := uint32(s[0])*0x101*a/0xFF, uint32(s[1])*0x101*a/0xFF, uint32(s[2])*0x101*a/0xFF
+		return r, g, b, a * 0x101
+
+	case *image.RGBA64:
+		i := p.PixOffset(x, y)
+		s := p.Pix[i : i+8 : i+8]
+		return uint32(s[0])<<8 | uint32(s[1]), uint32(s[2])<<8 | uint32(s[3]),
+			uint32(s[4])<<8 | uint32(s[5]), uint32(s[6])<<8 | uint32(s[7])
+
+	case *image.NRGBA64:
+		i := p.PixOffset(x, y)
+		s := p.Pix[i : i+8 : i+8]
+		a := uint32(s[6])<<8 | uint32(s[7])
+		r := (uint32(s[0])<<8 | uint32(s[1])) * a / 0xFFFF
+		g := (uint32(s[2])<<8 | uint32(s[3])) * a / 0xFFFF
+		b := (uint32(s[4])<<8 | uint32(s[5])) * a / 0xFFFF
+		return r, g, b, a
+
+	case *image.Gray:
+		v := uint32(p.Pix[p.PixOffset(x, y)]) * 0x101
+		return v, v, v, 0xFFFF
+
+	case *image.Gray16:
+		i := p.PixOffset(x, y)
+		v := uint32(p.Pix[i])<<8 | uint32(p.Pix[i+1])
+		return v, v, v, 0xFFFF
+
+	case *image.YCbCr:
+		yi, ci := p.YOffset(x, y), p.COffset(x, y)
+		return color.YCbCr{Y: p.Y[yi], Cb: p.Cb[ci], Cr: p.Cr[ci]}.RGBA()
+
+	default:
+		return src.At(x, y).RGBA()
+	}
+}
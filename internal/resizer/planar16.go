@@ -0,0 +1,185 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// planar16 reports whether the planar path can resize src; EWA,
+// anti-ringing and constant edges need the sampler, as do 16-bit images
+// of other types
+func (r *Resizer) planar16(src image.Image) bool {
+	switch src.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+	default:
+		return false
+	}
+
+	return !r.config.EWA && !r.config.AntiRinging && r.config.Edge != interpolation.EdgeConstant
+}
+
+// planes16 resizes the channels of src through resizePlanes16 at the
+// configured precision, handing target rows to the store of that type
+func (r *Resizer) planes16(src image.Image, srcWidth, srcHeight, channels int, store32 func(int, []float32), store64 func(int, []float64)) error {
+	if r.config.Precision == PrecisionFloat32 {
+		return resizePlanes16(r, kernels32, src, srcWidth, srcHeight, channels, store32)
+	}
+
+	return resizePlanes16(r, kernels64, src, srcWidth, srcHeight, channels, store64)
+}
+
+// storeRGBA64 returns a store writing the red, green, blue and alpha
+// planes of a target row to dst
+func storeRGBA64[T sample](dst *image.RGBA64) func(y int, acc []T) {
+	width := dst.Rect.Dx()
+
+	return func(y int, acc []T) {
+		pix := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			for c := 0; c < 4; c++ {
+				v := interpolation.ClampUint16(float64(acc[c*width+x]))
+				pix[8*x+2*c], pix[8*x+2*c+1] = uint8(v>>8), uint8(v)
+			}
+		}
+	}
+}
+
+// storeGray16 returns a store writing the gray plane of a target row to
+// dst
+func storeGray16[T sample](dst *image.Gray16) func(y int, acc []T) {
+	return func(y int, acc []T) {
+		pix := dst.Pix[y*dst.Stride:]
+		for x, v := range acc {
+			g := interpolation.ClampUint16(float64(v))
+			pix[2*x], pix[2*x+1] = uint8(g>>8), uint8(g)
+		}
+	}
+}
+
+// resizePlanes16 resamples the first channels premultiplied 16-bit
+// channels of src one axis at a time, like resizeYCbCr does for JPEG
+// Every target row is handed to store as channels planes of dstWidth
+// samples; the intermediate rows and the scratch rows of every worker
+// are carved from one buffer for the whole resize
+func resizePlanes16[T sample](r *Resizer, k kernels[T], src image.Image, srcWidth, srcHeight, channels int, store func(dy int, acc []T)) error {
+	table, err := r.newTableSampler(srcWidth, srcHeight)
+	if err != nil {
+		return err
+	}
+
+	dstWidth, dstHeight := r.config.TargetWidth, r.config.TargetHeight
+	mode := table.edge.mode
+	origin := table.edge.origin
+
+	// Only rows some output row reads need the horizontal pass
+	used := make([]bool, srcHeight)
+	for _, yc := range table.yContribs {
+		for j := range yc.Weights {
+			if sy, ok := interpolation.ResolveIndex(yc.Start+j, srcHeight, mode); ok {
+				used[sy] = true
+			}
+		}
+	}
+
+	// Source columns the taps reach, edges resolved once
+	first, last := table.xContribs[0].Start, 0
+	for _, xc := range table.xContribs {
+		first = min(first, xc.Start)
+		last = max(last, xc.Start+len(xc.Weights))
+	}
+
+	columns := make([]int, last-first)
+	for k := range columns {
+		columns[k], _ = interpolation.ResolveIndex(first+k, srcWidth, mode)
+	}
+
+	// Each worker splits source rows into its planes, then accumulates
+	// target rows over the same memory
+	n := len(columns)
+	width := channels * dstWidth
+	scratch := max(channels*n, width)
+	workers := r.workers(max(srcHeight, dstHeight))
+
+	arena := k.rows.get(width*srcHeight + workers*scratch)
+	defer k.rows.put(arena)
+	rows, scratches := arena[:width*srcHeight], arena[width*srcHeight:]
+	xWeights := k.weights(table.xContribs)
+
+	// Horizontal pass: each used row is split into channel planes over
+	// those columns, so every target sample is a dot product over
+	// contiguous taps
+	r.quiet().forEachWorkerRow(srcHeight, func(worker, sy int) {
+		if !used[sy] {
+			return
+		}
+
+		planes := scratches[worker*scratch : worker*scratch+channels*n]
+		widen16(src, origin.X, origin.Y+sy, columns, planes)
+
+		row := rows[width*sy:]
+		for dx, xc := range table.xContribs {
+			taps := xWeights(dx)
+			for c := 0; c < channels; c++ {
+				row[c*dstWidth+dx] = k.dot(taps, planes[c*n+xc.Start-first:(c+1)*n])
+			}
+		}
+	})
+
+	// Vertical pass: whole intermediate rows are weighted into one
+	// accumulator row per target row
+	r.forEachWorkerRow(dstHeight, func(worker, dy int) {
+		acc := scratches[worker*scratch : worker*scratch+width]
+		clear(acc)
+
+		for j, w := range table.yContribs[dy].Weights {
+			if w == 0.0 {
+				continue
+			}
+
+			sy, _ := interpolation.ResolveIndex(table.yContribs[dy].Start+j, srcHeight, mode)
+			k.addScaled(acc, rows[width*sy:], T(w))
+		}
+
+		store(dy, acc)
+	})
+
+	return nil
+}
+
+// widen16 splits the given columns of row y of src, relative to x0, into
+// consecutive planes of len(columns) samples, premultiplied as rgbaAt
+// reads them: red, green, blue and alpha, or the gray level alone
+func widen16[T sample](src image.Image, x0, y int, columns []int, planes []T) {
+	n := len(columns)
+
+	switch p := src.(type) {
+	case *image.RGBA64:
+		pix := p.Pix[p.PixOffset(x0, y):]
+		for k, sx := range columns {
+			s := pix[8*sx : 8*sx+8 : 8*sx+8]
+			planes[k] = T(uint32(s[0])<<8 | uint32(s[1]))
+			planes[n+k] = T(uint32(s[2])<<8 | uint32(s[3]))
+			planes[2*n+k] = T(uint32(s[4])<<8 | uint32(s[5]))
+			planes[3*n+k] = T(uint32(s[6])<<8 | uint32(s[7]))
+		}
+
+	case *image.NRGBA64:
+		pix := p.Pix[p.PixOffset(x0, y):]
+		for k, sx := range columns {
+			s := pix[8*sx : 8*sx+8 : 8*sx+8]
+			a := uint32(s[6])<<8 | uint32(s[7])
+			planes[k] = T((uint32(s[0])<<8 | uint32(s[1])) * a / 0xFFFF)
+			planes[n+k] = T((uint32(s[2])<<8 | uint32(s[3])) * a / 0xFFFF)
+			planes[2*n+k] = T((uint32(s[4])<<8 | uint32(s[5])) * a / 0xFFFF)
+			planes[3*n+k] = T(a)
+		}
+
+	case *image.Gray16:
+		pix := p.Pix[p.PixOffset(x0, y):]
+		for k, sx := range columns {
+			planes[k] = T(uint32(pix[2*sx])<<8 | uint32(pix[2*sx+1]))
+		}
+	}
+}
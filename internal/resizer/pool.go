@@ -0,0 +1,113 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"sync"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+)
+
+// bufferPool recycles slices by exact length, so a batch resizing to the
+// same size reuses its destination and intermediate buffers instead of
+// allocating them again; the garbage collector still empties idle pools
+type bufferPool[T any] struct {
+	pools sync.Map // length -> *sync.Pool of *[]T
+}
+
+var (
+	pixPool   bufferPool[uint8]   // Pix of 8 and 16-bit images
+	floatPool bufferPool[float32] // Pix of float images
+	rowPool   bufferPool[float64] // Intermediate filter rows
+	row32Pool bufferPool[float32] // Intermediate rows at float32 precision
+)
+
+// get returns a zeroed slice of length n
+func (p *bufferPool[T]) get(n int) []T {
+	if pool, ok := p.pools.Load(n); ok {
+		if s, ok := pool.(*sync.Pool).Get().(*[]T); ok {
+			clear(*s)
+			return *s
+		}
+	}
+
+	return make([]T, n)
+}
+
+// put hands s back for reuse; the caller must not touch it afterwards
+func (p *bufferPool[T]) put(s []T) {
+	// Assertion 1: Only whole buffers, never a window into a larger one
+	if len(s) == 0 || len(s) != cap(s) {
+		return
+	}
+
+	pool, _ := p.pools.LoadOrStore(len(s), &sync.Pool{})
+	pool.(*sync.Pool).Put(&s)
+}
+
+// newRGBA is image.NewRGBA drawing on pooled memory
+func newRGBA(r image.Rectangle) *image.RGBA {
+	return &image.RGBA{Pix: pixPool.get(4 * r.Dx() * r.Dy()), Stride: 4 * r.Dx(), Rect: r}
+}
+
+// newRGBA64 is image.NewRGBA64 drawing on pooled memory
+func newRGBA64(r image.Rectangle) *image.RGBA64 {
+	return &image.RGBA64{Pix: pixPool.get(8 * r.Dx() * r.Dy()), Stride: 8 * r.Dx(), Rect: r}
+}
+
+// newGray is image.NewGray drawing on pooled memory
+func newGray(r image.Rectangle) *image.Gray {
+	return &image.Gray{Pix: pixPool.get(r.Dx() * r.Dy()), Stride: r.Dx(), Rect: r}
+}
+
+// newGray16 is image.NewGray16 drawing on pooled memory
+func newGray16(r image.Rectangle) *image.Gray16 {
+	return &image.Gray16{Pix: pixPool.get(2 * r.Dx() * r.Dy()), Stride: 2 * r.Dx(), Rect: r}
+}
+
+// newFloat is hdr.NewRGBA drawing on pooled memory
+func newFloat(r image.Rectangle) *hdr.RGBA {
+	return &hdr.RGBA{Pix: floatPool.get(4 * r.Dx() * r.Dy()), Stride: 4 * r.Dx(), Rect: r}
+}
+
+// Release hands the pixel buffer of img back for reuse by later resizes,
+// which matters when many images are resized to the same size
+// img must be an image nothing reads any more, such as a Resize result
+// that has been encoded; Resize returns the source itself when it skips
+// an upscale, which must not be released while the caller still uses it
+// Sub-images and unknown types are ignored
+func Release(img image.Image) {
+	// Assertion 1: Only zero-origin images owning their whole buffer
+	if img == nil || img.Bounds().Min != (image.Point{}) {
+		return
+	}
+
+	height := img.Bounds().Dy()
+	switch p := img.(type) {
+	case *image.RGBA:
+		releasePix(p.Pix, p.Stride, height)
+	case *image.NRGBA:
+		releasePix(p.Pix, p.Stride, height)
+	case *image.RGBA64:
+		releasePix(p.Pix, p.Stride, height)
+	case *image.NRGBA64:
+		releasePix(p.Pix, p.Stride, height)
+	case *image.Gray:
+		releasePix(p.Pix, p.Stride, height)
+	case *image.Gray16:
+		releasePix(p.Pix, p.Stride, height)
+	case *image.Paletted:
+		releasePix(p.Pix, p.Stride, height)
+	case *hdr.RGBA:
+		if len(p.Pix) == p.Stride*height {
+			floatPool.put(p.Pix)
+		}
+	}
+}
+
+// releasePix pools pix when it holds exactly the rows of its image
+func releasePix(pix []uint8, stride, height int) {
+	if len(pix) == stride*height {
+		pixPool.put(pix)
+	}
+}
@@ -0,0 +1,92 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// Precision selects the sample type of the intermediate rows
+type Precision string
+
+const (
+	// PrecisionFloat64 keeps intermediate rows in float64 (default)
+	PrecisionFloat64 Precision = "float64"
+	// PrecisionFloat32 halves the intermediate rows and doubles the lanes
+	// of the vector loops; results can differ from float64 by one level
+	PrecisionFloat32 Precision = "float32"
+)
+
+var ErrUnknownPrecision = errors.New("unknown precision")
+
+// ParsePrecision converts a precision name into a Precision value
+func ParsePrecision(name string) (Precision, error) {
+	switch Precision(name) {
+	case PrecisionFloat64, PrecisionFloat32:
+		return Precision(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownPrecision, name)
+	}
+}
+
+// sample is the type of the intermediate rows of the planar paths
+type sample interface {
+	float32 | float64
+}
+
+// kernels are the convolution loops and row buffers of one sample type;
+// weights returns the taps of every entry of a weight table by index
+type kernels[T sample] struct {
+	dot       func(w, v []T) T
+	addScaled func(dst, src []T, w T)
+	weights   func(contribs []interpolation.Contribution) func(i int) []T
+	rows      *bufferPool[T]
+}
+
+var (
+	kernels64 = kernels[float64]{dot: dot, addScaled: addScaled, weights: weights64, rows: &rowPool}
+	kernels32 = kernels[float32]{dot: dot32, addScaled: addScaled32, weights: weights32, rows: &row32Pool}
+)
+
+// weights64 reads the weights of the table as they are
+func weights64(contribs []interpolation.Contribution) func(i int) []float64 {
+	return func(i int) []float64 {
+		return contribs[i].Weights
+	}
+}
+
+// weights32 rounds the weights of the table to float32 once per resize,
+// in one backing array; the cached tables stay float64
+func weights32(contribs []interpolation.Contribution) func(i int) []float32 {
+	total := 0
+	for _, c := range contribs {
+		total += len(c.Weights)
+	}
+
+	backing := make([]float32, total)
+	weights := make([][]float32, len(contribs))
+	for i, c := range contribs {
+		w := backing[:len(c.Weights):len(c.Weights)]
+		backing = backing[len(c.Weights):]
+		for k, v := range c.Weights {
+			w[k] = float32(v)
+		}
+
+		weights[i] = w
+	}
+
+	return func(i int) []float32 {
+		return weights[i]
+	}
+}
+
+// sampleBytes is the size of one intermediate sample at precision p
+func sampleBytes(p Precision) int64 {
+	if p == PrecisionFloat32 {
+		return 4
+	}
+
+	return 8
+}
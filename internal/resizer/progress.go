@@ -0,0 +1,56 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import "sync/atomic"
+
+// ProgressFunc receives how many of the total output rows of a resize
+// are done, for a progress bar over a large image
+// It is called from the resize workers, several at once when Jobs allows,
+// so it must be safe for concurrent use and may see counts out of order
+type ProgressFunc func(done, total int)
+
+// WithProgress returns a resizer reporting finished output rows to fn,
+// leaving r untouched; a nil fn reports nothing
+// Resizes made of several steps, such as supersampling, report every step
+// from zero, and a Stream reports each band it hands over
+func (r *Resizer) WithProgress(fn ProgressFunc) *Resizer {
+	active := *r
+	active.progress = fn
+	return &active
+}
+
+// quiet returns a resizer whose row loops report nothing, for the passes
+// that fill intermediate rows before the output ones
+func (r *Resizer) quiet() *Resizer {
+	if r.progress == nil {
+		return r
+	}
+
+	active := *r
+	active.progress = nil
+	return &active
+}
+
+// rowCounter counts the rows of one loop for the progress function
+type rowCounter struct {
+	fn    ProgressFunc
+	done  atomic.Int64
+	total int
+}
+
+// newRowCounter returns the counter of a loop over rows rows, nil when r
+// reports nothing
+func (r *Resizer) newRowCounter(rows int) *rowCounter {
+	if r.progress == nil {
+		return nil
+	}
+
+	return &rowCounter{fn: r.progress, total: rows}
+}
+
+// add counts one finished row
+func (c *rowCounter) add() {
+	if c != nil {
+		c.fn(int(c.done.Add(1)), c.total)
+	}
+}
@@ -0,0 +1,84 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/kasuraSH/kasurarykerion/internal/transform"
+)
+
+// ErrNotReducible is returned when the resize cannot start from a source
+// decoded at reduced size
+var ErrNotReducible = errors.New("cannot resize a reduced source")
+
+// reducedScales are the decoder reductions tried, largest first
+var reducedScales = [...]int{8, 4, 2}
+
+// ReducedScale returns the largest of 8, 4 and 2 by which a source of the
+// given size may be reduced while decoding, leaving the final pass at least
+// a 2x shrink on each axis, or 1 when no reduction applies
+func (r *Resizer) ReducedScale(srcWidth, srcHeight int) int {
+	// Assertion 1: Content analysis and linear-light output need every pixel
+	if r.reduceBlocker() != "" {
+		return 1
+	}
+
+	planned := r.withGeometry(srcWidth, srcHeight)
+	region := planned.regionFor(srcWidth, srcHeight)
+
+	for _, scale := range reducedScales {
+		s := float64(scale)
+		if region.width/s >= 2.0*float64(planned.config.TargetWidth) && region.height/s >= 2.0*float64(planned.config.TargetHeight) {
+			return scale
+		}
+	}
+
+	return 1
+}
+
+// ForReducedSource returns a resizer for the image of the given size
+// decoded at 1/scale, producing the output planned for the full image from
+// the matching fractional region of the reduced one
+func (r *Resizer) ForReducedSource(srcWidth, srcHeight, scale int) (*Resizer, error) {
+	// Assertion 1: Validate the reduction
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: scale 1/%d", ErrNotReducible, scale)
+	}
+
+	if reason := r.reduceBlocker(); reason != "" && scale > 1 {
+		return nil, fmt.Errorf("%w: %s needs the full image", ErrNotReducible, reason)
+	}
+
+	if scale == 1 {
+		return r, nil
+	}
+
+	planned := r.withGeometry(srcWidth, srcHeight)
+	region := planned.regionFor(srcWidth, srcHeight)
+	s := float64(scale)
+
+	// The output size is now fixed, so the sizing options are dropped
+	active := *planned
+	active.config.Mode = ModeStretch
+	active.config.Region = image.Rectangle{}
+	active.config.MaxEdge = 0
+	active.config.Megapixels = 0.0
+	active.config.Aspect = 0.0
+	active.region = sourceRegion{x: region.x / s, y: region.y / s, width: region.width / s, height: region.height / s}
+	return &active, nil
+}
+
+// reduceBlocker names the option that needs the full-size source, empty
+// when a reduced one will do
+func (r *Resizer) reduceBlocker() string {
+	switch {
+	case r.config.Mode == ModeFill && r.config.Anchor.Gravity == transform.GravitySmart && r.config.Anchor.Focal == nil:
+		return "smart gravity"
+	case r.config.Grayscale != 0:
+		return "grayscale output"
+	default:
+		return ""
+	}
+}
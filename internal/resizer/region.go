@@ -0,0 +1,119 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/kasuraSH/kasurarykerion/internal/transform"
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+// WithRegion returns a resizer that reads only rect of each source, given
+// relative to its top-left corner, without copying the region first
+func (r *Resizer) WithRegion(rect image.Rectangle) (*Resizer, error) {
+	// Assertion 1: Validate the rectangle
+	if err := validateRegion(rect); err != nil {
+		return nil, err
+	}
+
+	active := *r
+	active.config.Region = rect
+	return &active, nil
+}
+
+// validateRegion checks a configured source rectangle
+func validateRegion(rect image.Rectangle) error {
+	// Assertion 1: The rectangle starts inside the image
+	if rect.Min.X < 0 || rect.Min.Y < 0 {
+		return fmt.Errorf("%w: region %v starts outside the image", ErrInvalidBounds, rect)
+	}
+
+	// Assertion 2: The rectangle has a valid size
+	if err := validator.ValidateDimensions(rect.Dx(), rect.Dy()); err != nil {
+		return fmt.Errorf("%w: region %v: %v", ErrInvalidBounds, rect, err)
+	}
+
+	return nil
+}
+
+// hasRegion reports whether a source rectangle is configured
+func (r *Resizer) hasRegion() bool {
+	return !r.config.Region.Empty()
+}
+
+// roiFor returns the configured source rectangle as a region, or the full
+// image when none is set
+func (r *Resizer) roiFor(srcWidth, srcHeight int) sourceRegion {
+	// Assertion 1: Default to the whole source
+	if !r.hasRegion() {
+		return sourceRegion{width: float64(srcWidth), height: float64(srcHeight)}
+	}
+
+	rect := r.config.Region
+	return sourceRegion{
+		x:      float64(rect.Min.X),
+		y:      float64(rect.Min.Y),
+		width:  float64(rect.Dx()),
+		height: float64(rect.Dy()),
+	}
+}
+
+// checkRegion verifies the source rectangle lies inside the source
+func (r *Resizer) checkRegion(srcWidth, srcHeight int) error {
+	// Assertion 1: Validate the rectangle against the image
+	if r.hasRegion() && !r.config.Region.In(image.Rect(0, 0, srcWidth, srcHeight)) {
+		return fmt.Errorf("%w: region %v outside the %dx%d source", ErrInvalidBounds, r.config.Region, srcWidth, srcHeight)
+	}
+
+	return nil
+}
+
+// subImager is implemented by the standard library image types
+type subImager interface {
+	SubImage(rect image.Rectangle) image.Image
+}
+
+// regionView returns the part of src covered by the source rectangle for
+// whole-image analysis, sharing pixels with src when the type allows it
+func (r *Resizer) regionView(src image.Image) (image.Image, error) {
+	// Assertion 1: Prefer a view over a copy
+	if s, ok := src.(subImager); ok && r.hasRegion() {
+		return s.SubImage(r.config.Region.Add(src.Bounds().Min)), nil
+	}
+
+	return r.regionImage(src)
+}
+
+// regionImage returns a zero-origin copy of the part of src covered by the
+// source rectangle, or src itself when none is set
+func (r *Resizer) regionImage(src image.Image) (image.Image, error) {
+	// Assertion 1: Without a region the whole image is used
+	if !r.hasRegion() {
+		return src, nil
+	}
+
+	return transform.Crop(src, r.config.Region)
+}
+
+// cropToRegion copies the source rectangle out of src and returns a
+// resizer addressing the copy, so whole-image passes such as supersampling
+// only process the region
+func (r *Resizer) cropToRegion(src image.Image) (image.Image, *Resizer, error) {
+	// Assertion 1: Nothing to do without a region
+	if !r.hasRegion() {
+		return src, r, nil
+	}
+
+	cropped, err := r.regionImage(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	origin := r.config.Region.Min
+	active := *r
+	active.config.Region = image.Rectangle{}
+	active.region.x -= float64(origin.X)
+	active.region.y -= float64(origin.Y)
+	return cropped, &active, nil
+}
@@ -2,12 +2,16 @@
 package resizer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"math"
 
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
 	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+	"github.com/kasuraSH/kasurarykerion/internal/transform"
 	"github.com/kasuraSH/kasurarykerion/internal/validator"
 )
 
@@ -16,24 +20,103 @@ var (
 	ErrInvalidBounds  = errors.New("invalid image bounds")
 	ErrResizeFailed   = errors.New("resize operation failed")
 	ErrUnsupportedBit = errors.New("unsupported bit depth")
+	ErrUnknownFilter  = errors.New("unknown interpolation filter")
 )
 
+// Filter names a resampling kernel from the interpolation registry
+type Filter string
+
+const (
+	// FilterBicubic uses the Mitchell-Netravali cubic kernel (default)
+	FilterBicubic Filter = interpolation.NameBicubic
+	// FilterBilinear uses a triangle kernel, faster but softer
+	FilterBilinear Filter = interpolation.NameBilinear
+	// FilterCatmullRom uses the Catmull-Rom cubic kernel (B=0, C=0.5)
+	FilterCatmullRom Filter = interpolation.NameCatmullRom
+	// FilterGaussian uses a Gaussian kernel with a configurable sigma
+	FilterGaussian Filter = interpolation.NameGaussian
+	// FilterMagicKernelSharp2013 uses Costella's Magic Kernel Sharp 2013
+	FilterMagicKernelSharp2013 Filter = interpolation.NameMagicKernelSharp2013
+	// FilterMagicKernelSharp2021 uses Costella's Magic Kernel Sharp 2021
+	FilterMagicKernelSharp2021 Filter = interpolation.NameMagicKernelSharp2021
+	// FilterHermite uses the Hermite cubic kernel (B=0, C=0)
+	FilterHermite Filter = interpolation.NameHermite
+	// FilterSpline36 uses the 6-tap Spline36 kernel from video scalers
+	FilterSpline36 Filter = interpolation.NameSpline36
+	// FilterLanczos3 uses the 6-tap, 3-lobe Lanczos kernel
+	FilterLanczos3 Filter = interpolation.NameLanczos3
+)
+
+// ParseFilter converts a filter name into a Filter value
+func ParseFilter(name string) (Filter, error) {
+	if Filter(name) == FilterAuto {
+		return FilterAuto, nil
+	}
+
+	if _, err := interpolation.Lookup(name); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnknownFilter, name)
+	}
+
+	return Filter(name), nil
+}
+
+// RegisterFilter makes a custom kernel selectable by name
+func RegisterFilter(name string, k interpolation.Kernel) error {
+	return interpolation.Register(name, k)
+}
+
+// FilterNames returns the names of all selectable filters
+func FilterNames() []string {
+	return append([]string{string(FilterAuto)}, interpolation.Names()...)
+}
+
 // Config holds resize operation parameters
 type Config struct {
-	TargetWidth  int
-	TargetHeight int
-	Quality      int // 0-100, currently unused but reserved for future
+	TargetWidth  int     // Zero derives the width from the source aspect
+	TargetHeight int     // Zero derives the height from the source aspect
+	Quality      int     // Deprecated: resizing ignores it, encoders take imageio.JPEGOptions.Quality
+	Filter       Filter  // Interpolation kernel, defaults to bicubic
+	Sigma        float64 // Gaussian filter width, defaults to 0.5
+	AntiRinging  bool    // Clamp output to the range of contributing pixels
+	EWA          bool    // Use elliptical weighted average instead of separable filtering
+	Supersample  bool    // Pre-shrink by 2x area passes, lifting the MinScale limit
+	FixedPoint   bool    // Use 16.16 integer arithmetic for 8-bit images
+
+	Edge       interpolation.EdgeMode // Out-of-bounds sample policy, defaults to clamp
+	EdgeColor  color.Color            // Fill color for the constant edge mode
+	Mode       Mode                   // Target box handling, defaults to stretch
+	MaxEdge    int                    // Longest output side, replaces the target box when set
+	NoUpscale  UpscalePolicy          // What to do when the output would enlarge, defaults to allow
+	Anchor     transform.Anchor       // Part of the source kept by fill mode, defaults to centre
+	Aspect     float64                // Output width/height ratio, zero follows the source
+	Megapixels float64                // Output pixel count in millions, replaces the target box
+	Region     image.Rectangle        // Source rectangle to resize, empty means the whole image
+	MaxScale   float64                // Largest scale factor per axis, defaults to 16
+	MinScale   float64                // Smallest scale factor per axis, defaults to 1/16
+	Palette    PalettePolicy          // Output of paletted sources, defaults to true color
+	Dither     bool                   // Dither when re-quantizing to a palette
+	Grayscale  int                    // Gray output depth, 8 or 16, resized in linear light; zero keeps color
+	Jobs       int                    // Workers sharing the destination rows, zero uses GOMAXPROCS
+	Accel      Accel                  // Hardware running the convolution, defaults to the CPU
+	Precision  Precision              // Intermediate rows of the planar JPEG and 16-bit paths, defaults to float64
 }
 
 // Resizer handles image resizing operations
 type Resizer struct {
 	config Config
+	kernel interpolation.Kernel
+	filter Filter // Registered name of kernel, keying the weight table cache
+	region sourceRegion
+	origin image.Point
+	ctx    context.Context // Stops the row loops of a ResizeContext call, nil for Resize
+
+	progress ProgressFunc // Receives finished output rows, nil for none
 }
 
 // NewResizer creates a new resizer instance
 func NewResizer(cfg Config) (*Resizer, error) {
 	// Assertion 1: Validate target dimensions
-	if err := validator.ValidateDimensions(cfg.TargetWidth, cfg.TargetHeight); err != nil {
+	if err := validateTarget(cfg); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
@@ -42,375 +125,504 @@ func NewResizer(cfg Config) (*Resizer, error) {
 		cfg.Quality = 100 // Default to maximum quality
 	}
 
-	return &Resizer{config: cfg}, nil
-}
-
-// Resize performs the image resizing operation
-func (r *Resizer) Resize(src image.Image) (image.Image, error) {
-	// Assertion 1: Validate input image
-	if src == nil {
-		return nil, ErrNilImage
+	// Assertion 3: Validate filter selection
+	if cfg.Filter == "" {
+		cfg.Filter = FilterBicubic
 	}
 
-	bounds := src.Bounds()
-	srcWidth := bounds.Dx()
-	srcHeight := bounds.Dy()
+	// The auto mode resolves its kernel per image in Resize
+	lookup := cfg.Filter
+	if lookup == FilterAuto {
+		lookup = FilterBicubic
+	}
 
-	// Assertion 2: Validate source dimensions
-	if err := validator.ValidateDimensions(srcWidth, srcHeight); err != nil {
-		return nil, fmt.Errorf("invalid source dimensions: %w", err)
+	kernel, err := interpolation.Lookup(string(lookup))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w: %v", ErrUnknownFilter, err)
 	}
 
-	// Assertion 3: Validate resize ratio
-	if err := validator.ValidateResizeRatio(srcWidth, srcHeight, r.config.TargetWidth, r.config.TargetHeight); err != nil {
-		return nil, fmt.Errorf("invalid resize ratio: %w", err)
+	// Assertion 4: Validate Gaussian width
+	if cfg.Sigma == 0.0 {
+		cfg.Sigma = interpolation.DefaultGaussianSigma
 	}
 
-	// Determine bit depth and process accordingly
-	switch src.ColorModel() {
-	case color.RGBAModel, color.NRGBAModel:
-		return r.resizeRGBA(src, srcWidth, srcHeight)
-	case color.RGBA64Model, color.NRGBA64Model:
-		return r.resizeRGBA64(src, srcWidth, srcHeight)
-	case color.GrayModel:
-		return r.resizeGray(src, srcWidth, srcHeight)
-	case color.Gray16Model:
-		return r.resizeGray16(src, srcWidth, srcHeight)
-	default:
-		// Convert to RGBA for unsupported formats
-		return r.resizeRGBA(src, srcWidth, srcHeight)
+	if err := interpolation.ValidateGaussianSigma(cfg.Sigma); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
-}
 
-// resizeRGBA handles 8-bit RGBA images
-func (r *Resizer) resizeRGBA(src image.Image, srcWidth, srcHeight int) (*image.RGBA, error) {
-	// Assertion 1: Validate we can create destination image
-	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
-		return nil, err
+	// Assertion 5: Validate edge policy
+	if cfg.Edge == "" {
+		cfg.Edge = interpolation.EdgeClamp
 	}
 
-	dst := image.NewRGBA(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
+	if _, err := interpolation.ParseEdgeMode(string(cfg.Edge)); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-	xRatio := float64(srcWidth) / float64(r.config.TargetWidth)
-	yRatio := float64(srcHeight) / float64(r.config.TargetHeight)
+	if cfg.EdgeColor == nil {
+		cfg.EdgeColor = defaultEdgeColor
+	}
 
-	for y := 0; y < r.config.TargetHeight; y++ {
-		srcY := (float64(y) + 0.5) * yRatio
+	// Assertion 6: Validate resize mode
+	if cfg.Mode == "" {
+		cfg.Mode = ModeStretch
+	}
 
-		for x := 0; x < r.config.TargetWidth; x++ {
-			srcX := (float64(x) + 0.5) * xRatio
+	if _, err := ParseMode(string(cfg.Mode)); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-			// Process each color channel
-			r, g, b, a, err := r.sampleRGBA(src, srcX, srcY, srcWidth, srcHeight)
-			if err != nil {
-				return nil, fmt.Errorf("sampling failed at (%d,%d): %w", x, y, err)
-			}
+	// Assertion 7: Validate upscale policy
+	if cfg.NoUpscale == "" {
+		cfg.NoUpscale = UpscaleAllow
+	}
 
-			dst.SetRGBA(x, y, color.RGBA{R: r, G: g, B: b, A: a})
-		}
+	if _, err := ParseUpscalePolicy(string(cfg.NoUpscale)); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	return dst, nil
-}
+	// Assertion 8: Validate fill anchor and source region
+	if err := cfg.Anchor.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-// sampleRGBA performs bicubic sampling for RGBA channels
-func (r *Resizer) sampleRGBA(src image.Image, x, y float64, width, height int) (uint8, uint8, uint8, uint8, error) {
-	// Calculate kernel bounds
-	startX, endX, err := interpolation.CalculateKernelBounds(x, width)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	if !cfg.Region.Empty() {
+		if err := validateRegion(cfg.Region); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
 	}
 
-	startY, endY, err := interpolation.CalculateKernelBounds(y, height)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	// Assertion 9: Validate scale factor limits
+	if cfg.MaxScale == 0.0 {
+		cfg.MaxScale = validator.DefaultMaxScaleFactor
 	}
 
-	// Fractional parts for interpolation
-	dx := x - float64(int(x))
-	dy := y - float64(int(y))
+	if cfg.MinScale == 0.0 {
+		cfg.MinScale = validator.DefaultMinScaleFactor
+	}
 
-	var rPixels, gPixels, bPixels, aPixels [interpolation.KernelSize][interpolation.KernelSize]float64
+	if err := validator.ValidateScaleLimits(cfg.MinScale, cfg.MaxScale); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-	kernelY := 0
-	for srcY := startY; srcY < endY; srcY++ {
-		safeY := interpolation.GetSafeIndex(srcY, height)
-		kernelX := 0
+	// Assertion 10: Validate palette policy
+	if cfg.Palette == "" {
+		cfg.Palette = PaletteTrueColor
+	}
 
-		for srcX := startX; srcX < endX; srcX++ {
-			safeX := interpolation.GetSafeIndex(srcX, width)
+	if _, err := ParsePalettePolicy(string(cfg.Palette)); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-			c := src.At(safeX, safeY)
-			r32, g32, b32, a32 := c.RGBA()
+	// Assertion 11: Validate grayscale depth
+	if err := validateGrayscale(cfg.Grayscale); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-			// Convert from 16-bit to 8-bit
-			rPixels[kernelY][kernelX] = float64(r32 >> 8)
-			gPixels[kernelY][kernelX] = float64(g32 >> 8)
-			bPixels[kernelY][kernelX] = float64(b32 >> 8)
-			aPixels[kernelY][kernelX] = float64(a32 >> 8)
+	// Assertion 12: Validate worker count
+	if err := validateJobs(cfg.Jobs); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
-			kernelX++
-		}
-		kernelY++
+	// Assertion 13: Validate accelerator
+	if cfg.Accel == "" {
+		cfg.Accel = AccelCPU
 	}
 
-	// Perform bicubic interpolation for each channel
-	rVal, err := interpolation.InterpolateBicubic(rPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	if err := validateAccel(cfg.Accel); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	gVal, err := interpolation.InterpolateBicubic(gPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	// Assertion 14: Validate precision
+	if cfg.Precision == "" {
+		cfg.Precision = PrecisionFloat64
 	}
 
-	bVal, err := interpolation.InterpolateBicubic(bPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	if _, err := ParsePrecision(string(cfg.Precision)); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	aVal, err := interpolation.InterpolateBicubic(aPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	if _, ok := kernel.(interpolation.GaussianKernel); ok {
+		kernel = interpolation.GaussianKernel{Sigma: cfg.Sigma}
 	}
 
-	return interpolation.ClampUint8(rVal),
-		interpolation.ClampUint8(gVal),
-		interpolation.ClampUint8(bVal),
-		interpolation.ClampUint8(aVal),
-		nil
+	return &Resizer{config: cfg, kernel: kernel, filter: lookup}, nil
 }
 
-// resizeRGBA64 handles 16-bit RGBA images
-func (r *Resizer) resizeRGBA64(src image.Image, srcWidth, srcHeight int) (*image.RGBA64, error) {
-	// Assertion 1: Validate dimensions
-	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
-		return nil, err
-	}
+// validateTarget checks that exactly one way of sizing the output is used
+func validateTarget(cfg Config) error {
+	// Assertion 1: A pixel budget replaces the target box and longest edge
+	if cfg.Megapixels != 0.0 {
+		if err := validateMegapixels(cfg.Megapixels); err != nil {
+			return err
+		}
+
+		if cfg.TargetWidth != 0 || cfg.TargetHeight != 0 || cfg.MaxEdge != 0 {
+			return fmt.Errorf("%w: megapixels cannot be combined with width, height or max edge", ErrInvalidMegapixels)
+		}
 
-	dst := image.NewRGBA64(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
+		if cfg.Aspect != 0.0 {
+			return validateAspect(cfg.Aspect)
+		}
 
-	xRatio := float64(srcWidth) / float64(r.config.TargetWidth)
-	yRatio := float64(srcHeight) / float64(r.config.TargetHeight)
+		return nil
+	}
 
-	for y := 0; y < r.config.TargetHeight; y++ {
-		srcY := (float64(y) + 0.5) * yRatio
+	// Assertion 2: An aspect ratio replaces one dimension, or both in fill mode
+	if cfg.Aspect != 0.0 {
+		if err := validateAspect(cfg.Aspect); err != nil {
+			return err
+		}
 
-		for x := 0; x < r.config.TargetWidth; x++ {
-			srcX := (float64(x) + 0.5) * xRatio
+		if cfg.TargetWidth != 0 && cfg.TargetHeight != 0 {
+			return fmt.Errorf("%w: aspect cannot be combined with both width and height", ErrInvalidAspect)
+		}
 
-			r, g, b, a, err := r.sampleRGBA64(src, srcX, srcY, srcWidth, srcHeight)
-			if err != nil {
-				return nil, fmt.Errorf("sampling failed at (%d,%d): %w", x, y, err)
+		if cfg.TargetWidth == 0 && cfg.TargetHeight == 0 && cfg.MaxEdge == 0 {
+			if cfg.Mode != ModeFill {
+				return fmt.Errorf("%w: aspect without a dimension needs fill mode", ErrInvalidAspect)
 			}
 
-			dst.SetRGBA64(x, y, color.RGBA64{R: r, G: g, B: b, A: a})
+			return nil
 		}
 	}
 
-	return dst, nil
-}
+	// Assertion 3: The longest-edge constraint replaces the target box
+	if cfg.MaxEdge != 0 {
+		if cfg.TargetWidth != 0 || cfg.TargetHeight != 0 {
+			return fmt.Errorf("%w: max edge cannot be combined with width or height", validator.ErrInvalidDimension)
+		}
 
-// sampleRGBA64 performs bicubic sampling for 16-bit RGBA
-func (r *Resizer) sampleRGBA64(src image.Image, x, y float64, width, height int) (uint16, uint16, uint16, uint16, error) {
-	startX, endX, err := interpolation.CalculateKernelBounds(x, width)
-	if err != nil {
-		return 0, 0, 0, 0, err
+		return validator.ValidateDimensions(cfg.MaxEdge, cfg.MaxEdge)
 	}
 
-	startY, endY, err := interpolation.CalculateKernelBounds(y, height)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	return validator.ValidateTargetDimensions(cfg.TargetWidth, cfg.TargetHeight)
+}
+
+// Resize performs the image resizing operation
+// src may have any bounds, such as a SubImage; the result starts at (0, 0)
+func (r *Resizer) Resize(src image.Image) (image.Image, error) {
+	// Assertion 1: Validate input image
+	if src == nil {
+		return nil, ErrNilImage
 	}
 
-	dx := x - float64(int(x))
-	dy := y - float64(int(y))
+	bounds := src.Bounds()
+	srcWidth := bounds.Dx()
+	srcHeight := bounds.Dy()
+
+	// Assertion 2: Validate source dimensions
+	if err := validator.ValidateDimensions(srcWidth, srcHeight); err != nil {
+		return nil, fmt.Errorf("invalid source dimensions: %w", err)
+	}
 
-	var rPixels, gPixels, bPixels, aPixels [interpolation.KernelSize][interpolation.KernelSize]float64
+	if err := r.checkRegion(srcWidth, srcHeight); err != nil {
+		return nil, err
+	}
 
-	kernelY := 0
-	for srcY := startY; srcY < endY; srcY++ {
-		safeY := interpolation.GetSafeIndex(srcY, height)
-		kernelX := 0
+	// Remember the palette before supersampling converts the source
+	palette := r.sourcePalette(src)
 
-		for srcX := startX; srcX < endX; srcX++ {
-			safeX := interpolation.GetSafeIndex(srcX, width)
+	// Derive the output size and source region from the mode
+	withContent, err := r.withContent(src)
+	if err != nil {
+		return nil, err
+	}
 
-			c := src.At(safeX, safeY)
-			r32, g32, b32, a32 := c.RGBA()
+	planned := withContent.withGeometry(srcWidth, srcHeight)
+	regionWidth, regionHeight := planned.regionFor(srcWidth, srcHeight).regionSize()
 
-			rPixels[kernelY][kernelX] = float64(r32)
-			gPixels[kernelY][kernelX] = float64(g32)
-			bPixels[kernelY][kernelX] = float64(b32)
-			aPixels[kernelY][kernelX] = float64(a32)
+	// Honour the upscale guard before doing any work
+	if planned.skipsUpscale(srcWidth, srcHeight) {
+		copied, err := planned.regionImage(src)
+		if err != nil || planned.config.Grayscale == 0 {
+			return copied, err
+		}
 
-			kernelX++
+		lum := linearLuminance(copied)
+		if copied != src {
+			Release(copied)
 		}
-		kernelY++
+
+		gray := encodeGray(lum, planned.config.Grayscale)
+		Release(lum)
+		return gray, nil
 	}
 
-	rVal, err := interpolation.InterpolateBicubic(rPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	if planned.refusesUpscale(srcWidth, srcHeight) {
+		return nil, ErrUpscaleRefused
 	}
 
-	gVal, err := interpolation.InterpolateBicubic(gPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	// Assertion 3: Validate resize ratio
+	if err := planned.validateRatio(regionWidth, regionHeight); err != nil {
+		return nil, fmt.Errorf("invalid resize ratio: %w", err)
 	}
 
-	bVal, err := interpolation.InterpolateBicubic(bPixels, dx, dy)
-	if err != nil {
-		return 0, 0, 0, 0, err
+	// Shrink in 2x area passes first so the final pass stays artifact-free
+	if planned.config.Supersample {
+		original := src
+		src, planned, err = planned.cropToRegion(src)
+		if err != nil {
+			return nil, err
+		}
+
+		// The region copy and the reduced source are only read here
+		if src != original {
+			defer Release(src)
+		}
+		cropped := src
+
+		bounds = src.Bounds()
+		srcWidth = bounds.Dx()
+		srcHeight = bounds.Dy()
+
+		src = planned.supersample(src, srcWidth, srcHeight)
+		if src != cropped {
+			defer Release(src)
+		}
+
+		bounds = src.Bounds()
+		planned = planned.withScaledRegion(float64(bounds.Dx())/float64(srcWidth), float64(bounds.Dy())/float64(srcHeight))
+		srcWidth = bounds.Dx()
+		srcHeight = bounds.Dy()
+		regionWidth, regionHeight = planned.regionFor(srcWidth, srcHeight).regionSize()
 	}
 
-	aVal, err := interpolation.InterpolateBicubic(aPixels, dx, dy)
+	// Assertion 4: Resolve the kernel for this image
+	active, err := planned.forSource(regionWidth, regionHeight)
 	if err != nil {
-		return 0, 0, 0, 0, err
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFilter, err)
 	}
 
-	return interpolation.ClampUint16(rVal),
-		interpolation.ClampUint16(gVal),
-		interpolation.ClampUint16(bVal),
-		interpolation.ClampUint16(aVal),
-		nil
-}
+	// Sample relative to the source bounds, which SubImage may offset
+	active = active.withOrigin(bounds.Min)
 
-// resizeGray handles 8-bit grayscale images
-func (r *Resizer) resizeGray(src image.Image, srcWidth, srcHeight int) (*image.Gray, error) {
-	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
-		return nil, err
+	// Gray output replaces every color path, palettes included
+	if active.config.Grayscale != 0 {
+		return active.resizeGrayscale(src, srcWidth, srcHeight)
 	}
 
-	dst := image.NewGray(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
+	// Resample paletted sources in full color, then map back to a palette
+	if palette != nil {
+		dst, err := active.resizeRGBA(src, srcWidth, srcHeight)
+		if err != nil {
+			return nil, err
+		}
 
-	xRatio := float64(srcWidth) / float64(r.config.TargetWidth)
-	yRatio := float64(srcHeight) / float64(r.config.TargetHeight)
+		defer Release(dst)
+		return active.requantize(dst, palette)
+	}
 
-	for y := 0; y < r.config.TargetHeight; y++ {
-		srcY := (float64(y) + 0.5) * yRatio
+	// Identity resizes and exact 1/2 or 1/4 box reductions skip the kernel
+	if dst, ok := active.resizeFast(src, srcWidth, srcHeight); ok {
+		return dst, nil
+	}
 
-		for x := 0; x < r.config.TargetWidth; x++ {
-			srcX := (float64(x) + 0.5) * xRatio
+	// The GPU, when selected with an opencl build, takes plain separable
+	// convolutions
+	if dst, ok, err := active.resizeAccelerated(src, srcWidth, srcHeight); ok {
+		return dst, err
+	}
 
-			grayVal, err := r.sampleGray(src, srcX, srcY, srcWidth, srcHeight)
-			if err != nil {
-				return nil, fmt.Errorf("sampling failed at (%d,%d): %w", x, y, err)
-			}
+	// A native backend, built in with the vips tag, takes the
+	// configurations it implements
+	if dst, ok, err := active.resizeNative(src, srcWidth, srcHeight); ok {
+		return dst, err
+	}
 
-			dst.SetGray(x, y, color.Gray{Y: grayVal})
+	// Determine bit depth and process accordingly
+	switch src.ColorModel() {
+	case color.RGBAModel, color.NRGBAModel:
+		return active.resizeRGBA(src, srcWidth, srcHeight)
+	case color.RGBA64Model, color.NRGBA64Model:
+		return active.resizeRGBA64(src, srcWidth, srcHeight)
+	case color.GrayModel:
+		return active.resizeGray(src, srcWidth, srcHeight)
+	case color.Gray16Model:
+		return active.resizeGray16(src, srcWidth, srcHeight)
+	case hdr.ColorModel:
+		return active.resizeFloat(src, srcWidth, srcHeight)
+	case color.YCbCrModel, color.CMYKModel:
+		if ycc, ok := active.planarYCbCr(src); ok {
+			return active.resizeYCbCr(ycc, srcWidth, srcHeight)
 		}
+
+		// JPEG pixels are converted up front rather than at every tap
+		rgba := toRGBA(src)
+		defer Release(rgba)
+		return active.resizeRGBA(rgba, srcWidth, srcHeight)
+	default:
+		// Convert to RGBA for unsupported formats
+		return active.resizeRGBA(src, srcWidth, srcHeight)
 	}
+}
 
-	return dst, nil
+// validateRatio checks the scale factors allowed by the configured limits
+// and pipeline, supersampling lifts the downscale limit
+func (r *Resizer) validateRatio(srcWidth, srcHeight int) error {
+	minScale := r.config.MinScale
+	if r.config.Supersample {
+		minScale = 0.0
+	}
+
+	return validator.ValidateScaleRatio(srcWidth, srcHeight, r.config.TargetWidth, r.config.TargetHeight, minScale, r.config.MaxScale)
 }
 
-// sampleGray performs bicubic sampling for grayscale
-func (r *Resizer) sampleGray(src image.Image, x, y float64, width, height int) (uint8, error) {
-	startX, endX, err := interpolation.CalculateKernelBounds(x, width)
-	if err != nil {
-		return 0, err
+// resizeRGBA handles 8-bit RGBA images
+func (r *Resizer) resizeRGBA(src image.Image, srcWidth, srcHeight int) (*image.RGBA, error) {
+	// Assertion 1: Validate we can create destination image
+	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
+		return nil, err
 	}
 
-	startY, endY, err := interpolation.CalculateKernelBounds(y, height)
+	dst := newRGBA(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
+
+	sampler, err := r.new8BitSampler(srcWidth, srcHeight)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	dx := x - float64(int(x))
-	dy := y - float64(int(y))
+	r.forEachRow(r.config.TargetHeight, func(y int) {
+		for x := 0; x < r.config.TargetWidth; x++ {
+			// Process each color channel
+			v := sampler.sample(src, x, y, 8)
+
+			dst.SetRGBA(x, y, color.RGBA{
+				R: interpolation.ClampUint8(v[0]),
+				G: interpolation.ClampUint8(v[1]),
+				B: interpolation.ClampUint8(v[2]),
+				A: interpolation.ClampUint8(v[3]),
+			})
+		}
+	})
+
+	return dst, nil
+}
 
-	var pixels [interpolation.KernelSize][interpolation.KernelSize]float64
+// resizeRGBA64 handles 16-bit RGBA images
+func (r *Resizer) resizeRGBA64(src image.Image, srcWidth, srcHeight int) (*image.RGBA64, error) {
+	// Assertion 1: Validate dimensions
+	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
+		return nil, err
+	}
 
-	kernelY := 0
-	for srcY := startY; srcY < endY; srcY++ {
-		safeY := interpolation.GetSafeIndex(srcY, height)
-		kernelX := 0
+	dst := newRGBA64(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
 
-		for srcX := startX; srcX < endX; srcX++ {
-			safeX := interpolation.GetSafeIndex(srcX, width)
-			c := src.At(safeX, safeY)
-			gray, _, _, _ := c.RGBA()
-			pixels[kernelY][kernelX] = float64(gray >> 8)
-			kernelX++
+	// Plain RGBA64 and NRGBA64 images are resampled from channel planes
+	if r.planar16(src) {
+		err := r.planes16(src, srcWidth, srcHeight, 4, storeRGBA64[float32](dst), storeRGBA64[float64](dst))
+		if err != nil {
+			return nil, err
 		}
-		kernelY++
+
+		return dst, nil
 	}
 
-	val, err := interpolation.InterpolateBicubic(pixels, dx, dy)
+	sampler, err := r.newSampler(srcWidth, srcHeight)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return interpolation.ClampUint8(val), nil
+	r.forEachRow(r.config.TargetHeight, func(y int) {
+		for x := 0; x < r.config.TargetWidth; x++ {
+			v := sampler.sample(src, x, y, 0)
+
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: interpolation.ClampUint16(v[0]),
+				G: interpolation.ClampUint16(v[1]),
+				B: interpolation.ClampUint16(v[2]),
+				A: interpolation.ClampUint16(v[3]),
+			})
+		}
+	})
+
+	return dst, nil
 }
 
-// resizeGray16 handles 16-bit grayscale images
-func (r *Resizer) resizeGray16(src image.Image, srcWidth, srcHeight int) (*image.Gray16, error) {
+// resizeGray handles 8-bit grayscale images
+func (r *Resizer) resizeGray(src image.Image, srcWidth, srcHeight int) (*image.Gray, error) {
 	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
 		return nil, err
 	}
 
-	dst := image.NewGray16(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
-
-	xRatio := float64(srcWidth) / float64(r.config.TargetWidth)
-	yRatio := float64(srcHeight) / float64(r.config.TargetHeight)
+	dst := newGray(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
 
-	for y := 0; y < r.config.TargetHeight; y++ {
-		srcY := (float64(y) + 0.5) * yRatio
+	sampler, err := r.new8BitSampler(srcWidth, srcHeight)
+	if err != nil {
+		return nil, err
+	}
 
+	r.forEachRow(r.config.TargetHeight, func(y int) {
 		for x := 0; x < r.config.TargetWidth; x++ {
-			srcX := (float64(x) + 0.5) * xRatio
+			v := sampler.sample(src, x, y, 8)
 
-			grayVal, err := r.sampleGray16(src, srcX, srcY, srcWidth, srcHeight)
-			if err != nil {
-				return nil, fmt.Errorf("sampling failed at (%d,%d): %w", x, y, err)
-			}
-
-			dst.SetGray16(x, y, color.Gray16{Y: grayVal})
+			dst.SetGray(x, y, color.Gray{Y: interpolation.ClampUint8(v[0])})
 		}
-	}
+	})
 
 	return dst, nil
 }
 
-// sampleGray16 performs bicubic sampling for 16-bit grayscale
-func (r *Resizer) sampleGray16(src image.Image, x, y float64, width, height int) (uint16, error) {
-	startX, endX, err := interpolation.CalculateKernelBounds(x, width)
-	if err != nil {
-		return 0, err
+// resizeGray16 handles 16-bit grayscale images
+func (r *Resizer) resizeGray16(src image.Image, srcWidth, srcHeight int) (*image.Gray16, error) {
+	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
+		return nil, err
 	}
 
-	startY, endY, err := interpolation.CalculateKernelBounds(y, height)
+	dst := newGray16(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
+
+	if r.planar16(src) {
+		err := r.planes16(src, srcWidth, srcHeight, 1, storeGray16[float32](dst), storeGray16[float64](dst))
+		if err != nil {
+			return nil, err
+		}
+
+		return dst, nil
+	}
+
+	sampler, err := r.newSampler(srcWidth, srcHeight)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	dx := x - float64(int(x))
-	dy := y - float64(int(y))
+	r.forEachRow(r.config.TargetHeight, func(y int) {
+		for x := 0; x < r.config.TargetWidth; x++ {
+			v := sampler.sample(src, x, y, 0)
 
-	var pixels [interpolation.KernelSize][interpolation.KernelSize]float64
+			dst.SetGray16(x, y, color.Gray16{Y: interpolation.ClampUint16(v[0])})
+		}
+	})
 
-	kernelY := 0
-	for srcY := startY; srcY < endY; srcY++ {
-		safeY := interpolation.GetSafeIndex(srcY, height)
-		kernelX := 0
+	return dst, nil
+}
 
-		for srcX := startX; srcX < endX; srcX++ {
-			safeX := interpolation.GetSafeIndex(srcX, width)
-			c := src.At(safeX, safeY)
-			gray, _, _, _ := c.RGBA()
-			pixels[kernelY][kernelX] = float64(gray)
-			kernelX++
-		}
-		kernelY++
+// resizeFloat handles float32 high dynamic range images, keeping color
+// values beyond white and below black instead of clipping them
+// Alpha is still clamped to [0, 1]
+func (r *Resizer) resizeFloat(src image.Image, srcWidth, srcHeight int) (*hdr.RGBA, error) {
+	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
+		return nil, err
 	}
 
-	val, err := interpolation.InterpolateBicubic(pixels, dx, dy)
+	dst := newFloat(image.Rect(0, 0, r.config.TargetWidth, r.config.TargetHeight))
+
+	sampler, err := r.newSampler(srcWidth, srcHeight)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return interpolation.ClampUint16(val), nil
+	r.forEachRow(r.config.TargetHeight, func(y int) {
+		for x := 0; x < r.config.TargetWidth; x++ {
+			v := sampler.sample(src, x, y, 0)
+
+			dst.SetFloat(x, y, hdr.Color{
+				R: float32(v[0] / 0xFFFF),
+				G: float32(v[1] / 0xFFFF),
+				B: float32(v[2] / 0xFFFF),
+				A: float32(math.Min(math.Max(v[3]/0xFFFF, 0), 1)),
+			})
+		}
+	})
+
+	return dst, nil
 }
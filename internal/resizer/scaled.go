@@ -0,0 +1,115 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// pixelSampler computes filtered channel values for one destination pixel,
+// each source channel being right-shifted by shift before accumulation
+// The constructors validate everything up front, so sampling cannot fail
+// for destination pixels inside the target
+type pixelSampler interface {
+	sample(src image.Image, dstX, dstY int, shift uint) [4]float64
+}
+
+// newSampler picks the sampler for the configured mode
+func (r *Resizer) newSampler(srcWidth, srcHeight int) (pixelSampler, error) {
+	// Assertion 1: EWA handles every scale factor itself
+	if r.config.EWA {
+		return r.newEWASampler(srcWidth, srcHeight)
+	}
+
+	return r.newTableSampler(srcWidth, srcHeight)
+}
+
+// tableSampler applies the kernel separably using contribution tables that
+// are computed once per resize instead of once per pixel and channel
+// The kernel is widened by the shrink factor so that every source pixel
+// contributes when downscaling
+type tableSampler struct {
+	xContribs   []interpolation.Contribution
+	yContribs   []interpolation.Contribution
+	edge        edgeReader
+	antiRinging bool
+}
+
+// newTableSampler precomputes the per-column and per-row weight tables, or
+// takes them from an earlier resize of the same geometry
+func (r *Resizer) newTableSampler(srcWidth, srcHeight int) (*tableSampler, error) {
+	region := r.regionFor(srcWidth, srcHeight)
+
+	xContribs, err := r.axisContributions(srcWidth, region.x, region.width, r.config.TargetWidth)
+	if err != nil {
+		return nil, err
+	}
+
+	yContribs, err := r.axisContributions(srcHeight, region.y, region.height, r.config.TargetHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assertion 1: The tables must cover the target
+	if len(xContribs) != r.config.TargetWidth || len(yContribs) != r.config.TargetHeight {
+		return nil, fmt.Errorf("%w: contribution tables do not match the target", ErrResizeFailed)
+	}
+
+	return &tableSampler{
+		xContribs:   xContribs,
+		yContribs:   yContribs,
+		edge:        r.newEdgeReader(srcWidth, srcHeight),
+		antiRinging: r.config.AntiRinging,
+	}, nil
+}
+
+// sample implements pixelSampler
+func (s *tableSampler) sample(src image.Image, dstX, dstY int, shift uint) [4]float64 {
+	var result, lo, hi [4]float64
+	for c := 0; c < len(result); c++ {
+		lo[c] = math.Inf(1)
+		hi[c] = math.Inf(-1)
+	}
+
+	xc := s.xContribs[dstX]
+	yc := s.yContribs[dstY]
+
+	for j := 0; j < len(yc.Weights); j++ {
+		wy := yc.Weights[j]
+		if wy == 0.0 {
+			continue
+		}
+
+		var row [4]float64
+		for i := 0; i < len(xc.Weights); i++ {
+			wx := xc.Weights[i]
+			if wx == 0.0 {
+				continue
+			}
+
+			px := s.edge.pixel(src, xc.Start+i, yc.Start+j, shift)
+
+			for c := 0; c < len(row); c++ {
+				row[c] += px[c] * wx
+				lo[c] = math.Min(lo[c], px[c])
+				hi[c] = math.Max(hi[c], px[c])
+			}
+		}
+
+		for c := 0; c < len(result); c++ {
+			result[c] += row[c] * wy
+		}
+	}
+
+	// Suppress halos from negative kernel lobes when requested
+	if s.antiRinging {
+		for c := 0; c < len(result); c++ {
+			result[c] = interpolation.ClampRange(result[c], lo[c], hi[c])
+		}
+	}
+
+	return result
+}
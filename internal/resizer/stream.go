@@ -0,0 +1,488 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+	"github.com/kasuraSH/kasurarykerion/internal/transform"
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+var (
+	ErrNotStreamable = errors.New("cannot resize as a stream")
+	ErrMemoryBudget  = errors.New("memory budget too small")
+)
+
+// streamStripBytes is the size of the output bands a stream aims for,
+// smaller when the memory budget asks for it
+const streamStripBytes = 1 << 20
+
+// RowSource yields a zero-origin image top to bottom in bands of rows,
+// such as the strips of a TIFF file
+type RowSource interface {
+	Bounds() image.Rectangle
+	ColorModel() color.Model
+	// BandHeight is the row count of every band but the last
+	BandHeight() int
+	// Next returns the next band, whose bounds place it in the image, and
+	// io.EOF after the last one
+	Next() (image.Image, error)
+}
+
+// RowSink takes the output of a stream as zero-origin bands, top to
+// bottom, all of Stream.BandHeight rows but the last
+type RowSink interface {
+	WriteRows(band image.Image) error
+}
+
+// Stream resizes a RowSource into a RowSink a band at a time, holding
+// each horizontally filtered source row only until the last output row
+// reading it is done, so memory follows the filter window rather than the
+// image size
+// Results match Resize for the same source and configuration
+type Stream struct {
+	active   *Resizer
+	src      RowSource
+	table    *tableSampler
+	shift    uint
+	bandRows int   // Output rows per band
+	peak     int64 // Estimated bytes held at once
+	needs    []int // Last source row each output row reads
+	lastUse  []int // Last output row reading each source row, -1 for none
+	expiry   []int // Read source rows ordered by lastUse
+	expires  []int // expiry[expires[dy]:expires[dy+1]] are freed after row dy
+
+	progress ProgressFunc // Receives the rows handed over, nil for none
+}
+
+// NewStream plans the resize of src as a stream whose estimated peak
+// memory stays within budget bytes, zero meaning no limit
+// Configurations that look at the whole source, such as EWA, anti-ringing,
+// supersampling, grayscale output or smart gravity, cannot stream
+func (r *Resizer) NewStream(src RowSource, budget int64) (*Stream, error) {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	// Assertion 1: Validate the source
+	if bounds.Min != (image.Point{}) || src.BandHeight() <= 0 {
+		return nil, fmt.Errorf("%w: stream source %v in bands of %d rows", ErrInvalidBounds, bounds, src.BandHeight())
+	}
+
+	if err := validator.ValidateDimensions(srcWidth, srcHeight); err != nil {
+		return nil, fmt.Errorf("invalid source dimensions: %w", err)
+	}
+
+	if err := r.checkRegion(srcWidth, srcHeight); err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: Only the separable filter reads rows in order
+	if err := r.streamable(src.ColorModel()); err != nil {
+		return nil, err
+	}
+
+	planned := r.withGeometry(srcWidth, srcHeight)
+	if planned.skipsUpscale(srcWidth, srcHeight) {
+		return nil, fmt.Errorf("%w: the no-upscale policy copies the source", ErrNotStreamable)
+	}
+
+	if planned.refusesUpscale(srcWidth, srcHeight) {
+		return nil, ErrUpscaleRefused
+	}
+
+	// Assertion 3: Validate resize ratio
+	regionWidth, regionHeight := planned.regionFor(srcWidth, srcHeight).regionSize()
+	if err := planned.validateRatio(regionWidth, regionHeight); err != nil {
+		return nil, fmt.Errorf("invalid resize ratio: %w", err)
+	}
+
+	active, err := planned.forSource(regionWidth, regionHeight)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownFilter, err)
+	}
+
+	table, err := active.newTableSampler(srcWidth, srcHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// The band loops report nothing, RunContext counts the rows handed over
+	s := &Stream{active: active.quiet(), src: src, table: table, shift: 8, progress: active.progress}
+	if isDeepModel(src.ColorModel()) {
+		s.shift = 0
+	}
+
+	s.schedule(srcHeight)
+	if err := s.plan(budget); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// streamable reports configurations that need more than rows in order
+func (r *Resizer) streamable(model color.Model) error {
+	cfg := r.config
+
+	var reason string
+	switch {
+	case cfg.EWA:
+		reason = "EWA"
+	case cfg.AntiRinging:
+		reason = "anti-ringing"
+	case cfg.Supersample:
+		reason = "supersampling"
+	case cfg.FixedPoint:
+		reason = "fixed point"
+	case cfg.Grayscale != 0:
+		reason = "grayscale output"
+	case cfg.Accel == AccelGPU:
+		reason = "GPU acceleration"
+	case cfg.Edge == interpolation.EdgeConstant:
+		reason = "the constant edge mode"
+	case cfg.Mode == ModeFill && cfg.Anchor.Gravity == transform.GravitySmart && cfg.Anchor.Focal == nil:
+		reason = "smart gravity"
+	}
+
+	switch model {
+	case color.RGBAModel, color.NRGBAModel, color.RGBA64Model, color.NRGBA64Model, color.GrayModel, color.Gray16Model:
+	default:
+		reason = "the source color model"
+	}
+
+	if reason != "" {
+		return fmt.Errorf("%w: %s needs the whole image", ErrNotStreamable, reason)
+	}
+
+	return nil
+}
+
+// isDeepModel reports whether resizing keeps 16 bits per channel
+func isDeepModel(model color.Model) bool {
+	return model == color.RGBA64Model || model == color.NRGBA64Model || model == color.Gray16Model
+}
+
+// schedule records which source rows every output row reads and when each
+// source row can be dropped
+func (s *Stream) schedule(srcHeight int) {
+	mode := s.table.edge.mode
+	s.needs = make([]int, len(s.table.yContribs))
+	s.lastUse = make([]int, srcHeight)
+	for sy := range s.lastUse {
+		s.lastUse[sy] = -1
+	}
+
+	for dy, yc := range s.table.yContribs {
+		for j, w := range yc.Weights {
+			if w == 0.0 {
+				continue
+			}
+
+			sy, _ := interpolation.ResolveIndex(yc.Start+j, srcHeight, mode)
+			s.lastUse[sy] = dy
+			s.needs[dy] = max(s.needs[dy], sy)
+		}
+	}
+
+	// Bucket the source rows by the output row that frees them
+	s.expires = make([]int, len(s.needs)+1)
+	for _, dy := range s.lastUse {
+		if dy >= 0 {
+			s.expires[dy+1]++
+		}
+	}
+
+	for dy := 1; dy < len(s.expires); dy++ {
+		s.expires[dy] += s.expires[dy-1]
+	}
+
+	s.expiry = make([]int, s.expires[len(s.needs)])
+	fill := append([]int(nil), s.expires[:len(s.needs)]...)
+	for sy, dy := range s.lastUse {
+		if dy >= 0 {
+			s.expiry[fill[dy]] = sy
+			fill[dy]++
+		}
+	}
+}
+
+// plan picks the output band height and checks the estimated peak memory
+// against budget: the filtered rows alive at once, a decoded source band
+// with its raw samples, and an output band with its samples and
+// compressed copy in the sink
+func (s *Stream) plan(budget int64) error {
+	cfg := s.active.config
+	bounds := s.src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	band := s.src.BandHeight()
+
+	// Replay the schedule to find the most rows cached at once
+	cached, peakRows, dy := 0, 0, 0
+	for top := 0; top < srcHeight; top += band {
+		bottom := min(top+band, srcHeight)
+		for sy := top; sy < bottom; sy++ {
+			if s.lastUse[sy] >= 0 {
+				cached++
+			}
+		}
+
+		peakRows = max(peakRows, cached)
+		for ; dy < len(s.needs) && s.needs[dy] < bottom; dy++ {
+			cached -= s.expires[dy+1] - s.expires[dy]
+		}
+	}
+
+	var taps int64
+	for _, c := range s.table.xContribs {
+		taps += int64(len(c.Weights))
+	}
+	for _, c := range s.table.yContribs {
+		taps += int64(len(c.Weights))
+	}
+
+	fixed := int64(peakRows) * int64(4*8*cfg.TargetWidth)
+	fixed += 2 * int64(min(band, srcHeight)) * int64(srcWidth) * int64(pixelBytes(s.src.ColorModel()))
+	fixed += 8*taps + 8*int64(3*srcHeight+2*cfg.TargetHeight)
+
+	outRow := int64(cfg.TargetWidth) * int64(pixelBytes(s.ColorModel()))
+	rows := min(max(streamStripBytes/outRow, 1), int64(cfg.TargetHeight))
+
+	// Assertion 1: At least one output row must fit beside the window
+	if budget > 0 {
+		spare := (budget - fixed) / (3 * outRow)
+		if spare < 1 {
+			return fmt.Errorf("%w: streaming this resize needs about %d MiB", ErrMemoryBudget, (fixed+3*outRow)>>20+1)
+		}
+
+		rows = min(rows, spare)
+	}
+
+	s.bandRows = int(rows)
+	s.peak = fixed + 3*rows*outRow
+	return nil
+}
+
+// Size returns the output width and height
+func (s *Stream) Size() (int, int) {
+	return s.active.config.TargetWidth, s.active.config.TargetHeight
+}
+
+// ColorModel returns the model of the output bands, the one Resize
+// returns for the source
+func (s *Stream) ColorModel() color.Model {
+	switch s.src.ColorModel() {
+	case color.GrayModel:
+		return color.GrayModel
+	case color.Gray16Model:
+		return color.Gray16Model
+	case color.RGBA64Model, color.NRGBA64Model:
+		return color.RGBA64Model
+	default:
+		return color.RGBAModel
+	}
+}
+
+// BandHeight returns the rows of every output band but the last
+func (s *Stream) BandHeight() int {
+	return s.bandRows
+}
+
+// Peak returns the estimated bytes the stream holds at its largest
+func (s *Stream) Peak() int64 {
+	return s.peak
+}
+
+// Run reads the whole source and writes every output row to dst
+func (s *Stream) Run(dst RowSink) error {
+	return s.RunContext(context.Background(), dst)
+}
+
+// RunContext streams like Run, giving up between bands when ctx is done
+// and returning ctx.Err() wrapped in ErrResizeFailed; the rows written
+// to dst so far are left for the caller to discard
+func (s *Stream) RunContext(ctx context.Context, dst RowSink) error {
+	srcHeight := s.src.Bounds().Dy()
+	_, dstHeight := s.Size()
+
+	rows := make([][]float64, srcHeight)
+	defer func() {
+		for _, row := range rows {
+			if row != nil {
+				rowPool.put(row)
+			}
+		}
+	}()
+
+	out := s.newBand(0)
+	dy, read := 0, 0
+
+	// Each band holds at least one row, so srcHeight bands cover the source
+	for i := 0; i < srcHeight && read < srcHeight; i++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", ErrResizeFailed, err)
+		}
+
+		band, err := s.src.Next()
+		if err != nil {
+			return fmt.Errorf("%w: reading rows from %d: %v", ErrResizeFailed, read, err)
+		}
+
+		// Assertion 1: Bands continue the source
+		b := band.Bounds()
+		if b.Min.X != 0 || b.Dx() != s.src.Bounds().Dx() || b.Min.Y != read || b.Dy() <= 0 || b.Max.Y > srcHeight {
+			return fmt.Errorf("%w: band %v does not continue the source at row %d", ErrResizeFailed, b, read)
+		}
+
+		s.filterRows(band, rows, b.Min.Y, b.Max.Y)
+		read = b.Max.Y
+
+		// Output rows whose taps have all been read
+		ready := dy
+		for ready < dstHeight && s.needs[ready] < read {
+			ready++
+		}
+
+		for dy < ready {
+			top := dy / s.bandRows * s.bandRows
+			end := min(ready, top+out.Bounds().Dy())
+			s.storeRows(out, rows, top, dy, end)
+
+			for ; dy < end; dy++ {
+				for _, sy := range s.expiry[s.expires[dy]:s.expires[dy+1]] {
+					rowPool.put(rows[sy])
+					rows[sy] = nil
+				}
+			}
+
+			// Hand over full bands
+			if dy == top+out.Bounds().Dy() {
+				if err := dst.WriteRows(out); err != nil {
+					return err
+				}
+
+				if s.progress != nil {
+					s.progress(dy, dstHeight)
+				}
+
+				Release(out)
+				if dy < dstHeight {
+					out = s.newBand(dy)
+				}
+			}
+		}
+	}
+
+	// Assertion 2: The source must deliver every row
+	if dy < dstHeight {
+		return fmt.Errorf("%w: source ended after %d of %d rows", ErrResizeFailed, read, srcHeight)
+	}
+
+	if _, err := s.src.Next(); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: source has rows beyond its bounds", ErrResizeFailed)
+	}
+
+	return nil
+}
+
+// newBand allocates the output band starting at row top
+func (s *Stream) newBand(top int) image.Image {
+	width, height := s.Size()
+	rect := image.Rect(0, 0, width, min(s.bandRows, height-top))
+
+	switch s.ColorModel() {
+	case color.GrayModel:
+		return newGray(rect)
+	case color.Gray16Model:
+		return newGray16(rect)
+	case color.RGBA64Model:
+		return newRGBA64(rect)
+	default:
+		return newRGBA(rect)
+	}
+}
+
+// filterRows applies the horizontal pass to the rows of band that some
+// output row reads, as tableSampler does per tap row
+func (s *Stream) filterRows(band image.Image, rows [][]float64, top, bottom int) {
+	dstWidth := s.active.config.TargetWidth
+
+	s.active.forEachRow(bottom-top, func(i int) {
+		sy := top + i
+		if s.lastUse[sy] < 0 {
+			return
+		}
+
+		row := rowPool.get(4 * dstWidth)
+		for dx, xc := range s.table.xContribs {
+			acc := row[4*dx : 4*dx+4]
+			for k, wx := range xc.Weights {
+				if wx == 0.0 {
+					continue
+				}
+
+				px := s.table.edge.pixel(band, xc.Start+k, sy, s.shift)
+				for c := 0; c < len(px); c++ {
+					acc[c] += px[c] * wx
+				}
+			}
+		}
+
+		rows[sy] = row
+	})
+}
+
+// storeRows applies the vertical pass for output rows [from, to) into
+// out, the band starting at output row top
+func (s *Stream) storeRows(out image.Image, rows [][]float64, top, from, to int) {
+	dstWidth := s.active.config.TargetWidth
+	srcHeight := len(rows)
+	mode := s.table.edge.mode
+
+	s.active.forEachRow(to-from, func(i int) {
+		dy := from + i
+		acc := rowPool.get(4 * dstWidth)
+		defer rowPool.put(acc)
+
+		yc := s.table.yContribs[dy]
+		for j, wy := range yc.Weights {
+			if wy == 0.0 {
+				continue
+			}
+
+			sy, _ := interpolation.ResolveIndex(yc.Start+j, srcHeight, mode)
+			addScaled(acc, rows[sy], wy)
+		}
+
+		y := dy - top
+		for dx := 0; dx < dstWidth; dx++ {
+			v := acc[4*dx : 4*dx+4]
+
+			switch b := out.(type) {
+			case *image.Gray:
+				b.SetGray(dx, y, color.Gray{Y: interpolation.ClampUint8(v[0])})
+			case *image.Gray16:
+				b.SetGray16(dx, y, color.Gray16{Y: interpolation.ClampUint16(v[0])})
+			case *image.RGBA64:
+				b.SetRGBA64(dx, y, color.RGBA64{
+					R: interpolation.ClampUint16(v[0]),
+					G: interpolation.ClampUint16(v[1]),
+					B: interpolation.ClampUint16(v[2]),
+					A: interpolation.ClampUint16(v[3]),
+				})
+			case *image.RGBA:
+				b.SetRGBA(dx, y, color.RGBA{
+					R: interpolation.ClampUint8(v[0]),
+					G: interpolation.ClampUint8(v[1]),
+					B: interpolation.ClampUint8(v[2]),
+					A: interpolation.ClampUint8(v[3]),
+				})
+			}
+		}
+	})
+}
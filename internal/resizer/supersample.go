@@ -0,0 +1,151 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/kasuraSH/kasurarykerion/internal/hdr"
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+)
+
+// maxSupersamplePasses bounds the halving loop, 2^16 exceeds any valid dimension
+const maxSupersamplePasses = 16
+
+// supersample repeatedly halves src with a 2x2 area average until the
+// source region is within 2x of the target on each axis, leaving the final pass to the configured filter
+func (r *Resizer) supersample(src image.Image, srcWidth, srcHeight int) image.Image {
+	width, height := srcWidth, srcHeight
+	region := r.regionFor(srcWidth, srcHeight)
+	regionWidth, regionHeight := region.width, region.height
+
+	for pass := 0; pass < maxSupersamplePasses; pass++ {
+		halveX := regionWidth >= 2.0*float64(r.config.TargetWidth)
+		halveY := regionHeight >= 2.0*float64(r.config.TargetHeight)
+
+		// Assertion 1: Stop once the final pass can cover the rest
+		if !halveX && !halveY {
+			break
+		}
+
+		halved := halveArea(src, width, height, halveX, halveY)
+
+		// Earlier passes are only read by the next one
+		if pass > 0 {
+			Release(src)
+		}
+
+		src = halved
+		regionWidth *= float64(src.Bounds().Dx()) / float64(width)
+		regionHeight *= float64(src.Bounds().Dy()) / float64(height)
+		width = src.Bounds().Dx()
+		height = src.Bounds().Dy()
+	}
+
+	return src
+}
+
+// halveArea averages 2x2 (or 2x1 / 1x2) blocks of src into a new
+// zero-origin image of the same bit depth, rounding odd sizes up and
+// replicating the last row
+func halveArea(src image.Image, width, height int, halveX, halveY bool) image.Image {
+	origin := src.Bounds().Min
+	stepX, stepY := 1, 1
+	dstWidth, dstHeight := width, height
+
+	if halveX {
+		stepX = 2
+		dstWidth = (width + 1) / 2
+	}
+
+	if halveY {
+		stepY = 2
+		dstHeight = (height + 1) / 2
+	}
+
+	// Float sources are averaged in float so bright pixels are not clipped
+	if f, ok := src.(*hdr.RGBA); ok {
+		return halveFloat(f, width, height, stepX, stepY, dstWidth, dstHeight)
+	}
+
+	dst := newImageLike(src, dstWidth, dstHeight)
+	count := uint32(stepX * stepY)
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sr, sg, sb, sa uint32
+
+			for j := 0; j < stepY; j++ {
+				safeY := interpolation.GetSafeIndex(y*stepY+j, height)
+
+				for i := 0; i < stepX; i++ {
+					safeX := interpolation.GetSafeIndex(x*stepX+i, width)
+					r32, g32, b32, a32 := rgbaAt(src, origin.X+safeX, origin.Y+safeY)
+					sr += r32
+					sg += g32
+					sb += b32
+					sa += a32
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16((sr + count/2) / count),
+				G: uint16((sg + count/2) / count),
+				B: uint16((sb + count/2) / count),
+				A: uint16((sa + count/2) / count),
+			})
+		}
+	}
+
+	return dst
+}
+
+// halveFloat is halveArea for float sources
+func halveFloat(src *hdr.RGBA, width, height, stepX, stepY, dstWidth, dstHeight int) *hdr.RGBA {
+	origin := src.Bounds().Min
+	dst := newFloat(image.Rect(0, 0, dstWidth, dstHeight))
+	count := float32(stepX * stepY)
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sum hdr.Color
+
+			for j := 0; j < stepY; j++ {
+				safeY := interpolation.GetSafeIndex(y*stepY+j, height)
+
+				for i := 0; i < stepX; i++ {
+					safeX := interpolation.GetSafeIndex(x*stepX+i, width)
+					c := src.FloatAt(origin.X+safeX, origin.Y+safeY)
+					sum.R += c.R
+					sum.G += c.G
+					sum.B += c.B
+					sum.A += c.A
+				}
+			}
+
+			dst.SetFloat(x, y, hdr.Color{R: sum.R / count, G: sum.G / count, B: sum.B / count, A: sum.A / count})
+		}
+	}
+
+	return dst
+}
+
+// newImageLike allocates an image whose color model routes to the same
+// resize path as src
+func newImageLike(src image.Image, width, height int) draw.Image {
+	rect := image.Rect(0, 0, width, height)
+
+	switch src.ColorModel() {
+	case hdr.ColorModel:
+		return newFloat(rect)
+	case color.RGBA64Model, color.NRGBA64Model:
+		return newRGBA64(rect)
+	case color.GrayModel:
+		return newGray(rect)
+	case color.Gray16Model:
+		return newGray16(rect)
+	default:
+		return newRGBA(rect)
+	}
+}
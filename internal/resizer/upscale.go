@@ -0,0 +1,51 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// UpscalePolicy decides what happens when the output would be larger than
+// the source, so batch jobs never blow up small images by accident
+type UpscalePolicy string
+
+const (
+	// UpscaleAllow resizes regardless of direction (default)
+	UpscaleAllow UpscalePolicy = "allow"
+	// UpscaleCopy returns the source unchanged instead of enlarging it
+	UpscaleCopy UpscalePolicy = "copy"
+	// UpscaleClamp shrinks the target so it never exceeds the source
+	UpscaleClamp UpscalePolicy = "clamp"
+	// UpscaleError refuses the resize with ErrUpscaleRefused
+	UpscaleError UpscalePolicy = "error"
+)
+
+var (
+	ErrUpscaleRefused       = errors.New("upscaling refused by policy")
+	ErrUnknownUpscalePolicy = errors.New("unknown upscale policy")
+)
+
+// ParseUpscalePolicy converts a policy name into an UpscalePolicy value
+func ParseUpscalePolicy(name string) (UpscalePolicy, error) {
+	switch UpscalePolicy(name) {
+	case UpscaleAllow, UpscaleCopy, UpscaleClamp, UpscaleError:
+		return UpscalePolicy(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownUpscalePolicy, name)
+	}
+}
+
+// upscales reports whether mapping the region onto width x height enlarges
+// either axis
+func upscales(region sourceRegion, width, height int) bool {
+	return float64(width) > math.Round(region.width) || float64(height) > math.Round(region.height)
+}
+
+// clampToRegion scales width x height down uniformly until neither axis
+// exceeds the region, preserving the requested aspect ratio
+func clampToRegion(region sourceRegion, width, height int) (int, int) {
+	scale := math.Min(1.0, math.Min(region.width/float64(width), region.height/float64(height)))
+	return scaledDimension(width, scale, width), scaledDimension(height, scale, height)
+}
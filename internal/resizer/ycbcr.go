@@ -0,0 +1,137 @@
+// Open source image resizer coded by kasuraSH
+package resizer
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/kasuraSH/kasurarykerion/internal/interpolation"
+	"github.com/kasuraSH/kasurarykerion/internal/validator"
+)
+
+// planarYCbCr returns src as YCbCr when the planar path can resize it;
+// EWA, fixed point, anti-ringing and constant edges need the RGBA path
+func (r *Resizer) planarYCbCr(src image.Image) (*image.YCbCr, bool) {
+	ycc, ok := src.(*image.YCbCr)
+	if !ok || r.config.EWA || r.config.FixedPoint || r.config.AntiRinging || r.config.Edge == interpolation.EdgeConstant {
+		return nil, false
+	}
+
+	return ycc, true
+}
+
+// resizeYCbCr resamples the Y, Cb and Cr samples of a JPEG image directly,
+// one axis at a time, and converts to RGBA once per output pixel
+// Chroma is read at luma resolution like At does; as the conversion is
+// affine, filtering before it matches filtering the RGB values
+func (r *Resizer) resizeYCbCr(src *image.YCbCr, srcWidth, srcHeight int) (*image.RGBA, error) {
+	// Assertion 1: Validate we can create destination image
+	if err := validator.ValidateDimensions(r.config.TargetWidth, r.config.TargetHeight); err != nil {
+		return nil, err
+	}
+
+	if r.config.Precision == PrecisionFloat32 {
+		return resizeYCbCrPlanes(r, kernels32, src, srcWidth, srcHeight)
+	}
+
+	return resizeYCbCrPlanes(r, kernels64, src, srcWidth, srcHeight)
+}
+
+// resizeYCbCrPlanes runs both passes of resizeYCbCr over rows of T
+func resizeYCbCrPlanes[T sample](r *Resizer, k kernels[T], src *image.YCbCr, srcWidth, srcHeight int) (*image.RGBA, error) {
+	table, err := r.newTableSampler(srcWidth, srcHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	dstWidth, dstHeight := r.config.TargetWidth, r.config.TargetHeight
+	mode := table.edge.mode
+	origin := table.edge.origin
+
+	// Only rows some output row reads need the horizontal pass
+	used := make([]bool, srcHeight)
+	for _, yc := range table.yContribs {
+		for j := range yc.Weights {
+			if sy, ok := interpolation.ResolveIndex(yc.Start+j, srcHeight, mode); ok {
+				used[sy] = true
+			}
+		}
+	}
+
+	// Source columns the taps reach, edges resolved once
+	first, last := table.xContribs[0].Start, 0
+	for _, xc := range table.xContribs {
+		first = min(first, xc.Start)
+		last = max(last, xc.Start+len(xc.Weights))
+	}
+
+	columns := make([]int, last-first)
+	for k := range columns {
+		columns[k], _ = interpolation.ResolveIndex(first+k, srcWidth, mode)
+	}
+
+	// Horizontal pass: three samples per target column for every used row
+	// Each row is widened into Y, Cb and Cr planes over those columns, so
+	// every target column is a dot product over contiguous taps
+	rows := k.rows.get(3 * dstWidth * srcHeight)
+	defer k.rows.put(rows)
+	xWeights := k.weights(table.xContribs)
+	r.quiet().forEachRow(srcHeight, func(sy int) {
+		if !used[sy] {
+			return
+		}
+
+		n := len(columns)
+		planes := k.rows.get(3 * n)
+		defer k.rows.put(planes)
+		yPlane, cbPlane, crPlane := planes[:n], planes[n:2*n], planes[2*n:]
+
+		for k, sx := range columns {
+			yi := src.YOffset(origin.X+sx, origin.Y+sy)
+			ci := src.COffset(origin.X+sx, origin.Y+sy)
+			yPlane[k], cbPlane[k], crPlane[k] = T(src.Y[yi]), T(src.Cb[ci]), T(src.Cr[ci])
+		}
+
+		row := rows[3*dstWidth*sy:]
+		for dx, xc := range table.xContribs {
+			at, taps := xc.Start-first, xWeights(dx)
+			row[3*dx] = k.dot(taps, yPlane[at:])
+			row[3*dx+1] = k.dot(taps, cbPlane[at:])
+			row[3*dx+2] = k.dot(taps, crPlane[at:])
+		}
+	})
+
+	// Vertical pass and conversion: whole intermediate rows are weighted
+	// into one accumulator row per target row
+	dst := newRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	r.forEachRow(dstHeight, func(dy int) {
+		acc := k.rows.get(3 * dstWidth)
+		defer k.rows.put(acc)
+
+		for j, w := range table.yContribs[dy].Weights {
+			if w == 0.0 {
+				continue
+			}
+
+			sy, _ := interpolation.ResolveIndex(table.yContribs[dy].Start+j, srcHeight, mode)
+			k.addScaled(acc, rows[3*dstWidth*sy:], T(w))
+		}
+
+		for dx := 0; dx < dstWidth; dx++ {
+			dst.SetRGBA(dx, dy, ycbcrToRGBA(float64(acc[3*dx]), float64(acc[3*dx+1])-128, float64(acc[3*dx+2])-128))
+		}
+	})
+
+	return dst, nil
+}
+
+// ycbcrToRGBA converts full range JFIF luma and centered chroma to an
+// opaque 8-bit color
+func ycbcrToRGBA(y, cb, cr float64) color.RGBA {
+	return color.RGBA{
+		R: interpolation.ClampUint8(y + 1.402*cr),
+		G: interpolation.ClampUint8(y - 0.344136*cb - 0.714136*cr),
+		B: interpolation.ClampUint8(y + 1.772*cb),
+		A: 0xFF,
+	}
+}
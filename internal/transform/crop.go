@@ -0,0 +1,130 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+var (
+	ErrInvalidCrop = errors.New("invalid crop")
+	ErrNilImage    = errors.New("nil image provided")
+)
+
+// cropPattern matches WxH with optional signed offsets, e.g. 800x600+10-20
+var cropPattern = regexp.MustCompile(`^(\d+)x(\d+)([+-]\d+)?([+-]\d+)?$`)
+
+// CropSpec describes a crop rectangle relative to a gravity anchor
+type CropSpec struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+	Anchor Anchor // Defaults to north-west gravity, making X and Y absolute
+}
+
+// ParseCrop parses the WxH+X+Y geometry syntax
+func ParseCrop(s string) (CropSpec, error) {
+	m := cropPattern.FindStringSubmatch(s)
+
+	// Assertion 1: Validate syntax
+	if m == nil {
+		return CropSpec{}, fmt.Errorf("%w: expected WxH+X+Y, got %q", ErrInvalidCrop, s)
+	}
+
+	spec := CropSpec{}
+	values := []*int{&spec.Width, &spec.Height, &spec.X, &spec.Y}
+
+	for i := 0; i < len(values); i++ {
+		if m[i+1] == "" {
+			continue
+		}
+
+		v, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return CropSpec{}, fmt.Errorf("%w: %v", ErrInvalidCrop, err)
+		}
+
+		*values[i] = v
+	}
+
+	// Assertion 2: Validate size
+	if err := validator.ValidateDimensions(spec.Width, spec.Height); err != nil {
+		return CropSpec{}, fmt.Errorf("%w: %v", ErrInvalidCrop, err)
+	}
+
+	return spec, nil
+}
+
+// Rect resolves the crop against a source of the given size, clipping it to
+// the image; an empty intersection is an error
+func (c CropSpec) Rect(srcWidth, srcHeight int) (image.Rectangle, error) {
+	x, y := c.Anchor.Position(float64(srcWidth), float64(srcHeight),
+		float64(c.Width), float64(c.Height), float64(c.X), float64(c.Y), GravityNorthWest)
+
+	x0 := int(math.Round(x))
+	y0 := int(math.Round(y))
+	rect := image.Rect(x0, y0, x0+c.Width, y0+c.Height).Intersect(image.Rect(0, 0, srcWidth, srcHeight))
+
+	// Assertion 1: Validate the crop overlaps the image
+	if rect.Empty() {
+		return image.Rectangle{}, fmt.Errorf("%w: crop lies outside the %dx%d image", ErrInvalidCrop, srcWidth, srcHeight)
+	}
+
+	return rect, nil
+}
+
+// Crop copies rect of src into a new zero-origin image of the same kind
+// rect is relative to the top-left corner of src
+func Crop(src image.Image, rect image.Rectangle) (image.Image, error) {
+	// Assertion 1: Validate input image
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	bounds := src.Bounds()
+	abs := rect.Add(bounds.Min)
+
+	// Assertion 2: Validate the rectangle lies inside the image
+	if rect.Empty() || !abs.In(bounds) {
+		return nil, fmt.Errorf("%w: %v outside %v", ErrInvalidCrop, rect, bounds)
+	}
+
+	dst := NewImageLike(src, rect.Dx(), rect.Dy())
+	draw.Draw(dst, dst.Bounds(), src, abs.Min, draw.Src)
+
+	return dst, nil
+}
+
+// NewImageLike allocates a zero-origin image with the pixel layout of src
+// so transforms keep the bit depth and color model of their input
+func NewImageLike(src image.Image, width, height int) draw.Image {
+	rect := image.Rect(0, 0, width, height)
+
+	switch s := src.(type) {
+	case *image.Gray:
+		return image.NewGray(rect)
+	case *image.Gray16:
+		return image.NewGray16(rect)
+	case *image.RGBA64:
+		return image.NewRGBA64(rect)
+	case *image.NRGBA64:
+		return image.NewNRGBA64(rect)
+	case *image.NRGBA:
+		return image.NewNRGBA(rect)
+	case *image.Paletted:
+		return image.NewPaletted(rect, s.Palette)
+	case *hdr.RGBA:
+		return hdr.NewRGBA(rect)
+	default:
+		return image.NewRGBA(rect)
+	}
+}
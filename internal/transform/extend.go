@@ -0,0 +1,100 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+var ErrInvalidBorders = errors.New("invalid borders")
+
+// Borders holds the pixels added to each side of the canvas
+type Borders struct {
+	Top    int
+	Right  int
+	Bottom int
+	Left   int
+}
+
+// ParseBorders parses one, two or four comma separated pixel counts in
+// CSS order: all sides, vertical and horizontal, or top, right, bottom, left
+func ParseBorders(s string) (Borders, error) {
+	parts := strings.Split(s, ",")
+	values := make([]int, len(parts))
+
+	for i := 0; i < len(parts); i++ {
+		v, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+
+		// Assertion 1: Every value is a non-negative integer
+		if err != nil || v < 0 || v > validator.MaxImageDimension {
+			return Borders{}, fmt.Errorf("%w: %q", ErrInvalidBorders, s)
+		}
+
+		values[i] = v
+	}
+
+	// Assertion 2: Expand the shorthand forms
+	switch len(values) {
+	case 1:
+		return Borders{values[0], values[0], values[0], values[0]}, nil
+	case 2:
+		return Borders{values[0], values[1], values[0], values[1]}, nil
+	case 4:
+		return Borders{values[0], values[1], values[2], values[3]}, nil
+	default:
+		return Borders{}, fmt.Errorf("%w: expected 1, 2 or 4 values, got %q", ErrInvalidBorders, s)
+	}
+}
+
+// Extend grows the canvas of src by the borders, filling them with fill
+// 8-bit and 16-bit RGBA sources keep their type, others become RGBA or RGBA64
+func Extend(src image.Image, b Borders, fill color.Color) (image.Image, error) {
+	// Assertion 1: Validate inputs
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	if b.Top < 0 || b.Right < 0 || b.Bottom < 0 || b.Left < 0 {
+		return nil, fmt.Errorf("%w: negative border", ErrInvalidBorders)
+	}
+
+	if fill == nil {
+		fill = color.Transparent
+	}
+
+	bounds := src.Bounds()
+	width := bounds.Dx() + b.Left + b.Right
+	height := bounds.Dy() + b.Top + b.Bottom
+
+	// Assertion 2: Validate the extended canvas
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBorders, err)
+	}
+
+	dst := newCanvas(src, width, height)
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(b.Left, b.Top, b.Left+bounds.Dx(), b.Top+bounds.Dy()), src, bounds.Min, draw.Src)
+
+	return dst, nil
+}
+
+// newCanvas allocates an image able to hold both src and any fill color
+func newCanvas(src image.Image, width, height int) draw.Image {
+	switch src.(type) {
+	case *image.RGBA, *image.NRGBA, *image.RGBA64, *image.NRGBA64:
+		return NewImageLike(src, width, height)
+	}
+
+	if isDeep(src) {
+		return image.NewRGBA64(image.Rect(0, 0, width, height))
+	}
+
+	return image.NewRGBA(image.Rect(0, 0, width, height))
+}
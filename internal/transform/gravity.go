@@ -0,0 +1,203 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Gravity names the edge or corner a region is anchored to
+type Gravity string
+
+const (
+	GravityNorthWest Gravity = "northwest"
+	GravityNorth     Gravity = "north"
+	GravityNorthEast Gravity = "northeast"
+	GravityWest      Gravity = "west"
+	GravityCenter    Gravity = "center"
+	GravityEast      Gravity = "east"
+	GravitySouthWest Gravity = "southwest"
+	GravitySouth     Gravity = "south"
+	GravitySouthEast Gravity = "southeast"
+)
+
+var (
+	ErrUnknownGravity    = errors.New("unknown gravity")
+	ErrInvalidFocalPoint = errors.New("invalid focal point")
+)
+
+// ParseGravity converts a name such as "south-east" or "SouthEast" into a Gravity
+func ParseGravity(name string) (Gravity, error) {
+	g := Gravity(strings.ToLower(strings.ReplaceAll(name, "-", "")))
+
+	switch g {
+	case GravityNorthWest, GravityNorth, GravityNorthEast,
+		GravityWest, GravityCenter, GravityEast,
+		GravitySouthWest, GravitySouth, GravitySouthEast, GravitySmart:
+		return g, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownGravity, name)
+	}
+}
+
+// factors returns the horizontal and vertical anchor position of the
+// gravity, 0 for the left/top edge, 0.5 for the centre and 1 for right/bottom
+// Smart gravity acts as centre until resolved against an image
+func (g Gravity) factors() (float64, float64) {
+	fx, fy := 0.0, 0.0
+
+	switch g {
+	case GravityNorth, GravityCenter, GravitySouth, GravitySmart:
+		fx = 0.5
+	case GravityNorthEast, GravityEast, GravitySouthEast:
+		fx = 1.0
+	}
+
+	switch g {
+	case GravityWest, GravityCenter, GravityEast, GravitySmart:
+		fy = 0.5
+	case GravitySouthWest, GravitySouth, GravitySouthEast:
+		fy = 1.0
+	}
+
+	return fx, fy
+}
+
+// Anchor returns the top-left position of an inner area placed inside an
+// outer area according to the gravity, as floating point source pixels
+// Offsets point away from the anchored edge, so with south-east gravity a
+// positive x offset moves the area left
+func (g Gravity) Anchor(outerWidth, outerHeight, innerWidth, innerHeight, offsetX, offsetY float64) (float64, float64) {
+	fx, fy := g.factors()
+
+	x := (outerWidth - innerWidth) * fx
+	y := (outerHeight - innerHeight) * fy
+
+	// Assertion 1: Mirror offsets for right and bottom anchored gravities
+	if fx == 1.0 {
+		offsetX = -offsetX
+	}
+
+	if fy == 1.0 {
+		offsetY = -offsetY
+	}
+
+	return x + offsetX, y + offsetY
+}
+
+// FocalPoint is a position inside an image given as fractions of its width
+// and height, so the same point works for every size of the image
+type FocalPoint struct {
+	X float64
+	Y float64
+}
+
+// ParseFocalPoint parses "x,y" with both fractions in [0, 1]
+func ParseFocalPoint(s string) (FocalPoint, error) {
+	parts := strings.Split(s, ",")
+
+	// Assertion 1: Validate syntax
+	if len(parts) != 2 {
+		return FocalPoint{}, fmt.Errorf("%w: expected x,y, got %q", ErrInvalidFocalPoint, s)
+	}
+
+	x, errX := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errX != nil || errY != nil {
+		return FocalPoint{}, fmt.Errorf("%w: %q is not numeric", ErrInvalidFocalPoint, s)
+	}
+
+	p := FocalPoint{X: x, Y: y}
+
+	// Assertion 2: Validate range
+	if err := p.Validate(); err != nil {
+		return FocalPoint{}, err
+	}
+
+	return p, nil
+}
+
+// Validate checks that both coordinates lie inside the image
+func (p FocalPoint) Validate() error {
+	if !(p.X >= 0.0 && p.X <= 1.0 && p.Y >= 0.0 && p.Y <= 1.0) {
+		return fmt.Errorf("%w: %g,%g outside 0-1", ErrInvalidFocalPoint, p.X, p.Y)
+	}
+
+	return nil
+}
+
+// Anchor returns the top-left position of an inner area centred on the
+// focal point, shifted as little as needed to stay inside the outer area
+func (p FocalPoint) Anchor(outerWidth, outerHeight, innerWidth, innerHeight float64) (float64, float64) {
+	x := p.X*outerWidth - innerWidth/2.0
+	y := p.Y*outerHeight - innerHeight/2.0
+
+	// Assertion 1: Keep the inner area inside the outer one
+	x = math.Max(0.0, math.Min(x, outerWidth-innerWidth))
+	y = math.Max(0.0, math.Min(y, outerHeight-innerHeight))
+
+	return x, y
+}
+
+// Anchor selects which part of an image is kept, by gravity or focal point
+type Anchor struct {
+	Gravity Gravity     // Empty uses the default of the operation
+	Focal   *FocalPoint // Overrides Gravity when set
+}
+
+// ParseAnchor accepts a gravity name or an "x,y" focal point
+func ParseAnchor(s string) (Anchor, error) {
+	// Assertion 1: A comma marks focal point coordinates
+	if strings.Contains(s, ",") {
+		p, err := ParseFocalPoint(s)
+		if err != nil {
+			return Anchor{}, err
+		}
+
+		return Anchor{Focal: &p}, nil
+	}
+
+	g, err := ParseGravity(s)
+	if err != nil {
+		return Anchor{}, err
+	}
+
+	return Anchor{Gravity: g}, nil
+}
+
+// Validate checks the gravity name and focal point range
+func (a Anchor) Validate() error {
+	// Assertion 1: Validate focal point
+	if a.Focal != nil {
+		return a.Focal.Validate()
+	}
+
+	// Assertion 2: Validate gravity, empty means the default
+	if a.Gravity != "" {
+		if _, err := ParseGravity(string(a.Gravity)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Position returns the top-left position of an inner area inside an outer
+// area, falling back to def when the anchor names no gravity
+func (a Anchor) Position(outerWidth, outerHeight, innerWidth, innerHeight, offsetX, offsetY float64, def Gravity) (float64, float64) {
+	// Assertion 1: A focal point takes precedence over gravity
+	if a.Focal != nil {
+		x, y := a.Focal.Anchor(outerWidth, outerHeight, innerWidth, innerHeight)
+		return x + offsetX, y + offsetY
+	}
+
+	g := a.Gravity
+	if g == "" {
+		g = def
+	}
+
+	return g.Anchor(outerWidth, outerHeight, innerWidth, innerHeight, offsetX, offsetY)
+}
@@ -0,0 +1,108 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// FlipAxis names the mirror direction of Flip
+type FlipAxis string
+
+const (
+	// FlipHorizontal mirrors left and right
+	FlipHorizontal FlipAxis = "h"
+	// FlipVertical mirrors top and bottom
+	FlipVertical FlipAxis = "v"
+)
+
+var ErrUnknownFlip = errors.New("unknown flip axis")
+
+// ParseFlipAxis converts h or v into a FlipAxis
+func ParseFlipAxis(name string) (FlipAxis, error) {
+	switch FlipAxis(name) {
+	case FlipHorizontal, FlipVertical:
+		return FlipAxis(name), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFlip, name)
+	}
+}
+
+// IsQuarterTurn reports whether degrees is a whole number of right angles
+func IsQuarterTurn(degrees float64) bool {
+	return math.Mod(degrees, 90.0) == 0.0
+}
+
+// RotateQuarter turns src clockwise by quarter turns of 90 degrees by
+// moving pixels, so no resampling happens and the pixel type is kept
+func RotateQuarter(src image.Image, quarters int) (image.Image, error) {
+	// Assertion 1: Validate input image
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	// Assertion 2: Normalize to 0-3 clockwise turns
+	quarters = ((quarters % 4) + 4) % 4
+	if quarters == 0 {
+		return src, nil
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dstWidth, dstHeight := width, height
+	if quarters%2 == 1 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := NewImageLike(src, dstWidth, dstHeight)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := src.At(bounds.Min.X+x, bounds.Min.Y+y)
+
+			switch quarters {
+			case 1:
+				dst.Set(height-1-y, x, c)
+			case 2:
+				dst.Set(width-1-x, height-1-y, c)
+			default:
+				dst.Set(y, width-1-x, c)
+			}
+		}
+	}
+
+	return dst, nil
+}
+
+// Flip mirrors src along the given axis by moving pixels
+func Flip(src image.Image, axis FlipAxis) (image.Image, error) {
+	// Assertion 1: Validate inputs
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	if _, err := ParseFlipAxis(string(axis)); err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dst := NewImageLike(src, width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := src.At(bounds.Min.X+x, bounds.Min.Y+y)
+
+			if axis == FlipHorizontal {
+				dst.Set(width-1-x, y, c)
+			} else {
+				dst.Set(x, height-1-y, c)
+			}
+		}
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,42 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ErrNilResult is returned when an operation yields no image
+var ErrNilResult = errors.New("operation returned nil image")
+
+// Operation turns one image into another
+type Operation func(src image.Image) (image.Image, error)
+
+// Pipeline is a sequence of operations applied in order
+type Pipeline []Operation
+
+// Apply runs every operation on the output of the previous one
+func (p Pipeline) Apply(src image.Image) (image.Image, error) {
+	// Assertion 1: Validate input image
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	img := src
+	for i, op := range p {
+		out, err := op(img)
+		if err != nil {
+			return nil, err
+		}
+
+		// Assertion 2: Every step must produce an image
+		if out == nil {
+			return nil, fmt.Errorf("%w: step %d", ErrNilResult, i+1)
+		}
+
+		img = out
+	}
+
+	return img, nil
+}
@@ -0,0 +1,205 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// MaxPaletteSize is the largest palette GIF, PNG8 and 8-bit BMP can hold
+const MaxPaletteSize = 256
+
+var ErrInvalidPaletteSize = errors.New("invalid palette size")
+
+// colorCount is one distinct color of an image and how often it occurs
+type colorCount struct {
+	c [4]uint8
+	n int
+}
+
+// Quantize builds a palette of at most size colors for src with median
+// cut, reserving a fully transparent entry when src has transparent pixels
+func Quantize(src image.Image, size int) (color.Palette, error) {
+	// Assertion 1: Validate input and palette size
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	if size < 1 || size > MaxPaletteSize {
+		return nil, fmt.Errorf("%w: %d colors, must be 1-%d", ErrInvalidPaletteSize, size, MaxPaletteSize)
+	}
+
+	histogram, transparent := colorHistogram(src)
+
+	palette := make(color.Palette, 0, size)
+	if transparent {
+		palette = append(palette, color.RGBA{})
+	}
+
+	// Assertion 2: Only fully transparent pixels, or no room left for colors
+	if len(histogram) == 0 || len(palette) == size {
+		return palette, nil
+	}
+
+	boxes := medianCut(histogram, size-len(palette))
+	for i := 0; i < len(boxes); i++ {
+		palette = append(palette, boxMean(boxes[i]))
+	}
+
+	return palette, nil
+}
+
+// Remap converts src to a zero-origin paletted image, optionally spreading
+// the quantization error with Floyd-Steinberg dithering
+func Remap(src image.Image, palette color.Palette, dither bool) (*image.Paletted, error) {
+	// Assertion 1: Validate input and palette
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	if len(palette) == 0 || len(palette) > MaxPaletteSize {
+		return nil, fmt.Errorf("%w: %d colors, must be 1-%d", ErrInvalidPaletteSize, len(palette), MaxPaletteSize)
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette)
+
+	if dither {
+		draw.FloydSteinberg.Draw(dst, dst.Bounds(), src, bounds.Min)
+	} else {
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	}
+
+	return dst, nil
+}
+
+// colorHistogram counts the distinct 8-bit colors of src, leaving out
+// fully transparent pixels and reporting whether any were seen
+func colorHistogram(src image.Image) ([]colorCount, bool) {
+	bounds := src.Bounds()
+	counts := make(map[[4]uint8]int)
+	transparent := false
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(src.At(x, y)).(color.RGBA)
+
+			if c.A == 0 {
+				transparent = true
+				continue
+			}
+
+			counts[[4]uint8{c.R, c.G, c.B, c.A}]++
+		}
+	}
+
+	histogram := make([]colorCount, 0, len(counts))
+	for c, n := range counts {
+		histogram = append(histogram, colorCount{c: c, n: n})
+	}
+
+	// Map order is random, sort so the palette is reproducible
+	sort.Slice(histogram, func(i, j int) bool {
+		a, b := histogram[i].c, histogram[j].c
+		for k := 0; k < 4; k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	return histogram, transparent
+}
+
+// medianCut splits the histogram into at most count boxes, each time
+// halving the box with the widest channel range at its weighted median
+func medianCut(histogram []colorCount, count int) [][]colorCount {
+	boxes := [][]colorCount{histogram}
+
+	for pass := 1; pass < count; pass++ {
+		widest, channel, spread := -1, 0, 0
+
+		for i := 0; i < len(boxes); i++ {
+			c, s := widestChannel(boxes[i])
+			if len(boxes[i]) > 1 && s > spread {
+				widest, channel, spread = i, c, s
+			}
+		}
+
+		// Assertion 1: Stop when every box holds a single color
+		if widest < 0 {
+			break
+		}
+
+		low, high := splitBox(boxes[widest], channel)
+		boxes[widest] = low
+		boxes = append(boxes, high)
+	}
+
+	return boxes
+}
+
+// widestChannel returns the channel with the largest value range in box
+func widestChannel(box []colorCount) (int, int) {
+	channel, spread := 0, 0
+
+	for k := 0; k < 4; k++ {
+		lo, hi := 255, 0
+		for i := 0; i < len(box); i++ {
+			lo = min(lo, int(box[i].c[k]))
+			hi = max(hi, int(box[i].c[k]))
+		}
+
+		if hi-lo > spread {
+			channel, spread = k, hi-lo
+		}
+	}
+
+	return channel, spread
+}
+
+// splitBox sorts box along channel and cuts it where half of its pixels
+// fall on each side, keeping at least one color per half
+func splitBox(box []colorCount, channel int) ([]colorCount, []colorCount) {
+	sort.SliceStable(box, func(i, j int) bool {
+		return box[i].c[channel] < box[j].c[channel]
+	})
+
+	total := 0
+	for i := 0; i < len(box); i++ {
+		total += box[i].n
+	}
+
+	cut, seen := 1, box[0].n
+	for cut < len(box)-1 && seen < total/2 {
+		seen += box[cut].n
+		cut++
+	}
+
+	return box[:cut], box[cut:]
+}
+
+// boxMean returns the pixel-weighted average color of box
+func boxMean(box []colorCount) color.RGBA {
+	var sum [4]int
+	total := 0
+
+	for i := 0; i < len(box); i++ {
+		for k := 0; k < 4; k++ {
+			sum[k] += int(box[i].c[k]) * box[i].n
+		}
+		total += box[i].n
+	}
+
+	var mean [4]uint8
+	for k := 0; k < 4; k++ {
+		mean[k] = uint8((sum[k] + total/2) / total)
+	}
+
+	return color.RGBA{R: mean[0], G: mean[1], B: mean[2], A: mean[3]}
+}
@@ -0,0 +1,176 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kasurarykerion/golangresizer/internal/interpolation"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// rotateEpsilon absorbs floating point noise when sizing the rotated canvas
+const rotateEpsilon = 1e-9
+
+var ErrInvalidAngle = errors.New("invalid rotation angle")
+
+// Rotate turns src clockwise by degrees, resampling with kernel k
+// The canvas grows to hold the whole rotated image and uncovered areas
+// are filled with bg; 16-bit sources produce a 16-bit result
+// Multiples of 90 degrees are lossless pixel moves via RotateQuarter
+func Rotate(src image.Image, degrees float64, k interpolation.Kernel, bg color.Color) (image.Image, error) {
+	// Assertion 1: Validate inputs
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	if math.IsNaN(degrees) || math.IsInf(degrees, 0) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAngle, degrees)
+	}
+
+	if k == nil {
+		return nil, interpolation.ErrInvalidKernel
+	}
+
+	if bg == nil {
+		bg = color.Transparent
+	}
+
+	// Assertion 2: A full turn leaves the image unchanged
+	degrees = math.Mod(degrees, 360.0)
+	if degrees == 0.0 {
+		return src, nil
+	}
+
+	if IsQuarterTurn(degrees) {
+		return RotateQuarter(src, int(degrees/90.0))
+	}
+
+	bounds := src.Bounds()
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+	sin, cos := math.Sincos(degrees * math.Pi / 180.0)
+
+	dstWidth := int(math.Ceil(math.Abs(width*cos) + math.Abs(height*sin) - rotateEpsilon))
+	dstHeight := int(math.Ceil(math.Abs(width*sin) + math.Abs(height*cos) - rotateEpsilon))
+
+	// Assertion 3: Validate the rotated canvas
+	if err := validator.ValidateDimensions(dstWidth, dstHeight); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAngle, err)
+	}
+
+	fr, fg, fb, fa := bg.RGBA()
+	fill := [4]float64{float64(fr), float64(fg), float64(fb), float64(fa)}
+
+	deep := isDeep(src)
+	var dst64 *image.RGBA64
+	var dst8 *image.RGBA
+	if deep {
+		dst64 = image.NewRGBA64(image.Rect(0, 0, dstWidth, dstHeight))
+	} else {
+		dst8 = image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	}
+
+	support := k.Support()
+
+	for y := 0; y < dstHeight; y++ {
+		dy := float64(y) + 0.5 - float64(dstHeight)/2.0
+
+		for x := 0; x < dstWidth; x++ {
+			dx := float64(x) + 0.5 - float64(dstWidth)/2.0
+
+			// Inverse rotation into source pixel index coordinates
+			sx := dx*cos + dy*sin + width/2.0 - 0.5
+			sy := -dx*sin + dy*cos + height/2.0 - 0.5
+
+			px, err := samplePoint(src, sx, sy, k, support, fill)
+			if err != nil {
+				return nil, err
+			}
+
+			if deep {
+				dst64.SetRGBA64(x, y, color.RGBA64{
+					R: interpolation.ClampUint16(px[0]), G: interpolation.ClampUint16(px[1]),
+					B: interpolation.ClampUint16(px[2]), A: interpolation.ClampUint16(px[3]),
+				})
+			} else {
+				dst8.SetRGBA(x, y, color.RGBA{
+					R: interpolation.ClampUint8(px[0] / 257.0), G: interpolation.ClampUint8(px[1] / 257.0),
+					B: interpolation.ClampUint8(px[2] / 257.0), A: interpolation.ClampUint8(px[3] / 257.0),
+				})
+			}
+		}
+	}
+
+	if deep {
+		return dst64, nil
+	}
+
+	return dst8, nil
+}
+
+// samplePoint filters src around the point (sx, sy) with the 2D kernel
+// k(dx)*k(dy), treating pixels outside the image as fill
+func samplePoint(src image.Image, sx, sy float64, k interpolation.Kernel, support float64, fill [4]float64) ([4]float64, error) {
+	bounds := src.Bounds()
+	x0, x1 := int(math.Ceil(sx-support)), int(math.Floor(sx+support))
+	y0, y1 := int(math.Ceil(sy-support)), int(math.Floor(sy+support))
+
+	var px [4]float64
+	var sum float64
+
+	for j := y0; j <= y1; j++ {
+		wy := k.Weight(float64(j) - sy)
+		if wy == 0.0 {
+			continue
+		}
+
+		for i := x0; i <= x1; i++ {
+			w := wy * k.Weight(float64(i)-sx)
+			if w == 0.0 {
+				continue
+			}
+
+			c := fill
+			if i >= 0 && j >= 0 && i < bounds.Dx() && j < bounds.Dy() {
+				r, g, b, a := src.At(bounds.Min.X+i, bounds.Min.Y+j).RGBA()
+				c = [4]float64{float64(r), float64(g), float64(b), float64(a)}
+			}
+
+			px[0] += c[0] * w
+			px[1] += c[1] * w
+			px[2] += c[2] * w
+			px[3] += c[3] * w
+			sum += w
+		}
+	}
+
+	// Assertion 1: Ensure weights can be normalized
+	if sum == 0.0 {
+		return px, interpolation.ErrZeroWeightSum
+	}
+
+	for c := 0; c < 4; c++ {
+		px[c] /= sum
+	}
+
+	// Assertion 2: Keep premultiplied channels within the alpha
+	px[3] = math.Max(0.0, math.Min(px[3], interpolation.MaxUint16))
+	for c := 0; c < 3; c++ {
+		px[c] = math.Max(0.0, math.Min(px[c], px[3]))
+	}
+
+	return px, nil
+}
+
+// isDeep reports whether src stores more than 8 bits per channel
+func isDeep(src image.Image) bool {
+	switch src.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,101 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+)
+
+// Shrink reduces src by a whole factor, averaging factor x factor blocks;
+// blocks on the right and bottom edges average the pixels they cover
+// It is a cheap pre-scale, a resize to the exact size should follow
+// Paletted sources become RGBA so the averages are not snapped back to
+// the palette
+func Shrink(src image.Image, factor int) (image.Image, error) {
+	// Assertion 1: Validate input image and factor
+	if src == nil {
+		return nil, ErrNilImage
+	}
+
+	if factor < 1 {
+		return nil, fmt.Errorf("shrink factor %d must be at least 1", factor)
+	}
+
+	if factor == 1 {
+		return src, nil
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := (width+factor-1)/factor, (height+factor-1)/factor
+
+	// Float sources are averaged in float so bright pixels are not clipped
+	if f, ok := src.(*hdr.RGBA); ok {
+		return shrinkFloat(f, factor, dstWidth, dstHeight), nil
+	}
+
+	var dst draw.Image
+	if _, ok := src.(*image.Paletted); ok {
+		dst = image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	} else {
+		dst = NewImageLike(src, dstWidth, dstHeight)
+	}
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sr, sg, sb, sa, count uint64
+
+			for sy := y * factor; sy < min((y+1)*factor, height); sy++ {
+				for sx := x * factor; sx < min((x+1)*factor, width); sx++ {
+					r, g, b, a := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					sr += uint64(r)
+					sg += uint64(g)
+					sb += uint64(b)
+					sa += uint64(a)
+					count++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16((sr + count/2) / count),
+				G: uint16((sg + count/2) / count),
+				B: uint16((sb + count/2) / count),
+				A: uint16((sa + count/2) / count),
+			})
+		}
+	}
+
+	return dst, nil
+}
+
+// shrinkFloat is Shrink for float sources
+func shrinkFloat(src *hdr.RGBA, factor, dstWidth, dstHeight int) *hdr.RGBA {
+	bounds := src.Bounds()
+	dst := hdr.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sum hdr.Color
+			var count float32
+
+			for sy := y * factor; sy < min((y+1)*factor, bounds.Dy()); sy++ {
+				for sx := x * factor; sx < min((x+1)*factor, bounds.Dx()); sx++ {
+					c := src.FloatAt(bounds.Min.X+sx, bounds.Min.Y+sy)
+					sum.R += c.R
+					sum.G += c.G
+					sum.B += c.B
+					sum.A += c.A
+					count++
+				}
+			}
+
+			dst.SetFloat(x, y, hdr.Color{R: sum.R / count, G: sum.G / count, B: sum.B / count, A: sum.A / count})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,128 @@
+// Open source image resizer coded by kasuraSH
+package transform
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// GravitySmart keeps the area with the most edge detail
+	GravitySmart Gravity = "smart"
+
+	// smartGridSize is the long side of the luminance grid analysed by
+	// smart cropping, bounding its cost independently of the image size
+	smartGridSize = 256
+)
+
+// Resolve turns a content-dependent anchor into a focal point for src and
+// an inner area of the given size, returning other anchors unchanged
+func (a Anchor) Resolve(src image.Image, innerWidth, innerHeight float64) Anchor {
+	// Assertion 1: Only smart gravity needs the image
+	if a.Focal != nil || a.Gravity != GravitySmart || src == nil {
+		return a
+	}
+
+	p := SmartFocalPoint(src, innerWidth, innerHeight)
+	return Anchor{Focal: &p}
+}
+
+// SmartFocalPoint finds the inner area of src with the most edge energy and
+// returns its centre as a focal point, favouring the middle on ties
+func SmartFocalPoint(src image.Image, innerWidth, innerHeight float64) FocalPoint {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	centre := FocalPoint{X: 0.5, Y: 0.5}
+
+	// Assertion 1: Guard against empty images and areas
+	if width <= 0 || height <= 0 || innerWidth <= 0.0 || innerHeight <= 0.0 {
+		return centre
+	}
+
+	step := math.Max(1.0, float64(max(width, height))/smartGridSize)
+	gw := max(1, int(float64(width)/step))
+	gh := max(1, int(float64(height)/step))
+
+	luma := sampleLuma(src, gw, gh, step)
+	table := edgeEnergyTable(luma, gw, gh)
+
+	ww := min(gw, max(1, int(math.Round(innerWidth/step))))
+	wh := min(gh, max(1, int(math.Round(innerHeight/step))))
+
+	bestX, bestY := (gw-ww)/2, (gh-wh)/2
+	best := windowSum(table, gw, bestX, bestY, ww, wh)
+	bestDist := 0
+
+	for y := 0; y <= gh-wh; y++ {
+		for x := 0; x <= gw-ww; x++ {
+			sum := windowSum(table, gw, x, y, ww, wh)
+			dist := abs(x-(gw-ww)/2) + abs(y-(gh-wh)/2)
+
+			// Assertion 2: Prefer more energy, then the more central window
+			if sum > best || (sum == best && dist < bestDist) {
+				best, bestDist = sum, dist
+				bestX, bestY = x, y
+			}
+		}
+	}
+
+	return FocalPoint{
+		X: (float64(bestX) + float64(ww)/2.0) / float64(gw),
+		Y: (float64(bestY) + float64(wh)/2.0) / float64(gh),
+	}
+}
+
+// sampleLuma reads a gw x gh luminance grid from src, one pixel per cell
+func sampleLuma(src image.Image, gw, gh int, step float64) []float64 {
+	bounds := src.Bounds()
+	luma := make([]float64, gw*gh)
+
+	for y := 0; y < gh; y++ {
+		sy := bounds.Min.Y + min(bounds.Dy()-1, int((float64(y)+0.5)*step))
+
+		for x := 0; x < gw; x++ {
+			sx := bounds.Min.X + min(bounds.Dx()-1, int((float64(x)+0.5)*step))
+			r, g, b, _ := src.At(sx, sy).RGBA()
+			luma[y*gw+x] = 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+		}
+	}
+
+	return luma
+}
+
+// edgeEnergyTable returns the summed-area table of the gradient magnitude
+// of luma, with one extra leading row and column of zeros
+func edgeEnergyTable(luma []float64, gw, gh int) []float64 {
+	stride := gw + 1
+	table := make([]float64, stride*(gh+1))
+
+	for y := 0; y < gh; y++ {
+		var row float64
+
+		for x := 0; x < gw; x++ {
+			dx := luma[y*gw+min(gw-1, x+1)] - luma[y*gw+max(0, x-1)]
+			dy := luma[min(gh-1, y+1)*gw+x] - luma[max(0, y-1)*gw+x]
+			row += math.Abs(dx) + math.Abs(dy)
+
+			table[(y+1)*stride+x+1] = table[y*stride+x+1] + row
+		}
+	}
+
+	return table
+}
+
+// windowSum returns the table total over the ww x wh window at x, y
+func windowSum(table []float64, gw, x, y, ww, wh int) float64 {
+	stride := gw + 1
+	return table[(y+wh)*stride+x+ww] - table[y*stride+x+ww] -
+		table[(y+wh)*stride+x] + table[y*stride+x]
+}
+
+// abs returns the absolute value of an integer
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
@@ -4,6 +4,7 @@ package validator
 import (
 	"errors"
 	"fmt"
+	"math"
 )
 
 const (
@@ -11,12 +12,18 @@ const (
 	MaxImageDimension = 65535
 	MinImageDimension = 1
 	MaxFileSize       = 1073741824 // 1GB limit
+
+	// DefaultMaxScaleFactor is the largest enlargement allowed by default
+	DefaultMaxScaleFactor = 16.0
+	// DefaultMinScaleFactor is the smallest reduction allowed by default
+	DefaultMinScaleFactor = 0.0625 // 1/16
 )
 
 var (
 	ErrInvalidDimension = errors.New("dimension out of valid range")
 	ErrInvalidPath      = errors.New("invalid file path")
 	ErrNilPointer       = errors.New("nil pointer detected")
+	ErrInvalidScale     = errors.New("invalid scale factor limit")
 )
 
 // ValidateDimensions checks if image dimensions are within safe bounds
@@ -39,6 +46,26 @@ func ValidateDimensions(width, height int) error {
 	return nil
 }
 
+// ValidateTargetDimensions checks requested output dimensions where one
+// of them may be zero, meaning it follows the source aspect ratio
+func ValidateTargetDimensions(width, height int) error {
+	// Assertion 1: At least one dimension must be given
+	if width == 0 && height == 0 {
+		return fmt.Errorf("%w: width or height is required", ErrInvalidDimension)
+	}
+
+	// Assertion 2: Substitute a valid value for the automatic dimension
+	if width == 0 {
+		width = MinImageDimension
+	}
+
+	if height == 0 {
+		height = MinImageDimension
+	}
+
+	return ValidateDimensions(width, height)
+}
+
 // ValidatePath checks if file path is non-empty and within length limits
 func ValidatePath(path string) error {
 	const maxPathLength = 4096
@@ -56,11 +83,20 @@ func ValidatePath(path string) error {
 	return nil
 }
 
-// ValidateResizeRatio checks if resize ratio is within acceptable range
+// ValidateResizeRatio checks if resize ratio is within the default range
 func ValidateResizeRatio(originalWidth, originalHeight, newWidth, newHeight int) error {
-	const maxScaleFactor = 16.0
-	const minScaleFactor = 0.0625 // 1/16
+	return ValidateScaleRatio(originalWidth, originalHeight, newWidth, newHeight, DefaultMinScaleFactor, DefaultMaxScaleFactor)
+}
 
+// ValidateSupersampleRatio checks resize ratios when multi-pass supersampling
+// is enabled, which lifts the downscale limit but keeps the upscale limit
+func ValidateSupersampleRatio(originalWidth, originalHeight, newWidth, newHeight int) error {
+	return ValidateScaleRatio(originalWidth, originalHeight, newWidth, newHeight, 0.0, DefaultMaxScaleFactor)
+}
+
+// ValidateScaleRatio checks that both scale factors lie within
+// [minScale, maxScale]; a zero minScale allows any downscale
+func ValidateScaleRatio(originalWidth, originalHeight, newWidth, newHeight int, minScale, maxScale float64) error {
 	// Assertion 1: Validate all dimensions first
 	if err := ValidateDimensions(originalWidth, originalHeight); err != nil {
 		return err
@@ -75,13 +111,33 @@ func ValidateResizeRatio(originalWidth, originalHeight, newWidth, newHeight int)
 	widthRatio := float64(newWidth) / float64(originalWidth)
 	heightRatio := float64(newHeight) / float64(originalHeight)
 
-	if widthRatio > maxScaleFactor || widthRatio < minScaleFactor {
+	if widthRatio > maxScale || widthRatio < minScale {
 		return fmt.Errorf("%w: width scale factor out of range", ErrInvalidDimension)
 	}
 
-	if heightRatio > maxScaleFactor || heightRatio < minScaleFactor {
+	if heightRatio > maxScale || heightRatio < minScale {
 		return fmt.Errorf("%w: height scale factor out of range", ErrInvalidDimension)
 	}
 
 	return nil
 }
+
+// ValidateScaleLimits checks configured scale factor limits, which must
+// satisfy 0 < minScale <= 1 <= maxScale
+func ValidateScaleLimits(minScale, maxScale float64) error {
+	// Assertion 1: Reject values that cannot be compared
+	if math.IsNaN(minScale) || math.IsNaN(maxScale) || math.IsInf(maxScale, 0) {
+		return fmt.Errorf("%w: limits must be finite numbers", ErrInvalidScale)
+	}
+
+	// Assertion 2: The limits must bracket a same-size resize
+	if minScale <= 0.0 || minScale > 1.0 {
+		return fmt.Errorf("%w: minimum %g must be in (0, 1]", ErrInvalidScale, minScale)
+	}
+
+	if maxScale < 1.0 {
+		return fmt.Errorf("%w: maximum %g must be at least 1", ErrInvalidScale, maxScale)
+	}
+
+	return nil
+}
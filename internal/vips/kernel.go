@@ -0,0 +1,45 @@
+// Open source image resizer coded by kasuraSH
+package vips
+
+import "errors"
+
+var ErrVips = errors.New("libvips failed")
+
+// Kernel names a libvips resampling kernel
+type Kernel int
+
+const (
+	// KernelLinear is the triangle kernel
+	KernelLinear Kernel = iota + 1
+	// KernelCubic is the Catmull-Rom cubic
+	KernelCubic
+	// KernelMitchell is the Mitchell-Netravali cubic
+	KernelMitchell
+	// KernelLanczos3 is the 3-lobe Lanczos kernel
+	KernelLanczos3
+	// KernelMKS2013 is Magic Kernel Sharp 2013
+	KernelMKS2013
+	// KernelMKS2021 is Magic Kernel Sharp 2021
+	KernelMKS2021
+)
+
+// Image is pixel memory in the layout libvips reads and writes: rows
+// without padding, interleaved bands, 16-bit samples in the byte order of
+// the machine
+type Image struct {
+	Pix    []byte
+	Width  int
+	Height int
+	Bands  int  // 1 for gray, 4 for RGBA
+	Wide   bool // 16-bit samples
+}
+
+// size returns the bytes Pix must hold
+func (m Image) size() int {
+	size := m.Width * m.Height * m.Bands
+	if m.Wide {
+		size *= 2
+	}
+
+	return size
+}
@@ -0,0 +1,153 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build vips && cgo
+
+package vips
+
+/*
+#cgo pkg-config: vips
+#include <stdlib.h>
+#include <string.h>
+#include <vips/vips.h>
+
+#if VIPS_MAJOR_VERSION < 8 || (VIPS_MAJOR_VERSION == 8 && VIPS_MINOR_VERSION < 14)
+#error "the vips backend needs libvips 8.14 or newer"
+#endif
+
+static int gr_init(void) {
+	if (VIPS_INIT("golangresizer")) {
+		return -1;
+	}
+
+	// Cached operations would keep pointing at Go memory after a call
+	vips_cache_set_max(0);
+	return 0;
+}
+
+// gr_resize crops and resizes a packed image into out within one call, so
+// libvips holds no reference to Go memory once it returns
+static int gr_resize(void *pix, int width, int height, int bands, VipsBandFormat format,
+		int left, int top, int cropWidth, int cropHeight, double hscale, double vscale,
+		VipsKernel kernel, void *out, size_t outSize, int *outWidth, int *outHeight) {
+	size_t size = (size_t)width * height * bands * vips_format_sizeof(format);
+	VipsImage *in = vips_image_new_from_memory(pix, size, width, height, bands, format);
+	if (in == NULL) {
+		return -1;
+	}
+
+	VipsImage *crop = NULL;
+	int failed = vips_extract_area(in, &crop, left, top, cropWidth, cropHeight, NULL);
+	g_object_unref(in);
+	if (failed) {
+		return -1;
+	}
+
+	VipsImage *resized = NULL;
+	failed = vips_resize(crop, &resized, hscale, "vscale", vscale, "kernel", kernel, NULL);
+	g_object_unref(crop);
+	if (failed) {
+		return -1;
+	}
+
+	*outWidth = vips_image_get_width(resized);
+	*outHeight = vips_image_get_height(resized);
+
+	size_t written = 0;
+	void *data = vips_image_write_to_memory(resized, &written);
+	g_object_unref(resized);
+	if (data == NULL) {
+		return -1;
+	}
+
+	// A size mismatch is reported by the caller from the dimensions
+	if (written == outSize) {
+		memcpy(out, data, outSize);
+	}
+	g_free(data);
+	return 0;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+)
+
+// kernels maps Kernel to the libvips enumeration
+var kernels = map[Kernel]C.VipsKernel{
+	KernelLinear:   C.VIPS_KERNEL_LINEAR,
+	KernelCubic:    C.VIPS_KERNEL_CUBIC,
+	KernelMitchell: C.VIPS_KERNEL_MITCHELL,
+	KernelLanczos3: C.VIPS_KERNEL_LANCZOS3,
+	KernelMKS2013:  C.VIPS_KERNEL_MKS2013,
+	KernelMKS2021:  C.VIPS_KERNEL_MKS2021,
+}
+
+// start initializes libvips once per process
+var start = sync.OnceValue(func() error {
+	if C.gr_init() != 0 {
+		return fmt.Errorf("%w: %s", ErrVips, lastError())
+	}
+
+	return nil
+})
+
+// Version returns the version of the linked libvips
+func Version() string {
+	return fmt.Sprintf("%d.%d.%d", C.vips_version(0), C.vips_version(1), C.vips_version(2))
+}
+
+// Resize resamples the crop rectangle of in to fill out, whose size,
+// bands and depth must be set and whose Pix is overwritten
+func Resize(in Image, crop image.Rectangle, out Image, kernel Kernel) error {
+	vipsKernel, ok := kernels[kernel]
+
+	// Assertion 1: Validate the kernel, the layouts and the crop
+	if !ok {
+		return fmt.Errorf("%w: unknown kernel %d", ErrVips, kernel)
+	}
+	if len(in.Pix) < in.size() || in.size() == 0 || len(out.Pix) < out.size() || out.size() == 0 {
+		return fmt.Errorf("%w: pixel buffers do not match their sizes", ErrVips)
+	}
+	if in.Bands != out.Bands || in.Wide != out.Wide {
+		return fmt.Errorf("%w: source and destination layouts differ", ErrVips)
+	}
+	if crop.Empty() || !crop.In(image.Rect(0, 0, in.Width, in.Height)) {
+		return fmt.Errorf("%w: crop %v outside %dx%d", ErrVips, crop, in.Width, in.Height)
+	}
+
+	if err := start(); err != nil {
+		return err
+	}
+
+	format := C.VipsBandFormat(C.VIPS_FORMAT_UCHAR)
+	if in.Wide {
+		format = C.VIPS_FORMAT_USHORT
+	}
+
+	var width, height C.int
+	failed := C.gr_resize(unsafe.Pointer(&in.Pix[0]), C.int(in.Width), C.int(in.Height), C.int(in.Bands), format,
+		C.int(crop.Min.X), C.int(crop.Min.Y), C.int(crop.Dx()), C.int(crop.Dy()),
+		C.double(float64(out.Width)/float64(crop.Dx())), C.double(float64(out.Height)/float64(crop.Dy())),
+		vipsKernel, unsafe.Pointer(&out.Pix[0]), C.size_t(out.size()), &width, &height)
+	if failed != 0 {
+		return fmt.Errorf("%w: %s", ErrVips, lastError())
+	}
+
+	// Assertion 2: libvips rounds the output size itself
+	if int(width) != out.Width || int(height) != out.Height {
+		return fmt.Errorf("%w: made %dx%d, want %dx%d", ErrVips, width, height, out.Width, out.Height)
+	}
+
+	return nil
+}
+
+// lastError takes the message libvips recorded for the last failure
+func lastError() string {
+	msg := C.GoString(C.vips_error_buffer())
+	C.vips_error_clear()
+	return msg
+}
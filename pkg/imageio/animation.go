@@ -0,0 +1,219 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// MaxAnimationFrames bounds the frames decoded from or written to one file
+const MaxAnimationFrames = 4096
+
+var ErrInvalidAnimation = errors.New("invalid animation")
+
+// Disposal says what happens to a frame's area before the next frame
+// is drawn, using the GIF values
+type Disposal byte
+
+const (
+	// DisposalUnspecified leaves the choice to the viewer
+	DisposalUnspecified Disposal = 0
+	// DisposalNone keeps the frame in place
+	DisposalNone Disposal = gif.DisposalNone
+	// DisposalBackground clears the frame's area
+	DisposalBackground Disposal = gif.DisposalBackground
+	// DisposalPrevious restores the canvas from before the frame
+	DisposalPrevious Disposal = gif.DisposalPrevious
+)
+
+// Frame is one fully composited frame of an animation
+// Because frames cover the whole canvas, writing them back with their
+// original disposal displays the same sequence
+type Frame struct {
+	Image    image.Image   // Whole canvas as displayed
+	Duration time.Duration // How long the frame stays on screen
+	Disposal Disposal      // Disposal read from the file
+}
+
+// Animation is a sequence of equally sized frames with playback metadata
+// Still images load as a single frame
+type Animation struct {
+	Frames     []Frame
+	LoopCount  int         // Number of plays, zero repeats forever
+	Background color.NRGBA // Canvas color hint stored by the file
+}
+
+// Animated reports whether the animation has more than one frame
+func (a *Animation) Animated() bool {
+	return len(a.Frames) > 1
+}
+
+// LoadAnimation loads every frame of an animated file, or a still image
+// as a single frame
+func LoadAnimation(path string) (*Animation, error) {
+	return LoadAnimationWithOptions(path, DecodeOptions{})
+}
+
+// LoadAnimationWithOptions loads every frame like LoadAnimation, reading
+// the file as opts.MemoryMap selects and checking opts.MaxPixels from the
+// header; the shrink and orientation options apply to still images
+// loaded with LoadImageWithOptions only
+func LoadAnimationWithOptions(path string, opts DecodeOptions) (*Animation, error) {
+	file, err := openInput(path, opts.MemoryMap)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			// Log error but don't override return error
+		}
+	}()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAnimation(file, ext, opts)
+}
+
+// decodeAnimation decodes every frame of r in the format named by ext,
+// checking opts.MaxPixels first
+func decodeAnimation(r io.ReadSeeker, ext string, opts DecodeOptions) (*Animation, error) {
+	if opts.MaxPixels > 0 {
+		if err := checkPixels(r, ext, opts.MaxPixels); err != nil {
+			return nil, err
+		}
+	}
+
+	// Assertion 1: Only WebP and GIF carry animations
+	if ext != ".webp" && ext != ".gif" {
+		img, err := decodeImage(r, ext)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Animation{Frames: []Frame{{Image: img}}}, nil
+	}
+
+	if ext == ".gif" {
+		return decodeGIF(r)
+	}
+
+	return decodeWebP(r)
+}
+
+// SaveAnimation saves every frame of anim with its timing and loop count,
+// choosing the encoder from opts like SaveImage
+// A single frame is saved as a still image in any supported format
+func SaveAnimation(path string, anim *Animation, opts ...EncodeOptions) error {
+	return SaveAnimationContext(context.Background(), path, anim, opts...)
+}
+
+// SaveAnimationContext saves anim like SaveAnimation, giving up when ctx
+// is done before the file is written
+func SaveAnimationContext(ctx context.Context, path string, anim *Animation, opts ...EncodeOptions) error {
+	// Assertion 1: Validate path
+	if err := validator.ValidatePath(path); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	options, err := optionsOf(opts)
+	if err != nil {
+		return err
+	}
+
+	data, err := untilDone(ctx, func() ([]byte, error) {
+		return encodeAnimation(anim, options.format(path), options)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// SaveAnimationWithOptions saves every frame of anim
+//
+// Deprecated: SaveAnimation takes the options directly
+func SaveAnimationWithOptions(path string, anim *Animation, opts EncodeOptions) error {
+	return SaveAnimation(path, anim, opts)
+}
+
+// EncodeAnimation encodes anim in memory in the format named by
+// opts.Format, like EncodeImage
+func EncodeAnimation(anim *Animation, opts EncodeOptions) ([]byte, error) {
+	// Assertion 1: Without a path the format must be given
+	if opts.Format == "" {
+		return nil, fmt.Errorf("%w: no output format given", ErrUnsupportedFormat)
+	}
+
+	return encodeAnimation(anim, opts.Format, opts)
+}
+
+// encodeAnimation validates the frames and encodes them as ext; a single
+// frame is encoded as a still image with opts
+func encodeAnimation(anim *Animation, ext string, opts EncodeOptions) ([]byte, error) {
+	// Assertion 1: Validate frames
+	if err := anim.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	if !anim.Animated() {
+		return encodeImage(anim.Frames[0].Image, ext, opts)
+	}
+
+	// Assertion 2: Only WebP and GIF output can hold the frames
+	var buf bytes.Buffer
+	var err error
+
+	switch ext {
+	case ".webp":
+		err = encodeAnimatedWebP(&buf, anim)
+	case ".gif":
+		err = encodeAnimatedGIF(&buf, anim)
+	default:
+		return nil, fmt.Errorf("%w: animations can only be saved as .webp or .gif, not %s", ErrUnsupportedFormat, ext)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// validate checks that the frames exist and share one valid size
+func (a *Animation) validate() error {
+	// Assertion 1: Bound the frame count
+	if a == nil || len(a.Frames) == 0 || len(a.Frames) > MaxAnimationFrames {
+		return fmt.Errorf("%w: needs 1-%d frames", ErrInvalidAnimation, MaxAnimationFrames)
+	}
+
+	if a.Frames[0].Image == nil {
+		return fmt.Errorf("%w: frame 0 is nil", ErrInvalidAnimation)
+	}
+
+	size := a.Frames[0].Image.Bounds().Size()
+	if err := validator.ValidateDimensions(size.X, size.Y); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAnimation, err)
+	}
+
+	// Assertion 2: Every frame covers the same canvas
+	for i := 1; i < len(a.Frames); i++ {
+		if a.Frames[i].Image == nil || a.Frames[i].Image.Bounds().Size() != size {
+			return fmt.Errorf("%w: frame %d does not match the %dx%d canvas", ErrInvalidAnimation, i, size.X, size.Y)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,431 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// azblobScheme starts the paths naming Azure Blob Storage blobs,
+// azblob://container/blob
+const azblobScheme = "azblob://"
+
+const (
+	// azureVersion is the Blob service REST version requests ask for
+	azureVersion = "2021-08-06"
+	// azureResource is the audience of Entra ID tokens for storage
+	azureResource = "https://storage.azure.com/"
+	// azureMaxPages bounds the pages of one listing, 5000 blobs each
+	azureMaxPages = 10000
+	// azuriteAccount and azuriteKey are the well-known account of the
+	// Azurite emulator, which UseDevelopmentStorage=true names
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// azureClient sends requests to the Blob service of one storage account,
+// authorized by its Shared Key, a SAS token or an Entra ID token
+type azureClient struct {
+	http     *http.Client
+	endpoint *url.URL // The account, such as https://account.blob.core.windows.net
+	account  string
+	key      []byte      // Shared Key, nil when another way is used
+	sas      string      // Shared access signature query, without the ?
+	tokens   *tokenCache // Entra ID tokens when there is neither key nor SAS
+}
+
+// newAzureClient returns the Storage of azblob:// paths, configured by
+// AZURE_STORAGE_CONNECTION_STRING, or by AZURE_STORAGE_ACCOUNT with
+// AZURE_STORAGE_KEY, AZURE_STORAGE_SAS_TOKEN or an Entra ID identity
+func newAzureClient() (Storage, error) {
+	c := &azureClient{http: &http.Client{}}
+
+	settings := map[string]string{
+		"AccountName":           os.Getenv("AZURE_STORAGE_ACCOUNT"),
+		"AccountKey":            firstNonEmpty(os.Getenv("AZURE_STORAGE_KEY"), os.Getenv("AZURE_STORAGE_ACCESS_KEY")),
+		"SharedAccessSignature": os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+	}
+	if conn := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); conn != "" {
+		settings = parseConnectionString(conn)
+	}
+
+	c.account = settings["AccountName"]
+	endpoint := settings["BlobEndpoint"]
+
+	// Assertion 1: An account to send requests to, named for Shared Key
+	if c.account == "" && (endpoint == "" || settings["AccountKey"] != "") {
+		return nil, fmt.Errorf("%w: no Azure storage account; set AZURE_STORAGE_CONNECTION_STRING or AZURE_STORAGE_ACCOUNT", ErrRemote)
+	}
+
+	if endpoint == "" {
+		protocol := firstNonEmpty(settings["DefaultEndpointsProtocol"], "https")
+		endpoint = protocol + "://" + c.account + ".blob." + firstNonEmpty(settings["EndpointSuffix"], "core.windows.net")
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid Azure blob endpoint %q", ErrRemote, endpoint)
+	}
+	c.endpoint = u
+
+	switch {
+	case settings["AccountKey"] != "":
+		if c.key, err = base64.StdEncoding.DecodeString(settings["AccountKey"]); err != nil {
+			return nil, fmt.Errorf("%w: Azure account key is not base64: %v", ErrRemote, err)
+		}
+	case settings["SharedAccessSignature"] != "":
+		c.sas = strings.TrimPrefix(settings["SharedAccessSignature"], "?")
+	default:
+		c.tokens = &tokenCache{source: entraToken}
+	}
+
+	return c, nil
+}
+
+// parseConnectionString returns the settings of a storage connection
+// string, Name=value pairs separated by semicolons
+func parseConnectionString(conn string) map[string]string {
+	settings := make(map[string]string)
+	for _, pair := range strings.Split(conn, ";") {
+		// Values such as keys and signatures hold = themselves
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok {
+			settings[name] = value
+		}
+	}
+
+	if strings.EqualFold(settings["UseDevelopmentStorage"], "true") {
+		settings["AccountName"] = azuriteAccount
+		settings["AccountKey"] = azuriteKey
+		settings["BlobEndpoint"] = "http://127.0.0.1:10000/" + azuriteAccount
+	}
+
+	return settings
+}
+
+// entraToken returns an Entra ID token for storage as the Azure SDKs
+// would: a service principal secret, then a workload identity, then the
+// managed identity of the machine
+func entraToken(ctx context.Context) (bearerToken, error) {
+	client := &http.Client{Timeout: time.Minute}
+	tenant, clientID := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID")
+	endpoint := strings.TrimSuffix(firstNonEmpty(os.Getenv("AZURE_AUTHORITY_HOST"), "https://login.microsoftonline.com"), "/") + "/" + url.PathEscape(tenant) + "/oauth2/v2.0/token"
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "scope": {azureResource + ".default"}}
+
+	if secret := os.Getenv("AZURE_CLIENT_SECRET"); secret != "" && tenant != "" && clientID != "" {
+		form.Set("client_secret", secret)
+		return postToken(ctx, client, endpoint, form)
+	}
+
+	if file := os.Getenv("AZURE_FEDERATED_TOKEN_FILE"); file != "" && tenant != "" && clientID != "" {
+		// The file is renewed by the cluster, so it is read every time
+		assertion, err := os.ReadFile(file)
+		if err != nil {
+			return bearerToken{}, fmt.Errorf("%w: Azure federated token: %v", ErrRemote, err)
+		}
+
+		form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+		return postToken(ctx, client, endpoint, form)
+	}
+
+	return managedIdentityToken(ctx, clientID)
+}
+
+// managedIdentityToken asks the managed identity endpoint of App Service
+// or Functions, or else the instance metadata service of a VM, for a
+// token of the identity clientID names, or the only one when empty
+func managedIdentityToken(ctx context.Context, clientID string) (bearerToken, error) {
+	query := url.Values{"resource": {azureResource}}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	endpoint, header, value := "http://169.254.169.254/metadata/identity/oauth2/token", "Metadata", "true"
+	query.Set("api-version", "2018-02-01")
+	if identity := os.Getenv("IDENTITY_ENDPOINT"); identity != "" && os.Getenv("IDENTITY_HEADER") != "" {
+		endpoint, header, value = identity, "X-Identity-Header", os.Getenv("IDENTITY_HEADER")
+		query.Set("api-version", "2019-08-01")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return bearerToken{}, fmt.Errorf("%w: %v", ErrRemote, err)
+	}
+	req.Header.Set(header, value)
+
+	var resp tokenResponse
+	if err := getJSON(&http.Client{Timeout: metadataTimeout}, req, &resp); err != nil {
+		return bearerToken{}, fmt.Errorf("%w: no Azure credentials found; set AZURE_STORAGE_KEY, AZURE_STORAGE_SAS_TOKEN or AZURE_CLIENT_SECRET (managed identity: %v)", ErrRemote, err)
+	}
+
+	return resp.token("the managed identity endpoint")
+}
+
+// azureLocation is a container, or a blob in it when the name is set
+type azureLocation struct {
+	container string
+	name      string
+}
+
+// String returns the azblob:// path of l
+func (l azureLocation) String() string {
+	return azblobScheme + l.container + "/" + l.name
+}
+
+// Get reads the blob at key whole, refusing one over limit bytes
+func (c *azureClient) Get(ctx context.Context, bucket, key string, limit int64) ([]byte, error) {
+	loc := azureLocation{container: bucket, name: key}
+	resp, err := c.do(ctx, http.MethodGet, loc, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Assertion 1: Refuse oversized blobs before reading them
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("%w: %s is %d bytes, over the %d byte limit", ErrTooLarge, loc, resp.ContentLength, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading %s: %v", ErrRemote, loc, err)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s is over the %d byte limit", ErrTooLarge, loc, limit)
+	}
+
+	return data, nil
+}
+
+// Put writes data as the block blob at key in one request, which
+// replaces the blob only once it is complete
+func (c *azureClient) Put(ctx context.Context, bucket, key string, data []byte, contentType string) error {
+	loc := azureLocation{container: bucket, name: key}
+	header := http.Header{"X-Ms-Blob-Type": {"BlockBlob"}}
+	if contentType != "" {
+		header.Set("X-Ms-Blob-Content-Type", contentType)
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, loc, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Stat returns the size and ETag of the blob at key
+func (c *azureClient) Stat(ctx context.Context, bucket, key string) (RemoteObject, error) {
+	loc := azureLocation{container: bucket, name: key}
+	resp, err := c.do(ctx, http.MethodHead, loc, nil, nil, nil)
+	if err != nil {
+		return RemoteObject{}, err
+	}
+	resp.Body.Close()
+
+	return RemoteObject{Name: key, Size: resp.ContentLength, ETag: strings.Trim(resp.Header.Get("ETag"), `"`)}, nil
+}
+
+// azureListResult is a page of List Blobs
+type azureListResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			ContentLength int64  `xml:"Content-Length"`
+			ETag          string `xml:"Etag"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// List returns the blobs whose names start with prefix, named relative
+// to it, up to limit of them; names ending in a slash are the folders of
+// consoles and are left out
+func (c *azureClient) List(ctx context.Context, bucket, prefix string, limit int) ([]RemoteObject, error) {
+	loc := azureLocation{container: bucket, name: prefix}
+	var objects []RemoteObject
+	marker := ""
+	for page := 0; page < azureMaxPages; page++ {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, azureLocation{container: bucket}, query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result azureListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: listing %s: %v", ErrRemote, loc, err)
+		}
+
+		for _, blob := range result.Blobs {
+			if strings.HasSuffix(blob.Name, "/") {
+				continue
+			}
+
+			// Assertion 1: Bound the listing
+			if len(objects) == limit {
+				return nil, fmt.Errorf("%w: %s holds more than %d blobs", ErrRemote, loc, limit)
+			}
+
+			name := strings.TrimPrefix(blob.Name, prefix)
+			objects = append(objects, RemoteObject{Name: name, Size: blob.Properties.ContentLength, ETag: strings.Trim(blob.Properties.ETag, `"`)})
+		}
+
+		if result.NextMarker == "" {
+			return objects, nil
+		}
+		marker = result.NextMarker
+	}
+
+	return nil, fmt.Errorf("%w: listing %s did not end after %d pages", ErrRemote, loc, azureMaxPages)
+}
+
+// do sends an authorized request for loc, retrying while the Blob service
+// is busy; a missing blob wraps fs.ErrNotExist and any other failure
+// ErrRemote
+func (c *azureClient) do(ctx context.Context, method string, loc azureLocation, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	token := ""
+	if c.tokens != nil {
+		var err error
+		if token, err = c.tokens.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := sendRetrying(ctx, c.http, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, c.endpoint.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRemote, err)
+		}
+
+		req.URL = c.url(loc, query)
+		if body == nil {
+			req.Body, req.ContentLength = http.NoBody, 0
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.Header.Set("X-Ms-Version", azureVersion)
+		req.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+
+		switch {
+		case c.key != nil:
+			req.Header.Set("Authorization", "SharedKey "+c.account+":"+c.signature(req))
+		case token != "":
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s: %v", ErrRemote, method, loc, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, azureError(resp, method, loc)
+	}
+
+	return resp, nil
+}
+
+// url returns the URL of loc below the account endpoint, with the query
+// and any shared access signature
+func (c *azureClient) url(loc azureLocation, query url.Values) *url.URL {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + loc.container
+	if loc.name != "" {
+		u.Path += "/" + loc.name
+	}
+	u.RawPath = ""
+
+	u.RawQuery = query.Encode()
+	if c.sas != "" {
+		u.RawQuery = strings.TrimPrefix(u.RawQuery+"&"+c.sas, "&")
+	}
+
+	return &u
+}
+
+// signature returns the Shared Key signature of req: the HMAC-SHA256 of
+// its method, standard headers, x-ms- headers and resource
+func (c *azureClient) signature(req *http.Request) string {
+	length := ""
+	if req.ContentLength > 0 {
+		length = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	var b strings.Builder
+	b.WriteString(req.Method + "\n")
+	for _, name := range []string{"Content-Encoding", "Content-Language"} {
+		b.WriteString(req.Header.Get(name) + "\n")
+	}
+	b.WriteString(length + "\n")
+	for _, name := range []string{"Content-Md5", "Content-Type", "Date", "If-Modified-Since", "If-Match", "If-None-Match", "If-Unmodified-Since", "Range"} {
+		b.WriteString(req.Header.Get(name) + "\n")
+	}
+
+	var names []string
+	for name := range req.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-ms-") {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	// Parameter names are lowered before they are sorted and grouped
+	b.WriteString("/" + c.account + req.URL.EscapedPath())
+	query := make(map[string][]string)
+	for name, values := range req.URL.Query() {
+		query[strings.ToLower(name)] = append(query[strings.ToLower(name)], values...)
+	}
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	slices.Sort(params)
+	for _, name := range params {
+		values := query[name]
+		slices.Sort(values)
+		b.WriteString("\n" + name + ":" + strings.Join(values, ","))
+	}
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// azureError returns the error of a failed response, closing it
+func azureError(resp *http.Response, method string, loc azureLocation) error {
+	code, detail := xmlError(resp)
+	if resp.StatusCode == http.StatusNotFound && code != "ContainerNotFound" && loc.name != "" {
+		return fmt.Errorf("%w: %s %s: %w", ErrRemote, method, loc, fs.ErrNotExist)
+	}
+
+	return fmt.Errorf("%w: %s %s: %s", ErrRemote, method, loc, detail)
+}
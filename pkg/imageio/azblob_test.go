@@ -0,0 +1,198 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// isolateAzure clears the settings of Azure credentials
+func isolateAzure(t *testing.T) {
+	t.Helper()
+
+	for _, name := range []string{
+		"AZURE_STORAGE_CONNECTION_STRING", "AZURE_STORAGE_ACCOUNT", "AZURE_STORAGE_KEY", "AZURE_STORAGE_ACCESS_KEY",
+		"AZURE_STORAGE_SAS_TOKEN", "AZURE_TENANT_ID", "AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET",
+		"AZURE_FEDERATED_TOKEN_FILE", "AZURE_AUTHORITY_HOST", "IDENTITY_ENDPOINT", "IDENTITY_HEADER",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+// testAzureClient returns the client AZURE_STORAGE_CONNECTION_STRING
+// set to conn configures
+func testAzureClient(t *testing.T, conn string) *azureClient {
+	t.Helper()
+
+	isolateAzure(t)
+	t.Setenv("AZURE_STORAGE_CONNECTION_STRING", conn)
+
+	storage, err := newAzureClient()
+	if err != nil {
+		t.Fatalf("newAzureClient: %v", err)
+	}
+
+	return storage.(*azureClient)
+}
+
+func TestSharedKeyKnownAnswers(t *testing.T) {
+	c := testAzureClient(t, "UseDevelopmentStorage=true")
+
+	// The signatures were computed independently from the Shared Key
+	// string-to-sign of the Blob service reference, with the Azurite key
+	cases := []struct {
+		name     string
+		method   string
+		loc      azureLocation
+		query    url.Values
+		header   http.Header
+		length   int64
+		resource string
+		want     string
+	}{
+		{
+			name:     "ranged get",
+			method:   http.MethodGet,
+			loc:      azureLocation{container: "photos", name: "cat.png"},
+			header:   http.Header{"Range": {"bytes=0-1023"}},
+			resource: "/devstoreaccount1/devstoreaccount1/photos/cat.png",
+			want:     "7QtjERne4oMOTKmy8vKHcOvBHj9IJip5fb4495SKueE=",
+		},
+		{
+			name:     "put with length and escaped name",
+			method:   http.MethodPut,
+			loc:      azureLocation{container: "photos", name: "a b+.png"},
+			header:   http.Header{"Content-Type": {"image/png"}, "X-Ms-Blob-Type": {"BlockBlob"}},
+			length:   11,
+			resource: "/devstoreaccount1/devstoreaccount1/photos/a%20b+.png",
+			want:     "SLkyYtxYo7tCfQE78uU2PtfmEYKgXYscfGudUOmzlPE=",
+		},
+		{
+			name:     "list with sorted query",
+			method:   http.MethodGet,
+			loc:      azureLocation{container: "photos"},
+			query:    url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {"thumbs/a b"}, "marker": {"2!96!MDAw"}},
+			resource: "/devstoreaccount1/devstoreaccount1/photos",
+			want:     "R4LM7FkZfhndsRKvr7dHDcd82sJGH9AKIARKb4NiThY=",
+		},
+		{
+			name:     "conditional head with mixed case query",
+			method:   http.MethodHead,
+			loc:      azureLocation{container: "photos", name: "cat.png"},
+			query:    url.Values{"Timeout": {"30"}, "comp": {"metadata"}},
+			header:   http.Header{"If-Match": {`"0x8D1"`}, "If-None-Match": {"*"}},
+			resource: "/devstoreaccount1/devstoreaccount1/photos/cat.png",
+			want:     "HQe9YBQMkuBaO5VY03fAWKMG5oDRtGA5s/uSaxozz1M=",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.URL = c.url(tc.loc, tc.query)
+			req.ContentLength = tc.length
+			for name, values := range tc.header {
+				req.Header[name] = values
+			}
+			req.Header.Set("X-Ms-Version", azureVersion)
+			req.Header.Set("X-Ms-Date", "Fri, 24 May 2013 00:00:00 GMT")
+
+			if resource := "/" + c.account + req.URL.EscapedPath(); resource != tc.resource {
+				t.Errorf("canonical resource %s, want %s", resource, tc.resource)
+			}
+			if got := c.signature(req); got != tc.want {
+				t.Errorf("signature %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseConnectionString(t *testing.T) {
+	settings := parseConnectionString("DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=a2V5==;EndpointSuffix=core.chinacloudapi.cn")
+	if settings["AccountName"] != "acct" || settings["AccountKey"] != "a2V5==" {
+		t.Errorf("settings %v", settings)
+	}
+
+	c := testAzureClient(t, "DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=a2V5;EndpointSuffix=core.chinacloudapi.cn")
+	if got := c.endpoint.String(); got != "https://acct.blob.core.chinacloudapi.cn" {
+		t.Errorf("endpoint %s, want the account below the suffix", got)
+	}
+	if string(c.key) != "key" {
+		t.Errorf("key %q, want it base64 decoded", c.key)
+	}
+
+	// Assertion 1: A key that is not base64 is refused
+	isolateAzure(t)
+	t.Setenv("AZURE_STORAGE_CONNECTION_STRING", "AccountName=acct;AccountKey=not base64")
+	if _, err := newAzureClient(); err == nil {
+		t.Error("connection string with a bad key accepted")
+	}
+}
+
+func TestSASIsSentWithoutSignature(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("SAS request authorized with %q", auth)
+		}
+		if r.URL.Path != "/acct/photos/a b.png" || r.URL.Query().Get("sig") != "c2ln" || r.URL.Query().Get("sv") != "2021-08-06" {
+			t.Errorf("SAS request for %s", r.URL)
+		}
+		w.Write([]byte("image"))
+	}))
+	defer server.Close()
+
+	c := testAzureClient(t, "BlobEndpoint="+server.URL+"/acct;SharedAccessSignature=?sv=2021-08-06&sig=c2ln")
+	data, err := c.Get(context.Background(), "photos", "a b.png", 100)
+	if err != nil || string(data) != "image" {
+		t.Errorf("Get = %q, %v", data, err)
+	}
+}
+
+func TestEntraTokenRequests(t *testing.T) {
+	isolateAzure(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		switch {
+		case r.URL.Path == "/tenant/oauth2/v2.0/token" && r.FormValue("client_secret") != "":
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "app" || r.FormValue("scope") != azureResource+".default" {
+				t.Errorf("client secret request %v", r.PostForm)
+			}
+			token = "secret"
+		case r.URL.Path == "/identity":
+			query := r.URL.Query()
+			if r.Header.Get("X-Identity-Header") != "header" || query.Get("resource") != azureResource || query.Get("client_id") != "app" || query.Get("api-version") != "2019-08-01" {
+				t.Errorf("managed identity request %s", r.URL)
+			}
+			token = "identity"
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"` + token + `","expires_in":"3600"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AZURE_CLIENT_ID", "app")
+	t.Setenv("IDENTITY_ENDPOINT", server.URL+"/identity")
+	t.Setenv("IDENTITY_HEADER", "header")
+	if token, err := entraToken(context.Background()); err != nil || token.value != "identity" {
+		t.Errorf("without a secret got %q, %v, want the managed identity", token.value, err)
+	}
+
+	t.Setenv("AZURE_TENANT_ID", "tenant")
+	t.Setenv("AZURE_CLIENT_SECRET", "shh")
+	t.Setenv("AZURE_AUTHORITY_HOST", server.URL+"/")
+	if token, err := entraToken(context.Background()); err != nil || token.value != "secret" {
+		t.Errorf("with a secret got %q, %v, want the service principal", token.value, err)
+	}
+}
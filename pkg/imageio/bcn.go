@@ -0,0 +1,393 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// bcBlock is the decoded 4x4 texel block of a block compressed texture,
+// sixteen RGBA texels in row order
+type bcBlock [16][4]uint8
+
+// bc7Mode describes the field layout of one BC7 block mode
+type bc7Mode struct {
+	subsets        int
+	partitionBits  int
+	rotationBits   int
+	selectorBits   int
+	colorBits      int
+	alphaBits      int
+	endpointPBits  bool
+	sharedPBits    bool
+	indexBits      int
+	secondaryIndex int
+}
+
+// bc7Modes lists the eight BC7 block modes
+var bc7Modes = [8]bc7Mode{
+	{subsets: 3, partitionBits: 4, colorBits: 4, endpointPBits: true, indexBits: 3},
+	{subsets: 2, partitionBits: 6, colorBits: 6, sharedPBits: true, indexBits: 3},
+	{subsets: 3, partitionBits: 6, colorBits: 5, indexBits: 2},
+	{subsets: 2, partitionBits: 6, colorBits: 7, endpointPBits: true, indexBits: 2},
+	{subsets: 1, rotationBits: 2, selectorBits: 1, colorBits: 5, alphaBits: 6, indexBits: 2, secondaryIndex: 3},
+	{subsets: 1, rotationBits: 2, colorBits: 7, alphaBits: 8, indexBits: 2, secondaryIndex: 2},
+	{subsets: 1, colorBits: 7, alphaBits: 7, endpointPBits: true, indexBits: 4},
+	{subsets: 2, partitionBits: 6, colorBits: 5, alphaBits: 5, endpointPBits: true, indexBits: 2},
+}
+
+// bc7Weights holds the interpolation weights for 2, 3 and 4 bit indices
+var bc7Weights = [5][]int{
+	2: {0, 21, 43, 64},
+	3: {0, 9, 18, 27, 37, 46, 55, 64},
+	4: {0, 4, 9, 13, 17, 21, 26, 30, 34, 38, 43, 47, 51, 55, 60, 64},
+}
+
+// bc7Partitions2 holds the two subset partitions, bit i is the subset of
+// texel i
+var bc7Partitions2 = [64]uint16{
+	0xCCCC, 0x8888, 0xEEEE, 0xECC8, 0xC880, 0xFEEC, 0xFEC8, 0xEC80,
+	0xC800, 0xFFEC, 0xFE80, 0xE800, 0xFFE8, 0xFF00, 0xFFF0, 0xF000,
+	0xF710, 0x008E, 0x7100, 0x08CE, 0x008C, 0x7310, 0x3100, 0x8CCE,
+	0x088C, 0x3110, 0x6666, 0x366C, 0x17E8, 0x0FF0, 0x718E, 0x399C,
+	0xAAAA, 0xF0F0, 0x5A5A, 0x33CC, 0x3C3C, 0x55AA, 0x9696, 0xA55A,
+	0x73CE, 0x13C8, 0x324C, 0x3BDC, 0x6996, 0xC33C, 0x9966, 0x0660,
+	0x0272, 0x04E4, 0x4E40, 0x2720, 0xC936, 0x936C, 0x39C6, 0x639C,
+	0x9336, 0x9CC6, 0x817E, 0xE718, 0xCCF0, 0x0FCC, 0x7744, 0xEE22,
+}
+
+// bc7Partitions3 holds the three subset partitions, one digit per texel
+var bc7Partitions3 = [64]string{
+	"0011001102210222", "0001001122112221", "0000200122112211", "0222002200110111",
+	"0000000011221122", "0011001100220022", "0022002211111111", "0011001122112211",
+	"0000000011112222", "0000111111112222", "0000111122222222", "0012001200120012",
+	"0112011201120112", "0122012201220122", "0011011211221222", "0011200122002220",
+	"0001001101121122", "0111001120012200", "0000112211221122", "0022002200221111",
+	"0111011102220222", "0001000122212221", "0000001101220122", "0000110022102210",
+	"0122012200110000", "0012001211222222", "0110122112210110", "0000011012211221",
+	"0022110211020022", "0110011020022222", "0011012201220011", "0000200022112221",
+	"0000000211221222", "0222002200120011", "0011001200220222", "0120012001200120",
+	"0000111122220000", "0120120120120120", "0120201212010120", "0011220011220011",
+	"0011112222000011", "0101010122222222", "0000000021212121", "0022112200221122",
+	"0022001100220011", "0220122102201221", "0101222222220101", "0000212121212121",
+	"0101010101012222", "0222011102220111", "0002111200021112", "0000211221122112",
+	"0222011101110222", "0002111211120002", "0110011001102222", "0000000021122112",
+	"0110011022222222", "0022001100110022", "0022112211220022", "0000000000002112",
+	"0002000100020001", "0222122202221222", "0101222222222222", "0111201122012220",
+}
+
+// bc7Anchors2 holds the anchor texel of the second subset of each two
+// subset partition
+var bc7Anchors2 = [64]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 2, 8, 2, 2, 8, 8, 15, 2, 8, 2, 2, 8, 8, 2, 2,
+	15, 15, 6, 8, 2, 8, 15, 15, 2, 8, 2, 2, 2, 15, 15, 6,
+	6, 2, 6, 8, 15, 15, 2, 2, 15, 15, 15, 15, 15, 2, 2, 15,
+}
+
+// bc7Anchors3 holds the anchor texels of the second and third subsets of
+// each three subset partition
+var bc7Anchors3 = [2][64]uint8{
+	{
+		3, 3, 15, 15, 8, 3, 15, 15, 8, 8, 6, 6, 6, 5, 3, 3,
+		3, 3, 8, 15, 3, 3, 6, 10, 5, 8, 8, 6, 8, 5, 15, 15,
+		8, 15, 3, 5, 6, 10, 8, 15, 15, 3, 15, 5, 15, 15, 15, 15,
+		3, 15, 5, 5, 5, 8, 5, 10, 5, 10, 8, 13, 15, 12, 3, 3,
+	},
+	{
+		15, 8, 8, 3, 15, 15, 3, 8, 15, 15, 15, 15, 15, 15, 15, 8,
+		15, 8, 15, 3, 15, 8, 15, 8, 3, 15, 6, 10, 15, 15, 10, 8,
+		15, 3, 15, 10, 10, 8, 9, 10, 6, 15, 8, 15, 3, 6, 6, 8,
+		15, 3, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 3, 15, 15, 8,
+	},
+}
+
+// decodeBC1 decodes an 8 byte BC1 color block; punchThrough allows the
+// three color mode with transparent black, which BC2 and BC3 never use
+func decodeBC1(b []byte, out *bcBlock, punchThrough bool) {
+	c0 := binary.LittleEndian.Uint16(b[0:2])
+	c1 := binary.LittleEndian.Uint16(b[2:4])
+
+	var palette [4][4]int
+	palette[0] = expand565(c0)
+	palette[1] = expand565(c1)
+
+	// Assertion 1: The endpoint order selects four colors or three plus transparent
+	if c0 > c1 || !punchThrough {
+		for c := 0; c < 3; c++ {
+			palette[2][c] = (2*palette[0][c] + palette[1][c] + 1) / 3
+			palette[3][c] = (palette[0][c] + 2*palette[1][c] + 1) / 3
+		}
+		palette[2][3], palette[3][3] = 255, 255
+	} else {
+		for c := 0; c < 3; c++ {
+			palette[2][c] = (palette[0][c] + palette[1][c] + 1) / 2
+		}
+		palette[2][3] = 255
+	}
+
+	indices := binary.LittleEndian.Uint32(b[4:8])
+	for i := 0; i < 16; i++ {
+		p := palette[indices>>(2*i)&3]
+		out[i] = [4]uint8{uint8(p[0]), uint8(p[1]), uint8(p[2]), uint8(p[3])}
+	}
+}
+
+// expand565 widens a 5:6:5 color to 8 bits per channel
+func expand565(c uint16) [4]int {
+	r, g, b := int(c>>11&0x1F), int(c>>5&0x3F), int(c&0x1F)
+	return [4]int{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255}
+}
+
+// decodeBC2 decodes a 16 byte BC2 block of explicit 4 bit alpha and color
+func decodeBC2(b []byte, out *bcBlock) {
+	decodeBC1(b[8:16], out, false)
+
+	alpha := binary.LittleEndian.Uint64(b[0:8])
+	for i := 0; i < 16; i++ {
+		out[i][3] = uint8(alpha>>(4*i)&0xF) * 17
+	}
+}
+
+// decodeBC3 decodes a 16 byte BC3 block of interpolated alpha and color
+func decodeBC3(b []byte, out *bcBlock) {
+	decodeBC1(b[8:16], out, false)
+
+	var alpha [16]uint8
+	decodeBC4Channel(b[0:8], &alpha, false)
+	for i := 0; i < 16; i++ {
+		out[i][3] = alpha[i]
+	}
+}
+
+// decodeBC4 decodes an 8 byte BC4 block into gray texels
+func decodeBC4(b []byte, out *bcBlock, signed bool) {
+	var red [16]uint8
+	decodeBC4Channel(b, &red, signed)
+
+	for i := 0; i < 16; i++ {
+		out[i] = [4]uint8{red[i], red[i], red[i], 255}
+	}
+}
+
+// decodeBC5 decodes a 16 byte BC5 block into red and green, leaving blue
+// at zero
+func decodeBC5(b []byte, out *bcBlock, signed bool) {
+	var red, green [16]uint8
+	decodeBC4Channel(b[0:8], &red, signed)
+	decodeBC4Channel(b[8:16], &green, signed)
+
+	for i := 0; i < 16; i++ {
+		out[i] = [4]uint8{red[i], green[i], 0, 255}
+	}
+}
+
+// decodeBC4Channel decodes one 8 byte channel block shared by BC3, BC4
+// and BC5; signed values from -1 to 1 are mapped onto 0 to 255
+func decodeBC4Channel(b []byte, out *[16]uint8, signed bool) {
+	var palette [8]int
+	low, high := 0, 255
+
+	if signed {
+		// -128 and -127 both mean -1
+		palette[0], palette[1] = max(int(int8(b[0])), -127), max(int(int8(b[1])), -127)
+		low, high = -127, 127
+	} else {
+		palette[0], palette[1] = int(b[0]), int(b[1])
+	}
+
+	// Assertion 1: The endpoint order selects eight steps or six plus the extremes
+	if palette[0] > palette[1] {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = roundDiv((7-i)*palette[0]+i*palette[1], 7)
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = roundDiv((5-i)*palette[0]+i*palette[1], 5)
+		}
+		palette[6], palette[7] = low, high
+	}
+
+	var indices uint64
+	for i := 7; i >= 2; i-- {
+		indices = indices<<8 | uint64(b[i])
+	}
+
+	for i := 0; i < 16; i++ {
+		v := palette[indices>>(3*i)&7]
+		if signed {
+			v = ((v+127)*255 + 127) / 254
+		}
+		out[i] = uint8(v)
+	}
+}
+
+// roundDiv divides n by d rounding half away from zero
+func roundDiv(n, d int) int {
+	if n < 0 {
+		return -((-n + d/2) / d)
+	}
+
+	return (n + d/2) / d
+}
+
+// bitReader reads the fields of a 128 bit block from the low bit up
+type bitReader struct {
+	lo, hi uint64
+}
+
+// read returns the next n bits, n at most 8
+func (r *bitReader) read(n int) int {
+	v := int(r.lo & (1<<n - 1))
+	r.lo = r.lo>>n | r.hi<<(64-n)
+	r.hi >>= n
+	return v
+}
+
+// decodeBC7 decodes a 16 byte BC7 block; the reserved mode decodes to
+// transparent black
+func decodeBC7(b []byte, out *bcBlock) {
+	// Assertion 1: The lowest set bit of the first byte selects the mode
+	if b[0] == 0 {
+		*out = bcBlock{}
+		return
+	}
+
+	index := bits.TrailingZeros8(b[0])
+	mode := bc7Modes[index]
+	r := bitReader{lo: binary.LittleEndian.Uint64(b[0:8]), hi: binary.LittleEndian.Uint64(b[8:16])}
+	r.read(index + 1)
+
+	partition := r.read(mode.partitionBits)
+	rotation := r.read(mode.rotationBits)
+	selector := r.read(mode.selectorBits)
+
+	// Endpoints are stored channel by channel, red for every subset first
+	var endpoints [3][2][4]int
+	channels := 3
+	if mode.alphaBits > 0 {
+		channels = 4
+	}
+
+	for c := 0; c < channels; c++ {
+		width := mode.colorBits
+		if c == 3 {
+			width = mode.alphaBits
+		}
+
+		for s := 0; s < mode.subsets; s++ {
+			endpoints[s][0][c] = r.read(width)
+			endpoints[s][1][c] = r.read(width)
+		}
+	}
+
+	// Assertion 2: P-bits add one low bit to each endpoint or subset
+	colorBits, alphaBits := mode.colorBits, mode.alphaBits
+	if mode.endpointPBits || mode.sharedPBits {
+		for s := 0; s < mode.subsets; s++ {
+			p0 := r.read(1)
+			p1 := p0
+			if mode.endpointPBits {
+				p1 = r.read(1)
+			}
+
+			for c := 0; c < channels; c++ {
+				endpoints[s][0][c] = endpoints[s][0][c]<<1 | p0
+				endpoints[s][1][c] = endpoints[s][1][c]<<1 | p1
+			}
+		}
+		colorBits++
+		if alphaBits > 0 {
+			alphaBits++
+		}
+	}
+
+	for s := 0; s < mode.subsets; s++ {
+		for e := 0; e < 2; e++ {
+			for c := 0; c < 4; c++ {
+				switch {
+				case c < 3:
+					endpoints[s][e][c] = expandBits(endpoints[s][e][c], colorBits)
+				case alphaBits > 0:
+					endpoints[s][e][c] = expandBits(endpoints[s][e][c], alphaBits)
+				default:
+					endpoints[s][e][c] = 255
+				}
+			}
+		}
+	}
+
+	// Assertion 3: Anchor texels store their index with the top bit dropped
+	var subset [16]int
+	anchors := [3]int{0, -1, -1}
+	for i := 0; i < 16; i++ {
+		switch mode.subsets {
+		case 2:
+			subset[i] = int(bc7Partitions2[partition] >> i & 1)
+		case 3:
+			subset[i] = int(bc7Partitions3[partition][i] - '0')
+		}
+	}
+
+	switch mode.subsets {
+	case 2:
+		anchors[1] = int(bc7Anchors2[partition])
+	case 3:
+		anchors[1], anchors[2] = int(bc7Anchors3[0][partition]), int(bc7Anchors3[1][partition])
+	}
+
+	var primary, secondary [16]int
+	for i := 0; i < 16; i++ {
+		width := mode.indexBits
+		if i == anchors[subset[i]] {
+			width--
+		}
+		primary[i] = r.read(width)
+	}
+
+	if mode.secondaryIndex > 0 {
+		for i := 0; i < 16; i++ {
+			width := mode.secondaryIndex
+			if i == 0 {
+				width--
+			}
+			secondary[i] = r.read(width)
+		}
+	}
+
+	for i := 0; i < 16; i++ {
+		e := endpoints[subset[i]]
+		colorWeight := bc7Weights[mode.indexBits][primary[i]]
+		alphaWeight := colorWeight
+
+		// Modes 4 and 5 carry a second index set, the selector swaps their roles
+		if mode.secondaryIndex > 0 {
+			alphaWeight = bc7Weights[mode.secondaryIndex][secondary[i]]
+			if selector == 1 {
+				colorWeight, alphaWeight = alphaWeight, bc7Weights[mode.indexBits][primary[i]]
+			}
+		}
+
+		var texel [4]uint8
+		for c := 0; c < 4; c++ {
+			w := colorWeight
+			if c == 3 {
+				w = alphaWeight
+			}
+			texel[c] = uint8(((64-w)*e[0][c] + w*e[1][c] + 32) >> 6)
+		}
+
+		// Rotation swaps alpha with one color channel after decoding
+		if rotation > 0 {
+			texel[rotation-1], texel[3] = texel[3], texel[rotation-1]
+		}
+
+		out[i] = texel
+	}
+}
+
+// expandBits widens an n bit value to 8 bits by replicating its top bits
+func expandBits(v, n int) int {
+	v <<= 8 - n
+	return v | v>>n
+}
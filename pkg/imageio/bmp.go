@@ -0,0 +1,425 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"golang.org/x/image/bmp"
+)
+
+const (
+	// bmpFileHeaderSize is the BITMAPFILEHEADER before the info header
+	bmpFileHeaderSize = 14
+	// bmpInfoHeaderSize is the BITMAPINFOHEADER size
+	bmpInfoHeaderSize = 40
+	// bmpV4HeaderSize is the BITMAPV4HEADER size, which adds channel masks
+	bmpV4HeaderSize = 108
+
+	bmpRGB       = 0 // BI_RGB
+	bmpRLE8      = 1 // BI_RLE8
+	bmpRLE4      = 2 // BI_RLE4
+	bmpBitfields = 3 // BI_BITFIELDS
+
+	// bmpMaxRun is the longest run or literal one RLE code holds
+	bmpMaxRun = 255
+)
+
+// validate checks the depth and that RLE is not combined with one
+func (o BMPOptions) validate() error {
+	if o.BitDepth != 0 && o.BitDepth != 24 && o.BitDepth != 32 {
+		return fmt.Errorf("%w: BMP bit depth %d, want 24 or 32", ErrUnsupportedFormat, o.BitDepth)
+	}
+
+	if o.RLE && o.BitDepth != 0 {
+		return fmt.Errorf("%w: BMP RLE writes 8-bit paletted data, not %d bits", ErrUnsupportedFormat, o.BitDepth)
+	}
+
+	return nil
+}
+
+// encodeBMP writes img as a bottom-up BMP
+func encodeBMP(w io.Writer, img image.Image, opts BMPOptions) error {
+	// Assertion 1: Validate the options
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case opts.RLE:
+		return writeBMPRLE(w, img)
+	case opts.BitDepth != 0:
+		return writeBMPTrueColor(w, img, opts.BitDepth)
+	default:
+		return bmp.Encode(w, img)
+	}
+}
+
+// writeBMPHeaders writes the file and info headers; 32-bit images get a
+// V4 header whose masks carry the alpha channel
+func writeBMPHeaders(w io.Writer, bounds image.Rectangle, bpp, compression, colors, imageSize int) error {
+	infoSize := bmpInfoHeaderSize
+	if bpp == 32 {
+		infoSize = bmpV4HeaderSize
+	}
+
+	offset := bmpFileHeaderSize + infoSize + 4*colors
+	header := make([]byte, bmpFileHeaderSize+infoSize)
+	le := binary.LittleEndian
+
+	header[0], header[1] = 'B', 'M'
+	le.PutUint32(header[2:], uint32(offset+imageSize))
+	le.PutUint32(header[10:], uint32(offset))
+	le.PutUint32(header[14:], uint32(infoSize))
+	le.PutUint32(header[18:], uint32(bounds.Dx()))
+	le.PutUint32(header[22:], uint32(bounds.Dy()))
+	le.PutUint16(header[26:], 1)
+	le.PutUint16(header[28:], uint16(bpp))
+	le.PutUint32(header[30:], uint32(compression))
+	le.PutUint32(header[34:], uint32(imageSize))
+	le.PutUint32(header[46:], uint32(colors))
+
+	if infoSize == bmpV4HeaderSize {
+		le.PutUint32(header[54:], 0x00FF0000)
+		le.PutUint32(header[58:], 0x0000FF00)
+		le.PutUint32(header[62:], 0x000000FF)
+		le.PutUint32(header[66:], 0xFF000000)
+		copy(header[70:], "BGRs") // LCS_sRGB
+	}
+
+	_, err := w.Write(header)
+	return err
+}
+
+// writeBMPTrueColor writes BGR or, at 32 bits, BGRA rows with straight
+// alpha; 24 bits drops alpha, leaving transparent areas black
+func writeBMPTrueColor(w io.Writer, img image.Image, bpp int) error {
+	bounds := img.Bounds()
+	bytesPerPixel := bpp / 8
+	stride := (bytesPerPixel*bounds.Dx() + 3) &^ 3
+
+	bw := bufio.NewWriter(w)
+	if err := writeBMPHeaders(bw, bounds, bpp, bmpBitfieldsFor(bpp), 0, stride*bounds.Dy()); err != nil {
+		return err
+	}
+
+	row := make([]byte, stride)
+	for y := bounds.Max.Y - 1; y >= bounds.Min.Y; y-- {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := bytesPerPixel * (x - bounds.Min.X)
+
+			if bpp == 32 {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				row[i], row[i+1], row[i+2], row[i+3] = c.B, c.G, c.R, c.A
+				continue
+			}
+
+			r, g, b, _ := img.At(x, y).RGBA()
+			row[i], row[i+1], row[i+2] = uint8(b>>8), uint8(g>>8), uint8(r>>8)
+		}
+
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// bmpBitfieldsFor returns the compression field of a true color depth
+func bmpBitfieldsFor(bpp int) int {
+	if bpp == 32 {
+		return bmpBitfields
+	}
+
+	return bmpRGB
+}
+
+// writeBMPRLE writes a gray or paletted image as RLE8
+func writeBMPRLE(w io.Writer, img image.Image) error {
+	var palette color.Palette
+	var pix []uint8
+	var stride int
+
+	// Assertion 1: RLE8 holds palette indices only
+	switch m := img.(type) {
+	case *image.Paletted:
+		palette, pix, stride = m.Palette, m.Pix, m.Stride
+	case *image.Gray:
+		palette = make(color.Palette, 256)
+		for i := range palette {
+			palette[i] = color.Gray{Y: uint8(i)}
+		}
+		pix, stride = m.Pix, m.Stride
+	default:
+		return fmt.Errorf("%w: BMP RLE needs a gray or paletted image, got %T", ErrUnsupportedFormat, img)
+	}
+
+	if len(palette) > 256 {
+		return fmt.Errorf("%w: BMP palettes hold at most 256 colors", ErrUnsupportedFormat)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var data bytes.Buffer
+	for y := height - 1; y >= 0; y-- {
+		rleRow(&data, pix[y*stride:y*stride+width])
+
+		// End of line, or end of bitmap after the top row
+		if y > 0 {
+			data.Write([]byte{0, 0})
+		} else {
+			data.Write([]byte{0, 1})
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := writeBMPHeaders(bw, bounds, 8, bmpRLE8, len(palette), data.Len()); err != nil {
+		return err
+	}
+
+	entry := make([]byte, 4)
+	for _, c := range palette {
+		r, g, b, _ := c.RGBA()
+		entry[0], entry[1], entry[2] = uint8(b>>8), uint8(g>>8), uint8(r>>8)
+		if _, err := bw.Write(entry); err != nil {
+			return err
+		}
+	}
+
+	if _, err := data.WriteTo(bw); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// rleRow appends the RLE8 codes of one row: runs of two or more equal
+// indices become a count and index, other stretches of three or more
+// become absolute mode
+func rleRow(out *bytes.Buffer, row []uint8) {
+	x := 0
+	for x < len(row) {
+		run := runLength(row, x)
+		if run >= 2 {
+			out.Write([]byte{byte(run), row[x]})
+			x += run
+			continue
+		}
+
+		// Gather literals up to the next run of two or more
+		end := x + 1
+		for end < len(row) && end-x < bmpMaxRun && runLength(row, end) < 2 {
+			end++
+		}
+
+		// Absolute mode needs at least three pixels
+		if end-x < 3 {
+			for ; x < end; x++ {
+				out.Write([]byte{1, row[x]})
+			}
+			continue
+		}
+
+		out.Write([]byte{0, byte(end - x)})
+		out.Write(row[x:end])
+		if (end-x)%2 == 1 {
+			out.WriteByte(0)
+		}
+		x = end
+	}
+}
+
+// runLength counts the equal indices starting at x, at most bmpMaxRun
+func runLength(row []uint8, x int) int {
+	n := 1
+	for x+n < len(row) && n < bmpMaxRun && row[x+n] == row[x] {
+		n++
+	}
+
+	return n
+}
+
+// decodeBMP decodes a BMP stream, handling the RLE8 and RLE4 compression
+// that golang.org/x/image/bmp rejects
+// A mapped input is decoded without copying the file first
+func decodeBMP(r io.Reader) (image.Image, error) {
+	data, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := readBMPRLEHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if header == nil {
+		return bmp.Decode(bytes.NewReader(data))
+	}
+
+	return header.decode(data)
+}
+
+// decodeBMPConfig returns the configuration of a BMP stream, RLE included
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	head := make([]byte, bmpFileHeaderSize+bmpInfoHeaderSize)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return image.Config{}, err
+	}
+	head = head[:n]
+
+	header, err := readBMPRLEHeader(head)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	if header == nil {
+		return bmp.DecodeConfig(io.MultiReader(bytes.NewReader(head), r))
+	}
+
+	return image.Config{ColorModel: color.RGBAModel, Width: header.width, Height: header.height}, nil
+}
+
+// bmpRLEHeader is the part of a BMP header an RLE decode needs
+type bmpRLEHeader struct {
+	width, height int
+	bpp           int
+	offset        int
+	paletteAt     int
+	colors        int
+}
+
+// readBMPRLEHeader parses the header of an RLE8 or RLE4 BMP, or returns
+// nil for other BMPs
+func readBMPRLEHeader(data []byte) (*bmpRLEHeader, error) {
+	le := binary.LittleEndian
+
+	// Assertion 1: Only well formed RLE headers are handled here
+	if len(data) < bmpFileHeaderSize+bmpInfoHeaderSize || data[0] != 'B' || data[1] != 'M' {
+		return nil, nil
+	}
+
+	compression := le.Uint32(data[30:34])
+	if compression != bmpRLE8 && compression != bmpRLE4 {
+		return nil, nil
+	}
+
+	h := &bmpRLEHeader{
+		width:     int(int32(le.Uint32(data[18:22]))),
+		height:    int(int32(le.Uint32(data[22:26]))),
+		bpp:       int(le.Uint16(data[28:30])),
+		offset:    int(le.Uint32(data[10:14])),
+		paletteAt: bmpFileHeaderSize + int(le.Uint32(data[14:18])),
+		colors:    int(le.Uint32(data[46:50])),
+	}
+
+	// Assertion 2: RLE images are bottom-up with a matching depth
+	if h.width <= 0 || h.height <= 0 {
+		return nil, fmt.Errorf("bmp: RLE image has invalid size %dx%d", h.width, h.height)
+	}
+
+	if (compression == bmpRLE8 && h.bpp != 8) || (compression == bmpRLE4 && h.bpp != 4) {
+		return nil, fmt.Errorf("bmp: RLE compression with %d bits per pixel", h.bpp)
+	}
+
+	if h.colors == 0 || h.colors > 1<<h.bpp {
+		h.colors = 1 << h.bpp
+	}
+
+	return h, nil
+}
+
+// decode expands the RLE codes into a paletted image; pixels skipped by
+// a delta or an early end keep index zero
+func (h *bmpRLEHeader) decode(data []byte) (image.Image, error) {
+	// Assertion 1: The palette and codes fit in the file
+	if h.paletteAt+4*h.colors > len(data) || h.offset > len(data) || h.offset < h.paletteAt {
+		return nil, fmt.Errorf("bmp: RLE header points past the file")
+	}
+
+	palette := make(color.Palette, h.colors)
+	for i := range palette {
+		p := data[h.paletteAt+4*i:]
+		palette[i] = color.RGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, h.width, h.height), palette)
+	codes := data[h.offset:]
+	x, y := 0, h.height-1
+
+	// put stores one index when it lands inside the image
+	put := func(index uint8) {
+		if x < h.width && y >= 0 && int(index) < len(palette) {
+			img.Pix[y*img.Stride+x] = index
+		}
+		x++
+	}
+
+	// Every code consumes at least two bytes, which bounds the loop
+	for pos := 0; pos+1 < len(codes) && y >= 0; {
+		count, value := int(codes[pos]), codes[pos+1]
+		pos += 2
+
+		if count > 0 {
+			for i := 0; i < count; i++ {
+				if h.bpp == 8 {
+					put(value)
+				} else if i%2 == 0 {
+					put(value >> 4)
+				} else {
+					put(value & 0x0F)
+				}
+			}
+			continue
+		}
+
+		switch value {
+		case 0:
+			// End of line
+			x, y = 0, y-1
+		case 1:
+			// End of bitmap
+			return img, nil
+		case 2:
+			// Delta moves right and up
+			if pos+1 >= len(codes) {
+				return img, nil
+			}
+			x, y = x+int(codes[pos]), y-int(codes[pos+1])
+			pos += 2
+		default:
+			// Absolute mode, padded to a whole word
+			n := int(value)
+			size := n
+			if h.bpp == 4 {
+				size = (n + 1) / 2
+			}
+
+			if pos+size > len(codes) {
+				return nil, fmt.Errorf("bmp: RLE literal runs past the file")
+			}
+
+			for i := 0; i < n; i++ {
+				if h.bpp == 8 {
+					put(codes[pos+i])
+				} else if i%2 == 0 {
+					put(codes[pos+i/2] >> 4)
+				} else {
+					put(codes[pos+i/2] & 0x0F)
+				}
+			}
+
+			pos += size + size%2
+		}
+	}
+
+	return img, nil
+}
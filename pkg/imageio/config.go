@@ -0,0 +1,191 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// ImageConfig is the format and size of an image file
+type ImageConfig struct {
+	Format     string // Detected extension such as ".png"
+	Width      int
+	Height     int
+	ColorModel color.Model // Model LoadImage decodes to, nil when the header does not tell
+}
+
+// Pixels returns the pixel count of the image
+func (c ImageConfig) Pixels() int {
+	return c.Width * c.Height
+}
+
+// DecodedBytes estimates the memory of the decoded image, from its color
+// model when known and the widest layout the format decodes to otherwise
+func (c ImageConfig) DecodedBytes() int64 {
+	pixel := int64(4)
+	switch c.ColorModel {
+	case nil:
+		if c.Format == ".exr" || c.Format == ".hdr" || c.Format == ".psd" || c.Format == ".psb" {
+			pixel = 16
+		}
+	case color.GrayModel, color.AlphaModel:
+		pixel = 1
+	case color.Gray16Model:
+		pixel = 2
+	case color.YCbCrModel:
+		// Without chroma subsampling, the largest case
+		pixel = 3
+	case color.RGBA64Model, color.NRGBA64Model:
+		pixel = 8
+	case hdr.ColorModel:
+		pixel = 16
+	default:
+		if _, ok := c.ColorModel.(color.Palette); ok {
+			pixel = 1
+		}
+	}
+
+	return pixel * int64(c.Width) * int64(c.Height)
+}
+
+// LoadImageConfig returns the format and dimensions of an image file from
+// its header, so callers can reject or plan for an image before decoding
+// it; the size is that LoadImage would return before any orientation
+// Camera raw files decode their embedded preview to learn its size
+func LoadImageConfig(path string) (ImageConfig, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return ImageConfig{}, err
+	}
+	defer file.Close()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return ImageConfig{}, err
+	}
+
+	return decodeConfig(file, ext)
+}
+
+// decodeConfig reads the size of an image in the format named by ext
+func decodeConfig(r io.Reader, ext string) (ImageConfig, error) {
+	var width, height int
+	var model color.Model
+	var err error
+
+	switch ext {
+	case ".jpg", ".jpeg":
+		width, height, model, err = stdConfig(jpeg.DecodeConfig(r))
+	case ".png":
+		width, height, model, err = stdConfig(png.DecodeConfig(r))
+	case ".bmp":
+		width, height, model, err = stdConfig(decodeBMPConfig(r))
+	case ".tiff", ".tif":
+		width, height, model, err = stdConfig(tiff.DecodeConfig(r))
+	case ".webp":
+		// Animated files report their canvas
+		width, height, model, err = stdConfig(webp.DecodeConfig(r))
+	case ".gif":
+		width, height, model, err = stdConfig(gif.DecodeConfig(r))
+	case ".ppm", ".pgm", ".pbm", ".pnm":
+		var header pnmHeader
+		header, err = readPNMHeader(&pnmReader{r: bufio.NewReader(r)})
+		width, height, model = header.width, header.height, header.colorModel()
+	case ".hdr":
+		width, height, err = readRadianceHeader(bufio.NewReader(r))
+		model = hdr.ColorModel
+	case ".exr", ".psd", ".psb", ".ico", ".dds":
+		width, height, err = headerConfig(r, ext)
+	case ".dng", ".cr2", ".nef":
+		var img image.Image
+		if img, err = decodeRaw(r); err == nil {
+			width, height, model = img.Bounds().Dx(), img.Bounds().Dy(), img.ColorModel()
+		}
+	default:
+		return ImageConfig{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
+	}
+
+	// Assertion 1: Check the header result, some readers wrap ErrDecode already
+	if err != nil && !errors.Is(err, ErrDecode) {
+		return ImageConfig{}, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+	if err != nil {
+		return ImageConfig{}, err
+	}
+
+	return ImageConfig{Format: ext, Width: width, Height: height, ColorModel: model}, nil
+}
+
+// stdConfig unpacks the result of a registered DecodeConfig function
+func stdConfig(config image.Config, err error) (int, int, color.Model, error) {
+	return config.Width, config.Height, config.ColorModel, err
+}
+
+// headerConfig reads the size from formats whose headers are parsed from
+// memory: the data window of OpenEXR, the Photoshop header, the largest
+// icon entry and the DDS header
+func headerConfig(r io.Reader, ext string) (int, int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch ext {
+	case ".exr":
+		// Assertion 1: Validate magic number before the attributes
+		if len(data) < 8 || binary.LittleEndian.Uint32(data[0:4]) != exrMagic {
+			return 0, 0, fmt.Errorf("not an OpenEXR file")
+		}
+
+		header, _, err := readEXRHeader(data, 8)
+		if err != nil {
+			return 0, 0, err
+		}
+		return header.dataWindow.Dx(), header.dataWindow.Dy(), nil
+	case ".psd", ".psb":
+		header, err := readPSDHeader(data)
+		if err != nil {
+			return 0, 0, err
+		}
+		return header.width, header.height, nil
+	case ".ico":
+		entries, err := readICOEntries(data)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// The decoder picks the largest entry
+		best := entries[0]
+		for i := 1; i < len(entries); i++ {
+			if entries[i].width*entries[i].height > best.width*best.height {
+				best = entries[i]
+			}
+		}
+
+		// PNG entries record their true size in the stream
+		if bytes.HasPrefix(best.data, pngSignature) {
+			width, height, _, err := stdConfig(png.DecodeConfig(bytes.NewReader(best.data)))
+			return width, height, err
+		}
+		return best.width, best.height, nil
+	default:
+		// Assertion 2: Validate the DDS magic number and header size
+		if len(data) < 4+ddsHeaderSize || string(data[0:4]) != "DDS " {
+			return 0, 0, fmt.Errorf("not a DDS file")
+		}
+		return int(binary.LittleEndian.Uint32(data[16:20])), int(binary.LittleEndian.Uint32(data[12:16])), nil
+	}
+}
@@ -0,0 +1,107 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+)
+
+// Running counts the decoders and encoders started under a context,
+// those a cancel left behind included, so a caller can hold what bounds
+// them until they have all ended
+type Running struct {
+	wg sync.WaitGroup
+}
+
+// runningKey is the context key of a Running
+type runningKey struct{}
+
+// WithRunning returns ctx whose decoders and encoders running counts
+func WithRunning(ctx context.Context, running *Running) context.Context {
+	return context.WithValue(ctx, runningKey{}, running)
+}
+
+// Wait blocks until every decoder and encoder counted has returned
+func (r *Running) Wait() {
+	r.wg.Wait()
+}
+
+// LoadImageContext loads an image like LoadImageWithOptions, returning
+// once ctx is done even while the decoder is still running
+func LoadImageContext(ctx context.Context, path string, opts DecodeOptions) (image.Image, error) {
+	return untilDone(ctx, func() (image.Image, error) {
+		return LoadImageWithOptions(path, opts)
+	})
+}
+
+// LoadAnimationContext loads every frame like LoadAnimationWithOptions,
+// returning once ctx is done even while the decoder is still running
+func LoadAnimationContext(ctx context.Context, path string, opts DecodeOptions) (*Animation, error) {
+	return untilDone(ctx, func() (*Animation, error) {
+		return LoadAnimationWithOptions(path, opts)
+	})
+}
+
+// DecodeImageContext reads an image like DecodeImage, returning once ctx
+// is done even while the decoder is still running
+func DecodeImageContext(ctx context.Context, r io.Reader, opts DecodeOptions) (image.Image, error) {
+	return untilDone(ctx, func() (image.Image, error) {
+		return DecodeImage(r, opts)
+	})
+}
+
+// DecodeAnimationContext reads every frame like DecodeAnimation,
+// returning once ctx is done even while the decoder is still running
+func DecodeAnimationContext(ctx context.Context, r io.Reader, opts DecodeOptions) (*Animation, error) {
+	return untilDone(ctx, func() (*Animation, error) {
+		return DecodeAnimation(r, opts)
+	})
+}
+
+// untilDone runs fn, returning ctx.Err() wrapped in ErrCanceled as soon as
+// ctx is done; the decoders and encoders cannot be stopped part way, so
+// fn runs on and its result is dropped, which is why it must not write
+// anything itself, and a Running of ctx counts it until it ends
+func untilDone[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+
+	// Assertion 1: A context that never ends needs no goroutine
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return zero, fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	// Buffered so an abandoned fn can still hand over its result and end
+	results := make(chan result, 1)
+	running, _ := ctx.Value(runningKey{}).(*Running)
+	if running != nil {
+		running.wg.Add(1)
+	}
+
+	go func() {
+		if running != nil {
+			defer running.wg.Done()
+		}
+
+		value, err := fn()
+		results <- result{value, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-ctx.Done():
+		return zero, fmt.Errorf("%w: %w", ErrCanceled, ctx.Err())
+	}
+}
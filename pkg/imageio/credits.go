@@ -0,0 +1,166 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"slices"
+)
+
+const (
+	// tagArtist and tagCopyright name the creator and rights holder in the
+	// first EXIF directory
+	tagArtist    = 0x013B
+	tagCopyright = 0x8298
+)
+
+// emptyEXIF is a little-endian TIFF structure with one empty directory
+var emptyEXIF = []byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+
+// xmpPacket wraps RDF descriptions into a standalone XMP packet
+const xmpPacket = "<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+	"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"><rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">" +
+	"</rdf:RDF></x:xmpmeta>\n<?xpacket end=\"w\"?>"
+
+// asciiField is an ASCII value to record in an EXIF directory
+type asciiField struct {
+	tag   uint16
+	value string
+}
+
+// WithCredits returns a copy of m naming artist as the creator and
+// copyright as the rights notice, in the EXIF Artist and Copyright tags
+// and the XMP dc:creator and dc:rights properties; blocks are created
+// when m has none and empty values leave the fields alone
+func (m Metadata) WithCredits(artist, copyright string) Metadata {
+	var fields []asciiField
+	if artist != "" {
+		fields = append(fields, asciiField{tagArtist, artist})
+	}
+	if copyright != "" {
+		fields = append(fields, asciiField{tagCopyright, copyright})
+	}
+
+	if len(fields) == 0 {
+		return m
+	}
+
+	m.EXIF = exifWithASCII(m.EXIF, fields)
+	m.XMP = xmpWithCredits(m.XMP, artist, copyright)
+	return m
+}
+
+// exifWithASCII returns a copy of exif whose first directory holds fields,
+// replacing entries with the same tags
+// The directory is rewritten at the end of the block so existing values
+// keep their offsets
+func exifWithASCII(exif []byte, fields []asciiField) []byte {
+	file, first, err := openTIFF(exif)
+	if err == nil {
+		_, _, err = file.readIFD(first)
+	}
+
+	// Assertion 1: Start over when the block cannot be read
+	if err != nil {
+		exif = emptyEXIF
+		file, first, _ = openTIFF(exif)
+	}
+
+	order := file.order
+	count := int(order.Uint16(exif[first:]))
+	start := int(first) + 2
+	next := order.Uint32(exif[start+12*count:])
+
+	// Keep the 12 byte records of the entries that are not replaced
+	var records [][]byte
+	for i := 0; i < count; i++ {
+		record := exif[start+12*i : start+12*i+12]
+		tag := order.Uint16(record)
+		replaced := slices.ContainsFunc(fields, func(f asciiField) bool { return f.tag == tag })
+		if !replaced {
+			records = append(records, record)
+		}
+	}
+
+	out := bytes.Clone(exif)
+	if len(out)%2 == 1 {
+		out = append(out, 0)
+	}
+
+	ifdAt := len(out)
+	valuesAt := ifdAt + 2 + 12*(len(records)+len(fields)) + 4
+	var values []byte
+
+	for _, field := range fields {
+		value := append([]byte(field.value), 0)
+
+		record := make([]byte, 12)
+		order.PutUint16(record[0:], field.tag)
+		order.PutUint16(record[2:], tiffASCII)
+		order.PutUint32(record[4:], uint32(len(value)))
+
+		if len(value) <= 4 {
+			copy(record[8:], value)
+		} else {
+			order.PutUint32(record[8:], uint32(valuesAt+len(values)))
+			values = append(values, value...)
+			if len(values)%2 == 1 {
+				values = append(values, 0)
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	// Entries must stay sorted by tag
+	slices.SortFunc(records, func(a, b []byte) int {
+		return int(order.Uint16(a)) - int(order.Uint16(b))
+	})
+
+	ifd := make([]byte, valuesAt-ifdAt)
+	order.PutUint16(ifd, uint16(len(records)))
+	for i, record := range records {
+		copy(ifd[2+12*i:], record)
+	}
+	order.PutUint32(ifd[len(ifd)-4:], next)
+
+	out = append(out, ifd...)
+	out = append(out, values...)
+
+	order.PutUint32(out[4:8], uint32(ifdAt))
+	return out
+}
+
+// xmpWithCredits returns a copy of xmp, or a new packet when it is nil,
+// whose dc:creator and dc:rights hold the non-empty values
+func xmpWithCredits(xmp []byte, artist, copyright string) []byte {
+	if len(xmp) == 0 || !bytes.Contains(xmp, []byte("</rdf:RDF>")) {
+		xmp = []byte(xmpPacket)
+	}
+
+	var description bytes.Buffer
+	description.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">`)
+
+	if artist != "" {
+		xmp = xmpRemove(xmp, `dc:creator`)
+		description.WriteString("<dc:creator><rdf:Seq><rdf:li>")
+		xml.EscapeText(&description, []byte(artist))
+		description.WriteString("</rdf:li></rdf:Seq></dc:creator>")
+	}
+
+	if copyright != "" {
+		xmp = xmpRemove(xmp, `dc:rights`)
+		description.WriteString(`<dc:rights><rdf:Alt><rdf:li xml:lang="x-default">`)
+		xml.EscapeText(&description, []byte(copyright))
+		description.WriteString("</rdf:li></rdf:Alt></dc:rights>")
+	}
+
+	description.WriteString("</rdf:Description>")
+
+	// A further description of the same resource joins the existing ones
+	at := bytes.LastIndex(xmp, []byte("</rdf:RDF>"))
+	out := make([]byte, 0, len(xmp)+description.Len())
+	out = append(out, xmp[:at]...)
+	out = append(out, description.Bytes()...)
+	return append(out, xmp[at:]...)
+}
@@ -0,0 +1,42 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"encoding/base64"
+)
+
+// mimeTypes maps output extensions to their media types
+var mimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".bmp":  "image/bmp",
+	".tiff": "image/tiff",
+	".tif":  "image/tiff",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+	".ico":  "image/x-icon",
+	".ppm":  "image/x-portable-pixmap",
+	".pgm":  "image/x-portable-graymap",
+	".pbm":  "image/x-portable-bitmap",
+	".pnm":  "image/x-portable-anymap",
+	".exr":  "image/x-exr",
+	".hdr":  "image/vnd.radiance",
+	".dds":  "image/vnd-ms.dds",
+}
+
+// MIMEType returns the media type of an output extension, falling back to
+// application/octet-stream for unknown ones
+func MIMEType(ext string) string {
+	if mime, ok := mimeTypes[ext]; ok {
+		return mime
+	}
+
+	return "application/octet-stream"
+}
+
+// DataURI returns data as a base64 data URI with the media type of ext,
+// ready for an HTML src attribute or a CSS url()
+func DataURI(data []byte, ext string) string {
+	return "data:" + MIMEType(ext) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
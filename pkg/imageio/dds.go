@@ -0,0 +1,378 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+const (
+	// ddsHeaderSize is the size of the header after the magic number
+	ddsHeaderSize = 124
+	// ddsDX10Size is the size of the optional DX10 extension header
+	ddsDX10Size = 20
+)
+
+// DDS pixel format flags
+const (
+	ddpfAlphaPixels = 0x1
+	ddpfAlpha       = 0x2
+	ddpfFourCC      = 0x4
+	ddpfRGB         = 0x40
+	ddpfLuminance   = 0x20000
+)
+
+// ddsCompression names the block compression of a surface
+type ddsCompression int
+
+const (
+	ddsUncompressed ddsCompression = iota
+	ddsBC1
+	ddsBC2
+	ddsBC3
+	ddsBC4
+	ddsBC5
+	ddsBC7
+)
+
+// ddsFormat describes how the top surface of a DDS file is stored
+type ddsFormat struct {
+	compression   ddsCompression
+	signed        bool
+	premultiplied bool
+	// Uncompressed pixels are unpacked with a bit mask per channel
+	bitCount uint32
+	masks    [4]uint32
+}
+
+// decodeDDS decodes the top mip level of a DirectDraw Surface texture,
+// the first face of a cube map or the first slice of an array or volume
+// BC1 to BC5 and BC7 blocks as well as uncompressed masks are supported
+func decodeDDS(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	img, err := readDDS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// readDDS parses the header and decodes the first surface
+func readDDS(data []byte) (image.Image, error) {
+	// Assertion 1: Validate the magic number and header size
+	if len(data) < 4+ddsHeaderSize || string(data[0:4]) != "DDS " {
+		return nil, fmt.Errorf("not a DDS file")
+	}
+
+	le := binary.LittleEndian
+	if le.Uint32(data[4:8]) != ddsHeaderSize {
+		return nil, fmt.Errorf("invalid DDS header size")
+	}
+
+	height, width := int(le.Uint32(data[12:16])), int(le.Uint32(data[16:20]))
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: Resolve the pixel format, possibly from the DX10 header
+	format, dx10, err := ddsPixelFormat(data[76:108])
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 4 + ddsHeaderSize
+	if dx10 {
+		if len(data) < offset+ddsDX10Size {
+			return nil, fmt.Errorf("truncated DX10 header")
+		}
+
+		format, err = ddsDXGIFormat(le.Uint32(data[offset:]), le.Uint32(data[offset+16:]))
+		if err != nil {
+			return nil, err
+		}
+		offset += ddsDX10Size
+	}
+
+	if format.compression == ddsUncompressed {
+		return readDDSMasked(data[offset:], width, height, format)
+	}
+
+	return readDDSBlocks(data[offset:], width, height, format)
+}
+
+// ddsPixelFormat interprets the legacy pixel format block, reporting
+// whether a DX10 header follows
+func ddsPixelFormat(pf []byte) (ddsFormat, bool, error) {
+	le := binary.LittleEndian
+	flags := le.Uint32(pf[4:8])
+
+	if flags&ddpfFourCC != 0 {
+		switch fourCC := string(pf[8:12]); fourCC {
+		case "DX10":
+			return ddsFormat{}, true, nil
+		case "DXT1":
+			return ddsFormat{compression: ddsBC1}, false, nil
+		case "DXT2":
+			return ddsFormat{compression: ddsBC2, premultiplied: true}, false, nil
+		case "DXT3":
+			return ddsFormat{compression: ddsBC2}, false, nil
+		case "DXT4":
+			return ddsFormat{compression: ddsBC3, premultiplied: true}, false, nil
+		case "DXT5":
+			return ddsFormat{compression: ddsBC3}, false, nil
+		case "ATI1", "BC4U":
+			return ddsFormat{compression: ddsBC4}, false, nil
+		case "BC4S":
+			return ddsFormat{compression: ddsBC4, signed: true}, false, nil
+		case "ATI2", "BC5U":
+			return ddsFormat{compression: ddsBC5}, false, nil
+		case "BC5S":
+			return ddsFormat{compression: ddsBC5, signed: true}, false, nil
+		default:
+			return ddsFormat{}, false, fmt.Errorf("unsupported DDS format %q", fourCC)
+		}
+	}
+
+	format := ddsFormat{bitCount: le.Uint32(pf[12:16])}
+	if flags&(ddpfRGB|ddpfLuminance|ddpfAlpha) == 0 {
+		return ddsFormat{}, false, fmt.Errorf("unsupported DDS pixel format flags 0x%X", flags)
+	}
+
+	if flags&(ddpfRGB|ddpfLuminance) != 0 {
+		format.masks[0] = le.Uint32(pf[16:20])
+		format.masks[1] = le.Uint32(pf[20:24])
+		format.masks[2] = le.Uint32(pf[24:28])
+	}
+
+	// Luminance keeps its mask in the red field
+	if flags&ddpfLuminance != 0 {
+		format.masks[1], format.masks[2] = format.masks[0], format.masks[0]
+	}
+
+	if flags&(ddpfAlphaPixels|ddpfAlpha) != 0 {
+		format.masks[3] = le.Uint32(pf[28:32])
+	}
+
+	switch format.bitCount {
+	case 8, 16, 24, 32:
+		return format, false, nil
+	default:
+		return ddsFormat{}, false, fmt.Errorf("unsupported DDS bit count %d", format.bitCount)
+	}
+}
+
+// ddsDXGIFormat maps the DXGI format of a DX10 header, alpha mode 2
+// marks premultiplied alpha
+func ddsDXGIFormat(dxgi, miscFlags2 uint32) (ddsFormat, error) {
+	premultiplied := miscFlags2&7 == 2
+
+	switch dxgi {
+	case 28, 29:
+		return ddsFormat{bitCount: 32, masks: [4]uint32{0xFF, 0xFF00, 0xFF0000, 0xFF000000}, premultiplied: premultiplied}, nil
+	case 87, 91:
+		return ddsFormat{bitCount: 32, masks: [4]uint32{0xFF0000, 0xFF00, 0xFF, 0xFF000000}, premultiplied: premultiplied}, nil
+	case 88, 93:
+		return ddsFormat{bitCount: 32, masks: [4]uint32{0xFF0000, 0xFF00, 0xFF, 0}}, nil
+	case 61:
+		return ddsFormat{bitCount: 8, masks: [4]uint32{0xFF, 0xFF, 0xFF, 0}}, nil
+	case 70, 71, 72:
+		return ddsFormat{compression: ddsBC1}, nil
+	case 73, 74, 75:
+		return ddsFormat{compression: ddsBC2, premultiplied: premultiplied}, nil
+	case 76, 77, 78:
+		return ddsFormat{compression: ddsBC3, premultiplied: premultiplied}, nil
+	case 79, 80:
+		return ddsFormat{compression: ddsBC4}, nil
+	case 81:
+		return ddsFormat{compression: ddsBC4, signed: true}, nil
+	case 82, 83:
+		return ddsFormat{compression: ddsBC5}, nil
+	case 84:
+		return ddsFormat{compression: ddsBC5, signed: true}, nil
+	case 94, 95, 96:
+		return ddsFormat{}, fmt.Errorf("BC6H textures are not supported")
+	case 97, 98, 99:
+		return ddsFormat{compression: ddsBC7, premultiplied: premultiplied}, nil
+	default:
+		return ddsFormat{}, fmt.Errorf("unsupported DXGI format %d", dxgi)
+	}
+}
+
+// readDDSBlocks decodes a block compressed surface of 4x4 texel blocks
+func readDDSBlocks(data []byte, width, height int, format ddsFormat) (image.Image, error) {
+	blockSize := 16
+	if format.compression == ddsBC1 || format.compression == ddsBC4 {
+		blockSize = 8
+	}
+
+	// Assertion 1: Every block of the top surface must be present
+	blocksX, blocksY := (width+3)/4, (height+3)/4
+	if len(data) < blocksX*blocksY*blockSize {
+		return nil, fmt.Errorf("truncated DDS surface")
+	}
+
+	// Texels are written straight into the pixel buffer of the result
+	rect := image.Rect(0, 0, width, height)
+	var pix []uint8
+	var stride int
+	var img image.Image
+
+	switch {
+	case format.compression == ddsBC4:
+		gray := image.NewGray(rect)
+		pix, stride, img = gray.Pix, gray.Stride, gray
+	case format.premultiplied:
+		rgba := image.NewRGBA(rect)
+		pix, stride, img = rgba.Pix, rgba.Stride, rgba
+	default:
+		nrgba := image.NewNRGBA(rect)
+		pix, stride, img = nrgba.Pix, nrgba.Stride, nrgba
+	}
+
+	var block bcBlock
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			b := data[(by*blocksX+bx)*blockSize:][:blockSize]
+
+			switch format.compression {
+			case ddsBC1:
+				decodeBC1(b, &block, true)
+			case ddsBC2:
+				decodeBC2(b, &block)
+			case ddsBC3:
+				decodeBC3(b, &block)
+			case ddsBC4:
+				decodeBC4(b, &block, format.signed)
+			case ddsBC5:
+				decodeBC5(b, &block, format.signed)
+			case ddsBC7:
+				decodeBC7(b, &block)
+			}
+
+			// Assertion 2: Blocks overhanging the right or bottom edge are clipped
+			for ty := 0; ty < 4 && 4*by+ty < height; ty++ {
+				for tx := 0; tx < 4 && 4*bx+tx < width; tx++ {
+					texel := block[4*ty+tx]
+					if format.compression == ddsBC4 {
+						pix[(4*by+ty)*stride+4*bx+tx] = texel[0]
+						continue
+					}
+
+					copy(pix[(4*by+ty)*stride+4*(4*bx+tx):], texel[:])
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// readDDSMasked decodes an uncompressed surface whose channels are
+// picked out of each pixel by bit masks
+func readDDSMasked(data []byte, width, height int, format ddsFormat) (image.Image, error) {
+	bytesPerPixel := int(format.bitCount / 8)
+	pitch := width * bytesPerPixel
+
+	// Assertion 1: Every row of the top surface must be present
+	if len(data) < pitch*height {
+		return nil, fmt.Errorf("truncated DDS surface")
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	var pix []uint8
+	var stride int
+	var img image.Image
+
+	if format.premultiplied {
+		rgba := image.NewRGBA(rect)
+		pix, stride, img = rgba.Pix, rgba.Stride, rgba
+	} else {
+		nrgba := image.NewNRGBA(rect)
+		pix, stride, img = nrgba.Pix, nrgba.Stride, nrgba
+	}
+
+	for y := 0; y < height; y++ {
+		row := data[y*pitch:]
+		for x := 0; x < width; x++ {
+			var v uint32
+			for i := bytesPerPixel - 1; i >= 0; i-- {
+				v = v<<8 | uint32(row[x*bytesPerPixel+i])
+			}
+
+			p := pix[y*stride+4*x:][:4]
+			for c := 0; c < 4; c++ {
+				p[c] = maskedChannel(v, format.masks[c])
+			}
+
+			// A missing alpha mask means opaque
+			if format.masks[3] == 0 {
+				p[3] = 255
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// maskedChannel extracts the channel under mask from v, scaled to 8 bits
+func maskedChannel(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+
+	shift := bits.TrailingZeros32(mask)
+	limit := uint64(mask >> shift)
+	return uint8((uint64(v&mask>>shift)*255 + limit/2) / limit)
+}
+
+// encodeDDS writes img as an uncompressed 32-bit BGRA DDS texture with
+// straight alpha and a single mip level
+func encodeDDS(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	out := bufio.NewWriter(w)
+	le := binary.LittleEndian
+
+	header := make([]byte, 4+ddsHeaderSize)
+	copy(header[0:4], "DDS ")
+	le.PutUint32(header[4:], ddsHeaderSize)
+	// Caps, height, width, pitch and pixel format are valid
+	le.PutUint32(header[8:], 0x100F)
+	le.PutUint32(header[12:], uint32(height))
+	le.PutUint32(header[16:], uint32(width))
+	le.PutUint32(header[20:], uint32(4*width))
+	le.PutUint32(header[76:], 32)
+	le.PutUint32(header[80:], ddpfRGB|ddpfAlphaPixels)
+	le.PutUint32(header[88:], 32)
+	le.PutUint32(header[92:], 0xFF0000)
+	le.PutUint32(header[96:], 0xFF00)
+	le.PutUint32(header[100:], 0xFF)
+	le.PutUint32(header[104:], 0xFF000000)
+	// The surface is a plain texture
+	le.PutUint32(header[108:], 0x1000)
+	out.Write(header)
+
+	row := make([]byte, 4*width)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x, y)).(color.NRGBA)
+			row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = c.B, c.G, c.R, c.A
+		}
+
+		out.Write(row)
+	}
+
+	return out.Flush()
+}
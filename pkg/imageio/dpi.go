@@ -0,0 +1,174 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+const (
+	// MaxDPI is the largest density every supported format can record
+	MaxDPI = 65535
+	// metersPerInch converts dots per inch into dots per meter
+	metersPerInch = 0.0254
+	// tiffResolutionScale keeps two decimals of DPI in TIFF rationals
+	tiffResolutionScale = 100
+)
+
+var ErrInvalidDPI = errors.New("invalid DPI")
+
+// ValidateDPI checks that a density can be written to every format
+func ValidateDPI(dpi float64) error {
+	if math.IsNaN(dpi) || dpi <= 0.0 || dpi > MaxDPI {
+		return fmt.Errorf("%w: %g must be in (0, %d]", ErrInvalidDPI, dpi, MaxDPI)
+	}
+
+	return nil
+}
+
+// withDPI records dpi in encoded image data of the given extension
+func withDPI(ext string, data []byte, dpi float64) ([]byte, error) {
+	// Assertion 1: Validate density
+	if err := ValidateDPI(dpi); err != nil {
+		return nil, err
+	}
+
+	switch ext {
+	case ".jpg", ".jpeg":
+		return jpegWithDPI(data, dpi)
+	case ".png":
+		return pngWithDPI(data, dpi)
+	case ".bmp":
+		return bmpWithDPI(data, dpi)
+	case ".tiff", ".tif":
+		return tiffWithDPI(data, dpi)
+	default:
+		return nil, fmt.Errorf("%w: cannot record DPI in %s", ErrUnsupportedFormat, ext)
+	}
+}
+
+// jpegWithDPI adds or updates the JFIF APP0 segment after SOI
+func jpegWithDPI(data []byte, dpi float64) ([]byte, error) {
+	// Assertion 1: Validate the start of image marker
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("%w: missing JPEG SOI marker", ErrEncode)
+	}
+
+	density := uint16(math.Round(dpi))
+
+	// Assertion 2: Update an existing JFIF segment in place
+	if len(data) >= 18 && data[2] == 0xFF && data[3] == 0xE0 && string(data[6:11]) == "JFIF\x00" {
+		data[13] = 1 // Units: dots per inch
+		binary.BigEndian.PutUint16(data[14:16], density)
+		binary.BigEndian.PutUint16(data[16:18], density)
+		return data, nil
+	}
+
+	app0 := []byte{0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x02, 0x01, 0, 0, 0, 0, 0x00, 0x00}
+	binary.BigEndian.PutUint16(app0[12:14], density)
+	binary.BigEndian.PutUint16(app0[14:16], density)
+
+	out := make([]byte, 0, len(data)+len(app0))
+	out = append(out, data[:2]...)
+	out = append(out, app0...)
+	return append(out, data[2:]...), nil
+}
+
+// pngWithDPI inserts a pHYs chunk right after IHDR
+func pngWithDPI(data []byte, dpi float64) ([]byte, error) {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+
+	// Assertion 1: Validate the signature and IHDR chunk
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("%w: missing PNG IHDR chunk", ErrEncode)
+	}
+
+	ppm := uint32(math.Round(dpi / metersPerInch))
+
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk[0:4], 9)
+	copy(chunk[4:8], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:12], ppm)
+	binary.BigEndian.PutUint32(chunk[12:16], ppm)
+	chunk[16] = 1 // Unit: meter
+	binary.BigEndian.PutUint32(chunk[17:21], crc32.ChecksumIEEE(chunk[4:17]))
+
+	var out bytes.Buffer
+	out.Grow(len(data) + len(chunk))
+	out.Write(data[:ihdrEnd])
+	out.Write(chunk)
+	out.Write(data[ihdrEnd:])
+	return out.Bytes(), nil
+}
+
+// bmpWithDPI sets the pixels-per-meter fields of the BITMAPINFOHEADER
+func bmpWithDPI(data []byte, dpi float64) ([]byte, error) {
+	// Assertion 1: Validate the file and info headers
+	if len(data) < 46 || data[0] != 'B' || data[1] != 'M' {
+		return nil, fmt.Errorf("%w: missing BMP header", ErrEncode)
+	}
+
+	ppm := uint32(math.Round(dpi / metersPerInch))
+	binary.LittleEndian.PutUint32(data[38:42], ppm)
+	binary.LittleEndian.PutUint32(data[42:46], ppm)
+	return data, nil
+}
+
+// tiffWithDPI rewrites the XResolution and YResolution rationals of the
+// first IFD, which the encoder always writes with inch units
+func tiffWithDPI(data []byte, dpi float64) ([]byte, error) {
+	const (
+		tagXResolution = 282
+		tagYResolution = 283
+		typeRational   = 5
+		entrySize      = 12
+	)
+
+	// Assertion 1: Validate the little-endian header written by the encoder
+	if len(data) < 8 || string(data[0:4]) != "II*\x00" {
+		return nil, fmt.Errorf("%w: missing TIFF header", ErrEncode)
+	}
+
+	le := binary.LittleEndian
+	ifd := int(le.Uint32(data[4:8]))
+
+	if ifd+2 > len(data) {
+		return nil, fmt.Errorf("%w: TIFF IFD out of range", ErrEncode)
+	}
+
+	count := int(le.Uint16(data[ifd : ifd+2]))
+	patched := 0
+
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*entrySize
+		if entry+entrySize > len(data) {
+			return nil, fmt.Errorf("%w: TIFF IFD truncated", ErrEncode)
+		}
+
+		tag := le.Uint16(data[entry : entry+2])
+		if (tag != tagXResolution && tag != tagYResolution) || le.Uint16(data[entry+2:entry+4]) != typeRational {
+			continue
+		}
+
+		// Assertion 2: Rationals live in the pointer area
+		value := int(le.Uint32(data[entry+8 : entry+12]))
+		if value+8 > len(data) {
+			return nil, fmt.Errorf("%w: TIFF resolution out of range", ErrEncode)
+		}
+
+		le.PutUint32(data[value:value+4], uint32(math.Round(dpi*tiffResolutionScale)))
+		le.PutUint32(data[value+4:value+8], tiffResolutionScale)
+		patched++
+	}
+
+	// Assertion 3: Both axes must have been recorded
+	if patched != 2 {
+		return nil, fmt.Errorf("%w: TIFF resolution tags not found", ErrEncode)
+	}
+
+	return data, nil
+}
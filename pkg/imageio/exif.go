@@ -0,0 +1,283 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// tagExifIFD points from the first directory to the EXIF directory
+	tagExifIFD = 0x8769
+	// tagGPSIFD points from the first directory to the GPS directory
+	tagGPSIFD = 0x8825
+	// tagPixelXDimension and tagPixelYDimension record the image size in
+	// the EXIF directory
+	tagPixelXDimension = 0xA002
+	tagPixelYDimension = 0xA003
+	// tagMake, tagModel and tagDateTime identify the camera and when the
+	// file was written, tagDateTimeOriginal when the photo was taken
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagDateTime         = 0x0132
+	tagDateTimeOriginal = 0x9003
+
+	// jpegMaxSegment is the largest JPEG segment payload after its length
+	jpegMaxSegment = 0xFFFF - 2
+	// webpEXIFFlag marks a VP8X file as carrying an EXIF chunk
+	webpEXIFFlag = 1 << 3
+)
+
+// exifHeader prefixes EXIF data in JPEG APP1 segments and some WebP files
+var exifHeader = []byte("Exif\x00\x00")
+
+// ReadEXIF returns the EXIF block of a JPEG, PNG or WebP file as the TIFF
+// structure that follows the "Exif" header, or nil when the file has none
+// EXIF of TIFF based files lives in the image's own directories and is
+// not returned
+func ReadEXIF(path string) ([]byte, error) {
+	meta, err := ReadMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta.EXIF, nil
+}
+
+// EXIFSummary holds the descriptive fields of an EXIF block
+type EXIFSummary struct {
+	Make      string
+	Model     string
+	DateTime  string // Capture time, or the file time without one
+	Artist    string
+	Copyright string
+	GPS       bool // The block records a location
+}
+
+// SummarizeEXIF reads the camera, time, credits and GPS presence of an
+// EXIF block from ReadMetadata; unreadable fields are left empty
+func SummarizeEXIF(exif []byte) EXIFSummary {
+	var summary EXIFSummary
+
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return summary
+	}
+
+	entries, _, err := file.readIFD(first)
+	if err != nil {
+		return summary
+	}
+
+	summary.Make = exifString(entries[tagMake])
+	summary.Model = exifString(entries[tagModel])
+	summary.DateTime = exifString(entries[tagDateTime])
+	summary.Artist = exifString(entries[tagArtist])
+	summary.Copyright = exifString(entries[tagCopyright])
+	_, summary.GPS = file.uint(entries[tagGPSIFD], 0)
+
+	if offset, ok := file.uint(entries[tagExifIFD], 0); ok {
+		if sub, _, err := file.readIFD(offset); err == nil {
+			if original := exifString(sub[tagDateTimeOriginal]); original != "" {
+				summary.DateTime = original
+			}
+		}
+	}
+
+	return summary
+}
+
+// exifString returns an ASCII entry without its NUL padding and
+// surrounding blanks
+func exifString(e tiffEntry) string {
+	if e.kind != tiffASCII {
+		return ""
+	}
+
+	return strings.TrimSpace(string(bytes.TrimRight(e.value, "\x00")))
+}
+
+// ReadOrientation returns the EXIF orientation of a JPEG, PNG, WebP or
+// TIFF file, 1 when it has none
+// Camera raw previews are turned upright when decoded and report 1
+func ReadOrientation(path string) (int, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	return exifOrientation(data, ext), nil
+}
+
+// ResetOrientation returns a copy of an EXIF block from ReadEXIF whose
+// orientation says the pixels are already upright
+func ResetOrientation(exif []byte) []byte {
+	exif = bytes.Clone(exif)
+
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return exif
+	}
+
+	entries, _, err := file.readIFD(first)
+	if err != nil {
+		return exif
+	}
+
+	file.putUint(entries[tagOrientation], 1)
+	return exif
+}
+
+// StripGPS returns a copy of an EXIF block from ReadEXIF without its GPS
+// directory; the location values are zeroed, not just unlinked
+func StripGPS(exif []byte) []byte {
+	exif = bytes.Clone(exif)
+
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return exif
+	}
+
+	entries, _, err := file.readIFD(first)
+	if err != nil {
+		return exif
+	}
+
+	offset, ok := file.uint(entries[tagGPSIFD], 0)
+	if !ok {
+		return exif
+	}
+
+	// Assertion 1: Blank the GPS values and entries, which readIFD bounded
+	if gps, _, err := file.readIFD(offset); err == nil {
+		for _, entry := range gps {
+			clear(entry.value)
+		}
+
+		count := int(file.order.Uint16(exif[offset:]))
+		clear(exif[int(offset) : int(offset)+2+12*count])
+	}
+
+	file.removeEntry(first, tagGPSIFD)
+	return exif
+}
+
+// removeEntry deletes the entry with tag from the directory at offset,
+// moving the later entries and the next directory offset up
+func (f *tiffFile) removeEntry(offset uint32, tag uint16) {
+	count := int(f.order.Uint16(f.data[offset:]))
+	start := int(offset) + 2
+	end := start + 12*count + 4
+
+	for i := 0; i < count; i++ {
+		at := start + 12*i
+		if f.order.Uint16(f.data[at:]) != tag {
+			continue
+		}
+
+		copy(f.data[at:], f.data[at+12:end])
+		clear(f.data[end-12 : end])
+		f.order.PutUint16(f.data[offset:], uint16(count-1))
+		return
+	}
+}
+
+// embeddedEXIF returns the EXIF block of JPEG, PNG or WebP file data
+// without its "Exif" header
+func embeddedEXIF(data []byte, ext string) []byte {
+	var exif []byte
+
+	switch ext {
+	case ".jpg", ".jpeg":
+		exif = jpegSegment(data, 0xE1, exifHeader)
+	case ".png":
+		exif = pngChunk(data, "eXIf", nil)
+	case ".webp":
+		exif = webpChunk(data, "EXIF")
+	}
+
+	return bytes.TrimPrefix(exif, exifHeader)
+}
+
+// exifOrientation returns the EXIF orientation recorded in the JPEG, PNG,
+// WebP or TIFF file data, or 1 when there is none or it cannot be read
+func exifOrientation(data []byte, ext string) int {
+	exif := embeddedEXIF(data, ext)
+	if ext == ".tiff" || ext == ".tif" {
+		// TIFF files carry the tag in their own first directory
+		exif = data
+	}
+
+	// Assertion 1: The EXIF block is a TIFF structure
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return 1
+	}
+
+	entries, _, err := file.readIFD(first)
+	if err != nil {
+		return 1
+	}
+
+	// Assertion 2: Only the eight defined orientations count
+	orientation, ok := file.uint(entries[tagOrientation], 0)
+	if !ok || orientation < 1 || orientation > 8 {
+		return 1
+	}
+
+	return int(orientation)
+}
+
+// exifForSize returns a copy of exif whose PixelXDimension and
+// PixelYDimension tags, when present, hold the given size
+func exifForSize(exif []byte, width, height int) []byte {
+	exif = bytes.Clone(exif)
+
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return exif
+	}
+
+	entries, _, err := file.readIFD(first)
+	if err != nil {
+		return exif
+	}
+
+	offset, ok := file.uint(entries[tagExifIFD], 0)
+	if !ok {
+		return exif
+	}
+
+	sub, _, err := file.readIFD(offset)
+	if err != nil {
+		return exif
+	}
+
+	// Entry values alias the copy, so they are patched in place
+	file.putUint(sub[tagPixelXDimension], uint32(width))
+	file.putUint(sub[tagPixelYDimension], uint32(height))
+	return exif
+}
+
+// putUint overwrites the first value of a SHORT or LONG entry
+func (f *tiffFile) putUint(e tiffEntry, v uint32) {
+	switch {
+	case e.kind == 3 && len(e.value) >= 2 && v <= 0xFFFF:
+		f.order.PutUint16(e.value, uint16(v))
+	case e.kind == 4 && len(e.value) >= 4:
+		f.order.PutUint32(e.value, v)
+	}
+}
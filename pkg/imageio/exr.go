@@ -0,0 +1,620 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+const (
+	// exrMagic starts every OpenEXR file
+	exrMagic = 20000630
+	// exrVersion is the file format version this package reads and writes
+	exrVersion = 2
+	// exrTiledFlag, exrDeepFlag and exrMultipartFlag mark layouts other
+	// than a single scanline part
+	exrTiledFlag     = 0x200
+	exrDeepFlag      = 0x800
+	exrMultipartFlag = 0x1000
+	// exrMaxAttributes bounds the header attributes
+	exrMaxAttributes = 1024
+	// exrMaxName bounds attribute, type and channel names
+	exrMaxName = 255
+	// exrMaxChannels bounds the channel list
+	exrMaxChannels = 1024
+	// exrZIPLines is the number of scanlines per ZIP block
+	exrZIPLines = 16
+)
+
+// OpenEXR pixel types
+const (
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+)
+
+// OpenEXR compression methods read by this package
+const (
+	exrNoCompression   = 0
+	exrRLECompression  = 1
+	exrZIPSCompression = 2
+	exrZIPCompression  = 3
+)
+
+// exrChannel is one entry of the channel list
+type exrChannel struct {
+	name      string
+	pixelType int32
+}
+
+// size returns the bytes per sample of the channel
+func (c exrChannel) size() int {
+	if c.pixelType == exrHalf {
+		return 2
+	}
+
+	return 4
+}
+
+// exrHeader holds the header attributes needed to read the pixels
+type exrHeader struct {
+	channels    []exrChannel
+	compression byte
+	dataWindow  image.Rectangle
+}
+
+// linesPerBlock returns the scanlines stored in one chunk
+func (h *exrHeader) linesPerBlock() int {
+	if h.compression == exrZIPCompression {
+		return exrZIPLines
+	}
+
+	return 1
+}
+
+// decodeEXR decodes a single-part scanline OpenEXR file with HALF, FLOAT
+// or UINT channels, stored uncompressed or with RLE, ZIPS or ZIP
+// compression, reading the R, G, B and A channels, or Y for luminance
+func decodeEXR(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	img, err := readEXR(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// readEXR parses the header, offset table and chunks of data
+func readEXR(data []byte) (image.Image, error) {
+	// Assertion 1: Validate magic number and layout
+	if len(data) < 8 || binary.LittleEndian.Uint32(data[0:4]) != exrMagic {
+		return nil, fmt.Errorf("not an OpenEXR file")
+	}
+
+	version := binary.LittleEndian.Uint32(data[4:8])
+	if version&0xFF != exrVersion || version&(exrTiledFlag|exrDeepFlag|exrMultipartFlag) != 0 {
+		return nil, fmt.Errorf("only single-part scanline OpenEXR files are supported")
+	}
+
+	header, pos, err := readEXRHeader(data, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := header.dataWindow.Dx(), header.dataWindow.Dy()
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: The offset table must fit the file
+	lines := header.linesPerBlock()
+	chunks := (height + lines - 1) / lines
+	if len(data) < pos+8*chunks {
+		return nil, fmt.Errorf("truncated offset table")
+	}
+
+	img := hdr.NewRGBA(image.Rect(0, 0, width, height))
+	components := exrComponents(header.channels)
+
+	// Channels absent from the file stay opaque or zero
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 1
+	}
+
+	for i := 0; i < chunks; i++ {
+		offset := binary.LittleEndian.Uint64(data[pos+8*i:])
+		if err := readEXRChunk(data, offset, header, components, img); err != nil {
+			return nil, fmt.Errorf("chunk %d: %v", i, err)
+		}
+	}
+
+	return img, nil
+}
+
+// readEXRHeader reads the attributes starting at pos up to the
+// terminating null byte, returning the position after it
+func readEXRHeader(data []byte, pos int) (*exrHeader, int, error) {
+	header := &exrHeader{}
+	seen := map[string]bool{}
+
+	for i := 0; ; i++ {
+		// Assertion 1: Bound the attribute count
+		if i > exrMaxAttributes {
+			return nil, 0, fmt.Errorf("too many header attributes")
+		}
+
+		if pos >= len(data) {
+			return nil, 0, fmt.Errorf("truncated header")
+		}
+
+		if data[pos] == 0 {
+			pos++
+			break
+		}
+
+		name, next, err := readEXRName(data, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		kind, next, err := readEXRName(data, next)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(data) < next+4 {
+			return nil, 0, fmt.Errorf("truncated attribute %s", name)
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[next:]))
+		next += 4
+
+		// Assertion 2: The value lies inside the file
+		if size < 0 || size > len(data)-next {
+			return nil, 0, fmt.Errorf("attribute %s overruns the file", name)
+		}
+
+		value := data[next : next+size]
+		pos = next + size
+		seen[name] = true
+
+		switch {
+		case name == "channels" && kind == "chlist":
+			if header.channels, err = readEXRChannels(value); err != nil {
+				return nil, 0, err
+			}
+		case name == "compression" && kind == "compression" && size == 1:
+			header.compression = value[0]
+		case name == "dataWindow" && kind == "box2i" && size == 16:
+			box := [4]int64{}
+			for k := 0; k < 4; k++ {
+				box[k] = int64(int32(binary.LittleEndian.Uint32(value[4*k:])))
+			}
+
+			if box[2] < box[0] || box[3] < box[1] || box[2]-box[0] >= validator.MaxImageDimension || box[3]-box[1] >= validator.MaxImageDimension {
+				return nil, 0, fmt.Errorf("invalid data window")
+			}
+
+			header.dataWindow = image.Rect(int(box[0]), int(box[1]), int(box[2])+1, int(box[3])+1)
+		}
+	}
+
+	// Assertion 3: The attributes needed to read pixels are present
+	if !seen["channels"] || !seen["compression"] || !seen["dataWindow"] {
+		return nil, 0, fmt.Errorf("header lacks channels, compression or dataWindow")
+	}
+
+	switch header.compression {
+	case exrNoCompression, exrRLECompression, exrZIPSCompression, exrZIPCompression:
+	default:
+		return nil, 0, fmt.Errorf("unsupported OpenEXR compression %d", header.compression)
+	}
+
+	return header, pos, nil
+}
+
+// readEXRName reads a null terminated name at pos
+func readEXRName(data []byte, pos int) (string, int, error) {
+	end := bytes.IndexByte(data[pos:min(len(data), pos+exrMaxName+1)], 0)
+	if end < 0 {
+		return "", 0, fmt.Errorf("invalid header name")
+	}
+
+	return string(data[pos : pos+end]), pos + end + 1, nil
+}
+
+// readEXRChannels parses a chlist attribute
+func readEXRChannels(value []byte) ([]exrChannel, error) {
+	var channels []exrChannel
+	pos := 0
+
+	for len(channels) <= exrMaxChannels {
+		if pos >= len(value) {
+			return nil, fmt.Errorf("truncated channel list")
+		}
+
+		if value[pos] == 0 {
+			return channels, nil
+		}
+
+		name, next, err := readEXRName(value, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		// Name, pixel type, linear flag, padding and two sampling rates
+		if len(value) < next+16 {
+			return nil, fmt.Errorf("truncated channel %s", name)
+		}
+
+		pixelType := int32(binary.LittleEndian.Uint32(value[next:]))
+		xSampling := int32(binary.LittleEndian.Uint32(value[next+8:]))
+		ySampling := int32(binary.LittleEndian.Uint32(value[next+12:]))
+
+		// Assertion 1: Known types and full resolution channels only
+		if pixelType < exrUint || pixelType > exrFloat {
+			return nil, fmt.Errorf("channel %s has unknown pixel type %d", name, pixelType)
+		}
+
+		if xSampling != 1 || ySampling != 1 {
+			return nil, fmt.Errorf("channel %s is subsampled", name)
+		}
+
+		channels = append(channels, exrChannel{name: name, pixelType: pixelType})
+		pos = next + 16
+	}
+
+	return nil, fmt.Errorf("more than %d channels", exrMaxChannels)
+}
+
+// exrComponents maps each channel to the RGBA component it fills, or -1
+// Luminance fills red, green and blue, marked as 4
+func exrComponents(channels []exrChannel) []int {
+	components := make([]int, len(channels))
+
+	for i := 0; i < len(channels); i++ {
+		switch channels[i].name {
+		case "R":
+			components[i] = 0
+		case "G":
+			components[i] = 1
+		case "B":
+			components[i] = 2
+		case "A":
+			components[i] = 3
+		case "Y":
+			components[i] = 4
+		default:
+			components[i] = -1
+		}
+	}
+
+	return components
+}
+
+// readEXRChunk decompresses the chunk at offset into its rows of img
+func readEXRChunk(data []byte, offset uint64, header *exrHeader, components []int, img *hdr.RGBA) error {
+	// Assertion 1: The chunk header lies inside the file
+	if offset > uint64(len(data)) || uint64(len(data))-offset < 8 {
+		return fmt.Errorf("offset out of range")
+	}
+
+	chunk := data[offset:]
+	y := int(int32(binary.LittleEndian.Uint32(chunk[0:4])))
+	size := int(binary.LittleEndian.Uint32(chunk[4:8]))
+	if size < 0 || size > len(chunk)-8 {
+		return fmt.Errorf("data overruns the file")
+	}
+
+	window := header.dataWindow
+	row := y - window.Min.Y
+	if row < 0 || row >= window.Dy() {
+		return fmt.Errorf("scanline %d outside the data window", y)
+	}
+
+	lines := min(header.linesPerBlock(), window.Dy()-row)
+	width := window.Dx()
+
+	lineSize := 0
+	for i := 0; i < len(header.channels); i++ {
+		lineSize += width * header.channels[i].size()
+	}
+
+	raw, err := exrDecompress(chunk[8:8+size], header.compression, lines*lineSize)
+	if err != nil {
+		return err
+	}
+
+	pos := 0
+	for line := 0; line < lines; line++ {
+		pix := img.Pix[(row+line)*img.Stride:]
+
+		for i := 0; i < len(header.channels); i++ {
+			channel := header.channels[i]
+			component := components[i]
+
+			for x := 0; x < width; x++ {
+				v := exrSample(raw[pos:], channel.pixelType)
+				pos += channel.size()
+
+				switch {
+				case component == 4:
+					pix[4*x], pix[4*x+1], pix[4*x+2] = v, v, v
+				case component >= 0:
+					pix[4*x+component] = v
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// exrSample reads one little-endian sample as a float
+func exrSample(b []byte, pixelType int32) float32 {
+	switch pixelType {
+	case exrHalf:
+		return hdr.HalfToFloat(binary.LittleEndian.Uint16(b))
+	case exrFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	default:
+		return float32(binary.LittleEndian.Uint32(b))
+	}
+}
+
+// exrDecompress expands one chunk into exactly size bytes
+// Chunks that would not shrink are stored as is
+func exrDecompress(data []byte, compression byte, size int) ([]byte, error) {
+	// Assertion 1: Uncompressed data must match the block size
+	if len(data) == size || compression == exrNoCompression {
+		if len(data) != size {
+			return nil, fmt.Errorf("block holds %d bytes, expected %d", len(data), size)
+		}
+
+		return data, nil
+	}
+
+	raw := make([]byte, size)
+
+	if compression == exrRLECompression {
+		if err := exrRLEDecode(data, raw); err != nil {
+			return nil, err
+		}
+	} else {
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := io.ReadFull(zr, raw); err != nil {
+			return nil, fmt.Errorf("ZIP block: %v", err)
+		}
+	}
+
+	return exrUnpredict(raw), nil
+}
+
+// exrRLEDecode expands run-length data into dst, which it must fill
+func exrRLEDecode(data, dst []byte) error {
+	out := 0
+
+	for len(data) > 0 {
+		count := int(int8(data[0]))
+		data = data[1:]
+
+		// Assertion 1: Negative counts copy literals, others repeat a byte
+		if count < 0 {
+			n := -count
+			if n > len(data) || out+n > len(dst) {
+				return fmt.Errorf("invalid RLE literal")
+			}
+
+			copy(dst[out:], data[:n])
+			data = data[n:]
+			out += n
+			continue
+		}
+
+		n := count + 1
+		if len(data) == 0 || out+n > len(dst) {
+			return fmt.Errorf("invalid RLE run")
+		}
+
+		for i := 0; i < n; i++ {
+			dst[out+i] = data[0]
+		}
+
+		data = data[1:]
+		out += n
+	}
+
+	if out != len(dst) {
+		return fmt.Errorf("RLE block holds %d bytes, expected %d", out, len(dst))
+	}
+
+	return nil
+}
+
+// exrUnpredict undoes the delta predictor and byte split that RLE and
+// ZIP compression apply before compressing
+func exrUnpredict(t []byte) []byte {
+	for i := 1; i < len(t); i++ {
+		t[i] = byte(int(t[i-1]) + int(t[i]) - 128)
+	}
+
+	half := (len(t) + 1) / 2
+	out := make([]byte, len(t))
+	for i := 0; i < len(t); i++ {
+		if i%2 == 0 {
+			out[i] = t[i/2]
+		} else {
+			out[i] = t[half+i/2]
+		}
+	}
+
+	return out
+}
+
+// exrPredict splits raw into its even and odd bytes and delta encodes
+// the result, the inverse of exrUnpredict
+func exrPredict(raw []byte) []byte {
+	half := (len(raw) + 1) / 2
+	t := make([]byte, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if i%2 == 0 {
+			t[i/2] = raw[i]
+		} else {
+			t[half+i/2] = raw[i]
+		}
+	}
+
+	previous := 0
+	for i := 0; i < len(t); i++ {
+		current := int(t[i])
+		if i > 0 {
+			t[i] = byte(current - previous + 128)
+		}
+		previous = current
+	}
+
+	return t
+}
+
+// encodeEXR writes img as a ZIP compressed scanline OpenEXR file with
+// HALF channels, leaving out alpha when the image is opaque
+func encodeEXR(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	names := []string{"B", "G", "R"}
+	if o, ok := img.(interface{ Opaque() bool }); !ok || !o.Opaque() {
+		names = append(names, "A")
+	}
+
+	// Channels are stored in alphabetical order
+	sort.Strings(names)
+
+	var head []byte
+	head = binary.LittleEndian.AppendUint32(head, exrMagic)
+	head = binary.LittleEndian.AppendUint32(head, exrVersion)
+
+	var chlist []byte
+	for i := 0; i < len(names); i++ {
+		chlist = append(chlist, names[i]...)
+		chlist = append(chlist, 0)
+		chlist = binary.LittleEndian.AppendUint32(chlist, exrHalf)
+		chlist = append(chlist, 0, 0, 0, 0)
+		chlist = binary.LittleEndian.AppendUint32(chlist, 1)
+		chlist = binary.LittleEndian.AppendUint32(chlist, 1)
+	}
+	chlist = append(chlist, 0)
+
+	var box []byte
+	for _, v := range []int{0, 0, width - 1, height - 1} {
+		box = binary.LittleEndian.AppendUint32(box, uint32(v))
+	}
+
+	head = appendEXRAttribute(head, "channels", "chlist", chlist)
+	head = appendEXRAttribute(head, "compression", "compression", []byte{exrZIPCompression})
+	head = appendEXRAttribute(head, "dataWindow", "box2i", box)
+	head = appendEXRAttribute(head, "displayWindow", "box2i", box)
+	head = appendEXRAttribute(head, "lineOrder", "lineOrder", []byte{0})
+	head = appendEXRAttribute(head, "pixelAspectRatio", "float", binary.LittleEndian.AppendUint32(nil, math.Float32bits(1)))
+	head = appendEXRAttribute(head, "screenWindowCenter", "v2f", make([]byte, 8))
+	head = appendEXRAttribute(head, "screenWindowWidth", "float", binary.LittleEndian.AppendUint32(nil, math.Float32bits(1)))
+	head = append(head, 0)
+
+	chunks := (height + exrZIPLines - 1) / exrZIPLines
+	var body []byte
+	offsets := make([]byte, 0, 8*chunks)
+	start := len(head) + 8*chunks
+
+	for i := 0; i < chunks; i++ {
+		block, err := exrBlock(img, names, i*exrZIPLines, min(exrZIPLines, height-i*exrZIPLines))
+		if err != nil {
+			return err
+		}
+
+		offsets = binary.LittleEndian.AppendUint64(offsets, uint64(start+len(body)))
+		body = binary.LittleEndian.AppendUint32(body, uint32(i*exrZIPLines))
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(block)))
+		body = append(body, block...)
+	}
+
+	for _, part := range [][]byte{head, offsets, body} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendEXRAttribute appends one header attribute
+func appendEXRAttribute(dst []byte, name, kind string, value []byte) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, 0)
+	dst = append(dst, kind...)
+	dst = append(dst, 0)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(value)))
+	return append(dst, value...)
+}
+
+// exrBlock packs and compresses lines rows of img starting at row y,
+// keeping the raw bytes when compression does not shrink them
+func exrBlock(img image.Image, names []string, y, lines int) ([]byte, error) {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	raw := make([]byte, 0, lines*len(names)*width*2)
+
+	for line := 0; line < lines; line++ {
+		for i := 0; i < len(names); i++ {
+			for x := 0; x < width; x++ {
+				c := hdr.FloatAt(img, bounds.Min.X+x, bounds.Min.Y+y+line)
+
+				v := c.A
+				switch names[i] {
+				case "R":
+					v = c.R
+				case "G":
+					v = c.G
+				case "B":
+					v = c.B
+				}
+
+				raw = binary.LittleEndian.AppendUint16(raw, hdr.FloatToHalf(v))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(exrPredict(raw)); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	// Assertion 1: Store blocks raw when compression does not pay off
+	if buf.Len() >= len(raw) {
+		return raw, nil
+	}
+
+	return buf.Bytes(), nil
+}
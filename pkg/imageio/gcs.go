@@ -0,0 +1,414 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// gcsScheme starts the paths naming Google Cloud Storage objects,
+// gs://bucket/object
+const gcsScheme = "gs://"
+
+const (
+	// gcsEndpoint is the JSON API of Cloud Storage
+	gcsEndpoint = "https://storage.googleapis.com"
+	// gcsScope is the OAuth scope reading and writing objects
+	gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+	// gcsTokenURI is the token endpoint of user credentials without one
+	gcsTokenURI = "https://oauth2.googleapis.com/token"
+	// gcsMaxPages bounds the pages of one listing, 1000 objects each
+	gcsMaxPages = 10000
+)
+
+// gcsClient sends requests to the Cloud Storage JSON API, or to the
+// emulator of STORAGE_EMULATOR_HOST
+type gcsClient struct {
+	http     *http.Client
+	endpoint string
+	tokens   *tokenCache // Nil for the emulator, which takes no credentials
+}
+
+// newGCSClient returns the Storage of gs:// paths, finding credentials as
+// the Google libraries do: GOOGLE_APPLICATION_CREDENTIALS, the gcloud
+// application default credentials, then the metadata server
+func newGCSClient() (Storage, error) {
+	c := &gcsClient{http: &http.Client{}, endpoint: gcsEndpoint}
+
+	// Assertion 1: The emulator needs no credentials
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		c.endpoint = strings.TrimSuffix(host, "/")
+		return c, nil
+	}
+
+	source, err := googleCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	c.tokens = &tokenCache{source: source}
+	return c, nil
+}
+
+// googleKey is a credentials file of a service account or of a user
+// signed in with gcloud
+type googleKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleCredentials returns the token source of the application default
+// credentials
+func googleCredentials() (func(ctx context.Context) (bearerToken, error), error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		path = gcloudCredentialsFile()
+		if _, err := os.Stat(path); err != nil {
+			return metadataToken, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: google credentials: %v", ErrRemote, err)
+	}
+
+	var key googleKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("%w: google credentials %s: %v", ErrRemote, path, err)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	switch key.Type {
+	case "service_account":
+		signer, err := parseRSAKey(key.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: google credentials %s: %v", ErrRemote, path, err)
+		}
+
+		return func(ctx context.Context) (bearerToken, error) {
+			return serviceAccountToken(ctx, client, key, signer)
+		}, nil
+	case "authorized_user":
+		return func(ctx context.Context) (bearerToken, error) {
+			form := url.Values{"grant_type": {"refresh_token"}, "client_id": {key.ClientID}, "client_secret": {key.ClientSecret}, "refresh_token": {key.RefreshToken}}
+			return postToken(ctx, client, firstNonEmpty(key.TokenURI, gcsTokenURI), form)
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: google credentials %s: type %q is not a service account or user", ErrRemote, path, key.Type)
+	}
+}
+
+// gcloudCredentialsFile returns where gcloud auth application-default
+// login keeps the user credentials
+func gcloudCredentialsFile() string {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" && runtime.GOOS == "windows" {
+		dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+	}
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config", "gcloud")
+	}
+
+	return filepath.Join(dir, "application_default_credentials.json")
+}
+
+// parseRSAKey parses the PEM private key of a service account
+func parseRSAKey(text string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return nil, errors.New("private key is not PEM")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("private key: %v", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+
+	return key, nil
+}
+
+// serviceAccountToken trades a JWT signed with the key of a service
+// account for an access token
+func serviceAccountToken(ctx context.Context, client *http.Client, key googleKey, signer *rsa.PrivateKey) (bearerToken, error) {
+	assertion, err := serviceAccountAssertion(key, signer, time.Now())
+	if err != nil {
+		return bearerToken{}, err
+	}
+
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {assertion}}
+	return postToken(ctx, client, firstNonEmpty(key.TokenURI, gcsTokenURI), form)
+}
+
+// serviceAccountAssertion returns the RS256 JWT asking for a token of the
+// service account, issued at now and valid for an hour
+func serviceAccountAssertion(key googleKey, signer *rsa.PrivateKey, now time.Time) (string, error) {
+	claims, err := json.Marshal(map[string]any{
+		"iss":   key.ClientEmail,
+		"scope": gcsScope,
+		"aud":   firstNonEmpty(key.TokenURI, gcsTokenURI),
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: token claims: %v", ErrRemote, err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + encode(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(nil, signer, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("%w: signing the token request: %v", ErrRemote, err)
+	}
+
+	return unsigned + "." + encode(signature), nil
+}
+
+// metadataToken asks the metadata server of Compute Engine, GKE or Cloud
+// Run for a token of the attached service account
+func metadataToken(ctx context.Context) (bearerToken, error) {
+	host := firstNonEmpty(os.Getenv("GCE_METADATA_HOST"), "metadata.google.internal")
+	endpoint := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token?scopes=" + url.QueryEscape(gcsScope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return bearerToken{}, fmt.Errorf("%w: %v", ErrRemote, err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var resp tokenResponse
+	if err := getJSON(&http.Client{Timeout: metadataTimeout}, req, &resp); err != nil {
+		return bearerToken{}, fmt.Errorf("%w: no Google credentials found; set GOOGLE_APPLICATION_CREDENTIALS or run gcloud auth application-default login (metadata server: %v)", ErrRemote, err)
+	}
+
+	return resp.token("the metadata server")
+}
+
+// Get reads the object at key whole, refusing one over limit bytes
+func (c *gcsClient) Get(ctx context.Context, bucket, key string, limit int64) ([]byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, bucket, key, c.objectURL(bucket, key)+"?alt=media", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Assertion 1: Refuse oversized objects before reading them
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("%w: %s%s/%s is %d bytes, over the %d byte limit", ErrTooLarge, gcsScheme, bucket, key, resp.ContentLength, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading %s%s/%s: %v", ErrRemote, gcsScheme, bucket, key, err)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s%s/%s is over the %d byte limit", ErrTooLarge, gcsScheme, bucket, key, limit)
+	}
+
+	return data, nil
+}
+
+// Put uploads data as the object at key in one request; Cloud Storage
+// shows the new object only once it is complete
+func (c *gcsClient) Put(ctx context.Context, bucket, key string, data []byte, contentType string) error {
+	endpoint := c.endpoint + "/upload/storage/v1/b/" + url.PathEscape(bucket) + "/o?uploadType=media&name=" + url.QueryEscape(key)
+	resp, err := c.send(ctx, http.MethodPost, bucket, key, endpoint, data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// gcsObject is the metadata of an object
+type gcsObject struct {
+	Name string      `json:"name"`
+	Size json.Number `json:"size"`
+	ETag string      `json:"etag"`
+}
+
+// remote returns o named name
+func (o gcsObject) remote(name string) RemoteObject {
+	size, _ := o.Size.Int64()
+	return RemoteObject{Name: name, Size: size, ETag: o.ETag}
+}
+
+// Stat returns the size and ETag of the object at key
+func (c *gcsClient) Stat(ctx context.Context, bucket, key string) (RemoteObject, error) {
+	resp, err := c.send(ctx, http.MethodGet, bucket, key, c.objectURL(bucket, key)+"?fields=name,size,etag", nil, "")
+	if err != nil {
+		return RemoteObject{}, err
+	}
+	defer resp.Body.Close()
+
+	var object gcsObject
+	if err := json.NewDecoder(resp.Body).Decode(&object); err != nil {
+		return RemoteObject{}, fmt.Errorf("%w: %s%s/%s: %v", ErrRemote, gcsScheme, bucket, key, err)
+	}
+
+	return object.remote(key), nil
+}
+
+// gcsList is a page of objects
+type gcsList struct {
+	Items         []gcsObject `json:"items"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+// List returns the objects whose names start with prefix, named relative
+// to it, up to limit of them; names ending in a slash are the folders of
+// consoles and are left out
+func (c *gcsClient) List(ctx context.Context, bucket, prefix string, limit int) ([]RemoteObject, error) {
+	var objects []RemoteObject
+	token := ""
+	for page := 0; page < gcsMaxPages; page++ {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name,size,etag),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+
+		resp, err := c.send(ctx, http.MethodGet, bucket, "", c.endpoint+"/storage/v1/b/"+url.PathEscape(bucket)+"/o?"+query.Encode(), nil, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var result gcsList
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: listing %s%s/%s: %v", ErrRemote, gcsScheme, bucket, prefix, err)
+		}
+
+		for _, object := range result.Items {
+			if strings.HasSuffix(object.Name, "/") {
+				continue
+			}
+
+			// Assertion 1: Bound the listing
+			if len(objects) == limit {
+				return nil, fmt.Errorf("%w: %s%s/%s holds more than %d objects", ErrRemote, gcsScheme, bucket, prefix, limit)
+			}
+
+			objects = append(objects, object.remote(strings.TrimPrefix(object.Name, prefix)))
+		}
+
+		if result.NextPageToken == "" {
+			return objects, nil
+		}
+		token = result.NextPageToken
+	}
+
+	return nil, fmt.Errorf("%w: listing %s%s/%s did not end after %d pages", ErrRemote, gcsScheme, bucket, prefix, gcsMaxPages)
+}
+
+// objectURL returns the JSON API URL of an object, whose name is one
+// escaped path segment
+func (c *gcsClient) objectURL(bucket, key string) string {
+	return c.endpoint + "/storage/v1/b/" + url.PathEscape(bucket) + "/o/" + url.PathEscape(key)
+}
+
+// send sends an authorized request for the object at key, or the bucket
+// when key is empty, retrying while Cloud Storage is busy; a missing
+// object wraps fs.ErrNotExist and any other failure ErrRemote
+func (c *gcsClient) send(ctx context.Context, method, bucket, key, endpoint string, body []byte, contentType string) (*http.Response, error) {
+	token := ""
+	if c.tokens != nil {
+		var err error
+		if token, err = c.tokens.get(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := sendRetrying(ctx, c.http, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRemote, err)
+		}
+
+		if body == nil {
+			req.Body, req.ContentLength = http.NoBody, 0
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s%s/%s: %v", ErrRemote, method, gcsScheme, bucket, key, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, gcsError(resp, method, bucket, key)
+	}
+
+	return resp, nil
+}
+
+// gcsErrorBody is the error document of the JSON API
+type gcsErrorBody struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// gcsError returns the error of a failed response, closing it; only an
+// object read can be missing, as a bucket is named by a listing or upload
+func gcsError(resp *http.Response, method, bucket, key string) error {
+	defer resp.Body.Close()
+
+	path := gcsScheme + bucket + "/" + key
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet && key != "" {
+		return fmt.Errorf("%w: %s %s: %w", ErrRemote, method, path, fs.ErrNotExist)
+	}
+
+	var body gcsErrorBody
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, remoteMaxBody))
+	if json.Unmarshal(data, &body) != nil || body.Error.Message == "" {
+		body.Error.Message = resp.Status
+	}
+
+	return fmt.Errorf("%w: %s %s: %s", ErrRemote, method, path, body.Error.Message)
+}
@@ -0,0 +1,154 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/internal/transform"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// gifDelayUnit is the resolution of GIF frame delays
+const gifDelayUnit = 10 * time.Millisecond
+
+// decodeGIF decodes every frame of a GIF file, compositing them onto the
+// logical screen as the disposal methods dictate
+// A single full-screen frame is kept paletted
+func decodeGIF(r io.Reader) (*Animation, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	// Assertion 1: Bound the number of frames held in memory
+	if len(g.Image) == 0 || len(g.Image) > MaxAnimationFrames {
+		return nil, fmt.Errorf("%w: GIF needs 1-%d frames", ErrDecode, MaxAnimationFrames)
+	}
+
+	screen := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if screen.Empty() {
+		screen = g.Image[0].Bounds()
+	}
+
+	// Assertion 2: Validate the logical screen
+	if err := validator.ValidateDimensions(screen.Dx(), screen.Dy()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	anim := &Animation{LoopCount: gifPlays(g.LoopCount)}
+	if palette, ok := g.Config.ColorModel.(color.Palette); ok && int(g.BackgroundIndex) < len(palette) {
+		anim.Background = color.NRGBAModel.Convert(palette[g.BackgroundIndex]).(color.NRGBA)
+	}
+
+	if len(g.Image) == 1 && g.Image[0].Bounds() == screen {
+		anim.Frames = []Frame{{Image: g.Image[0], Duration: gifDuration(g.Delay[0]), Disposal: Disposal(g.Disposal[0])}}
+		return anim, nil
+	}
+
+	canvas := image.NewNRGBA(screen)
+	var saved *image.NRGBA
+
+	for i := 0; i < len(g.Image); i++ {
+		frame := g.Image[i]
+		disposal := Disposal(g.Disposal[i])
+
+		if disposal == DisposalPrevious {
+			saved = cloneNRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Frames = append(anim.Frames, Frame{Image: cloneNRGBA(canvas), Duration: gifDuration(g.Delay[i]), Disposal: disposal})
+
+		switch disposal {
+		case DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case DisposalPrevious:
+			canvas = saved
+		}
+	}
+
+	return anim, nil
+}
+
+// encodeAnimatedGIF writes anim as a GIF, giving each frame that is not
+// already paletted its own palette
+// Frames are not dithered so static areas do not flicker between frames
+func encodeAnimatedGIF(w io.Writer, anim *Animation) error {
+	bounds := anim.Frames[0].Image.Bounds()
+	out := &gif.GIF{
+		LoopCount: gifLoopCount(anim.LoopCount),
+		Config:    image.Config{Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+
+	for i := 0; i < len(anim.Frames); i++ {
+		frame, err := toPaletted(anim.Frames[i].Image)
+		if err != nil {
+			return fmt.Errorf("frame %d: %v", i, err)
+		}
+
+		out.Image = append(out.Image, frame)
+		out.Delay = append(out.Delay, int((anim.Frames[i].Duration+gifDelayUnit/2)/gifDelayUnit))
+		out.Disposal = append(out.Disposal, byte(anim.Frames[i].Disposal))
+	}
+
+	return gif.EncodeAll(w, out)
+}
+
+// toPaletted returns img as a zero-origin paletted image, re-quantizing
+// it to an adaptive palette of up to 256 colors when needed
+func toPaletted(img image.Image) (*image.Paletted, error) {
+	// Assertion 1: Paletted frames keep their palette
+	if p, ok := img.(*image.Paletted); ok && p.Bounds().Min == (image.Point{}) && len(p.Palette) <= transform.MaxPaletteSize {
+		return p, nil
+	}
+
+	palette, err := transform.Quantize(img, transform.MaxPaletteSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return transform.Remap(img, palette, false)
+}
+
+// cloneNRGBA returns a copy of img
+func cloneNRGBA(img *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(img.Bounds())
+	copy(dst.Pix, img.Pix)
+	return dst
+}
+
+// gifDuration converts a GIF delay in hundredths of a second
+func gifDuration(delay int) time.Duration {
+	return time.Duration(delay) * gifDelayUnit
+}
+
+// gifPlays converts a GIF loop count, which counts repeats with -1 for a
+// single play, into the number of plays with zero for forever
+func gifPlays(loopCount int) int {
+	switch {
+	case loopCount == 0:
+		return 0
+	case loopCount < 0:
+		return 1
+	default:
+		return loopCount + 1
+	}
+}
+
+// gifLoopCount is the inverse of gifPlays
+func gifLoopCount(plays int) int {
+	switch {
+	case plays == 0:
+		return 0
+	case plays == 1:
+		return -1
+	default:
+		return plays - 1
+	}
+}
@@ -0,0 +1,377 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+const (
+	// iccHeaderSize is the fixed header before the tag table
+	iccHeaderSize = 128
+	// iccMaxTags bounds the tag table walk
+	iccMaxTags = 256
+	// iccMaxChunks is the most APP2 segments a profile can span
+	iccMaxChunks = 255
+	// iccCacheSize bounds the colors remembered by a conversion
+	iccCacheSize = 1 << 16
+)
+
+// iccMarker starts every APP2 segment carrying part of an ICC profile
+var iccMarker = []byte("ICC_PROFILE\x00")
+
+// iccLUT is an AToB lookup table of a CMYK profile: per channel input
+// curves, a four dimensional grid and per channel output curves, every
+// value normalized to [0, 1]
+type iccLUT struct {
+	input  [4][]float64
+	grid   int
+	clut   []float64
+	output [3][]float64
+	lab    bool // PCS is Lab, otherwise XYZ
+	lut8   bool // Eight bit tables, which encode Lab without the 16-bit legacy scaling
+}
+
+// decodeJPEG decodes a JPEG stream, converting CMYK images through their
+// embedded ICC profile when it holds a lookup table this package reads
+// CMYK without a usable profile stays CMYK and is converted naively later
+func decodeJPEG(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	cmyk, ok := img.(*image.CMYK)
+	if !ok {
+		return img, nil
+	}
+
+	lut, err := parseICCProfile(jpegICC(data))
+	if err != nil {
+		return img, nil
+	}
+
+	return lut.convert(cmyk), nil
+}
+
+// jpegICC joins the ICC profile chunks of the APP2 segments of a JPEG
+// stream in sequence order, or returns nil when the profile is missing or
+// incomplete
+func jpegICC(data []byte) []byte {
+	var chunks [iccMaxChunks + 1][]byte
+	total := 0
+	pos := 2
+
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+
+		// Metadata precedes the first scan
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE2 && bytes.HasPrefix(segment, iccMarker) && len(segment) >= len(iccMarker)+2 {
+			seq, count := int(segment[len(iccMarker)]), int(segment[len(iccMarker)+1])
+			if seq >= 1 && seq <= count {
+				chunks[seq] = segment[len(iccMarker)+2:]
+				total = count
+			}
+		}
+
+		pos += 2 + length
+	}
+
+	// Assertion 1: Every announced chunk must be present
+	var profile []byte
+	for seq := 1; seq <= total; seq++ {
+		if chunks[seq] == nil {
+			return nil
+		}
+		profile = append(profile, chunks[seq]...)
+	}
+
+	return profile
+}
+
+// parseICCProfile reads the perceptual, or else the relative colorimetric,
+// AToB table of a CMYK profile with a Lab or XYZ connection space
+// Only the lut8 and lut16 table types are read
+func parseICCProfile(profile []byte) (*iccLUT, error) {
+	be := binary.BigEndian
+
+	// Assertion 1: Validate the header and color spaces
+	if len(profile) < iccHeaderSize+4 || string(profile[36:40]) != "acsp" {
+		return nil, fmt.Errorf("not an ICC profile")
+	}
+
+	if string(profile[16:20]) != "CMYK" {
+		return nil, fmt.Errorf("ICC profile is not for CMYK data")
+	}
+
+	pcs := string(profile[20:24])
+	if pcs != "Lab " && pcs != "XYZ " {
+		return nil, fmt.Errorf("unknown ICC connection space %q", pcs)
+	}
+
+	count := int(be.Uint32(profile[iccHeaderSize:]))
+	if count > iccMaxTags || len(profile) < iccHeaderSize+4+12*count {
+		return nil, fmt.Errorf("ICC tag table overruns the profile")
+	}
+
+	tags := map[string][]byte{}
+	for i := 0; i < count; i++ {
+		entry := profile[iccHeaderSize+4+12*i:]
+		offset, size := uint64(be.Uint32(entry[4:8])), uint64(be.Uint32(entry[8:12]))
+		if offset+size <= uint64(len(profile)) {
+			tags[string(entry[0:4])] = profile[offset : offset+size]
+		}
+	}
+
+	// Assertion 2: Prefer the perceptual table
+	table, ok := tags["A2B0"]
+	if !ok {
+		if table, ok = tags["A2B1"]; !ok {
+			return nil, fmt.Errorf("ICC profile has no AToB table")
+		}
+	}
+
+	lut, err := parseICCLUT(table)
+	if err != nil {
+		return nil, err
+	}
+
+	lut.lab = pcs == "Lab "
+	return lut, nil
+}
+
+// parseICCLUT reads a lut8 (mft1) or lut16 (mft2) table with four inputs
+// and three outputs
+func parseICCLUT(table []byte) (*iccLUT, error) {
+	be := binary.BigEndian
+
+	// Assertion 1: Validate the type and channel counts
+	if len(table) < 48 {
+		return nil, fmt.Errorf("ICC lookup table truncated")
+	}
+
+	kind := string(table[0:4])
+	if kind != "mft1" && kind != "mft2" {
+		return nil, fmt.Errorf("ICC table type %q is not supported", kind)
+	}
+
+	if table[8] != 4 || table[9] != 3 || table[10] < 2 {
+		return nil, fmt.Errorf("ICC table maps %d channels to %d, want 4 to 3", table[8], table[9])
+	}
+
+	lut := &iccLUT{grid: int(table[10]), lut8: kind == "mft1"}
+	inputEntries, outputEntries, pos, sample := 256, 256, 48, 1
+	if !lut.lut8 {
+		if len(table) < 52 {
+			return nil, fmt.Errorf("ICC lookup table truncated")
+		}
+		inputEntries, outputEntries, pos, sample = int(be.Uint16(table[48:50])), int(be.Uint16(table[50:52])), 52, 2
+	}
+
+	if inputEntries < 2 || outputEntries < 2 {
+		return nil, fmt.Errorf("ICC curves need at least two entries")
+	}
+
+	// Assertion 2: The tables fit the tag
+	gridSize := lut.grid * lut.grid * lut.grid * lut.grid * 3
+	if len(table) < pos+sample*(4*inputEntries+gridSize+3*outputEntries) {
+		return nil, fmt.Errorf("ICC lookup table truncated")
+	}
+
+	// read returns n normalized values starting at pos
+	read := func(n int) []float64 {
+		values := make([]float64, n)
+		for i := range values {
+			if lut.lut8 {
+				values[i] = float64(table[pos+i]) / 255
+			} else {
+				values[i] = float64(be.Uint16(table[pos+2*i:])) / 65535
+			}
+		}
+		pos += sample * n
+		return values
+	}
+
+	for c := 0; c < 4; c++ {
+		lut.input[c] = read(inputEntries)
+	}
+	lut.clut = read(gridSize)
+	for c := 0; c < 3; c++ {
+		lut.output[c] = read(outputEntries)
+	}
+
+	return lut, nil
+}
+
+// curve looks v up in a table of evenly spaced samples with linear
+// interpolation
+func curve(table []float64, v float64) float64 {
+	position := max(0, min(1, v)) * float64(len(table)-1)
+	i := min(int(position), len(table)-2)
+	frac := position - float64(i)
+
+	return table[i] + frac*(table[i+1]-table[i])
+}
+
+// lookup maps normalized CMYK through the input curves, the grid with
+// quadrilinear interpolation and the output curves
+func (l *iccLUT) lookup(cmyk [4]float64) [3]float64 {
+	var base [4]int
+	var frac [4]float64
+	top := float64(l.grid - 1)
+
+	for c := 0; c < 4; c++ {
+		position := curve(l.input[c], cmyk[c]) * top
+		base[c] = min(int(position), l.grid-2)
+		frac[c] = position - float64(base[c])
+	}
+
+	var out [3]float64
+
+	// Blend the 16 corners of the enclosing grid cell
+	for corner := 0; corner < 16; corner++ {
+		weight := 1.0
+		index := 0
+
+		for c := 0; c < 4; c++ {
+			step := (corner >> (3 - c)) & 1
+			if step == 1 {
+				weight *= frac[c]
+			} else {
+				weight *= 1 - frac[c]
+			}
+			index = index*l.grid + base[c] + step
+		}
+
+		if weight == 0 {
+			continue
+		}
+
+		for o := 0; o < 3; o++ {
+			out[o] += weight * l.clut[3*index+o]
+		}
+	}
+
+	for o := 0; o < 3; o++ {
+		out[o] = curve(l.output[o], out[o])
+	}
+
+	return out
+}
+
+// toXYZ decodes the connection space values of a table into D50 XYZ
+func (l *iccLUT) toXYZ(pcs [3]float64) (float64, float64, float64) {
+	if !l.lab {
+		// lut16 XYZ is u1Fixed15, where 0x8000 is 1.0
+		scale := 65535.0 / 32768.0
+		if l.lut8 {
+			scale = 255.0 / 128.0
+		}
+		return pcs[0] * scale, pcs[1] * scale, pcs[2] * scale
+	}
+
+	// lut16 tables use the legacy Lab encoding, where 0xFF00 is L* 100
+	scale := 1.0
+	if !l.lut8 {
+		scale = 65535.0 / 65280.0
+	}
+
+	lightness := pcs[0] * scale * 100
+	a := pcs[1]*scale*255 - 128
+	b := pcs[2]*scale*255 - 128
+
+	fy := (lightness + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+
+	// D50 reference white
+	return 0.9642 * labInverse(fx), labInverse(fy), 0.8249 * labInverse(fz)
+}
+
+// labInverse inverts the cube root companding of CIELAB
+func labInverse(t float64) float64 {
+	if t > 6.0/29.0 {
+		return t * t * t
+	}
+
+	return 3 * (6.0 / 29.0) * (6.0 / 29.0) * (t - 4.0/29.0)
+}
+
+// convert maps every pixel of img to sRGB; distinct CMYK values are looked
+// up once
+func (l *iccLUT) convert(img *image.CMYK) *image.RGBA {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	cache := map[color.CMYK]color.RGBA{}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.CMYKAt(x, y)
+
+			rgba, ok := cache[c]
+			if !ok {
+				// Photographs can hold millions of colors, start over when full
+				if len(cache) >= iccCacheSize {
+					clear(cache)
+				}
+
+				rgba = l.sRGB(c)
+				cache[c] = rgba
+			}
+
+			dst.SetRGBA(x, y, rgba)
+		}
+	}
+
+	return dst
+}
+
+// sRGB converts one CMYK color through the table and a Bradford adapted
+// D50 to sRGB matrix
+func (l *iccLUT) sRGB(c color.CMYK) color.RGBA {
+	pcs := l.lookup([4]float64{float64(c.C) / 255, float64(c.M) / 255, float64(c.Y) / 255, float64(c.K) / 255})
+	x, y, z := l.toXYZ(pcs)
+
+	r := 3.1338561*x - 1.6168667*y - 0.4906146*z
+	g := -0.9787684*x + 1.9161415*y + 0.0334540*z
+	b := 0.0719453*x - 0.2289914*y + 1.4052427*z
+
+	return color.RGBA{R: srgbByte(r), G: srgbByte(g), B: srgbByte(b), A: 0xFF}
+}
+
+// srgbByte applies the sRGB transfer curve to a linear value
+func srgbByte(v float64) uint8 {
+	v = max(0, min(1, v))
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+
+	return uint8(math.Round(v * 255))
+}
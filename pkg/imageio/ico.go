@@ -0,0 +1,330 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+const (
+	// ICOMaxSize is the largest side an icon entry can have
+	ICOMaxSize = 256
+	// icoHeaderSize is the size of the ICONDIR header
+	icoHeaderSize = 6
+	// icoEntrySize is the size of one ICONDIRENTRY
+	icoEntrySize = 16
+	// icoMaxEntries bounds the entries read from or written to one file
+	icoMaxEntries = 256
+	// dibHeaderSize is the size of a BITMAPINFOHEADER
+	dibHeaderSize = 40
+)
+
+// ICOSizes are the square entry sizes of a favicon written from one source
+var ICOSizes = []int{16, 32, 48, 64, 128, 256}
+
+// pngSignature starts every PNG stream, including PNG icon entries
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// icoEntry is one directory entry of an ICO file
+type icoEntry struct {
+	width, height int
+	bitCount      int
+	data          []byte
+}
+
+// decodeICO decodes the largest image of an ICO or CUR file
+// Entries hold either a PNG stream or a DIB with an AND transparency mask
+func decodeICO(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	entries, err := readICOEntries(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assertion 1: Pick the largest entry, deepest color on ties
+	best := entries[0]
+	for i := 1; i < len(entries); i++ {
+		e := entries[i]
+		if e.width*e.height > best.width*best.height || (e.width*e.height == best.width*best.height && e.bitCount > best.bitCount) {
+			best = e
+		}
+	}
+
+	if bytes.HasPrefix(best.data, pngSignature) {
+		img, err := png.Decode(bytes.NewReader(best.data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+		}
+
+		return img, nil
+	}
+
+	return decodeDIB(best.data)
+}
+
+// readICOEntries parses the icon directory of data
+func readICOEntries(data []byte) ([]icoEntry, error) {
+	// Assertion 1: Validate the ICONDIR header, type 1 is an icon, 2 a cursor
+	if len(data) < icoHeaderSize || binary.LittleEndian.Uint16(data[0:2]) != 0 {
+		return nil, fmt.Errorf("%w: not an ICO file", ErrDecode)
+	}
+
+	kind := binary.LittleEndian.Uint16(data[2:4])
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if (kind != 1 && kind != 2) || count == 0 || count > icoMaxEntries {
+		return nil, fmt.Errorf("%w: ICO file needs 1-%d entries", ErrDecode, icoMaxEntries)
+	}
+
+	// Assertion 2: The directory fits in the file
+	if len(data) < icoHeaderSize+count*icoEntrySize {
+		return nil, fmt.Errorf("%w: truncated ICO directory", ErrDecode)
+	}
+
+	entries := make([]icoEntry, 0, count)
+	for i := 0; i < count; i++ {
+		b := data[icoHeaderSize+i*icoEntrySize:]
+		size := uint64(binary.LittleEndian.Uint32(b[8:12]))
+		offset := uint64(binary.LittleEndian.Uint32(b[12:16]))
+
+		// Assertion 3: Every entry lies inside the file
+		if size == 0 || offset+size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: ICO entry %d overruns the file", ErrDecode, i)
+		}
+
+		entries = append(entries, icoEntry{
+			width:    icoDimension(b[0]),
+			height:   icoDimension(b[1]),
+			bitCount: int(binary.LittleEndian.Uint16(b[6:8])),
+			data:     data[offset : offset+size],
+		})
+	}
+
+	return entries, nil
+}
+
+// icoDimension converts a directory size byte, where zero means 256
+func icoDimension(b byte) int {
+	if b == 0 {
+		return ICOMaxSize
+	}
+
+	return int(b)
+}
+
+// decodeDIB decodes the bottom-up BITMAPINFOHEADER image of an icon entry,
+// whose height counts both the color rows and the AND mask rows
+func decodeDIB(data []byte) (image.Image, error) {
+	// Assertion 1: Validate the header
+	if len(data) < dibHeaderSize {
+		return nil, fmt.Errorf("%w: truncated icon bitmap header", ErrDecode)
+	}
+
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bitCount := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:36]))
+
+	if headerSize < dibHeaderSize || headerSize > len(data) {
+		return nil, fmt.Errorf("%w: invalid icon bitmap header size %d", ErrDecode, headerSize)
+	}
+
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	// Assertion 2: Uncompressed 1, 4, 8, 24 and 32 bit bitmaps only,
+	// 32 bit ones may declare the standard BGRA bit fields
+	switch {
+	case compression == 0 && (bitCount == 1 || bitCount == 4 || bitCount == 8 || bitCount == 24 || bitCount == 32):
+	case compression == 3 && bitCount == 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported icon bitmap, %d bits with compression %d", ErrDecode, bitCount, compression)
+	}
+
+	pos := headerSize
+	var palette color.Palette
+	if bitCount <= 8 {
+		if colorsUsed == 0 || colorsUsed > 1<<bitCount {
+			colorsUsed = 1 << bitCount
+		}
+
+		if len(data) < pos+4*colorsUsed {
+			return nil, fmt.Errorf("%w: truncated icon palette", ErrDecode)
+		}
+
+		palette = make(color.Palette, colorsUsed)
+		for i := 0; i < colorsUsed; i++ {
+			b := data[pos+4*i:]
+			palette[i] = color.NRGBA{R: b[2], G: b[1], B: b[0], A: 0xFF}
+		}
+
+		pos += 4 * colorsUsed
+	}
+
+	// Assertion 3: The color rows must be present, the mask may be cut short
+	stride := (width*bitCount + 31) / 32 * 4
+	if len(data) < pos+stride*height {
+		return nil, fmt.Errorf("%w: truncated icon bitmap", ErrDecode)
+	}
+
+	pixels := data[pos : pos+stride*height]
+	mask := data[pos+stride*height:]
+	maskStride := (width + 31) / 32 * 4
+	if len(mask) < maskStride*height {
+		mask = nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+
+	for y := 0; y < height; y++ {
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			c := dibPixel(row, x, bitCount, palette)
+			hasAlpha = hasAlpha || c.A != 0
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// 32 bit entries carry alpha unless every pixel is zero, older ones
+	// rely on the AND mask where a set bit is transparent
+	if bitCount == 32 && hasAlpha {
+		return img, nil
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := img.PixOffset(x, y) + 3
+			img.Pix[i] = 0xFF
+
+			if mask != nil && mask[(height-1-y)*maskStride+x/8]&(0x80>>(x%8)) != 0 {
+				img.Pix[i] = 0
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// dibPixel reads pixel x of a DIB row, alpha is only meaningful at 32 bits
+func dibPixel(row []byte, x, bitCount int, palette color.Palette) color.NRGBA {
+	switch bitCount {
+	case 32:
+		return color.NRGBA{R: row[4*x+2], G: row[4*x+1], B: row[4*x], A: row[4*x+3]}
+	case 24:
+		return color.NRGBA{R: row[3*x+2], G: row[3*x+1], B: row[3*x], A: 0}
+	}
+
+	// Paletted rows pack the leftmost pixel in the high bits
+	perByte := 8 / bitCount
+	shift := uint(8 - bitCount*(x%perByte+1))
+	index := int(row[x/perByte]>>shift) & (1<<bitCount - 1)
+
+	// Assertion 1: Indices past the palette read as black
+	if index >= len(palette) {
+		return color.NRGBA{}
+	}
+
+	c := palette[index].(color.NRGBA)
+	c.A = 0
+	return c
+}
+
+// encodeICO writes images as the entries of one ICO file, each stored as
+// a PNG stream, which every current browser and Windows since Vista reads
+func encodeICO(w io.Writer, images []image.Image) error {
+	// Assertion 1: Validate the entry count
+	if len(images) == 0 || len(images) > icoMaxEntries {
+		return fmt.Errorf("ICO file needs 1-%d entries", icoMaxEntries)
+	}
+
+	streams := make([][]byte, len(images))
+	encoder := &png.Encoder{CompressionLevel: PNGCompression}
+
+	for i := 0; i < len(images); i++ {
+		size := images[i].Bounds().Size()
+
+		// Assertion 2: Entries are limited to 256 pixels per side
+		if size.X < 1 || size.Y < 1 || size.X > ICOMaxSize || size.Y > ICOMaxSize {
+			return fmt.Errorf("ICO entry %d is %dx%d, sides must be 1-%d", i, size.X, size.Y, ICOMaxSize)
+		}
+
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, images[i]); err != nil {
+			return err
+		}
+
+		streams[i] = buf.Bytes()
+	}
+
+	out := make([]byte, 0, icoHeaderSize+icoEntrySize*len(images))
+	out = binary.LittleEndian.AppendUint16(out, 0)
+	out = binary.LittleEndian.AppendUint16(out, 1)
+	out = binary.LittleEndian.AppendUint16(out, uint16(len(images)))
+
+	offset := icoHeaderSize + icoEntrySize*len(images)
+	for i := 0; i < len(images); i++ {
+		size := images[i].Bounds().Size()
+
+		// A side of 256 is stored as zero
+		out = append(out, byte(size.X), byte(size.Y), 0, 0)
+		out = binary.LittleEndian.AppendUint16(out, 1)
+		out = binary.LittleEndian.AppendUint16(out, 32)
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(streams[i])))
+		out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+		offset += len(streams[i])
+	}
+
+	for i := 0; i < len(streams); i++ {
+		out = append(out, streams[i]...)
+	}
+
+	_, err := w.Write(out)
+	return err
+}
+
+// SaveIcon saves images as the entries of one ICO file, such as the
+// ICOSizes renditions of a favicon
+func SaveIcon(path string, images []image.Image) error {
+	// Assertion 1: Validate path
+	if err := validator.ValidatePath(path); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	data, err := EncodeIcon(images)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// EncodeIcon encodes images in memory as one ICO file
+func EncodeIcon(images []image.Image) ([]byte, error) {
+	// Assertion 1: Validate images
+	for i := 0; i < len(images); i++ {
+		if images[i] == nil {
+			return nil, fmt.Errorf("%w: icon entry %d is nil", ErrFileCreate, i)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := encodeICO(&buf, images); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -2,19 +2,23 @@
 package imageio
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/kasurarykerion/golangresizer/internal/transform"
 	"github.com/kasurarykerion/golangresizer/internal/validator"
-	"golang.org/x/image/bmp"
 	"golang.org/x/image/tiff"
-	"golang.org/x/image/webp"
 )
 
 var (
@@ -23,20 +27,27 @@ var (
 	ErrFileCreate        = errors.New("failed to create file")
 	ErrDecode            = errors.New("failed to decode image")
 	ErrEncode            = errors.New("failed to encode image")
+	ErrTooLarge          = errors.New("image too large")
+	ErrCanceled          = errors.New("operation canceled")
 )
 
 const (
-	// JPEGQuality defines the JPEG encoding quality (1-100)
+	// JPEGQuality defines the JPEG encoding quality (1-100) when
+	// EncodeOptions leaves it unset
 	JPEGQuality = 95
-	// PNGCompression defines PNG compression level
+	// PNGCompression defines the PNG compression level when EncodeOptions
+	// leaves it unset
 	PNGCompression = png.DefaultCompression
 )
 
 // SupportedFormats lists all supported image formats
-var SupportedFormats = []string{".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".tif", ".webp"}
+var SupportedFormats = []string{".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".tif", ".webp", ".gif", ".ico", ".ppm", ".pgm", ".pbm", ".pnm", ".exr", ".hdr", ".psd", ".psb", ".dng", ".cr2", ".nef", ".dds"}
 
-// LoadImage loads an image from the specified file path
-func LoadImage(path string) (image.Image, error) {
+// OutputFormats lists the formats images can be saved as
+var OutputFormats = []string{".jpg", ".jpeg", ".png", ".bmp", ".tiff", ".tif", ".webp", ".gif", ".ico", ".ppm", ".pgm", ".pbm", ".pnm", ".exr", ".hdr", ".dds"}
+
+// openFile opens path for reading after checking its path and size
+func openFile(path string) (*os.File, error) {
 	// Assertion 1: Validate path
 	if err := validator.ValidatePath(path); err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrFileOpen, err)
@@ -47,48 +58,332 @@ func LoadImage(path string) (image.Image, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrFileOpen, err)
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			// Log error but don't override return error
-		}
-	}()
 
 	// Assertion 3: Get file info to validate size
 	fileInfo, err := file.Stat()
 	if err != nil {
+		file.Close()
 		return nil, fmt.Errorf("%w: cannot stat file: %v", ErrFileOpen, err)
 	}
 
 	// Assertion 4: Check file size is within limits
 	if fileInfo.Size() > validator.MaxFileSize {
+		file.Close()
 		return nil, fmt.Errorf("%w: file too large", ErrFileOpen)
 	}
 
-	// Determine format from extension
-	ext := strings.ToLower(filepath.Ext(path))
-	
+	return file, nil
+}
+
+// LoadImage loads an image from the specified file path
+// The format is sniffed from the file contents, the extension only
+// decides when they are ambiguous
+// Animated files yield their first frame, icons their largest entry
+func LoadImage(path string) (image.Image, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			// Log error but don't override return error
+		}
+	}()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeImage(file, ext)
+}
+
+// DecodeOptions tunes LoadImageWithOptions; the zero value loads like
+// LoadImage
+type DecodeOptions struct {
+	MaxPixels    int  // Reject images with more pixels before decoding them, zero for no limit
+	MemoryMap    bool // Map the file into memory instead of reading it, saving copies for large local files
+	AutoOrient   bool // Turn JPEG, PNG, WebP and TIFF images upright from their EXIF orientation
+	TargetWidth  int  // Shrink by a whole factor while staying at least this wide, zero for any width
+	TargetHeight int  // Shrink by a whole factor while staying at least this tall, zero for any height
+}
+
+// validate rejects negative limits
+func (o DecodeOptions) validate() error {
+	if o.MaxPixels < 0 || o.TargetWidth < 0 || o.TargetHeight < 0 {
+		return fmt.Errorf("%w: decode limits must not be negative", ErrDecode)
+	}
+
+	return nil
+}
+
+// LoadImageWithOptions loads an image like LoadImage, checking its size
+// from the header before decoding and shrinking it right after
+// JPEG input takes the power of two part of the shrink from its DCT
+// blocks while decoding; other decoders cannot skip detail, so for them
+// the shrink saves the later resize work rather than decode time
+func LoadImageWithOptions(path string, opts DecodeOptions) (image.Image, error) {
+	// Assertion 1: Validate the options
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	file, err := openInput(path, opts.MemoryMap)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// A mapped file is decoded in place and unmapped on return, after
+	// every decoder has copied out what it keeps
+	data, err := readAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	return decodeWithOptions(data, ext, opts)
+}
+
+// decodeWithOptions decodes data in the format named by ext as
+// LoadImageWithOptions describes
+func decodeWithOptions(data []byte, ext string, opts DecodeOptions) (image.Image, error) {
+	// Assertion 2: Reject huge images from their header
+	if opts.MaxPixels > 0 {
+		config, err := decodeConfig(bytes.NewReader(data), ext)
+		if err != nil {
+			return nil, err
+		}
+
+		if config.Pixels() > opts.MaxPixels {
+			return nil, fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrTooLarge, config.Width, config.Height, opts.MaxPixels)
+		}
+	}
+
+	// Assertion 3: Split the shrink into a reduced decode and the rest
+	factor := 1
+	if isJPEG(ext) && (opts.TargetWidth > 0 || opts.TargetHeight > 0) {
+		factor = jpegShrink(data, ext, opts)
+	}
+
+	img, scale, err := decodeReducedData(data, ext, reductionDividing(factor))
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.AutoOrient {
+		if img, err = Orient(img, exifOrientation(data, ext)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+		}
+	}
+
+	// Assertion 4: Shrink by the largest factor both target sides allow
+	if scale == 1 {
+		factor = shrinkFactor(img.Bounds(), opts.TargetWidth, opts.TargetHeight)
+	} else {
+		factor /= scale
+	}
+
+	if factor > 1 {
+		if img, err = transform.Shrink(img, factor); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+		}
+	}
+
+	return img, nil
+}
+
+// checkPixels rejects an image with more than maxPixels pixels from the
+// header at the start of file, rewinding it for the decoder
+func checkPixels(file io.ReadSeeker, ext string, maxPixels int) error {
+	config, err := decodeConfig(file, ext)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	// Assertion 1: Refuse before any pixel is decoded
+	if config.Pixels() > maxPixels {
+		return fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrTooLarge, config.Width, config.Height, maxPixels)
+	}
+
+	return nil
+}
+
+// shrinkFactor returns the largest whole factor that keeps bounds at
+// least targetWidth x targetHeight, ignoring zero sides
+func shrinkFactor(bounds image.Rectangle, targetWidth, targetHeight int) int {
+	if targetWidth == 0 && targetHeight == 0 {
+		return 1
+	}
+
+	factor := math.MaxInt
+	if targetWidth > 0 {
+		factor = min(factor, bounds.Dx()/targetWidth)
+	}
+	if targetHeight > 0 {
+		factor = min(factor, bounds.Dy()/targetHeight)
+	}
+
+	return max(factor, 1)
+}
+
+// LoadReduced loads an image like LoadImage, decoding JPEG input at
+// 1/scale of its size rounded up when scale is 2, 4 or 8, from the low
+// frequencies of its DCT blocks, which is several times faster than
+// decoding it whole; it returns the scale applied, 1 for other formats and
+// for JPEG layouts that are only decoded whole such as CMYK
+func LoadReduced(path string, scale int) (image.Image, int, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Assertion 1: Only JPEG input reduces
+	if !isJPEG(ext) || scale == 1 {
+		img, err := decodeImage(file, ext)
+		return img, 1, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	return decodeReducedData(data, ext, scale)
+}
+
+// decodeReducedData decodes data in the format named by ext, a JPEG at
+// 1/scale of its size when the reduced decoder takes it, and returns the
+// scale applied
+// Streams the reduced decoder rejects are decoded whole, so image/jpeg
+// reports any damage in its usual terms
+func decodeReducedData(data []byte, ext string, scale int) (image.Image, int, error) {
+	if isJPEG(ext) && scale > 1 {
+		if img, err := decodeJPEGReduced(data, scale); err == nil {
+			return img, scale, nil
+		}
+	}
+
+	img, err := decodeImage(bytes.NewReader(data), ext)
+	return img, 1, err
+}
+
+// isJPEG reports whether ext names the JPEG format
+func isJPEG(ext string) bool {
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// jpegShrink returns the shrink factor LoadImageWithOptions applies to a
+// JPEG, read from its header and orientation before decoding
+func jpegShrink(data []byte, ext string, opts DecodeOptions) int {
+	config, err := decodeConfig(bytes.NewReader(data), ext)
+	if err != nil {
+		return 1
+	}
+
+	bounds := image.Rect(0, 0, config.Width, config.Height)
+	// Orientations 5 to 8 swap the sides
+	if orientation := exifOrientation(data, ext); opts.AutoOrient && orientation >= 5 && orientation <= 8 {
+		bounds = image.Rect(0, 0, config.Height, config.Width)
+	}
+
+	return shrinkFactor(bounds, opts.TargetWidth, opts.TargetHeight)
+}
+
+// reductionDividing returns the largest JPEG reduction, 8, 4 or 2, that
+// divides factor, or 1 when none does
+func reductionDividing(factor int) int {
+	for scale := MaxJPEGReduction; scale > 1; scale /= 2 {
+		if factor%scale == 0 {
+			return scale
+		}
+	}
+
+	return 1
+}
+
+// decodeImage decodes a still image in the format named by ext
+func decodeImage(file io.Reader, ext string) (image.Image, error) {
 	var img image.Image
+	var err error
+
 	switch ext {
 	case ".jpg", ".jpeg":
-		img, err = jpeg.Decode(file)
+		img, err = decodeJPEG(file)
 	case ".png":
 		img, err = png.Decode(file)
 	case ".bmp":
-		img, err = bmp.Decode(file)
+		img, err = decodeBMP(file)
 	case ".tiff", ".tif":
 		img, err = tiff.Decode(file)
 	case ".webp":
-		img, err = webp.Decode(file)
+		anim, err := decodeWebP(file)
+		if err != nil {
+			return nil, err
+		}
+
+		img = anim.Frames[0].Image
+	case ".gif":
+		img, err = gif.Decode(file)
+	case ".ico":
+		img, err = decodeICO(file)
+		if err != nil {
+			return nil, err
+		}
+	case ".ppm", ".pgm", ".pbm", ".pnm":
+		img, err = decodePNM(file)
+		if err != nil {
+			return nil, err
+		}
+	case ".exr":
+		img, err = decodeEXR(file)
+		if err != nil {
+			return nil, err
+		}
+	case ".hdr":
+		img, err = decodeRadiance(file)
+		if err != nil {
+			return nil, err
+		}
+	case ".psd", ".psb":
+		img, err = decodePSD(file)
+		if err != nil {
+			return nil, err
+		}
+	case ".dng", ".cr2", ".nef":
+		img, err = decodeRaw(file)
+		if err != nil {
+			return nil, err
+		}
+	case ".dds":
+		img, err = decodeDDS(file)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
 	}
 
-	// Assertion 5: Check decode result
+	// Assertion 1: Check decode result
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
 	}
 
-	// Assertion 6: Validate decoded image
+	// Assertion 2: Validate decoded image
 	if img == nil {
 		return nil, fmt.Errorf("%w: decoded image is nil", ErrDecode)
 	}
@@ -96,65 +391,280 @@ func LoadImage(path string) (image.Image, error) {
 	return img, nil
 }
 
-// SaveImage saves an image to the specified file path
-func SaveImage(path string, img image.Image) error {
+// EncodeOptions tunes how images are encoded; each encoder reads only its
+// own settings, and the zero value gives the defaults
+// WebP output is always lossless, so it has no settings
+type EncodeOptions struct {
+	DPI    float64 // Pixel density recorded in the file, zero keeps the encoder default
+	Format string  // Output extension such as ".png", empty uses the path's extension
+
+	// Metadata from ReadMetadata for still output, empty writes none; EXIF
+	// goes to JPEG, PNG and WebP, XMP to those and TIFF, IPTC to JPEG and
+	// TIFF
+	Metadata Metadata
+
+	JPEG JPEGOptions // JPEG output, and TIFF output with JPEG compression
+	PNG  PNGOptions
+	TIFF TIFFOptions
+	BMP  BMPOptions
+}
+
+// JPEGOptions tunes the JPEG encoders
+type JPEGOptions struct {
+	Quality     int  // 1-100, zero uses JPEGQuality
+	Progressive bool // Write progressive scans instead of a baseline frame
+}
+
+// PNGOptions tunes the PNG encoder
+type PNGOptions struct {
+	Compression png.CompressionLevel // Zero is the PNGCompression default
+	Chunks      []PNGChunk           // Ancillary chunks from ReadPNGChunks, written after IHDR
+}
+
+// TIFFOptions tunes the TIFF encoder
+type TIFFOptions struct {
+	Compression TIFFCompression // Strip compression, Deflate by default
+	Predictor   bool            // Apply the horizontal predictor before LZW or Deflate
+}
+
+// BMPOptions tunes the BMP encoder; the zero value writes gray and
+// paletted images at 8 bits, translucent ones at 32 and the rest at 24
+type BMPOptions struct {
+	BitDepth int  // 24 or 32 bits per pixel for every image, zero picks per image
+	RLE      bool // Run-length encode gray or paletted images as 8-bit RLE
+}
+
+// optionsOf returns the single optional EncodeOptions of a variadic call
+func optionsOf(opts []EncodeOptions) (EncodeOptions, error) {
+	switch len(opts) {
+	case 0:
+		return EncodeOptions{}, nil
+	case 1:
+		return opts[0], nil
+	default:
+		return EncodeOptions{}, fmt.Errorf("%w: %d option sets given, want at most one", ErrFileCreate, len(opts))
+	}
+}
+
+// format returns the extension selecting the encoder for path
+func (o EncodeOptions) format(path string) string {
+	if o.Format != "" {
+		return o.Format
+	}
+
+	return strings.ToLower(filepath.Ext(path))
+}
+
+// ParseFormat turns a format name such as "png", ".JPG" or "jpeg" into
+// the output extension that selects its encoder
+func ParseFormat(name string) (string, error) {
+	ext := "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), ".")
+
+	// Assertion 1: The format must have an encoder
+	for i := 0; i < len(OutputFormats); i++ {
+		if ext == OutputFormats[i] {
+			return ext, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: cannot save as %q", ErrUnsupportedFormat, name)
+}
+
+// SaveImage saves an image to the specified file path, encoded with opts
+// when given; calls without options keep the defaults
+func SaveImage(path string, img image.Image, opts ...EncodeOptions) error {
+	return SaveImageContext(context.Background(), path, img, opts...)
+}
+
+// SaveImageContext saves an image like SaveImage, giving up when ctx is
+// done before the file is written; an existing file at path is left as
+// it was
+func SaveImageContext(ctx context.Context, path string, img image.Image, opts ...EncodeOptions) error {
 	// Assertion 1: Validate path
 	if err := validator.ValidatePath(path); err != nil {
 		return fmt.Errorf("%w: %v", ErrFileCreate, err)
 	}
 
-	// Assertion 2: Validate image is not nil
+	options, err := optionsOf(opts)
+	if err != nil {
+		return err
+	}
+
+	// Encode first so unsupported formats leave no empty file behind
+	data, err := untilDone(ctx, func() ([]byte, error) {
+		return encodeImage(img, options.format(path), options)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// SaveImageWithOptions saves an image to the specified file path
+//
+// Deprecated: SaveImage takes the options directly
+func SaveImageWithOptions(path string, img image.Image, opts EncodeOptions) error {
+	return SaveImage(path, img, opts)
+}
+
+// EncodeImage encodes img in memory in the format named by opts.Format,
+// for callers that send the bytes somewhere other than a file
+func EncodeImage(img image.Image, opts EncodeOptions) ([]byte, error) {
+	// Assertion 1: Without a path the format must be given
+	if opts.Format == "" {
+		return nil, fmt.Errorf("%w: no output format given", ErrUnsupportedFormat)
+	}
+
+	return encodeImage(img, opts.Format, opts)
+}
+
+// encodeImage validates img and encodes it as ext, recording the DPI and
+// metadata of opts when set
+func encodeImage(img image.Image, ext string, opts EncodeOptions) ([]byte, error) {
+	dpi := opts.DPI
+
+	// Assertion 1: Validate image is not nil
 	if img == nil {
-		return fmt.Errorf("%w: image is nil", ErrFileCreate)
+		return nil, fmt.Errorf("%w: image is nil", ErrFileCreate)
 	}
 
-	// Assertion 3: Validate image dimensions
+	// Assertion 2: Validate image dimensions
 	bounds := img.Bounds()
 	if err := validator.ValidateDimensions(bounds.Dx(), bounds.Dy()); err != nil {
-		return fmt.Errorf("%w: invalid dimensions: %v", ErrFileCreate, err)
+		return nil, fmt.Errorf("%w: invalid dimensions: %v", ErrFileCreate, err)
 	}
 
-	// Create output directory if it doesn't exist
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("%w: cannot create directory: %v", ErrFileCreate, err)
+	if dpi != 0.0 {
+		if err := ValidateDPI(dpi); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFileCreate, err)
+		}
 	}
 
-	// Assertion 4: Create output file
-	file, err := os.Create(path)
+	if opts.JPEG.Quality != 0 {
+		if err := ValidateQuality(opts.JPEG.Quality); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFileCreate, err)
+		}
+	}
+
+	if !opts.Metadata.Empty() {
+		if err := opts.Metadata.check(ext); err != nil {
+			return nil, err
+		}
+
+		meta, err := opts.Metadata.forImage(img)
+		if err != nil {
+			return nil, err
+		}
+		opts.Metadata = meta
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, ext, img, opts); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	if dpi != 0.0 {
+		patched, err := withDPI(ext, data, dpi)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+		}
+
+		data = patched
+	}
+
+	if ext == ".png" && len(opts.PNG.Chunks) > 0 {
+		patched, err := pngWithChunks(data, opts.PNG.Chunks)
+		if err != nil {
+			return nil, err
+		}
+
+		data = patched
+	}
+
+	if !opts.Metadata.Empty() {
+		patched, err := withMetadata(ext, data, opts.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+		}
+
+		data = patched
+	}
+
+	return data, nil
+}
+
+// writeFile writes data to path, creating its directory when needed, or
+// stores it as a remote object; the file appears at path only once it is
+// complete
+func writeFile(path string, data []byte) error {
+	if IsRemote(path) {
+		return WriteRemote(context.Background(), path, data)
+	}
+
+	file, err := createOutput(path)
 	if err != nil {
+		return err
+	}
+
+	// Assertion 1: Check write result
+	if _, err := file.Write(data); err != nil {
+		file.abort()
 		return fmt.Errorf("%w: %v", ErrFileCreate, err)
 	}
 
-	// Ensure file is closed and check for errors
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			// Error during close
-		}
-	}()
+	return file.commit()
+}
 
-	// Determine format from extension
-	ext := strings.ToLower(filepath.Ext(path))
+// encode writes img to w in the format selected by ext
+func encode(w io.Writer, ext string, img image.Image, opts EncodeOptions) error {
+	var err error
 
 	switch ext {
 	case ".jpg", ".jpeg":
-		// Assertion 5: Check JPEG encode
-		err = jpeg.Encode(file, img, &jpeg.Options{Quality: JPEGQuality})
+		// Assertion 1: Check JPEG encode, baseline unless progressive is asked
+		if opts.JPEG.Progressive {
+			err = encodeProgressiveJPEG(w, img, opts.JPEG.quality())
+		} else {
+			err = jpeg.Encode(w, img, &jpeg.Options{Quality: opts.JPEG.quality()})
+		}
 	case ".png":
-		// Assertion 6: Check PNG encode
-		encoder := &png.Encoder{CompressionLevel: PNGCompression}
-		err = encoder.Encode(file, img)
+		// Assertion 2: Check PNG encode
+		encoder := &png.Encoder{CompressionLevel: opts.PNG.Compression}
+		err = encoder.Encode(w, img)
 	case ".bmp":
-		// Assertion 7: Check BMP encode
-		err = bmp.Encode(file, img)
+		// Assertion 3: Check BMP encode
+		err = encodeBMP(w, img, opts.BMP)
 	case ".tiff", ".tif":
-		// Assertion 8: Check TIFF encode
-		err = tiff.Encode(file, img, &tiff.Options{Compression: tiff.Deflate})
+		// Assertion 4: Check TIFF encode
+		err = encodeTIFF(w, img, opts.TIFF, opts.JPEG.quality(), opts.Metadata)
+	case ".gif":
+		// Assertion 5: Check GIF encode, paletted images keep their palette
+		err = gif.Encode(w, img, &gif.Options{NumColors: 256})
+	case ".webp":
+		// Assertion 6: Check WebP encode, always lossless
+		err = encodeWebP(w, img)
+	case ".ico":
+		// Assertion 7: Check ICO encode, a single entry
+		err = encodeICO(w, []image.Image{img})
+	case ".ppm", ".pgm", ".pbm", ".pnm":
+		// Assertion 8: Check Netpbm encode, always binary
+		err = encodePNM(w, ext, img)
+	case ".exr":
+		// Assertion 9: Check OpenEXR encode, half floats keep values beyond white
+		err = encodeEXR(w, img)
+	case ".hdr":
+		// Assertion 10: Check Radiance encode
+		err = encodeRadiance(w, img)
+	case ".dds":
+		// Assertion 11: Check DDS encode, uncompressed BGRA
+		err = encodeDDS(w, img)
 	default:
 		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
 	}
 
-	// Assertion 9: Check encode result
+	// Assertion 12: Check encode result
 	if err != nil {
 		return fmt.Errorf("%w: %v", ErrEncode, err)
 	}
@@ -0,0 +1,982 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+// JPEG markers read by the reduced decoder besides those the progressive
+// encoder writes
+const (
+	markerSOF0  = 0xC0
+	markerSOF1  = 0xC1
+	markerRST0  = 0xD0
+	markerRST7  = 0xD7
+	markerDRI   = 0xDD
+	markerAPP0  = 0xE0
+	markerAPP14 = 0xEE
+)
+
+// jpegBlockSide is the width and height of a DCT block
+const jpegBlockSide = 8
+
+// jpegLookupBits is the code length the Huffman decoder resolves with a
+// single table lookup, longer codes are searched length by length
+const jpegLookupBits = 9
+
+// MaxJPEGReduction is the largest reduced decode, one sample per block
+const MaxJPEGReduction = 8
+
+// errJPEGLayout marks streams the reduced decoder leaves to image/jpeg:
+// CMYK and RGB color, 12-bit samples, arithmetic coding and the lossless
+// and hierarchical modes
+var errJPEGLayout = errors.New("JPEG layout not supported by the reduced decoder")
+
+// jpegHuffDecoder resolves the codes of one Huffman table
+type jpegHuffDecoder struct {
+	lookup  [1 << jpegLookupBits]uint16 // Code length << 8 | symbol, zero for longer codes
+	maxCode [17]int32                   // Largest code of each length, -1 when none
+	offset  [17]int32                   // Index of the first symbol of each length, less its code
+	symbols [256]byte
+}
+
+// reducedComponent is one color plane of the frame being decoded
+type reducedComponent struct {
+	id      byte
+	h, v    int // Sampling factors
+	quant   int
+	dc, ac  int // Huffman tables of the current scan
+	pred    int32
+	blocksX int // Blocks per row and column covering whole MCUs
+	blocksY int
+	codedX  int // Blocks per row and column a single component scan codes
+	codedY  int
+	plane   []byte
+	stride  int
+	coeffs  [][jpegBlockSize]int16 // Natural order, progressive frames only
+}
+
+// reducedScan is the header of one scan
+type reducedScan struct {
+	components []int
+	start, end int // Spectral selection in zig-zag order
+	high, low  int // Successive approximation bit positions
+}
+
+// reducedDecoder decodes a baseline or progressive JPEG at 1/scale of its
+// size, inverting only the lowest 8/scale frequencies of each block
+type reducedDecoder struct {
+	data  []byte
+	pos   int
+	acc   uint64 // Entropy bits, most significant first
+	nbits uint
+	stop  bool // The bit reader reached a marker and feeds zeros
+	short bool // The bit reader ran off the end of the data
+	err   error
+
+	size        int // Samples per block side, 8/scale
+	cosines     []float32
+	width       int
+	height      int
+	progressive bool
+	frame       bool
+	jfif        bool
+	adobe       bool
+	transform   byte
+	restart     int
+	eobRun      int
+	quant       [4][jpegBlockSize]float32 // Natural order
+	dcTables    [4]*jpegHuffDecoder
+	acTables    [4]*jpegHuffDecoder
+	comps       []reducedComponent
+	hmax, vmax  int
+	mcusX       int
+	mcusY       int
+	coef        [jpegBlockSize]int32
+}
+
+// decodeJPEGReduced decodes a JPEG stream at 1/scale of its width and
+// height rounded up, scale 2, 4 or 8, into the image types image/jpeg
+// returns; errJPEGLayout reports streams it does not handle
+// Each block keeps the low frequencies that fit the smaller grid, so the
+// result matches averaging the full decode while skipping most of the
+// inverse transform and color work
+func decodeJPEGReduced(data []byte, scale int) (image.Image, error) {
+	// Assertion 1: Validate the scale
+	if scale != 2 && scale != 4 && scale != MaxJPEGReduction {
+		return nil, fmt.Errorf("%w: reduction %d, want 2, 4 or 8", ErrDecode, scale)
+	}
+
+	// Assertion 2: Validate the start of image marker
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, fmt.Errorf("%w: missing JPEG start of image", ErrDecode)
+	}
+
+	d := &reducedDecoder{data: data, pos: 2, size: jpegBlockSide / scale}
+	d.cosines = reducedCosines(d.size)
+
+	// Every segment advances pos, which bounds the loop
+	for d.pos < len(data) {
+		marker, segment, err := d.nextSegment()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case marker == markerEOI:
+			return d.finish()
+		case marker == markerDQT:
+			err = d.readDQT(segment)
+		case marker == markerDHT:
+			err = d.readDHT(segment)
+		case marker == markerSOF0 || marker == markerSOF1 || marker == markerSOF2:
+			err = d.readSOF(segment, marker == markerSOF2)
+		case marker == markerDRI:
+			if len(segment) < 2 {
+				return nil, fmt.Errorf("%w: short restart interval", ErrDecode)
+			}
+			d.restart = int(binary.BigEndian.Uint16(segment))
+		case marker == markerSOS:
+			err = d.readSOS(segment)
+		case marker == markerAPP0:
+			d.jfif = len(segment) >= 5 && string(segment[:5]) == "JFIF\x00"
+		case marker == markerAPP14:
+			if len(segment) >= 12 && string(segment[:5]) == "Adobe" {
+				d.adobe, d.transform = true, segment[11]
+			}
+		case marker >= 0xC3 && marker <= 0xCF:
+			// Lossless, hierarchical and arithmetic coded frames
+			return nil, errJPEGLayout
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: missing JPEG end of image", ErrDecode)
+}
+
+// reducedCosines returns the inverse DCT basis for n samples per block
+// side from the lowest n frequencies, indexed by x*n+u; the scaling makes
+// one sample the mean of the block
+func reducedCosines(n int) []float32 {
+	c := make([]float32, n*n)
+	for x := 0; x < n; x++ {
+		for u := 0; u < n; u++ {
+			scale := 0.5
+			if u == 0 {
+				scale = math.Sqrt(0.125)
+			}
+
+			c[x*n+u] = float32(scale * math.Cos(float64(2*x+1)*float64(u)*math.Pi/float64(2*n)))
+		}
+	}
+
+	return c
+}
+
+// nextSegment reads the marker at pos and the payload of markers that
+// have one, leaving pos after the segment
+func (d *reducedDecoder) nextSegment() (byte, []byte, error) {
+	// Assertion 1: A marker follows, fill bytes may pad it
+	if d.pos+1 >= len(d.data) || d.data[d.pos] != 0xFF {
+		return 0, nil, fmt.Errorf("%w: JPEG marker expected at offset %d", ErrDecode, d.pos)
+	}
+
+	for d.pos+1 < len(d.data) && d.data[d.pos+1] == 0xFF {
+		d.pos++
+	}
+
+	if d.pos+1 >= len(d.data) {
+		return 0, nil, fmt.Errorf("%w: truncated JPEG marker", ErrDecode)
+	}
+
+	marker := d.data[d.pos+1]
+	d.pos += 2
+
+	// Markers without a payload
+	if marker == markerEOI || marker == 0x01 || marker >= markerRST0 && marker <= markerRST7 {
+		return marker, nil, nil
+	}
+
+	// Assertion 2: The segment length covers itself and fits the data
+	if d.pos+2 > len(d.data) {
+		return 0, nil, fmt.Errorf("%w: truncated JPEG segment", ErrDecode)
+	}
+
+	length := int(binary.BigEndian.Uint16(d.data[d.pos:]))
+	if length < 2 || d.pos+length > len(d.data) {
+		return 0, nil, fmt.Errorf("%w: JPEG segment length %d out of range", ErrDecode, length)
+	}
+
+	segment := d.data[d.pos+2 : d.pos+length]
+	d.pos += length
+	return marker, segment, nil
+}
+
+// readDQT stores the quantization tables of a DQT segment in natural order
+func (d *reducedDecoder) readDQT(segment []byte) error {
+	// Each table consumes at least 65 bytes, which bounds the loop
+	for len(segment) > 0 {
+		precision, id := segment[0]>>4, int(segment[0]&0x0F)
+		size := 1 + jpegBlockSize*(1+int(precision))
+
+		// Assertion 1: Validate the table header
+		if precision > 1 || id > 3 || len(segment) < size {
+			return fmt.Errorf("%w: invalid quantization table", ErrDecode)
+		}
+
+		for i := 0; i < jpegBlockSize; i++ {
+			q := int(segment[1+i])
+			if precision == 1 {
+				q = int(binary.BigEndian.Uint16(segment[1+2*i:]))
+			}
+
+			d.quant[id][jpegUnzig[i]] = float32(q)
+		}
+
+		segment = segment[size:]
+	}
+
+	return nil
+}
+
+// readDHT builds the Huffman decoders of a DHT segment
+func (d *reducedDecoder) readDHT(segment []byte) error {
+	// Each table consumes at least 17 bytes, which bounds the loop
+	for len(segment) > 0 {
+		class, id := segment[0]>>4, int(segment[0]&0x0F)
+
+		// Assertion 1: Validate the table header
+		if class > 1 || id > 3 || len(segment) < 17 {
+			return fmt.Errorf("%w: invalid Huffman table", ErrDecode)
+		}
+
+		var counts [16]int
+		total := 0
+		for i := range counts {
+			counts[i] = int(segment[1+i])
+			total += counts[i]
+		}
+
+		if total > 256 || len(segment) < 17+total {
+			return fmt.Errorf("%w: invalid Huffman table", ErrDecode)
+		}
+
+		table, err := buildHuffDecoder(counts, segment[17:17+total])
+		if err != nil {
+			return err
+		}
+
+		if class == 0 {
+			d.dcTables[id] = table
+		} else {
+			d.acTables[id] = table
+		}
+
+		segment = segment[17+total:]
+	}
+
+	return nil
+}
+
+// buildHuffDecoder assigns the canonical codes of section C of the JPEG
+// standard to symbols, counts[l-1] of them l bits long
+func buildHuffDecoder(counts [16]int, symbols []byte) (*jpegHuffDecoder, error) {
+	t := &jpegHuffDecoder{}
+	copy(t.symbols[:], symbols)
+
+	code, k := 0, 0
+	for l := 1; l <= 16; l++ {
+		t.offset[l] = int32(k - code)
+		t.maxCode[l] = -1
+
+		// Assertion 1: The codes of this length fit in l bits
+		if code+counts[l-1] > 1<<l {
+			return nil, fmt.Errorf("%w: oversubscribed Huffman table", ErrDecode)
+		}
+
+		for i := 0; i < counts[l-1]; i++ {
+			if l <= jpegLookupBits {
+				shift := jpegLookupBits - l
+				for j := code << shift; j < (code+1)<<shift; j++ {
+					t.lookup[j] = uint16(l<<8) | uint16(symbols[k])
+				}
+			}
+
+			t.maxCode[l] = int32(code)
+			code++
+			k++
+		}
+
+		code <<= 1
+	}
+
+	return t, nil
+}
+
+// readSOF lays out the frame and allocates the planes at the reduced size
+func (d *reducedDecoder) readSOF(segment []byte, progressive bool) error {
+	// Assertion 1: One frame of 8-bit samples
+	if d.frame {
+		return fmt.Errorf("%w: more than one JPEG frame", ErrDecode)
+	}
+
+	if len(segment) < 6 {
+		return fmt.Errorf("%w: short JPEG frame header", ErrDecode)
+	}
+
+	if segment[0] != 8 {
+		return errJPEGLayout
+	}
+
+	d.height = int(binary.BigEndian.Uint16(segment[1:]))
+	d.width = int(binary.BigEndian.Uint16(segment[3:]))
+	count := int(segment[5])
+
+	// Assertion 2: Gray or three component color with a known height
+	if count != 1 && count != 3 || d.height == 0 {
+		return errJPEGLayout
+	}
+
+	if d.width == 0 || len(segment) < 6+3*count {
+		return fmt.Errorf("%w: invalid JPEG frame header", ErrDecode)
+	}
+
+	d.frame, d.progressive = true, progressive
+	d.comps = make([]reducedComponent, count)
+	d.hmax, d.vmax = 1, 1
+
+	for i := range d.comps {
+		c := &d.comps[i]
+		spec := segment[6+3*i:]
+		c.id, c.h, c.v, c.quant = spec[0], int(spec[1]>>4), int(spec[1]&0x0F), int(spec[2])
+
+		if c.h < 1 || c.h > 4 || c.v < 1 || c.v > 4 || c.quant > 3 {
+			return fmt.Errorf("%w: invalid JPEG component", ErrDecode)
+		}
+
+		// A single component is never interleaved, whatever its factors
+		if count == 1 {
+			c.h, c.v = 1, 1
+		}
+
+		d.hmax, d.vmax = max(d.hmax, c.h), max(d.vmax, c.v)
+	}
+
+	d.mcusX = (d.width + 8*d.hmax - 1) / (8 * d.hmax)
+	d.mcusY = (d.height + 8*d.vmax - 1) / (8 * d.vmax)
+
+	for i := range d.comps {
+		c := &d.comps[i]
+		c.blocksX, c.blocksY = d.mcusX*c.h, d.mcusY*c.v
+		c.codedX = (d.width*c.h + 8*d.hmax - 1) / (8 * d.hmax)
+		c.codedY = (d.height*c.v + 8*d.vmax - 1) / (8 * d.vmax)
+
+		if progressive {
+			c.coeffs = make([][jpegBlockSize]int16, c.blocksX*c.blocksY)
+		}
+	}
+
+	return d.allocatePlanes()
+}
+
+// allocatePlanes creates the image the frame decodes into, covering whole
+// MCUs, and points every component at its plane
+func (d *reducedDecoder) allocatePlanes() error {
+	n := d.size
+	rect := image.Rect(0, 0, n*d.hmax*d.mcusX, n*d.vmax*d.mcusY)
+
+	if len(d.comps) == 1 {
+		gray := image.NewGray(rect)
+		d.comps[0].plane, d.comps[0].stride = gray.Pix, gray.Stride
+		return nil
+	}
+
+	ratio, ok := reducedRatio(d.comps)
+	if !ok {
+		return errJPEGLayout
+	}
+
+	// Files without a JFIF marker may hold RGB, as image/jpeg decides
+	if !d.jfif && (d.adobe && d.transform == 0 || string([]byte{d.comps[0].id, d.comps[1].id, d.comps[2].id}) == "RGB") {
+		return errJPEGLayout
+	}
+
+	ycc := image.NewYCbCr(rect, ratio)
+	d.comps[0].plane, d.comps[0].stride = ycc.Y, ycc.YStride
+	d.comps[1].plane, d.comps[1].stride = ycc.Cb, ycc.CStride
+	d.comps[2].plane, d.comps[2].stride = ycc.Cr, ycc.CStride
+	return nil
+}
+
+// reducedRatio returns the image.YCbCr subsampling of the components,
+// false for layouts it cannot express
+func reducedRatio(comps []reducedComponent) (image.YCbCrSubsampleRatio, bool) {
+	y, cb, cr := comps[0], comps[1], comps[2]
+	if cb.h != cr.h || cb.v != cr.v {
+		return 0, false
+	}
+
+	if y.h == cb.h && y.v == cb.v {
+		return image.YCbCrSubsampleRatio444, true
+	}
+
+	if cb.h != 1 || cb.v != 1 {
+		return 0, false
+	}
+
+	switch [2]int{y.h, y.v} {
+	case [2]int{2, 1}:
+		return image.YCbCrSubsampleRatio422, true
+	case [2]int{2, 2}:
+		return image.YCbCrSubsampleRatio420, true
+	case [2]int{1, 2}:
+		return image.YCbCrSubsampleRatio440, true
+	case [2]int{4, 1}:
+		return image.YCbCrSubsampleRatio411, true
+	case [2]int{4, 2}:
+		return image.YCbCrSubsampleRatio410, true
+	default:
+		return 0, false
+	}
+}
+
+// readSOS parses a scan header and decodes the entropy coded data after it
+func (d *reducedDecoder) readSOS(segment []byte) error {
+	// Assertion 1: A frame precedes its scans
+	if !d.frame {
+		return fmt.Errorf("%w: JPEG scan before the frame header", ErrDecode)
+	}
+
+	if len(segment) < 1 || len(segment) < 4+2*int(segment[0]) {
+		return fmt.Errorf("%w: short JPEG scan header", ErrDecode)
+	}
+
+	count := int(segment[0])
+	if count < 1 || count > len(d.comps) {
+		return fmt.Errorf("%w: invalid JPEG scan component count %d", ErrDecode, count)
+	}
+
+	scan := reducedScan{components: make([]int, count)}
+	for i := 0; i < count; i++ {
+		id, tables := segment[1+2*i], segment[2+2*i]
+
+		index := -1
+		for j := range d.comps {
+			if d.comps[j].id == id {
+				index = j
+			}
+		}
+
+		// Assertion 2: Scans name frame components and defined tables
+		if index < 0 {
+			return fmt.Errorf("%w: JPEG scan names unknown component %d", ErrDecode, id)
+		}
+
+		c := &d.comps[index]
+		c.dc, c.ac = int(tables>>4), int(tables&0x0F)
+		if c.dc > 3 || c.ac > 3 {
+			return fmt.Errorf("%w: invalid JPEG scan tables", ErrDecode)
+		}
+
+		scan.components[i] = index
+	}
+
+	params := segment[1+2*count:]
+	scan.start, scan.end = int(params[0]), int(params[1])
+	scan.high, scan.low = int(params[2]>>4), int(params[2]&0x0F)
+
+	if !d.progressive {
+		scan.start, scan.end, scan.high, scan.low = 0, jpegBlockSize-1, 0, 0
+	}
+
+	// Assertion 3: Validate the progression of the scan
+	if scan.start > scan.end || scan.end >= jpegBlockSize || scan.low > 13 || scan.start == 0 && scan.end != 0 && d.progressive || scan.start > 0 && count != 1 {
+		return fmt.Errorf("%w: invalid JPEG spectral selection", ErrDecode)
+	}
+
+	for _, index := range scan.components {
+		c := d.comps[index]
+		needsDC := scan.start == 0 && scan.high == 0
+		needsAC := scan.start > 0 || !d.progressive
+		if needsDC && d.dcTables[c.dc] == nil || needsAC && d.acTables[c.ac] == nil {
+			return fmt.Errorf("%w: JPEG scan uses an undefined Huffman table", ErrDecode)
+		}
+	}
+
+	if err := d.decodeScan(scan); err != nil {
+		return err
+	}
+
+	return d.skipToMarker()
+}
+
+// decodeScan decodes the MCUs of one scan, a single component scan going
+// block by block over the blocks the component codes
+func (d *reducedDecoder) decodeScan(scan reducedScan) error {
+	d.resetBits()
+	for i := range d.comps {
+		d.comps[i].pred = 0
+	}
+	d.eobRun = 0
+
+	single := len(scan.components) == 1
+	mcusX, mcusY := d.mcusX, d.mcusY
+	if single {
+		c := &d.comps[scan.components[0]]
+		mcusX, mcusY = c.codedX, c.codedY
+	}
+
+	total := mcusX * mcusY
+	for m := 0; m < total; m++ {
+		if d.restart > 0 && m > 0 && m%d.restart == 0 {
+			if err := d.nextRestart(); err != nil {
+				return err
+			}
+		}
+
+		mx, my := m%mcusX, m/mcusX
+		for _, index := range scan.components {
+			c := &d.comps[index]
+			if single {
+				d.decodeBlock(c, mx, my, scan)
+				continue
+			}
+
+			for j := 0; j < c.v; j++ {
+				for i := 0; i < c.h; i++ {
+					d.decodeBlock(c, mx*c.h+i, my*c.v+j, scan)
+				}
+			}
+		}
+
+		// Assertion 1: Stop at the first corrupt code
+		if d.err != nil {
+			return d.err
+		}
+	}
+
+	// Assertion 2: The scan ended inside the data
+	if d.short {
+		return fmt.Errorf("%w: truncated JPEG scan", ErrDecode)
+	}
+
+	return nil
+}
+
+// decodeBlock decodes block (bx, by) of c; baseline blocks are inverted
+// at once, progressive ones accumulate until the last scan
+func (d *reducedDecoder) decodeBlock(c *reducedComponent, bx, by int, scan reducedScan) {
+	if !d.progressive {
+		d.baselineBlock(c)
+		d.inverse(&d.coef, &d.quant[c.quant], c.plane[by*d.size*c.stride+bx*d.size:], c.stride)
+		return
+	}
+
+	b := &c.coeffs[by*c.blocksX+bx]
+	switch {
+	case scan.start == 0 && scan.high == 0:
+		t := d.huff(d.dcTables[c.dc])
+		c.pred += d.receive(t)
+		b[0] = int16(c.pred << scan.low)
+	case scan.start == 0:
+		if d.bit() {
+			b[0] |= 1 << scan.low
+		}
+	case scan.high == 0:
+		d.firstAC(c, b, scan)
+	default:
+		d.refineAC(c, b, scan)
+	}
+}
+
+// baselineBlock decodes the next block into coef, keeping only the
+// frequencies the reduced inverse reads
+func (d *reducedDecoder) baselineBlock(c *reducedComponent) {
+	n := d.size
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			d.coef[v*jpegBlockSide+u] = 0
+		}
+	}
+
+	t := d.huff(d.dcTables[c.dc])
+	c.pred += d.receive(t)
+	d.coef[0] = c.pred
+
+	ac := d.acTables[c.ac]
+	for k := 1; k < jpegBlockSize; k++ {
+		rs := d.huff(ac)
+		run, size := int(rs>>4), rs&0x0F
+		if size == 0 {
+			if run != 15 {
+				return
+			}
+
+			k += 15
+			continue
+		}
+
+		k += run
+		if k >= jpegBlockSize {
+			d.fail("JPEG block has too many coefficients")
+			return
+		}
+
+		value := d.receive(size)
+		if z := jpegUnzig[k]; z%jpegBlockSide < n && z/jpegBlockSide < n {
+			d.coef[z] = value
+		}
+	}
+}
+
+// firstAC decodes the first pass over a spectral band of a progressive
+// block, section G.1.2.2 of the JPEG standard
+func (d *reducedDecoder) firstAC(c *reducedComponent, b *[jpegBlockSize]int16, scan reducedScan) {
+	if d.eobRun > 0 {
+		d.eobRun--
+		return
+	}
+
+	ac := d.acTables[c.ac]
+	for k := scan.start; k <= scan.end; k++ {
+		rs := d.huff(ac)
+		run, size := int(rs>>4), rs&0x0F
+		if size == 0 {
+			if run != 15 {
+				d.eobRun = 1<<run - 1
+				if run > 0 {
+					d.eobRun += int(d.bits(uint(run)))
+				}
+				return
+			}
+
+			k += 15
+			continue
+		}
+
+		k += run
+		if k > scan.end {
+			d.fail("JPEG band has too many coefficients")
+			return
+		}
+
+		b[jpegUnzig[k]] = int16(d.receive(size) << scan.low)
+	}
+}
+
+// refineAC adds one bit of precision to a spectral band, section G.1.2.3
+// of the JPEG standard: new coefficients are placed among the zero ones
+// and every nonzero one passed gets a correction bit
+func (d *reducedDecoder) refineAC(c *reducedComponent, b *[jpegBlockSize]int16, scan reducedScan) {
+	delta := int16(1) << scan.low
+	k := scan.start
+
+	if d.eobRun == 0 {
+		ac := d.acTables[c.ac]
+		for ; k <= scan.end; k++ {
+			rs := d.huff(ac)
+			zeros, size := int(rs>>4), rs&0x0F
+
+			var value int16
+			switch size {
+			case 0:
+				if zeros != 15 {
+					d.eobRun = 1 << zeros
+					if zeros > 0 {
+						d.eobRun += int(d.bits(uint(zeros)))
+					}
+				}
+			case 1:
+				value = delta
+				if !d.bit() {
+					value = -delta
+				}
+			default:
+				d.fail("unexpected JPEG refinement code")
+				return
+			}
+
+			if size == 0 && zeros != 15 {
+				break
+			}
+
+			k = d.refineNonZero(b, k, scan.end, zeros, delta)
+			if k > scan.end {
+				d.fail("JPEG refinement has too many coefficients")
+				return
+			}
+
+			if value != 0 {
+				b[jpegUnzig[k]] = value
+			}
+		}
+	}
+
+	if d.eobRun > 0 {
+		d.eobRun--
+		d.refineNonZero(b, k, scan.end, -1, delta)
+	}
+}
+
+// refineNonZero corrects the nonzero coefficients from zig-zag position k
+// up to end, stopping at the zero one after skipping zeros of them; a
+// negative zeros runs to end
+func (d *reducedDecoder) refineNonZero(b *[jpegBlockSize]int16, k, end, zeros int, delta int16) int {
+	for ; k <= end; k++ {
+		z := jpegUnzig[k]
+		if b[z] == 0 {
+			if zeros == 0 {
+				break
+			}
+
+			zeros--
+			continue
+		}
+
+		if !d.bit() {
+			continue
+		}
+
+		if b[z] >= 0 {
+			b[z] += delta
+		} else {
+			b[z] -= delta
+		}
+	}
+
+	return k
+}
+
+// finish inverts the coefficients of a progressive frame and returns the
+// image cropped to the reduced size
+func (d *reducedDecoder) finish() (image.Image, error) {
+	// Assertion 1: A frame was decoded
+	if !d.frame {
+		return nil, fmt.Errorf("%w: JPEG has no frame", ErrDecode)
+	}
+
+	if d.progressive {
+		for i := range d.comps {
+			c := &d.comps[i]
+			for by := 0; by < c.codedY; by++ {
+				for bx := 0; bx < c.codedX; bx++ {
+					b := &c.coeffs[by*c.blocksX+bx]
+					for k := range d.coef {
+						d.coef[k] = int32(b[k])
+					}
+
+					d.inverse(&d.coef, &d.quant[c.quant], c.plane[by*d.size*c.stride+bx*d.size:], c.stride)
+				}
+			}
+		}
+	}
+
+	scale := jpegBlockSide / d.size
+	rect := image.Rect(0, 0, (d.width+scale-1)/scale, (d.height+scale-1)/scale)
+
+	c := d.comps[0]
+	if len(d.comps) == 1 {
+		gray := &image.Gray{Pix: c.plane, Stride: c.stride, Rect: image.Rect(0, 0, c.stride, len(c.plane)/c.stride)}
+		return gray.SubImage(rect), nil
+	}
+
+	ratio, _ := reducedRatio(d.comps)
+	ycc := &image.YCbCr{
+		Y:              c.plane,
+		Cb:             d.comps[1].plane,
+		Cr:             d.comps[2].plane,
+		YStride:        c.stride,
+		CStride:        d.comps[1].stride,
+		SubsampleRatio: ratio,
+		Rect:           image.Rect(0, 0, c.stride, len(c.plane)/c.stride),
+	}
+	return ycc.SubImage(rect), nil
+}
+
+// inverse writes the size x size samples of a block from its lowest
+// frequencies, dequantized by q, into dst rows stride bytes apart
+func (d *reducedDecoder) inverse(coef *[jpegBlockSize]int32, q *[jpegBlockSize]float32, dst []byte, stride int) {
+	n := d.size
+	if n == 1 {
+		dst[0] = clampSample(float32(coef[0]) * q[0] / 8)
+		return
+	}
+
+	// Rows first: tmp holds each frequency row v inverted to n columns
+	var tmp [jpegBlockSize]float32
+	for v := 0; v < n; v++ {
+		row := v * jpegBlockSide
+		for x := 0; x < n; x++ {
+			var sum float32
+			for u := 0; u < n; u++ {
+				sum += d.cosines[x*n+u] * float32(coef[row+u]) * q[row+u]
+			}
+			tmp[v*n+x] = sum
+		}
+	}
+
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sum float32
+			for v := 0; v < n; v++ {
+				sum += d.cosines[y*n+v] * tmp[v*n+x]
+			}
+			dst[y*stride+x] = clampSample(sum)
+		}
+	}
+}
+
+// clampSample level shifts an inverse DCT output and rounds it to a byte
+func clampSample(v float32) byte {
+	v += 128.5
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return byte(v)
+	}
+}
+
+// fill tops the bit accumulator up to at least 57 bits, undoing byte
+// stuffing; past a marker or the end of the data it feeds zeros
+func (d *reducedDecoder) fill() {
+	for d.nbits <= 56 {
+		var c byte
+		switch {
+		case d.stop:
+		case d.pos >= len(d.data):
+			d.short = true
+		case d.data[d.pos] != 0xFF:
+			c = d.data[d.pos]
+			d.pos++
+		case d.pos+1 < len(d.data) && d.data[d.pos+1] == 0x00:
+			c = 0xFF
+			d.pos += 2
+		default:
+			d.stop = true
+		}
+
+		d.acc |= uint64(c) << (56 - d.nbits)
+		d.nbits += 8
+	}
+}
+
+// bits reads n raw bits, n at most 16
+func (d *reducedDecoder) bits(n uint) uint32 {
+	if d.nbits < n {
+		d.fill()
+	}
+
+	v := uint32(d.acc >> (64 - n))
+	d.acc <<= n
+	d.nbits -= n
+	return v
+}
+
+// bit reads one bit
+func (d *reducedDecoder) bit() bool {
+	return d.bits(1) == 1
+}
+
+// receive reads an s-bit coefficient and extends its sign, section F.2.2.1
+// of the JPEG standard
+func (d *reducedDecoder) receive(s byte) int32 {
+	// Assertion 1: Coefficients of 8-bit samples fit 16 bits
+	if s == 0 || s > 16 {
+		if s > 16 {
+			d.fail("JPEG coefficient too long")
+		}
+		return 0
+	}
+
+	v := int32(d.bits(uint(s)))
+	if v < 1<<(s-1) {
+		v -= 1<<s - 1
+	}
+
+	return v
+}
+
+// huff decodes one symbol with t
+func (d *reducedDecoder) huff(t *jpegHuffDecoder) byte {
+	if d.nbits < 16 {
+		d.fill()
+	}
+
+	if e := t.lookup[d.acc>>(64-jpegLookupBits)]; e != 0 {
+		n := uint(e >> 8)
+		d.acc <<= n
+		d.nbits -= n
+		return byte(e)
+	}
+
+	for l := jpegLookupBits + 1; l <= 16; l++ {
+		code := int32(d.acc >> (64 - l))
+		if code <= t.maxCode[l] {
+			d.acc <<= uint(l)
+			d.nbits -= uint(l)
+			return t.symbols[code+t.offset[l]]
+		}
+	}
+
+	d.fail("invalid JPEG Huffman code")
+	return 0
+}
+
+// fail records the first decoding error of a scan
+func (d *reducedDecoder) fail(message string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrDecode, message)
+	}
+}
+
+// resetBits drops buffered bits, as at the start of a scan or interval
+func (d *reducedDecoder) resetBits() {
+	d.acc, d.nbits, d.stop = 0, 0, false
+}
+
+// skipToMarker moves pos to the next marker, past the padding that ends
+// the entropy coded data
+func (d *reducedDecoder) skipToMarker() error {
+	d.resetBits()
+
+	// Stuffed bytes and fill bytes are not markers
+	for d.pos+1 < len(d.data) && (d.data[d.pos] != 0xFF || d.data[d.pos+1] == 0x00 || d.data[d.pos+1] == 0xFF) {
+		d.pos++
+	}
+
+	if d.pos+1 >= len(d.data) {
+		return fmt.Errorf("%w: truncated JPEG scan", ErrDecode)
+	}
+
+	return nil
+}
+
+// nextRestart consumes the restart marker between two intervals and
+// resets the predictors it ends
+func (d *reducedDecoder) nextRestart() error {
+	if err := d.skipToMarker(); err != nil {
+		return err
+	}
+
+	// Assertion 1: The interval ends with a restart marker
+	if marker := d.data[d.pos+1]; marker < markerRST0 || marker > markerRST7 {
+		return fmt.Errorf("%w: JPEG restart marker expected", ErrDecode)
+	}
+
+	d.pos += 2
+	for i := range d.comps {
+		d.comps[i].pred = 0
+	}
+	d.eobRun = 0
+	return nil
+}
@@ -0,0 +1,620 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+const (
+	// tagXMP and tagIPTC hold the XMP packet and IPTC records of a TIFF
+	tagXMP  = 700
+	tagIPTC = 33723
+
+	// photoshopIPTC is the image resource that wraps IPTC records
+	photoshopIPTC = 0x0404
+
+	// webpXMPFlag marks a VP8X file as carrying an XMP chunk
+	webpXMPFlag = 1 << 2
+
+	// xmpMaxSize caps a compressed PNG XMP packet once inflated
+	xmpMaxSize = 16 << 20
+)
+
+var (
+	// xmpHeader prefixes the XMP packet in JPEG APP1 segments
+	xmpHeader = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	// photoshopHeader prefixes the image resources in JPEG APP13 segments
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+	// xmpKeyword names the PNG iTXt chunk holding the XMP packet
+	xmpKeyword = []byte("XML:com.adobe.xmp\x00")
+)
+
+// Metadata holds the metadata blocks copied from an input to an output
+type Metadata struct {
+	EXIF []byte // TIFF structure that follows the "Exif" header
+	XMP  []byte // XMP packet, the RDF/XML text
+	IPTC []byte // IPTC-IIM records such as caption, keywords and credit
+}
+
+// metadataFormats lists the output extensions that can carry each block
+var metadataFormats = map[string][]string{
+	"EXIF": {".jpg", ".jpeg", ".png", ".webp"},
+	"XMP":  {".jpg", ".jpeg", ".png", ".webp", ".tiff", ".tif"},
+	"IPTC": {".jpg", ".jpeg", ".tiff", ".tif"},
+}
+
+// ReadMetadata returns the EXIF, XMP and IPTC blocks of a JPEG, PNG, WebP
+// or TIFF file; missing blocks are nil
+// EXIF of TIFF based files lives in the image's own directories and is
+// not returned
+func ReadMetadata(path string) (Metadata, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer file.Close()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	return metadataOf(data, ext), nil
+}
+
+// metadataOf copies the blocks ReadMetadata returns out of data, a file
+// in the format named by ext
+func metadataOf(data []byte, ext string) Metadata {
+	var meta Metadata
+
+	// Assertion 1: Only a readable TIFF structure is worth copying
+	if exif := embeddedEXIF(data, ext); exif != nil {
+		if _, _, err := openTIFF(exif); err == nil {
+			meta.EXIF = bytes.Clone(exif)
+		}
+	}
+
+	switch ext {
+	case ".jpg", ".jpeg":
+		meta.XMP = bytes.TrimPrefix(jpegSegment(data, 0xE1, xmpHeader), xmpHeader)
+		meta.IPTC = photoshopResource(bytes.TrimPrefix(jpegSegment(data, 0xED, photoshopHeader), photoshopHeader), photoshopIPTC)
+	case ".png":
+		meta.XMP = pngXMP(data)
+	case ".webp":
+		meta.XMP = webpChunk(data, "XMP ")
+	case ".tiff", ".tif":
+		meta.XMP, meta.IPTC = tiffXMPAndIPTC(data)
+	}
+
+	meta.XMP = bytes.Clone(meta.XMP)
+	meta.IPTC = bytes.Clone(meta.IPTC)
+	return meta
+}
+
+// Empty reports whether m holds no block
+func (m Metadata) Empty() bool {
+	return len(m.EXIF) == 0 && len(m.XMP) == 0 && len(m.IPTC) == 0
+}
+
+// Supported splits m into the blocks a format given by its extension can
+// carry and the names of those it cannot
+func (m Metadata) Supported(ext string) (Metadata, []string) {
+	kept := m
+	var dropped []string
+
+	blocks := []struct {
+		name  string
+		block *[]byte
+	}{{"EXIF", &kept.EXIF}, {"XMP", &kept.XMP}, {"IPTC", &kept.IPTC}}
+
+	for _, b := range blocks {
+		if len(*b.block) == 0 || slices.Contains(metadataFormats[b.name], ext) {
+			continue
+		}
+
+		*b.block = nil
+		dropped = append(dropped, b.name)
+	}
+
+	return kept, dropped
+}
+
+// Upright returns a copy of m whose EXIF and XMP orientation say the
+// pixels are already upright
+func (m Metadata) Upright() Metadata {
+	if m.EXIF != nil {
+		m.EXIF = ResetOrientation(m.EXIF)
+	}
+
+	m.XMP = xmpSet(m.XMP, "tiff:Orientation", "1")
+	return m
+}
+
+// WithoutGPS returns a copy of m without the GPS location, dropping the
+// GPS directory of the EXIF block and the exif:GPS properties of the XMP
+// packet
+func (m Metadata) WithoutGPS() Metadata {
+	if m.EXIF != nil {
+		m.EXIF = StripGPS(m.EXIF)
+	}
+
+	if m.XMP != nil {
+		// GPS properties of the EXIF schema
+		m.XMP = xmpRemove(m.XMP, `exif:GPS\w*`)
+	}
+
+	return m
+}
+
+// forImage returns a copy of m describing img: recorded pixel dimensions,
+// when present, hold its size and an EXIF thumbnail is made from it
+func (m Metadata) forImage(img image.Image) (Metadata, error) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	if m.EXIF != nil {
+		exif, err := exifWithThumbnail(exifForSize(m.EXIF, width, height), img)
+		if err != nil {
+			return Metadata{}, err
+		}
+		m.EXIF = exif
+	}
+
+	w, h := strconv.Itoa(width), strconv.Itoa(height)
+	m.XMP = xmpSet(m.XMP, "exif:PixelXDimension", w)
+	m.XMP = xmpSet(m.XMP, "exif:PixelYDimension", h)
+	m.XMP = xmpSet(m.XMP, "tiff:ImageWidth", w)
+	m.XMP = xmpSet(m.XMP, "tiff:ImageLength", h)
+	return m, nil
+}
+
+// check reports the first block of m that the format cannot carry
+func (m Metadata) check(ext string) error {
+	// Assertion 1: Every block needs a home in the format
+	if _, dropped := m.Supported(ext); len(dropped) > 0 {
+		return fmt.Errorf("%w: cannot record %s in %s", ErrUnsupportedFormat, dropped[0], ext)
+	}
+
+	// Assertion 2: The EXIF block must be a TIFF structure
+	if m.EXIF != nil {
+		if _, _, err := openTIFF(m.EXIF); err != nil {
+			return fmt.Errorf("%w: EXIF block is not a TIFF structure", ErrEncode)
+		}
+	}
+
+	return nil
+}
+
+// withMetadata embeds meta in encoded JPEG, PNG or WebP data; TIFF output
+// already carries it from encodeTIFF
+func withMetadata(ext string, data []byte, meta Metadata) ([]byte, error) {
+	switch ext {
+	case ".jpg", ".jpeg":
+		return jpegWithMetadata(data, meta)
+	case ".png":
+		return pngWithMetadata(data, meta)
+	case ".webp":
+		return webpWithMetadata(data, meta)
+	default:
+		return data, nil
+	}
+}
+
+// jpegSegment returns the payload of the first marker segment before the
+// image data that starts with prefix
+func jpegSegment(data []byte, marker byte, prefix []byte) []byte {
+	pos := 2
+
+	for pos+4 <= len(data) {
+		// Assertion 1: Segments start with a marker
+		if data[pos] != 0xFF {
+			return nil
+		}
+
+		switch data[pos+1] {
+		case 0xFF:
+			// Fill byte before a marker
+			pos++
+			continue
+		case 0xDA, 0xD9:
+			// Start of scan or end of image, metadata comes before
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil
+		}
+
+		segment := data[pos+4 : pos+2+length]
+		if data[pos+1] == marker && bytes.HasPrefix(segment, prefix) {
+			return segment
+		}
+
+		pos += 2 + length
+	}
+
+	return nil
+}
+
+// pngChunk returns the payload of the first chunk of kind whose payload
+// starts with prefix
+func pngChunk(data []byte, kind string, prefix []byte) []byte {
+	pos := len(pngSignature)
+
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		id := string(data[pos+4 : pos+8])
+
+		// Assertion 1: The chunk and its CRC fit in the file
+		if length < 0 || pos+12+length > len(data) {
+			return nil
+		}
+
+		payload := data[pos+8 : pos+8+length]
+		switch {
+		case id == kind && bytes.HasPrefix(payload, prefix):
+			return payload
+		case id == "IEND":
+			return nil
+		}
+
+		pos += 12 + length
+	}
+
+	return nil
+}
+
+// webpChunk returns the payload of the first chunk with id of an extended
+// WebP file
+func webpChunk(data []byte, id string) []byte {
+	// Assertion 1: Validate the RIFF header
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil
+	}
+
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	chunks, err := readChunks(data[12:min(len(data), 8+size)])
+	if err != nil {
+		return nil
+	}
+
+	for _, chunk := range chunks {
+		if chunk.id == id {
+			return chunk.data
+		}
+	}
+
+	return nil
+}
+
+// pngXMP returns the text of the XMP iTXt chunk, inflating it when the
+// chunk is compressed
+func pngXMP(data []byte) []byte {
+	payload := bytes.TrimPrefix(pngChunk(data, "iTXt", xmpKeyword), xmpKeyword)
+
+	// Assertion 1: The compression fields precede the language and
+	// translated keyword, both NUL terminated
+	if len(payload) < 2 {
+		return nil
+	}
+
+	compressed := payload[0] == 1
+	rest := payload[2:]
+	for i := 0; i < 2; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return nil
+		}
+		rest = rest[end+1:]
+	}
+
+	if !compressed {
+		return rest
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+
+	text, err := io.ReadAll(io.LimitReader(reader, xmpMaxSize))
+	if err != nil {
+		return nil
+	}
+
+	return text
+}
+
+// photoshopResource returns the data of the image resource with id from a
+// sequence of 8BIM blocks
+func photoshopResource(data []byte, id uint16) []byte {
+	for len(data) >= 12 && string(data[:4]) == "8BIM" {
+		// The Pascal string name is padded to an even size with its length
+		nameSize := 1 + int(data[6])
+		nameSize += nameSize % 2
+
+		// Assertion 1: The header and data fit in the block
+		at := 6 + nameSize
+		if at+4 > len(data) {
+			return nil
+		}
+
+		size := int(binary.BigEndian.Uint32(data[at : at+4]))
+		at += 4
+		if size < 0 || at+size > len(data) {
+			return nil
+		}
+
+		if binary.BigEndian.Uint16(data[4:6]) == id {
+			return data[at : at+size]
+		}
+
+		data = data[at+size+size%2:]
+	}
+
+	return nil
+}
+
+// tiffXMPAndIPTC returns the XMP and IPTC fields of a TIFF file's first
+// directory
+func tiffXMPAndIPTC(data []byte) ([]byte, []byte) {
+	file, first, err := openTIFF(data)
+	if err != nil {
+		return nil, nil
+	}
+
+	entries, _, err := file.readIFD(first)
+	if err != nil {
+		return nil, nil
+	}
+
+	return entries[tagXMP].value, entries[tagIPTC].value
+}
+
+// tiffMetadataFields returns the IFD fields recording the XMP packet and
+// IPTC records of meta
+func tiffMetadataFields(meta Metadata) []tiffField {
+	var fields []tiffField
+
+	if len(meta.XMP) > 0 {
+		fields = append(fields, tiffField{tagXMP, tiffByte, tiffBytes(meta.XMP)})
+	}
+
+	if len(meta.IPTC) > 0 {
+		fields = append(fields, tiffField{tagIPTC, tiffUndefined, tiffBytes(meta.IPTC)})
+	}
+
+	return fields
+}
+
+// tiffBytes widens data to one field value per byte
+func tiffBytes(data []byte) []uint32 {
+	values := make([]uint32, len(data))
+	for i, b := range data {
+		values[i] = uint32(b)
+	}
+
+	return values
+}
+
+// jpegWithMetadata inserts APP1 EXIF and XMP segments and an APP13 IPTC
+// segment after SOI and any JFIF APP0
+func jpegWithMetadata(data []byte, meta Metadata) ([]byte, error) {
+	// Assertion 1: Validate the start of image marker
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("%w: missing JPEG SOI marker", ErrEncode)
+	}
+
+	var segments []byte
+	var err error
+
+	if meta.EXIF != nil {
+		if segments, err = appendJPEGSegment(segments, 0xE1, "EXIF", exifHeader, meta.EXIF); err != nil {
+			return nil, err
+		}
+	}
+
+	if meta.XMP != nil {
+		if segments, err = appendJPEGSegment(segments, 0xE1, "XMP", xmpHeader, meta.XMP); err != nil {
+			return nil, err
+		}
+	}
+
+	if meta.IPTC != nil {
+		resource := []byte("8BIM")
+		resource = binary.BigEndian.AppendUint16(resource, photoshopIPTC)
+		// Empty name, padded to an even size
+		resource = append(resource, 0, 0)
+		resource = binary.BigEndian.AppendUint32(resource, uint32(len(meta.IPTC)))
+		resource = append(resource, meta.IPTC...)
+		if len(meta.IPTC)%2 == 1 {
+			resource = append(resource, 0)
+		}
+
+		if segments, err = appendJPEGSegment(segments, 0xED, "IPTC", photoshopHeader, resource); err != nil {
+			return nil, err
+		}
+	}
+
+	at := 2
+	if data[2] == 0xFF && data[3] == 0xE0 && len(data) >= 6 {
+		at += 2 + int(binary.BigEndian.Uint16(data[4:6]))
+	}
+
+	out := make([]byte, 0, len(data)+len(segments))
+	out = append(out, data[:at]...)
+	out = append(out, segments...)
+	return append(out, data[at:]...), nil
+}
+
+// appendJPEGSegment appends a marker segment holding header and payload,
+// naming the block when it is too large for one segment
+func appendJPEGSegment(dst []byte, marker byte, name string, header, payload []byte) ([]byte, error) {
+	// Assertion 1: The payload must fit a single segment
+	if len(header)+len(payload) > jpegMaxSegment {
+		return nil, fmt.Errorf("%w: %s block of %d bytes does not fit a JPEG segment", ErrEncode, name, len(payload))
+	}
+
+	dst = append(dst, 0xFF, marker)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(2+len(header)+len(payload)))
+	dst = append(dst, header...)
+	return append(dst, payload...), nil
+}
+
+// pngWithMetadata inserts eXIf and XMP iTXt chunks right after IHDR
+func pngWithMetadata(data []byte, meta Metadata) ([]byte, error) {
+	var chunks []byte
+	if meta.EXIF != nil {
+		chunks = appendPNGChunk(chunks, "eXIf", meta.EXIF)
+	}
+
+	if meta.XMP != nil {
+		// Uncompressed, with empty language and translated keyword
+		text := append(bytes.Clone(xmpKeyword), 0, 0, 0, 0)
+		chunks = appendPNGChunk(chunks, "iTXt", append(text, meta.XMP...))
+	}
+
+	return pngInsert(data, chunks)
+}
+
+// pngInsert inserts encoded chunks right after IHDR
+func pngInsert(data, chunks []byte) ([]byte, error) {
+	const ihdrEnd = 8 + 4 + 4 + 13 + 4
+
+	// Assertion 1: Validate the signature and IHDR chunk
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("%w: missing PNG IHDR chunk", ErrEncode)
+	}
+
+	out := make([]byte, 0, len(data)+len(chunks))
+	out = append(out, data[:ihdrEnd]...)
+	out = append(out, chunks...)
+	return append(out, data[ihdrEnd:]...), nil
+}
+
+// appendPNGChunk appends a chunk with its length and CRC
+func appendPNGChunk(dst []byte, kind string, payload []byte) []byte {
+	start := len(dst) + 4
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = append(dst, kind...)
+	dst = append(dst, payload...)
+	return binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(dst[start:]))
+}
+
+// webpWithMetadata appends EXIF and XMP chunks, turning a simple lossless
+// file into an extended one whose VP8X header announces them
+func webpWithMetadata(data []byte, meta Metadata) ([]byte, error) {
+	// Assertion 1: Validate the RIFF header
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%w: missing WebP RIFF header", ErrEncode)
+	}
+
+	chunks, err := readChunks(data[12:])
+	if err != nil || len(chunks) == 0 {
+		return nil, fmt.Errorf("%w: unreadable WebP chunks", ErrEncode)
+	}
+
+	var flags byte
+	if meta.EXIF != nil {
+		flags |= webpEXIFFlag
+	}
+	if meta.XMP != nil {
+		flags |= webpXMPFlag
+	}
+
+	var body []byte
+	switch first := chunks[0]; {
+	case first.id == "VP8X" && len(first.data) >= 10:
+		first.data[0] |= flags
+	case first.id == "VP8L" && len(first.data) >= 5:
+		// The lossless header packs width-1, height-1 and an alpha bit
+		bits := binary.LittleEndian.Uint32(first.data[1:5])
+		if bits>>28&1 == 1 {
+			flags |= webpAlphaFlag
+		}
+
+		header := []byte{flags, 0, 0, 0}
+		header = appendUint24(header, int(bits&0x3FFF))
+		header = appendUint24(header, int(bits>>14&0x3FFF))
+		body = appendChunk(body, "VP8X", header)
+	default:
+		return nil, fmt.Errorf("%w: cannot add metadata to a %s WebP file", ErrEncode, first.id)
+	}
+
+	for _, chunk := range chunks {
+		body = appendChunk(body, chunk.id, chunk.data)
+	}
+
+	// Metadata chunks follow the image data, EXIF before XMP
+	if meta.EXIF != nil {
+		body = appendChunk(body, "EXIF", meta.EXIF)
+	}
+	if meta.XMP != nil {
+		body = appendChunk(body, "XMP ", meta.XMP)
+	}
+
+	return riffWebP(body), nil
+}
+
+// xmpSet replaces the value of a simple XMP property written as an
+// attribute or as an element, leaving packets without it unchanged
+func xmpSet(xmp []byte, name, value string) []byte {
+	if !bytes.Contains(xmp, []byte(name)) {
+		return xmp
+	}
+
+	quoted := regexp.QuoteMeta(name)
+	attribute := regexp.MustCompile(`(\b` + quoted + `\s*=\s*["'])[^"']*(["'])`)
+	element := regexp.MustCompile(`(<` + quoted + `>)[^<]*(</` + quoted + `>)`)
+
+	xmp = attribute.ReplaceAll(xmp, []byte("${1}"+value+"${2}"))
+	return element.ReplaceAll(xmp, []byte("${1}"+value+"${2}"))
+}
+
+// xmpRemove returns a copy of an XMP packet without the properties whose
+// names match the pattern, whether written as attributes or as elements
+func xmpRemove(xmp []byte, name string) []byte {
+	attribute := regexp.MustCompile(`\s+` + name + `\s*=\s*("[^"]*"|'[^']*')`)
+	element := regexp.MustCompile(`<(` + name + `)\b[^>]*?(/?)>`)
+
+	xmp = attribute.ReplaceAll(xmp, nil)
+
+	// Each pass removes one element, so the loop is bounded by their count
+	passes := len(element.FindAllIndex(xmp, -1))
+	for i := 0; i < passes; i++ {
+		loc := element.FindSubmatchIndex(xmp)
+		if loc == nil {
+			break
+		}
+
+		end := loc[1]
+		if loc[4] == loc[5] {
+			// Not self closing, remove through the matching end tag
+			closing := []byte("</" + string(xmp[loc[2]:loc[3]]) + ">")
+			at := bytes.Index(xmp[end:], closing)
+			if at < 0 {
+				break
+			}
+			end += at + len(closing)
+		}
+
+		xmp = append(xmp[:loc[0]:loc[0]], xmp[end:]...)
+	}
+
+	return xmp
+}
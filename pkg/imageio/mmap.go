@@ -0,0 +1,78 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// inputFile is an open input, read through the file or its memory mapping
+type inputFile interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+// mappedFile reads an input mapped into memory, so decoders that take
+// a ReaderAt such as TIFF copy strips straight from the page cache
+// instead of issuing a read per strip
+type mappedFile struct {
+	*bytes.Reader
+	data  []byte
+	unmap func() error
+}
+
+// Close releases the mapping; images decoded from it own their pixels
+func (m *mappedFile) Close() error {
+	return m.unmap()
+}
+
+// remaining returns the bytes not read yet, without copying them
+func (m *mappedFile) remaining() []byte {
+	return m.data[len(m.data)-m.Len():]
+}
+
+// openInput opens path for reading like openFile, mapping it into memory
+// when mapped is set
+func openInput(path string, mapped bool) (inputFile, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !mapped {
+		return file, nil
+	}
+	defer file.Close()
+
+	// Assertion 1: The size was checked by openFile, so it fits an int
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot stat file: %v", ErrFileOpen, err)
+	}
+
+	// The mapping outlives the descriptor
+	data, unmap, err := mapFile(file, int(info.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot map file: %v", ErrFileOpen, err)
+	}
+
+	return &mappedFile{Reader: bytes.NewReader(data), data: data, unmap: unmap}, nil
+}
+
+// readAll returns the rest of r like io.ReadAll, but hands out a mapped
+// input as is, so decoders working on a whole-file buffer need no copy;
+// the bytes must not be kept once the input is closed
+func readAll(r io.Reader) ([]byte, error) {
+	if m, ok := r.(*mappedFile); ok {
+		return m.remaining(), nil
+	}
+
+	return io.ReadAll(r)
+}
+
+// noUnmap is the release function of an input that maps nothing
+func noUnmap() error {
+	return nil
+}
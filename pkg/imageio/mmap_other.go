@@ -0,0 +1,21 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build !unix
+
+package imageio
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the whole file where memory mapping is not available, so
+// the mapped input behaves the same with one copy made up front
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, nil, err
+	}
+
+	return data, noUnmap, nil
+}
@@ -0,0 +1,27 @@
+// Open source image resizer coded by kasuraSH
+
+//go:build unix
+
+package imageio
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of file read-only
+// A file truncated while mapped faults on access, so only files that are
+// not being written should be mapped
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	// Assertion 1: Empty files cannot be mapped
+	if size == 0 {
+		return nil, noUnmap, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
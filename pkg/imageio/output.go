@@ -0,0 +1,82 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// outputFile is an image being written for path: a temporary file beside
+// it that commit renames over path, so an interrupted or failed write
+// never leaves a truncated image, or path itself when that is a device
+// or pipe such as /dev/stdout
+type outputFile struct {
+	*os.File
+	path string
+	temp bool
+}
+
+// createOutput starts writing path, creating its directory
+func createOutput(path string) (*outputFile, error) {
+	// Assertion 1: Remote objects are stored whole, not written in place
+	if IsRemote(path) {
+		return nil, fmt.Errorf("%w: %s is remote storage, which takes whole images rather than streamed ones", ErrFileCreate, path)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("%w: cannot create directory: %v", ErrFileCreate, err)
+	}
+
+	// Assertion 2: Only regular files can be replaced by a rename
+	if stat, err := os.Stat(path); err == nil && !stat.Mode().IsRegular() {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFileCreate, err)
+		}
+
+		return &outputFile{File: file, path: path}, nil
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	// CreateTemp keeps the file private, images are shared like os.Create
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	return &outputFile{File: file, path: path, temp: true}, nil
+}
+
+// commit closes the file and moves it to its path
+func (f *outputFile) commit() error {
+	err := f.File.Close()
+	if err == nil && f.temp {
+		err = os.Rename(f.File.Name(), f.path)
+	}
+
+	// Assertion 1: A file that could not be put in place is dropped
+	if err != nil {
+		if f.temp {
+			os.Remove(f.File.Name())
+		}
+
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	return nil
+}
+
+// abort closes the file and removes it, leaving path as it was
+func (f *outputFile) abort() {
+	f.File.Close()
+	if f.temp {
+		os.Remove(f.File.Name())
+	}
+}
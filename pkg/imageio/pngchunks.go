@@ -0,0 +1,90 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// pngCopiedChunks are the ancillary chunks ReadPNGChunks returns: text
+// annotations and the color space chunks that have to precede the image
+var pngCopiedChunks = []string{"tEXt", "zTXt", "iTXt", "gAMA", "cHRM", "sRGB"}
+
+// PNGChunk is an ancillary PNG chunk copied from a source file
+type PNGChunk struct {
+	Type string // Four letter chunk type such as "tEXt"
+	Data []byte // Payload without length and CRC
+}
+
+// ReadPNGChunks returns the text, gAMA, cHRM and sRGB chunks of a PNG
+// file in file order, nil for other formats
+// The XMP iTXt chunk is left to ReadMetadata
+func ReadPNGChunks(path string) ([]PNGChunk, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ext, err := detectFormat(file, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext != ".png" {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	return pngChunksOf(data), nil
+}
+
+// pngChunksOf copies the chunks ReadPNGChunks returns out of data, a PNG
+// file
+func pngChunksOf(data []byte) []PNGChunk {
+	var chunks []PNGChunk
+	pos := len(pngSignature)
+
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		kind := string(data[pos+4 : pos+8])
+
+		// Assertion 1: The chunk and its CRC fit in the file
+		if length < 0 || pos+12+length > len(data) || kind == "IEND" {
+			break
+		}
+
+		payload := data[pos+8 : pos+8+length]
+		xmp := kind == "iTXt" && bytes.HasPrefix(payload, xmpKeyword)
+		if slices.Contains(pngCopiedChunks, kind) && !xmp {
+			chunks = append(chunks, PNGChunk{Type: kind, Data: bytes.Clone(payload)})
+		}
+
+		pos += 12 + length
+	}
+
+	return chunks
+}
+
+// pngWithChunks inserts chunks right after IHDR, where the color space
+// chunks are required to come before PLTE and IDAT
+func pngWithChunks(data []byte, chunks []PNGChunk) ([]byte, error) {
+	var encoded []byte
+	for _, chunk := range chunks {
+		// Assertion 1: Only the ancillary chunks ReadPNGChunks returns
+		if !slices.Contains(pngCopiedChunks, chunk.Type) {
+			return nil, fmt.Errorf("%w: cannot copy PNG chunk %q", ErrEncode, chunk.Type)
+		}
+
+		encoded = appendPNGChunk(encoded, chunk.Type, chunk.Data)
+	}
+
+	return pngInsert(data, encoded)
+}
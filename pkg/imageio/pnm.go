@@ -0,0 +1,450 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+const (
+	// pnmMaxValue is the largest sample value a Netpbm file may declare
+	pnmMaxValue = 65535
+	// pnmMaxToken bounds the length of one header or ASCII raster token
+	pnmMaxToken = 16
+	// pnmMaxComment bounds the length of one header comment
+	pnmMaxComment = 4096
+)
+
+// pnmReader reads the whitespace separated tokens of a Netpbm stream
+type pnmReader struct {
+	r *bufio.Reader
+}
+
+// skipSpace skips whitespace and comments up to the next token
+func (p *pnmReader) skipSpace() error {
+	for {
+		b, err := p.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch b {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+		case '#':
+			// Assertion 1: Comments run to the end of the line
+			for i := 0; b != '\n' && b != '\r'; i++ {
+				if i > pnmMaxComment {
+					return fmt.Errorf("header comment too long")
+				}
+
+				if b, err = p.r.ReadByte(); err != nil {
+					return err
+				}
+			}
+		default:
+			return p.r.UnreadByte()
+		}
+	}
+}
+
+// int reads the next decimal token
+func (p *pnmReader) int() (int, error) {
+	if err := p.skipSpace(); err != nil {
+		return 0, err
+	}
+
+	var token []byte
+	for len(token) <= pnmMaxToken {
+		b, err := p.r.ReadByte()
+		if err == io.EOF && len(token) > 0 {
+			break
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if b < '0' || b > '9' {
+			if err := p.r.UnreadByte(); err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		token = append(token, b)
+	}
+
+	// Assertion 1: A token is a bounded run of digits
+	if len(token) == 0 || len(token) > pnmMaxToken {
+		return 0, fmt.Errorf("invalid number in Netpbm stream")
+	}
+
+	return strconv.Atoi(string(token))
+}
+
+// bit reads the next ASCII bitmap digit, which needs no separator
+func (p *pnmReader) bit() (int, error) {
+	if err := p.skipSpace(); err != nil {
+		return 0, err
+	}
+
+	b, err := p.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if b != '0' && b != '1' {
+		return 0, fmt.Errorf("invalid bitmap digit %q", b)
+	}
+
+	return int(b - '0'), nil
+}
+
+// decodePNM decodes a PBM, PGM or PPM file in its ASCII (P1-P3) or
+// binary (P4-P6) form, keeping 16-bit samples when maxval exceeds 255
+func decodePNM(r io.Reader) (image.Image, error) {
+	img, err := readPNM(&pnmReader{r: bufio.NewReader(r)})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// pnmHeader is the magic number, size and maxval of a Netpbm stream
+type pnmHeader struct {
+	kind          byte
+	width, height int
+	maxValue      int
+}
+
+// readPNM parses the header and raster of a Netpbm stream
+func readPNM(p *pnmReader) (image.Image, error) {
+	header, err := readPNMHeader(p)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, maxValue := header.kind, header.maxValue
+
+	// Assertion 1: A single whitespace byte separates a binary raster
+	if kind >= '4' {
+		if _, err := p.r.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+
+	rect := image.Rect(0, 0, header.width, header.height)
+
+	switch kind {
+	case '1', '4':
+		return readPBM(p, rect, kind == '4')
+	case '2', '5':
+		return readPixmap(p, rect, 1, maxValue, kind == '5')
+	default:
+		return readPixmap(p, rect, 3, maxValue, kind == '6')
+	}
+}
+
+// readPNMHeader parses the magic number, size and maxval, leaving p at
+// the whitespace before the raster
+func readPNMHeader(p *pnmReader) (pnmHeader, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(p.r, magic); err != nil {
+		return pnmHeader{}, err
+	}
+
+	// Assertion 1: Validate the magic number
+	if magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return pnmHeader{}, fmt.Errorf("not a Netpbm file")
+	}
+
+	header := pnmHeader{kind: magic[1], maxValue: 1}
+
+	var err error
+	if header.width, err = p.int(); err != nil {
+		return pnmHeader{}, err
+	}
+
+	if header.height, err = p.int(); err != nil {
+		return pnmHeader{}, err
+	}
+
+	// Assertion 2: Validate dimensions before allocating the raster
+	if err := validator.ValidateDimensions(header.width, header.height); err != nil {
+		return pnmHeader{}, err
+	}
+
+	if header.kind != '1' && header.kind != '4' {
+		if header.maxValue, err = p.int(); err != nil {
+			return pnmHeader{}, err
+		}
+
+		if header.maxValue < 1 || header.maxValue > pnmMaxValue {
+			return pnmHeader{}, fmt.Errorf("maxval %d out of range 1-%d", header.maxValue, pnmMaxValue)
+		}
+	}
+
+	return header, nil
+}
+
+// colorModel returns the model of the image readPNM decodes for the header
+func (h pnmHeader) colorModel() color.Model {
+	switch {
+	case h.kind == '1' || h.kind == '4':
+		return color.GrayModel
+	case h.kind == '2' || h.kind == '5':
+		if h.maxValue > 0xFF {
+			return color.Gray16Model
+		}
+		return color.GrayModel
+	case h.maxValue > 0xFF:
+		return color.RGBA64Model
+	default:
+		return color.RGBAModel
+	}
+}
+
+// readPBM reads a bitmap, where a set bit is black
+func readPBM(p *pnmReader, rect image.Rectangle, binary bool) (image.Image, error) {
+	img := image.NewGray(rect)
+	row := make([]byte, (rect.Dx()+7)/8)
+
+	for y := 0; y < rect.Dy(); y++ {
+		if binary {
+			if _, err := io.ReadFull(p.r, row); err != nil {
+				return nil, err
+			}
+		}
+
+		for x := 0; x < rect.Dx(); x++ {
+			bit := int(row[x/8]>>(7-x%8)) & 1
+			if !binary {
+				var err error
+				if bit, err = p.bit(); err != nil {
+					return nil, err
+				}
+			}
+
+			if bit == 0 {
+				img.Pix[y*img.Stride+x] = 0xFF
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// readPixmap reads a graymap (one channel) or pixmap (three channels)
+// row by row into an opaque 8 or 16-bit image
+func readPixmap(p *pnmReader, rect image.Rectangle, channels, maxValue int, binary bool) (image.Image, error) {
+	var img image.Image
+	var pix []byte
+	var stride int
+
+	switch {
+	case channels == 1 && maxValue <= 0xFF:
+		gray := image.NewGray(rect)
+		img, pix, stride = gray, gray.Pix, gray.Stride
+	case channels == 1:
+		gray := image.NewGray16(rect)
+		img, pix, stride = gray, gray.Pix, gray.Stride
+	case maxValue <= 0xFF:
+		rgba := image.NewRGBA(rect)
+		img, pix, stride = rgba, rgba.Pix, rgba.Stride
+	default:
+		rgba := image.NewRGBA64(rect)
+		img, pix, stride = rgba, rgba.Pix, rgba.Stride
+	}
+
+	size, target := 1, 0xFF
+	if maxValue > 0xFF {
+		size, target = 2, 0xFFFF
+	}
+
+	// Color images carry an opaque alpha sample after the three channels
+	pixel := channels
+	if channels == 3 {
+		pixel = 4
+	}
+
+	row := make([]int, rect.Dx()*channels)
+	raw := make([]byte, len(row)*size)
+
+	for y := 0; y < rect.Dy(); y++ {
+		if err := readRow(p, row, raw, maxValue, binary); err != nil {
+			return nil, err
+		}
+
+		line := pix[y*stride:]
+		for x := 0; x < rect.Dx(); x++ {
+			for k := 0; k < pixel; k++ {
+				v := target
+				if k < channels {
+					v = scaleSample(row[x*channels+k], maxValue, target)
+				}
+
+				at := (x*pixel + k) * size
+				if size == 2 {
+					line[at], line[at+1] = uint8(v>>8), uint8(v)
+				} else {
+					line[at] = uint8(v)
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// readRow reads the samples of one raster row, binary ones taking two
+// big-endian bytes when maxValue exceeds 255
+func readRow(p *pnmReader, row []int, raw []byte, maxValue int, binary bool) error {
+	if !binary {
+		for i := 0; i < len(row); i++ {
+			v, err := p.int()
+			if err != nil {
+				return err
+			}
+			row[i] = v
+		}
+	} else {
+		if _, err := io.ReadFull(p.r, raw); err != nil {
+			return err
+		}
+
+		wide := len(raw) == 2*len(row)
+		for i := 0; i < len(row); i++ {
+			if wide {
+				row[i] = int(raw[2*i])<<8 | int(raw[2*i+1])
+			} else {
+				row[i] = int(raw[i])
+			}
+		}
+	}
+
+	// Assertion 1: No sample may exceed maxval
+	for i := 0; i < len(row); i++ {
+		if row[i] > maxValue {
+			return fmt.Errorf("sample %d exceeds maxval %d", row[i], maxValue)
+		}
+	}
+
+	return nil
+}
+
+// scaleSample rescales v from [0, from] to [0, to] with rounding
+func scaleSample(v, from, to int) int {
+	if from == to {
+		return v
+	}
+
+	return (v*to + from/2) / from
+}
+
+// encodePNM writes img as a binary Netpbm file: .pbm as a bitmap, .pgm
+// as a graymap, .ppm as a pixmap, and .pnm as whichever of the last two
+// fits the color model
+// 16-bit images keep 16-bit samples, transparency is flattened onto black
+func encodePNM(w io.Writer, ext string, img image.Image) error {
+	kind := byte('6')
+	switch ext {
+	case ".pbm":
+		kind = '4'
+	case ".pgm":
+		kind = '5'
+	case ".pnm":
+		if isGray(img.ColorModel()) {
+			kind = '5'
+		}
+	}
+
+	maxValue := 0xFF
+	if kind != '4' && isDeep(img.ColorModel()) {
+		maxValue = 0xFFFF
+	}
+
+	bounds := img.Bounds()
+	out := bufio.NewWriter(w)
+
+	if kind == '4' {
+		fmt.Fprintf(out, "P4\n%d %d\n", bounds.Dx(), bounds.Dy())
+	} else {
+		fmt.Fprintf(out, "P%c\n%d %d\n%d\n", kind, bounds.Dx(), bounds.Dy(), maxValue)
+	}
+
+	// Rows are written one at a time so the encoder streams
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if _, err := out.Write(pnmRow(img, y, kind, maxValue)); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+// pnmRow encodes row y of img for the given kind and maxval
+func pnmRow(img image.Image, y int, kind byte, maxValue int) []byte {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+
+	if kind == '4' {
+		row := make([]byte, (width+7)/8)
+		for x := 0; x < width; x++ {
+			// Dark pixels become set bits
+			if color.Gray16Model.Convert(img.At(bounds.Min.X+x, y)).(color.Gray16).Y < 0x8000 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		return row
+	}
+
+	channels := 3
+	if kind == '5' {
+		channels = 1
+	}
+
+	size := 1
+	if maxValue > 0xFF {
+		size = 2
+	}
+
+	row := make([]byte, 0, width*channels*size)
+	for x := 0; x < width; x++ {
+		c := img.At(bounds.Min.X+x, y)
+
+		var values [3]uint32
+		if channels == 1 {
+			values[0] = uint32(color.Gray16Model.Convert(c).(color.Gray16).Y)
+		} else {
+			values[0], values[1], values[2], _ = c.RGBA()
+		}
+
+		for k := 0; k < channels; k++ {
+			if size == 2 {
+				row = append(row, uint8(values[k]>>8), uint8(values[k]))
+			} else {
+				row = append(row, uint8(values[k]>>8))
+			}
+		}
+	}
+
+	return row
+}
+
+// isGray reports whether m holds only gray values
+func isGray(m color.Model) bool {
+	return m == color.GrayModel || m == color.Gray16Model
+}
+
+// isDeep reports whether m holds more than eight bits per channel
+func isDeep(m color.Model) bool {
+	return m == color.Gray16Model || m == color.RGBA64Model || m == color.NRGBA64Model || m == hdr.ColorModel
+}
@@ -0,0 +1,674 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// jpegBlockSize is the number of coefficients in one 8x8 block
+const jpegBlockSize = 64
+
+// jpegMaxEOBRun is the longest run of empty blocks one EOBRUN code holds
+const jpegMaxEOBRun = 0x7FFF
+
+// JPEG markers written by the progressive encoder
+const (
+	markerSOF2 = 0xC2
+	markerDHT  = 0xC4
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerDQT  = 0xDB
+)
+
+// jpegUnzig maps zig-zag positions to natural row-major positions
+var jpegUnzig = [jpegBlockSize]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegQuant holds the luminance and chrominance tables of section K.1 of
+// the JPEG standard in zig-zag order, before quality scaling
+var jpegQuant = [2][jpegBlockSize]int{
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegCosines holds cos((2x+1)uπ/16) scaled by the DCT normalisation,
+// indexed by u*8+x
+var jpegCosines = func() [jpegBlockSize]float64 {
+	var c [jpegBlockSize]float64
+	for u := 0; u < 8; u++ {
+		scale := 0.5
+		if u == 0 {
+			scale = math.Sqrt(0.125)
+		}
+
+		for x := 0; x < 8; x++ {
+			c[u*8+x] = scale * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return c
+}()
+
+// jpegComponent is one color plane of the image as quantized blocks
+type jpegComponent struct {
+	id      byte
+	h, v    int // Sampling factors
+	table   int // Quantization and Huffman table, 0 luminance, 1 chrominance
+	blocksX int // Blocks per row, covering whole MCUs
+	codedX  int // Blocks per row and column a single component scan codes
+	codedY  int
+	coeffs  [][jpegBlockSize]int32 // Zig-zag order
+}
+
+// jpegScan is one scan of the progressive script, coding the spectral
+// band start to end of its components
+type jpegScan struct {
+	components []int
+	start, end int
+}
+
+// encodeProgressiveJPEG writes img as a progressive JPEG with 4:2:0
+// chroma, sending the DC coefficients first, then low and high frequency
+// bands; every scan gets Huffman tables built from its own statistics
+func encodeProgressiveJPEG(w io.Writer, img image.Image, quality int) error {
+	bounds := img.Bounds()
+	components := jpegPlanes(img)
+
+	var quant [2][jpegBlockSize]int
+	for t := 0; t < 2; t++ {
+		quant[t] = scaleQuant(jpegQuant[t], quality)
+	}
+
+	for i := range components {
+		quantizeComponent(&components[i], quant[components[i].table])
+	}
+
+	// Low luma frequencies come before chroma since they carry most detail
+	scans := []jpegScan{
+		{components: []int{0}, start: 0, end: 0},
+		{components: []int{0}, start: 1, end: 5},
+		{components: []int{0}, start: 6, end: 63},
+	}
+	if len(components) == 3 {
+		scans = []jpegScan{
+			{components: []int{0, 1, 2}, start: 0, end: 0},
+			{components: []int{0}, start: 1, end: 5},
+			{components: []int{1}, start: 1, end: 63},
+			{components: []int{2}, start: 1, end: 63},
+			{components: []int{0}, start: 6, end: 63},
+		}
+	}
+
+	out := bufio.NewWriter(w)
+	out.Write([]byte{0xFF, markerSOI})
+	writeDQT(out, quant, len(components))
+	writeSOF2(out, bounds.Dx(), bounds.Dy(), components)
+
+	for _, scan := range scans {
+		// Assertion 1: Count the symbols first so the tables fit the scan
+		counter := &jpegCounter{}
+		codeScan(counter, components, scan)
+
+		var tables [2]*jpegHuffman
+		for t := 0; t < 2; t++ {
+			if counter.used[t] {
+				tables[t] = buildHuffman(&counter.freqs[t])
+			}
+		}
+
+		writeDHT(out, tables, scan.start > 0)
+		writeSOS(out, components, scan)
+
+		bw := &jpegBitWriter{w: out, tables: tables}
+		codeScan(bw, components, scan)
+		bw.flush()
+	}
+
+	out.Write([]byte{0xFF, markerEOI})
+	return out.Flush()
+}
+
+// jpegPlanes converts img to YCbCr and lays out the components, a single
+// luma component for gray images
+func jpegPlanes(img image.Image) []jpegComponent {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	gray := false
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		gray = true
+	}
+
+	if gray {
+		mcusX, mcusY := (width+7)/8, (height+7)/8
+		luma := make([]uint8, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				luma[y*width+x] = color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+			}
+		}
+
+		c := jpegComponent{id: 1, h: 1, v: 1, blocksX: mcusX, codedX: mcusX, codedY: mcusY}
+		c.coeffs = make([][jpegBlockSize]int32, mcusX*mcusY)
+		fillBlocks(&c, luma, width, height, mcusY)
+		return []jpegComponent{c}
+	}
+
+	// Premultiplied colors are encoded as they are, like image/jpeg does
+	luma := make([]uint8, width*height)
+	cb := make([]uint8, width*height)
+	cr := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			i := y*width + x
+			luma[i], cb[i], cr[i] = color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+		}
+	}
+
+	mcusX, mcusY := (width+15)/16, (height+15)/16
+	halfW, halfH := (width+1)/2, (height+1)/2
+
+	components := []jpegComponent{
+		{id: 1, h: 2, v: 2, table: 0, blocksX: 2 * mcusX, codedX: (width + 7) / 8, codedY: (height + 7) / 8},
+		{id: 2, h: 1, v: 1, table: 1, blocksX: mcusX, codedX: (halfW + 7) / 8, codedY: (halfH + 7) / 8},
+		{id: 3, h: 1, v: 1, table: 1, blocksX: mcusX, codedX: (halfW + 7) / 8, codedY: (halfH + 7) / 8},
+	}
+
+	components[0].coeffs = make([][jpegBlockSize]int32, 4*mcusX*mcusY)
+	fillBlocks(&components[0], luma, width, height, 2*mcusY)
+
+	for i, plane := range [][]uint8{cb, cr} {
+		c := &components[i+1]
+		c.coeffs = make([][jpegBlockSize]int32, mcusX*mcusY)
+		fillBlocks(c, halve(plane, width, height), halfW, halfH, mcusY)
+	}
+
+	return components
+}
+
+// halve averages 2x2 pixels of a plane, repeating the last row and
+// column of odd sizes
+func halve(plane []uint8, width, height int) []uint8 {
+	halfW, halfH := (width+1)/2, (height+1)/2
+	out := make([]uint8, halfW*halfH)
+
+	for y := 0; y < halfH; y++ {
+		y0, y1 := 2*y, min(2*y+1, height-1)
+		for x := 0; x < halfW; x++ {
+			x0, x1 := 2*x, min(2*x+1, width-1)
+			sum := int(plane[y0*width+x0]) + int(plane[y0*width+x1]) + int(plane[y1*width+x0]) + int(plane[y1*width+x1])
+			out[y*halfW+x] = uint8((sum + 2) / 4)
+		}
+	}
+
+	return out
+}
+
+// fillBlocks transforms a plane into the DCT blocks of c, repeating edge
+// pixels into the padding of partial blocks
+func fillBlocks(c *jpegComponent, plane []uint8, width, height, blocksY int) {
+	var samples [jpegBlockSize]float64
+
+	for by := 0; by < blocksY; by++ {
+		for bx := 0; bx < c.blocksX; bx++ {
+			for y := 0; y < 8; y++ {
+				row := min(8*by+y, height-1) * width
+				for x := 0; x < 8; x++ {
+					samples[8*y+x] = float64(plane[row+min(8*bx+x, width-1)]) - 128
+				}
+			}
+
+			fdct(&samples, &c.coeffs[by*c.blocksX+bx])
+		}
+	}
+}
+
+// fdct runs the separable 8x8 forward DCT, storing the unquantized
+// coefficients in natural order as float bits for quantizeComponent
+func fdct(samples *[jpegBlockSize]float64, out *[jpegBlockSize]int32) {
+	var rows [jpegBlockSize]float64
+
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for x := 0; x < 8; x++ {
+				sum += jpegCosines[u*8+x] * samples[8*y+x]
+			}
+			rows[8*y+u] = sum
+		}
+	}
+
+	// Coefficients are kept at 8 times their value to round once later
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			sum := 0.0
+			for y := 0; y < 8; y++ {
+				sum += jpegCosines[v*8+y] * rows[8*y+u]
+			}
+			out[8*v+u] = int32(math.Round(sum * 8))
+		}
+	}
+}
+
+// quantizeComponent divides every coefficient by its quantizer and
+// reorders the block into zig-zag order
+func quantizeComponent(c *jpegComponent, quant [jpegBlockSize]int) {
+	for i := range c.coeffs {
+		natural := c.coeffs[i]
+		for k := 0; k < jpegBlockSize; k++ {
+			q := int32(8 * quant[k])
+			v := natural[jpegUnzig[k]]
+
+			// Round half away from zero
+			if v < 0 {
+				c.coeffs[i][k] = -((-v + q/2) / q)
+			} else {
+				c.coeffs[i][k] = (v + q/2) / q
+			}
+		}
+	}
+}
+
+// scaleQuant scales a base table to quality the way libjpeg does
+func scaleQuant(base [jpegBlockSize]int, quality int) [jpegBlockSize]int {
+	quality = max(1, min(100, quality))
+	scale := 200 - 2*quality
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+
+	var out [jpegBlockSize]int
+	for i := range base {
+		out[i] = max(1, min(255, (base[i]*scale+50)/100))
+	}
+
+	return out
+}
+
+// jpegSink receives the Huffman symbols and raw bits of a scan
+type jpegSink interface {
+	symbol(table int, s byte)
+	raw(value uint32, n int)
+}
+
+// codeScan codes one scan into sink; the DC scan interleaves its
+// components MCU by MCU, AC scans hold a single component
+func codeScan(sink jpegSink, components []jpegComponent, scan jpegScan) {
+	if scan.start == 0 {
+		codeDCScan(sink, components, scan.components)
+		return
+	}
+
+	c := &components[scan.components[0]]
+	var eobRun int
+
+	for by := 0; by < c.codedY; by++ {
+		for bx := 0; bx < c.codedX; bx++ {
+			block := &c.coeffs[by*c.blocksX+bx]
+			run := 0
+
+			for k := scan.start; k <= scan.end; k++ {
+				if block[k] == 0 {
+					run++
+					continue
+				}
+
+				if eobRun > 0 {
+					emitEOBRun(sink, c.table, eobRun)
+					eobRun = 0
+				}
+
+				for ; run > 15; run -= 16 {
+					sink.symbol(c.table, 0xF0)
+				}
+
+				size, value := magnitude(block[k])
+				sink.symbol(c.table, byte(run<<4|size))
+				sink.raw(value, size)
+				run = 0
+			}
+
+			// Assertion 1: Trailing zeros extend the run of empty bands
+			if run > 0 {
+				eobRun++
+				if eobRun == jpegMaxEOBRun {
+					emitEOBRun(sink, c.table, eobRun)
+					eobRun = 0
+				}
+			}
+		}
+	}
+
+	if eobRun > 0 {
+		emitEOBRun(sink, c.table, eobRun)
+	}
+}
+
+// codeDCScan codes the DC coefficients as differences from the previous
+// block of the same component
+func codeDCScan(sink jpegSink, components []jpegComponent, indexes []int) {
+	var previous [3]int32
+
+	code := func(i int, block *[jpegBlockSize]int32) {
+		size, value := magnitude(block[0] - previous[i])
+		sink.symbol(components[i].table, byte(size))
+		sink.raw(value, size)
+		previous[i] = block[0]
+	}
+
+	// A single component is coded in plain raster order
+	if len(indexes) == 1 {
+		c := &components[indexes[0]]
+		for by := 0; by < c.codedY; by++ {
+			for bx := 0; bx < c.codedX; bx++ {
+				code(indexes[0], &c.coeffs[by*c.blocksX+bx])
+			}
+		}
+		return
+	}
+
+	luma := &components[0]
+	mcusX, mcusY := luma.blocksX/luma.h, len(luma.coeffs)/luma.blocksX/luma.v
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			for _, i := range indexes {
+				c := &components[i]
+				for v := 0; v < c.v; v++ {
+					for h := 0; h < c.h; h++ {
+						code(i, &c.coeffs[(my*c.v+v)*c.blocksX+mx*c.h+h])
+					}
+				}
+			}
+		}
+	}
+}
+
+// emitEOBRun codes a run of blocks whose band is empty
+func emitEOBRun(sink jpegSink, table, run int) {
+	n := bits.Len(uint(run)) - 1
+	sink.symbol(table, byte(n<<4))
+	sink.raw(uint32(run)&(1<<n-1), n)
+}
+
+// magnitude returns the bit size of v and its JPEG value bits, negative
+// values stored as their one's complement
+func magnitude(v int32) (int, uint32) {
+	a := v
+	if a < 0 {
+		a = -a
+		v--
+	}
+
+	size := bits.Len32(uint32(a))
+	return size, uint32(v) & (1<<size - 1)
+}
+
+// jpegCounter counts symbol frequencies per table
+type jpegCounter struct {
+	freqs [2][257]int
+	used  [2]bool
+}
+
+// symbol implements jpegSink
+func (c *jpegCounter) symbol(table int, s byte) {
+	c.freqs[table][s]++
+	c.used[table] = true
+}
+
+// raw implements jpegSink
+func (c *jpegCounter) raw(value uint32, n int) {}
+
+// jpegHuffman is a canonical Huffman table with its DHT form
+type jpegHuffman struct {
+	counts [16]byte
+	values []byte
+	codes  [256]uint16
+	sizes  [256]uint8
+}
+
+// buildHuffman builds a length limited Huffman table for the counted
+// frequencies, following section K.2 of the JPEG standard
+func buildHuffman(counted *[257]int) *jpegHuffman {
+	freq := *counted
+	// A reserved symbol keeps any real code from being all ones
+	freq[256] = 1
+
+	var codeSize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		// Assertion 1: Merge the two least frequent trees, larger index on ties
+		c1, c2 := -1, -1
+		for i := 0; i < 257; i++ {
+			if freq[i] > 0 && (c1 < 0 || freq[i] <= freq[c1]) {
+				c1 = i
+			}
+		}
+		for i := 0; i < 257; i++ {
+			if freq[i] > 0 && i != c1 && (c2 < 0 || freq[i] <= freq[c2]) {
+				c2 = i
+			}
+		}
+
+		if c2 < 0 {
+			break
+		}
+
+		freq[c1] += freq[c2]
+		freq[c2] = 0
+
+		codeSize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codeSize[c1]++
+		}
+		others[c1] = c2
+
+		codeSize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codeSize[c2]++
+		}
+	}
+
+	var lengths [33]int
+	for i := 0; i < 257; i++ {
+		if codeSize[i] > 0 {
+			lengths[min(codeSize[i], 32)]++
+		}
+	}
+
+	// Assertion 2: Move codes longer than 16 bits up the tree
+	for i := 32; i > 16; i-- {
+		for lengths[i] > 0 {
+			j := i - 2
+			for lengths[j] == 0 {
+				j--
+			}
+
+			lengths[i] -= 2
+			lengths[i-1]++
+			lengths[j+1] += 2
+			lengths[j]--
+		}
+	}
+
+	// Drop the reserved symbol from the longest length in use
+	for i := 16; i > 0; i-- {
+		if lengths[i] > 0 {
+			lengths[i]--
+			break
+		}
+	}
+
+	h := &jpegHuffman{}
+	for i := 1; i <= 16; i++ {
+		h.counts[i-1] = byte(lengths[i])
+	}
+
+	for size := 1; size <= 32; size++ {
+		for s := 0; s < 256; s++ {
+			if codeSize[s] == size {
+				h.values = append(h.values, byte(s))
+			}
+		}
+	}
+
+	// Canonical codes in order of length then value
+	code, k := uint16(0), 0
+	for i := 0; i < 16; i++ {
+		for j := 0; j < int(h.counts[i]); j++ {
+			s := h.values[k]
+			h.codes[s], h.sizes[s] = code, uint8(i+1)
+			code++
+			k++
+		}
+		code <<= 1
+	}
+
+	return h
+}
+
+// jpegBitWriter writes entropy coded data, stuffing a zero after 0xFF
+type jpegBitWriter struct {
+	w      *bufio.Writer
+	tables [2]*jpegHuffman
+	bits   uint32
+	n      int
+}
+
+// symbol implements jpegSink
+func (b *jpegBitWriter) symbol(table int, s byte) {
+	h := b.tables[table]
+	b.raw(uint32(h.codes[s]), int(h.sizes[s]))
+}
+
+// raw implements jpegSink for n up to 16 bits
+func (b *jpegBitWriter) raw(value uint32, n int) {
+	b.bits = b.bits<<n | value
+	b.n += n
+
+	for b.n >= 8 {
+		c := byte(b.bits >> (b.n - 8))
+		b.w.WriteByte(c)
+		if c == 0xFF {
+			b.w.WriteByte(0)
+		}
+		b.n -= 8
+	}
+	b.bits &= 1<<b.n - 1
+}
+
+// flush pads the last byte with one bits
+func (b *jpegBitWriter) flush() {
+	if b.n > 0 {
+		b.raw(1<<(8-b.n)-1, 8-b.n)
+	}
+}
+
+// writeMarker writes a marker with the length of its payload
+func writeMarker(out *bufio.Writer, marker byte, payload int) {
+	out.Write([]byte{0xFF, marker, byte((payload + 2) >> 8), byte(payload + 2)})
+}
+
+// writeDQT writes the quantization tables in zig-zag order
+func writeDQT(out *bufio.Writer, quant [2][jpegBlockSize]int, components int) {
+	tables := min(components, 2)
+	writeMarker(out, markerDQT, tables*(1+jpegBlockSize))
+
+	for t := 0; t < tables; t++ {
+		out.WriteByte(byte(t))
+		for k := 0; k < jpegBlockSize; k++ {
+			out.WriteByte(byte(quant[t][k]))
+		}
+	}
+}
+
+// writeSOF2 writes the progressive DCT frame header
+func writeSOF2(out *bufio.Writer, width, height int, components []jpegComponent) {
+	writeMarker(out, markerSOF2, 6+3*len(components))
+	out.Write([]byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), byte(len(components))})
+
+	for _, c := range components {
+		out.Write([]byte{c.id, byte(c.h<<4 | c.v), byte(c.table)})
+	}
+}
+
+// writeDHT writes the tables of a scan as DC or AC tables
+func writeDHT(out *bufio.Writer, tables [2]*jpegHuffman, ac bool) {
+	size := 0
+	for _, h := range tables {
+		if h != nil {
+			size += 1 + 16 + len(h.values)
+		}
+	}
+
+	writeMarker(out, markerDHT, size)
+	for t, h := range tables {
+		if h == nil {
+			continue
+		}
+
+		class := byte(0)
+		if ac {
+			class = 1
+		}
+
+		out.WriteByte(class<<4 | byte(t))
+		out.Write(h.counts[:])
+		out.Write(h.values)
+	}
+}
+
+// writeSOS writes the header of a scan, without successive approximation
+func writeSOS(out *bufio.Writer, components []jpegComponent, scan jpegScan) {
+	writeMarker(out, markerSOS, 4+2*len(scan.components))
+	out.WriteByte(byte(len(scan.components)))
+
+	for _, i := range scan.components {
+		c := components[i]
+		tables := byte(c.table << 4)
+		if scan.start > 0 {
+			tables = byte(c.table)
+		}
+		out.Write([]byte{c.id, tables})
+	}
+
+	out.Write([]byte{byte(scan.start), byte(scan.end), 0})
+}
@@ -0,0 +1,469 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+const (
+	// psdHeaderSize is the size of the fixed file header
+	psdHeaderSize = 26
+	// psdMaxChannels is the channel limit of the format
+	psdMaxChannels = 56
+	// psdPaletteSize is the size of an indexed color table
+	psdPaletteSize = 768
+)
+
+// PSD color modes read by this package
+const (
+	psdBitmap    = 0
+	psdGrayscale = 1
+	psdIndexed   = 2
+	psdRGB       = 3
+	psdCMYK      = 4
+	psdDuotone   = 8
+)
+
+// PSD image data compression methods read by this package
+const (
+	psdRaw      = 0
+	psdPackBits = 1
+)
+
+// psdHeader is the fixed header of a PSD or PSB file
+type psdHeader struct {
+	large    bool // PSB, which widens some lengths to 64 bits
+	channels int
+	width    int
+	height   int
+	depth    int
+	mode     int
+}
+
+// decodePSD decodes the flattened composite stored at the end of a
+// Photoshop PSD or PSB file, without reading the layers
+// Bitmap, grayscale, duotone, indexed, RGB and CMYK documents at 1, 8,
+// 16 or 32 bits are supported, the composite being raw or PackBits
+func decodePSD(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	img, err := readPSD(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// readPSD walks the file sections up to the composite image data
+func readPSD(data []byte) (image.Image, error) {
+	header, err := readPSDHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := psdHeaderSize
+
+	// Assertion 1: Color mode data holds the palette of indexed documents
+	modeData, pos, err := psdSection(data, pos, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.mode == psdIndexed && len(modeData) < psdPaletteSize {
+		return nil, fmt.Errorf("indexed document without a color table")
+	}
+
+	// Image resources are not needed for the composite
+	if _, pos, err = psdSection(data, pos, false); err != nil {
+		return nil, err
+	}
+
+	layers, pos, err := psdSection(data, pos, header.large)
+	if err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: The composite holds transparency only when the layer
+	// count is negative
+	alpha := psdHasMergedAlpha(layers, header.large) && header.channels > psdColorChannels(header.mode)
+
+	// Spot and extra alpha channels after those are skipped
+	needed := psdColorChannels(header.mode)
+	if alpha {
+		needed++
+	}
+
+	planes, err := readPSDPlanes(data, pos, header, needed)
+	if err != nil {
+		return nil, err
+	}
+
+	return psdImage(header, planes, modeData, alpha)
+}
+
+// readPSDHeader validates the fixed header
+func readPSDHeader(data []byte) (*psdHeader, error) {
+	// Assertion 1: Validate signature and version
+	if len(data) < psdHeaderSize || string(data[0:4]) != "8BPS" {
+		return nil, fmt.Errorf("not a Photoshop file")
+	}
+
+	version := binary.BigEndian.Uint16(data[4:6])
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("unknown Photoshop version %d", version)
+	}
+
+	header := &psdHeader{
+		large:    version == 2,
+		channels: int(binary.BigEndian.Uint16(data[12:14])),
+		height:   int(binary.BigEndian.Uint32(data[14:18])),
+		width:    int(binary.BigEndian.Uint32(data[18:22])),
+		depth:    int(binary.BigEndian.Uint16(data[22:24])),
+		mode:     int(binary.BigEndian.Uint16(data[24:26])),
+	}
+
+	// Assertion 2: Validate channels, size, depth and color mode
+	if header.channels < 1 || header.channels > psdMaxChannels {
+		return nil, fmt.Errorf("invalid channel count %d", header.channels)
+	}
+
+	if err := validator.ValidateDimensions(header.width, header.height); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case header.mode == psdBitmap && header.depth == 1:
+	case header.mode == psdIndexed && header.depth == 8:
+	case (header.mode == psdGrayscale || header.mode == psdDuotone || header.mode == psdRGB || header.mode == psdCMYK) &&
+		(header.depth == 8 || header.depth == 16 || header.depth == 32):
+	default:
+		return nil, fmt.Errorf("unsupported color mode %d at %d bits", header.mode, header.depth)
+	}
+
+	if header.channels < psdColorChannels(header.mode) {
+		return nil, fmt.Errorf("color mode %d needs %d channels", header.mode, psdColorChannels(header.mode))
+	}
+
+	return header, nil
+}
+
+// psdColorChannels returns the number of color channels of a mode
+func psdColorChannels(mode int) int {
+	switch mode {
+	case psdRGB:
+		return 3
+	case psdCMYK:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// psdSection returns the length-prefixed section at pos and the position
+// after it, wide sections having a 64-bit length
+func psdSection(data []byte, pos int, wide bool) ([]byte, int, error) {
+	size := 4
+	if wide {
+		size = 8
+	}
+
+	// Assertion 1: The length and section lie inside the file
+	if len(data)-pos < size {
+		return nil, 0, fmt.Errorf("truncated section header")
+	}
+
+	var length uint64
+	if wide {
+		length = binary.BigEndian.Uint64(data[pos:])
+	} else {
+		length = uint64(binary.BigEndian.Uint32(data[pos:]))
+	}
+
+	pos += size
+	if length > uint64(len(data)-pos) {
+		return nil, 0, fmt.Errorf("section overruns the file")
+	}
+
+	return data[pos : pos+int(length)], pos + int(length), nil
+}
+
+// psdHasMergedAlpha reads the layer count at the start of the layer and
+// mask section, a negative count marking a transparent composite
+func psdHasMergedAlpha(layers []byte, wide bool) bool {
+	info, _, err := psdSection(layers, 0, wide)
+	if err != nil || len(info) < 2 {
+		return false
+	}
+
+	return int16(binary.BigEndian.Uint16(info)) < 0
+}
+
+// readPSDPlanes reads the first count channels of the composite as
+// planar rows of (width*depth+7)/8 bytes
+func readPSDPlanes(data []byte, pos int, header *psdHeader, count int) ([][]byte, error) {
+	if len(data)-pos < 2 {
+		return nil, fmt.Errorf("missing image data")
+	}
+
+	compression := binary.BigEndian.Uint16(data[pos:])
+	pos += 2
+
+	rowSize := (header.width*header.depth + 7) / 8
+	planeSize := rowSize * header.height
+	rows := header.channels * header.height
+	planes := make([][]byte, count)
+
+	switch compression {
+	case psdRaw:
+		// Assertion 1: Raw planes follow each other
+		if len(data)-pos < planeSize*header.channels {
+			return nil, fmt.Errorf("truncated image data")
+		}
+
+		for c := 0; c < count; c++ {
+			planes[c] = data[pos+c*planeSize : pos+(c+1)*planeSize]
+		}
+	case psdPackBits:
+		// Assertion 2: A table of packed row sizes precedes the rows
+		countSize := 2
+		if header.large {
+			countSize = 4
+		}
+
+		if len(data)-pos < rows*countSize {
+			return nil, fmt.Errorf("truncated row size table")
+		}
+
+		table := data[pos : pos+rows*countSize]
+		pos += rows * countSize
+
+		for c := 0; c < count; c++ {
+			planes[c] = make([]byte, planeSize)
+
+			for y := 0; y < header.height; y++ {
+				i := c*header.height + y
+
+				var packed int
+				if header.large {
+					packed = int(binary.BigEndian.Uint32(table[4*i:]))
+				} else {
+					packed = int(binary.BigEndian.Uint16(table[2*i:]))
+				}
+
+				if packed > len(data)-pos {
+					return nil, fmt.Errorf("packed row overruns the file")
+				}
+
+				if err := unpackBits(data[pos:pos+packed], planes[c][y*rowSize:(y+1)*rowSize]); err != nil {
+					return nil, fmt.Errorf("channel %d row %d: %v", c, y, err)
+				}
+
+				pos += packed
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported image data compression %d", compression)
+	}
+
+	return planes, nil
+}
+
+// unpackBits expands PackBits data into dst, which it must fill
+func unpackBits(src, dst []byte) error {
+	out := 0
+
+	for len(src) > 0 && out < len(dst) {
+		n := int(int8(src[0]))
+		src = src[1:]
+
+		switch {
+		case n >= 0:
+			// Assertion 1: Literal runs copy n+1 bytes
+			if n+1 > len(src) || out+n+1 > len(dst) {
+				return fmt.Errorf("invalid literal run")
+			}
+
+			copy(dst[out:], src[:n+1])
+			src = src[n+1:]
+			out += n + 1
+		case n > -128:
+			// Assertion 2: Repeat runs copy one byte 1-n times
+			if len(src) == 0 || out+1-n > len(dst) {
+				return fmt.Errorf("invalid repeat run")
+			}
+
+			for i := 0; i < 1-n; i++ {
+				dst[out+i] = src[0]
+			}
+
+			src = src[1:]
+			out += 1 - n
+		}
+	}
+
+	if out != len(dst) {
+		return fmt.Errorf("row holds %d bytes, expected %d", out, len(dst))
+	}
+
+	return nil
+}
+
+// psdImage assembles the planes into an image of matching depth
+func psdImage(header *psdHeader, planes [][]byte, modeData []byte, alpha bool) (image.Image, error) {
+	rect := image.Rect(0, 0, header.width, header.height)
+
+	switch header.mode {
+	case psdBitmap:
+		// Set bits are black
+		img := image.NewGray(rect)
+		rowSize := (header.width + 7) / 8
+		for y := 0; y < header.height; y++ {
+			for x := 0; x < header.width; x++ {
+				if planes[0][y*rowSize+x/8]&(0x80>>(x%8)) == 0 {
+					img.Pix[y*img.Stride+x] = 0xFF
+				}
+			}
+		}
+		return img, nil
+	case psdIndexed:
+		palette := make(color.Palette, 256)
+		for i := 0; i < 256; i++ {
+			palette[i] = color.RGBA{R: modeData[i], G: modeData[256+i], B: modeData[512+i], A: 0xFF}
+		}
+
+		img := image.NewPaletted(rect, palette)
+		copy(img.Pix, planes[0])
+		return img, nil
+	}
+
+	colors := psdColorChannels(header.mode)
+
+	if header.depth == 32 {
+		return psdFloatImage(header, planes, colors, alpha), nil
+	}
+
+	if header.mode != psdRGB && header.mode != psdCMYK && !alpha {
+		if header.depth == 8 {
+			img := image.NewGray(rect)
+			copy(img.Pix, planes[0])
+			return img, nil
+		}
+
+		img := image.NewGray16(rect)
+		copy(img.Pix, planes[0])
+		return img, nil
+	}
+
+	img := image.NewNRGBA64(rect)
+	for y := 0; y < header.height; y++ {
+		for x := 0; x < header.width; x++ {
+			i := y*header.width + x
+			var c [4]float64
+
+			for k := 0; k < colors; k++ {
+				c[k] = psdSample(planes[k], i, header.depth)
+			}
+
+			a := 1.0
+			if alpha {
+				a = psdSample(planes[colors], i, header.depth)
+			}
+
+			r, g, b := psdRGBOf(header.mode, c)
+			img.SetNRGBA64(x, y, color.NRGBA64{
+				R: uint16(math.Round(unmatte(r, a) * 0xFFFF)),
+				G: uint16(math.Round(unmatte(g, a) * 0xFFFF)),
+				B: uint16(math.Round(unmatte(b, a) * 0xFFFF)),
+				A: uint16(math.Round(a * 0xFFFF)),
+			})
+		}
+	}
+
+	// Eight bit documents do not need the wider pixels
+	if header.depth == 8 {
+		narrow := image.NewNRGBA(rect)
+		for i := 0; i < len(narrow.Pix); i++ {
+			narrow.Pix[i] = img.Pix[2*i]
+		}
+		return narrow, nil
+	}
+
+	return img, nil
+}
+
+// psdFloatImage assembles 32-bit float planes into a float image
+func psdFloatImage(header *psdHeader, planes [][]byte, colors int, alpha bool) image.Image {
+	img := hdr.NewRGBA(image.Rect(0, 0, header.width, header.height))
+
+	for i := 0; i < header.width*header.height; i++ {
+		var c [4]float64
+		for k := 0; k < colors; k++ {
+			c[k] = psdSample(planes[k], i, 32)
+		}
+
+		a := 1.0
+		if alpha {
+			a = psdSample(planes[colors], i, 32)
+		}
+
+		r, g, b := psdRGBOf(header.mode, c)
+		img.Pix[4*i] = float32(unmatte(r, a) * a)
+		img.Pix[4*i+1] = float32(unmatte(g, a) * a)
+		img.Pix[4*i+2] = float32(unmatte(b, a) * a)
+		img.Pix[4*i+3] = float32(a)
+	}
+
+	return img
+}
+
+// psdSample reads sample i of a plane as a fraction of full scale, float
+// samples being returned as stored
+func psdSample(plane []byte, i, depth int) float64 {
+	switch depth {
+	case 8:
+		return float64(plane[i]) / 0xFF
+	case 16:
+		return float64(binary.BigEndian.Uint16(plane[2*i:])) / 0xFFFF
+	default:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(plane[4*i:])))
+	}
+}
+
+// psdRGBOf converts the color channels of a mode to RGB
+// CMYK samples are stored inverted, so full scale means no ink
+func psdRGBOf(mode int, c [4]float64) (float64, float64, float64) {
+	switch mode {
+	case psdRGB:
+		return c[0], c[1], c[2]
+	case psdCMYK:
+		return c[0] * c[3], c[1] * c[3], c[2] * c[3]
+	default:
+		return c[0], c[0], c[0]
+	}
+}
+
+// unmatte removes the white background Photoshop blends transparent
+// composites with
+func unmatte(v, a float64) float64 {
+	if a <= 0 {
+		return 0
+	}
+
+	if a >= 1 {
+		return v
+	}
+
+	return math.Min(math.Max((v-(1-a))/a, 0), 1)
+}
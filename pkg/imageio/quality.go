@@ -0,0 +1,49 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+)
+
+// ErrInvalidQuality is returned for an encoder quality outside 1-100
+var ErrInvalidQuality = errors.New("invalid quality")
+
+// pngCompressionNames maps flag values to PNG compression levels
+var pngCompressionNames = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// ValidateQuality checks that a lossy encoder quality is in 1-100
+func ValidateQuality(quality int) error {
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("%w: %d must be in [1, 100]", ErrInvalidQuality, quality)
+	}
+
+	return nil
+}
+
+// ParsePNGCompression turns a name such as "best" into a PNG compression
+// level
+func ParsePNGCompression(name string) (png.CompressionLevel, error) {
+	level, ok := pngCompressionNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown PNG compression %q (want none, fast, default or best)", ErrUnsupportedFormat, name)
+	}
+
+	return level, nil
+}
+
+// quality returns the JPEG quality of o, JPEGQuality when unset
+func (o JPEGOptions) quality() int {
+	if o.Quality == 0 {
+		return JPEGQuality
+	}
+
+	return o.Quality
+}
@@ -0,0 +1,304 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kasurarykerion/golangresizer/internal/hdr"
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+const (
+	// rgbeMaxHeaderLines bounds the header of a Radiance file
+	rgbeMaxHeaderLines = 1024
+	// rgbeMaxLine bounds the length of one header line
+	rgbeMaxLine = 4096
+	// rgbeMinRun is the shortest repeat worth a run-length code
+	rgbeMinRun = 4
+	// rgbeMinRLEWidth and rgbeMaxRLEWidth bound the scanlines that may use
+	// the run-length encoding
+	rgbeMinRLEWidth = 8
+	rgbeMaxRLEWidth = 0x7FFF
+)
+
+// decodeRadiance decodes a Radiance RGBE (.hdr) file into float pixels
+// Only the standard -Y H +X W orientation is supported
+func decodeRadiance(r io.Reader) (image.Image, error) {
+	img, err := readRadiance(bufio.NewReader(r))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// readRadiance parses the header, resolution line and scanlines
+func readRadiance(br *bufio.Reader) (image.Image, error) {
+	width, height, err := readRadianceHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	img := hdr.NewRGBA(image.Rect(0, 0, width, height))
+	line := make([]byte, 4*width)
+
+	for y := 0; y < height; y++ {
+		if err := readRGBELine(br, line, width); err != nil {
+			return nil, fmt.Errorf("scanline %d: %v", y, err)
+		}
+
+		row := img.Pix[y*img.Stride:]
+		for x := 0; x < width; x++ {
+			r, g, b := rgbeToFloat(line[4*x : 4*x+4])
+			row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = r, g, b, 1
+		}
+	}
+
+	return img, nil
+}
+
+// readRadianceHeader parses the header and resolution line, leaving br at
+// the first scanline
+func readRadianceHeader(br *bufio.Reader) (int, int, error) {
+	// Assertion 1: Validate the signature and format
+	first, err := readHeaderLine(br)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !strings.HasPrefix(first, "#?") {
+		return 0, 0, fmt.Errorf("not a Radiance file")
+	}
+
+	for i := 0; ; i++ {
+		if i >= rgbeMaxHeaderLines {
+			return 0, 0, fmt.Errorf("Radiance header too long")
+		}
+
+		line, err := readHeaderLine(br)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if line == "" {
+			break
+		}
+
+		if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+			return 0, 0, fmt.Errorf("unsupported Radiance format %s", format)
+		}
+	}
+
+	// Assertion 2: Validate the resolution line
+	resolution, err := readHeaderLine(br)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fields := strings.Fields(resolution)
+	if len(fields) != 4 || fields[0] != "-Y" || fields[2] != "+X" {
+		return 0, 0, fmt.Errorf("unsupported Radiance orientation %q", resolution)
+	}
+
+	height, errH := strconv.Atoi(fields[1])
+	width, errW := strconv.Atoi(fields[3])
+	if errH != nil || errW != nil {
+		return 0, 0, fmt.Errorf("invalid Radiance resolution %q", resolution)
+	}
+
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return 0, 0, err
+	}
+
+	return width, height, nil
+}
+
+// readHeaderLine reads one newline terminated header line
+func readHeaderLine(br *bufio.Reader) (string, error) {
+	var line []byte
+
+	for len(line) <= rgbeMaxLine {
+		b, err := br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		if b == '\n' {
+			return strings.TrimRight(string(line), "\r"), nil
+		}
+
+		line = append(line, b)
+	}
+
+	return "", fmt.Errorf("Radiance header line too long")
+}
+
+// readRGBELine reads one scanline of RGBE pixels, either flat or with
+// each channel run-length encoded
+func readRGBELine(br *bufio.Reader, line []byte, width int) error {
+	if _, err := io.ReadFull(br, line[:4]); err != nil {
+		return err
+	}
+
+	// Assertion 1: Run-length lines start with 2, 2 and the line width
+	if width < rgbeMinRLEWidth || width > rgbeMaxRLEWidth || line[0] != 2 || line[1] != 2 || line[2]&0x80 != 0 {
+		_, err := io.ReadFull(br, line[4:])
+		return err
+	}
+
+	if int(line[2])<<8|int(line[3]) != width {
+		return fmt.Errorf("run-length width mismatch")
+	}
+
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			// Assertion 2: Codes above 128 repeat one byte, others are literals
+			run := int(count)
+			repeat := run > 128
+			if repeat {
+				run -= 128
+			}
+
+			if run == 0 || x+run > width {
+				return fmt.Errorf("invalid run-length code")
+			}
+
+			var value byte
+			if repeat {
+				if value, err = br.ReadByte(); err != nil {
+					return err
+				}
+			}
+
+			for i := 0; i < run; i++ {
+				if !repeat {
+					if value, err = br.ReadByte(); err != nil {
+						return err
+					}
+				}
+
+				line[4*(x+i)+c] = value
+			}
+
+			x += run
+		}
+	}
+
+	return nil
+}
+
+// rgbeToFloat expands a shared-exponent pixel
+func rgbeToFloat(p []byte) (float32, float32, float32) {
+	if p[3] == 0 {
+		return 0, 0, 0
+	}
+
+	f := math.Ldexp(1, int(p[3])-(128+8))
+	return float32((float64(p[0]) + 0.5) * f), float32((float64(p[1]) + 0.5) * f), float32((float64(p[2]) + 0.5) * f)
+}
+
+// floatToRGBE packs a pixel into shared-exponent form, negative
+// channels become zero
+func floatToRGBE(r, g, b float64) [4]byte {
+	r, g, b = math.Max(r, 0), math.Max(g, 0), math.Max(b, 0)
+	v := math.Max(r, math.Max(g, b))
+
+	// Assertion 1: Tiny, NaN or infinite values cannot be represented
+	if !(v >= 1e-32) || math.IsInf(v, 0) {
+		return [4]byte{}
+	}
+
+	mantissa, exponent := math.Frexp(v)
+	if exponent > 127 {
+		return [4]byte{0xFF, 0xFF, 0xFF, 0xFF}
+	}
+
+	scale := mantissa * 256 / v
+	return [4]byte{byte(r * scale), byte(g * scale), byte(b * scale), byte(exponent + 128)}
+}
+
+// encodeRadiance writes img as a run-length encoded Radiance RGBE file
+// Alpha is dropped, leaving the premultiplied colors over black
+func encodeRadiance(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", height, width)
+
+	line := make([]byte, 4*width)
+	channel := make([]byte, width)
+	rle := width >= rgbeMinRLEWidth && width <= rgbeMaxRLEWidth
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := hdr.FloatAt(img, bounds.Min.X+x, bounds.Min.Y+y)
+			p := floatToRGBE(float64(c.R), float64(c.G), float64(c.B))
+			copy(line[4*x:], p[:])
+		}
+
+		if !rle {
+			out.Write(line)
+			continue
+		}
+
+		out.Write([]byte{2, 2, byte(width >> 8), byte(width)})
+		for c := 0; c < 4; c++ {
+			for x := 0; x < width; x++ {
+				channel[x] = line[4*x+c]
+			}
+
+			writeRGBERuns(out, channel)
+		}
+	}
+
+	return out.Flush()
+}
+
+// writeRGBERuns run-length encodes one channel of a scanline, emitting
+// runs of at least rgbeMinRun equal bytes and literals for the rest
+func writeRGBERuns(out *bufio.Writer, data []byte) {
+	for cur := 0; cur < len(data); {
+		begin, run, previous := cur, 0, 0
+
+		// Find the next run long enough to be worth encoding
+		for run < rgbeMinRun && begin < len(data) {
+			begin += run
+			previous = run
+			run = 1
+
+			for begin+run < len(data) && run < 127 && data[begin] == data[begin+run] {
+				run++
+			}
+		}
+
+		// A short run right before the long one is still a run
+		if previous > 1 && previous == begin-cur {
+			out.Write([]byte{byte(128 + previous), data[cur]})
+			cur = begin
+		}
+
+		for cur < begin {
+			literal := min(128, begin-cur)
+			out.WriteByte(byte(literal))
+			out.Write(data[cur : cur+literal])
+			cur += literal
+		}
+
+		if run >= rgbeMinRun {
+			out.Write([]byte{byte(128 + run), data[begin]})
+			cur += run
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+
+	"github.com/kasurarykerion/golangresizer/internal/transform"
+)
+
+const (
+	// rawMaxIFDs bounds the image file directories visited in one file
+	rawMaxIFDs = 64
+	// rawMaxEntries bounds the entries of one directory
+	rawMaxEntries = 4096
+	// rawMaxSubIFDs bounds the SubIFD offsets followed from one directory
+	rawMaxSubIFDs = 16
+)
+
+// TIFF tags used to find embedded previews
+const (
+	tagCompression     = 259
+	tagStripOffsets    = 273
+	tagOrientation     = 274
+	tagStripByteCounts = 279
+	tagSubIFDs         = 330
+	tagJPEGOffset      = 513
+	tagJPEGLength      = 514
+)
+
+// tiffFile addresses the directories of a TIFF based raw file
+type tiffFile struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiffEntry is one directory entry with its value or value offset
+type tiffEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// rawPreview is an embedded JPEG stream and its decoded size
+type rawPreview struct {
+	data []byte
+	area int
+}
+
+// decodeRaw decodes the largest embedded JPEG preview of a TIFF based
+// camera raw file such as DNG, CR2 or NEF, turned upright as its
+// orientation tag asks
+// The sensor data itself is not demosaiced
+func decodeRaw(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	img, err := readRaw(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// readRaw walks every directory for JPEG previews and decodes the largest
+func readRaw(data []byte) (image.Image, error) {
+	file, first, err := openTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var best rawPreview
+	orientation := 1
+	queue := []uint32{first}
+	visited := map[uint32]bool{}
+
+	// Assertion 1: Bound the walk and skip directories seen before
+	for len(queue) > 0 && len(visited) < rawMaxIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+
+		entries, next, err := file.readIFD(offset)
+		if err != nil {
+			return nil, err
+		}
+
+		// The first directory describes the picture as a whole
+		if offset == first {
+			if v, ok := file.uint(entries[tagOrientation], 0); ok {
+				orientation = int(v)
+			}
+		}
+
+		queue = append(queue, next)
+		if sub, ok := entries[tagSubIFDs]; ok {
+			for i := 0; i < int(min(sub.count, rawMaxSubIFDs)); i++ {
+				if v, ok := file.uint(sub, i); ok {
+					queue = append(queue, v)
+				}
+			}
+		}
+
+		for _, candidate := range file.previews(entries) {
+			if candidate.area > best.area {
+				best = candidate
+			}
+		}
+	}
+
+	// Assertion 2: A preview must exist
+	if best.data == nil {
+		return nil, fmt.Errorf("no embedded JPEG preview, raw sensor data cannot be decoded")
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(best.data))
+	if err != nil {
+		return nil, err
+	}
+
+	return Orient(img, orientation)
+}
+
+// openTIFF validates the TIFF header and returns the first IFD offset
+func openTIFF(data []byte) (*tiffFile, uint32, error) {
+	// Assertion 1: Validate the byte order mark and magic number
+	if len(data) < 8 {
+		return nil, 0, fmt.Errorf("not a TIFF based raw file")
+	}
+
+	file := &tiffFile{data: data}
+	switch string(data[0:2]) {
+	case "II":
+		file.order = binary.LittleEndian
+	case "MM":
+		file.order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("not a TIFF based raw file")
+	}
+
+	if file.order.Uint16(data[2:4]) != 42 {
+		return nil, 0, fmt.Errorf("not a TIFF based raw file")
+	}
+
+	return file, file.order.Uint32(data[4:8]), nil
+}
+
+// readIFD reads the directory at offset, returning its entries by tag and
+// the offset of the next directory
+func (f *tiffFile) readIFD(offset uint32) (map[uint16]tiffEntry, uint32, error) {
+	// Assertion 1: The entry count and entries lie inside the file
+	if uint64(offset)+2 > uint64(len(f.data)) {
+		return nil, 0, fmt.Errorf("directory offset out of range")
+	}
+
+	count := int(f.order.Uint16(f.data[offset:]))
+	start := int(offset) + 2
+	if count > rawMaxEntries || start+12*count+4 > len(f.data) {
+		return nil, 0, fmt.Errorf("directory overruns the file")
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		b := f.data[start+12*i : start+12*i+12]
+		entry := tiffEntry{kind: f.order.Uint16(b[2:4]), count: f.order.Uint32(b[4:8])}
+
+		// Values of four bytes or less are stored in the entry itself
+		size := uint64(tiffTypeSize(entry.kind)) * uint64(entry.count)
+		switch {
+		case size == 0:
+			continue
+		case size <= 4:
+			entry.value = b[8 : 8+size]
+		default:
+			at := uint64(f.order.Uint32(b[8:12]))
+			if at+size > uint64(len(f.data)) {
+				continue
+			}
+			entry.value = f.data[at : at+size]
+		}
+
+		entries[f.order.Uint16(b[0:2])] = entry
+	}
+
+	return entries, f.order.Uint32(f.data[start+12*count:]), nil
+}
+
+// tiffTypeSize returns the size of one value of a TIFF field type, zero
+// for types that are not needed here
+func tiffTypeSize(kind uint16) int {
+	switch kind {
+	case 1, 2, 6, 7:
+		return 1
+	case 3, 8:
+		return 2
+	case 4, 9, 13:
+		return 4
+	case 5, 10:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// uint returns value i of a SHORT, LONG or IFD entry
+func (f *tiffFile) uint(e tiffEntry, i int) (uint32, bool) {
+	switch {
+	case e.kind == 3 && 2*i+2 <= len(e.value):
+		return uint32(f.order.Uint16(e.value[2*i:])), true
+	case (e.kind == 4 || e.kind == 13) && 4*i+4 <= len(e.value):
+		return f.order.Uint32(e.value[4*i:]), true
+	default:
+		return 0, false
+	}
+}
+
+// previews returns the baseline JPEG streams a directory points at,
+// either through the JPEG interchange tags or as a single JPEG strip
+func (f *tiffFile) previews(entries map[uint16]tiffEntry) []rawPreview {
+	var spans [][2]uint32
+
+	if offset, ok := f.uint(entries[tagJPEGOffset], 0); ok {
+		if length, ok := f.uint(entries[tagJPEGLength], 0); ok {
+			spans = append(spans, [2]uint32{offset, length})
+		}
+	}
+
+	// Compression 6 and 7 are old and new style JPEG
+	compression, _ := f.uint(entries[tagCompression], 0)
+	if (compression == 6 || compression == 7) && entries[tagStripOffsets].count == 1 {
+		offset, okOffset := f.uint(entries[tagStripOffsets], 0)
+		length, okLength := f.uint(entries[tagStripByteCounts], 0)
+		if okOffset && okLength {
+			spans = append(spans, [2]uint32{offset, length})
+		}
+	}
+
+	var found []rawPreview
+	for i := 0; i < len(spans); i++ {
+		start, end := uint64(spans[i][0]), uint64(spans[i][0])+uint64(spans[i][1])
+
+		// Assertion 1: The stream lies inside the file and starts with SOI
+		if end > uint64(len(f.data)) || end-start < 2 || f.data[start] != 0xFF || f.data[start+1] != 0xD8 {
+			continue
+		}
+
+		// Lossless raw data also uses JPEG compression but does not decode
+		stream := f.data[start:end]
+		config, err := jpeg.DecodeConfig(bytes.NewReader(stream))
+		if err != nil {
+			continue
+		}
+
+		found = append(found, rawPreview{data: stream, area: config.Width * config.Height})
+	}
+
+	return found
+}
+
+// Orient turns img upright for a TIFF/EXIF orientation value; values
+// other than 2-8 return img unchanged
+func Orient(img image.Image, orientation int) (image.Image, error) {
+	quarters := 0
+	var axis transform.FlipAxis
+
+	switch orientation {
+	case 2:
+		axis = transform.FlipHorizontal
+	case 3:
+		quarters = 2
+	case 4:
+		axis = transform.FlipVertical
+	case 5:
+		quarters, axis = 1, transform.FlipHorizontal
+	case 6:
+		quarters = 1
+	case 7:
+		quarters, axis = 3, transform.FlipHorizontal
+	case 8:
+		quarters = 3
+	default:
+		return img, nil
+	}
+
+	var err error
+	if quarters != 0 {
+		if img, err = transform.RotateQuarter(img, quarters); err != nil {
+			return nil, err
+		}
+	}
+
+	if axis != "" {
+		return transform.Flip(img, axis)
+	}
+
+	return img, nil
+}
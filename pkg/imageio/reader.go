@@ -0,0 +1,132 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// The Decode functions read an image from a stream such as standard input
+// rather than a file; with no path to fall back on, the format comes from
+// the contents alone, so camera raw formats other than CR2 decode as the
+// plain TIFF they are built on
+
+// readStream reads r whole, up to the size limit of an input file, and
+// sniffs its format
+func readStream(r io.Reader) ([]byte, string, error) {
+	// Assertion 1: Read one byte past the limit to tell a full stream from
+	// a longer one
+	data, err := io.ReadAll(io.LimitReader(r, validator.MaxFileSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	if int64(len(data)) > validator.MaxFileSize {
+		return nil, "", fmt.Errorf("%w: stream too large", ErrFileOpen)
+	}
+
+	// Assertion 2: The contents must name the format
+	ext := SniffFormat(data[:min(len(data), sniffLength)])
+	if ext == "" {
+		return nil, "", fmt.Errorf("%w: format not recognized from the contents", ErrUnsupportedFormat)
+	}
+
+	return data, ext, nil
+}
+
+// DecodeImage reads an image from r like LoadImageWithOptions; the
+// MemoryMap option has no effect on a stream
+func DecodeImage(r io.Reader, opts DecodeOptions) (image.Image, error) {
+	// Assertion 1: Validate the options
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	data, ext, err := readStream(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeWithOptions(data, ext, opts)
+}
+
+// DecodeReduced reads an image from r like LoadReduced
+func DecodeReduced(r io.Reader, scale int) (image.Image, int, error) {
+	data, ext, err := readStream(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return decodeReducedData(data, ext, scale)
+}
+
+// DecodeAnimation reads every frame from r like LoadAnimationWithOptions
+func DecodeAnimation(r io.Reader, opts DecodeOptions) (*Animation, error) {
+	data, ext, err := readStream(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeAnimation(bytes.NewReader(data), ext, opts)
+}
+
+// DecodeImageConfig reads the format and dimensions of an image from the
+// start of r like LoadImageConfig, consuming only what the header needs
+// for most formats
+func DecodeImageConfig(r io.Reader) (ImageConfig, error) {
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return ImageConfig{}, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	// Assertion 1: The contents must name the format
+	ext := SniffFormat(header[:n])
+	if ext == "" {
+		return ImageConfig{}, fmt.Errorf("%w: format not recognized from the contents", ErrUnsupportedFormat)
+	}
+
+	return decodeConfig(io.MultiReader(bytes.NewReader(header[:n]), r), ext)
+}
+
+// DecodeOrientation reads the EXIF orientation of an image from r like
+// ReadOrientation
+func DecodeOrientation(r io.Reader) (int, error) {
+	data, ext, err := readStream(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return exifOrientation(data, ext), nil
+}
+
+// DecodeMetadata reads the EXIF, XMP and IPTC blocks of an image from r
+// like ReadMetadata
+func DecodeMetadata(r io.Reader) (Metadata, error) {
+	data, ext, err := readStream(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return metadataOf(data, ext), nil
+}
+
+// DecodePNGChunks reads the chunks ReadPNGChunks returns from r, nil for
+// formats other than PNG
+func DecodePNGChunks(r io.Reader) ([]PNGChunk, error) {
+	data, ext, err := readStream(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext != ".png" {
+		return nil, nil
+	}
+
+	return pngChunksOf(data), nil
+}
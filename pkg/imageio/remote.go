@@ -0,0 +1,177 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ErrRemote marks a failed request to remote storage
+var ErrRemote = errors.New("remote storage request failed")
+
+// RemoteObject is one object of remote storage
+type RemoteObject struct {
+	Name string // Key below the listed prefix, or the whole key
+	Size int64
+	ETag string // Changes whenever the content does
+}
+
+// Storage reads and writes the objects of one kind of remote storage,
+// named by paths such as s3://bucket/key; a missing object wraps
+// fs.ErrNotExist and any other failure ErrRemote
+type Storage interface {
+	// Get reads the object at key whole, refusing one over limit bytes
+	// with ErrTooLarge
+	Get(ctx context.Context, bucket, key string, limit int64) ([]byte, error)
+	// Put stores data as the object at key, replacing it only once the
+	// new one is complete
+	Put(ctx context.Context, bucket, key string, data []byte, contentType string) error
+	// Stat returns the size and ETag of the object at key
+	Stat(ctx context.Context, bucket, key string) (RemoteObject, error)
+	// List returns the objects whose keys start with prefix, named
+	// relative to it, in key order, failing beyond limit of them
+	List(ctx context.Context, bucket, prefix string, limit int) ([]RemoteObject, error)
+}
+
+// storage is a Storage set up on first use, from the environment
+type storage struct {
+	open    func() (Storage, error)
+	once    sync.Once
+	storage Storage
+	err     error
+}
+
+var (
+	storagesMu sync.RWMutex
+	storages   = map[string]*storage{
+		s3Scheme:     {open: newS3Client},
+		gcsScheme:    {open: newGCSClient},
+		azblobScheme: {open: newAzureClient},
+	}
+)
+
+// RegisterStorage makes the paths starting with scheme, such as
+// "minio://", use the Storage open returns when first needed
+func RegisterStorage(scheme string, open func() (Storage, error)) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+
+	storages[scheme] = &storage{open: open}
+}
+
+// remoteScheme returns the scheme path starts with and its storage, nil
+// for a local path
+func remoteScheme(path string) (string, *storage) {
+	storagesMu.RLock()
+	defer storagesMu.RUnlock()
+
+	for scheme, s := range storages {
+		if strings.HasPrefix(path, scheme) {
+			return scheme, s
+		}
+	}
+
+	return "", nil
+}
+
+// IsRemote reports whether path names an object of remote storage, such
+// as s3://bucket/key, rather than a local file
+func IsRemote(path string) bool {
+	_, s := remoteScheme(path)
+	return s != nil
+}
+
+// IsRemoteDir reports whether path names a prefix of remote storage
+// rather than one object: a bucket alone, or a key ending in a slash
+func IsRemoteDir(path string) bool {
+	scheme, s := remoteScheme(path)
+	if s == nil {
+		return false
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(path, scheme), "/")
+	return bucket != "" && (key == "" || strings.HasSuffix(key, "/"))
+}
+
+// JoinRemote joins elements, slash separated, to the key of a remote
+// path
+func JoinRemote(dir string, elem ...string) string {
+	scheme, _ := remoteScheme(dir)
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(dir, scheme), "/")
+
+	return scheme + bucket + "/" + strings.TrimPrefix(path.Join(append([]string{key}, elem...)...), "/")
+}
+
+// ReadRemote reads the remote object at uri whole, refusing one over
+// limit bytes
+func ReadRemote(ctx context.Context, uri string, limit int64) ([]byte, error) {
+	s, bucket, key, err := remotePath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Get(ctx, bucket, key, limit)
+}
+
+// WriteRemote stores data as the remote object at uri, typed by its
+// extension; the object appears only once it is complete
+func WriteRemote(ctx context.Context, uri string, data []byte) error {
+	s, bucket, key, err := remotePath(uri)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, bucket, key, data, MIMEType(strings.ToLower(path.Ext(key))))
+}
+
+// StatRemote returns the size and ETag of the remote object at uri; a
+// missing object wraps fs.ErrNotExist
+func StatRemote(ctx context.Context, uri string) (RemoteObject, error) {
+	s, bucket, key, err := remotePath(uri)
+	if err != nil {
+		return RemoteObject{}, err
+	}
+
+	return s.Stat(ctx, bucket, key)
+}
+
+// ListRemote returns the objects below the remote prefix dir, every
+// level of it, named relative to it and in key order; more than limit
+// objects is an error
+func ListRemote(ctx context.Context, dir string, limit int) ([]RemoteObject, error) {
+	s, bucket, key, err := remotePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.List(ctx, bucket, key, limit)
+}
+
+// remotePath splits a remote path into its storage, set up on first use,
+// its bucket and its key
+func remotePath(uri string) (Storage, string, string, error) {
+	scheme, s := remoteScheme(uri)
+	if s == nil {
+		return nil, "", "", fmt.Errorf("%w: %s is not a remote path", ErrRemote, uri)
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(uri, scheme), "/")
+
+	// Assertion 1: A bucket name every storage could accept
+	if bucket == "" || len(bucket) > 255 || strings.ContainsAny(bucket, "?#:@ \\") {
+		return nil, "", "", fmt.Errorf("%w: %s is not a %sbucket/key path", ErrRemote, uri, scheme)
+	}
+
+	s.once.Do(func() {
+		s.storage, s.err = s.open()
+	})
+	if s.err != nil {
+		return nil, "", "", s.err
+	}
+
+	return s.storage, bucket, key, nil
+}
@@ -0,0 +1,220 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// remoteMaxAttempts bounds the tries of a request the storage was
+	// too busy for
+	remoteMaxAttempts = 4
+	// remoteMaxBody bounds the error and token documents read
+	remoteMaxBody = 64 * 1024
+	// credentialsMargin renews temporary credentials this long before
+	// they expire
+	credentialsMargin = 5 * time.Minute
+	// metadataTimeout bounds asking a cloud metadata service, which does
+	// not answer outside its cloud
+	metadataTimeout = 2 * time.Second
+)
+
+// backoff waits before try attempt of a request, 200ms, 400ms then
+// 800ms, and not at all before the first
+func backoff(ctx context.Context, attempt int) error {
+	if attempt == 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(100 * time.Millisecond << attempt):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrCanceled, ctx.Err())
+	}
+}
+
+// retryable reports whether a response status says the storage is busy
+// and the request may work again
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// sendRetrying sends the request newRequest builds, again after a backoff
+// while the storage is busy or unreachable; the last response is
+// returned whatever its status
+func sendRetrying(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; attempt < remoteMaxAttempts; attempt++ {
+		if err := backoff(ctx, attempt); err != nil {
+			return nil, err
+		}
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("%w: %v", ErrCanceled, ctx.Err())
+			}
+
+			lastErr = err
+			continue
+		}
+
+		if retryable(resp.StatusCode) && attempt < remoteMaxAttempts-1 {
+			resp.Body.Close()
+			continue
+		}
+
+		return resp, nil
+	}
+
+	return nil, lastErr
+}
+
+// xmlErrorBody is the error document of S3 and Azure
+type xmlErrorBody struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// xmlError returns the error code of a failed response and its message,
+// the status when it has no error document; the body is closed
+func xmlError(resp *http.Response) (string, string) {
+	defer resp.Body.Close()
+
+	var body xmlErrorBody
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, remoteMaxBody))
+	if xml.Unmarshal(data, &body) != nil || body.Code == "" {
+		body.Code = firstNonEmpty(resp.Header.Get("X-Ms-Error-Code"), resp.Status)
+	}
+
+	detail := body.Code
+	if body.Message != "" {
+		detail += ": " + strings.TrimSpace(body.Message)
+	}
+
+	return body.Code, detail
+}
+
+// getText returns the body of the response to req, which must be 200 OK
+func getText(client *http.Client, req *http.Request) (string, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteMaxBody))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s answered %s %s", req.URL.Host, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return string(data), err
+}
+
+// getJSON decodes the JSON body of the response to req into v
+func getJSON(client *http.Client, req *http.Request, v any) error {
+	text, err := getText(client, req)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(text), v)
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// bearerToken is an OAuth access token
+type bearerToken struct {
+	value   string
+	expires time.Time
+}
+
+// tokenResponse is the answer of an OAuth token endpoint; some metadata
+// services send expires_in as a string
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+}
+
+// token returns the access token of r, checking there is one
+func (r tokenResponse) token(source string) (bearerToken, error) {
+	if r.AccessToken == "" {
+		return bearerToken{}, fmt.Errorf("%w: %s returned no access token", ErrRemote, source)
+	}
+
+	seconds, _ := r.ExpiresIn.Int64()
+	return bearerToken{value: r.AccessToken, expires: time.Now().Add(time.Duration(seconds) * time.Second)}, nil
+}
+
+// postToken asks the OAuth token endpoint at endpoint for a token with
+// the form values
+func postToken(ctx context.Context, client *http.Client, endpoint string, form url.Values) (bearerToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return bearerToken{}, fmt.Errorf("%w: %v", ErrRemote, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp tokenResponse
+	if err := getJSON(client, req, &resp); err != nil {
+		return bearerToken{}, fmt.Errorf("%w: token request: %v", ErrRemote, err)
+	}
+
+	return resp.token(endpoint)
+}
+
+// tokenCache keeps the token of a source until it is about to expire
+type tokenCache struct {
+	source func(ctx context.Context) (bearerToken, error)
+
+	mu    sync.Mutex
+	token bearerToken
+}
+
+// get returns the cached token, asking the source for a new one when it
+// is about to expire
+func (c *tokenCache) get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token.value != "" && time.Until(c.token.expires) > credentialsMargin {
+		return c.token.value, nil
+	}
+
+	token, err := c.source(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.token = token
+	return token.value, nil
+}
@@ -0,0 +1,712 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3Scheme starts the paths naming S3 objects, s3://bucket/key
+const s3Scheme = "s3://"
+
+const (
+	// s3DefaultRegion is the region of buckets when none is configured;
+	// S3 names the right one when it differs
+	s3DefaultRegion = "us-east-1"
+	// s3MaxPages bounds the pages of one listing, 1000 keys each
+	s3MaxPages = 10000
+	// amzDateLayout is the time format of SigV4
+	amzDateLayout = "20060102T150405Z"
+)
+
+// awsSignedHeaders are the headers besides host and x-amz-* that SigV4
+// signs when a request carries them
+var awsSignedHeaders = []string{"content-md5", "content-type", "date", "range"}
+
+// emptySHA256 is the hash of an empty payload
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Location is an object, or a prefix of keys, in a bucket
+type s3Location struct {
+	bucket string
+	key    string
+}
+
+// String returns the s3:// path of l
+func (l s3Location) String() string {
+	return s3Scheme + l.bucket + "/" + l.key
+}
+
+// awsCredentials sign S3 requests
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string    // Only for temporary credentials
+	Expires         time.Time // Zero for keys that do not expire
+}
+
+// valid reports whether c are set and not about to expire
+func (c awsCredentials) valid() bool {
+	return c.AccessKeyID != "" && (c.Expires.IsZero() || time.Until(c.Expires) > credentialsMargin)
+}
+
+// s3Client sends SigV4 signed requests to S3, or to the endpoint of a
+// service speaking its API such as MinIO
+type s3Client struct {
+	http     *http.Client
+	region   string
+	endpoint *url.URL // AWS_ENDPOINT_URL_S3, addressed path style; nil for AWS
+
+	mu      sync.Mutex
+	creds   awsCredentials
+	regions map[string]string // Bucket regions S3 named in redirects
+}
+
+// newS3Client returns the Storage of s3:// paths, reading the region and
+// endpoint as the AWS tools do
+func newS3Client() (Storage, error) {
+	c := &s3Client{http: &http.Client{}, regions: make(map[string]string)}
+
+	c.region = firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if c.region == "" {
+		config, err := readINI(awsConfigFile(), configSection(awsProfile()))
+		if err != nil {
+			return nil, err
+		}
+		c.region = config["region"]
+	}
+
+	if c.region == "" {
+		c.region = s3DefaultRegion
+	}
+
+	if raw := firstNonEmpty(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); raw != "" {
+		endpoint, err := url.Parse(raw)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return nil, fmt.Errorf("%w: invalid S3 endpoint %q", ErrRemote, raw)
+		}
+
+		c.endpoint = endpoint
+	}
+
+	return c, nil
+}
+
+// Get reads the object at key whole, refusing one over limit bytes
+func (c *s3Client) Get(ctx context.Context, bucket, key string, limit int64) ([]byte, error) {
+	loc := s3Location{bucket: bucket, key: key}
+	resp, err := c.do(ctx, http.MethodGet, loc, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Assertion 1: Refuse oversized objects before reading them
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("%w: %s is %d bytes, over the %d byte limit", ErrTooLarge, loc, resp.ContentLength, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading %s: %v", ErrRemote, loc, err)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %s is over the %d byte limit", ErrTooLarge, loc, limit)
+	}
+
+	return data, nil
+}
+
+// Put writes data as the object at key, which S3 replaces whole, so a
+// failed write leaves the previous object
+func (c *s3Client) Put(ctx context.Context, bucket, key string, data []byte, contentType string) error {
+	loc := s3Location{bucket: bucket, key: key}
+	resp, err := c.do(ctx, http.MethodPut, loc, nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Stat returns the size and ETag of the object at key
+func (c *s3Client) Stat(ctx context.Context, bucket, key string) (RemoteObject, error) {
+	loc := s3Location{bucket: bucket, key: key}
+	resp, err := c.do(ctx, http.MethodHead, loc, nil, nil, "")
+	if err != nil {
+		return RemoteObject{}, err
+	}
+	resp.Body.Close()
+
+	return RemoteObject{Name: loc.key, Size: resp.ContentLength, ETag: strings.Trim(resp.Header.Get("ETag"), `"`)}, nil
+}
+
+// s3ListResult is a page of ListObjectsV2
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+		ETag string `xml:"ETag"`
+	} `xml:"Contents"`
+}
+
+// List returns the objects whose keys start with prefix, named relative
+// to it, up to limit of them; keys ending in a slash are the folders of
+// consoles and are left out
+func (c *s3Client) List(ctx context.Context, bucket, prefix string, limit int) ([]RemoteObject, error) {
+	loc := s3Location{bucket: bucket, key: prefix}
+	var objects []RemoteObject
+	token := ""
+	for page := 0; page < s3MaxPages; page++ {
+		query := url.Values{"list-type": {"2"}, "prefix": {loc.key}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := c.do(ctx, http.MethodGet, s3Location{bucket: loc.bucket}, query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: listing %s: %v", ErrRemote, loc, err)
+		}
+
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, "/") {
+				continue
+			}
+
+			// Assertion 1: Bound the listing
+			if len(objects) == limit {
+				return nil, fmt.Errorf("%w: %s holds more than %d objects", ErrRemote, loc, limit)
+			}
+
+			name := strings.TrimPrefix(object.Key, loc.key)
+			objects = append(objects, RemoteObject{Name: name, Size: object.Size, ETag: strings.Trim(object.ETag, `"`)})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+
+	return nil, fmt.Errorf("%w: listing %s did not end after %d pages", ErrRemote, loc, s3MaxPages)
+}
+
+// do sends a signed request for loc, retrying when S3 is busy or names
+// another region for the bucket; a missing object wraps fs.ErrNotExist
+// and any other failure ErrRemote
+func (c *s3Client) do(ctx context.Context, method string, loc s3Location, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	creds, err := c.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := emptySHA256
+	if body != nil {
+		sum := sha256.Sum256(body)
+		payload = hex.EncodeToString(sum[:])
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < remoteMaxAttempts; attempt++ {
+		if err := backoff(ctx, attempt); err != nil {
+			return nil, err
+		}
+
+		region := c.bucketRegion(loc.bucket)
+		req, err := http.NewRequestWithContext(ctx, method, "https://s3.amazonaws.com", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRemote, err)
+		}
+
+		req.URL = c.url(loc, region, query)
+		req.Host = req.URL.Host
+		if body == nil {
+			req.Body, req.ContentLength = http.NoBody, 0
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		creds.sign(req, region, payload, time.Now())
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("%w: %v", ErrCanceled, ctx.Err())
+			}
+
+			lastErr = fmt.Errorf("%w: %s %s: %v", ErrRemote, method, loc, err)
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		// Assertion 1: Follow S3 to the region of the bucket
+		if other := resp.Header.Get("X-Amz-Bucket-Region"); other != "" && other != region {
+			resp.Body.Close()
+			c.setBucketRegion(loc.bucket, other)
+			continue
+		}
+
+		lastErr = s3Error(resp, method, loc)
+		if !retryable(resp.StatusCode) {
+			return nil, lastErr
+		}
+	}
+
+	return nil, lastErr
+}
+
+// url returns the URL of loc in region: path style on a custom endpoint
+// or for bucket names with dots, which TLS names cannot hold, and virtual
+// host style on AWS otherwise
+func (c *s3Client) url(loc s3Location, region string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https"}
+	key := ""
+	if loc.key != "" {
+		key = "/" + loc.key
+	}
+
+	switch {
+	case c.endpoint != nil:
+		u.Scheme, u.Host = c.endpoint.Scheme, c.endpoint.Host
+		u.Path = strings.TrimSuffix(c.endpoint.Path, "/") + "/" + loc.bucket + key
+	case strings.Contains(loc.bucket, "."):
+		u.Host = "s3." + region + ".amazonaws.com"
+		u.Path = "/" + loc.bucket + key
+	default:
+		u.Host = loc.bucket + ".s3." + region + ".amazonaws.com"
+		u.Path = "/" + strings.TrimPrefix(key, "/")
+	}
+
+	// SigV4 signs the path and query escaped exactly as sent
+	u.RawPath = awsEscape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// bucketRegion returns the region requests for bucket go to
+func (c *s3Client) bucketRegion(bucket string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if region, ok := c.regions[bucket]; ok {
+		return region
+	}
+
+	return c.region
+}
+
+// setBucketRegion remembers the region S3 named for bucket
+func (c *s3Client) setBucketRegion(bucket, region string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.regions[bucket] = region
+}
+
+// s3Error returns the error of a failed response, closing it
+func s3Error(resp *http.Response, method string, loc s3Location) error {
+	code, detail := xmlError(resp)
+	if resp.StatusCode == http.StatusNotFound && code != "NoSuchBucket" {
+		return fmt.Errorf("%w: %s %s: %w", ErrRemote, method, loc, fs.ErrNotExist)
+	}
+
+	return fmt.Errorf("%w: %s %s: %s", ErrRemote, method, loc, detail)
+}
+
+// sign adds the SigV4 headers of c to req for region at now; payload is
+// the hex SHA-256 of the body
+func (c awsCredentials) sign(req *http.Request, region, payload string, now time.Time) {
+	stamp := now.UTC().Format(amzDateLayout)
+	day := stamp[:8]
+
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	// Host, the x-amz headers and the others S3 checks are signed
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	for name, value := range req.Header {
+		lower := strings.ToLower(name)
+		if slices.Contains(awsSignedHeaders, lower) || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+			values[lower] = strings.TrimSpace(strings.Join(value, ","))
+		}
+	}
+	slices.Sort(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers.String(), signed, payload}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKeyID+"/"+scope+", SignedHeaders="+signed+", Signature="+signature)
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent-encodes every byte of s but the unreserved ones, and
+// slashes unless slash is set, as SigV4 expects
+func awsEscape(s string, slash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		unreserved := 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '.' || b == '_' || b == '~'
+		if unreserved || (b == '/' && !slash) {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+
+	return sb.String()
+}
+
+// canonicalQuery returns query sorted by name and escaped for SigV4
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, awsEscape(name, true)+"="+awsEscape(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// credentials returns the credentials of the client, looking them up
+// again when temporary ones are about to expire
+func (c *s3Client) credentials(ctx context.Context) (awsCredentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds.valid() {
+		return c.creds, nil
+	}
+
+	creds, err := resolveCredentials(ctx, c.region)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	c.creds = creds
+	return creds, nil
+}
+
+// resolveCredentials finds credentials in the order of the AWS tools:
+// the environment, the shared credentials and config files of
+// AWS_PROFILE, a web identity token, the container credentials and the
+// EC2 instance role
+func resolveCredentials(ctx context.Context, region string) (awsCredentials, error) {
+	// Assertion 1: Keys in the environment come first
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if secret == "" {
+			return awsCredentials{}, fmt.Errorf("%w: AWS_ACCESS_KEY_ID is set without AWS_SECRET_ACCESS_KEY", ErrRemote)
+		}
+
+		return awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	profile := awsProfile()
+	for _, file := range []struct{ path, section string }{{awsCredentialsFile(), profile}, {awsConfigFile(), configSection(profile)}} {
+		keys, err := readINI(file.path, file.section)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+
+		if keys["aws_access_key_id"] != "" {
+			return awsCredentials{AccessKeyID: keys["aws_access_key_id"], SecretAccessKey: keys["aws_secret_access_key"], SessionToken: keys["aws_session_token"]}, nil
+		}
+	}
+
+	client := &http.Client{Timeout: metadataTimeout}
+	switch {
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "":
+		return webIdentityCredentials(ctx, &http.Client{Timeout: time.Minute}, region)
+	case os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "":
+		return containerCredentials(ctx, client)
+	case !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true"):
+		creds, err := instanceCredentials(ctx, client)
+		if err == nil {
+			return creds, nil
+		}
+	}
+
+	return awsCredentials{}, fmt.Errorf("%w: no AWS credentials found; set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or AWS_PROFILE with ~/.aws/credentials", ErrRemote)
+}
+
+// awsProfile returns the profile of the shared files
+func awsProfile() string {
+	return firstNonEmpty(os.Getenv("AWS_PROFILE"), os.Getenv("AWS_DEFAULT_PROFILE"), "default")
+}
+
+// configSection returns the section of profile in the config file, which
+// prefixes every profile but the default
+func configSection(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+
+	return "profile " + profile
+}
+
+// awsCredentialsFile returns the path of the shared credentials file
+func awsCredentialsFile() string {
+	return awsFile("AWS_SHARED_CREDENTIALS_FILE", "credentials")
+}
+
+// awsConfigFile returns the path of the shared config file
+func awsConfigFile() string {
+	return awsFile("AWS_CONFIG_FILE", "config")
+}
+
+// awsFile returns the path in variable, or name in ~/.aws
+func awsFile(variable, name string) string {
+	if path := os.Getenv(variable); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return home + string(os.PathSeparator) + ".aws" + string(os.PathSeparator) + name
+}
+
+// readINI returns the keys of section in the INI file at path, none
+// when the file or the section does not exist
+func readINI(path, section string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if path == "" {
+		return keys, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRemote, err)
+	}
+
+	inSection := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			inSection = strings.TrimSpace(strings.TrimSuffix(name, "]")) == section
+			continue
+		}
+
+		if name, value, ok := strings.Cut(line, "="); ok && inSection {
+			keys[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+
+	return keys, nil
+}
+
+// metadataCredentials is the credentials document of the container and
+// instance metadata services
+type metadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// credentials returns m as credentials, checking it holds keys
+func (m metadataCredentials) credentials(source string) (awsCredentials, error) {
+	if m.AccessKeyID == "" || m.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("%w: %s returned no credentials", ErrRemote, source)
+	}
+
+	return awsCredentials{AccessKeyID: m.AccessKeyID, SecretAccessKey: m.SecretAccessKey, SessionToken: m.Token, Expires: m.Expiration}, nil
+}
+
+// containerCredentials asks the ECS or EKS Pod Identity agent for the
+// credentials of the task role
+func containerCredentials(ctx context.Context, client *http.Client) (awsCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: container credentials: %v", ErrRemote, err)
+	}
+
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("%w: container credentials: %v", ErrRemote, err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	var doc metadataCredentials
+	if err := getJSON(client, req, &doc); err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: container credentials: %v", ErrRemote, err)
+	}
+
+	return doc.credentials("the container credentials endpoint")
+}
+
+// instanceCredentials asks the EC2 instance metadata service, version 2,
+// for the credentials of the instance role
+func instanceCredentials(ctx context.Context, client *http.Client) (awsCredentials, error) {
+	const imds = "http://169.254.169.254"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, imds+"/latest/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+
+	token, err := getText(client, req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	path := imds + "/latest/meta-data/iam/security-credentials/"
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, path, nil); err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", token)
+
+	roles, err := getText(client, req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	role, _, _ := strings.Cut(strings.TrimSpace(roles), "\n")
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, path+url.PathEscape(role), nil); err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", token)
+
+	var doc metadataCredentials
+	if err := getJSON(client, req, &doc); err != nil {
+		return awsCredentials{}, err
+	}
+
+	return doc.credentials("the instance metadata service")
+}
+
+// webIdentityResponse is the answer of STS AssumeRoleWithWebIdentity
+type webIdentityResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// webIdentityCredentials trades the token of AWS_WEB_IDENTITY_TOKEN_FILE
+// for credentials of AWS_ROLE_ARN, as EKS service accounts do
+func webIdentityCredentials(ctx context.Context, client *http.Client, region string) (awsCredentials, error) {
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: web identity: %v", ErrRemote, err)
+	}
+
+	session := firstNonEmpty(os.Getenv("AWS_ROLE_SESSION_NAME"), "golangresizer-"+strconv.FormatInt(time.Now().Unix(), 10))
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+
+	endpoint := firstNonEmpty(os.Getenv("AWS_ENDPOINT_URL_STS"), "https://sts."+region+".amazonaws.com/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(query.Encode()))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: web identity: %v", ErrRemote, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: web identity: %v", ErrRemote, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("%w: web identity: STS answered %s", ErrRemote, resp.Status)
+	}
+
+	var doc webIdentityResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return awsCredentials{}, fmt.Errorf("%w: web identity: %v", ErrRemote, err)
+	}
+
+	creds := doc.Credentials
+	return metadataCredentials{AccessKeyID: creds.AccessKeyID, SecretAccessKey: creds.SecretAccessKey, Token: creds.SessionToken, Expiration: creds.Expiration}.credentials("STS")
+}
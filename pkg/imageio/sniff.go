@@ -0,0 +1,102 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLength is how many leading bytes the format sniffer looks at
+const sniffLength = 16
+
+// SniffFormat identifies an image format from the leading bytes of a
+// file and returns the extension its decoder is registered under, or ""
+// when the bytes match no known signature
+// TIFF based camera raw files other than CR2 sniff as ".tiff"
+func SniffFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return ".jpg"
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return ".png"
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return ".gif"
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WEBP":
+		return ".webp"
+	case len(header) >= 10 && (string(header[0:4]) == "II*\x00" || string(header[0:4]) == "MM\x00*"):
+		// Canon raw marks itself right after the TIFF header
+		if string(header[8:10]) == "CR" {
+			return ".cr2"
+		}
+		return ".tiff"
+	case bytes.HasPrefix(header, []byte{0x76, 0x2F, 0x31, 0x01}):
+		return ".exr"
+	case bytes.HasPrefix(header, []byte("#?")):
+		return ".hdr"
+	case bytes.HasPrefix(header, []byte("8BPS")):
+		return ".psd"
+	case bytes.HasPrefix(header, []byte("DDS ")):
+		return ".dds"
+	case isBMP(header):
+		return ".bmp"
+	case len(header) >= 6 && string(header[0:4]) == "\x00\x00\x01\x00" && binary.LittleEndian.Uint16(header[4:6]) > 0:
+		return ".ico"
+	case len(header) >= 3 && header[0] == 'P' && header[1] >= '1' && header[1] <= '6' && isPNMSpace(header[2]):
+		return ".pnm"
+	default:
+		return ""
+	}
+}
+
+// isBMP checks the BM signature together with the reserved fields and a
+// known info header size, as two letters alone match plenty of text
+func isBMP(header []byte) bool {
+	if len(header) < 18 || string(header[0:2]) != "BM" || binary.LittleEndian.Uint32(header[6:10]) != 0 {
+		return false
+	}
+
+	switch binary.LittleEndian.Uint32(header[14:18]) {
+	case 12, 40, 52, 56, 64, 108, 124:
+		return true
+	default:
+		return false
+	}
+}
+
+// isPNMSpace reports whether b separates Netpbm header tokens
+func isPNMSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '#'
+}
+
+// detectFormat sniffs the format of an open file and rewinds it; the
+// extension of path decides only when the contents are ambiguous
+func detectFormat(file io.ReadSeeker, path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	// Assertion 1: Rewind so the decoder sees the whole file
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	sniffed := SniffFormat(header[:n])
+	switch {
+	case sniffed == "":
+		return ext, nil
+	case sniffed == ".tiff" && (ext == ".dng" || ext == ".nef" || ext == ".cr2"):
+		// DNG and NEF share the plain TIFF signature
+		return ext, nil
+	default:
+		return sniffed, nil
+	}
+}
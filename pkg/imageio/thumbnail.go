@@ -0,0 +1,171 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"github.com/kasurarykerion/golangresizer/internal/transform"
+)
+
+const (
+	// exifThumbnailSide bounds the longer side of a regenerated thumbnail
+	exifThumbnailSide = 160
+	// exifThumbnailQuality keeps the thumbnail a few kilobytes
+	exifThumbnailQuality = 75
+)
+
+// WithoutThumbnail returns a copy of m whose EXIF block carries no
+// thumbnail; the old preview is cut off or zeroed, not just unlinked
+func (m Metadata) WithoutThumbnail() Metadata {
+	if m.EXIF != nil {
+		m.EXIF, _ = exifWithoutThumbnail(m.EXIF)
+	}
+
+	return m
+}
+
+// exifWithoutThumbnail returns a copy of exif without its second
+// directory, reporting whether that directory held a JPEG thumbnail
+// Thumbnail data ending the block is cut off, anything else is zeroed
+func exifWithoutThumbnail(exif []byte) ([]byte, bool) {
+	exif = bytes.Clone(exif)
+
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return exif, false
+	}
+
+	_, next, err := file.readIFD(first)
+	if err != nil || next == 0 {
+		return exif, false
+	}
+
+	// Unlink the directory from the end of the first one
+	count := int(file.order.Uint16(exif[first:]))
+	file.order.PutUint32(exif[int(first)+2+12*count:], 0)
+
+	entries, _, err := file.readIFD(next)
+	if err != nil {
+		return exif, false
+	}
+
+	// Assertion 1: Blank the JPEG data or strips the directory points at
+	var regions [][2]uint32
+	offset, okOffset := file.uint(entries[tagJPEGOffset], 0)
+	length, okLength := file.uint(entries[tagJPEGLength], 0)
+	isJPEG := okOffset && okLength && length > 0
+	if isJPEG {
+		regions = append(regions, [2]uint32{offset, length})
+	}
+
+	strips := entries[tagStripOffsets]
+	for i := 0; i < int(strips.count) && i < rawMaxEntries; i++ {
+		at, okAt := file.uint(strips, i)
+		size, okSize := file.uint(entries[tagStripByteCounts], i)
+		if okAt && okSize {
+			regions = append(regions, [2]uint32{at, size})
+		}
+	}
+
+	end := len(exif)
+	for _, region := range regions {
+		from, to := uint64(region[0]), uint64(region[0])+uint64(region[1])
+		if to > uint64(len(exif)) {
+			continue
+		}
+
+		clear(exif[from:to])
+		// A region ending the block, give or take a pad byte, is cut off
+		if to+1 >= uint64(len(exif)) {
+			end = min(end, int(from))
+		}
+	}
+
+	// Blank the directory's own values and entries, which readIFD bounded
+	for _, entry := range entries {
+		clear(entry.value)
+	}
+	entryCount := int(file.order.Uint16(exif[next:]))
+	clear(exif[int(next) : int(next)+2+12*entryCount+4])
+
+	return exif[:end], isJPEG
+}
+
+// exifWithThumbnail returns a copy of exif whose JPEG thumbnail, when it
+// has one, is replaced by one made from img; other thumbnails are dropped
+func exifWithThumbnail(exif []byte, img image.Image) ([]byte, error) {
+	exif, isJPEG := exifWithoutThumbnail(exif)
+	if !isJPEG {
+		return exif, nil
+	}
+
+	thumb, err := thumbnailJPEG(img)
+	if err != nil {
+		return nil, err
+	}
+
+	file, first, err := openTIFF(exif)
+	if err != nil {
+		return exif, nil
+	}
+
+	if len(exif)%2 == 1 {
+		exif = append(exif, 0)
+	}
+
+	// Compression 6 marks an old style JPEG thumbnail, which follows its
+	// three entry directory
+	ifdAt := len(exif)
+	fields := [][3]uint32{
+		{tagCompression, tiffShort, 6},
+		{tagJPEGOffset, tiffLong, uint32(ifdAt + 2 + 12*3 + 4)},
+		{tagJPEGLength, tiffLong, uint32(len(thumb))},
+	}
+
+	ifd := make([]byte, 2+12*len(fields)+4)
+	file.order.PutUint16(ifd, uint16(len(fields)))
+	for i, field := range fields {
+		record := ifd[2+12*i:]
+		file.order.PutUint16(record[0:], uint16(field[0]))
+		file.order.PutUint16(record[2:], uint16(field[1]))
+		file.order.PutUint32(record[4:], 1)
+		if field[1] == tiffShort {
+			file.order.PutUint16(record[8:], uint16(field[2]))
+		} else {
+			file.order.PutUint32(record[8:], field[2])
+		}
+	}
+
+	exif = append(exif, ifd...)
+	exif = append(exif, thumb...)
+
+	count := int(file.order.Uint16(exif[first:]))
+	file.order.PutUint32(exif[int(first)+2+12*count:], uint32(ifdAt))
+	return exif, nil
+}
+
+// thumbnailJPEG encodes img shrunk by a whole factor to fit
+// exifThumbnailSide
+func thumbnailJPEG(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	side := max(bounds.Dx(), bounds.Dy())
+	factor := (side + exifThumbnailSide - 1) / exifThumbnailSide
+
+	if factor > 1 {
+		shrunk, err := transform.Shrink(img, factor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: thumbnail: %v", ErrEncode, err)
+		}
+		img = shrunk
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: exifThumbnailQuality}); err != nil {
+		return nil, fmt.Errorf("%w: thumbnail: %v", ErrEncode, err)
+	}
+
+	return buf.Bytes(), nil
+}
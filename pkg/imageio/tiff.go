@@ -0,0 +1,541 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"strings"
+)
+
+// tiffStripBytes is the uncompressed size of the strips encodeTIFF
+// writes, small enough for TIFFReader to stream the file back
+const tiffStripBytes = 64 << 10
+
+// TIFFCompression selects how TIFF output compresses its strips
+type TIFFCompression int
+
+const (
+	TIFFDeflate      TIFFCompression = iota // zlib, the default
+	TIFFUncompressed                        // Raw samples
+	TIFFLZW                                 // LZW with TIFF's early code change
+	TIFFJPEG                                // Lossy JPEG-in-TIFF, drops alpha
+)
+
+// tiffCompressionNames maps flag values to compression schemes
+var tiffCompressionNames = map[string]TIFFCompression{
+	"deflate": TIFFDeflate,
+	"zip":     TIFFDeflate,
+	"none":    TIFFUncompressed,
+	"lzw":     TIFFLZW,
+	"jpeg":    TIFFJPEG,
+	"jpg":     TIFFJPEG,
+}
+
+// TIFF tags and field types written besides those raw.go reads
+const (
+	tagImageWidth       = 256
+	tagImageLength      = 257
+	tagBitsPerSample    = 258
+	tagPhotometric      = 262
+	tagSamplesPerPixel  = 277
+	tagRowsPerStrip     = 278
+	tagXResolution      = 282
+	tagYResolution      = 283
+	tagPlanarConfig     = 284
+	tagResolutionUnit   = 296
+	tagPredictor        = 317
+	tagColorMap         = 320
+	tagExtraSamples     = 338
+	tagYCbCrSubSampling = 530
+
+	tiffByte      = 1
+	tiffASCII     = 2
+	tiffShort     = 3
+	tiffLong      = 4
+	tiffRational  = 5
+	tiffUndefined = 7
+)
+
+// tiffCompressionCodes are the values of the Compression tag
+var tiffCompressionCodes = map[TIFFCompression]uint32{
+	TIFFUncompressed: 1,
+	TIFFLZW:          5,
+	TIFFJPEG:         7,
+	TIFFDeflate:      8,
+}
+
+// ParseTIFFCompression turns a name such as "lzw" into a compression
+// scheme
+func ParseTIFFCompression(name string) (TIFFCompression, error) {
+	compression, ok := tiffCompressionNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown TIFF compression %q (want none, lzw, deflate or jpeg)", ErrUnsupportedFormat, name)
+	}
+
+	return compression, nil
+}
+
+// Predictable reports whether the horizontal predictor can be combined
+// with the scheme, which holds for the lossless compressors
+func (c TIFFCompression) Predictable() bool {
+	return c == TIFFDeflate || c == TIFFLZW
+}
+
+// tiffField is one IFD field to write; rationals hold numerator and
+// denominator pairs, and byte fields one byte per value
+type tiffField struct {
+	tag    uint16
+	kind   uint16
+	values []uint32
+}
+
+// tiffLayout describes how the samples of an image are stored
+type tiffLayout struct {
+	photometric uint32
+	samples     int
+	bits        int
+	alpha       bool
+	palette     color.Palette
+}
+
+// encodeTIFF writes img as a TIFF of tiffStripBytes strips compressed as
+// opts asks, differencing samples horizontally first for the predictor;
+// quality applies to JPEG compression only, which writes one strip, and
+// the XMP and IPTC blocks of meta are recorded in the directory
+// 16-bit images keep 16-bit samples and alpha is stored unassociated
+func encodeTIFF(w io.Writer, img image.Image, opts TIFFOptions, quality int, meta Metadata) error {
+	compression, predictor := opts.Compression, opts.Predictor
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Assertion 1: The predictor only pairs with lossless compressors
+	if predictor && !compression.Predictable() {
+		return fmt.Errorf("TIFF predictor needs LZW or Deflate compression")
+	}
+
+	var layout tiffLayout
+	var strips [][]byte
+	rowsPerStrip := height
+
+	if compression == TIFFJPEG {
+		layout = tiffJPEGLayout(img)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return err
+		}
+		strips = [][]byte{buf.Bytes()}
+	} else {
+		layout = tiffLayoutOf(img)
+		raw := tiffSamples(img, layout)
+
+		if predictor {
+			tiffDifference(raw, width, layout.samples, layout.bits)
+		}
+
+		rowBytes := width * layout.samples * layout.bits / 8
+		rowsPerStrip = max(1, tiffStripBytes/rowBytes)
+
+		// Each strip holds at least one row, so height strips cover the image
+		for top := 0; top < height; top += rowsPerStrip {
+			bottom := min(top+rowsPerStrip, height)
+
+			// Assertion 2: Check the strip compression
+			strip, err := tiffCompress(raw[top*rowBytes:bottom*rowBytes], compression)
+			if err != nil {
+				return err
+			}
+			strips = append(strips, strip)
+		}
+	}
+
+	// Assertion 3: Classic TIFF addresses 4 GiB
+	offsets, counts, ifdOffset, err := tiffStripOffsets(strips)
+	if err != nil {
+		return err
+	}
+
+	bitsPerSample := make([]uint32, layout.samples)
+	for i := range bitsPerSample {
+		bitsPerSample[i] = uint32(layout.bits)
+	}
+
+	entries := []tiffField{
+		{tagImageWidth, tiffLong, []uint32{uint32(width)}},
+		{tagImageLength, tiffLong, []uint32{uint32(height)}},
+		{tagBitsPerSample, tiffShort, bitsPerSample},
+		{tagCompression, tiffShort, []uint32{tiffCompressionCodes[compression]}},
+		{tagPhotometric, tiffShort, []uint32{layout.photometric}},
+		{tagStripOffsets, tiffLong, offsets},
+		{tagSamplesPerPixel, tiffShort, []uint32{uint32(layout.samples)}},
+		{tagRowsPerStrip, tiffLong, []uint32{uint32(rowsPerStrip)}},
+		{tagStripByteCounts, tiffLong, counts},
+		{tagXResolution, tiffRational, []uint32{72, 1}},
+		{tagYResolution, tiffRational, []uint32{72, 1}},
+		{tagPlanarConfig, tiffShort, []uint32{1}},
+		// Resolution is in inches
+		{tagResolutionUnit, tiffShort, []uint32{2}},
+	}
+
+	if predictor {
+		entries = append(entries, tiffField{tagPredictor, tiffShort, []uint32{2}})
+	}
+
+	if layout.palette != nil {
+		entries = append(entries, tiffField{tagColorMap, tiffShort, tiffColorMap(layout.palette)})
+	}
+
+	if layout.alpha {
+		// Unassociated alpha
+		entries = append(entries, tiffField{tagExtraSamples, tiffShort, []uint32{2}})
+	}
+
+	if layout.photometric == 6 {
+		// The JPEG encoder subsamples chroma 4:2:0
+		entries = append(entries, tiffField{tagYCbCrSubSampling, tiffShort, []uint32{2, 2}})
+	}
+
+	// The metadata tags sort after all the others
+	entries = append(entries, tiffMetadataFields(meta)...)
+
+	return writeTIFF(w, strips, ifdOffset, entries)
+}
+
+// tiffStripOffsets places strips one after the other past the 8 byte
+// header, each on a word boundary, returning their offsets and byte
+// counts and the offset of the IFD that follows them
+func tiffStripOffsets(strips [][]byte) ([]uint32, []uint32, int64, error) {
+	offsets := make([]uint32, 0, len(strips))
+	counts := make([]uint32, 0, len(strips))
+	end := int64(8)
+
+	for _, strip := range strips {
+		// Assertion 1: Every strip must be addressable
+		if end+int64(len(strip))+1 > math.MaxUint32 {
+			return nil, nil, 0, fmt.Errorf("%w: output exceeds the 4 GiB limit of TIFF", ErrTooLarge)
+		}
+
+		offsets = append(offsets, uint32(end))
+		counts = append(counts, uint32(len(strip)))
+		end += int64(len(strip) + len(strip)%2)
+	}
+
+	return offsets, counts, end, nil
+}
+
+// writeTIFF writes a little-endian header, the strips laid out by
+// tiffStripOffsets, and one IFD at ifdOffset whose entries must be sorted
+// by tag
+func writeTIFF(w io.Writer, strips [][]byte, ifdOffset int64, entries []tiffField) error {
+	le := binary.LittleEndian
+	out := bufio.NewWriter(w)
+
+	// Assertion 1: The directory must be addressable too
+	ifd := appendIFD(nil, int(ifdOffset), entries)
+	if ifdOffset+int64(len(ifd)) > math.MaxUint32 {
+		return fmt.Errorf("%w: output exceeds the 4 GiB limit of TIFF", ErrTooLarge)
+	}
+
+	header := make([]byte, 8)
+	copy(header, "II*\x00")
+	le.PutUint32(header[4:], uint32(ifdOffset))
+	out.Write(header)
+
+	for _, strip := range strips {
+		out.Write(strip)
+		if len(strip)%2 == 1 {
+			out.WriteByte(0)
+		}
+	}
+
+	out.Write(ifd)
+
+	return out.Flush()
+}
+
+// appendIFD appends a little-endian IFD that starts at file offset
+// ifdOffset, followed by the values too large for their entries; entries
+// must be sorted by tag
+func appendIFD(dst []byte, ifdOffset int, entries []tiffField) []byte {
+	le := binary.LittleEndian
+	// Values too large for an entry go after the IFD
+	overflowOffset := ifdOffset + 2 + 12*len(entries) + 4
+
+	ifd := le.AppendUint16(dst, uint16(len(entries)))
+	le.PutUint16(ifd, uint16(len(entries)))
+	var overflow []byte
+
+	for _, entry := range entries {
+		var value []byte
+		count := len(entry.values)
+
+		switch entry.kind {
+		case tiffByte, tiffUndefined:
+			for _, v := range entry.values {
+				value = append(value, byte(v))
+			}
+		case tiffShort:
+			for _, v := range entry.values {
+				value = le.AppendUint16(value, uint16(v))
+			}
+		case tiffRational:
+			count /= 2
+			fallthrough
+		default:
+			for _, v := range entry.values {
+				value = le.AppendUint32(value, v)
+			}
+		}
+
+		ifd = le.AppendUint16(ifd, entry.tag)
+		ifd = le.AppendUint16(ifd, entry.kind)
+		ifd = le.AppendUint32(ifd, uint32(count))
+
+		if len(value) <= 4 {
+			ifd = append(ifd, value...)
+			ifd = append(ifd, make([]byte, 4-len(value))...)
+			continue
+		}
+
+		ifd = le.AppendUint32(ifd, uint32(overflowOffset+len(overflow)))
+		overflow = append(overflow, value...)
+		// Keep the next value on a word boundary
+		if len(value)%2 == 1 {
+			overflow = append(overflow, 0)
+		}
+	}
+
+	// No further IFDs
+	ifd = le.AppendUint32(ifd, 0)
+
+	return append(ifd, overflow...)
+}
+
+// tiffLayoutOf picks the sample layout that keeps the precision and
+// palette of img, dropping alpha when the image is opaque
+func tiffLayoutOf(img image.Image) tiffLayout {
+	opaque := false
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		opaque = o.Opaque()
+	}
+
+	switch m := img.(type) {
+	case *image.Paletted:
+		return tiffLayout{photometric: 3, samples: 1, bits: 8, palette: m.Palette}
+	case *image.Gray:
+		return tiffLayout{photometric: 1, samples: 1, bits: 8}
+	case *image.Gray16:
+		return tiffLayout{photometric: 1, samples: 1, bits: 16}
+	}
+
+	layout := tiffLayout{photometric: 2, samples: 4, bits: 8, alpha: true}
+	if isDeep(img.ColorModel()) {
+		layout.bits = 16
+	}
+
+	if opaque {
+		layout.samples, layout.alpha = 3, false
+	}
+
+	return layout
+}
+
+// tiffJPEGLayout mirrors what image/jpeg writes: one gray component for
+// gray images, subsampled YCbCr for everything else
+func tiffJPEGLayout(img image.Image) tiffLayout {
+	if _, ok := img.(*image.Gray); ok {
+		return tiffLayout{photometric: 1, samples: 1, bits: 8}
+	}
+
+	return tiffLayout{photometric: 6, samples: 3, bits: 8}
+}
+
+// tiffSamples returns the pixels of img as interleaved little-endian
+// samples in layout
+func tiffSamples(img image.Image, layout tiffLayout) []byte {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	bytesPerSample := layout.bits / 8
+	raw := make([]byte, 0, width*bounds.Dy()*layout.samples*bytesPerSample)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			switch {
+			case layout.palette != nil:
+				raw = append(raw, img.(*image.Paletted).ColorIndexAt(x, y))
+			case layout.photometric == 1 && layout.bits == 8:
+				raw = append(raw, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			case layout.photometric == 1:
+				raw = binary.LittleEndian.AppendUint16(raw, color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)
+			case layout.bits == 8:
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				raw = append(raw, c.R, c.G, c.B, c.A)
+			default:
+				c := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+				raw = binary.LittleEndian.AppendUint16(raw, c.R)
+				raw = binary.LittleEndian.AppendUint16(raw, c.G)
+				raw = binary.LittleEndian.AppendUint16(raw, c.B)
+				raw = binary.LittleEndian.AppendUint16(raw, c.A)
+			}
+
+			// Opaque color images store no alpha sample
+			if layout.photometric == 2 && !layout.alpha {
+				raw = raw[:len(raw)-bytesPerSample]
+			}
+		}
+	}
+
+	return raw
+}
+
+// tiffDifference applies the horizontal predictor in place, replacing each
+// sample by its difference from the same sample of the previous pixel
+func tiffDifference(raw []byte, width, samples, bits int) {
+	rowSize := width * samples * bits / 8
+	le := binary.LittleEndian
+
+	for row := 0; row+rowSize <= len(raw); row += rowSize {
+		line := raw[row : row+rowSize]
+
+		// Walk backwards so every sample still sees its original neighbour
+		if bits == 8 {
+			for i := len(line) - 1; i >= samples; i-- {
+				line[i] -= line[i-samples]
+			}
+			continue
+		}
+
+		for i := len(line)/2 - 1; i >= samples; i-- {
+			le.PutUint16(line[2*i:], le.Uint16(line[2*i:])-le.Uint16(line[2*(i-samples):]))
+		}
+	}
+}
+
+// tiffCompress compresses a strip with one of the lossless schemes
+func tiffCompress(raw []byte, compression TIFFCompression) ([]byte, error) {
+	switch compression {
+	case TIFFUncompressed:
+		return raw, nil
+	case TIFFLZW:
+		return tiffLZW(raw), nil
+	default:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(raw); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// tiffColorMap expands a palette into the ColorMap layout: all reds, then
+// all greens, then all blues, for 256 entries
+func tiffColorMap(palette color.Palette) []uint32 {
+	colorMap := make([]uint32, 3*256)
+
+	for i := 0; i < len(palette) && i < 256; i++ {
+		r, g, b, _ := palette[i].RGBA()
+		colorMap[i], colorMap[256+i], colorMap[512+i] = r, g, b
+	}
+
+	return colorMap
+}
+
+// TIFF LZW code space
+const (
+	lzwClear    = 256
+	lzwEOI      = 257
+	lzwFirst    = 258
+	lzwMaxWidth = 12
+	// The table restarts two codes early so the decoder never grows past
+	// 12 bits
+	lzwLimit = 1<<lzwMaxWidth - 2
+)
+
+// lzwWriter packs codes most significant bit first
+type lzwWriter struct {
+	out   []byte
+	bits  uint32
+	nBits uint
+	width uint
+}
+
+// write appends one code of the current width
+func (l *lzwWriter) write(code int) {
+	l.bits = l.bits<<l.width | uint32(code)
+	l.nBits += l.width
+
+	for l.nBits >= 8 {
+		l.nBits -= 8
+		l.out = append(l.out, byte(l.bits>>l.nBits))
+	}
+}
+
+// tiffLZW compresses raw with TIFF's variant of LZW, which widens codes
+// one entry before the table fills
+func tiffLZW(raw []byte) []byte {
+	l := &lzwWriter{width: 9}
+	table := make(map[uint32]int, lzwLimit)
+	next := lzwFirst
+
+	l.write(lzwClear)
+	if len(raw) == 0 {
+		l.write(lzwEOI)
+		return l.flush()
+	}
+
+	// grow accounts for the entry the decoder adds after each code
+	grow := func() {
+		next++
+		if next+1 > 1<<l.width && l.width < lzwMaxWidth {
+			l.width++
+		}
+	}
+
+	prefix := int(raw[0])
+	for i := 1; i < len(raw); i++ {
+		key := uint32(prefix)<<8 | uint32(raw[i])
+		if code, ok := table[key]; ok {
+			prefix = code
+			continue
+		}
+
+		l.write(prefix)
+		table[key] = next
+		grow()
+
+		if next >= lzwLimit {
+			l.write(lzwClear)
+			clear(table)
+			next, l.width = lzwFirst, 9
+		}
+
+		prefix = int(raw[i])
+	}
+
+	l.write(prefix)
+	grow()
+	l.write(lzwEOI)
+
+	return l.flush()
+}
+
+// flush pads the final partial byte with zeros
+func (l *lzwWriter) flush() []byte {
+	if l.nBits > 0 {
+		l.out = append(l.out, byte(l.bits<<(8-l.nBits)))
+	}
+
+	return l.out
+}
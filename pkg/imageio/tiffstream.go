@@ -0,0 +1,657 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+
+	"golang.org/x/image/tiff/lzw"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+)
+
+// TIFF tags read by the streaming decoder besides those above
+const (
+	tagTileWidth      = 322
+	tagTileLength     = 323
+	tagTileOffsets    = 324
+	tagTileByteCounts = 325
+	tagSampleFormat   = 339
+)
+
+const (
+	// tiffMaxChunks bounds the strips or tiles of a streamed image
+	tiffMaxChunks = 1 << 22
+	// tiffMaxValueSize bounds one directory value read from disk
+	tiffMaxValueSize = 4 * tiffMaxChunks
+)
+
+// ExtraSamples values of the alpha channel
+const (
+	tiffAssociated   = 1
+	tiffUnassociated = 2
+)
+
+// TIFFReader decodes the first image of a TIFF file one strip, or one
+// row of tiles, at a time, so images larger than memory can be resized
+// as a stream
+// Chunky 8 and 16-bit gray and RGB images, with or without alpha, are
+// read uncompressed or with LZW, Deflate or PackBits compression; bands
+// have the types tiff.Decode returns for the same file
+type TIFFReader struct {
+	file        *os.File
+	tiff        tiffFile
+	width       int
+	height      int
+	samples     int
+	bits        int
+	gray        bool
+	alpha       uint32 // ExtraSamples value, zero without alpha
+	compression uint32
+	predictor   bool
+	offsets     []uint32
+	counts      []uint32
+	chunkWidth  int // Tile width, the image width for strips
+	chunkHeight int // Tile height or rows per strip
+	orientation int
+	next        int // First row of the next band
+}
+
+// OpenTIFF opens path for reading as a stream; unlike LoadImage it
+// accepts files of any size, as only one band is held at a time
+func OpenTIFF(path string) (*TIFFReader, error) {
+	// Assertion 1: Validate path
+	if err := validator.ValidatePath(path); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFileOpen, err)
+	}
+
+	t, err := readTIFFLayout(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	t.file = file
+	return t, nil
+}
+
+// readTIFFLayout reads the first directory of r and checks the reader can
+// stream what it describes
+func readTIFFLayout(r io.ReaderAt) (*TIFFReader, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	file, first, err := openTIFF(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a TIFF file", ErrUnsupportedFormat)
+	}
+
+	entries, err := readIFDAt(r, file.order, first)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	value := func(tag uint16, fallback uint32) uint32 {
+		if v, ok := file.uint(entries[tag], 0); ok {
+			return v
+		}
+		return fallback
+	}
+
+	t := &TIFFReader{
+		tiff:        *file,
+		width:       int(value(tagImageWidth, 0)),
+		height:      int(value(tagImageLength, 0)),
+		samples:     int(value(tagSamplesPerPixel, 1)),
+		bits:        int(value(tagBitsPerSample, 1)),
+		alpha:       value(tagExtraSamples, 0),
+		compression: value(tagCompression, 1),
+		predictor:   value(tagPredictor, 1) == 2,
+		orientation: int(value(tagOrientation, 1)),
+	}
+
+	// Assertion 1: Validate the size
+	if err := validator.ValidateDimensions(t.width, t.height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	// Assertion 2: Only the layouts named on TIFFReader stream
+	photometric := value(tagPhotometric, math.MaxUint32)
+	switch {
+	case value(tagPlanarConfig, 1) != 1:
+		return nil, fmt.Errorf("%w: planar TIFF cannot be streamed", ErrUnsupportedFormat)
+	case value(tagSampleFormat, 1) != 1:
+		return nil, fmt.Errorf("%w: only unsigned integer TIFF samples can be streamed", ErrUnsupportedFormat)
+	case t.bits != 8 && t.bits != 16:
+		return nil, fmt.Errorf("%w: %d-bit TIFF cannot be streamed", ErrUnsupportedFormat, t.bits)
+	case value(tagPredictor, 1) > 2:
+		return nil, fmt.Errorf("%w: floating point predictor cannot be streamed", ErrUnsupportedFormat)
+	case photometric == 1 && t.samples == 1:
+		t.gray, t.alpha = true, 0
+	case photometric == 2 && t.samples == 3:
+		t.alpha = 0
+	case photometric == 2 && t.samples == 4 && (t.alpha == tiffAssociated || t.alpha == tiffUnassociated):
+	default:
+		return nil, fmt.Errorf("%w: photometric %d with %d samples cannot be streamed", ErrUnsupportedFormat, photometric, t.samples)
+	}
+
+	switch t.compression {
+	case 1, 5, 8, 32773, 32946:
+	default:
+		return nil, fmt.Errorf("%w: TIFF compression %d cannot be streamed", ErrUnsupportedFormat, t.compression)
+	}
+
+	for i := 1; i < t.samples; i++ {
+		if bits, ok := file.uint(entries[tagBitsPerSample], i); ok && int(bits) != t.bits {
+			return nil, fmt.Errorf("%w: mixed sample sizes cannot be streamed", ErrUnsupportedFormat)
+		}
+	}
+
+	if err := t.readChunks(entries); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// readChunks reads the strip or tile geometry and the chunk offsets
+func (t *TIFFReader) readChunks(entries map[uint16]tiffEntry) error {
+	offsetTag, countTag := uint16(tagStripOffsets), uint16(tagStripByteCounts)
+	t.chunkWidth, t.chunkHeight = t.width, t.height
+
+	if _, tiled := entries[tagTileWidth]; tiled {
+		tileWidth, _ := t.tiff.uint(entries[tagTileWidth], 0)
+		tileHeight, _ := t.tiff.uint(entries[tagTileLength], 0)
+		t.chunkWidth, t.chunkHeight = int(tileWidth), int(tileHeight)
+		offsetTag, countTag = tagTileOffsets, tagTileByteCounts
+	} else if rows, ok := t.tiff.uint(entries[tagRowsPerStrip], 0); ok && int64(rows) < int64(t.height) {
+		t.chunkHeight = int(rows)
+	}
+
+	// Assertion 1: Chunks are non-empty and no wider than the image allows
+	if t.chunkWidth <= 0 || t.chunkHeight <= 0 || t.chunkWidth > validator.MaxImageDimension || t.chunkHeight > validator.MaxImageDimension {
+		return fmt.Errorf("%w: invalid %dx%d TIFF chunks", ErrDecode, t.chunkWidth, t.chunkHeight)
+	}
+
+	across := (t.width + t.chunkWidth - 1) / t.chunkWidth
+	down := (t.height + t.chunkHeight - 1) / t.chunkHeight
+	chunks := across * down
+
+	offsets, counts := entries[offsetTag], entries[countTag]
+	if chunks > tiffMaxChunks || int(offsets.count) < chunks || int(counts.count) < chunks {
+		return fmt.Errorf("%w: TIFF lists fewer chunks than its %dx%d layout needs", ErrDecode, across, down)
+	}
+
+	t.offsets = make([]uint32, chunks)
+	t.counts = make([]uint32, chunks)
+	for i := 0; i < chunks; i++ {
+		offset, ok1 := t.tiff.uint(offsets, i)
+		count, ok2 := t.tiff.uint(counts, i)
+		if !ok1 || !ok2 {
+			return fmt.Errorf("%w: unreadable chunk table", ErrDecode)
+		}
+
+		t.offsets[i], t.counts[i] = offset, count
+	}
+
+	return nil
+}
+
+// readIFDAt reads the directory at offset of a file too large to load,
+// reading values stored elsewhere up to tiffMaxValueSize bytes each
+func readIFDAt(r io.ReaderAt, order binary.ByteOrder, offset uint32) (map[uint16]tiffEntry, error) {
+	countBytes := make([]byte, 2)
+	if _, err := r.ReadAt(countBytes, int64(offset)); err != nil {
+		return nil, fmt.Errorf("directory offset out of range")
+	}
+
+	// Assertion 1: Bound the entries
+	count := int(order.Uint16(countBytes))
+	if count > rawMaxEntries {
+		return nil, fmt.Errorf("directory too large")
+	}
+
+	table := make([]byte, 12*count)
+	if _, err := r.ReadAt(table, int64(offset)+2); err != nil {
+		return nil, fmt.Errorf("directory overruns the file")
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		b := table[12*i : 12*i+12]
+		entry := tiffEntry{kind: order.Uint16(b[2:4]), count: order.Uint32(b[4:8])}
+
+		// Values of four bytes or less are stored in the entry itself
+		size := uint64(tiffTypeSize(entry.kind)) * uint64(entry.count)
+		switch {
+		case size == 0 || size > tiffMaxValueSize:
+			continue
+		case size <= 4:
+			entry.value = b[8 : 8+size]
+		default:
+			entry.value = make([]byte, size)
+			if _, err := r.ReadAt(entry.value, int64(order.Uint32(b[8:12]))); err != nil {
+				continue
+			}
+		}
+
+		entries[order.Uint16(b[0:2])] = entry
+	}
+
+	return entries, nil
+}
+
+// Bounds returns the size of the whole image
+func (t *TIFFReader) Bounds() image.Rectangle {
+	return image.Rect(0, 0, t.width, t.height)
+}
+
+// ColorModel returns the model of the bands
+func (t *TIFFReader) ColorModel() color.Model {
+	deep := t.bits == 16
+
+	switch {
+	case t.gray && deep:
+		return color.Gray16Model
+	case t.gray:
+		return color.GrayModel
+	case t.alpha == tiffUnassociated && deep:
+		return color.NRGBA64Model
+	case t.alpha == tiffUnassociated:
+		return color.NRGBAModel
+	case deep:
+		return color.RGBA64Model
+	default:
+		return color.RGBAModel
+	}
+}
+
+// Orientation returns the Orientation tag, 1 when the rows are stored
+// upright
+func (t *TIFFReader) Orientation() int {
+	return t.orientation
+}
+
+// Opaque reports whether the image stores no alpha channel
+func (t *TIFFReader) Opaque() bool {
+	return t.alpha == 0
+}
+
+// BandHeight returns the rows of every band but the last, which may be
+// shorter
+func (t *TIFFReader) BandHeight() int {
+	return t.chunkHeight
+}
+
+// Next decodes the next band of rows, whose bounds give its place in the
+// image, and returns io.EOF after the last one
+func (t *TIFFReader) Next() (image.Image, error) {
+	if t.next >= t.height {
+		return nil, io.EOF
+	}
+
+	top := t.next
+	bottom := min(top+t.chunkHeight, t.height)
+	band := t.newBand(image.Rect(0, top, t.width, bottom))
+
+	// Strips end with the image, tiles are always whole
+	rows := t.chunkHeight
+	if t.chunkWidth == t.width {
+		rows = bottom - top
+	}
+
+	pixelBytes := t.samples * t.bits / 8
+	raw := make([]byte, t.chunkWidth*rows*pixelBytes)
+	across := (t.width + t.chunkWidth - 1) / t.chunkWidth
+
+	for i := 0; i < across; i++ {
+		if err := t.readChunk((top/t.chunkHeight)*across+i, raw); err != nil {
+			return nil, fmt.Errorf("%w: rows %d-%d: %v", ErrDecode, top, bottom, err)
+		}
+
+		if t.predictor {
+			t.undoDifference(raw)
+		}
+
+		left := i * t.chunkWidth
+		for y := top; y < bottom; y++ {
+			t.storeRow(band, raw[(y-top)*t.chunkWidth*pixelBytes:], left, min(t.chunkWidth, t.width-left), y)
+		}
+	}
+
+	t.next = bottom
+	return band, nil
+}
+
+// Close closes the file
+func (t *TIFFReader) Close() error {
+	return t.file.Close()
+}
+
+// newBand allocates a band of rect in the model of the image
+func (t *TIFFReader) newBand(rect image.Rectangle) image.Image {
+	switch t.ColorModel() {
+	case color.Gray16Model:
+		return image.NewGray16(rect)
+	case color.GrayModel:
+		return image.NewGray(rect)
+	case color.NRGBA64Model:
+		return image.NewNRGBA64(rect)
+	case color.NRGBAModel:
+		return image.NewNRGBA(rect)
+	case color.RGBA64Model:
+		return image.NewRGBA64(rect)
+	default:
+		return image.NewRGBA(rect)
+	}
+}
+
+// readChunk fills dst with the decompressed samples of strip or tile i
+func (t *TIFFReader) readChunk(i int, dst []byte) error {
+	var r io.Reader = io.NewSectionReader(t.file, int64(t.offsets[i]), int64(t.counts[i]))
+
+	switch t.compression {
+	case 5:
+		lr := lzw.NewReader(r, lzw.MSB, 8)
+		defer lr.Close()
+		r = lr
+	case 8, 32946:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case 32773:
+		// PackBits chunks are read whole, they are at most band sized
+		packed, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return unpackBits(packed, dst)
+	}
+
+	_, err := io.ReadFull(r, dst)
+	return err
+}
+
+// undoDifference reverses the horizontal predictor over the rows of one
+// chunk in place
+func (t *TIFFReader) undoDifference(raw []byte) {
+	rowSize := t.chunkWidth * t.samples * t.bits / 8
+
+	for row := 0; row+rowSize <= len(raw); row += rowSize {
+		line := raw[row : row+rowSize]
+
+		if t.bits == 8 {
+			for i := t.samples; i < len(line); i++ {
+				line[i] += line[i-t.samples]
+			}
+			continue
+		}
+
+		order := t.tiff.order
+		for i := t.samples; i < len(line)/2; i++ {
+			order.PutUint16(line[2*i:], order.Uint16(line[2*i:])+order.Uint16(line[2*(i-t.samples):]))
+		}
+	}
+}
+
+// storeRow copies width pixels of raw samples into row y of band, starting
+// at column left
+func (t *TIFFReader) storeRow(band image.Image, raw []byte, left, width, y int) {
+	order := t.tiff.order
+	sample := func(i int) uint16 {
+		if t.bits == 8 {
+			return uint16(raw[i])
+		}
+		return order.Uint16(raw[2*i:])
+	}
+
+	for x := 0; x < width; x++ {
+		i := x * t.samples
+
+		switch b := band.(type) {
+		case *image.Gray:
+			b.Pix[b.PixOffset(left+x, y)] = raw[i]
+		case *image.Gray16:
+			binary.BigEndian.PutUint16(b.Pix[b.PixOffset(left+x, y):], sample(i))
+		case *image.RGBA, *image.NRGBA:
+			pix, offset := pix8(b, left+x, y)
+			pix[offset], pix[offset+1], pix[offset+2], pix[offset+3] = raw[i], raw[i+1], raw[i+2], 0xFF
+			if t.samples == 4 {
+				pix[offset+3] = raw[i+3]
+			}
+		case *image.RGBA64, *image.NRGBA64:
+			pix, offset := pix16(b, left+x, y)
+			a := uint16(0xFFFF)
+			if t.samples == 4 {
+				a = sample(i + 3)
+			}
+
+			for c, v := range [4]uint16{sample(i), sample(i + 1), sample(i + 2), a} {
+				binary.BigEndian.PutUint16(pix[offset+2*c:], v)
+			}
+		}
+	}
+}
+
+// pix8 returns the buffer and offset of pixel (x, y) of an 8-bit band
+func pix8(band image.Image, x, y int) ([]byte, int) {
+	if b, ok := band.(*image.NRGBA); ok {
+		return b.Pix, b.PixOffset(x, y)
+	}
+
+	b := band.(*image.RGBA)
+	return b.Pix, b.PixOffset(x, y)
+}
+
+// pix16 returns the buffer and offset of pixel (x, y) of a 16-bit band
+func pix16(band image.Image, x, y int) ([]byte, int) {
+	if b, ok := band.(*image.NRGBA64); ok {
+		return b.Pix, b.PixOffset(x, y)
+	}
+
+	b := band.(*image.RGBA64)
+	return b.Pix, b.PixOffset(x, y)
+}
+
+// TIFFWriter encodes a TIFF one band of rows at a time, each band one
+// strip, holding only the strip being compressed in memory
+type TIFFWriter struct {
+	file    *outputFile
+	out     *bufio.Writer
+	width   int
+	height  int
+	layout  tiffLayout
+	opts    TIFFOptions
+	offsets []uint32
+	counts  []uint32
+	strip   int   // Rows per strip, set by the first band
+	rows    int   // Rows written so far
+	size    int64 // Bytes written so far
+}
+
+// CreateTIFF creates path for a width x height image written band by band
+// with WriteRows; model and opaque pick the samples as SaveImage would for
+// an image of that model, and JPEG compression is not available
+// Every band but the last must have the height of the first
+func CreateTIFF(path string, width, height int, model color.Model, opaque bool, opts TIFFOptions) (*TIFFWriter, error) {
+	// Assertion 1: Validate the size and options
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+
+	if opts.Compression == TIFFJPEG {
+		return nil, fmt.Errorf("%w: JPEG compressed TIFF cannot be written as a stream", ErrEncode)
+	}
+
+	if opts.Predictor && !opts.Compression.Predictable() {
+		return nil, fmt.Errorf("TIFF predictor needs LZW or Deflate compression")
+	}
+
+	file, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &TIFFWriter{file: file, out: bufio.NewWriter(file), width: width, height: height, opts: opts, size: 8}
+	w.layout = tiffStreamLayout(model, opaque)
+
+	// The directory offset is filled in by Close
+	w.out.WriteString("II*\x00\x00\x00\x00\x00")
+	return w, nil
+}
+
+// tiffStreamLayout is tiffLayoutOf for an image known by its model
+func tiffStreamLayout(model color.Model, opaque bool) tiffLayout {
+	switch model {
+	case color.GrayModel:
+		return tiffLayout{photometric: 1, samples: 1, bits: 8}
+	case color.Gray16Model:
+		return tiffLayout{photometric: 1, samples: 1, bits: 16}
+	}
+
+	layout := tiffLayout{photometric: 2, samples: 4, bits: 8, alpha: true}
+	if isDeep(model) {
+		layout.bits = 16
+	}
+
+	if opaque {
+		layout.samples, layout.alpha = 3, false
+	}
+
+	return layout
+}
+
+// WriteRows compresses band as the next strip
+func (w *TIFFWriter) WriteRows(band image.Image) error {
+	rows := band.Bounds().Dy()
+
+	// Assertion 1: Bands continue the image in strips of one height
+	if band.Bounds().Dx() != w.width || rows <= 0 || w.rows+rows > w.height {
+		return fmt.Errorf("%w: band %v does not continue the %dx%d image at row %d", ErrEncode, band.Bounds(), w.width, w.height, w.rows)
+	}
+
+	if w.strip == 0 {
+		w.strip = rows
+	} else if rows > w.strip || rows < w.strip && w.rows+rows != w.height {
+		return fmt.Errorf("%w: strips must be %d rows high", ErrEncode, w.strip)
+	}
+
+	raw := tiffSamples(band, w.layout)
+	if w.opts.Predictor {
+		tiffDifference(raw, w.width, w.layout.samples, w.layout.bits)
+	}
+
+	strip, err := tiffCompress(raw, w.opts.Compression)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrEncode, err)
+	}
+
+	// Assertion 2: Classic TIFF addresses 4 GiB
+	if w.size+int64(len(strip))+1 > math.MaxUint32 {
+		return fmt.Errorf("%w: output exceeds the 4 GiB limit of TIFF", ErrTooLarge)
+	}
+
+	if _, err := w.out.Write(strip); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	w.offsets = append(w.offsets, uint32(w.size))
+	w.counts = append(w.counts, uint32(len(strip)))
+	w.size += int64(len(strip))
+	w.rows += rows
+
+	// Keep every strip on a word boundary
+	if w.size%2 == 1 {
+		w.out.WriteByte(0)
+		w.size++
+	}
+
+	return nil
+}
+
+// Close writes the directory and closes the file; it fails when fewer
+// rows than the image height were written, leaving no file at the path
+func (w *TIFFWriter) Close() error {
+	if err := w.finish(); err != nil {
+		w.file.abort()
+		return err
+	}
+
+	return w.file.commit()
+}
+
+// finish writes the directory and points the header at it
+func (w *TIFFWriter) finish() error {
+	// Assertion 1: Every row must be present
+	if w.rows != w.height {
+		return fmt.Errorf("%w: %d of %d rows written", ErrEncode, w.rows, w.height)
+	}
+
+	bitsPerSample := make([]uint32, w.layout.samples)
+	for i := range bitsPerSample {
+		bitsPerSample[i] = uint32(w.layout.bits)
+	}
+
+	entries := []tiffField{
+		{tagImageWidth, tiffLong, []uint32{uint32(w.width)}},
+		{tagImageLength, tiffLong, []uint32{uint32(w.height)}},
+		{tagBitsPerSample, tiffShort, bitsPerSample},
+		{tagCompression, tiffShort, []uint32{tiffCompressionCodes[w.opts.Compression]}},
+		{tagPhotometric, tiffShort, []uint32{w.layout.photometric}},
+		{tagStripOffsets, tiffLong, w.offsets},
+		{tagSamplesPerPixel, tiffShort, []uint32{uint32(w.layout.samples)}},
+		{tagRowsPerStrip, tiffLong, []uint32{uint32(w.strip)}},
+		{tagStripByteCounts, tiffLong, w.counts},
+		{tagXResolution, tiffRational, []uint32{72, 1}},
+		{tagYResolution, tiffRational, []uint32{72, 1}},
+		{tagPlanarConfig, tiffShort, []uint32{1}},
+		// Resolution is in inches
+		{tagResolutionUnit, tiffShort, []uint32{2}},
+	}
+
+	if w.opts.Predictor {
+		entries = append(entries, tiffField{tagPredictor, tiffShort, []uint32{2}})
+	}
+
+	if w.layout.alpha {
+		// Unassociated alpha
+		entries = append(entries, tiffField{tagExtraSamples, tiffShort, []uint32{2}})
+	}
+
+	ifd := appendIFD(nil, int(w.size), entries)
+	if w.size+int64(len(ifd)) > math.MaxUint32 {
+		return fmt.Errorf("%w: output exceeds the 4 GiB limit of TIFF", ErrTooLarge)
+	}
+
+	if _, err := w.out.Write(ifd); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	if err := w.out.Flush(); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	offset := binary.LittleEndian.AppendUint32(nil, uint32(w.size))
+	if _, err := w.file.WriteAt(offset, 4); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,409 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+)
+
+const (
+	// vp8lMaxDimension is the largest width or height a VP8L header can hold
+	vp8lMaxDimension = 1 << 14
+	// vp8lSignature starts every VP8L bitstream
+	vp8lSignature = 0x2f
+	// vp8lSubtractGreen is the transform type that stores red and blue
+	// relative to green
+	vp8lSubtractGreen = 2
+	// vp8lGreenAlphabet holds 256 literals and 24 length prefixes
+	vp8lGreenAlphabet = 256 + 24
+	// vp8lMaxCodeLength bounds pixel prefix codes
+	vp8lMaxCodeLength = 15
+	// vp8lMaxLengthCodeLength bounds the code that encodes code lengths
+	vp8lMaxLengthCodeLength = 7
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are stored in
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// bitWriter packs values least significant bit first, as VP8L expects
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+// write appends the low n bits of v
+func (w *bitWriter) write(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nBits
+	w.nBits += n
+
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nBits -= 8
+	}
+}
+
+// bytes flushes the partial byte and returns the stream
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nBits = 0, 0
+	}
+
+	return w.buf
+}
+
+// prefixCode is a canonical Huffman code with bit-reversed codes ready
+// for the LSB-first stream; a code with a single symbol uses no bits
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  bool
+}
+
+// writeSymbol appends the code for sym
+func (c *prefixCode) writeSymbol(w *bitWriter, sym int) {
+	if c.single {
+		return
+	}
+
+	w.write(uint32(c.codes[sym]), uint(c.lengths[sym]))
+}
+
+// encodeVP8L encodes img as a lossless VP8L bitstream using the subtract
+// green transform and one set of prefix codes for the whole image
+func encodeVP8L(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Assertion 1: The header stores each dimension in 14 bits
+	if width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return nil, fmt.Errorf("WebP is limited to %dx%d pixels", vp8lMaxDimension, vp8lMaxDimension)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	pix := nrgba.Pix
+
+	// Store red and blue relative to green and count every channel
+	var green [vp8lGreenAlphabet]int
+	var red, blue, alpha [256]int
+	alphaUsed := false
+
+	for i := 0; i < len(pix); i += 4 {
+		pix[i] -= pix[i+1]
+		pix[i+2] -= pix[i+1]
+
+		red[pix[i]]++
+		green[pix[i+1]]++
+		blue[pix[i+2]]++
+		alpha[pix[i+3]]++
+		alphaUsed = alphaUsed || pix[i+3] != 0xff
+	}
+
+	w := &bitWriter{}
+	w.write(vp8lSignature, 8)
+	w.write(uint32(width-1), 14)
+	w.write(uint32(height-1), 14)
+	w.write(boolBit(alphaUsed), 1)
+	w.write(0, 3)
+
+	// One subtract green transform, no color cache, no meta prefix codes
+	w.write(1, 1)
+	w.write(vp8lSubtractGreen, 2)
+	w.write(0, 1)
+	w.write(0, 1)
+	w.write(0, 1)
+
+	codes := [4]*prefixCode{
+		writePrefixCode(w, green[:]),
+		writePrefixCode(w, red[:]),
+		writePrefixCode(w, blue[:]),
+		writePrefixCode(w, alpha[:]),
+	}
+	writePrefixCode(w, make([]int, 40))
+
+	for i := 0; i < len(pix); i += 4 {
+		codes[0].writeSymbol(w, int(pix[i+1]))
+		codes[1].writeSymbol(w, int(pix[i]))
+		codes[2].writeSymbol(w, int(pix[i+2]))
+		codes[3].writeSymbol(w, int(pix[i+3]))
+	}
+
+	return w.bytes(), nil
+}
+
+// boolBit converts a flag into a single bit
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// writePrefixCode builds a code for the histogram and writes its
+// description, using the simple form when at most one symbol occurs
+func writePrefixCode(w *bitWriter, histogram []int) *prefixCode {
+	used, last := 0, 0
+	for sym := 0; sym < len(histogram); sym++ {
+		if histogram[sym] > 0 {
+			used++
+			last = sym
+		}
+	}
+
+	// Assertion 1: A lone symbol (always a literal below 256) needs no bits
+	if used <= 1 {
+		w.write(1, 1)
+		w.write(0, 1)
+
+		if last < 2 {
+			w.write(0, 1)
+			w.write(uint32(last), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(last), 8)
+		}
+
+		return &prefixCode{single: true}
+	}
+
+	code := newPrefixCode(histogram, vp8lMaxCodeLength)
+	w.write(0, 1)
+	writeCodeLengths(w, code.lengths)
+	return code
+}
+
+// codeLengthToken is one symbol of the code length alphabet with the
+// repeat count carried in its extra bits
+type codeLengthToken struct {
+	symbol    int
+	extra     uint32
+	extraBits uint
+}
+
+// writeCodeLengths run-length codes lengths with the code length alphabet
+// and writes that alphabet's own code followed by the tokens
+func writeCodeLengths(w *bitWriter, lengths []uint8) {
+	tokens := codeLengthTokens(lengths)
+
+	var histogram [19]int
+	for i := 0; i < len(tokens); i++ {
+		histogram[tokens[i].symbol]++
+	}
+
+	code := newPrefixCode(histogram[:], vp8lMaxLengthCodeLength)
+
+	count := 4
+	for i := 0; i < len(vp8lCodeLengthOrder); i++ {
+		if code.lengths[vp8lCodeLengthOrder[i]] > 0 {
+			count = max(count, i+1)
+		}
+	}
+
+	w.write(uint32(count-4), 4)
+	for i := 0; i < count; i++ {
+		w.write(uint32(code.lengths[vp8lCodeLengthOrder[i]]), 3)
+	}
+
+	// Every symbol of the alphabet is described, so no max_symbol
+	w.write(0, 1)
+
+	for i := 0; i < len(tokens); i++ {
+		code.writeSymbol(w, tokens[i].symbol)
+		w.write(tokens[i].extra, tokens[i].extraBits)
+	}
+}
+
+// codeLengthTokens replaces runs in lengths with the repeat codes 16
+// (previous length, 3-6 times), 17 (zero, 3-10) and 18 (zero, 11-138)
+func codeLengthTokens(lengths []uint8) []codeLengthToken {
+	tokens := make([]codeLengthToken, 0, len(lengths))
+
+	for i := 0; i < len(lengths); {
+		v, run := lengths[i], 1
+		for i+run < len(lengths) && lengths[i+run] == v {
+			run++
+		}
+		i += run
+
+		// Assertion 1: Zero runs use the dedicated zero repeats
+		if v == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, codeLengthToken{symbol: 18, extra: uint32(n - 11), extraBits: 7})
+				run -= n
+			}
+
+			if run >= 3 {
+				tokens = append(tokens, codeLengthToken{symbol: 17, extra: uint32(run - 3), extraBits: 3})
+				run = 0
+			}
+
+			for ; run > 0; run-- {
+				tokens = append(tokens, codeLengthToken{symbol: 0})
+			}
+
+			continue
+		}
+
+		tokens = append(tokens, codeLengthToken{symbol: int(v)})
+		run--
+
+		for run >= 3 {
+			n := min(run, 6)
+			tokens = append(tokens, codeLengthToken{symbol: 16, extra: uint32(n - 3), extraBits: 2})
+			run -= n
+		}
+
+		for ; run > 0; run-- {
+			tokens = append(tokens, codeLengthToken{symbol: int(v)})
+		}
+	}
+
+	return tokens
+}
+
+// newPrefixCode builds a canonical Huffman code for the histogram whose
+// lengths do not exceed limit
+func newPrefixCode(histogram []int, limit int) *prefixCode {
+	lengths := huffmanLengths(histogram, limit)
+
+	used := 0
+	for sym := 0; sym < len(lengths); sym++ {
+		if lengths[sym] > 0 {
+			used++
+		}
+	}
+
+	// Canonical codes as in DEFLATE, bit-reversed for the LSB-first writer
+	var count, next [vp8lMaxCodeLength + 2]int
+	for sym := 0; sym < len(lengths); sym++ {
+		count[lengths[sym]]++
+	}
+	count[0] = 0
+
+	code := 0
+	for n := 1; n <= vp8lMaxCodeLength; n++ {
+		code = (code + count[n-1]) << 1
+		next[n] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for sym := 0; sym < len(lengths); sym++ {
+		n := int(lengths[sym])
+		if n == 0 {
+			continue
+		}
+
+		codes[sym] = reverseBits(uint16(next[n]), n)
+		next[n]++
+	}
+
+	return &prefixCode{lengths: lengths, codes: codes, single: used <= 1}
+}
+
+// reverseBits reverses the low n bits of v
+func reverseBits(v uint16, n int) uint16 {
+	var r uint16
+	for i := 0; i < n; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+
+	return r
+}
+
+// huffmanNode is a leaf or merged subtree while building a code
+type huffmanNode struct {
+	weight int
+	parent int
+}
+
+// huffmanLengths returns Huffman code lengths for the histogram, flattening
+// small counts until no code is longer than limit
+func huffmanLengths(histogram []int, limit int) []uint8 {
+	lengths := make([]uint8, len(histogram))
+
+	symbols := make([]int, 0, len(histogram))
+	for sym := 0; sym < len(histogram); sym++ {
+		if histogram[sym] > 0 {
+			symbols = append(symbols, sym)
+		}
+	}
+
+	// Assertion 1: A lone symbol gets a nominal length of one
+	if len(symbols) <= 1 {
+		for i := 0; i < len(symbols); i++ {
+			lengths[symbols[i]] = 1
+		}
+		return lengths
+	}
+
+	// Raising the smallest counts evens out the tree; once all counts are
+	// equal the depth is log2 of the symbol count, well within any limit
+	for floor := 0; floor <= 1<<30; floor = max(1, floor*2) {
+		depths := huffmanDepths(histogram, symbols, floor)
+
+		deepest := 0
+		for i := 0; i < len(depths); i++ {
+			deepest = max(deepest, depths[i])
+		}
+
+		if deepest <= limit {
+			for i := 0; i < len(symbols); i++ {
+				lengths[symbols[i]] = uint8(depths[i])
+			}
+			return lengths
+		}
+	}
+
+	return lengths
+}
+
+// huffmanDepths builds a Huffman tree over the symbols, each weighted by
+// its count raised to at least floor, and returns the depth of each leaf
+func huffmanDepths(histogram, symbols []int, floor int) []int {
+	n := len(symbols)
+	nodes := make([]huffmanNode, n, 2*n-1)
+
+	order := make([]int, n)
+	for i := 0; i < n; i++ {
+		nodes[i] = huffmanNode{weight: max(histogram[symbols[i]], floor), parent: -1}
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return nodes[order[a]].weight < nodes[order[b]].weight
+	})
+
+	// Two queues: sorted leaves and merged nodes, which come out sorted too
+	leaf, merged := 0, n
+	pick := func() int {
+		if leaf < n && (merged >= len(nodes) || nodes[order[leaf]].weight <= nodes[merged].weight) {
+			leaf++
+			return order[leaf-1]
+		}
+		merged++
+		return merged - 1
+	}
+
+	for i := 0; i < n-1; i++ {
+		a, b := pick(), pick()
+		nodes = append(nodes, huffmanNode{weight: nodes[a].weight + nodes[b].weight, parent: -1})
+		nodes[a].parent = len(nodes) - 1
+		nodes[b].parent = len(nodes) - 1
+	}
+
+	// Parents are appended after their children, so walk back from the root
+	depth := make([]int, len(nodes))
+	for i := len(nodes) - 2; i >= 0; i-- {
+		depth[i] = depth[nodes[i].parent] + 1
+	}
+
+	return depth[:n]
+}
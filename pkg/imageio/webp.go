@@ -0,0 +1,309 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"time"
+
+	"github.com/kasurarykerion/golangresizer/internal/validator"
+	"golang.org/x/image/webp"
+)
+
+const (
+	// webpAnimationFlag marks a VP8X file as animated
+	webpAnimationFlag = 1 << 1
+	// webpAlphaFlag marks a VP8X file as having transparency
+	webpAlphaFlag = 1 << 4
+	// webpNoBlendFlag makes an ANMF frame replace the canvas instead of
+	// being alpha-blended onto it
+	webpNoBlendFlag = 1 << 1
+	// webpDisposeFlag clears an ANMF frame's rectangle after display
+	webpDisposeFlag = 1 << 0
+	// webpMaxCanvas is the largest canvas side a VP8X chunk can hold
+	webpMaxCanvas = 1 << 24
+	// webpMaxDuration is the largest frame duration in milliseconds
+	webpMaxDuration = 1<<24 - 1
+	// webpFrameHeaderSize is the size of the fixed part of an ANMF chunk
+	webpFrameHeaderSize = 16
+)
+
+// riffChunk is one chunk of a RIFF container
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+// readChunks splits data into RIFF chunks, honouring the padding byte
+// that follows odd-sized chunks
+func readChunks(data []byte) ([]riffChunk, error) {
+	var chunks []riffChunk
+
+	for len(data) > 0 {
+		// Assertion 1: Every chunk has an eight byte header
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: truncated WebP chunk header", ErrDecode)
+		}
+
+		size := binary.LittleEndian.Uint32(data[4:8])
+
+		// Assertion 2: The payload fits in what is left
+		if uint64(size) > uint64(len(data)-8) {
+			return nil, fmt.Errorf("%w: WebP chunk %q overruns the file", ErrDecode, data[:4])
+		}
+
+		chunks = append(chunks, riffChunk{id: string(data[:4]), data: data[8 : 8+size]})
+		data = data[8+size:]
+
+		if size%2 == 1 && len(data) > 0 {
+			data = data[1:]
+		}
+	}
+
+	return chunks, nil
+}
+
+// appendChunk appends a RIFF chunk with its padding byte
+func appendChunk(dst []byte, id string, data []byte) []byte {
+	dst = append(dst, id...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(data)))
+	dst = append(dst, data...)
+
+	if len(data)%2 == 1 {
+		dst = append(dst, 0)
+	}
+
+	return dst
+}
+
+// riffWebP wraps chunks in a RIFF WEBP container
+func riffWebP(chunks []byte) []byte {
+	out := make([]byte, 0, 12+len(chunks))
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(4+len(chunks)))
+	out = append(out, "WEBP"...)
+	return append(out, chunks...)
+}
+
+// uint24 reads a little-endian 24-bit value
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+// appendUint24 appends v as a little-endian 24-bit value
+func appendUint24(dst []byte, v int) []byte {
+	return append(dst, byte(v), byte(v>>8), byte(v>>16))
+}
+
+// decodeWebP decodes a still or animated WebP file, compositing the
+// frames of an animation onto its canvas
+func decodeWebP(r io.Reader) (*Animation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	// Assertion 1: Validate the RIFF header
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%w: not a WebP file", ErrDecode)
+	}
+
+	size := int(binary.LittleEndian.Uint32(data[4:8]))
+	chunks, err := readChunks(data[12:min(len(data), 8+size)])
+	if err != nil {
+		return nil, err
+	}
+
+	// Assertion 2: Anything but an animated VP8X file is a still image
+	if len(chunks) == 0 || chunks[0].id != "VP8X" || len(chunks[0].data) < 10 || chunks[0].data[0]&webpAnimationFlag == 0 {
+		img, err := webp.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+		}
+
+		return &Animation{Frames: []Frame{{Image: img}}}, nil
+	}
+
+	header := chunks[0].data
+	width, height := 1+uint24(header[4:7]), 1+uint24(header[7:10])
+	if err := validator.ValidateDimensions(width, height); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	anim := &Animation{}
+
+	for i := 1; i < len(chunks); i++ {
+		switch chunks[i].id {
+		case "ANIM":
+			if len(chunks[i].data) < 6 {
+				return nil, fmt.Errorf("%w: short ANIM chunk", ErrDecode)
+			}
+
+			b := chunks[i].data
+			anim.Background = color.NRGBA{R: b[2], G: b[1], B: b[0], A: b[3]}
+			anim.LoopCount = int(binary.LittleEndian.Uint16(b[4:6]))
+		case "ANMF":
+			if err := anim.addWebPFrame(canvas, chunks[i].data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Assertion 3: An animation must hold at least one frame
+	if len(anim.Frames) == 0 {
+		return nil, fmt.Errorf("%w: animated WebP without frames", ErrDecode)
+	}
+
+	return anim, nil
+}
+
+// addWebPFrame decodes one ANMF chunk, draws it onto canvas and appends a
+// snapshot of the canvas as the next frame
+func (a *Animation) addWebPFrame(canvas *image.NRGBA, data []byte) error {
+	// Assertion 1: Bound the number of frames held in memory
+	if len(a.Frames) >= MaxAnimationFrames {
+		return fmt.Errorf("%w: more than %d frames", ErrDecode, MaxAnimationFrames)
+	}
+
+	if len(data) < webpFrameHeaderSize {
+		return fmt.Errorf("%w: short ANMF chunk", ErrDecode)
+	}
+
+	x, y := 2*uint24(data[0:3]), 2*uint24(data[3:6])
+	duration := uint24(data[12:15])
+	flags := data[15]
+
+	img, err := decodeWebPFrame(data[webpFrameHeaderSize:], 1+uint24(data[6:9]), 1+uint24(data[9:12]))
+	if err != nil {
+		return err
+	}
+
+	// Assertion 2: The frame must lie on the canvas
+	rect := image.Rect(x, y, x+img.Bounds().Dx(), y+img.Bounds().Dy())
+	if !rect.In(canvas.Bounds()) {
+		return fmt.Errorf("%w: frame %v outside the canvas", ErrDecode, rect)
+	}
+
+	op := draw.Over
+	if flags&webpNoBlendFlag != 0 {
+		op = draw.Src
+	}
+
+	draw.Draw(canvas, rect, img, img.Bounds().Min, op)
+
+	frame := image.NewNRGBA(canvas.Bounds())
+	copy(frame.Pix, canvas.Pix)
+	next := Frame{Image: frame, Duration: time.Duration(duration) * time.Millisecond, Disposal: DisposalNone}
+
+	if flags&webpDisposeFlag != 0 {
+		draw.Draw(canvas, rect, image.Transparent, image.Point{}, draw.Src)
+		next.Disposal = DisposalBackground
+	}
+
+	a.Frames = append(a.Frames, next)
+
+	return nil
+}
+
+// decodeWebPFrame decodes the bitstream chunks of one ANMF frame by
+// wrapping them as a standalone WebP file
+func decodeWebPFrame(data []byte, width, height int) (image.Image, error) {
+	chunks, err := readChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	hasAlpha := false
+	for i := 0; i < len(chunks); i++ {
+		switch chunks[i].id {
+		case "ALPH":
+			hasAlpha = true
+			body = appendChunk(body, chunks[i].id, chunks[i].data)
+		case "VP8 ", "VP8L":
+			body = appendChunk(body, chunks[i].id, chunks[i].data)
+		}
+	}
+
+	// Lossy frames with an alpha plane need a VP8X header announcing it
+	if hasAlpha {
+		header := []byte{webpAlphaFlag, 0, 0, 0}
+		header = appendUint24(header, width-1)
+		header = appendUint24(header, height-1)
+		body = append(appendChunk(nil, "VP8X", header), body...)
+	}
+
+	img, err := webp.Decode(bytes.NewReader(riffWebP(body)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	return img, nil
+}
+
+// encodeWebP writes img as a lossless still WebP file
+func encodeWebP(w io.Writer, img image.Image) error {
+	bitstream, err := encodeVP8L(img)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(riffWebP(appendChunk(nil, "VP8L", bitstream)))
+	return err
+}
+
+// encodeAnimatedWebP writes anim as a lossless animated WebP file, each
+// frame covering the whole canvas
+func encodeAnimatedWebP(w io.Writer, anim *Animation) error {
+	bounds := anim.Frames[0].Image.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Assertion 1: The canvas size must fit the VP8X chunk
+	if width > webpMaxCanvas || height > webpMaxCanvas {
+		return fmt.Errorf("WebP canvas is limited to %d pixels per side", webpMaxCanvas)
+	}
+
+	header := []byte{webpAnimationFlag | webpAlphaFlag, 0, 0, 0}
+	header = appendUint24(header, width-1)
+	header = appendUint24(header, height-1)
+	body := appendChunk(nil, "VP8X", header)
+
+	bg := anim.Background
+	params := []byte{bg.B, bg.G, bg.R, bg.A}
+	params = binary.LittleEndian.AppendUint16(params, uint16(anim.LoopCount))
+	body = appendChunk(body, "ANIM", params)
+
+	for i := 0; i < len(anim.Frames); i++ {
+		bitstream, err := encodeVP8L(anim.Frames[i].Image)
+		if err != nil {
+			return err
+		}
+
+		duration := min(webpMaxDuration, max(0, int(anim.Frames[i].Duration/time.Millisecond)))
+
+		frame := make([]byte, 0, webpFrameHeaderSize+8+len(bitstream))
+		frame = appendUint24(frame, 0)
+		frame = appendUint24(frame, 0)
+		frame = appendUint24(frame, width-1)
+		frame = appendUint24(frame, height-1)
+		frame = appendUint24(frame, duration)
+		flags := byte(webpNoBlendFlag)
+		if anim.Frames[i].Disposal == DisposalBackground {
+			flags |= webpDisposeFlag
+		}
+
+		frame = append(frame, flags)
+		frame = appendChunk(frame, "VP8L", bitstream)
+
+		body = appendChunk(body, "ANMF", frame)
+	}
+
+	_, err := w.Write(riffWebP(body))
+	return err
+}
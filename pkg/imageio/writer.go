@@ -0,0 +1,69 @@
+// Open source image resizer coded by kasuraSH
+package imageio
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+)
+
+// WriteImage encodes img in the format named by opts.Format and writes it
+// to w, for a stream such as standard output
+func WriteImage(w io.Writer, img image.Image, opts EncodeOptions) error {
+	return WriteImageContext(context.Background(), w, img, opts)
+}
+
+// WriteImageContext writes img like WriteImage, giving up when ctx is
+// done before anything is written; the whole image is encoded first, so
+// w never receives part of a failed one
+func WriteImageContext(ctx context.Context, w io.Writer, img image.Image, opts EncodeOptions) error {
+	data, err := untilDone(ctx, func() ([]byte, error) {
+		return EncodeImage(img, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeStream(w, data)
+}
+
+// WriteAnimation encodes every frame of anim like EncodeAnimation and
+// writes them to w
+func WriteAnimation(w io.Writer, anim *Animation, opts EncodeOptions) error {
+	return WriteAnimationContext(context.Background(), w, anim, opts)
+}
+
+// WriteAnimationContext writes anim like WriteAnimation, giving up when
+// ctx is done before anything is written
+func WriteAnimationContext(ctx context.Context, w io.Writer, anim *Animation, opts EncodeOptions) error {
+	data, err := untilDone(ctx, func() ([]byte, error) {
+		return EncodeAnimation(anim, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeStream(w, data)
+}
+
+// WriteIcon encodes images as the entries of one ICO file and writes it
+// to w
+func WriteIcon(w io.Writer, images []image.Image) error {
+	data, err := EncodeIcon(images)
+	if err != nil {
+		return err
+	}
+
+	return writeStream(w, data)
+}
+
+// writeStream writes data to w in full
+func writeStream(w io.Writer, data []byte) error {
+	// Assertion 1: Check write result
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("%w: %v", ErrFileCreate, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+// Open source image resizer coded by kasuraSH
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// The build stamps these with -ldflags, for example
+//
+//	-X github.com/kasurarykerion/golangresizer/pkg/version.Version=v1.4.0
+//
+// as the Makefile does; left empty they are read from the build info Go
+// records in every binary
+var (
+	Version string // Release such as v1.4.0
+	Commit  string // VCS revision the binary was built from
+	Date    string // Build or commit time, RFC 3339
+)
+
+// fallbackVersion names a build with neither a stamp nor a module version,
+// such as go run or go build in a checkout without VCS information
+const fallbackVersion = "dev"
+
+// Info describes the build of the running binary
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"` // GOOS/GOARCH
+}
+
+// Get returns the build information: the values stamped by -ldflags, and
+// for the ones left empty the module version and VCS settings Go embeds,
+// so go install module@version and a plain go build both report theirs
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.fill(build)
+	}
+
+	if info.Version == "" {
+		info.Version = fallbackVersion
+	}
+
+	return info
+}
+
+// fill takes what the stamped values leave empty from build
+func (i *Info) fill(build *debug.BuildInfo) {
+	if i.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		i.Version = build.Main.Version
+	}
+
+	if build.GoVersion != "" {
+		i.GoVersion = build.GoVersion
+	}
+
+	// The VCS settings describe the checkout of a go build, and a stamped
+	// commit keeps its own date and state
+	stamped := i.Commit != ""
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if !stamped {
+				i.Commit = setting.Value
+			}
+		case "vcs.time":
+			if !stamped && i.Date == "" {
+				i.Date = setting.Value
+			}
+		case "vcs.modified":
+			if !stamped {
+				i.Modified = setting.Value == "true"
+			}
+		}
+	}
+}
+
+// String returns the version with the short commit and date, such as
+// "v1.4.0 (3f2a9c1, 2026-10-18T09:30:00Z)"
+func (i Info) String() string {
+	var details []string
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, commit)
+	}
+
+	if i.Date != "" {
+		details = append(details, i.Date)
+	}
+
+	if len(details) == 0 {
+		return i.Version
+	}
+
+	return fmt.Sprintf("%s (%s)", i.Version, strings.Join(details, ", "))
+}